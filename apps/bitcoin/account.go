@@ -50,6 +50,9 @@ func UnmarshalWitnessScriptAccount(extra []byte) (*WitnessScriptAccount, error)
 }
 
 func ExtractPkScriptAddr(pkScript []byte, chain byte) (string, error) {
+	if chain == ChainBitcoinCash {
+		return extractCashAddress(pkScript)
+	}
 	cls, addrs, threshold, err := txscript.ExtractPkScriptAddrs(pkScript, NetConfig(chain))
 	if err != nil {
 		return "", err
@@ -64,12 +67,13 @@ func EncodeAddress(script []byte, chain byte) (string, error) {
 	typ := checkScriptType(script)
 	switch typ {
 	case InputTypeP2WSHMultisigHolderSigner:
-		msh := sha256.Sum256(script)
-		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+		return encodeScriptHashAddress(script, chain)
+	case InputTypeP2TRHolderSigner:
+		tst, err := parseTaprootScript(script)
 		if err != nil {
 			return "", err
 		}
-		return mwsh.EncodeAddress(), nil
+		return tst.encodeAddress(chain)
 	default:
 		panic(typ)
 	}
@@ -151,21 +155,52 @@ func BuildWitnessScriptAccount(holder, signer, observer string, lock time.Durati
 	if err != nil {
 		return nil, fmt.Errorf("build.Script() => %v", err)
 	}
-	msh := sha256.Sum256(script)
-	mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+	addr, err := encodeScriptHashAddress(script, chain)
 	if err != nil {
-		return nil, fmt.Errorf("btcutil.NewAddressWitnessScriptHash(%x) => %v", msh[:], err)
+		return nil, fmt.Errorf("encodeScriptHashAddress(%x) => %v", script, err)
 	}
 
 	return &WitnessScriptAccount{
 		Sequence: uint32(sequence),
 		Script:   script,
-		Address:  mwsh.EncodeAddress(),
+		Address:  addr,
 	}, nil
 }
 
+// bitcoin cash has no segwit, so the same script is paid to the legacy
+// P2SH address and spent with the script sig instead of the witness
+func encodeScriptHashAddress(script []byte, chain byte) (string, error) {
+	if chain == ChainBitcoinCash {
+		return encodeCashScriptHashAddress(script)
+	}
+	msh := sha256.Sum256(script)
+	mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+	if err != nil {
+		return "", err
+	}
+	return mwsh.EncodeAddress(), nil
+}
+
+func EncodeAccountantAddress(public []byte, chain byte) (string, error) {
+	if chain == ChainBitcoinCash {
+		return encodeCashAddress(cashAddressTypeP2PKH, btcutil.Hash160(public))
+	}
+	awpkh, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(public), NetConfig(chain))
+	if err != nil {
+		return "", err
+	}
+	return awpkh.EncodeAddress(), nil
+}
+
 func CheckMultisigHolderSignerScript(script []byte) bool {
-	return checkScriptType(script) == InputTypeP2WSHMultisigHolderSigner
+	switch checkScriptType(script) {
+	case InputTypeP2WSHMultisigHolderSigner:
+		return true
+	case InputTypeP2TRHolderSigner:
+		return true
+	default:
+		return false
+	}
 }
 
 func parseBitcoinCompressedPublicKey(public string) (*btcutil.AddressPubKey, error) {
@@ -182,6 +217,8 @@ func ValueDust(chain byte) int64 {
 		return 1000
 	case ChainLitecoin:
 		return 10000
+	case ChainBitcoinCash:
+		return 1000
 	default:
 		panic(chain)
 	}
@@ -191,7 +228,7 @@ func protocolVersion(chain byte) uint32 {
 	switch chain {
 	case ChainBitcoin:
 		return wire.ProtocolVersion
-	case ChainLitecoin:
+	case ChainLitecoin, ChainBitcoinCash:
 		return 70015
 	default:
 		panic(chain)
@@ -223,6 +260,16 @@ func NetConfig(chain byte) *chaincfg.Params {
 			HDPublicKeyID:  [4]byte{0x01, 0x9d, 0xa4, 0x64},
 			HDPrivateKeyID: [4]byte{0x01, 0x9d, 0x9c, 0xfe},
 		}
+	case ChainBitcoinCash:
+		return &chaincfg.Params{
+			Net: 0xe8f3e1e3,
+
+			PubKeyHashAddrID: 0x00,
+			ScriptHashAddrID: 0x05,
+
+			HDPublicKeyID:  [4]byte{0x04, 0x88, 0xb2, 0x1e},
+			HDPrivateKeyID: [4]byte{0x04, 0x88, 0xad, 0xe4},
+		}
 	default:
 		panic(chain)
 	}
@@ -232,6 +279,9 @@ func checkScriptType(script []byte) int {
 	if len(script) == 33 {
 		return InputTypeP2WPKHAccoutant
 	}
+	if CheckTaprootScript(script) {
+		return InputTypeP2TRHolderSigner
+	}
 	if len(script) > 100 {
 		return InputTypeP2WSHMultisigHolderSigner
 	}
@@ -0,0 +1,92 @@
+package bitcoin
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"slices"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+type BatchTransaction struct {
+	PSBT    *PartiallySignedTransaction
+	Inputs  []*Input
+	Outputs []*Output
+}
+
+// BuildPartiallySignedBatch splits the outputs into consecutive chunks and
+// builds one transaction for each chunk, every transaction spends just enough
+// inputs to cover its outputs and stays within both MaxUnspentUtxo and
+// MaxStandardTxWeight. The inputs are consumed from the largest, and those not
+// needed by the batch are left untouched.
+func BuildPartiallySignedBatch(mainInputs []*Input, outputs []*Output, rid []byte, chain byte) ([]*BatchTransaction, error) {
+	inputs := slices.Clone(mainInputs)
+	slices.SortStableFunc(inputs, func(a, b *Input) int {
+		switch {
+		case a.Satoshi > b.Satoshi:
+			return -1
+		case a.Satoshi < b.Satoshi:
+			return 1
+		}
+		return 0
+	})
+
+	var batch []*BatchTransaction
+	for next := 0; len(outputs) > 0; {
+		start, count := next, 0
+		var inputSatoshi, outputSatoshi int64
+		for count < len(outputs) {
+			need := outputSatoshi + outputs[count].Satoshi
+			end, sum := next, inputSatoshi
+			for ; sum < need && end < len(inputs); end++ {
+				sum = sum + inputs[end].Satoshi
+			}
+			if sum < need {
+				return nil, BuildInsufficientInputError("main", fmt.Sprint(sum), fmt.Sprint(need))
+			}
+			if count > 0 && !checkBatchTransactionSize(end-start, count+1, rid) {
+				break
+			}
+			inputSatoshi, outputSatoshi, next, count = sum, need, end, count+1
+		}
+		psbt, err := BuildPartiallySignedTransaction(inputs[start:next], outputs[:count], rid, chain)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, &BatchTransaction{
+			PSBT:    psbt,
+			Inputs:  inputs[start:next],
+			Outputs: outputs[:count],
+		})
+		outputs = outputs[count:]
+	}
+	return batch, nil
+}
+
+// HashBatchForSignature returns the message hash the holder signs to approve
+// all transactions of the batch proposed by the request rid at once.
+func HashBatchForSignature(rid string, hashes []string, chain byte) []byte {
+	digest := sha256.New()
+	for _, h := range hashes {
+		hash, err := chainhash.NewHashFromStr(h)
+		if err != nil {
+			panic(h)
+		}
+		digest.Write(hash[:])
+	}
+	msg := fmt.Sprintf("APPROVE:%s:%x", rid, digest.Sum(nil))
+	return HashMessageForSignature(msg, chain)
+}
+
+// the same estimation as BuildPartiallySignedTransaction, with a change
+// output and the request id return output always counted
+func checkBatchTransactionSize(inputs, outputs int, rid []byte) bool {
+	if inputs > MaxUnspentUtxo {
+		return false
+	}
+	estvb := (40 + inputs*300 + (outputs+2)*128) / 4
+	if len(rid) > 0 && len(rid) <= 64 {
+		estvb += len(rid)
+	}
+	return estvb*4 <= MaxStandardTxWeight
+}
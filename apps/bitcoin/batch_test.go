@@ -0,0 +1,70 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPartiallySignedBatch(t *testing.T) {
+	require := require.New(t)
+	hk, sk, ok := testTaprootKeys()
+	holder := hex.EncodeToString(hk.PubKey().SerializeCompressed())
+	signer := hex.EncodeToString(sk.PubKey().SerializeCompressed())
+	observer := hex.EncodeToString(ok.PubKey().SerializeCompressed())
+	wsa, err := BuildWitnessScriptAccount(holder, signer, observer, time.Hour*24*90, ChainBitcoin)
+	require.Nil(err)
+
+	var inputs []*Input
+	for i := 0; i < 1200; i++ {
+		inputs = append(inputs, &Input{
+			TransactionHash: fmt.Sprintf("%064x", i+1),
+			Index:           uint32(i % 3),
+			Satoshi:         100000,
+			Script:          wsa.Script,
+			Sequence:        wsa.Sequence,
+		})
+	}
+	var outputs []*Output
+	for i := 0; i < 2000; i++ {
+		outputs = append(outputs, &Output{Address: wsa.Address, Satoshi: 50000})
+	}
+
+	rid := []byte("batch")
+	batch, err := BuildPartiallySignedBatch(inputs, outputs, rid, ChainBitcoin)
+	require.Nil(err)
+	require.Len(batch, 2)
+
+	spent := make(map[string]bool)
+	var hashes []string
+	var paid int
+	for _, bt := range batch {
+		msgTx := bt.PSBT.UnsignedTx
+		require.Len(bt.Inputs, len(msgTx.TxIn))
+		require.LessOrEqual(len(msgTx.TxIn), MaxUnspentUtxo)
+		for _, in := range msgTx.TxIn {
+			pop := in.PreviousOutPoint.String()
+			require.False(spent[pop])
+			spent[pop] = true
+		}
+		for _, out := range msgTx.TxOut {
+			if out.Value == 50000 {
+				paid++
+			}
+		}
+		paid = paid - len(bt.Outputs)
+		hashes = append(hashes, bt.PSBT.Hash())
+	}
+	require.Equal(0, paid)
+	require.Equal(1000, len(spent))
+
+	msg := HashBatchForSignature("request", hashes, ChainBitcoin)
+	require.Len(msg, 32)
+	require.NotEqual(msg, HashBatchForSignature("request", hashes[:1], ChainBitcoin))
+
+	_, err = BuildPartiallySignedBatch(inputs[:999], outputs, rid, ChainBitcoin)
+	require.True(IsInsufficientInputError(err))
+}
@@ -0,0 +1,138 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinCashAddress(t *testing.T) {
+	require := require.New(t)
+
+	hash, _ := hex.DecodeString("f5bf48b397dae70be82b3cca4793f8eb2b6cdac9")
+	addr, err := encodeCashAddress(cashAddressTypeP2PKH, hash)
+	require.Nil(err)
+	require.Equal("bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2", addr)
+
+	legacy, err := btcutil.DecodeAddress("3CWFddi6m4ndiGyKqzYvsFYagqDLPVMTzC", &chaincfg.MainNetParams)
+	require.Nil(err)
+	addr, err = encodeCashAddress(cashAddressTypeP2SH, legacy.ScriptAddress())
+	require.Nil(err)
+	require.Equal("bitcoincash:ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq", addr)
+	script, err := ParseAddress(addr, ChainBitcoinCash)
+	require.Nil(err)
+	require.Equal(txscript.ScriptHashTy, txscript.GetScriptClass(script))
+	require.Equal(legacy.ScriptAddress(), script[2:22])
+
+	for _, a := range []string{
+		"bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2",
+		"qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2",
+		"BITCOINCASH:QR6M7J9NJLDWWZLG9V7V53UNLR4JKMX6EYLEP8EKG2",
+	} {
+		script, err := ParseAddress(a, ChainBitcoinCash)
+		require.Nil(err)
+		require.Equal(txscript.PubKeyHashTy, txscript.GetScriptClass(script))
+		addr, err := ExtractPkScriptAddr(script, ChainBitcoinCash)
+		require.Nil(err)
+		require.Equal("bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2", addr)
+	}
+
+	_, err = ParseAddress("bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg3", ChainBitcoinCash)
+	require.NotNil(err)
+	_, err = ParseAddress("bitcoincash:Qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2", ChainBitcoinCash)
+	require.NotNil(err)
+	_, err = ParseAddress("pr6m7j9njldwwzlg9v7v53unlr4jkmx6eyvwc0uz5t", ChainBitcoinCash)
+	require.NotNil(err)
+	_, err = ParseAddress("bchtest:pr6m7j9njldwwzlg9v7v53unlr4jkmx6eyvwc0uz5t", ChainBitcoinCash)
+	require.NotNil(err)
+	_, err = ParseAddress("1DdhSdxiLepsH2YuiVxt8n85UHmWp4qjUt", ChainBitcoinCash)
+	require.NotNil(err)
+	_, err = ParseAddress("bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2", ChainBitcoin)
+	require.NotNil(err)
+}
+
+func TestBitcoinCashTransaction(t *testing.T) {
+	require := require.New(t)
+	hk, sk, ok := testTaprootKeys()
+	holder := hex.EncodeToString(hk.PubKey().SerializeCompressed())
+	signer := hex.EncodeToString(sk.PubKey().SerializeCompressed())
+	observer := hex.EncodeToString(ok.PubKey().SerializeCompressed())
+
+	wsa, err := BuildWitnessScriptAccount(holder, signer, observer, time.Hour*24*90, ChainBitcoinCash)
+	require.Nil(err)
+	require.Equal("bitcoincash:p", wsa.Address[:13])
+	addr, err := EncodeAddress(wsa.Script, ChainBitcoinCash)
+	require.Nil(err)
+	require.Equal(wsa.Address, addr)
+	bwsa, err := BuildWitnessScriptAccount(holder, signer, observer, time.Hour*24*90, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(bwsa.Script, wsa.Script)
+
+	receiver := "bitcoincash:qr6m7j9njldwwzlg9v7v53unlr4jkmx6eylep8ekg2"
+	inputs := []*Input{{
+		TransactionHash: "9f3ec2b3d5b1ac1fdc1d5d3ba5c3a2b1b1f5b6c1e5a0d9b5c3f2a1e0d9c8b7a6",
+		Index:           1,
+		Satoshi:         100000,
+		Script:          wsa.Script,
+		Sequence:        wsa.Sequence,
+	}}
+	outputs := []*Output{{Address: receiver, Satoshi: 80000}}
+	psbt, err := BuildPartiallySignedTransaction(inputs, outputs, []byte("bitcoincash"), ChainBitcoinCash)
+	require.Nil(err)
+	require.Len(psbt.UnsignedTx.TxOut, 3)
+	require.True(psbt.IsScriptHashInput(0))
+	require.False(psbt.IsTaprootInput(0))
+	require.Nil(psbt.Inputs[0].WitnessScript)
+	require.Equal(SigHashType|SigHashForkID, psbt.Inputs[0].SighashType)
+	change, err := ExtractPkScriptAddr(psbt.UnsignedTx.TxOut[1].PkScript, ChainBitcoinCash)
+	require.Nil(err)
+	require.Equal(wsa.Address, change)
+
+	spsbt := SignPartiallySignedTransaction(psbt.Marshal(), hk)
+	require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), holder))
+	spsbt = SignPartiallySignedTransaction(spsbt.Marshal(), sk)
+	require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), signer))
+	hash := spsbt.SigHash(0)
+
+	msgTx, err := spsbt.SignedTransaction(holder, signer, observer)
+	require.Nil(err)
+	require.False(msgTx.HasWitness())
+	pushes, err := txscript.PushedData(msgTx.TxIn[0].SignatureScript)
+	require.Nil(err)
+	require.Len(pushes, 4)
+	require.Len(pushes[0], 0)
+	require.Equal(wsa.Script, pushes[3])
+	for i, pub := range []string{signer, holder} {
+		sig := pushes[i+1]
+		require.Equal(byte(SigHashType|SigHashForkID), sig[len(sig)-1])
+		require.Nil(VerifySignatureDER(pub, hash, sig[:len(sig)-1]))
+	}
+	pkScript := psbt.Inputs[0].WitnessUtxo.PkScript
+	require.True(bytes.Equal(pkScript[2:22], btcutil.Hash160(pushes[3])))
+
+	raw, err := MarshalWiredTransaction(msgTx, wire.WitnessEncoding, ChainBitcoinCash)
+	require.Nil(err)
+	require.LessOrEqual(len(raw), spsbt.EstimateVirtualSize())
+
+	accountant := "c663c88aab70d1539b22f475cb8febc714dc61b9a43b472dc1ef970786cf31f9"
+	feeInputs := []*Input{{
+		TransactionHash: "1f3ec2b3d5b1ac1fdc1d5d3ba5c3a2b1b1f5b6c1e5a0d9b5c3f2a1e0d9c8b7a6",
+		Index:           0,
+		Satoshi:         5000,
+	}}
+	ftx, err := SpendSignedTransaction(hex.EncodeToString(raw), feeInputs, accountant, ChainBitcoinCash)
+	require.Nil(err)
+	require.False(ftx.HasWitness())
+	require.Len(ftx.TxIn, 2)
+	pushes, err = txscript.PushedData(ftx.TxIn[1].SignatureScript)
+	require.Nil(err)
+	require.Len(pushes, 2)
+	require.Equal(byte(txscript.SigHashAll|SigHashForkID), pushes[0][len(pushes[0])-1])
+}
@@ -0,0 +1,161 @@
+package bitcoin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// https://github.com/bitcoincashorg/bitcoincash.org/blob/master/spec/cashaddr.md
+const (
+	cashAddressPrefix        = "bitcoincash"
+	cashAddressPrefixTestnet = "bchtest"
+	cashAddressPrefixRegtest = "bchreg"
+	cashAddressCharset       = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	cashAddressTypeP2PKH = 0
+	cashAddressTypeP2SH  = 1
+)
+
+func encodeCashAddress(typ byte, hash []byte) (string, error) {
+	if len(hash) != 20 {
+		return "", fmt.Errorf("invalid cash address hash %x", hash)
+	}
+	payload := append([]byte{typ << 3}, hash...)
+	data, err := bech32.ConvertBits(payload, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	checksum := cashAddressPolyMod(cashAddressExpandPrefix(cashAddressPrefix), data, make([]byte, 8))
+	var sb strings.Builder
+	sb.WriteString(cashAddressPrefix)
+	sb.WriteByte(':')
+	for _, d := range data {
+		sb.WriteByte(cashAddressCharset[d])
+	}
+	for i := 0; i < 8; i++ {
+		sb.WriteByte(cashAddressCharset[(checksum>>uint(5*(7-i)))&0x1f])
+	}
+	return sb.String(), nil
+}
+
+// the prefix is the network of the cash address, it may be omitted for the
+// implied mainnet prefix, and the testnet or regtest ones are rejected
+func decodeCashAddress(addr string) (byte, []byte, error) {
+	if strings.ToLower(addr) != addr && strings.ToUpper(addr) != addr {
+		return 0, nil, fmt.Errorf("mixed case cash address %s", addr)
+	}
+	addr = strings.ToLower(addr)
+	prefix, body, found := strings.Cut(addr, ":")
+	if !found {
+		prefix, body = cashAddressPrefix, addr
+	}
+	switch prefix {
+	case cashAddressPrefix:
+	case cashAddressPrefixTestnet, cashAddressPrefixRegtest:
+		return 0, nil, fmt.Errorf("invalid cash address network %s", addr)
+	default:
+		return 0, nil, fmt.Errorf("invalid cash address prefix %s", addr)
+	}
+	if len(body) <= 8 {
+		return 0, nil, fmt.Errorf("invalid cash address length %s", addr)
+	}
+	data := make([]byte, len(body))
+	for i, c := range []byte(body) {
+		idx := strings.IndexByte(cashAddressCharset, c)
+		if idx < 0 {
+			return 0, nil, fmt.Errorf("invalid cash address character %s", addr)
+		}
+		data[i] = byte(idx)
+	}
+	if cashAddressPolyMod(cashAddressExpandPrefix(prefix), data) != 0 {
+		return 0, nil, fmt.Errorf("invalid cash address checksum %s", addr)
+	}
+	payload, err := bech32.ConvertBits(data[:len(data)-8], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) != 21 || payload[0]&0x07 != 0 {
+		return 0, nil, fmt.Errorf("invalid cash address version %s", addr)
+	}
+	typ := payload[0] >> 3
+	switch typ {
+	case cashAddressTypeP2PKH, cashAddressTypeP2SH:
+	default:
+		return 0, nil, fmt.Errorf("invalid cash address type %s", addr)
+	}
+	return typ, payload[1:], nil
+}
+
+func parseCashAddress(addr string) ([]byte, error) {
+	typ, hash, err := decodeCashAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	builder := txscript.NewScriptBuilder()
+	switch typ {
+	case cashAddressTypeP2PKH:
+		builder.AddOp(txscript.OP_DUP)
+		builder.AddOp(txscript.OP_HASH160)
+		builder.AddData(hash)
+		builder.AddOp(txscript.OP_EQUALVERIFY)
+		builder.AddOp(txscript.OP_CHECKSIG)
+	case cashAddressTypeP2SH:
+		builder.AddOp(txscript.OP_HASH160)
+		builder.AddData(hash)
+		builder.AddOp(txscript.OP_EQUAL)
+	}
+	return builder.Script()
+}
+
+func extractCashAddress(pkScript []byte) (string, error) {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.PubKeyHashTy:
+		return encodeCashAddress(cashAddressTypeP2PKH, pkScript[3:23])
+	case txscript.ScriptHashTy:
+		return encodeCashAddress(cashAddressTypeP2SH, pkScript[2:22])
+	default:
+		return "", fmt.Errorf("unsupported cash pkscript %x", pkScript)
+	}
+}
+
+func encodeCashScriptHashAddress(script []byte) (string, error) {
+	return encodeCashAddress(cashAddressTypeP2SH, btcutil.Hash160(script))
+}
+
+func cashAddressExpandPrefix(prefix string) []byte {
+	data := make([]byte, len(prefix)+1)
+	for i := range prefix {
+		data[i] = prefix[i] & 0x1f
+	}
+	return data
+}
+
+func cashAddressPolyMod(parts ...[]byte) uint64 {
+	c := uint64(1)
+	for _, v := range parts {
+		for _, d := range v {
+			c0 := byte(c >> 35)
+			c = ((c & 0x07ffffffff) << 5) ^ uint64(d)
+			if c0&0x01 != 0 {
+				c ^= 0x98f2bc8e61
+			}
+			if c0&0x02 != 0 {
+				c ^= 0x79b76d99e2
+			}
+			if c0&0x04 != 0 {
+				c ^= 0xf33e5fb3c4
+			}
+			if c0&0x08 != 0 {
+				c ^= 0xae2eabe2a8
+			}
+			if c0&0x10 != 0 {
+				c ^= 0x1e4f43e470
+			}
+		}
+	}
+	return c ^ 1
+}
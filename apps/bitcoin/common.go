@@ -18,8 +18,9 @@ import (
 )
 
 const (
-	ChainBitcoin  = 1
-	ChainLitecoin = 5
+	ChainBitcoin     = 1
+	ChainLitecoin    = 5
+	ChainBitcoinCash = 7
 
 	ValuePrecision = 8
 	ValueSatoshi   = 100000000
@@ -31,11 +32,17 @@ const (
 
 	ScriptPubKeyTypeWitnessKeyHash    = "witness_v0_keyhash"
 	ScriptPubKeyTypeWitnessScriptHash = "witness_v0_scripthash"
+	ScriptPubKeyTypeWitnessTaproot    = "witness_v1_taproot"
+	ScriptPubKeyTypeScriptHash        = "scripthash"
+	ScriptPubKeyTypePubKeyHash        = "pubkeyhash"
 	SigHashType                       = txscript.SigHashAll | txscript.SigHashAnyOneCanPay
+	SigHashForkID                     = txscript.SigHashType(0x40)
 
 	InputTypeP2WPKHAccoutant             = 1
 	InputTypeP2WSHMultisigHolderSigner   = 2
 	InputTypeP2WSHMultisigObserverSigner = 3
+	InputTypeP2TRHolderSigner            = 4
+	InputTypeP2TRObserverSigner          = 5
 
 	MaxTransactionSequence = 0xffffffff
 	MaxStandardTxWeight    = 300000
@@ -56,9 +63,29 @@ func ParseSatoshi(amount string) int64 {
 	return amt.BigInt().Int64()
 }
 
+// CheckReplaceByFee tells whether the chain nodes relay the BIP125 fee
+// replacements, Litecoin Core disables the mempool replacement by default,
+// and Bitcoin Cash has removed it.
+func CheckReplaceByFee(chain byte) bool {
+	switch chain {
+	case ChainBitcoin:
+		return true
+	case ChainLitecoin, ChainBitcoinCash:
+		return false
+	default:
+		panic(chain)
+	}
+}
+
 func ParseAddress(addr string, chain byte) ([]byte, error) {
 	switch chain {
 	case ChainBitcoin, ChainLitecoin:
+	case ChainBitcoinCash:
+		script, err := parseCashAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("parseCashAddress(%s) => %v", addr, err)
+		}
+		return script, nil
 	default:
 		return nil, fmt.Errorf("ParseAddress(%s, %d)", addr, chain)
 	}
@@ -82,7 +109,7 @@ func ParseSequence(lock time.Duration, chain byte) int64 {
 	}
 	blockDuration := 10 * time.Minute
 	switch chain {
-	case ChainBitcoin:
+	case ChainBitcoin, ChainBitcoinCash:
 	case ChainLitecoin:
 		blockDuration = 150 * time.Second
 	default:
@@ -101,6 +128,8 @@ func CheckFeeRange(fvb int64, chain byte) bool {
 		return fvb >= 2 && fvb <= 1000
 	case ChainLitecoin:
 		return fvb >= 1 && fvb <= 20
+	case ChainBitcoinCash:
+		return fvb >= 1 && fvb <= 20
 	default:
 		panic(chain)
 	}
@@ -152,7 +181,7 @@ func HashMessageForSignature(msg string, chain byte) []byte {
 	var buf bytes.Buffer
 	prefix := "Bitcoin Signed Message:\n"
 	switch chain {
-	case ChainBitcoin:
+	case ChainBitcoin, ChainBitcoinCash:
 	case ChainLitecoin:
 		prefix = "Litecoin Signed Message:\n"
 	default:
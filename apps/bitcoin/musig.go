@@ -0,0 +1,517 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// The cooperative spend of a taproot safe is the key path of the MuSig2
+// aggregated key of the holder and the signer, and the signer key is a FROST
+// threshold key, so the signer members act together as the MuSig2 signer.
+//
+// The holder sends the public nonce first, together with a BIP340 signature
+// of the sighash and the nonce to approve the transaction. Then the signer
+// members produce the signer nonce and partial signature with the holder
+// nonce, and at last the holder makes its partial signature and combines them
+// to the key path signature. So the holder secret nonce must be kept until the
+// signer partial signature is ready, and never used for another transaction.
+//
+// The nonces and partial signatures are kept in the PSBT input with the BIP373
+// key types, and the holder approval signature with a proprietary key type.
+
+const (
+	// sighash || holder || merkle root || holder public nonce
+	TaprootKeySpendMessageSize = 32 + 33 + 32 + musig2.PubNonceSize
+
+	taprootHolderApprovalSize = musig2.PubNonceSize + schnorr.SignatureSize
+	taprootSignerPartialSize  = musig2.PubNonceSize + 32
+
+	psbtMuSig2ParticipantsType = 0x1a
+	psbtMuSig2PubNonceType     = 0x1b
+	psbtMuSig2PartialSigType   = 0x1c
+	psbtProprietaryType        = 0xfc
+)
+
+var (
+	psbtSafeApprovalPrefix = []byte{psbtProprietaryType, 4, 's', 'a', 'f', 'e', 0}
+	taprootApprovalTag     = []byte("safe/taproot-approval")
+)
+
+type TaprootKeySpend struct {
+	Hash        []byte
+	Holder      *btcec.PublicKey
+	Signer      *btcec.PublicKey
+	MerkleRoot  []byte
+	HolderNonce [musig2.PubNonceSize]byte
+}
+
+// ParseTaprootKeySpendMessage parses the message for the signer to make its
+// partial signature, the signer is the x-only public key of the safe.
+func ParseTaprootKeySpendMessage(msg []byte, signer string) (*TaprootKeySpend, error) {
+	if len(msg) != TaprootKeySpendMessageSize {
+		return nil, fmt.Errorf("invalid taproot key spend message %x", msg)
+	}
+	spk, err := parseTaprootPublicKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	spk, err = schnorr.ParsePubKey(schnorr.SerializePubKey(spk))
+	if err != nil {
+		return nil, err
+	}
+	hpk, err := btcec.ParsePubKey(msg[32:65])
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(schnorr.SerializePubKey(hpk), schnorr.SerializePubKey(spk)) {
+		return nil, fmt.Errorf("invalid taproot key spend holder %x", msg[32:65])
+	}
+	ks := &TaprootKeySpend{
+		Hash:       msg[:32],
+		Holder:     hpk,
+		Signer:     spk,
+		MerkleRoot: msg[65:97],
+	}
+	copy(ks.HolderNonce[:], msg[97:])
+	err = checkMuSig2PubNonce(ks.HolderNonce)
+	if err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *TaprootKeySpend) Message() []byte {
+	msg := append([]byte{}, ks.Hash...)
+	msg = append(msg, ks.Holder.SerializeCompressed()...)
+	msg = append(msg, ks.MerkleRoot...)
+	return append(msg, ks.HolderNonce[:]...)
+}
+
+// SignerChallenge returns the values for the signer members to make their
+// shares of the signer partial signature s = k₁ + b⋅k₂ + e⋅c⋅d, and the
+// nonces k₁ and k₂ should be negated if negate is true.
+func (ks *TaprootKeySpend) SignerChallenge(signerNonce [musig2.PubNonceSize]byte) (*btcec.ModNScalar, *btcec.ModNScalar, *btcec.ModNScalar, bool, error) {
+	combined, err := musig2.AggregateNonces([][musig2.PubNonceSize]byte{ks.HolderNonce, signerNonce})
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	agg, parity, _, err := musig2.AggregateKeys(ks.keys(), true, musig2.WithTaprootKeyTweak(ks.MerkleRoot))
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	q := schnorr.SerializePubKey(agg.FinalKey)
+
+	var b btcec.ModNScalar
+	bh := chainhash.TaggedHash(musig2.NonceBlindTag, combined[:], q, ks.Hash)
+	b.SetByteSlice(bh[:])
+	r1, err := btcec.ParseJacobian(combined[:btcec.PubKeyBytesLenCompressed])
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	r2, err := btcec.ParseJacobian(combined[btcec.PubKeyBytesLenCompressed:])
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	var r btcec.JacobianPoint
+	btcec.ScalarMultNonConst(&b, &r2, &r2)
+	btcec.AddNonConst(&r1, &r2, &r)
+	if (r.X.IsZero() && r.Y.IsZero()) || r.Z.IsZero() {
+		btcec.GeneratorJacobian(&r)
+	}
+	r.ToAffine()
+
+	var e btcec.ModNScalar
+	rx := schnorr.SerializePubKey(btcec.NewPublicKey(&r.X, &r.Y))
+	eh := chainhash.TaggedHash(musig2.ChallengeHashTag, rx, q, ks.Hash)
+	e.SetByteSlice(eh[:])
+
+	c := musig2KeyCoefficient(ks.keys(), ks.Signer)
+	if agg.FinalKey.SerializeCompressed()[0] == secp256k1.PubKeyFormatCompressedOdd {
+		c.Negate()
+	}
+	c.Mul(parity)
+	return &b, &e, c, r.Y.IsOdd(), nil
+}
+
+// VerifySignerPartial verifies the signer public nonce and partial signature
+func (ks *TaprootKeySpend) VerifySignerPartial(sig []byte) error {
+	if len(sig) != taprootSignerPartialSize {
+		return fmt.Errorf("invalid signer partial signature %x", sig)
+	}
+	var nonce [musig2.PubNonceSize]byte
+	copy(nonce[:], sig)
+	combined, err := musig2.AggregateNonces([][musig2.PubNonceSize]byte{ks.HolderNonce, nonce})
+	if err != nil {
+		return err
+	}
+	var s btcec.ModNScalar
+	if s.SetByteSlice(sig[musig2.PubNonceSize:]) {
+		return fmt.Errorf("invalid signer partial signature %x", sig)
+	}
+	ps := musig2.NewPartialSignature(&s, nil)
+	valid := ps.Verify(nonce, combined, ks.keys(), ks.Signer, [32]byte(ks.Hash),
+		musig2.WithSortedKeys(), musig2.WithTaprootSignTweak(ks.MerkleRoot))
+	if !valid {
+		return fmt.Errorf("bitcoin.VerifySignerPartial(%x, %x)", ks.Message(), sig)
+	}
+	return nil
+}
+
+// Sign makes the holder partial signature and combines it with the signer one
+func (ks *TaprootKeySpend) Sign(key *btcec.PrivateKey, secNonce [musig2.SecNonceSize]byte, signerPartial []byte) (*schnorr.Signature, error) {
+	err := ks.VerifySignerPartial(signerPartial)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [musig2.PubNonceSize]byte
+	copy(nonce[:], signerPartial)
+	combined, err := musig2.AggregateNonces([][musig2.PubNonceSize]byte{ks.HolderNonce, nonce})
+	if err != nil {
+		return nil, err
+	}
+	hps, err := musig2.Sign(secNonce, key, combined, ks.keys(), [32]byte(ks.Hash),
+		musig2.WithSortedKeys(), musig2.WithTaprootSignTweak(ks.MerkleRoot))
+	if err != nil {
+		return nil, err
+	}
+	var s btcec.ModNScalar
+	s.SetByteSlice(signerPartial[musig2.PubNonceSize:])
+	sps := musig2.NewPartialSignature(&s, hps.R)
+	sig := musig2.CombineSigs(hps.R, []*musig2.PartialSignature{hps, &sps},
+		musig2.WithTaprootTweakedCombine([32]byte(ks.Hash), ks.keys(), ks.MerkleRoot, true))
+	agg, _, _, err := musig2.AggregateKeys(ks.keys(), true, musig2.WithTaprootKeyTweak(ks.MerkleRoot))
+	if err != nil {
+		return nil, err
+	}
+	if !sig.Verify(ks.Hash, agg.FinalKey) {
+		return nil, fmt.Errorf("bitcoin.Sign(%x) => %x", ks.Message(), sig.Serialize())
+	}
+	return sig, nil
+}
+
+func (ks *TaprootKeySpend) keys() []*btcec.PublicKey {
+	return []*btcec.PublicKey{ks.Holder, ks.Signer}
+}
+
+func (psbt *PartiallySignedTransaction) IsTaprootKeySpendInput(idx int) bool {
+	pin := psbt.Inputs[idx]
+	return len(pin.TaprootInternalKey) > 0 && len(pin.TaprootLeafScript) == 0
+}
+
+// TaprootKeySpend returns the MuSig2 context of the key path input, and the
+// holder nonce is empty until the holder approves the input.
+func (psbt *PartiallySignedTransaction) TaprootKeySpend(idx int) (*TaprootKeySpend, error) {
+	if !psbt.IsTaprootKeySpendInput(idx) {
+		return nil, fmt.Errorf("not taproot key spend input %d", idx)
+	}
+	pin := &psbt.Inputs[idx]
+	participants := psbt.taprootParticipants(idx)
+	if len(participants) != 2 {
+		return nil, fmt.Errorf("invalid taproot participants %d", idx)
+	}
+	ks := &TaprootKeySpend{
+		Hash:       psbt.SigHash(idx),
+		Holder:     participants[0],
+		Signer:     participants[1],
+		MerkleRoot: pin.TaprootMerkleRoot,
+	}
+	agg, _, _, err := musig2.AggregateKeys(ks.keys(), true, musig2.WithTaprootKeyTweak(ks.MerkleRoot))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(schnorr.SerializePubKey(agg.PreTweakedKey), pin.TaprootInternalKey) {
+		return nil, fmt.Errorf("invalid taproot internal key %x", pin.TaprootInternalKey)
+	}
+	pkScript := pin.WitnessUtxo.PkScript
+	if len(pkScript) != 34 || !bytes.Equal(pkScript[2:], schnorr.SerializePubKey(agg.FinalKey)) {
+		return nil, fmt.Errorf("invalid taproot output key %x", pkScript)
+	}
+	nonce := taprootInputUnknown(pin, taprootUnknownKey(psbtMuSig2PubNonceType, ks.Holder, agg.PreTweakedKey))
+	if len(nonce) == musig2.PubNonceSize {
+		copy(ks.HolderNonce[:], nonce)
+	}
+	return ks, nil
+}
+
+// ApproveTaprootKeySpendInput adds the holder public nonce and approval of
+// the key path input, and returns the secret nonce for SignTaprootKeySpendInput.
+func (psbt *PartiallySignedTransaction) ApproveTaprootKeySpendInput(idx int, key *btcec.PrivateKey) ([musig2.SecNonceSize]byte, error) {
+	var secNonce [musig2.SecNonceSize]byte
+	ks, err := psbt.TaprootKeySpend(idx)
+	if err != nil {
+		return secNonce, err
+	}
+	if !ks.Holder.IsEqual(key.PubKey()) {
+		return secNonce, fmt.Errorf("invalid taproot holder %x", key.PubKey().SerializeCompressed())
+	}
+	nonces, err := musig2.GenNonces(musig2.WithPublicKey(key.PubKey()),
+		musig2.WithNonceSecretKeyAux(key), musig2.WithNonceMessageAux([32]byte(ks.Hash)))
+	if err != nil {
+		return secNonce, err
+	}
+	sig, err := schnorr.Sign(key, taprootApprovalHash(ks.Hash, nonces.PubNonce[:]))
+	if err != nil {
+		return secNonce, err
+	}
+	holder := hex.EncodeToString(ks.Holder.SerializeCompressed())
+	psbt.AddInputSignature(idx, holder, append(nonces.PubNonce[:], sig.Serialize()...))
+	return nonces.SecNonce, nil
+}
+
+// SignTaprootKeySpendInput combines the holder and signer partial signatures
+// to the key path signature of the input, after the signer signed it.
+func (psbt *PartiallySignedTransaction) SignTaprootKeySpendInput(idx int, key *btcec.PrivateKey, secNonce [musig2.SecNonceSize]byte) error {
+	ks, err := psbt.TaprootKeySpend(idx)
+	if err != nil {
+		return err
+	}
+	signer := hex.EncodeToString(schnorr.SerializePubKey(ks.Signer))
+	partial := psbt.InputSignature(idx, signer)
+	if partial == nil {
+		return fmt.Errorf("taproot signer partial signature not found %d", idx)
+	}
+	sig, err := ks.Sign(key, secNonce, partial)
+	if err != nil {
+		return err
+	}
+	pin := &psbt.Inputs[idx]
+	pin.TaprootKeySpendSig = append(sig.Serialize(), byte(pin.SighashType))
+	return nil
+}
+
+func (psbt *PartiallySignedTransaction) verifyTaprootKeySpendSig(idx int) error {
+	pin := psbt.Inputs[idx]
+	sig := pin.TaprootKeySpendSig
+	if len(sig) != schnorr.SignatureSize+1 || sig[schnorr.SignatureSize] != byte(pin.SighashType) {
+		return fmt.Errorf("invalid taproot key spend signature %x", sig)
+	}
+	output, err := schnorr.ParsePubKey(pin.WitnessUtxo.PkScript[2:])
+	if err != nil {
+		return err
+	}
+	ss, err := schnorr.ParseSignature(sig[:schnorr.SignatureSize])
+	if err != nil {
+		return err
+	}
+	if !ss.Verify(psbt.SigHash(idx), output) {
+		return fmt.Errorf("invalid taproot key spend signature %x", sig)
+	}
+	return nil
+}
+
+// the holder approval is the public nonce and the BIP340 signature of it,
+// and the signer partial signature is the public nonce and the scalar
+func (psbt *PartiallySignedTransaction) taprootKeySpendSignatures(idx int) map[string][]byte {
+	pin := &psbt.Inputs[idx]
+	participants := psbt.taprootParticipants(idx)
+	if len(participants) != 2 {
+		return nil
+	}
+	agg := taprootAggregateKey(pin)
+	sigs := make(map[string][]byte, 2)
+	for i, pub := range participants {
+		nonce := taprootInputUnknown(pin, taprootUnknownKey(psbtMuSig2PubNonceType, pub, agg))
+		if len(nonce) != musig2.PubNonceSize {
+			continue
+		}
+		var extra []byte
+		switch i {
+		case 0:
+			extra = taprootInputUnknown(pin, append(slices.Clone(psbtSafeApprovalPrefix), pub.SerializeCompressed()...))
+		case 1:
+			extra = taprootInputUnknown(pin, taprootUnknownKey(psbtMuSig2PartialSigType, pub, agg))
+		}
+		if extra == nil {
+			continue
+		}
+		key := hex.EncodeToString(schnorr.SerializePubKey(pub))
+		sigs[key] = append(slices.Clone(nonce), extra...)
+	}
+	return sigs
+}
+
+func (psbt *PartiallySignedTransaction) verifyTaprootKeySpendSignature(idx int, public string, sig []byte) error {
+	ks, err := psbt.TaprootKeySpend(idx)
+	if err != nil {
+		return err
+	}
+	public = XOnlyPublicKey(public)
+	switch public {
+	case hex.EncodeToString(schnorr.SerializePubKey(ks.Holder)):
+		if len(sig) != taprootHolderApprovalSize {
+			return fmt.Errorf("invalid holder approval %x", sig)
+		}
+		var nonce [musig2.PubNonceSize]byte
+		copy(nonce[:], sig)
+		err = checkMuSig2PubNonce(nonce)
+		if err != nil {
+			return err
+		}
+		hash := taprootApprovalHash(ks.Hash, nonce[:])
+		return VerifySignatureSchnorr(public, hash, sig[musig2.PubNonceSize:])
+	case hex.EncodeToString(schnorr.SerializePubKey(ks.Signer)):
+		if ks.HolderNonce == [musig2.PubNonceSize]byte{} {
+			return fmt.Errorf("taproot holder nonce not found %d", idx)
+		}
+		return ks.VerifySignerPartial(sig)
+	default:
+		return fmt.Errorf("invalid taproot participant %s", public)
+	}
+}
+
+func (raw *PartiallySignedTransaction) setTaprootKeySpendSignature(idx int, public string, sig []byte, replace bool) {
+	pin := &raw.Inputs[idx]
+	participants := raw.taprootParticipants(idx)
+	if len(participants) != 2 {
+		panic(idx)
+	}
+	agg := taprootAggregateKey(pin)
+	public = XOnlyPublicKey(public)
+	i := slices.IndexFunc(participants, func(pub *btcec.PublicKey) bool {
+		return hex.EncodeToString(schnorr.SerializePubKey(pub)) == public
+	})
+	if i < 0 {
+		panic(public)
+	}
+	pub := participants[i]
+	var key []byte
+	switch {
+	case i == 0 && len(sig) == taprootHolderApprovalSize:
+		key = append(slices.Clone(psbtSafeApprovalPrefix), pub.SerializeCompressed()...)
+	case i == 1 && len(sig) == taprootSignerPartialSize:
+		key = taprootUnknownKey(psbtMuSig2PartialSigType, pub, agg)
+	default:
+		panic(hex.EncodeToString(sig))
+	}
+	nonce := &psbt.Unknown{
+		Key:   taprootUnknownKey(psbtMuSig2PubNonceType, pub, agg),
+		Value: sig[:musig2.PubNonceSize],
+	}
+	extra := &psbt.Unknown{Key: key, Value: sig[musig2.PubNonceSize:]}
+
+	pin.Unknowns = slices.DeleteFunc(pin.Unknowns, func(u *psbt.Unknown) bool {
+		participant := taprootUnknownParticipant(u)
+		if participant == nil {
+			return false
+		}
+		return replace || bytes.Equal(participant, pub.SerializeCompressed())
+	})
+	pin.Unknowns = append(pin.Unknowns, nonce, extra)
+}
+
+// the participant of the nonce, partial signature or approval unknown field
+func taprootUnknownParticipant(u *psbt.Unknown) []byte {
+	size := btcec.PubKeyBytesLenCompressed
+	switch {
+	case bytes.HasPrefix(u.Key, psbtSafeApprovalPrefix):
+		return u.Key[len(psbtSafeApprovalPrefix):]
+	case len(u.Key) != 1+size*2:
+		return nil
+	case u.Key[0] == psbtMuSig2PubNonceType, u.Key[0] == psbtMuSig2PartialSigType:
+		return u.Key[1 : 1+size]
+	default:
+		return nil
+	}
+}
+
+// the participants are the holder and the signer in order
+func (psbt *PartiallySignedTransaction) taprootParticipants(idx int) []*btcec.PublicKey {
+	pin := &psbt.Inputs[idx]
+	for _, u := range pin.Unknowns {
+		if len(u.Key) != 1+btcec.PubKeyBytesLenCompressed || u.Key[0] != psbtMuSig2ParticipantsType {
+			continue
+		}
+		if len(u.Value)%btcec.PubKeyBytesLenCompressed != 0 {
+			return nil
+		}
+		var participants []*btcec.PublicKey
+		for i := 0; i < len(u.Value); i += btcec.PubKeyBytesLenCompressed {
+			pub, err := btcec.ParsePubKey(u.Value[i : i+btcec.PubKeyBytesLenCompressed])
+			if err != nil {
+				return nil
+			}
+			participants = append(participants, pub)
+		}
+		return participants
+	}
+	return nil
+}
+
+func taprootAggregateKey(pin *psbt.PInput) *btcec.PublicKey {
+	for _, u := range pin.Unknowns {
+		if len(u.Key) != 1+btcec.PubKeyBytesLenCompressed || u.Key[0] != psbtMuSig2ParticipantsType {
+			continue
+		}
+		pub, err := btcec.ParsePubKey(u.Key[1:])
+		if err != nil {
+			panic(hex.EncodeToString(u.Key))
+		}
+		return pub
+	}
+	panic(hex.EncodeToString(pin.TaprootInternalKey))
+}
+
+func taprootUnknownKey(typ byte, participant, agg *btcec.PublicKey) []byte {
+	key := []byte{typ}
+	key = append(key, participant.SerializeCompressed()...)
+	return append(key, agg.SerializeCompressed()...)
+}
+
+func taprootInputUnknown(pin *psbt.PInput, key []byte) []byte {
+	for _, u := range pin.Unknowns {
+		if bytes.Equal(u.Key, key) {
+			return u.Value
+		}
+	}
+	return nil
+}
+
+func taprootApprovalHash(hash, nonce []byte) []byte {
+	h := chainhash.TaggedHash(taprootApprovalTag, hash, nonce)
+	return h[:]
+}
+
+func checkMuSig2PubNonce(nonce [musig2.PubNonceSize]byte) error {
+	_, err := btcec.ParsePubKey(nonce[:btcec.PubKeyBytesLenCompressed])
+	if err != nil {
+		return err
+	}
+	_, err = btcec.ParsePubKey(nonce[btcec.PubKeyBytesLenCompressed:])
+	return err
+}
+
+// the BIP327 key aggregation coefficient, and the second unique key has 1
+func musig2KeyCoefficient(keys []*btcec.PublicKey, key *btcec.PublicKey) *btcec.ModNScalar {
+	sorted := slices.Clone(keys)
+	slices.SortFunc(sorted, func(a, b *btcec.PublicKey) int {
+		return bytes.Compare(a.SerializeCompressed(), b.SerializeCompressed())
+	})
+	var buf []byte
+	for _, k := range sorted {
+		buf = append(buf, k.SerializeCompressed()...)
+	}
+	list := chainhash.TaggedHash(musig2.KeyAggTagList, buf)
+
+	var mu btcec.ModNScalar
+	for _, k := range sorted {
+		if k.IsEqual(sorted[0]) {
+			continue
+		}
+		if k.IsEqual(key) {
+			return mu.SetInt(1)
+		}
+		break
+	}
+	h := chainhash.TaggedHash(musig2.KeyAggTagCoeff, list[:], key.SerializeCompressed())
+	mu.SetByteSlice(h[:])
+	return &mu
+}
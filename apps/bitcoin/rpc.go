@@ -14,7 +14,6 @@ import (
 
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/txscript"
 	"github.com/shopspring/decimal"
 )
 
@@ -45,13 +44,18 @@ type RPCTransaction struct {
 	Hex       string    `json:"hex"`
 	Fee       float64   `json:"fee"`
 	VSize     int64     `json:"vsize"`
+	Size      int64     `json:"size"`
 }
 
 type MemPoolTransaction struct {
 	Fees struct {
-		Base float64 `json:"base"`
+		Base     float64 `json:"base"`
+		Ancestor float64 `json:"ancestor"`
 	} `json:"fees"`
-	VSize int64 `json:"vsize"`
+	VSize        int64    `json:"vsize"`
+	Size         int64    `json:"size"`
+	AncestorSize int64    `json:"ancestorsize"`
+	Depends      []string `json:"depends"`
 }
 
 type RPCBlock struct {
@@ -76,8 +80,15 @@ func RPCGetTransactionOutput(chain byte, rpc, hash string, index int64) (*RPCTra
 		return nil, nil, nil
 	}
 	out := tx.Vout[index]
-	skt := out.ScriptPubKey.Type
-	if skt != ScriptPubKeyTypeWitnessScriptHash && skt != ScriptPubKeyTypeWitnessKeyHash {
+	switch out.ScriptPubKey.Type {
+	case ScriptPubKeyTypeWitnessScriptHash:
+	case ScriptPubKeyTypeWitnessKeyHash:
+	case ScriptPubKeyTypeWitnessTaproot:
+	case ScriptPubKeyTypeScriptHash, ScriptPubKeyTypePubKeyHash:
+		if chain != ChainBitcoinCash {
+			return nil, nil, nil
+		}
+	default:
 		return nil, nil, nil
 	}
 	if out.ScriptPubKey.Address == "" {
@@ -122,15 +133,11 @@ func RPCGetTransactionOutput(chain byte, rpc, hash string, index int64) (*RPCTra
 	if rtmx.TxOut[index].Value != output.Satoshi {
 		return nil, nil, nil
 	}
-	script, err := txscript.ParsePkScript(rtmx.TxOut[index].PkScript)
-	if err != nil {
-		return nil, nil, err
-	}
-	addr, err := script.Address(NetConfig(chain))
+	addr, err := ExtractPkScriptAddr(rtmx.TxOut[index].PkScript, chain)
 	if err != nil {
 		return nil, nil, err
 	}
-	if addr.EncodeAddress() != output.Address {
+	if addr != output.Address {
 		return nil, nil, nil
 	}
 
@@ -158,7 +165,7 @@ func RPCGetTransaction(chain byte, rpc, hash string) (*RPCTransaction, error) {
 	if err != nil {
 		return nil, err
 	}
-	fixLitecoinLegacyScriptPubKeyRPC(chain, &tx)
+	fixLegacyScriptPubKeyRPC(chain, &tx)
 	return &tx, err
 }
 
@@ -185,7 +192,7 @@ func RPCGetRawMempool(chain byte, rpc string) ([]*RPCTransaction, error) {
 	return transactions, nil
 }
 
-func RPCGetRawMempoolWithTransactions(rpc string) ([]*RPCTransaction, error) {
+func RPCGetRawMempoolWithTransactions(chain byte, rpc string) ([]*RPCTransaction, error) {
 	res, err := callBitcoinRPCUntilSufficient(rpc, "getrawmempool", []any{true})
 	if err != nil {
 		return nil, err
@@ -198,15 +205,39 @@ func RPCGetRawMempoolWithTransactions(rpc string) ([]*RPCTransaction, error) {
 
 	var transactions []*RPCTransaction
 	for id, tx := range txMap {
+		vsize := tx.VSize
+		if chain == ChainBitcoinCash {
+			vsize = tx.Size
+		}
 		transactions = append(transactions, &RPCTransaction{
 			TxId:  id,
 			Fee:   tx.Fees.Base,
-			VSize: tx.VSize,
+			VSize: vsize,
 		})
 	}
 	return transactions, nil
 }
 
+// RPCGetMempoolEntry returns nil if the transaction is not in the mempool,
+// either confirmed or evicted.
+func RPCGetMempoolEntry(chain byte, rpc, hash string) (*MemPoolTransaction, error) {
+	res, err := callBitcoinRPCUntilSufficient(rpc, "getmempoolentry", []any{hash})
+	if err != nil && strings.Contains(err.Error(), "not in mempool") {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var entry MemPoolTransaction
+	err = json.Unmarshal(res, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if chain == ChainBitcoinCash {
+		entry.VSize = entry.Size
+	}
+	return &entry, nil
+}
+
 func RPCGetBlockWithTransactions(chain byte, rpc, hash string) (*RPCBlockWithTransactions, error) {
 	res, err := callBitcoinRPCUntilSufficient(rpc, "getblock", []any{hash, 2})
 	if err != nil {
@@ -218,7 +249,7 @@ func RPCGetBlockWithTransactions(chain byte, rpc, hash string) (*RPCBlockWithTra
 		return nil, err
 	}
 	for _, tx := range b.Tx {
-		fixLitecoinLegacyScriptPubKeyRPC(chain, tx)
+		fixLegacyScriptPubKeyRPC(chain, tx)
 		tx.BlockHash = hash
 	}
 	return &b, err
@@ -249,8 +280,8 @@ func RPCGetBlockAverageFeePerBytes(chain byte, rpc string) (*big.Int, error) {
 	return sum.Ceil().BigInt(), nil
 }
 
-func RPCGetMempoolAverageFeePerBytes(rpc string) (*big.Int, error) {
-	txs, err := RPCGetRawMempoolWithTransactions(rpc)
+func RPCGetMempoolAverageFeePerBytes(chain byte, rpc string) (*big.Int, error) {
+	txs, err := RPCGetRawMempoolWithTransactions(chain, rpc)
 	if err != nil {
 		return nil, err
 	}
@@ -311,11 +342,14 @@ func RPCGetBlockHeight(rpc string) (int64, error) {
 }
 
 func EstimateAvgFee(chain byte, rpc string) (int64, error) {
+	if chain == ChainBitcoinCash {
+		return estimateBitcoinCashFee(rpc)
+	}
 	blockAvgFee, err := RPCGetBlockAverageFeePerBytes(chain, rpc)
 	if err != nil {
 		return 0, err
 	}
-	mempoolTopAvgFee, err := RPCGetMempoolAverageFeePerBytes(rpc)
+	mempoolTopAvgFee, err := RPCGetMempoolAverageFeePerBytes(chain, rpc)
 	if err != nil {
 		return 0, err
 	}
@@ -337,6 +371,33 @@ func EstimateAvgFee(chain byte, rpc string) (int64, error) {
 	return fvb, nil
 }
 
+// bitcoin cash node has no fee in the block transactions, and the blocks
+// are mostly not full, so the node fee estimation is good enough. The node
+// has no estimatesmartfee, and estimatefee returns the fee rate in BCH/kB
+func estimateBitcoinCashFee(rpc string) (int64, error) {
+	res, err := callBitcoinRPCUntilSufficient(rpc, "estimatefee", []any{})
+	if err != nil {
+		return 0, err
+	}
+	var fpk float64
+	err = json.Unmarshal(res, &fpk)
+	if err != nil {
+		return 0, err
+	}
+	if fpk <= 0 {
+		return 0, fmt.Errorf("estimatefee(%d) => %f", ChainBitcoinCash, fpk)
+	}
+	fvb := decimal.NewFromFloat(fpk).Mul(decimal.New(1, 8)).Div(decimal.NewFromInt(1000))
+	fee := fvb.Ceil().IntPart()
+	if fee < 1 {
+		fee = 1
+	}
+	if !CheckFeeRange(fee, ChainBitcoinCash) {
+		return 0, fmt.Errorf("CheckFeeRange(%d) => %d", ChainBitcoinCash, fee)
+	}
+	return fee, nil
+}
+
 func RPCSendRawTransaction(rpc, raw string) (string, error) {
 	res, err := callBitcoinRPCUntilSufficient(rpc, "sendrawtransaction", []any{raw})
 	if err != nil {
@@ -348,9 +409,10 @@ func RPCSendRawTransaction(rpc, raw string) (string, error) {
 }
 
 // FIXME wait for litecoin core update to the latest rpc
-func fixLitecoinLegacyScriptPubKeyRPC(chain byte, tx *RPCTransaction) {
+// bitcoin cash node still uses the legacy addresses and size fields
+func fixLegacyScriptPubKeyRPC(chain byte, tx *RPCTransaction) {
 	switch chain {
-	case ChainLitecoin:
+	case ChainLitecoin, ChainBitcoinCash:
 		for _, o := range tx.Vout {
 			if len(o.ScriptPubKey.LegacyAddresses) != 1 {
 				continue
@@ -358,6 +420,9 @@ func fixLitecoinLegacyScriptPubKeyRPC(chain byte, tx *RPCTransaction) {
 			o.ScriptPubKey.Address = o.ScriptPubKey.LegacyAddresses[0]
 		}
 	}
+	if chain == ChainBitcoinCash && tx.VSize == 0 {
+		tx.VSize = tx.Size
+	}
 }
 
 func callBitcoinRPCUntilSufficient(rpc, method string, params []any) ([]byte, error) {
@@ -0,0 +1,234 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// OP_1 <HOLDER> <SIGNER> <OBSERVER> <SEQUENCE>
+//
+// the taproot account script is not a bitcoin script, it only packs the
+// compressed holder and observer keys, the x-only signer key and the
+// recovery sequence, so that the same WitnessScriptAccount and UTXO script
+// storage works for both P2WSH and P2TR safes
+const taprootScriptSize = 1 + 33 + 32 + 33 + 2
+
+type taprootScriptTree struct {
+	Holder      *btcec.PublicKey
+	Signer      *btcec.PublicKey
+	Observer    *btcec.PublicKey
+	Sequence    uint32
+	InternalKey *btcec.PublicKey
+	OutputKey   *btcec.PublicKey
+	Tree        *txscript.IndexedTapScriptTree
+}
+
+// tr(musig(HOLDER,SIGNER),and_v(v:thresh(1,pk(HOLDER),s:pk(SIGNER)),and_v(v:pk(OBSERVER),older(12960))))
+//
+// the internal key is the BIP327 MuSig2 aggregated key of the holder and the
+// signer, so the normal transactions are spent with the key path, and look
+// the same as any single key output, only the recovery reveals the script
+//
+// <HOLDER> OP_CHECKSIG <SIGNER> OP_CHECKSIGADD OP_VERIFY
+// <OBSERVER> OP_CHECKSIGVERIFY <a032> OP_CHECKSEQUENCEVERIFY
+func BuildTaprootAccount(holder, signer, observer string, lock time.Duration, chain byte) (*WitnessScriptAccount, error) {
+	hpk, err := parseBitcoinCompressedPublicKey(holder)
+	if err != nil {
+		return nil, fmt.Errorf("parseBitcoinCompressedPublicKey(%s) => %v", holder, err)
+	}
+	spk, err := parseTaprootPublicKey(signer)
+	if err != nil {
+		return nil, fmt.Errorf("parseTaprootPublicKey(%s) => %v", signer, err)
+	}
+	opk, err := parseBitcoinCompressedPublicKey(observer)
+	if err != nil {
+		return nil, fmt.Errorf("parseBitcoinCompressedPublicKey(%s) => %v", observer, err)
+	}
+
+	if lock < TimeLockMinimum || lock > TimeLockMaximum {
+		return nil, fmt.Errorf("time lock out of range %s", lock.String())
+	}
+	sequence := ParseSequence(lock, chain)
+
+	script := []byte{txscript.OP_1}
+	script = append(script, hpk.ScriptAddress()...)
+	script = append(script, schnorr.SerializePubKey(spk)...)
+	script = append(script, opk.ScriptAddress()...)
+	script = binary.BigEndian.AppendUint16(script, uint16(sequence))
+
+	tst, err := parseTaprootScript(script)
+	if err != nil {
+		return nil, fmt.Errorf("parseTaprootScript(%x) => %v", script, err)
+	}
+	addr, err := tst.encodeAddress(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WitnessScriptAccount{
+		Sequence: uint32(sequence),
+		Script:   script,
+		Address:  addr,
+	}, nil
+}
+
+func CheckTaprootScript(script []byte) bool {
+	return len(script) == taprootScriptSize && script[0] == txscript.OP_1
+}
+
+func VerifyTaprootKey(public string) error {
+	_, err := parseTaprootPublicKey(public)
+	return err
+}
+
+func XOnlyPublicKey(public string) string {
+	pub, err := parseTaprootPublicKey(public)
+	if err != nil {
+		panic(public)
+	}
+	return hex.EncodeToString(schnorr.SerializePubKey(pub))
+}
+
+func VerifySignatureSchnorr(public string, msg, sig []byte) error {
+	pub, err := parseTaprootPublicKey(public)
+	if err != nil {
+		return err
+	}
+	ss, err := schnorr.ParseSignature(sig)
+	if err != nil {
+		return err
+	}
+	if ss.Verify(msg, pub) {
+		return nil
+	}
+	return fmt.Errorf("bitcoin.VerifySignatureSchnorr(%s, %x, %x)", public, msg, sig)
+}
+
+func parseTaprootPublicKey(public string) (*btcec.PublicKey, error) {
+	pub, err := hex.DecodeString(public)
+	if err != nil {
+		return nil, err
+	}
+	switch len(pub) {
+	case schnorr.PubKeyBytesLen:
+		return schnorr.ParsePubKey(pub)
+	case btcec.PubKeyBytesLenCompressed:
+		return btcec.ParsePubKey(pub)
+	default:
+		return nil, fmt.Errorf("invalid taproot public key %s", public)
+	}
+}
+
+func parseTaprootScript(script []byte) (*taprootScriptTree, error) {
+	if !CheckTaprootScript(script) {
+		return nil, fmt.Errorf("invalid taproot script %x", script)
+	}
+	holder, err := btcec.ParsePubKey(script[1:34])
+	if err != nil {
+		return nil, err
+	}
+	signer, err := schnorr.ParsePubKey(script[34:66])
+	if err != nil {
+		return nil, err
+	}
+	observer, err := btcec.ParsePubKey(script[66:99])
+	if err != nil {
+		return nil, err
+	}
+	sequence := binary.BigEndian.Uint16(script[99:])
+	if sequence == 0 {
+		return nil, fmt.Errorf("invalid taproot sequence %d", sequence)
+	}
+
+	recovery, err := buildTaprootRecoveryLeaf(holder, signer, observer, int64(sequence))
+	if err != nil {
+		return nil, err
+	}
+	tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(recovery))
+
+	agg, _, _, err := musig2.AggregateKeys([]*btcec.PublicKey{holder, signer}, true)
+	if err != nil {
+		return nil, err
+	}
+	internal := agg.PreTweakedKey
+	root := tree.RootNode.TapHash()
+	output := txscript.ComputeTaprootOutputKey(internal, root[:])
+
+	return &taprootScriptTree{
+		Holder:      holder,
+		Signer:      signer,
+		Observer:    observer,
+		Sequence:    uint32(sequence),
+		InternalKey: internal,
+		OutputKey:   output,
+		Tree:        tree,
+	}, nil
+}
+
+func (tst *taprootScriptTree) encodeAddress(chain byte) (string, error) {
+	key := schnorr.SerializePubKey(tst.OutputKey)
+	addr, err := btcutil.NewAddressTaproot(key, NetConfig(chain))
+	if err != nil {
+		return "", fmt.Errorf("btcutil.NewAddressTaproot(%x) => %v", key, err)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+func (tst *taprootScriptTree) leafScript() (*psbt.TaprootTapLeafScript, error) {
+	proof := tst.Tree.LeafMerkleProofs[0]
+	cb := proof.ToControlBlock(tst.InternalKey)
+	control, err := cb.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &psbt.TaprootTapLeafScript{
+		ControlBlock: control,
+		Script:       proof.TapLeaf.Script,
+		LeafVersion:  proof.TapLeaf.LeafVersion,
+	}, nil
+}
+
+// the recovery input spends the script path, and the normal input spends
+// the key path with the BIP373 participants of the aggregated key
+func (tst *taprootScriptTree) buildInput(pin *psbt.PInput, recovery bool) error {
+	root := tst.Tree.RootNode.TapHash()
+	pin.TaprootInternalKey = schnorr.SerializePubKey(tst.InternalKey)
+	pin.TaprootMerkleRoot = root[:]
+	if recovery {
+		ls, err := tst.leafScript()
+		if err != nil {
+			return err
+		}
+		pin.TaprootLeafScript = []*psbt.TaprootTapLeafScript{ls}
+		return nil
+	}
+	participants := append(tst.Holder.SerializeCompressed(), tst.Signer.SerializeCompressed()...)
+	pin.Unknowns = []*psbt.Unknown{{
+		Key:   append([]byte{psbtMuSig2ParticipantsType}, tst.InternalKey.SerializeCompressed()...),
+		Value: participants,
+	}}
+	return nil
+}
+
+func buildTaprootRecoveryLeaf(holder, signer, observer *btcec.PublicKey, sequence int64) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	builder.AddData(schnorr.SerializePubKey(holder))
+	builder.AddOp(txscript.OP_CHECKSIG)
+	builder.AddData(schnorr.SerializePubKey(signer))
+	builder.AddOp(txscript.OP_CHECKSIGADD)
+	builder.AddOp(txscript.OP_VERIFY)
+	builder.AddData(schnorr.SerializePubKey(observer))
+	builder.AddOp(txscript.OP_CHECKSIGVERIFY)
+	builder.AddInt64(sequence)
+	builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	return builder.Script()
+}
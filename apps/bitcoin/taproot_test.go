@@ -0,0 +1,249 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTaprootHolderKey   = "52250bb9b9edc5d54466182778a6470a5ee34033c215c92dd250b9c2ce543556"
+	testTaprootSignerKey   = "044a0d6d6a4fc1eed8f1b3a44a09fd2d28dbd4b6a8f4bd1b5b1a63b9bb6ad5dc"
+	testTaprootObserverKey = "6a5f7d6eaf3c0bbd9d2c47f5c1a9e2dbe33cc84b4af4fd4ab36d9bb9a3e1d7f2"
+)
+
+func TestTaprootAccount(t *testing.T) {
+	require := require.New(t)
+	hk, sk, ok := testTaprootKeys()
+	holder := hex.EncodeToString(hk.PubKey().SerializeCompressed())
+	signer := hex.EncodeToString(schnorr.SerializePubKey(sk.PubKey()))
+	observer := hex.EncodeToString(ok.PubKey().SerializeCompressed())
+
+	require.Nil(VerifyTaprootKey(holder))
+	require.Nil(VerifyTaprootKey(signer))
+	require.NotNil(VerifyTaprootKey(signer[2:]))
+	require.Equal(holder[2:], XOnlyPublicKey(holder))
+	require.Equal(signer, XOnlyPublicKey(signer))
+
+	lock := time.Hour * 24 * 90
+	wsa, err := BuildTaprootAccount(holder, signer, observer, lock, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(uint32(12960), wsa.Sequence)
+	require.Len(wsa.Script, taprootScriptSize)
+	require.True(CheckTaprootScript(wsa.Script))
+	require.True(CheckMultisigHolderSignerScript(wsa.Script))
+	require.Equal(InputTypeP2TRHolderSigner, checkScriptType(wsa.Script))
+	addr, err := EncodeAddress(wsa.Script, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(wsa.Address, addr)
+	require.Equal("bc1p", wsa.Address[:4])
+
+	ba, err := btcutil.DecodeAddress(wsa.Address, NetConfig(ChainBitcoin))
+	require.Nil(err)
+	_, ok2 := ba.(*btcutil.AddressTaproot)
+	require.True(ok2)
+
+	tst, err := parseTaprootScript(wsa.Script)
+	require.Nil(err)
+	spub, err := schnorr.ParsePubKey(schnorr.SerializePubKey(sk.PubKey()))
+	require.Nil(err)
+	agg, _, _, err := musig2.AggregateKeys([]*btcec.PublicKey{hk.PubKey(), spub}, true)
+	require.Nil(err)
+	require.Equal(schnorr.SerializePubKey(agg.PreTweakedKey), schnorr.SerializePubKey(tst.InternalKey))
+	require.Len(tst.Tree.LeafMerkleProofs, 1)
+
+	wsa2, err := UnmarshalWitnessScriptAccount(wsa.Marshal())
+	require.Nil(err)
+	require.Equal(wsa, wsa2)
+
+	_, err = BuildTaprootAccount(holder, signer, observer, time.Minute, ChainBitcoin)
+	require.NotNil(err)
+	_, err = BuildTaprootAccount(signer, signer, observer, lock, ChainBitcoin)
+	require.NotNil(err)
+
+	msg := HashMessageForSignature("taproot", ChainBitcoin)
+	sig, err := schnorr.Sign(sk, msg)
+	require.Nil(err)
+	require.Nil(VerifySignatureSchnorr(signer, msg, sig.Serialize()))
+	require.NotNil(VerifySignatureSchnorr(observer, msg, sig.Serialize()))
+}
+
+func TestTaprootTransaction(t *testing.T) {
+	require := require.New(t)
+	hk, sk, ok := testTaprootKeys()
+	holder := hex.EncodeToString(hk.PubKey().SerializeCompressed())
+	signer := hex.EncodeToString(schnorr.SerializePubKey(sk.PubKey()))
+	observer := hex.EncodeToString(ok.PubKey().SerializeCompressed())
+	wsa, err := BuildTaprootAccount(holder, signer, observer, time.Hour*24*90, ChainBitcoin)
+	require.Nil(err)
+
+	receiver := "bc1qxy2kgdygjrsqtzq2n0yrf2493p83kkfjhx0wlh"
+	for _, recovery := range []bool{false, true} {
+		inputs := []*Input{{
+			TransactionHash: "9f3ec2b3d5b1ac1fdc1d5d3ba5c3a2b1b1f5b6c1e5a0d9b5c3f2a1e0d9c8b7a6",
+			Index:           1,
+			Satoshi:         100000,
+			Script:          wsa.Script,
+			Sequence:        wsa.Sequence,
+			RouteBackup:     recovery,
+		}, {
+			TransactionHash: "1f3ec2b3d5b1ac1fdc1d5d3ba5c3a2b1b1f5b6c1e5a0d9b5c3f2a1e0d9c8b7a6",
+			Index:           0,
+			Satoshi:         50000,
+			Script:          wsa.Script,
+			Sequence:        wsa.Sequence,
+			RouteBackup:     recovery,
+		}}
+		outputs := []*Output{{Address: receiver, Satoshi: 120000}}
+		psbt, err := BuildPartiallySignedTransaction(inputs, outputs, []byte("taproot"), ChainBitcoin)
+		require.Nil(err)
+		require.Equal(recovery, psbt.IsRecoveryTransaction())
+		require.Len(psbt.UnsignedTx.TxOut, 3)
+		require.Equal(wsa.Address, testTaprootOutputAddress(psbt.UnsignedTx.TxOut[1].PkScript))
+		for idx := range psbt.Inputs {
+			require.True(psbt.IsTaprootInput(idx))
+			require.Len(psbt.SigHash(idx), 32)
+			require.Nil(psbt.Inputs[idx].WitnessScript)
+		}
+
+		raw := psbt.Marshal()
+		var spsbt *PartiallySignedTransaction
+		if recovery {
+			spsbt = SignPartiallySignedTransaction(raw, hk)
+			require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), holder))
+			require.False(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), signer))
+			require.Equal([]string{holder[2:]}, spsbt.InputSigners(0))
+			_, err = spsbt.SignedTransaction(holder, signer, observer)
+			require.NotNil(err)
+			for idx := range spsbt.Inputs {
+				require.False(spsbt.IsTaprootKeySpendInput(idx))
+				sig, err := schnorr.Sign(ok, spsbt.SigHash(idx))
+				require.Nil(err)
+				require.Nil(spsbt.VerifyInputSignature(idx, observer, sig.Serialize()))
+				spsbt.AddInputSignature(idx, observer, sig.Serialize())
+			}
+			require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), observer))
+		} else {
+			spsbt, err = UnmarshalPartiallySignedTransaction(raw)
+			require.Nil(err)
+			nonces := make([][musig2.SecNonceSize]byte, len(spsbt.Inputs))
+			for idx := range spsbt.Inputs {
+				require.True(spsbt.IsTaprootKeySpendInput(idx))
+				require.Nil(spsbt.Inputs[idx].TaprootLeafScript)
+				_, err = spsbt.ApproveTaprootKeySpendInput(idx, sk)
+				require.NotNil(err)
+				nonces[idx], err = spsbt.ApproveTaprootKeySpendInput(idx, hk)
+				require.Nil(err)
+			}
+			require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), holder))
+			require.False(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), signer))
+			require.Equal([]string{holder[2:]}, spsbt.InputSigners(0))
+			_, err = spsbt.SignedTransaction(holder, signer, observer)
+			require.NotNil(err)
+
+			for idx := range spsbt.Inputs {
+				ks, err := spsbt.TaprootKeySpend(idx)
+				require.Nil(err)
+				require.Len(ks.Message(), TaprootKeySpendMessageSize)
+				ks, err = ParseTaprootKeySpendMessage(ks.Message(), signer)
+				require.Nil(err)
+				require.Equal(spsbt.SigHash(idx), ks.Hash)
+				_, err = ParseTaprootKeySpendMessage(ks.Message(), holder[2:])
+				require.NotNil(err)
+
+				sig := testTaprootSignerPartial(require, ks, sk)
+				require.Nil(ks.VerifySignerPartial(sig))
+				require.Nil(spsbt.VerifyInputSignature(idx, signer, sig))
+				require.NotNil(spsbt.VerifyInputSignature(idx, holder, sig))
+				sig[len(sig)-1] ^= 1
+				require.NotNil(ks.VerifySignerPartial(sig))
+				sig[len(sig)-1] ^= 1
+
+				rpsbt, _ := UnmarshalPartiallySignedTransaction(raw)
+				rpsbt.SetInputSignature(idx, signer, sig)
+				require.Equal([]string{signer}, rpsbt.InputSigners(idx))
+				require.Equal(sig, rpsbt.InputSignature(idx, signer))
+				require.Nil(rpsbt.InputSignature(idx, holder))
+				spsbt.AddInputSignature(idx, signer, sig)
+			}
+			require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), holder))
+			require.True(CheckTransactionPartiallySignedBy(hex.EncodeToString(spsbt.Marshal()), signer))
+			require.Equal([]string{holder[2:], signer}, spsbt.InputSigners(0))
+			_, err = spsbt.SignedTransaction(holder, signer, observer)
+			require.NotNil(err)
+
+			spsbt, err = UnmarshalPartiallySignedTransaction(spsbt.Marshal())
+			require.Nil(err)
+			for idx := range spsbt.Inputs {
+				require.Nil(spsbt.SignTaprootKeySpendInput(idx, hk, nonces[idx]))
+			}
+		}
+
+		spsbt, err = UnmarshalPartiallySignedTransaction(spsbt.Marshal())
+		require.Nil(err)
+		msgTx, err := spsbt.SignedTransaction(holder, signer, observer)
+		require.Nil(err)
+		for idx, in := range inputs {
+			pkScript := spsbt.Inputs[idx].WitnessUtxo.PkScript
+			pof := txscript.NewCannedPrevOutputFetcher(pkScript, in.Satoshi)
+			tsh := txscript.NewTxSigHashes(msgTx, pof)
+			vm, err := txscript.NewEngine(pkScript, msgTx, idx, txscript.StandardVerifyFlags, nil, tsh, in.Satoshi, pof)
+			require.Nil(err)
+			require.Nil(vm.Execute())
+		}
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(msgTx))
+		require.GreaterOrEqual(int64(spsbt.EstimateVirtualSize()), (weight+3)/4)
+		_, err = MarshalWiredTransaction(msgTx, wire.WitnessEncoding, ChainBitcoin)
+		require.Nil(err)
+	}
+}
+
+// the signer partial signature made as the signer members do, with only one member
+func testTaprootSignerPartial(require *require.Assertions, ks *TaprootKeySpend, sk *btcec.PrivateKey) []byte {
+	d := sk.Key
+	if sk.PubKey().SerializeCompressed()[0] == 3 {
+		d.Negate()
+	}
+	k1, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	k2, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	var nonce [musig2.PubNonceSize]byte
+	copy(nonce[:], k1.PubKey().SerializeCompressed())
+	copy(nonce[btcec.PubKeyBytesLenCompressed:], k2.PubKey().SerializeCompressed())
+
+	b, e, c, negate, err := ks.SignerChallenge(nonce)
+	require.Nil(err)
+	if negate {
+		k1.Key.Negate()
+		k2.Key.Negate()
+	}
+	s := new(btcec.ModNScalar).Mul2(b, &k2.Key).Add(&k1.Key)
+	s.Add(e.Mul(c).Mul(&d))
+	sb := s.Bytes()
+	return append(nonce[:], sb[:]...)
+}
+
+func testTaprootKeys() (*btcec.PrivateKey, *btcec.PrivateKey, *btcec.PrivateKey) {
+	var keys []*btcec.PrivateKey
+	for _, k := range []string{testTaprootHolderKey, testTaprootSignerKey, testTaprootObserverKey} {
+		b, _ := hex.DecodeString(k)
+		priv, _ := btcec.PrivKeyFromBytes(b)
+		keys = append(keys, priv)
+	}
+	return keys[0], keys[1], keys[2]
+}
+
+func testTaprootOutputAddress(pkScript []byte) string {
+	addr, _ := ExtractPkScriptAddr(pkScript, ChainBitcoin)
+	return addr
+}
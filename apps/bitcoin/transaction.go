@@ -2,7 +2,6 @@ package bitcoin
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"time"
@@ -10,6 +9,7 @@ import (
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -64,7 +64,22 @@ func (psbt *PartiallySignedTransaction) EstimateVirtualSize() int {
 		panic(err)
 	}
 	const witnessSize = 264
-	weight := len(tx.MsgTx().TxIn) * witnessSize
+	const taprootWitnessSize = 376
+	const taprootKeySpendWitnessSize = 68
+	const scriptSigSize = 154
+	var weight int
+	for idx := range tx.MsgTx().TxIn {
+		switch {
+		case psbt.IsTaprootKeySpendInput(idx):
+			weight = weight + taprootKeySpendWitnessSize
+		case psbt.IsTaprootInput(idx):
+			weight = weight + taprootWitnessSize
+		case psbt.IsScriptHashInput(idx):
+			weight = weight + 4*(scriptSigSize+len(psbt.Inputs[idx].RedeemScript))
+		default:
+			weight = weight + witnessSize
+		}
+	}
 	weight = weight + 4*len(raw)
 	return weight / 4
 }
@@ -84,13 +99,44 @@ func (psbt *PartiallySignedTransaction) IsRecoveryTransaction() bool {
 		psbt.UnsignedTx.TxIn[0].Sequence != MaxTransactionSequence
 }
 
+func (psbt *PartiallySignedTransaction) IsTaprootInput(idx int) bool {
+	return len(psbt.Inputs[idx].TaprootInternalKey) > 0
+}
+
+func (psbt *PartiallySignedTransaction) IsScriptHashInput(idx int) bool {
+	return len(psbt.Inputs[idx].RedeemScript) > 0
+}
+
 func (psbt *PartiallySignedTransaction) SigHash(idx int) []byte {
 	tx := psbt.UnsignedTx
 	pin := psbt.Inputs[idx]
 	satoshi := pin.WitnessUtxo.Value
-	pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessScript, satoshi)
+	if psbt.IsTaprootInput(idx) {
+		pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessUtxo.PkScript, satoshi)
+		tsh := txscript.NewTxSigHashes(tx, pof)
+		if psbt.IsTaprootKeySpendInput(idx) {
+			hash, err := txscript.CalcTaprootSignatureHash(tsh, SigHashType, tx, idx, pof)
+			if err != nil {
+				panic(err)
+			}
+			return hash
+		}
+		ls := pin.TaprootLeafScript[0]
+		leaf := txscript.NewTapLeaf(ls.LeafVersion, ls.Script)
+		hash, err := txscript.CalcTapscriptSignaturehash(tsh, SigHashType, tx, idx, pof, leaf)
+		if err != nil {
+			panic(err)
+		}
+		return hash
+	}
+	script, hashType := pin.WitnessScript, SigHashType
+	if psbt.IsScriptHashInput(idx) {
+		// bitcoin cash signs the legacy inputs with BIP143 and SIGHASH_FORKID
+		script, hashType = pin.RedeemScript, SigHashType|SigHashForkID
+	}
+	pof := txscript.NewCannedPrevOutputFetcher(script, satoshi)
 	tsh := txscript.NewTxSigHashes(tx, pof)
-	hash, err := txscript.CalcWitnessSigHash(pin.WitnessScript, tsh, SigHashType, tx, idx, satoshi)
+	hash, err := txscript.CalcWitnessSigHash(script, tsh, hashType, tx, idx, satoshi)
 	if err != nil {
 		panic(err)
 	}
@@ -102,19 +148,28 @@ func (psbt *PartiallySignedTransaction) SignedTransaction(holder, signer, observ
 	isRecoveryTransaction := psbt.IsRecoveryTransaction()
 	for idx := range msgTx.TxIn {
 		pin := psbt.Inputs[idx]
-		sigs := make(map[string][]byte, 2)
-		for _, ps := range pin.PartialSigs {
-			pub := hex.EncodeToString(ps.PubKey)
-			sig, err := CanonicalSignatureDER(ps.Signature)
-			if err != nil {
-				return nil, err
+		if psbt.IsTaprootKeySpendInput(idx) {
+			err := psbt.verifyTaprootKeySpendSig(idx)
+			if isRecoveryTransaction || err != nil {
+				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) key spend %v", holder, signer, observer, err)
 			}
-			sigs[pub] = sig
+			msgTx.TxIn[idx].Witness = wire.TxWitness{pin.TaprootKeySpendSig}
+			continue
+		}
+		sigs, err := psbt.inputSignatures(idx)
+		if err != nil {
+			return nil, err
 		}
 
+		taproot := psbt.IsTaprootInput(idx)
 		holderSig := sigs[holder]
 		signerSig := sigs[signer]
 		observerSig := sigs[observer]
+		if taproot {
+			holderSig = sigs[XOnlyPublicKey(holder)]
+			signerSig = sigs[XOnlyPublicKey(signer)]
+			observerSig = sigs[XOnlyPublicKey(observer)]
+		}
 		switch {
 		case isRecoveryTransaction:
 			if observerSig == nil {
@@ -135,7 +190,9 @@ func (psbt *PartiallySignedTransaction) SignedTransaction(holder, signer, observ
 		if observerSig != nil {
 			observerSig = append(observerSig, byte(pin.SighashType))
 		}
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, observerSig)
+		if !taproot || isRecoveryTransaction {
+			msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, observerSig)
+		}
 		if signerSig != nil {
 			signerSig = append(signerSig, byte(pin.SighashType))
 		}
@@ -144,7 +201,25 @@ func (psbt *PartiallySignedTransaction) SignedTransaction(holder, signer, observ
 			holderSig = append(holderSig, byte(pin.SighashType))
 		}
 		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, holderSig)
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, pin.WitnessScript)
+		switch {
+		case taproot:
+			ls := pin.TaprootLeafScript[0]
+			msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, ls.Script, ls.ControlBlock)
+		case psbt.IsScriptHashInput(idx):
+			builder := txscript.NewScriptBuilder()
+			for _, item := range msgTx.TxIn[idx].Witness {
+				builder.AddData(item)
+			}
+			builder.AddData(pin.RedeemScript)
+			script, err := builder.Script()
+			if err != nil {
+				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) script %v", holder, signer, observer, err)
+			}
+			msgTx.TxIn[idx].SignatureScript = script
+			msgTx.TxIn[idx].Witness = nil
+		default:
+			msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, pin.WitnessScript)
+		}
 	}
 	return msgTx, nil
 }
@@ -163,22 +238,11 @@ func CheckTransactionPartiallySignedBy(raw, public string) bool {
 	psbt, _ := UnmarshalPartiallySignedTransaction(b)
 
 	for i := range psbt.Inputs {
-		pin := psbt.Inputs[i]
-		sigs := make(map[string][]byte, 2)
-		for _, ps := range pin.PartialSigs {
-			pub := hex.EncodeToString(ps.PubKey)
-			sig, err := CanonicalSignatureDER(ps.Signature)
-			if err != nil {
-				return false
-			}
-			sigs[pub] = sig
-		}
-
-		if sigs[public] == nil {
+		sig := psbt.InputSignature(i, public)
+		if sig == nil {
 			return false
 		}
-		hash := psbt.SigHash(i)
-		err := VerifySignatureDER(public, hash, sigs[public])
+		err := psbt.VerifyInputSignature(i, public, sig)
 		if err != nil {
 			return false
 		}
@@ -187,7 +251,155 @@ func CheckTransactionPartiallySignedBy(raw, public string) bool {
 	return len(psbt.Inputs) > 0
 }
 
+func (psbt *PartiallySignedTransaction) InputSigners(idx int) []string {
+	pin := psbt.Inputs[idx]
+	var signers []string
+	for _, ps := range pin.PartialSigs {
+		signers = append(signers, hex.EncodeToString(ps.PubKey))
+	}
+	for _, ps := range pin.TaprootScriptSpendSig {
+		signers = append(signers, hex.EncodeToString(ps.XOnlyPubKey))
+	}
+	if psbt.IsTaprootKeySpendInput(idx) {
+		sigs := psbt.taprootKeySpendSignatures(idx)
+		for _, pub := range psbt.taprootParticipants(idx) {
+			key := hex.EncodeToString(schnorr.SerializePubKey(pub))
+			if sigs[key] != nil {
+				signers = append(signers, key)
+			}
+		}
+	}
+	return signers
+}
+
+func (psbt *PartiallySignedTransaction) InputSignature(idx int, public string) []byte {
+	sigs, err := psbt.inputSignatures(idx)
+	if err != nil {
+		return nil
+	}
+	if psbt.IsTaprootInput(idx) {
+		if VerifyTaprootKey(public) != nil {
+			return nil
+		}
+		public = XOnlyPublicKey(public)
+	}
+	return sigs[public]
+}
+
+func (psbt *PartiallySignedTransaction) VerifyInputSignature(idx int, public string, sig []byte) error {
+	if psbt.IsTaprootKeySpendInput(idx) {
+		return psbt.verifyTaprootKeySpendSignature(idx, public, sig)
+	}
+	hash := psbt.SigHash(idx)
+	if psbt.IsTaprootInput(idx) {
+		return VerifySignatureSchnorr(public, hash, sig)
+	}
+	return VerifySignatureDER(public, hash, sig)
+}
+
+// SetInputSignature replaces all signatures of the input with the signature of public
+func (raw *PartiallySignedTransaction) SetInputSignature(idx int, public string, sig []byte) {
+	pin := &raw.Inputs[idx]
+	if !raw.IsTaprootInput(idx) {
+		pub, err := hex.DecodeString(public)
+		if err != nil {
+			panic(public)
+		}
+		pin.PartialSigs = []*psbt.PartialSig{{
+			PubKey:    pub,
+			Signature: sig,
+		}}
+		return
+	}
+	if raw.IsTaprootKeySpendInput(idx) {
+		raw.setTaprootKeySpendSignature(idx, public, sig, true)
+		return
+	}
+	pub, err := hex.DecodeString(XOnlyPublicKey(public))
+	if err != nil {
+		panic(public)
+	}
+	ls := pin.TaprootLeafScript[0]
+	leaf := txscript.NewTapLeaf(ls.LeafVersion, ls.Script).TapHash()
+	pin.TaprootScriptSpendSig = []*psbt.TaprootScriptSpendSig{{
+		XOnlyPubKey: pub,
+		LeafHash:    leaf[:],
+		Signature:   sig,
+		SigHash:     SigHashType,
+	}}
+}
+
+// AddInputSignature appends the signature of public to the input
+func (raw *PartiallySignedTransaction) AddInputSignature(idx int, public string, sig []byte) {
+	pin := &raw.Inputs[idx]
+	if !raw.IsTaprootInput(idx) {
+		pub, err := hex.DecodeString(public)
+		if err != nil {
+			panic(public)
+		}
+		pin.PartialSigs = append(pin.PartialSigs, &psbt.PartialSig{
+			PubKey:    pub,
+			Signature: sig,
+		})
+		return
+	}
+	if raw.IsTaprootKeySpendInput(idx) {
+		raw.setTaprootKeySpendSignature(idx, public, sig, false)
+		return
+	}
+	pub, err := hex.DecodeString(XOnlyPublicKey(public))
+	if err != nil {
+		panic(public)
+	}
+	ls := pin.TaprootLeafScript[0]
+	leaf := txscript.NewTapLeaf(ls.LeafVersion, ls.Script).TapHash()
+	pin.TaprootScriptSpendSig = append(pin.TaprootScriptSpendSig, &psbt.TaprootScriptSpendSig{
+		XOnlyPubKey: pub,
+		LeafHash:    leaf[:],
+		Signature:   sig,
+		SigHash:     SigHashType,
+	})
+}
+
+func (psbt *PartiallySignedTransaction) inputSignatures(idx int) (map[string][]byte, error) {
+	pin := psbt.Inputs[idx]
+	sigs := make(map[string][]byte, 3)
+	for _, ps := range pin.PartialSigs {
+		pub := hex.EncodeToString(ps.PubKey)
+		sig, err := CanonicalSignatureDER(ps.Signature)
+		if err != nil {
+			return nil, err
+		}
+		sigs[pub] = sig
+	}
+	for _, ps := range pin.TaprootScriptSpendSig {
+		pub := hex.EncodeToString(ps.XOnlyPubKey)
+		sigs[pub] = append([]byte{}, ps.Signature...)
+	}
+	if psbt.IsTaprootKeySpendInput(idx) {
+		for pub, sig := range psbt.taprootKeySpendSignatures(idx) {
+			sigs[pub] = sig
+		}
+	}
+	return sigs, nil
+}
+
 func SpendSignedTransaction(raw string, feeInputs []*Input, accountant string, chain byte) (*wire.MsgTx, error) {
+	b, err := hex.DecodeString(accountant)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, publicKey := btcec.PrivKeyFromBytes(b)
+	public := hex.EncodeToString(publicKey.SerializeCompressed())
+	return SpendSignedTransactionWithSigner(raw, feeInputs, public, func(hash []byte) ([]byte, error) {
+		return ecdsa.Sign(privateKey, hash).Serialize(), nil
+	}, chain)
+}
+
+// SpendSignedTransactionWithSigner adds the accountant fee inputs of the
+// public key to the signed transaction, and the sign function returns the
+// DER signature of the input hash by the accountant key.
+func SpendSignedTransactionWithSigner(raw string, feeInputs []*Input, public string, sign func(hash []byte) ([]byte, error), chain byte) (*wire.MsgTx, error) {
 	b, err := hex.DecodeString(raw)
 	if err != nil {
 		return nil, err
@@ -199,12 +411,7 @@ func SpendSignedTransaction(raw string, feeInputs []*Input, accountant string, c
 	msgTx := rtx.MsgTx()
 	mainCount := len(msgTx.TxIn)
 
-	b, err = hex.DecodeString(accountant)
-	if err != nil {
-		return nil, err
-	}
-	privateKey, publicKey := btcec.PrivKeyFromBytes(b)
-	apk, err := btcutil.NewAddressPubKey(publicKey.SerializeCompressed(), NetConfig(chain))
+	apk, err := parseBitcoinCompressedPublicKey(public)
 	if err != nil {
 		return nil, err
 	}
@@ -219,17 +426,34 @@ func SpendSignedTransaction(raw string, feeInputs []*Input, accountant string, c
 		return nil, fmt.Errorf("addInputs(fee) => %v", err)
 	}
 
+	hashType := txscript.SigHashAll
+	if chain == ChainBitcoinCash {
+		hashType = hashType | SigHashForkID
+	}
 	for idx, in := range feeInputs {
 		script := scripts[idx]
 		idx = idx + mainCount
 		pof := txscript.NewCannedPrevOutputFetcher(in.Script, in.Satoshi)
 		tsh := txscript.NewTxSigHashes(msgTx, pof)
-		hash, err := txscript.CalcWitnessSigHash(in.Script, tsh, txscript.SigHashAll, msgTx, idx, in.Satoshi)
+		hash, err := txscript.CalcWitnessSigHash(in.Script, tsh, hashType, msgTx, idx, in.Satoshi)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := sign(hash)
 		if err != nil {
 			return nil, err
 		}
-		signature := ecdsa.Sign(privateKey, hash)
-		sig := append(signature.Serialize(), byte(txscript.SigHashAll))
+		sig := append(signature, byte(hashType))
+		if chain == ChainBitcoinCash {
+			builder := txscript.NewScriptBuilder()
+			builder.AddData(sig)
+			builder.AddData(script)
+			msgTx.TxIn[idx].SignatureScript, err = builder.Script()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, sig)
 		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, script)
 	}
@@ -309,11 +533,7 @@ func BuildPartiallySignedTransaction(mainInputs []*Input, outputs []*Output, rid
 	}
 	for i, in := range mainInputs {
 		address := mainAddress
-		addr, err := btcutil.DecodeAddress(address, NetConfig(chain))
-		if err != nil {
-			panic(address)
-		}
-		pkScript, err := txscript.PayToAddrScript(addr)
+		pkScript, err := ParseAddress(address, chain)
 		if err != nil {
 			panic(address)
 		}
@@ -321,8 +541,23 @@ func BuildPartiallySignedTransaction(mainInputs []*Input, outputs []*Output, rid
 			Value:    in.Satoshi,
 			PkScript: pkScript,
 		})
-		pin.WitnessScript = in.Script
 		pin.SighashType = SigHashType
+		switch {
+		case chain == ChainBitcoinCash:
+			pin.SighashType = SigHashType | SigHashForkID
+			pin.RedeemScript = in.Script
+		case CheckTaprootScript(in.Script):
+			tst, err := parseTaprootScript(in.Script)
+			if err != nil {
+				panic(address)
+			}
+			err = tst.buildInput(pin, in.RouteBackup)
+			if err != nil {
+				return nil, fmt.Errorf("taproot.buildInput(%s) => %v", address, err)
+			}
+		default:
+			pin.WitnessScript = in.Script
+		}
 		if !pin.IsSane() {
 			panic(address)
 		}
@@ -370,41 +605,54 @@ func addInput(tx *wire.MsgTx, in *Input, chain byte) (string, error) {
 		},
 	}
 	typ := checkScriptType(in.Script)
-	if in.RouteBackup {
+	switch {
+	case in.RouteBackup && typ == InputTypeP2TRHolderSigner:
+		typ = InputTypeP2TRObserverSigner
+	case in.RouteBackup:
 		typ = InputTypeP2WSHMultisigObserverSigner
 	}
 	switch typ {
 	case InputTypeP2WPKHAccoutant:
-		in.Script = btcutil.Hash160(in.Script)
-		wpkh, err := btcutil.NewAddressWitnessPubKeyHash(in.Script, NetConfig(chain))
+		addr, err = EncodeAccountantAddress(in.Script, chain)
 		if err != nil {
 			return "", err
 		}
-		builder := txscript.NewScriptBuilder()
-		builder.AddOp(txscript.OP_0)
-		builder.AddData(in.Script)
-		script, err := builder.Script()
+		in.Script, err = ParseAddress(addr, chain)
 		if err != nil {
 			return "", err
 		}
-		in.Script = script
-		addr = wpkh.EncodeAddress()
 		txIn.Sequence = MaxTransactionSequence
 	case InputTypeP2WSHMultisigHolderSigner:
-		msh := sha256.Sum256(in.Script)
-		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+		addr, err = encodeScriptHashAddress(in.Script, chain)
 		if err != nil {
 			return "", err
 		}
-		addr = mwsh.EncodeAddress()
 		txIn.Sequence = MaxTransactionSequence
 	case InputTypeP2WSHMultisigObserverSigner:
-		msh := sha256.Sum256(in.Script)
-		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+		addr, err = encodeScriptHashAddress(in.Script, chain)
+		if err != nil {
+			return "", err
+		}
+		txIn.Sequence = in.Sequence
+	case InputTypeP2TRHolderSigner:
+		tst, err := parseTaprootScript(in.Script)
+		if err != nil {
+			return "", err
+		}
+		addr, err = tst.encodeAddress(chain)
+		if err != nil {
+			return "", err
+		}
+		txIn.Sequence = MaxTransactionSequence
+	case InputTypeP2TRObserverSigner:
+		tst, err := parseTaprootScript(in.Script)
+		if err != nil {
+			return "", err
+		}
+		addr, err = tst.encodeAddress(chain)
 		if err != nil {
 			return "", err
 		}
-		addr = mwsh.EncodeAddress()
 		txIn.Sequence = in.Sequence
 	default:
 		return "", fmt.Errorf("invalid input type %d", typ)
@@ -417,11 +665,7 @@ func addInput(tx *wire.MsgTx, in *Input, chain byte) (string, error) {
 }
 
 func addOutput(tx *wire.MsgTx, address string, satoshi int64, chain byte) (bool, error) {
-	addr, err := btcutil.DecodeAddress(address, NetConfig(chain))
-	if err != nil {
-		return false, err
-	}
-	script, err := txscript.PayToAddrScript(addr)
+	script, err := ParseAddress(address, chain)
 	if err != nil {
 		return false, err
 	}
@@ -433,10 +677,31 @@ func addOutput(tx *wire.MsgTx, address string, satoshi int64, chain byte) (bool,
 	return true, nil
 }
 
+// SignPartiallySignedTransaction signs all inputs except the taproot key path
+// inputs, which are signed with ApproveTaprootKeySpendInput and SignTaprootKeySpendInput.
 func SignPartiallySignedTransaction(raw []byte, signer *secp256k1.PrivateKey) *PartiallySignedTransaction {
 	psTx, _ := UnmarshalPartiallySignedTransaction(raw)
 	for idx := range psTx.UnsignedTx.TxIn {
+		if psTx.IsTaprootKeySpendInput(idx) {
+			continue
+		}
 		hash := psTx.SigHash(idx)
+		if psTx.IsTaprootInput(idx) {
+			sig, err := schnorr.Sign(signer, hash)
+			if err != nil {
+				panic(err)
+			}
+			ls := psTx.Inputs[idx].TaprootLeafScript[0]
+			leaf := txscript.NewTapLeaf(ls.LeafVersion, ls.Script).TapHash()
+			osig := &psbt.TaprootScriptSpendSig{
+				XOnlyPubKey: schnorr.SerializePubKey(signer.PubKey()),
+				LeafHash:    leaf[:],
+				Signature:   sig.Serialize(),
+				SigHash:     SigHashType,
+			}
+			psTx.Inputs[idx].TaprootScriptSpendSig = append(psTx.Inputs[idx].TaprootScriptSpendSig, osig)
+			continue
+		}
 		sig := ecdsa.Sign(signer, hash).Serialize()
 
 		osig := &psbt.PartialSig{
@@ -0,0 +1,202 @@
+package ethereum
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChainConfig describes an EVM chain supported by safe, all chains share the
+// same Gnosis Safe contracts, and only differ in the parameters below. The
+// bond factory is deployed on only one of the chains, which hosts the bonds
+// of all safe assets.
+type ChainConfig struct {
+	Chain             byte          `toml:"chain"`
+	Curve             byte          `toml:"curve"`
+	Name              string        `toml:"name"`
+	EvmChainId        int64         `toml:"evm-chain-id"`
+	MixinChainId      string        `toml:"mixin-chain-id"`
+	Precision         int32         `toml:"precision"`
+	Confirmations     uint64        `toml:"confirmations"`
+	FinalizationDelay int64         `toml:"finalization-delay"`
+	DepositCheckpoint int64         `toml:"deposit-checkpoint"`
+	BlockInterval     time.Duration `toml:"block-interval"`
+	FactoryAddress    string        `toml:"factory-address"`
+}
+
+var chains = struct {
+	sync.RWMutex
+	m map[byte]*ChainConfig
+}{m: make(map[byte]*ChainConfig)}
+
+func init() {
+	RegisterChain(&ChainConfig{
+		Chain:             ChainEthereum,
+		Curve:             2,
+		Name:              "ethereum",
+		EvmChainId:        1,
+		MixinChainId:      "43d61dcd-e413-450d-80b8-101d5e903357",
+		Precision:         ValuePrecision,
+		Confirmations:     1,
+		FinalizationDelay: 32,
+		DepositCheckpoint: 19175473,
+		BlockInterval:     5 * time.Second,
+	})
+	RegisterChain(&ChainConfig{
+		Chain:             ChainPolygon,
+		Curve:             112,
+		Name:              "polygon",
+		EvmChainId:        137,
+		MixinChainId:      "b7938396-3f94-4e0a-9179-d3440718156f",
+		Precision:         ValuePrecision,
+		Confirmations:     256,
+		FinalizationDelay: 512,
+		DepositCheckpoint: 52950000,
+		BlockInterval:     2 * time.Second,
+		FactoryAddress:    "0x4D17777E0AC12C6a0d4DEF1204278cFEAe142a1E",
+	})
+	RegisterChain(&ChainConfig{
+		Chain:             ChainMVM,
+		Curve:             102,
+		Name:              "mvm",
+		EvmChainId:        73927,
+		MixinChainId:      "a0ffd769-5850-4b48-9651-d2ae44a3e64d",
+		Precision:         ValuePrecision,
+		Confirmations:     1,
+		FinalizationDelay: 1,
+		DepositCheckpoint: 1,
+		BlockInterval:     time.Second,
+	})
+}
+
+// RegisterChain adds an EVM chain to the registry, the chain, curve, name and
+// Mixin chain id must all be unique, and only one chain could have the bond
+// factory, otherwise it panics. Registering the same config again is a no-op,
+// so the chains from the configuration could be registered by every node.
+func RegisterChain(c *ChainConfig) {
+	err := c.validate()
+	if err != nil {
+		panic(err)
+	}
+	chains.Lock()
+	defer chains.Unlock()
+	for _, o := range chains.m {
+		if *o == *c {
+			return
+		}
+		if o.Chain == c.Chain || o.Curve == c.Curve || o.Name == c.Name ||
+			o.EvmChainId == c.EvmChainId || o.MixinChainId == c.MixinChainId {
+			panic(fmt.Errorf("duplicated evm chain %v %v", o, c))
+		}
+		if o.FactoryAddress != "" && c.FactoryAddress != "" {
+			panic(fmt.Errorf("duplicated evm bond chain %v %v", o, c))
+		}
+	}
+	chains.m[c.Chain] = c
+}
+
+func GetChainConfig(chain byte) *ChainConfig {
+	chains.RLock()
+	defer chains.RUnlock()
+	return chains.m[chain]
+}
+
+func GetChainConfigByCurve(crv byte) *ChainConfig {
+	chains.RLock()
+	defer chains.RUnlock()
+	for _, c := range chains.m {
+		if c.Curve == crv {
+			return c
+		}
+	}
+	return nil
+}
+
+func GetChainConfigByMixinChainId(id string) *ChainConfig {
+	chains.RLock()
+	defer chains.RUnlock()
+	for _, c := range chains.m {
+		if c.MixinChainId == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// BondChain returns the chain with the bond factory.
+func BondChain() *ChainConfig {
+	chains.RLock()
+	defer chains.RUnlock()
+	for _, c := range chains.m {
+		if c.FactoryAddress != "" {
+			return c
+		}
+	}
+	panic("no evm bond chain")
+}
+
+// ChainRPC returns the RPC of the chain from the rpcs table by the chain
+// name, falling back to the legacy ethereum and polygon RPC.
+func ChainRPC(chain byte, rpcs map[string]string, ethereumRPC, polygonRPC string) string {
+	c := mustChainConfig(chain)
+	if rpc := rpcs[c.Name]; rpc != "" {
+		return rpc
+	}
+	switch chain {
+	case ChainEthereum:
+		return ethereumRPC
+	case ChainPolygon:
+		return polygonRPC
+	}
+	return ""
+}
+
+func IsEVMChain(chain byte) bool {
+	return GetChainConfig(chain) != nil
+}
+
+func ListChains() []*ChainConfig {
+	chains.RLock()
+	defer chains.RUnlock()
+	list := make([]*ChainConfig, 0, len(chains.m))
+	for _, c := range chains.m {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Chain < list[j].Chain })
+	return list
+}
+
+func mustChainConfig(chain byte) *ChainConfig {
+	c := GetChainConfig(chain)
+	if c == nil {
+		panic(chain)
+	}
+	return c
+}
+
+func (c *ChainConfig) validate() error {
+	switch {
+	case c.Chain == 0:
+		return fmt.Errorf("invalid evm chain %v", c)
+	case c.Curve%10 != 2:
+		return fmt.Errorf("invalid evm chain curve %v", c)
+	case c.Name == "":
+		return fmt.Errorf("invalid evm chain name %v", c)
+	case c.EvmChainId <= 0:
+		return fmt.Errorf("invalid evm chain id %v", c)
+	case len(c.MixinChainId) != 36:
+		return fmt.Errorf("invalid evm mixin chain id %v", c)
+	case c.Precision <= 0 || c.Precision > ValuePrecision:
+		return fmt.Errorf("invalid evm chain precision %v", c)
+	case c.Confirmations < 1 || c.FinalizationDelay < 1:
+		return fmt.Errorf("invalid evm chain finalization %v", c)
+	case c.DepositCheckpoint < 1 || c.BlockInterval <= 0:
+		return fmt.Errorf("invalid evm chain blocks %v", c)
+	case c.FactoryAddress != "" && !common.IsHexAddress(c.FactoryAddress):
+		return fmt.Errorf("invalid evm chain factory %v", c)
+	}
+	return nil
+}
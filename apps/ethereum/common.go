@@ -24,7 +24,7 @@ import (
 
 const (
 	ChainEthereum = 2
-	chainMVM      = 4
+	ChainMVM      = 4
 	ChainPolygon  = 6
 
 	ValuePrecision = 18
@@ -215,16 +215,7 @@ func VerifyDeposit(ctx context.Context, chain byte, rpc, hash, chainId, assetAdd
 }
 
 func CheckFinalization(num uint64, chain byte) bool {
-	switch chain {
-	case ChainEthereum:
-		return num >= 1
-	case ChainPolygon:
-		return num >= 256
-	case chainMVM:
-		return num >= 1
-	default:
-		panic(chain)
-	}
+	return num >= mustChainConfig(chain).Confirmations
 }
 
 func ParseAmount(amount string, decimals int32) *big.Int {
@@ -245,29 +236,17 @@ func UnitAmount(amount *big.Int, decimals int32) string {
 }
 
 func GetEvmChainID(chain int64) int64 {
-	switch chain {
-	case ChainEthereum:
-		return 1
-	case ChainPolygon:
-		return 137
-	case chainMVM:
-		return 73927
-	default:
+	if chain <= 0 || chain > 255 {
 		panic(chain)
 	}
+	return mustChainConfig(byte(chain)).EvmChainId
 }
 
 func GetMixinChainID(chain int64) string {
-	switch chain {
-	case ChainEthereum:
-		return "43d61dcd-e413-450d-80b8-101d5e903357"
-	case ChainPolygon:
-		return "b7938396-3f94-4e0a-9179-d3440718156f"
-	case chainMVM:
-		return "a0ffd769-5850-4b48-9651-d2ae44a3e64d"
-	default:
+	if chain <= 0 || chain > 255 {
 		panic(chain)
 	}
+	return mustChainConfig(byte(chain)).MixinChainId
 }
 
 func FetchAsset(chain byte, rpc, address string) (*Asset, error) {
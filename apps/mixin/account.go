@@ -0,0 +1,156 @@
+package mixin
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	sdk "github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
+)
+
+const (
+	KernelAccountThreshold = 2
+
+	kernelKeyIndexHolder   = 0
+	kernelKeyIndexSigner   = 1
+	kernelKeyIndexObserver = 2
+)
+
+// the kernel account is a 2/3 multisig of the holder, signer and observer
+// spend keys, and all of them use the observer key as the view key, so the
+// observer could track all deposits and changes without the ability to spend
+// alone. The holder and signer spend the normal transactions together.
+//
+// The kernel has no timelock script, so any two of the keys could spend at any
+// time. The keeper only requests the signer signatures for transactions signed
+// by the holder, thus the signer and observer never spend without the holder,
+// but the holder and observer could, and the observer is trusted to not sign
+// with the holder before the safe timelock expires.
+type KernelAccount struct {
+	Holder   string
+	Signer   string
+	Observer string
+	Address  string
+}
+
+func BuildKernelAccount(holder, signer, observer string) (*KernelAccount, error) {
+	view, err := ParseKey(observer)
+	if err != nil {
+		return nil, fmt.Errorf("mixin.ParseKey(%s) => %v", observer, err)
+	}
+	var members []string
+	for _, pub := range KernelAccountKeys(holder, signer, observer) {
+		spend, err := ParseKey(pub)
+		if err != nil {
+			return nil, fmt.Errorf("mixin.ParseKey(%s) => %v", pub, err)
+		}
+		addr := mixinnet.Address{
+			PublicSpendKey: spend,
+			PublicViewKey:  view,
+		}
+		members = append(members, addr.String())
+	}
+	ma, err := sdk.NewMainnetMixAddress(members, KernelAccountThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("mixin.NewMainnetMixAddress(%v) => %v", members, err)
+	}
+	return &KernelAccount{
+		Holder:   holder,
+		Signer:   signer,
+		Observer: observer,
+		Address:  ma.String(),
+	}, nil
+}
+
+// the output keys and signature indexes follow the same members order
+func KernelAccountKeys(holder, signer, observer string) []string {
+	keys := make([]string, 3)
+	keys[kernelKeyIndexHolder] = holder
+	keys[kernelKeyIndexSigner] = signer
+	keys[kernelKeyIndexObserver] = observer
+	return keys
+}
+
+func KernelAccountScript() string {
+	return mixinnet.NewThresholdScript(KernelAccountThreshold).String()
+}
+
+func (ka *KernelAccount) Marshal() []byte {
+	enc := common.NewEncoder()
+	WriteBytes(enc, []byte(ka.Holder))
+	WriteBytes(enc, []byte(ka.Signer))
+	WriteBytes(enc, []byte(ka.Observer))
+	WriteBytes(enc, []byte(ka.Address))
+	return enc.Bytes()
+}
+
+func UnmarshalKernelAccount(extra []byte) (*KernelAccount, error) {
+	dec := common.NewDecoder(extra)
+	holder, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	observer, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &KernelAccount{
+		Holder:   string(holder),
+		Signer:   string(signer),
+		Observer: string(observer),
+		Address:  string(addr),
+	}, nil
+}
+
+// the output key P = x*G + B, where B is the member public key, and
+// x = Hs(r*A) is the mask derivation scalar known to the observer
+func DeriveOutputKey(public string, mask []byte) (*mixinnet.Key, error) {
+	spend, err := ParseKey(public)
+	if err != nil {
+		return nil, err
+	}
+	x, err := parseScalar(mask)
+	if err != nil {
+		return nil, err
+	}
+	B, err := spend.ToPoint()
+	if err != nil {
+		return nil, err
+	}
+	P := edwards25519Add(B, x)
+	return &P, nil
+}
+
+// the observer views the output with the private view key a, and returns
+// the mask derivation scalar x if the output keys belong to the account
+// members in order. All members share the same view key, so x is the same
+// for all output keys.
+func ViewOutputMask(members []string, view mixinnet.Key, keys []string, mask string, index uint8) ([]byte, bool) {
+	if len(keys) != len(members) {
+		return nil, false
+	}
+	R, err := ParseKey(mask)
+	if err != nil {
+		return nil, false
+	}
+	for i, k := range keys {
+		P, err := ParseKey(k)
+		if err != nil {
+			return nil, false
+		}
+		B := mixinnet.ViewGhostOutputKey(mixinnet.TxVersion, &P, &view, &R, index)
+		if B.String() != members[i] {
+			return nil, false
+		}
+	}
+	x := mixinnet.HashScalar(mixinnet.TxVersion, mixinnet.KeyMultPubPriv(&R, &view), index)
+	return x.Bytes(), true
+}
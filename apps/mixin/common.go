@@ -1,3 +1,76 @@
 package mixin
 
-const OutputTypeWithdrawalClaim = 0xa9
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ChainMixinKernel = 3
+
+	ValuePrecision = 8
+	MaxUnspentUtxo = 256
+
+	OutputTypeWithdrawalClaim = 0xa9
+)
+
+func ParseAddress(addr string) (*mixinnet.Address, error) {
+	ma, err := mixinnet.AddressFromString(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ma.String() != addr {
+		return nil, fmt.Errorf("mixin.ParseAddress(%s) => %s", addr, ma.String())
+	}
+	return &ma, nil
+}
+
+func ParseKey(public string) (mixinnet.Key, error) {
+	key, err := mixinnet.KeyFromString(public)
+	if err != nil {
+		return key, err
+	}
+	if !key.CheckKey() {
+		return key, fmt.Errorf("invalid mixin key %s", public)
+	}
+	return key, nil
+}
+
+func VerifyHolderKey(public string) error {
+	_, err := ParseKey(public)
+	return err
+}
+
+func VerifySignature(public string, msg, sig []byte) error {
+	key, err := ParseKey(public)
+	if err != nil {
+		return err
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid mixin signature size %d", len(sig))
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key[:]), msg, sig) {
+		return fmt.Errorf("invalid mixin signature %s %x %x", public, msg, sig)
+	}
+	return nil
+}
+
+func ParseAmount(amount string) mixinnet.Integer {
+	amt, err := decimal.NewFromString(amount)
+	if err != nil || amt.Sign() <= 0 {
+		panic(amount)
+	}
+	if amt.Exponent() < -ValuePrecision {
+		panic(amount)
+	}
+	return mixinnet.IntegerFromDecimal(amt)
+}
+
+func WriteBytes(enc *common.Encoder, b []byte) {
+	enc.WriteInt(len(b))
+	enc.Write(b)
+}
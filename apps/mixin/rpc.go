@@ -18,18 +18,29 @@ type WithdrawalData struct {
 	Tag     string `json:"tag"`
 }
 
+type RPCInput struct {
+	Hash  string `json:"hash"`
+	Index int    `json:"index"`
+}
+
 type Output struct {
 	Type       uint8           `json:"type"`
 	Amount     string          `json:"amount"`
+	Keys       []string        `json:"keys"`
+	Script     string          `json:"script"`
+	Mask       string          `json:"mask"`
 	Withdrawal *WithdrawalData `json:"withdrawal"`
 }
 
 type RPCTransaction struct {
-	Asset      string   `json:"asset"`
-	Extra      string   `json:"extra"`
-	Hash       string   `json:"hash"`
-	Output     []Output `json:"outputs"`
-	References []string `json:"references"`
+	Version    uint8      `json:"version"`
+	Asset      string     `json:"asset"`
+	Extra      string     `json:"extra"`
+	Hash       string     `json:"hash"`
+	Snapshot   string     `json:"snapshot"`
+	Input      []RPCInput `json:"inputs"`
+	Output     []Output   `json:"outputs"`
+	References []string   `json:"references"`
 }
 
 type RPCSnapshot struct {
@@ -39,6 +50,13 @@ type RPCSnapshot struct {
 	Topology    uint64           `json:"topology"`
 }
 
+type RPCMintDistribution struct {
+	Group       string `json:"group"`
+	Batch       uint64 `json:"batch"`
+	Amount      string `json:"amount"`
+	Transaction string `json:"transaction"`
+}
+
 func RPCGetTransaction(ctx context.Context, rpc, hash string) (*RPCTransaction, error) {
 	res, err := callMixinRPCUntilSufficient(rpc, "gettransaction", []any{hash})
 	if err != nil {
@@ -49,6 +67,31 @@ func RPCGetTransaction(ctx context.Context, rpc, hash string) (*RPCTransaction,
 	return r, err
 }
 
+func RPCGetUTXO(ctx context.Context, rpc, hash string, index int) (*Output, string, error) {
+	res, err := callMixinRPCUntilSufficient(rpc, "getutxo", []any{hash, fmt.Sprint(index)})
+	if err != nil {
+		return nil, "", err
+	}
+	var r struct {
+		Output
+		Lock string `json:"lock"`
+	}
+	err = json.Unmarshal(res, &r)
+	return &r.Output, r.Lock, err
+}
+
+func RPCSendRawTransaction(ctx context.Context, rpc, raw string) (string, error) {
+	res, err := callMixinRPCUntilSufficient(rpc, "sendrawtransaction", []any{raw})
+	if err != nil {
+		return "", err
+	}
+	var r struct {
+		Hash string `json:"hash"`
+	}
+	err = json.Unmarshal(res, &r)
+	return r.Hash, err
+}
+
 func RPCListSnapshots(ctx context.Context, rpc string, offset uint64, limit int) ([]RPCSnapshot, error) {
 	res, err := callMixinRPCUntilSufficient(rpc, "listsnapshots", []any{fmt.Sprint(offset), fmt.Sprint(limit), "false", "true"})
 	if err != nil {
@@ -62,6 +105,16 @@ func RPCListSnapshots(ctx context.Context, rpc string, offset uint64, limit int)
 	return r, err
 }
 
+func RPCListMintDistributions(ctx context.Context, rpc string, offset uint64, limit int) ([]*RPCMintDistribution, error) {
+	res, err := callMixinRPCUntilSufficient(rpc, "listmintdistributions", []any{fmt.Sprint(offset), fmt.Sprint(limit), "false"})
+	if err != nil {
+		return nil, err
+	}
+	var r []*RPCMintDistribution
+	err = json.Unmarshal(res, &r)
+	return r, err
+}
+
 func callMixinRPCUntilSufficient(rpc, method string, params []any) ([]byte, error) {
 	for {
 		res, err := callMixinRPC(rpc, method, params)
@@ -0,0 +1,352 @@
+package mixin
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"filippo.io/edwards25519"
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	sdk "github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+type Input struct {
+	TransactionHash string
+	Index           uint32
+	Amount          decimal.Decimal
+	Mask            []byte
+}
+
+type TransactionOutput struct {
+	Address string
+	Amount  decimal.Decimal
+}
+
+// the signatures are the signer signatures, and the holder signatures are
+// signed by the holder with the derived holder key of each input
+type PartiallySignedTransaction struct {
+	*mixinnet.Transaction
+	Masks            [][]byte
+	Signatures       [][]byte
+	HolderSignatures [][]byte
+}
+
+func BuildPartiallySignedTransaction(assetId string, inputs []*Input, outputs []*TransactionOutput, change, rid string, seed []byte) (*PartiallySignedTransaction, error) {
+	if len(inputs) == 0 || len(inputs) > MaxUnspentUtxo {
+		return nil, fmt.Errorf("invalid inputs count %d", len(inputs))
+	}
+	if len(outputs) == 0 || len(outputs)+1 > MaxUnspentUtxo {
+		return nil, fmt.Errorf("invalid outputs count %d", len(outputs))
+	}
+
+	tx := &mixinnet.Transaction{
+		Version: mixinnet.TxVersion,
+		Asset:   mixinnet.Hash(crypto.Sha256Hash([]byte(assetId))),
+		Extra:   uuid.Must(uuid.FromString(rid)).Bytes(),
+	}
+	psbt := &PartiallySignedTransaction{Transaction: tx}
+
+	var total decimal.Decimal
+	for _, in := range inputs {
+		h, err := mixinnet.HashFromString(in.TransactionHash)
+		if err != nil {
+			return nil, err
+		}
+		if in.Index > 255 || len(in.Mask) != 32 {
+			return nil, fmt.Errorf("invalid input %s:%d", in.TransactionHash, in.Index)
+		}
+		tx.Inputs = append(tx.Inputs, &mixinnet.Input{Hash: &h, Index: uint8(in.Index)})
+		psbt.Masks = append(psbt.Masks, in.Mask)
+		total = total.Add(in.Amount)
+	}
+
+	for _, out := range outputs {
+		if out.Amount.Sign() <= 0 || out.Amount.Exponent() < -ValuePrecision {
+			return nil, fmt.Errorf("invalid output amount %s", out.Amount)
+		}
+		err := psbt.addOutput(out.Address, out.Amount, seed)
+		if err != nil {
+			return nil, err
+		}
+		total = total.Sub(out.Amount)
+	}
+	if total.Sign() < 0 {
+		return nil, fmt.Errorf("insufficient inputs %s", total)
+	}
+	if total.Sign() > 0 {
+		err := psbt.addOutput(change, total, seed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	psbt.Signatures = make([][]byte, len(tx.Inputs))
+	psbt.HolderSignatures = make([][]byte, len(tx.Inputs))
+	return psbt, nil
+}
+
+func (psbt *PartiallySignedTransaction) addOutput(receiver string, amount decimal.Decimal, seed []byte) error {
+	members, threshold, err := parseReceiver(receiver)
+	if err != nil {
+		return err
+	}
+	index := len(psbt.Outputs)
+	buf := binary.BigEndian.AppendUint64(append([]byte{}, seed...), uint64(index))
+	sum := sha512.Sum512(buf)
+	r := mixinnet.KeyFromBytes(sum[:])
+	var keys []mixinnet.Key
+	for _, addr := range members {
+		key := mixinnet.DeriveGhostPublicKey(psbt.Version, &r, &addr.PublicViewKey, &addr.PublicSpendKey, uint8(index))
+		keys = append(keys, *key)
+	}
+	psbt.Outputs = append(psbt.Outputs, &mixinnet.Output{
+		Type:   mixinnet.OutputTypeScript,
+		Amount: mixinnet.IntegerFromDecimal(amount),
+		Keys:   keys,
+		Script: mixinnet.NewThresholdScript(threshold),
+		Mask:   r.Public(),
+	})
+	return nil
+}
+
+// the receiver is either a kernel address, or a mix address of kernel
+// addresses, e.g. the safe kernel account address for the change output
+func parseReceiver(receiver string) ([]*mixinnet.Address, uint8, error) {
+	if !strings.HasPrefix(receiver, sdk.MixAddressPrefix) {
+		addr, err := ParseAddress(receiver)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []*mixinnet.Address{addr}, 1, nil
+	}
+	ma, err := sdk.MixAddressFromString(receiver)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ma.String() != receiver {
+		return nil, 0, fmt.Errorf("mixin.MixAddressFromString(%s) => %s", receiver, ma.String())
+	}
+	var members []*mixinnet.Address
+	for _, m := range ma.Members() {
+		addr, err := ParseAddress(m)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid mix address member %s %s", receiver, m)
+		}
+		members = append(members, addr)
+	}
+	return members, ma.Threshold, nil
+}
+
+func (psbt *PartiallySignedTransaction) Hash() string {
+	h, err := psbt.TransactionHash()
+	if err != nil {
+		panic(err)
+	}
+	return h.String()
+}
+
+// the signer signs the message x || hash with the key derivation scalar x
+// prepended, so that the signature is valid for the output key x*G + B
+func (psbt *PartiallySignedTransaction) SigHash(idx int) []byte {
+	h, err := psbt.TransactionHash()
+	if err != nil {
+		panic(err)
+	}
+	return append(append([]byte{}, psbt.Masks[idx]...), h[:]...)
+}
+
+func (psbt *PartiallySignedTransaction) VerifySignature(public string, idx int, sig []byte) error {
+	P, err := DeriveOutputKey(public, psbt.Masks[idx])
+	if err != nil {
+		return err
+	}
+	h, err := psbt.TransactionHash()
+	if err != nil {
+		return err
+	}
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(ed25519.PublicKey(P[:]), h[:], sig) {
+		return fmt.Errorf("invalid mixin signature %d %x", idx, sig)
+	}
+	return nil
+}
+
+func (psbt *PartiallySignedTransaction) VerifyHolderSignature(holder string) error {
+	for idx, sig := range psbt.HolderSignatures {
+		err := psbt.VerifySignature(holder, idx, sig)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the holder key is an ed25519 seed, and the input key is x*G + a*G, where
+// a is the clamped scalar of the seed, so the holder signs all inputs with
+// the scalar x + a
+func (psbt *PartiallySignedTransaction) SignWithHolderKey(priv ed25519.PrivateKey) error {
+	digest := sha512.Sum512(priv.Seed())
+	a, err := edwards25519.NewScalar().SetBytesWithClamping(digest[:32])
+	if err != nil {
+		return err
+	}
+	h, err := psbt.TransactionHash()
+	if err != nil {
+		return err
+	}
+	for idx, mask := range psbt.Masks {
+		x, err := parseScalar(mask)
+		if err != nil {
+			return err
+		}
+		var key mixinnet.Key
+		copy(key[:], edwards25519.NewScalar().Add(x, a).Bytes())
+		sig := key.Sign(h[:])
+		psbt.HolderSignatures[idx] = sig[:]
+	}
+	return nil
+}
+
+func (psbt *PartiallySignedTransaction) IsFullySigned() bool {
+	for i, sig := range psbt.Signatures {
+		if len(sig) != ed25519.SignatureSize {
+			return false
+		}
+		if len(psbt.HolderSignatures[i]) != ed25519.SignatureSize {
+			return false
+		}
+	}
+	return true
+}
+
+// the signatures are indexed by the position of the signing key in the input
+// UTXO keys, which follow the KernelAccountKeys order of the safe members, as
+// verified for all deposits and built for all changes to the safe address
+func (psbt *PartiallySignedTransaction) SignedTransaction(holder, signer, observer string) (string, error) {
+	if !psbt.IsFullySigned() {
+		return "", fmt.Errorf("transaction not fully signed %s", psbt.Hash())
+	}
+	hi, si := -1, -1
+	for i, k := range KernelAccountKeys(holder, signer, observer) {
+		switch k {
+		case holder:
+			hi = i
+		case signer:
+			si = i
+		}
+	}
+	if hi < 0 || si < 0 || hi == si {
+		return "", fmt.Errorf("invalid kernel account keys %s %s %s", holder, signer, observer)
+	}
+	tx := *psbt.Transaction
+	tx.Signatures = make([]map[uint16]*mixinnet.Signature, len(psbt.Signatures))
+	for i := range psbt.Signatures {
+		err := psbt.VerifySignature(holder, i, psbt.HolderSignatures[i])
+		if err != nil {
+			return "", err
+		}
+		err = psbt.VerifySignature(signer, i, psbt.Signatures[i])
+		if err != nil {
+			return "", err
+		}
+		var hs, ss mixinnet.Signature
+		copy(hs[:], psbt.HolderSignatures[i])
+		copy(ss[:], psbt.Signatures[i])
+		tx.Signatures[i] = map[uint16]*mixinnet.Signature{
+			uint16(hi): &hs,
+			uint16(si): &ss,
+		}
+	}
+	return tx.Dump()
+}
+
+func (psbt *PartiallySignedTransaction) Marshal() []byte {
+	raw, err := psbt.DumpPayload()
+	if err != nil {
+		panic(err)
+	}
+	enc := common.NewEncoder()
+	WriteBytes(enc, raw)
+	enc.WriteInt(len(psbt.Masks))
+	for i, m := range psbt.Masks {
+		WriteBytes(enc, m)
+		WriteBytes(enc, psbt.Signatures[i])
+		WriteBytes(enc, psbt.HolderSignatures[i])
+	}
+	return enc.Bytes()
+}
+
+func UnmarshalPartiallySignedTransaction(b []byte) (*PartiallySignedTransaction, error) {
+	dec := common.NewDecoder(b)
+	raw, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := mixinnet.TransactionFromData(raw)
+	if err != nil {
+		return nil, err
+	}
+	n, err := dec.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	if n != len(tx.Inputs) {
+		return nil, fmt.Errorf("invalid masks count %d %d", n, len(tx.Inputs))
+	}
+	psbt := &PartiallySignedTransaction{Transaction: tx}
+	for i := 0; i < n; i++ {
+		mask, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		sig, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		hs, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		psbt.Masks = append(psbt.Masks, mask)
+		psbt.Signatures = append(psbt.Signatures, sig)
+		psbt.HolderSignatures = append(psbt.HolderSignatures, hs)
+	}
+	return psbt, nil
+}
+
+func UnmarshalPartiallySignedTransactionHex(s string) (*PartiallySignedTransaction, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalPartiallySignedTransaction(b)
+}
+
+func CheckTransactionPartiallySignedBy(raw, public string) bool {
+	psbt, err := UnmarshalPartiallySignedTransactionHex(raw)
+	if err != nil {
+		return false
+	}
+	return psbt.VerifyHolderSignature(public) == nil
+}
+
+func parseScalar(b []byte) (*edwards25519.Scalar, error) {
+	if len(b) != 32 {
+		return nil, fmt.Errorf("invalid scalar %x", b)
+	}
+	return edwards25519.NewScalar().SetCanonicalBytes(b)
+}
+
+func edwards25519Add(B *edwards25519.Point, x *edwards25519.Scalar) mixinnet.Key {
+	X := edwards25519.NewIdentityPoint().ScalarBaseMult(x)
+	P := edwards25519.NewIdentityPoint().Add(B, X)
+	var key mixinnet.Key
+	copy(key[:], P.Bytes())
+	return key
+}
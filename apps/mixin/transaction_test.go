@@ -0,0 +1,151 @@
+package mixin
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const testMixinAssetId = "c94ac88f-4671-3976-b60a-09064f1811e8"
+
+func TestTransaction(t *testing.T) {
+	require := require.New(t)
+
+	holder, hk, _ := ed25519.GenerateKey(rand.Reader)
+	spend := mixinnet.GenerateKey(rand.Reader)
+	view := mixinnet.GenerateKey(rand.Reader)
+	signer, observer := spend.Public(), view.Public()
+	ka, err := BuildKernelAccount(hex.EncodeToString(holder), signer.String(), observer.String())
+	require.Nil(err)
+	require.True(strings.HasPrefix(ka.Address, "MIX"))
+	members, threshold, err := parseReceiver(ka.Address)
+	require.Nil(err)
+	require.Equal(uint8(KernelAccountThreshold), threshold)
+	require.Len(members, 3)
+	require.Equal(hex.EncodeToString(holder), hex.EncodeToString(members[0].PublicSpendKey[:]))
+	require.Equal(signer.String(), members[1].PublicSpendKey.String())
+	require.Equal(observer.String(), members[2].PublicSpendKey.String())
+	for _, m := range members {
+		require.Equal(observer.String(), m.PublicViewKey.String())
+	}
+	_, err = BuildKernelAccount(hex.EncodeToString(holder), signer.String(), "invalid")
+	require.NotNil(err)
+
+	extra := ka.Marshal()
+	ka2, err := UnmarshalKernelAccount(extra)
+	require.Nil(err)
+	require.Equal(ka, ka2)
+
+	keys := KernelAccountKeys(ka.Holder, ka.Signer, ka.Observer)
+	r := mixinnet.GenerateKey(rand.Reader)
+	R := r.Public()
+	var ghosts []string
+	for _, m := range members {
+		output := mixinnet.DeriveGhostPublicKey(mixinnet.TxVersion, &r, &m.PublicViewKey, &m.PublicSpendKey, 0)
+		ghosts = append(ghosts, output.String())
+	}
+	mask, ok := ViewOutputMask(keys, view, ghosts[:1], R.String(), 0)
+	require.False(ok)
+	require.Nil(mask)
+	mask, ok = ViewOutputMask(keys, view, []string{ghosts[1], ghosts[0], ghosts[2]}, R.String(), 0)
+	require.False(ok)
+	require.Nil(mask)
+	mask, ok = ViewOutputMask(keys, view, ghosts, R.String(), 0)
+	require.True(ok)
+	for i, k := range keys {
+		P, err := DeriveOutputKey(k, mask)
+		require.Nil(err)
+		require.Equal(ghosts[i], P.String())
+	}
+	output, err := ParseKey(ghosts[1])
+	require.Nil(err)
+
+	receiver := mixinnet.Address{
+		PublicSpendKey: mixinnet.GenerateKey(rand.Reader).Public(),
+		PublicViewKey:  mixinnet.GenerateKey(rand.Reader).Public(),
+	}
+	inputs := []*Input{{
+		TransactionHash: mixinnet.NewHash([]byte("deposit")).String(),
+		Index:           0,
+		Amount:          decimal.RequireFromString("1.5"),
+		Mask:            mask,
+	}}
+	outputs := []*TransactionOutput{{
+		Address: receiver.String(),
+		Amount:  decimal.RequireFromString("1.2"),
+	}}
+	rid := uuid.Must(uuid.NewV4()).String()
+	psbt, err := BuildPartiallySignedTransaction(testMixinAssetId, inputs, outputs, ka.Address, rid, []byte(rid))
+	require.Nil(err)
+	require.Len(psbt.Inputs, 1)
+	require.Len(psbt.Outputs, 2)
+	require.Equal("1.20000000", psbt.Outputs[0].Amount.String())
+	require.Equal("0.30000000", psbt.Outputs[1].Amount.String())
+	require.Len(psbt.Outputs[0].Keys, 1)
+	require.Equal("fffe01", psbt.Outputs[0].Script.String())
+	require.Len(psbt.Outputs[1].Keys, 3)
+	require.Equal(KernelAccountScript(), psbt.Outputs[1].Script.String())
+	require.Equal(mixinnet.Hash(sha256.Sum256([]byte(testMixinAssetId))), psbt.Asset)
+	require.False(psbt.IsFullySigned())
+
+	_, err = BuildPartiallySignedTransaction(testMixinAssetId, inputs, []*TransactionOutput{{
+		Address: receiver.String(),
+		Amount:  decimal.RequireFromString("1.6"),
+	}}, ka.Address, rid, []byte(rid))
+	require.NotNil(err)
+
+	h, err := psbt.TransactionHash()
+	require.Nil(err)
+	require.Equal(psbt.Hash(), h.String())
+	require.Equal(append(append([]byte{}, mask...), h[:]...), psbt.SigHash(0))
+
+	ghost := mixinnet.DeriveGhostPrivateKey(mixinnet.TxVersion, &R, &view, &spend, 0)
+	require.Equal(output.String(), ghost.Public().String())
+	sig := ghost.Sign(h[:])
+	require.Nil(psbt.VerifySignature(signer.String(), 0, sig[:]))
+	require.NotNil(psbt.VerifySignature(observer.String(), 0, sig[:]))
+	psbt.Signatures[0] = sig[:]
+	require.False(psbt.IsFullySigned())
+
+	require.NotNil(psbt.VerifyHolderSignature(hex.EncodeToString(holder)))
+	err = psbt.SignWithHolderKey(hk)
+	require.Nil(err)
+	require.Nil(psbt.VerifyHolderSignature(hex.EncodeToString(holder)))
+	require.True(psbt.IsFullySigned())
+	raw := hex.EncodeToString(psbt.Marshal())
+	require.True(CheckTransactionPartiallySignedBy(raw, hex.EncodeToString(holder)))
+	require.False(CheckTransactionPartiallySignedBy(raw, signer.String()))
+
+	psbt2, err := UnmarshalPartiallySignedTransactionHex(raw)
+	require.Nil(err)
+	require.Equal(psbt.Hash(), psbt2.Hash())
+	require.Equal(psbt.Masks, psbt2.Masks)
+	require.Equal(psbt.Signatures, psbt2.Signatures)
+	require.Equal(psbt.HolderSignatures, psbt2.HolderSignatures)
+
+	_, err = psbt2.SignedTransaction(signer.String(), hex.EncodeToString(holder), observer.String())
+	require.NotNil(err)
+	signed, err := psbt2.SignedTransaction(hex.EncodeToString(holder), signer.String(), observer.String())
+	require.Nil(err)
+	b, err := hex.DecodeString(signed)
+	require.Nil(err)
+	tx, err := mixinnet.TransactionFromData(b)
+	require.Nil(err)
+	th, err := tx.TransactionHash()
+	require.Nil(err)
+	require.Equal(psbt.Hash(), th.String())
+	require.Len(tx.Signatures, 1)
+	require.Len(tx.Signatures[0], 2)
+	require.True(output.Verify(h[:], *tx.Signatures[0][1]))
+	hout, err := ParseKey(ghosts[0])
+	require.Nil(err)
+	require.True(hout.Verify(h[:], *tx.Signatures[0][0]))
+}
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountProposal(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	receiver := "fcb87491-4fa0-4c2f-b387-262b63cbc112"
+	p := &AccountProposal{
+		Chain:     common.SafeChainBitcoin,
+		Holder:    "02bf0a7fa4b7905a0de5ab60a5322529e1a591ddd1ee53df82e751e8adb4bed08c",
+		Timelock:  24 * time.Hour,
+		Threshold: 1,
+		Receivers: []string{receiver},
+	}
+	id := uuid.Must(uuid.NewV4()).String()
+	op, err := p.Operation(id)
+	require.Nil(err)
+	require.Equal(common.ActionBitcoinSafeProposeAccount, int(op.Type))
+	require.Equal(common.CurveSecp256k1ECDSABitcoin, int(op.Curve))
+
+	req := &common.Request{Action: op.Type}
+	arp, err := req.ParseMixinRecipient(ctx, nil, op.Extra)
+	require.Nil(err)
+	require.Equal(24*time.Hour, arp.Timelock)
+	require.Equal(byte(1), arp.Threshold)
+	require.Equal([]string{receiver}, arp.Receivers)
+
+	p.Taproot = true
+	op, err = p.Operation(id)
+	require.Nil(err)
+	require.Equal(common.ActionBitcoinSafeProposeAccount, int(op.Type))
+	require.Equal(common.CurveSecp256k1SchnorrBitcoin, int(op.Curve))
+	require.Equal(p.Holder, op.Public)
+	p.Chain = common.SafeChainLitecoin
+	_, err = p.Operation(id)
+	require.NotNil(err)
+	p.Taproot = false
+
+	p.Chain = common.SafeChainPolygon
+	op, err = p.Operation(id)
+	require.Nil(err)
+	require.Equal(common.ActionEthereumSafeProposeAccount, int(op.Type))
+
+	p.Timelock = 90 * time.Minute
+	_, err = p.Operation(id)
+	require.NotNil(err)
+	p.Timelock = time.Hour
+	p.Threshold = 2
+	_, err = p.Operation(id)
+	require.NotNil(err)
+	p.Threshold = 1
+	p.Observer = "invalid"
+	_, err = p.Operation(id)
+	require.NotNil(err)
+	_, err = p.Operation("invalid")
+	require.NotNil(err)
+}
+
+func TestTransactionProposal(t *testing.T) {
+	require := require.New(t)
+
+	info := uuid.Must(uuid.NewV4())
+	hash := "8e3a2b8b3b4e6b4a6b2d5d1f6f1fa3d8e8d3ab4e5bd1c9f2cd7a0b6f6e8a1c3d"
+	p := &TransactionProposal{
+		Chain:         common.SafeChainBitcoin,
+		Flag:          common.FlagProposeNormalTransaction,
+		NetworkInfoId: info.String(),
+		Receiver:      "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e",
+	}
+	extra, err := p.Extra()
+	require.Nil(err)
+	require.Equal(byte(common.FlagProposeNormalTransaction), extra[0])
+	require.Equal(info.Bytes(), extra[1:17])
+	require.Equal(p.Receiver, string(extra[17:]))
+
+	p.Outpoints = []*Outpoint{{Hash: hash, Index: 3}}
+	extra, err = p.Extra()
+	require.Nil(err)
+	require.Equal(byte(common.FlagProposeNormalTransaction|common.FlagProposeCoinControl), extra[0])
+	require.Equal(byte(1), extra[17])
+	require.Equal(hash, hex.EncodeToString(extra[18:50]))
+	require.Equal(uint32(3), binary.BigEndian.Uint32(extra[50:54]))
+	require.Equal(p.Receiver, string(extra[54:]))
+
+	p.Flag = common.FlagProposeRecoveryTransaction
+	_, err = p.Extra()
+	require.NotNil(err)
+	p.Flag = common.FlagProposeBatchTransaction
+	_, err = p.Extra()
+	require.NotNil(err)
+	p.Receiver = ""
+	p.Reference = crypto.Keccak256([]byte("recipients"))
+	extra, err = p.Extra()
+	require.Nil(err)
+	require.Equal(p.Reference, extra[54:])
+
+	p.Chain = common.SafeChainEthereum
+	_, err = p.Extra()
+	require.NotNil(err)
+	p.Outpoints = nil
+	op, err := p.Operation(info.String())
+	require.Nil(err)
+	require.Equal(common.ActionEthereumSafeProposeTransaction, int(op.Type))
+	require.Equal(common.CurveSecp256k1ECDSAEthereum, int(op.Curve))
+}
+
+func TestSignatures(t *testing.T) {
+	require := require.New(t)
+
+	bk, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	pub := hex.EncodeToString(bk.PubKey().SerializeCompressed())
+	msg := ApproveAccountMessage("a6a5e3b5-6b3c-3f42-8e23-b66fba3ba0a0", "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e")
+	sig, err := base64.RawURLEncoding.DecodeString(SignBitcoinMessage(bk, msg, common.SafeChainBitcoin))
+	require.Nil(err)
+	hash := bitcoin.HashMessageForSignature(msg, common.SafeChainBitcoin)
+	require.Nil(bitcoin.VerifySignatureDER(pub, hash, sig))
+	hash = bitcoin.HashMessageForSignature(msg, common.SafeChainLitecoin)
+	require.NotNil(bitcoin.VerifySignatureDER(pub, hash, sig))
+
+	for _, taproot := range []bool{false, true} {
+		raw := testBitcoinTransaction(require, pub, taproot)
+		signed, nonces, err := SignBitcoinTransaction(bk, raw)
+		require.Nil(err)
+		require.True(bitcoin.CheckTransactionPartiallySignedBy(signed, pub))
+		rb, _ := hex.DecodeString(signed)
+		pst, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+		require.Nil(err)
+		for idx := range pst.Inputs {
+			require.Equal(taproot, pst.IsTaprootInput(idx))
+			require.Equal(taproot, pst.IsTaprootKeySpendInput(idx))
+			sig := pst.InputSignature(idx, pub)
+			require.Nil(pst.VerifyInputSignature(idx, pub, sig))
+		}
+		if !taproot {
+			require.Len(nonces, 0)
+			continue
+		}
+		require.Len(nonces, len(pst.Inputs))
+		_, err = FinishBitcoinTransaction(bk, signed, nonces)
+		require.NotNil(err)
+		_, err = FinishBitcoinTransaction(bk, signed, nil)
+		require.NotNil(err)
+	}
+
+	ek, err := crypto.GenerateKey()
+	require.Nil(err)
+	pub = hex.EncodeToString(crypto.CompressPubkey(&ek.PublicKey))
+	sig, err = SignEthereumMessage(ek, []byte(msg))
+	require.Nil(err)
+	require.Nil(ethereum.VerifyMessageSignature(pub, []byte(msg), sig))
+	require.NotNil(ethereum.VerifyMessageSignature(pub, []byte("REVOKE"), sig))
+}
+
+func testBitcoinTransaction(require *require.Assertions, holder string, taproot bool) string {
+	sk, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	ok, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	signer := hex.EncodeToString(sk.PubKey().SerializeCompressed())
+	observer := hex.EncodeToString(ok.PubKey().SerializeCompressed())
+	build := bitcoin.BuildWitnessScriptAccount
+	if taproot {
+		build = bitcoin.BuildTaprootAccount
+	}
+	wsa, err := build(holder, signer, observer, 24*time.Hour, common.SafeChainBitcoin)
+	require.Nil(err)
+
+	inputs := []*bitcoin.Input{{
+		TransactionHash: "9f3ec2b3d5b1ac1fdc1d5d3ba5c3a2b1b1f5b6c1e5a0d9b5c3f2a1e0d9c8b7a6",
+		Index:           1,
+		Satoshi:         100000,
+		Script:          wsa.Script,
+		Sequence:        wsa.Sequence,
+	}}
+	outputs := []*bitcoin.Output{{Address: "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e", Satoshi: 60000}}
+	pst, err := bitcoin.BuildPartiallySignedTransaction(inputs, outputs, []byte("client"), common.SafeChainBitcoin)
+	require.Nil(err)
+	return hex.EncodeToString(pst.Marshal())
+}
+
+func TestObserver(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if r.Method == http.MethodPost {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+		}
+		switch r.URL.Path {
+		case "/accounts/a6a5e3b5-6b3c-3f42-8e23-b66fba3ba0a0":
+			_, _ = w.Write([]byte(`{"chain":1,"id":"a6a5e3b5-6b3c-3f42-8e23-b66fba3ba0a0","address":"bc1q","keys":{"holder":"02"},"state":"pending"}`))
+		case "/transactions/b0b8d6a8-2a42-3fbb-9e29-2e4e5de83b6e":
+			_, _ = w.Write([]byte(`{"chain":1,"id":"b0b8d6a8-2a42-3fbb-9e29-2e4e5de83b6e","hash":"8e3a","raw":"70","state":"initial"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"404"}`))
+		}
+	}))
+	defer server.Close()
+
+	o := NewObserver(server.URL + "/")
+	account, err := o.GetAccount(ctx, "a6a5e3b5-6b3c-3f42-8e23-b66fba3ba0a0")
+	require.Nil(err)
+	require.Equal("bc1q", account.Address)
+	require.Equal("pending", account.State)
+	require.JSONEq(`{"holder":"02"}`, string(account.Keys))
+	require.Nil(body)
+
+	_, err = o.ApproveAccount(ctx, "a6a5e3b5-6b3c-3f42-8e23-b66fba3ba0a0", "bc1q", "c2ln")
+	require.Nil(err)
+	require.Equal(map[string]any{"action": "approve", "address": "bc1q", "signature": "c2ln"}, body)
+
+	tx, err := o.RevokeTransaction(ctx, "b0b8d6a8-2a42-3fbb-9e29-2e4e5de83b6e", common.SafeChainBitcoin, "c2ln")
+	require.Nil(err)
+	require.Equal("8e3a", tx.Hash)
+	require.Equal(map[string]any{"action": "revoke", "chain": float64(1), "signature": "c2ln"}, body)
+
+	_, err = o.GetRecovery(ctx, "bc1q")
+	require.NotNil(err)
+	oe, ok := err.(*Error)
+	require.True(ok)
+	require.Equal(http.StatusNotFound, oe.Status)
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Observer is the client of the observer HTTP API, all holder requests
+// other than the proposals are signed by the holder and sent through it.
+type Observer struct {
+	endpoint string
+	client   *http.Client
+}
+
+type Account struct {
+	Chain       byte            `json:"chain"`
+	Id          string          `json:"id"`
+	Address     string          `json:"address"`
+	Keys        json.RawMessage `json:"keys"`
+	SafeAssetId string          `json:"safe_asset_id"`
+	State       string          `json:"state"`
+}
+
+type Transaction struct {
+	Chain byte   `json:"chain"`
+	Id    string `json:"id"`
+	Hash  string `json:"hash"`
+	Raw   string `json:"raw"`
+	State string `json:"state"`
+}
+
+type Recovery struct {
+	Address string `json:"address"`
+	Chain   byte   `json:"chain"`
+	Holder  string `json:"holder"`
+	Raw     string `json:"raw"`
+	Hash    string `json:"hash"`
+	State   string `json:"state"`
+}
+
+type Batch struct {
+	Id           string         `json:"id"`
+	Chain        byte           `json:"chain"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// Error is returned for all responses not in the 2xx range.
+type Error struct {
+	Status int
+	Body   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("observer %d %s", e.Status, e.Body)
+}
+
+func NewObserver(endpoint string) *Observer {
+	return &Observer{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (o *Observer) GetAccount(ctx context.Context, id string) (*Account, error) {
+	var a Account
+	err := o.request(ctx, http.MethodGet, "/accounts/"+url.PathEscape(id), nil, &a)
+	return &a, err
+}
+
+// ApproveAccount sends the holder signature of ApproveAccountMessage.
+func (o *Observer) ApproveAccount(ctx context.Context, id, address, signature string) (*Account, error) {
+	return o.postAccount(ctx, id, map[string]any{
+		"action":    "approve",
+		"address":   address,
+		"signature": signature,
+	})
+}
+
+// CloseAccount sends the recovery transaction signed by the holder, to close
+// the safe without the observer signature after the timelock.
+func (o *Observer) CloseAccount(ctx context.Context, id, address, raw, hash string) (*Account, error) {
+	return o.postAccount(ctx, id, map[string]any{
+		"action":  "close",
+		"address": address,
+		"raw":     raw,
+		"hash":    hash,
+	})
+}
+
+// ConsolidateAccount sends the holder signature of ConsolidateAccountMessage,
+// where the hash is the network info id to estimate the fee.
+func (o *Observer) ConsolidateAccount(ctx context.Context, id, address, networkInfoId, signature string) (*Account, error) {
+	return o.postAccount(ctx, id, map[string]any{
+		"action":    "consolidate",
+		"address":   address,
+		"hash":      networkInfoId,
+		"signature": signature,
+	})
+}
+
+func (o *Observer) GetTransaction(ctx context.Context, id string) (*Transaction, error) {
+	var t Transaction
+	err := o.request(ctx, http.MethodGet, "/transactions/"+url.PathEscape(id), nil, &t)
+	return &t, err
+}
+
+// ApproveTransaction sends the transaction signed by the holder, i.e. the
+// result of SignBitcoinTransaction or SignEthereumTransaction. The taproot
+// transaction must be signed again with SignTransaction after the keeper signs.
+func (o *Observer) ApproveTransaction(ctx context.Context, id string, chain byte, raw string) (*Transaction, error) {
+	var t Transaction
+	err := o.request(ctx, http.MethodPost, "/transactions/"+url.PathEscape(id), map[string]any{
+		"action": "approve",
+		"chain":  chain,
+		"raw":    raw,
+	}, &t)
+	return &t, err
+}
+
+// SignTransaction sends the result of FinishBitcoinTransaction, after the
+// taproot transaction approved by ApproveTransaction is signed by the keeper.
+func (o *Observer) SignTransaction(ctx context.Context, id string, chain byte, raw string) (*Transaction, error) {
+	var t Transaction
+	err := o.request(ctx, http.MethodPost, "/transactions/"+url.PathEscape(id), map[string]any{
+		"action": "sign",
+		"chain":  chain,
+		"raw":    raw,
+	}, &t)
+	return &t, err
+}
+
+// RevokeTransaction sends the holder signature of RevokeTransactionMessage.
+func (o *Observer) RevokeTransaction(ctx context.Context, id string, chain byte, signature string) (*Transaction, error) {
+	var t Transaction
+	err := o.request(ctx, http.MethodPost, "/transactions/"+url.PathEscape(id), map[string]any{
+		"action":    "revoke",
+		"chain":     chain,
+		"signature": signature,
+	}, &t)
+	return &t, err
+}
+
+func (o *Observer) GetBatch(ctx context.Context, id string) (*Batch, error) {
+	var b Batch
+	err := o.request(ctx, http.MethodGet, "/batches/"+url.PathEscape(id), nil, &b)
+	return &b, err
+}
+
+// ApproveBatch sends all transactions of the batch signed by the holder, and
+// the signature of SignBitcoinBatch.
+func (o *Observer) ApproveBatch(ctx context.Context, id string, chain byte, raws []string, signature string) (*Batch, error) {
+	var b Batch
+	err := o.request(ctx, http.MethodPost, "/batches/"+url.PathEscape(id), map[string]any{
+		"chain":     chain,
+		"raws":      raws,
+		"signature": signature,
+	}, &b)
+	return &b, err
+}
+
+func (o *Observer) GetRecovery(ctx context.Context, address string) (*Recovery, error) {
+	var r Recovery
+	err := o.request(ctx, http.MethodGet, "/recoveries/"+url.PathEscape(address), nil, &r)
+	return &r, err
+}
+
+// SignRecovery sends the recovery transaction signed by the holder, for the
+// recovery initialized by the observer with the owner.
+func (o *Observer) SignRecovery(ctx context.Context, address, raw, hash string) (*Account, error) {
+	var a Account
+	err := o.request(ctx, http.MethodPost, "/recoveries/"+url.PathEscape(address), map[string]any{
+		"raw":  raw,
+		"hash": hash,
+	}, &a)
+	return &a, err
+}
+
+// SetSpendingPolicy sends the policy JSON and the holder signature of the
+// SpendingPolicyMessage, the id is the keeper request id of the observer MTG
+// transaction, so the signature is bound to that request.
+func (o *Observer) SetSpendingPolicy(ctx context.Context, address, id, policy, signature string) error {
+	return o.request(ctx, http.MethodPost, "/policies/"+url.PathEscape(address), map[string]any{
+		"id":        id,
+		"policy":    policy,
+		"signature": signature,
+	}, nil)
+}
+
+func (o *Observer) postAccount(ctx context.Context, id string, body map[string]any) (*Account, error) {
+	var a Account
+	err := o.request(ctx, http.MethodPost, "/accounts/"+url.PathEscape(id), body, &a)
+	return &a, err
+}
+
+func (o *Observer) request(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, o.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &Error{Status: resp.StatusCode, Body: string(b)}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+)
+
+// Outpoint selects a safe UTXO to spend with the coin control flag, the hash
+// is the transaction hash in the usual hex display order.
+type Outpoint struct {
+	Hash  string
+	Index uint32
+}
+
+// AccountProposal is the holder request to create a safe account, the bond
+// and fee are paid with the account price asset set by the observer. The
+// Taproot flag proposes a bitcoin taproot safe instead of the witness script.
+type AccountProposal struct {
+	Chain     byte
+	Holder    string
+	Timelock  time.Duration
+	Threshold byte
+	Receivers []string
+	Observer  string
+	Taproot   bool
+}
+
+// TransactionProposal is the holder request to spend from the safe, paid with
+// the safe bond asset of the amount to send. Either a single Receiver or the
+// Reference of a storage transaction with the encoded recipients is required.
+type TransactionProposal struct {
+	Chain         byte
+	Holder        string
+	Flag          byte
+	NetworkInfoId string
+	Receiver      string
+	Reference     []byte
+	Outpoints     []*Outpoint
+}
+
+// Operation builds the operation sent to the keeper in the payment memo.
+func (p *AccountProposal) Operation(id string) (*common.Operation, error) {
+	action, err := holderAction(p.Chain, common.ActionBitcoinSafeProposeAccount)
+	if err != nil {
+		return nil, err
+	}
+	curve := common.SafeChainCurve(p.Chain)
+	if p.Taproot {
+		if p.Chain != common.SafeChainBitcoin {
+			return nil, fmt.Errorf("taproot on chain %d", p.Chain)
+		}
+		err = bitcoin.VerifyHolderKey(p.Holder)
+		if err != nil {
+			return nil, err
+		}
+		curve = common.CurveSecp256k1SchnorrBitcoin
+	}
+	extra, err := p.Extra()
+	if err != nil {
+		return nil, err
+	}
+	return buildOperation(id, curve, action, p.Holder, extra)
+}
+
+// Extra encodes the timelock in hours, the threshold and the receivers of the
+// bond, and the optional custom observer key at last.
+func (p *AccountProposal) Extra() ([]byte, error) {
+	if p.Timelock%time.Hour != 0 || p.Timelock < 0 || p.Timelock/time.Hour > 65535 {
+		return nil, fmt.Errorf("invalid timelock %s", p.Timelock)
+	}
+	total := len(p.Receivers)
+	if total == 0 || total > 255 || int(p.Threshold) > total || p.Threshold == 0 {
+		return nil, fmt.Errorf("invalid receivers %d/%d", p.Threshold, total)
+	}
+	extra := binary.BigEndian.AppendUint16(nil, uint16(p.Timelock/time.Hour))
+	extra = append(extra, p.Threshold, byte(total))
+	for _, r := range p.Receivers {
+		uid, err := uuid.FromString(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid receiver %s", r)
+		}
+		extra = append(extra, uid.Bytes()...)
+	}
+	if p.Observer == "" {
+		return extra, nil
+	}
+
+	var err error
+	switch common.SafeChainFamily(p.Chain) {
+	case common.SafeChainBitcoin:
+		err = bitcoin.VerifyHolderKey(p.Observer)
+	case common.SafeChainEthereum:
+		err = ethereum.VerifyHolderKey(p.Observer)
+	default:
+		err = fmt.Errorf("custom observer on chain %d", p.Chain)
+	}
+	if err != nil {
+		return nil, err
+	}
+	observer, err := hex.DecodeString(p.Observer)
+	if err != nil {
+		return nil, err
+	}
+	return append(extra, observer...), nil
+}
+
+// Operation builds the operation sent to the keeper in the payment memo.
+func (p *TransactionProposal) Operation(id string) (*common.Operation, error) {
+	action, err := holderAction(p.Chain, common.ActionBitcoinSafeProposeTransaction)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := p.Extra()
+	if err != nil {
+		return nil, err
+	}
+	return buildOperation(id, common.SafeChainCurve(p.Chain), action, p.Holder, extra)
+}
+
+// Extra encodes the flag, the network info id to estimate the fee, the
+// optional coin control outpoints and then the receiver or the reference.
+func (p *TransactionProposal) Extra() ([]byte, error) {
+	switch p.Flag {
+	case common.FlagProposeNormalTransaction:
+	case common.FlagProposeRecoveryTransaction:
+	case common.FlagProposeBatchTransaction:
+	default:
+		return nil, fmt.Errorf("invalid flag %d", p.Flag)
+	}
+	iid, err := uuid.FromString(p.NetworkInfoId)
+	if err != nil || iid == uuid.Nil {
+		return nil, fmt.Errorf("invalid network info %s", p.NetworkInfoId)
+	}
+
+	flag := p.Flag
+	if len(p.Outpoints) > 0 {
+		if common.SafeChainFamily(p.Chain) != common.SafeChainBitcoin {
+			return nil, fmt.Errorf("coin control on chain %d", p.Chain)
+		}
+		if p.Flag == common.FlagProposeRecoveryTransaction || len(p.Outpoints) > 255 {
+			return nil, fmt.Errorf("invalid outpoints %d", len(p.Outpoints))
+		}
+		flag = flag | common.FlagProposeCoinControl
+	}
+	extra := []byte{flag}
+	extra = append(extra, iid.Bytes()...)
+	if len(p.Outpoints) > 0 {
+		extra = append(extra, byte(len(p.Outpoints)))
+		for _, op := range p.Outpoints {
+			hash, err := hex.DecodeString(op.Hash)
+			if err != nil || len(hash) != 32 {
+				return nil, fmt.Errorf("invalid outpoint %s", op.Hash)
+			}
+			extra = append(extra, hash...)
+			extra = binary.BigEndian.AppendUint32(extra, op.Index)
+		}
+	}
+
+	switch {
+	case len(p.Reference) == 32 && p.Receiver == "":
+		return append(extra, p.Reference...), nil
+	case len(p.Reference) == 0 && p.Receiver != "":
+		if p.Flag == common.FlagProposeBatchTransaction {
+			return nil, fmt.Errorf("batch without recipients reference")
+		}
+		return append(extra, []byte(p.Receiver)...), nil
+	}
+	return nil, fmt.Errorf("invalid receiver %s or reference %x", p.Receiver, p.Reference)
+}
+
+// EncodeMemo encodes the operation as the memo of the payment to the keeper.
+func EncodeMemo(keeperAppId string, op *common.Operation) string {
+	return mtg.EncodeMixinExtraBase64(keeperAppId, op.Encode())
+}
+
+// holderAction maps the bitcoin action to the same action of the chain
+// family, the actions of all families share the same order.
+func holderAction(chain, action byte) (byte, error) {
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
+		return action, nil
+	case common.SafeChainMixin:
+		return action + common.ActionMixinSafeProposeAccount - common.ActionBitcoinSafeProposeAccount, nil
+	case common.SafeChainEthereum:
+		return action + common.ActionEthereumSafeProposeAccount - common.ActionBitcoinSafeProposeAccount, nil
+	}
+	return 0, fmt.Errorf("invalid chain %d", chain)
+}
+
+func buildOperation(id string, curve, action byte, holder string, extra []byte) (*common.Operation, error) {
+	uid, err := uuid.FromString(id)
+	if err != nil || uid.String() != id {
+		return nil, fmt.Errorf("invalid operation id %s", id)
+	}
+	return &common.Operation{
+		Id:     id,
+		Type:   action,
+		Curve:  curve,
+		Public: holder,
+		Extra:  extra,
+	}, nil
+}
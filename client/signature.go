@@ -0,0 +1,158 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/btcsuite/btcd/btcec/v2"
+	becdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The messages signed by the holder for the observer requests, they must be
+// the same as those verified by the observer and the keeper.
+
+func ApproveAccountMessage(requestId, address string) string {
+	return fmt.Sprintf("APPROVE:%s:%s", requestId, address)
+}
+
+func RevokeTransactionMessage(requestId, transactionHash string) string {
+	return fmt.Sprintf("REVOKE:%s:%s", requestId, transactionHash)
+}
+
+func ConsolidateAccountMessage(networkInfoId, address string) string {
+	return fmt.Sprintf("CONSOLIDATE:%s:%s", networkInfoId, address)
+}
+
+func SpendingPolicyMessage(id, policy string) string {
+	return fmt.Sprintf("POLICY:%s:%x", id, sha256.Sum256([]byte(policy)))
+}
+
+// SignBitcoinMessage returns the base64 DER signature of the message in the
+// format accepted by the observer HTTP API.
+func SignBitcoinMessage(key *btcec.PrivateKey, msg string, chain byte) string {
+	hash := bitcoin.HashMessageForSignature(msg, chain)
+	sig := becdsa.Sign(key, hash).Serialize()
+	return base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// SignBitcoinBatch returns the signature to approve all transactions of the
+// batch proposed by the request id, in the order listed by the observer.
+func SignBitcoinBatch(key *btcec.PrivateKey, id string, hashes []string, chain byte) string {
+	hash := bitcoin.HashBatchForSignature(id, hashes, chain)
+	sig := becdsa.Sign(key, hash).Serialize()
+	return base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// SignBitcoinTransaction adds the holder partial signature to all inputs of
+// the PSBT proposed by the keeper and returns the hex of the signed PSBT. The
+// taproot key path inputs are approved with the holder MuSig2 nonces instead,
+// and the secret nonces returned must be kept for FinishBitcoinTransaction.
+func SignBitcoinTransaction(key *btcec.PrivateKey, raw string) (string, map[int][musig2.SecNonceSize]byte, error) {
+	rb, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	pst, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+	if err != nil {
+		return "", nil, err
+	}
+	holder := hex.EncodeToString(key.PubKey().SerializeCompressed())
+	nonces := make(map[int][musig2.SecNonceSize]byte)
+	for idx := range pst.UnsignedTx.TxIn {
+		if pst.IsTaprootKeySpendInput(idx) {
+			nonce, err := pst.ApproveTaprootKeySpendInput(idx, key)
+			if err != nil {
+				return "", nil, err
+			}
+			nonces[idx] = nonce
+			continue
+		}
+		hash := pst.SigHash(idx)
+		if !pst.IsTaprootInput(idx) {
+			sig := becdsa.Sign(key, hash).Serialize()
+			pst.SetInputSignature(idx, holder, sig)
+			continue
+		}
+		sig, err := schnorr.Sign(key, hash)
+		if err != nil {
+			return "", nil, err
+		}
+		pst.SetInputSignature(idx, holder, sig.Serialize())
+	}
+	return hex.EncodeToString(pst.Marshal()), nonces, nil
+}
+
+// FinishBitcoinTransaction combines the holder and signer partial signatures
+// of the taproot key path inputs, after the keeper signed the transaction,
+// and returns the hex of the PSBT for the observer to broadcast.
+func FinishBitcoinTransaction(key *btcec.PrivateKey, raw string, nonces map[int][musig2.SecNonceSize]byte) (string, error) {
+	rb, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", err
+	}
+	pst, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+	if err != nil {
+		return "", err
+	}
+	for idx := range pst.UnsignedTx.TxIn {
+		if !pst.IsTaprootKeySpendInput(idx) {
+			continue
+		}
+		nonce, found := nonces[idx]
+		if !found {
+			return "", fmt.Errorf("taproot secret nonce not found %d", idx)
+		}
+		err = pst.SignTaprootKeySpendInput(idx, key, nonce)
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(pst.Marshal()), nil
+}
+
+// SignEthereumMessage returns the signature of the message with the prefix
+// of personal messages, and the v adjusted as required by the Gnosis Safe.
+func SignEthereumMessage(key *ecdsa.PrivateKey, msg []byte) ([]byte, error) {
+	hash := ethereum.HashMessageForSignature(hex.EncodeToString(msg))
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, err
+	}
+	return ethereum.ProcessSignature(sig), nil
+}
+
+// SignEthereumTransaction puts the holder signature of the Gnosis Safe
+// transaction to the holder position of the sorted safe owners, and returns
+// the hex of the signed transaction.
+func SignEthereumTransaction(key *ecdsa.PrivateKey, raw, signer, observer string) (string, error) {
+	rb, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", err
+	}
+	st, err := ethereum.UnmarshalSafeTransaction(rb)
+	if err != nil {
+		return "", err
+	}
+	holder := hex.EncodeToString(crypto.CompressPubkey(&key.PublicKey))
+	_, pubs := ethereum.GetSortedSafeOwners(holder, signer, observer)
+	for i, pub := range pubs {
+		if pub != holder {
+			continue
+		}
+		sig, err := SignEthereumMessage(key, st.Message)
+		if err != nil {
+			return "", err
+		}
+		st.Signatures[i] = sig
+		return hex.EncodeToString(st.Marshal()), nil
+	}
+	return "", fmt.Errorf("holder %s not in owners", holder)
+}
@@ -9,10 +9,10 @@ import (
 	"os"
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/client"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/config"
 	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/fox-one/mixin-sdk-go/v2"
 	"github.com/gofrs/uuid/v5"
 	"github.com/mdp/qrterminal"
@@ -28,14 +28,12 @@ func GenerateTestSafeApproval(c *cli.Context) error {
 		return fmt.Errorf("invalid chain %d", chain)
 	}
 
-	hash := bitcoin.HashMessageForSignature(c.String("address"), byte(chain))
 	kb, err := hex.DecodeString(c.String("key"))
 	if err != nil {
 		return err
 	}
 	private, _ := btcec.PrivKeyFromBytes(kb)
-	sig := ecdsa.Sign(private, hash)
-	fmt.Println(base64.RawURLEncoding.EncodeToString(sig.Serialize()))
+	fmt.Println(client.SignBitcoinMessage(private, c.String("address"), byte(chain)))
 	return nil
 }
 
@@ -55,17 +53,21 @@ func GenerateTestSafeProposal(c *cli.Context) error {
 	private, _ := btcec.PrivKeyFromBytes(seed)
 	holder := testPublicKey(hex.EncodeToString(private.Serialize()))
 
-	threshold := byte(1)
-	total := byte(1)
-	receivers := []string{"fcb87491-4fa0-4c2f-b387-262b63cbc112"}
-	extra := []byte{threshold, total}
-	uid := uuid.Must(uuid.FromString(receivers[0]))
-	extra = append(extra, uid.Bytes()...)
-
+	proposal := &client.AccountProposal{
+		Chain:     byte(chain),
+		Holder:    holder,
+		Timelock:  bitcoin.TimeLockMinimum,
+		Threshold: 1,
+		Receivers: []string{"fcb87491-4fa0-4c2f-b387-262b63cbc112"},
+	}
 	sid := uuid.Must(uuid.NewV4()).String()
+	op, err := proposal.Operation(sid)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("session: %s\npublic: %s\nprivate: %x\n", sid, holder, private.Serialize())
 
-	memo := testBuildHolderRequest(sid, holder, common.ActionBitcoinSafeProposeAccount, extra)
+	memo := testBuildHolderRequest(sid, holder, op.Type, op.Extra)
 	amount := decimal.NewFromFloat(1)
 	assetId := "31d2ea9c-95eb-3355-b65b-ba096853bc18"
 	return makeKeeperPaymentRequest(c.String("config"), assetId, amount, sid, memo)
@@ -9,6 +9,7 @@ import (
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/config"
 	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeperapi"
 	"github.com/MixinNetwork/trusted-group/mtg"
 	"github.com/fox-one/mixin-sdk-go/v2"
 	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
@@ -141,6 +142,9 @@ func KeeperBootCmd(c *cli.Context) error {
 	if mmc := mc.Keeper.MonitorConversaionId; mmc != "" {
 		go MonitorKeeper(ctx, db, kd, mc.Keeper, group, mmc, version)
 	}
+	if mc.Keeper.MetricsListen != "" {
+		go ServeKeeperMetrics(ctx, mc.Keeper.StoreDir+"/mtg.sqlite3", kd, mc.Keeper)
+	}
 
 	group.AttachWorker(mc.Keeper.AppId, keeper)
 	group.RegisterDepositEntry(mc.Keeper.AppId, mtg.DepositEntry{
@@ -164,3 +168,17 @@ func KeeperFundRequest(c *cli.Context) error {
 	traceId := uuid.Must(uuid.NewV4()).String()
 	return makeKeeperPaymentRequest(c.String("config"), assetId, amount, traceId, "")
 }
+
+func KeeperAPIBootCmd(c *cli.Context) error {
+	mc, err := config.ReadConfiguration(c.String("config"), "keeper")
+	if err != nil {
+		return err
+	}
+	kd, err := keeper.OpenSQLite3ReadOnlyStore(mc.Keeper.StoreDir + "/safe.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+
+	return keeperapi.StartHTTP(kd, c.String("listen"))
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	kstore "github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/fox-one/mixin-sdk-go/v2"
+)
+
+func ServeSignerMetrics(ctx context.Context, mtgPath string, store *signer.SQLite3Store, conf *signer.Configuration) {
+	mdb, err := common.OpenSQLite3ReadOnlyStore(mtgPath)
+	if err != nil {
+		panic(err)
+	}
+	defer mdb.Close()
+
+	err = common.ServeMetrics(ctx, conf.MetricsListen, func(ctx context.Context) (*common.Metrics, error) {
+		m := common.NewMetrics()
+		err := collectMTGMetrics(ctx, m, "signer", mdb, conf.AppId, conf.KeeperAssetId)
+		if err != nil {
+			return nil, err
+		}
+
+		ss, err := store.SessionsState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for state, count := range map[int]int{
+			common.RequestStateInitial: ss.Initial,
+			common.RequestStatePending: ss.Pending,
+			common.RequestStateDone:    ss.Done,
+		} {
+			m.Gauge("safe_signer_sessions", "The signer sessions by state", float64(count), "state", common.StateName(state))
+		}
+		m.Gauge("safe_signer_keys", "The generated keys", float64(ss.Keys))
+		ubc, err := store.CountUnbackupedKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.Gauge("safe_signer_keys_unbackuped", "The generated keys not backed up to the saver", float64(ubc))
+		return m, nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func ServeKeeperMetrics(ctx context.Context, mtgPath string, store *kstore.SQLite3Store, conf *keeper.Configuration) {
+	mdb, err := common.OpenSQLite3ReadOnlyStore(mtgPath)
+	if err != nil {
+		panic(err)
+	}
+	defer mdb.Close()
+
+	err = common.ServeMetrics(ctx, conf.MetricsListen, func(ctx context.Context) (*common.Metrics, error) {
+		m := common.NewMetrics()
+		err := collectMTGMetrics(ctx, m, "keeper", mdb, conf.AppId, conf.AssetId)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := store.ReadLatestRequest(ctx)
+		if err != nil {
+			return nil, err
+		} else if req != nil {
+			m.Gauge("safe_keeper_latest_request_sequence", "The sequence of the latest request", float64(req.Sequence))
+		}
+		req, err = store.ReadPendingRequest(ctx)
+		if err != nil {
+			return nil, err
+		} else if req != nil {
+			m.Gauge("safe_keeper_pending_request_age_seconds", "The age of the oldest initial request", time.Since(req.CreatedAt).Seconds())
+		}
+
+		chains := []byte{common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash}
+		for _, ec := range ethereum.ListChains() {
+			chains = append(chains, ec.Chain)
+		}
+		for _, c := range chains {
+			info, err := store.ReadLatestNetworkInfo(ctx, c, time.Now())
+			if err != nil {
+				return nil, err
+			} else if info != nil {
+				m.Gauge("safe_keeper_network_height", "The latest block height from the observer", float64(info.Height), "chain", fmt.Sprint(c))
+			}
+		}
+
+		for _, curve := range []byte{
+			common.CurveSecp256k1ECDSABitcoin,
+			common.CurveSecp256k1SchnorrBitcoin,
+			common.CurveSecp256k1ECDSAEthereum,
+			common.CurveEdwards25519Mixin,
+		} {
+			for role, name := range map[int]string{common.RequestRoleSigner: "signer", common.RequestRoleObserver: "observer"} {
+				count, err := store.CountSpareKeys(ctx, curve, common.RequestFlagNone, role)
+				if err != nil {
+					return nil, err
+				}
+				m.Gauge("safe_keeper_spare_keys", "The spare keys by curve and role", float64(count), "curve", fmt.Sprint(curve), "role", name)
+			}
+		}
+
+		for _, state := range []int{common.RequestStateInitial, common.RequestStatePending, common.RequestStateDone, common.RequestStateFailed} {
+			count, err := store.CountTransactionsByState(ctx, byte(state))
+			if err != nil {
+				return nil, err
+			}
+			m.Gauge("safe_keeper_transactions", "The safe transactions by state", float64(count), "state", common.StateName(state))
+		}
+		return m, nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// the MTG store has no count methods, so the counts are queried from a read
+// only connection to its database
+func collectMTGMetrics(ctx context.Context, m *common.Metrics, role string, mdb *sql.DB, appId, assetId string) error {
+	name := fmt.Sprintf("safe_%s_mtg_transactions", role)
+	for state, label := range map[int]string{
+		mtg.TransactionStateInitial:  "initial",
+		mtg.TransactionStateSigned:   "signed",
+		mtg.TransactionStateSnapshot: "snapshot",
+	} {
+		count, err := countMTGRows(ctx, mdb, "SELECT COUNT(*) FROM transactions WHERE state=?", state)
+		if err != nil {
+			return err
+		}
+		m.Gauge(name, "The MTG transactions by state", float64(count), "state", label)
+	}
+	query := "SELECT COUNT(*) FROM outputs WHERE app_id=? AND asset_id=? AND state=?"
+	count, err := countMTGRows(ctx, mdb, query, appId, assetId, mixin.UTXOStateUnspent)
+	if err != nil {
+		return err
+	}
+	m.Gauge(fmt.Sprintf("safe_%s_mtg_outputs", role), "The unspent MTG outputs of the asset", float64(count), "asset", assetId)
+	return nil
+}
+
+func countMTGRows(ctx context.Context, mdb *sql.DB, query string, params ...any) (int, error) {
+	var count int
+	row := mdb.QueryRowContext(ctx, query, params...)
+	err := row.Scan(&count)
+	return count, err
+}
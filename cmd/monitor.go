@@ -103,6 +103,14 @@ func bundleSignerState(ctx context.Context, mdb *mtg.SQLite3Store, store *signer
 	state = state + fmt.Sprintf("🔑 Final sessions: %d\n", ss.Done)
 	state = state + fmt.Sprintf("🔑 Generated keys: %d\n", ss.Keys)
 
+	reputations, err := store.ListMemberReputations(ctx, conf.Threshold+1)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range grp.GetMembers() {
+		state = state + fmt.Sprintf("🚨 Culprit sessions %s: %d\n", id[:8], reputations[id])
+	}
+
 	state = state + fmt.Sprintf("🦷 Binary version: %s", version)
 	return state, nil
 }
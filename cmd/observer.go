@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -42,22 +43,62 @@ func ObserverBootCmd(c *cli.Context) error {
 	resty.SetTimeout(time.Second * 30)
 	resty.SetHeader("User-Agent", ua)
 
-	mc, err := config.ReadConfiguration(c.String("config"), "observer")
+	node, closer, err := buildObserverNode(ctx, c.String("config"))
 	if err != nil {
 		return err
 	}
+	defer closer()
 
-	db, err := observer.OpenSQLite3Store(mc.Observer.StoreDir + "/safe.sqlite3")
+	readme := c.App.Metadata["README"].(string)
+	go node.StartHTTP(version, readme)
+	node.Boot(ctx)
+	return nil
+}
+
+func ObserverSignerRefreshCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	node, closer, err := buildObserverNode(ctx, c.String("config"))
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer closer()
 
-	kd, err := keeper.OpenSQLite3ReadOnlyStore(mc.Observer.KeeperStoreDir + "/safe.sqlite3")
+	return node.RequestSignerRefresh(ctx, c.String("key"))
+}
+
+func ObserverSignerReshareCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	node, closer, err := buildObserverNode(ctx, c.String("config"))
 	if err != nil {
 		return err
 	}
-	defer kd.Close()
+	defer closer()
+
+	return node.RequestSignerReshare(ctx, c.String("key"), c.Int("threshold"))
+}
+
+func buildObserverNode(ctx context.Context, path string) (*observer.Node, func(), error) {
+	mc, err := config.ReadConfiguration(path, "observer")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := observer.OpenSQLite3Store(mc.Observer.StoreDir + "/safe.sqlite3")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kd, err := keeper.OpenSQLite3ReadOnlyStore(mc.Observer.KeeperStoreDir + "/safe.sqlite3")
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	closer := func() {
+		kd.Close()
+		db.Close()
+	}
 
 	mixin, err := mixin.NewFromKeystore(&mixin.Keystore{
 		AppID:             mc.Observer.App.AppId,
@@ -66,23 +107,23 @@ func ObserverBootCmd(c *cli.Context) error {
 		ServerPublicKey:   mc.Observer.App.ServerPublicKey,
 	})
 	if err != nil {
-		return err
+		closer()
+		return nil, nil, err
 	}
 	me, err := mixin.UserMe(ctx)
 	if err != nil {
-		return err
+		closer()
+		return nil, nil, err
 	}
 	key, err := mixinnet.ParseKeyWithPub(mc.Observer.App.SpendPrivateKey, me.SpendPublicKey)
 	if err != nil {
-		return err
+		closer()
+		return nil, nil, err
 	}
 	mc.Observer.App.SpendPrivateKey = key.String()
 
 	node := observer.NewNode(db, kd, mc.Observer, mc.Keeper.MTG, mixin)
-	readme := c.App.Metadata["README"].(string)
-	go node.StartHTTP(version, readme)
-	node.Boot(ctx)
-	return nil
+	return node, closer, nil
 }
 
 func ObserverFillAccountants(c *cli.Context) error {
@@ -91,6 +132,7 @@ func ObserverFillAccountants(c *cli.Context) error {
 	switch chain {
 	case bitcoin.ChainBitcoin:
 	case bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoinCash:
 	default:
 		return fmt.Errorf("invalid chain %d", chain)
 	}
@@ -156,16 +198,30 @@ func ObserverFillAccountants(c *cli.Context) error {
 	}
 	msgTx.TxOut = msgTx.TxOut[:count]
 
+	hashType := txscript.SigHashAll
+	if chain == bitcoin.ChainBitcoinCash {
+		hashType = hashType | bitcoin.SigHashForkID
+	}
 	for idx := range msgTx.TxIn {
 		in := inputs[idx]
 		pof := txscript.NewCannedPrevOutputFetcher(in.Script, in.Satoshi)
 		tsh := txscript.NewTxSigHashes(msgTx, pof)
-		hash, err := txscript.CalcWitnessSigHash(in.Script, tsh, txscript.SigHashAll, msgTx, idx, in.Satoshi)
+		hash, err := txscript.CalcWitnessSigHash(in.Script, tsh, hashType, msgTx, idx, in.Satoshi)
 		if err != nil {
 			return err
 		}
 		signature := ecdsa.Sign(priv, hash)
-		sig := append(signature.Serialize(), byte(txscript.SigHashAll))
+		sig := append(signature.Serialize(), byte(hashType))
+		if chain == bitcoin.ChainBitcoinCash {
+			builder := txscript.NewScriptBuilder()
+			builder.AddData(sig)
+			builder.AddData(pub.SerializeCompressed())
+			msgTx.TxIn[idx].SignatureScript, err = builder.Script()
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, sig)
 		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, pub.SerializeCompressed())
 	}
@@ -182,6 +238,21 @@ func ObserverFillAccountants(c *cli.Context) error {
 	return nil
 }
 
+func ObserverKeySignerCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	signer, err := observer.NewFileKeySigner(c.String("list"))
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("unix", c.String("socket"))
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	return observer.ServeKeySigner(ctx, ln, signer)
+}
+
 func ObserverImportKeys(c *cli.Context) error {
 	ctx := context.Background()
 
@@ -248,11 +319,11 @@ func generateAccountantKey(chain byte) (*btcec.PrivateKey, string, error) {
 		return nil, "", err
 	}
 	pub := priv.PubKey().SerializeCompressed()
-	awpkh, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pub), bitcoin.NetConfig(chain))
+	addr, err := bitcoin.EncodeAccountantAddress(pub, chain)
 	if err != nil {
 		return nil, "", err
 	}
-	return priv, awpkh.EncodeAddress(), nil
+	return priv, addr, nil
 }
 
 func GenerateObserverKeys(c *cli.Context) error {
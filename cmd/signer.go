@@ -1,16 +1,20 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/config"
+	"github.com/MixinNetwork/safe/custodian"
 	"github.com/MixinNetwork/safe/messenger"
 	"github.com/MixinNetwork/safe/signer"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -50,7 +54,7 @@ func SignerBootCmd(c *cli.Context) error {
 	group.EnableDebug()
 	group.SetKernelRPC(mc.Signer.MixinRPC)
 
-	messenger, err := messenger.NewMixinMessenger(ctx, mc.Signer.Messenger())
+	network, err := buildSignerNetwork(ctx, mc.Signer)
 	if err != nil {
 		return err
 	}
@@ -60,6 +64,14 @@ func SignerBootCmd(c *cli.Context) error {
 		return err
 	}
 	defer kd.Close()
+	shareKey, err := readSignerShareKey(mc.Signer)
+	if err != nil {
+		return err
+	}
+	err = kd.EnableShareEncryption(ctx, shareKey)
+	if err != nil {
+		return err
+	}
 
 	s := &mixin.Keystore{
 		ClientID:          mc.Signer.MTG.App.AppId,
@@ -81,18 +93,147 @@ func SignerBootCmd(c *cli.Context) error {
 	}
 	mc.Signer.MTG.App.SpendPrivateKey = key.String()
 
-	node := signer.NewNode(kd, group, messenger, mc.Signer, mc.Keeper.MTG, client)
+	node := signer.NewNode(kd, group, network, mc.Signer, mc.Keeper.MTG, client)
 	node.Boot(ctx)
 
 	if mmc := mc.Signer.MonitorConversaionId; mmc != "" {
 		go MonitorSigner(ctx, db, kd, mc.Signer, group, mmc, version)
 	}
+	if mc.Signer.MetricsListen != "" {
+		go ServeSignerMetrics(ctx, mc.Signer.StoreDir+"/mtg.sqlite3", kd, mc.Signer)
+	}
 
 	group.AttachWorker(mc.Signer.AppId, node)
+	if appId := mc.Signer.CustodianAppId; appId != "" {
+		cd, err := custodian.OpenSQLite3Store(mc.Signer.StoreDir + "/custodian.sqlite3")
+		if err != nil {
+			return err
+		}
+		defer cd.Close()
+		worker := custodian.NewWorker(cd, group, &custodian.Configuration{
+			AppId:           appId,
+			SignerAssetId:   mc.Signer.AssetId,
+			MixinRPC:        mc.Signer.MixinRPC,
+			SpendPrivateKey: mc.Signer.MTG.App.SpendPrivateKey,
+			MintAccount:     mc.Signer.CustodianMintAccount,
+			ForwardMint:     mc.Signer.CustodianForwardMint,
+		}, client)
+		worker.Boot(ctx)
+		group.AttachWorker(appId, worker)
+	}
 	group.Run(ctx)
 	return nil
 }
 
+// SignerRekeyCmd encrypts all shares with the new key file or passphrase,
+// the signer node must be stopped, and its configuration updated after.
+func SignerRekeyCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	mc, err := config.ReadConfiguration(c.String("config"), "signer")
+	if err != nil {
+		return err
+	}
+	oldKey, err := readSignerShareKey(mc.Signer)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readSignerNewSharePassphrase(c.Bool("passphrase-stdin"))
+	if err != nil {
+		return err
+	}
+	newKey, err := signer.ShareEncryptionKey(c.String("key-file"), passphrase, mc.Signer.AppId)
+	if err != nil {
+		return err
+	}
+	if newKey == nil && !c.Bool("plaintext") {
+		return fmt.Errorf("no new share key file or passphrase")
+	}
+
+	kd, err := signer.OpenSQLite3Store(mc.Signer.StoreDir + "/mpc.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+	count, err := kd.RekeyShares(ctx, oldKey, newKey)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d shares rekeyed, update the share key configuration before starting the signer\n", count)
+	return nil
+}
+
+// SignerRestoreCmd restores the shares of the signer from its saver backups,
+// it could be used when the signer node lost its store, and must be stopped.
+func SignerRestoreCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	mc, err := config.ReadConfiguration(c.String("config"), "signer")
+	if err != nil {
+		return err
+	}
+	shareKey, err := readSignerShareKey(mc.Signer)
+	if err != nil {
+		return err
+	}
+
+	kd, err := signer.OpenSQLite3Store(mc.Signer.StoreDir + "/mpc.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+	err = kd.Migrate(ctx)
+	if err != nil {
+		return err
+	}
+	err = kd.EnableShareEncryption(ctx, shareKey)
+	if err != nil {
+		return err
+	}
+	count, err := signer.RestoreKeysFromSaver(ctx, kd, mc.Signer)
+	fmt.Printf("%d shares restored from the saver %s\n", count, mc.Signer.SaverAPI)
+	return err
+}
+
+// the direct network is preferred if configured, and the public key should be
+// shared with all other signer nodes in their network peers
+func buildSignerNetwork(ctx context.Context, conf *signer.Configuration) (signer.Network, error) {
+	dc := conf.Direct()
+	if dc == nil {
+		return messenger.NewMixinMessenger(ctx, conf.Messenger())
+	}
+	logger.Printf("buildSignerNetwork(%s, %s)", dc.Listen, messenger.DirectPublicKey(dc.Key))
+	return messenger.NewDirectMessenger(ctx, dc)
+}
+
+// the passphrase could be kept out of the configuration file with the
+// SAFE_SIGNER_SHARE_PASSPHRASE environment variable
+func readSignerShareKey(conf *signer.Configuration) ([]byte, error) {
+	passphrase := conf.SharePassphrase
+	if passphrase == "" {
+		passphrase = os.Getenv("SAFE_SIGNER_SHARE_PASSPHRASE")
+	}
+	return signer.ShareEncryptionKey(conf.ShareKeyFile, passphrase, conf.AppId)
+}
+
+// the new passphrase is never given in the arguments, which are visible to
+// other users and kept in the shell history, so it's read from the
+// SAFE_SIGNER_SHARE_NEW_PASSPHRASE environment variable or the first stdin line
+func readSignerNewSharePassphrase(stdin bool) (string, error) {
+	passphrase := os.Getenv("SAFE_SIGNER_SHARE_NEW_PASSPHRASE")
+	if !stdin {
+		return passphrase, nil
+	}
+	if passphrase != "" {
+		return "", fmt.Errorf("both SAFE_SIGNER_SHARE_NEW_PASSPHRASE and stdin passphrase")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read passphrase from stdin: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
 func SignerFundRequest(c *cli.Context) error {
 	mc, err := config.ReadConfiguration(c.String("config"), "signer")
 	if err != nil {
@@ -36,16 +36,34 @@ func GenerateTestTransactionProposal(c *cli.Context) error {
 	private, _ := btcec.PrivKeyFromBytes(kb)
 	holder := testPublicKey(hex.EncodeToString(private.Serialize()))
 
-	receiver, err := bitcoin.ParseAddress(c.String("address"), byte(chain))
-	if err != nil {
-		return err
+	var extra []byte
+	if rl := c.StringSlice("recipient"); len(rl) > 0 {
+		recipients, err := parseRecipients(rl, byte(chain))
+		if err != nil {
+			return err
+		}
+		fmt.Println("recipients: " + hex.EncodeToString(common.EncodeRecipients(recipients)))
+		ref := c.String("reference")
+		if ref == "" {
+			fmt.Println("write the recipients to a storage transaction and propose again with its hash as reference")
+			return nil
+		}
+		extra, err = hex.DecodeString(ref)
+		if err != nil || len(extra) != 32 {
+			return fmt.Errorf("invalid reference %s", ref)
+		}
+	} else {
+		receiver, err := bitcoin.ParseAddress(c.String("address"), byte(chain))
+		if err != nil {
+			return err
+		}
+		extra = []byte(receiver)
 	}
 
 	addr := abi.GetFactoryAssetAddress("0x11EC02748116A983deeD59235302C3139D6e8cdD", common.SafeBitcoinChainId, "BTC", "Bitcoin", holder)
 	assetKey := strings.ToLower(addr.String())
-	bondId := fetchAssetId(ethereum.GenerateAssetId(common.SafeChainPolygon, assetKey))
+	bondId := fetchAssetId(ethereum.GenerateAssetId(ethereum.BondChain().Chain, assetKey))
 
-	extra := []byte(receiver)
 	sid := uuid.Must(uuid.NewV4()).String()
 	amount := decimal.NewFromFloat(c.Float64("amount"))
 
@@ -54,6 +72,30 @@ func GenerateTestTransactionProposal(c *cli.Context) error {
 	return makeKeeperPaymentRequest(c.String("config"), bondId, amount, sid, memo)
 }
 
+func parseRecipients(list []string, chain byte) ([]*common.Recipient, error) {
+	var recipients []*common.Recipient
+	for _, r := range list {
+		i := strings.LastIndex(r, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid recipient %s", r)
+		}
+		address, amount := r[:i], r[i+1:]
+		_, err := bitcoin.ParseAddress(address, chain)
+		if err != nil {
+			return nil, err
+		}
+		amt, err := decimal.NewFromString(amount)
+		if err != nil || !amt.IsPositive() {
+			return nil, fmt.Errorf("invalid recipient amount %s", r)
+		}
+		recipients = append(recipients, &common.Recipient{Address: address, Amount: amt})
+	}
+	if len(recipients) > common.RecipientListMaximum {
+		return nil, fmt.Errorf("too many recipients %d", len(recipients))
+	}
+	return recipients, nil
+}
+
 func GenerateTestTransactionApproval(c *cli.Context) error {
 	chain := c.Int("chain")
 	switch chain {
@@ -1,35 +1,57 @@
 package common
 
 import (
+	"fmt"
+
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
 )
 
 const (
-	SafeChainBitcoin  = bitcoin.ChainBitcoin
-	SafeChainLitecoin = bitcoin.ChainLitecoin
-	SafeChainEthereum = ethereum.ChainEthereum
-	SafeChainPolygon  = ethereum.ChainPolygon
+	SafeChainBitcoin     = bitcoin.ChainBitcoin
+	SafeChainLitecoin    = bitcoin.ChainLitecoin
+	SafeChainBitcoinCash = bitcoin.ChainBitcoinCash
+	SafeChainMixin       = mixin.ChainMixinKernel
+	SafeChainEthereum    = ethereum.ChainEthereum
+	SafeChainPolygon     = ethereum.ChainPolygon
+	SafeChainMVM         = ethereum.ChainMVM
 
-	SafeBitcoinChainId  = "c6d0c728-2624-429b-8e0d-d9d19b6592fa"
-	SafeEthereumChainId = "43d61dcd-e413-450d-80b8-101d5e903357"
-	SafeLitecoinChainId = "76c802a2-7c88-447f-a93e-c29c9e5dd9c8"
-	SafePolygonChainId  = "b7938396-3f94-4e0a-9179-d3440718156f"
+	SafeBitcoinChainId     = "c6d0c728-2624-429b-8e0d-d9d19b6592fa"
+	SafeEthereumChainId    = "43d61dcd-e413-450d-80b8-101d5e903357"
+	SafeLitecoinChainId    = "76c802a2-7c88-447f-a93e-c29c9e5dd9c8"
+	SafeBitcoinCashChainId = "fd11b6e3-0b87-41f1-a41f-f0e9b49e5bf0"
+	SafeMixinChainId       = "c94ac88f-4671-3976-b60a-09064f1811e8"
+	SafePolygonChainId     = "b7938396-3f94-4e0a-9179-d3440718156f"
 )
 
+// RegisterEVMChain adds an EVM chain from the configuration, which must not
+// take the chain or the Mixin chain id of the other chain families.
+func RegisterEVMChain(c *ethereum.ChainConfig) {
+	if SafeChainFamily(c.Chain) != 0 && !ethereum.IsEVMChain(c.Chain) {
+		panic(fmt.Errorf("invalid evm chain %v", c))
+	}
+	if id := SafeAssetIdChainNoPanic(c.MixinChainId); id != 0 && !ethereum.IsEVMChain(id) {
+		panic(fmt.Errorf("invalid evm mixin chain id %v", c))
+	}
+	ethereum.RegisterChain(c)
+}
+
 func SafeCurveChain(crv byte) byte {
 	switch crv {
-	case CurveSecp256k1ECDSABitcoin:
+	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1SchnorrBitcoin:
 		return SafeChainBitcoin
 	case CurveSecp256k1ECDSALitecoin:
 		return SafeChainLitecoin
-	case CurveSecp256k1ECDSAEthereum:
-		return SafeChainEthereum
-	case CurveSecp256k1ECDSAPolygon:
-		return SafeChainPolygon
-	default:
-		panic(crv)
+	case CurveSecp256k1ECDSABitcoinCash:
+		return SafeChainBitcoinCash
+	case CurveEdwards25519Mixin:
+		return SafeChainMixin
+	}
+	if ec := ethereum.GetChainConfigByCurve(crv); ec != nil {
+		return ec.Chain
 	}
+	panic(crv)
 }
 
 func SafeChainCurve(chain byte) byte {
@@ -38,13 +60,15 @@ func SafeChainCurve(chain byte) byte {
 		return CurveSecp256k1ECDSABitcoin
 	case SafeChainLitecoin:
 		return CurveSecp256k1ECDSALitecoin
-	case SafeChainEthereum:
-		return CurveSecp256k1ECDSAEthereum
-	case SafeChainPolygon:
-		return CurveSecp256k1ECDSAPolygon
-	default:
-		panic(chain)
+	case SafeChainBitcoinCash:
+		return CurveSecp256k1ECDSABitcoinCash
+	case SafeChainMixin:
+		return CurveEdwards25519Mixin
 	}
+	if ec := ethereum.GetChainConfig(chain); ec != nil {
+		return ec.Curve
+	}
+	panic(chain)
 }
 
 func SafeChainAssetId(chain byte) string {
@@ -53,13 +77,15 @@ func SafeChainAssetId(chain byte) string {
 		return SafeBitcoinChainId
 	case SafeChainLitecoin:
 		return SafeLitecoinChainId
-	case SafeChainEthereum:
-		return SafeEthereumChainId
-	case SafeChainPolygon:
-		return SafePolygonChainId
-	default:
-		panic(chain)
+	case SafeChainBitcoinCash:
+		return SafeBitcoinCashChainId
+	case SafeChainMixin:
+		return SafeMixinChainId
+	}
+	if ec := ethereum.GetChainConfig(chain); ec != nil {
+		return ec.MixinChainId
 	}
+	panic(chain)
 }
 
 func SafeAssetIdChain(chainId string) byte {
@@ -76,10 +102,29 @@ func SafeAssetIdChainNoPanic(chainId string) byte {
 		return SafeChainBitcoin
 	case SafeLitecoinChainId:
 		return SafeChainLitecoin
-	case SafeEthereumChainId:
+	case SafeBitcoinCashChainId:
+		return SafeChainBitcoinCash
+	case SafeMixinChainId:
+		return SafeChainMixin
+	}
+	if ec := ethereum.GetChainConfigByMixinChainId(chainId); ec != nil {
+		return ec.Chain
+	}
+	return 0
+}
+
+// SafeChainFamily returns the chain that shares the same account and
+// transaction model with the chain, i.e. SafeChainBitcoin for all UTXO
+// chains forked from Bitcoin, and SafeChainEthereum for all EVM chains.
+func SafeChainFamily(chain byte) byte {
+	switch chain {
+	case SafeChainBitcoin, SafeChainLitecoin, SafeChainBitcoinCash:
+		return SafeChainBitcoin
+	case SafeChainMixin:
+		return SafeChainMixin
+	}
+	if ethereum.IsEVMChain(chain) {
 		return SafeChainEthereum
-	case SafePolygonChainId:
-		return SafeChainPolygon
 	}
 	return 0
 }
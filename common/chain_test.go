@@ -0,0 +1,89 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	require := require.New(t)
+
+	for _, c := range []struct {
+		chain, crv, family byte
+		assetId            string
+	}{
+		{SafeChainBitcoin, CurveSecp256k1ECDSABitcoin, SafeChainBitcoin, SafeBitcoinChainId},
+		{SafeChainLitecoin, CurveSecp256k1ECDSALitecoin, SafeChainBitcoin, SafeLitecoinChainId},
+		{SafeChainBitcoinCash, CurveSecp256k1ECDSABitcoinCash, SafeChainBitcoin, SafeBitcoinCashChainId},
+		{SafeChainMixin, CurveEdwards25519Mixin, SafeChainMixin, SafeMixinChainId},
+		{SafeChainEthereum, CurveSecp256k1ECDSAEthereum, SafeChainEthereum, SafeEthereumChainId},
+		{SafeChainPolygon, CurveSecp256k1ECDSAPolygon, SafeChainEthereum, SafePolygonChainId},
+		{SafeChainMVM, CurveSecp256k1ECDSAMVM, SafeChainEthereum, ethereum.GetMixinChainID(SafeChainMVM)},
+	} {
+		require.Equal(c.chain, SafeCurveChain(c.crv))
+		require.Equal(c.crv, SafeChainCurve(c.chain))
+		require.Equal(c.family, SafeChainFamily(c.chain))
+		require.Equal(c.assetId, SafeChainAssetId(c.chain))
+		require.Equal(c.chain, SafeAssetIdChain(c.assetId))
+	}
+	require.Equal(CurveSecp256k1ECDSABitcoin, int(SafeChainCurve(SafeCurveChain(CurveSecp256k1SchnorrBitcoin))))
+	require.Equal(byte(0), SafeChainFamily(0))
+	require.Equal(byte(0), SafeAssetIdChainNoPanic("c6d0c728-2624-429b-8e0d-d9d19b6592fb"))
+
+	for _, ec := range ethereum.ListChains() {
+		require.Equal(SafeChainEthereum, int(SafeChainFamily(ec.Chain)))
+		require.Equal(CurveSecp256k1ECDSAEthereum, int(NormalizeCurve(ec.Curve)))
+		require.Equal(ec.Chain, SafeCurveChain(ec.Curve))
+		require.Equal(ec.EvmChainId, ethereum.GetEvmChainID(int64(ec.Chain)))
+	}
+	require.Panics(func() {
+		ethereum.RegisterChain(&ethereum.ChainConfig{
+			Chain:             SafeChainPolygon,
+			Curve:             122,
+			Name:              "polygon",
+			EvmChainId:        137,
+			MixinChainId:      SafePolygonChainId,
+			Precision:         18,
+			Confirmations:     1,
+			FinalizationDelay: 1,
+			DepositCheckpoint: 1,
+			BlockInterval:     1,
+		})
+	})
+
+	require.Equal(SafeChainPolygon, ethereum.BondChain().Chain)
+	rpcs := map[string]string{"mvm": "https://geth.mixin.dev"}
+	require.Equal("https://geth.mixin.dev", ethereum.ChainRPC(SafeChainMVM, rpcs, "ethereum", "polygon"))
+	require.Equal("polygon", ethereum.ChainRPC(SafeChainPolygon, rpcs, "ethereum", "polygon"))
+
+	bsc := &ethereum.ChainConfig{
+		Chain:             8,
+		Curve:             122,
+		Name:              "bsc",
+		EvmChainId:        56,
+		MixinChainId:      "1949e683-6a08-49e2-b087-d6b72398588f",
+		Precision:         18,
+		Confirmations:     16,
+		FinalizationDelay: 32,
+		DepositCheckpoint: 1,
+		BlockInterval:     3 * time.Second,
+	}
+	dup := *bsc
+	RegisterEVMChain(bsc)
+	RegisterEVMChain(&dup)
+	require.Equal(bsc.Chain, SafeCurveChain(bsc.Curve))
+	require.Equal(SafeChainEthereum, int(SafeChainFamily(bsc.Chain)))
+	require.Equal(bsc.Chain, SafeAssetIdChain(bsc.MixinChainId))
+
+	invalid := *bsc
+	invalid.Chain, invalid.Curve, invalid.Name, invalid.EvmChainId, invalid.MixinChainId = SafeChainBitcoinCash, 132, "bch", 10000, "fd11b6e3-0b87-41f1-a41f-f0e9b49e5bf1"
+	require.Panics(func() { RegisterEVMChain(&invalid) })
+	invalid.Chain, invalid.MixinChainId = 9, SafeBitcoinChainId
+	require.Panics(func() { RegisterEVMChain(&invalid) })
+	invalid.MixinChainId = "fd11b6e3-0b87-41f1-a41f-f0e9b49e5bf1"
+	invalid.FactoryAddress = "0x4D17777E0AC12C6a0d4DEF1204278cFEAe142a1E"
+	require.Panics(func() { RegisterEVMChain(&invalid) })
+}
@@ -38,6 +38,7 @@ func HandleCORS(handler http.Handler) http.Handler {
 		}
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 		w.Header().Add("Access-Control-Allow-Headers", "Content-Type,X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 		w.Header().Set("Access-Control-Allow-Methods", "OPTIONS,GET,POST,DELETE")
 		w.Header().Set("Access-Control-Max-Age", "600")
 		if r.Method == "OPTIONS" {
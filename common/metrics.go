@@ -0,0 +1,84 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// Metrics is a snapshot of gauges in the Prometheus text exposition format,
+// all values are collected from the stores on each scrape, so there is no
+// state to keep between scrapes and no client library needed.
+type Metrics struct {
+	helps  map[string]string
+	values map[string][]string
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		helps:  make(map[string]string),
+		values: make(map[string][]string),
+	}
+}
+
+// Gauge records the value of the metric name with the optional label pairs,
+// e.g. Gauge("safe_keeper_transactions", "...", 3, "state", "pending").
+func (m *Metrics) Gauge(name, help string, value float64, labels ...string) {
+	if len(labels)%2 != 0 {
+		panic(labels)
+	}
+	m.helps[name] = help
+	var pairs []string
+	for i := 0; i < len(labels); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], v))
+	}
+	line := name
+	if len(pairs) > 0 {
+		line = line + "{" + strings.Join(pairs, ",") + "}"
+	}
+	line = fmt.Sprintf("%s %v", line, value)
+	m.values[name] = append(m.values[name], line)
+}
+
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(m.helps))
+	for n := range m.helps {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, n := range names {
+		fmt.Fprintf(&b, "# HELP %s %s\n", n, m.helps[n])
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", n)
+		for _, l := range m.values[n] {
+			b.WriteString(l + "\n")
+		}
+	}
+	size, err := io.WriteString(w, b.String())
+	return int64(size), err
+}
+
+// ServeMetrics listens on the address and serves the collected metrics on
+// GET /metrics, it's optional for all nodes and should not be exposed to the
+// public network.
+func ServeMetrics(ctx context.Context, listen string, collect func(context.Context) (*Metrics, error)) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		m, err := collect(ctx)
+		if err != nil {
+			logger.Verbosef("ServeMetrics(%s) => %v", listen, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = m.WriteTo(w)
+	})
+	return http.ListenAndServe(listen, mux)
+}
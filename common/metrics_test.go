@@ -0,0 +1,30 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	require := require.New(t)
+
+	m := NewMetrics()
+	m.Gauge("safe_test_transactions", "The transactions", 3, "state", "pending")
+	m.Gauge("safe_test_transactions", "The transactions", 1, "state", `fa"iled`)
+	m.Gauge("safe_test_keys", "The keys", 12)
+	require.Panics(func() { m.Gauge("safe_test_keys", "The keys", 1, "state") })
+
+	var b strings.Builder
+	_, err := m.WriteTo(&b)
+	require.Nil(err)
+	require.Equal(`# HELP safe_test_keys The keys
+# TYPE safe_test_keys gauge
+safe_test_keys 12
+# HELP safe_test_transactions The transactions
+# TYPE safe_test_transactions gauge
+safe_test_transactions{state="pending"} 3
+safe_test_transactions{state="fa\"iled"} 1
+`, b.String())
+}
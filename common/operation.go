@@ -1,19 +1,31 @@
 package common
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/gofrs/uuid/v5"
 )
 
 const (
-	OperationTypeWrapper     = 0
-	OperationTypeKeygenInput = 1
-	OperationTypeSignInput   = 2
-
-	OperationTypeKeygenOutput = 11
-	OperationTypeSignOutput   = 12
+	OperationTypeWrapper        = 0
+	OperationTypeKeygenInput    = 1
+	OperationTypeSignInput      = 2
+	OperationTypeRefreshInput   = 3
+	OperationTypeReshareInput   = 4
+	OperationTypePresignInput   = 5
+	OperationTypeBatchSignInput = 6
+
+	OperationTypeKeygenOutput    = 11
+	OperationTypeSignOutput      = 12
+	OperationTypeRefreshOutput   = 13
+	OperationTypeReshareOutput   = 14
+	OperationTypeBatchSignOutput = 16
 
 	CurveSecp256k1ECDSABitcoin   = 1
 	CurveSecp256k1ECDSAEthereum  = 2
@@ -25,6 +37,10 @@ const (
 	CurveSecp256k1ECDSABitcoinCash = 110 + CurveSecp256k1ECDSABitcoin
 	CurveSecp256k1ECDSAMVM         = 100 + CurveSecp256k1ECDSAEthereum
 	CurveSecp256k1ECDSAPolygon     = 110 + CurveSecp256k1ECDSAEthereum
+
+	// the batch messages are signed one by one in separate MPC sessions, so
+	// the limit keeps all of them signed well within the signer session timeout
+	BatchSignLimit = 16
 )
 
 type Operation struct {
@@ -76,6 +92,60 @@ func NormalizeCurve(crv uint8) uint8 {
 	return crv
 }
 
+// the committee of a reshare operation is encoded as the threshold followed
+// by the hash of the sorted members, so all signers can check it against
+// their own configuration before resharing any key
+func EncodeSignerCommittee(members []string, threshold int) []byte {
+	if threshold < 1 || threshold >= len(members) || threshold > 255 {
+		panic(threshold)
+	}
+	members = slices.Clone(members)
+	slices.Sort(members)
+	sum := sha256.Sum256([]byte(strings.Join(members, ";")))
+	return append([]byte{byte(threshold)}, sum[:]...)
+}
+
+// the messages of a batch sign operation and the signatures of its output are
+// too large for the operation extra, so they are encoded as a list and sent in
+// a storage transaction, then the operation extra is the digest of the list
+func EncodeBatchItems(items [][]byte) []byte {
+	if len(items) == 0 || len(items) > BatchSignLimit {
+		panic(len(items))
+	}
+	enc := common.NewEncoder()
+	enc.WriteInt(len(items))
+	for _, b := range items {
+		enc.WriteInt(len(b))
+		enc.Write(b)
+	}
+	return enc.Bytes()
+}
+
+func DecodeBatchItems(b []byte) ([][]byte, error) {
+	dec := common.NewDecoder(b)
+	n, err := dec.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 || n > BatchSignLimit {
+		return nil, fmt.Errorf("invalid batch size %d", n)
+	}
+	items := make([][]byte, n)
+	for i := range items {
+		items[i], err = dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(items[i]) == 0 {
+			return nil, fmt.Errorf("invalid batch item %d", i)
+		}
+	}
+	if !bytes.Equal(EncodeBatchItems(items), b) {
+		return nil, fmt.Errorf("invalid batch encoding %x", b)
+	}
+	return items, nil
+}
+
 func DecodeOperation(b []byte) (*Operation, error) {
 	dec := common.NewDecoder(b)
 	id, err := readUUID(dec)
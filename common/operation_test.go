@@ -57,3 +57,22 @@ func TestOperation(t *testing.T) {
 
 	require.Equal("feL`4xL1,UGP^(,bIw]q$AAAA", Base91Encode(op.Encode()))
 }
+
+func TestBatchItems(t *testing.T) {
+	require := require.New(t)
+
+	msg, _ := hex.DecodeString("a99c2e0e2b1da4d648755ef19bd95139acbbe6564cfb06dec7cd34931ca72cdc")
+	items := [][]byte{msg, []byte("mixin")}
+	b := EncodeBatchItems(items)
+	require.Equal("00020020a99c2e0e2b1da4d648755ef19bd95139acbbe6564cfb06dec7cd34931ca72cdc00056d6978696e", hex.EncodeToString(b))
+	decoded, err := DecodeBatchItems(b)
+	require.Nil(err)
+	require.Equal(items, decoded)
+
+	_, err = DecodeBatchItems(append(b, 0))
+	require.NotNil(err)
+	_, err = DecodeBatchItems(b[:len(b)-1])
+	require.NotNil(err)
+	_, err = DecodeBatchItems([]byte{0, 0})
+	require.NotNil(err)
+}
@@ -0,0 +1,213 @@
+package common
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	RecipientListVersion = 1
+
+	// large enough for batch proposals, a single transaction still has to
+	// respect the outputs limit of its chain
+	RecipientListMaximum = 4096
+
+	// the amount exponent is bounded before any arithmetic, a huge exponent
+	// would make every keeper rescale the decimal to an unbounded integer
+	recipientExponentMinimum = -36
+	recipientExponentMaximum = 18
+
+	recipientFlagMemo  = 1 << 0
+	recipientFlagAsset = 1 << 1
+)
+
+// Recipient is one output of a transaction proposal, memo and asset are
+// optional and only accepted by chains that support them.
+type Recipient struct {
+	Address string
+	Amount  decimal.Decimal
+	Memo    []byte
+	AssetId string
+}
+
+// EncodeRecipients serializes the recipients as
+//
+//	version:1 | count:2 | (flags:1 | address:2+n | exp:4 | coef:1+n | [memo:2+n] | [asset:16])*
+//
+// all integers are big endian, the leading version byte never collides with
+// the legacy JSON encoding which always starts with '['.
+func EncodeRecipients(recipients []*Recipient) []byte {
+	if n := len(recipients); n == 0 || n > RecipientListMaximum {
+		panic(n)
+	}
+	b := []byte{RecipientListVersion}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(recipients)))
+	for _, r := range recipients {
+		if !r.Amount.IsPositive() {
+			panic(r.Amount)
+		}
+		var flags byte
+		if len(r.Memo) > 0 {
+			flags |= recipientFlagMemo
+		}
+		if r.AssetId != "" {
+			flags |= recipientFlagAsset
+		}
+		b = append(b, flags)
+		b = appendRecipientBytes(b, []byte(r.Address))
+		b = binary.BigEndian.AppendUint32(b, uint32(r.Amount.Exponent()))
+		coef := r.Amount.Coefficient().Bytes()
+		if len(coef) > 255 {
+			panic(r.Amount)
+		}
+		b = append(b, byte(len(coef)))
+		b = append(b, coef...)
+		if flags&recipientFlagMemo != 0 {
+			b = appendRecipientBytes(b, r.Memo)
+		}
+		if flags&recipientFlagAsset != 0 {
+			b = append(b, uuid.Must(uuid.FromString(r.AssetId)).Bytes()...)
+		}
+	}
+	return b
+}
+
+// DecodeRecipients accepts both the versioned binary encoding and the legacy
+// JSON array of [address, amount] pairs.
+func DecodeRecipients(b []byte) ([]*Recipient, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty recipients")
+	}
+	switch b[0] {
+	case RecipientListVersion:
+		return decodeRecipientsV1(b[1:])
+	case '[':
+		return decodeRecipientsJSON(b)
+	}
+	return nil, fmt.Errorf("invalid recipients version %d", b[0])
+}
+
+func decodeRecipientsV1(b []byte) ([]*Recipient, error) {
+	r := &recipientReader{buf: b}
+	count := int(r.uint16())
+	if r.err == nil && (count == 0 || count > RecipientListMaximum) {
+		return nil, fmt.Errorf("invalid recipients count %d", count)
+	}
+	recipients := make([]*Recipient, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		flags := r.next(1)
+		address := r.bytes()
+		exp := int32(r.uint32())
+		coef := r.next(int(r.byte()))
+		if r.err != nil {
+			break
+		}
+		if flags[0]&^(recipientFlagMemo|recipientFlagAsset) != 0 {
+			return nil, fmt.Errorf("invalid recipient flags %d", flags[0])
+		}
+		if exp < recipientExponentMinimum || exp > recipientExponentMaximum {
+			return nil, fmt.Errorf("invalid recipient exponent %d", exp)
+		}
+		rp := &Recipient{
+			Address: string(address),
+			Amount:  decimal.NewFromBigInt(new(big.Int).SetBytes(coef), exp),
+		}
+		if flags[0]&recipientFlagMemo != 0 {
+			rp.Memo = r.bytes()
+		}
+		if flags[0]&recipientFlagAsset != 0 {
+			id, err := uuid.FromBytes(r.next(16))
+			if r.err == nil && err != nil {
+				return nil, err
+			}
+			rp.AssetId = id.String()
+		}
+		recipients = append(recipients, rp)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.buf) != 0 {
+		return nil, fmt.Errorf("invalid recipients trailing %x", r.buf)
+	}
+	return recipients, validateRecipients(recipients)
+}
+
+func decodeRecipientsJSON(b []byte) ([]*Recipient, error) {
+	var pairs [][2]string
+	err := json.Unmarshal(b, &pairs)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(pairs); n == 0 || n > RecipientListMaximum {
+		return nil, fmt.Errorf("invalid recipients count %d", n)
+	}
+	recipients := make([]*Recipient, len(pairs))
+	for i, p := range pairs {
+		amt, err := decimal.NewFromString(p[1])
+		if err != nil {
+			return nil, err
+		}
+		if exp := amt.Exponent(); exp < recipientExponentMinimum || exp > recipientExponentMaximum {
+			return nil, fmt.Errorf("invalid recipient exponent %d", exp)
+		}
+		recipients[i] = &Recipient{Address: p[0], Amount: amt}
+	}
+	return recipients, validateRecipients(recipients)
+}
+
+func validateRecipients(recipients []*Recipient) error {
+	for _, r := range recipients {
+		if r.Address == "" || !r.Amount.IsPositive() {
+			return fmt.Errorf("invalid recipient %v", r)
+		}
+	}
+	return nil
+}
+
+func appendRecipientBytes(b, data []byte) []byte {
+	if len(data) > 65535 {
+		panic(len(data))
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+type recipientReader struct {
+	buf []byte
+	err error
+}
+
+func (r *recipientReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.buf) < n {
+		r.err = fmt.Errorf("invalid recipients length %d %d", len(r.buf), n)
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *recipientReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *recipientReader) uint16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *recipientReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *recipientReader) bytes() []byte {
+	return r.next(int(r.uint16()))
+}
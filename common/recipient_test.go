@@ -0,0 +1,72 @@
+package common
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecipients(t *testing.T) {
+	require := require.New(t)
+
+	recipients := []*Recipient{{
+		Address: "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e",
+		Amount:  decimal.RequireFromString("0.00012345"),
+	}, {
+		Address: "XINKnBS8j2UQSGzmgX8tLkXaDHV8ymfZvD8uW9jzcb3ps6z8uP6MkNaQrNbKDrjhULjRq8Qf6qBW8KCb5nS5gyBdGNNSGw5S",
+		Amount:  decimal.RequireFromString("7"),
+		Memo:    []byte("memo"),
+		AssetId: SafeBitcoinChainId,
+	}}
+	b := EncodeRecipients(recipients)
+	require.Equal(byte(RecipientListVersion), b[0])
+	require.Equal("01000200002a62633171657675397171706671703473396a"+
+		"71337878756c666830387267796a7938726e3736616a3765fffffff8023039", hex.EncodeToString(b[:55]))
+
+	decoded, err := DecodeRecipients(b)
+	require.Nil(err)
+	require.Len(decoded, 2)
+	for i, r := range recipients {
+		require.Equal(r.Address, decoded[i].Address)
+		require.True(r.Amount.Equal(decoded[i].Amount))
+		require.Equal(r.Memo, decoded[i].Memo)
+		require.Equal(r.AssetId, decoded[i].AssetId)
+	}
+
+	_, err = DecodeRecipients(b[:len(b)-1])
+	require.NotNil(err)
+	_, err = DecodeRecipients(append(b, 0))
+	require.NotNil(err)
+	_, err = DecodeRecipients([]byte{RecipientListVersion, 0, 0})
+	require.NotNil(err)
+	_, err = DecodeRecipients([]byte{2, 0, 1})
+	require.NotNil(err)
+	_, err = DecodeRecipients(nil)
+	require.NotNil(err)
+
+	huge := append([]byte{}, b...)
+	copy(huge[48:52], []byte{0x7f, 0xff, 0xff, 0xff})
+	_, err = DecodeRecipients(huge)
+	require.NotNil(err)
+	require.Contains(err.Error(), "exponent")
+	copy(huge[48:52], []byte{0x80, 0x00, 0x00, 0x00})
+	_, err = DecodeRecipients(huge)
+	require.NotNil(err)
+	require.Contains(err.Error(), "exponent")
+
+	decoded, err = DecodeRecipients([]byte(`[["bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e","0.00012345"]]`))
+	require.Nil(err)
+	require.Len(decoded, 1)
+	require.Equal(recipients[0].Address, decoded[0].Address)
+	require.True(recipients[0].Amount.Equal(decoded[0].Amount))
+	require.Nil(decoded[0].Memo)
+	require.Equal("", decoded[0].AssetId)
+	_, err = DecodeRecipients([]byte(`[["bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e","-1"]]`))
+	require.NotNil(err)
+	_, err = DecodeRecipients([]byte(`[["bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e","1e2147483647"]]`))
+	require.NotNil(err)
+	_, err = DecodeRecipients([]byte(`[]`))
+	require.NotNil(err)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	mixinkernel "github.com/MixinNetwork/safe/apps/mixin"
 	"github.com/MixinNetwork/trusted-group/mtg"
 	"github.com/fox-one/mixin-sdk-go/v2"
 	"github.com/gofrs/uuid/v5"
@@ -35,11 +36,14 @@ const (
 	// Observer can terminate all signer and keeper nodes
 	ActionTerminate = 100
 
-	ActionObserverAddKey              = 101
-	ActionObserverRequestSignerKeys   = 102
-	ActionObserverUpdateNetworkStatus = 103
-	ActionObserverHolderDeposit       = 104
-	ActionObserverSetOperationParams  = 106
+	ActionObserverAddKey               = 101
+	ActionObserverRequestSignerKeys    = 102
+	ActionObserverUpdateNetworkStatus  = 103
+	ActionObserverHolderDeposit        = 104
+	ActionObserverSetOperationParams   = 106
+	ActionObserverSetSpendingPolicy    = 107
+	ActionObserverRequestSignerRefresh = 108
+	ActionObserverRequestSignerReshare = 109
 
 	// For all Bitcoin like chains
 	ActionBitcoinSafeProposeAccount     = 110
@@ -48,6 +52,7 @@ const (
 	ActionBitcoinSafeApproveTransaction = 113
 	ActionBitcoinSafeRevokeTransaction  = 114
 	ActionBitcoinSafeCloseAccount       = 115
+	ActionBitcoinSafeConsolidate        = 116
 
 	// For Mixin Kernel mainnet
 	ActionMixinSafeProposeAccount     = 120
@@ -67,6 +72,11 @@ const (
 
 	FlagProposeNormalTransaction   = 0
 	FlagProposeRecoveryTransaction = 1
+	FlagProposeBatchTransaction    = 2
+
+	// combined with the normal or batch flag, the proposal spends only the
+	// outpoints listed in the extra instead of all safe outputs
+	FlagProposeCoinControl = 1 << 7
 )
 
 type Request struct {
@@ -140,6 +150,7 @@ func DecodeRequest(out *mtg.Action, b []byte, role uint8) (*Request, error) {
 func (req *Request) ParseMixinRecipient(ctx context.Context, client *mixin.Client, extra []byte) (*AccountProposal, error) {
 	switch req.Action {
 	case ActionBitcoinSafeProposeAccount:
+	case ActionMixinSafeProposeAccount:
 	case ActionEthereumSafeProposeAccount:
 	default:
 		panic(req.Action)
@@ -151,7 +162,12 @@ func (req *Request) ParseMixinRecipient(ctx context.Context, client *mixin.Clien
 		return nil, err
 	}
 	timelock := time.Duration(hours) * time.Hour
-	if timelock < bitcoin.TimeLockMinimum || timelock > bitcoin.TimeLockMaximum {
+	switch {
+	case req.Action == ActionMixinSafeProposeAccount:
+		if timelock != 0 {
+			return nil, fmt.Errorf("timelock %d hours", hours)
+		}
+	case timelock < bitcoin.TimeLockMinimum || timelock > bitcoin.TimeLockMaximum:
 		return nil, fmt.Errorf("timelock %d hours", hours)
 	}
 
@@ -184,6 +200,10 @@ func (req *Request) ParseMixinRecipient(ctx context.Context, client *mixin.Clien
 		return arp, nil
 	}
 
+	// the observer holds the private view key of the kernel account
+	if req.Action == ActionMixinSafeProposeAccount {
+		return nil, fmt.Errorf("custom observer %x %v", extra, arp)
+	}
 	if len(extra) != offset+33 {
 		return nil, fmt.Errorf("extra size %x %v", extra, arp)
 	}
@@ -231,13 +251,17 @@ func (r *Request) VerifyFormat() error {
 		return fmt.Errorf("invalid request mixin %v", r)
 	}
 	switch r.Curve {
-	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1ECDSALitecoin:
+	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1ECDSALitecoin, CurveSecp256k1ECDSABitcoinCash:
 		return bitcoin.VerifyHolderKey(r.Holder)
-	case CurveSecp256k1ECDSAEthereum, CurveSecp256k1ECDSAMVM, CurveSecp256k1ECDSAPolygon:
+	case CurveSecp256k1SchnorrBitcoin:
+		return bitcoin.VerifyTaprootKey(r.Holder)
+	case CurveEdwards25519Mixin:
+		return mixinkernel.VerifyHolderKey(r.Holder)
+	}
+	if ethereum.GetChainConfigByCurve(r.Curve) != nil {
 		return ethereum.VerifyHolderKey(r.Holder)
-	default:
-		return fmt.Errorf("invalid request curve %v", r)
 	}
+	return fmt.Errorf("invalid request curve %v", r)
 }
 
 func StateName(state int) string {
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS properties (
+	key           VARCHAR NOT NULL,
+	value         VARCHAR NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	PRIMARY KEY ('key')
+);
+
+
+CREATE TABLE IF NOT EXISTS work_votes (
+	day           VARCHAR NOT NULL,
+	signer_id     VARCHAR NOT NULL,
+	works         VARCHAR NOT NULL,
+	output_id     VARCHAR NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	PRIMARY KEY ('day', 'signer_id')
+);
+
+
+CREATE TABLE IF NOT EXISTS daily_works (
+	day           VARCHAR NOT NULL,
+	members       VARCHAR NOT NULL,
+	works         VARCHAR NOT NULL,
+	output_id     VARCHAR NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	PRIMARY KEY ('day')
+);
+
+
+CREATE TABLE IF NOT EXISTS mint_distributions (
+	batch          INTEGER NOT NULL,
+	amount         VARCHAR NOT NULL,
+	output_id      VARCHAR NOT NULL,
+	works_day      VARCHAR,
+	created_at     TIMESTAMP NOT NULL,
+	distributed_at TIMESTAMP,
+	PRIMARY KEY ('batch')
+);
+
+CREATE INDEX IF NOT EXISTS mint_distributions_by_distributed ON mint_distributions(distributed_at, batch);
+
+
+CREATE TABLE IF NOT EXISTS action_results (
+	output_id       VARCHAR NOT NULL,
+	transactions    TEXT NOT NULL,
+	created_at      TIMESTAMP NOT NULL,
+	PRIMARY KEY ('output_id')
+);
@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
 )
 
 //go:embed schema.sql
@@ -90,3 +92,241 @@ func (s *SQLite3Store) WriteProperty(ctx context.Context, k, v string) error {
 	}
 	return tx.Commit()
 }
+
+type DailyWorks struct {
+	Day       string
+	Members   []string
+	Works     []byte
+	OutputId  string
+	CreatedAt time.Time
+}
+
+type MintDistribution struct {
+	Batch         uint64
+	Amount        string
+	OutputId      string
+	WorksDay      sql.NullString
+	CreatedAt     time.Time
+	DistributedAt sql.NullTime
+}
+
+func (s *SQLite3Store) WriteWorksVoteIfNotExists(ctx context.Context, day, signerId string, works []byte, outputId string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT works FROM work_votes WHERE day=? AND signer_id=?", day, signerId)
+	if err != nil || existed {
+		return err
+	}
+
+	cols := []string{"day", "signer_id", "works", "output_id", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("work_votes", cols), day, signerId, hex.EncodeToString(works), outputId, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("INSERT work_votes %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListWorksVotes(ctx context.Context, day string) (map[string][]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT signer_id, works FROM work_votes WHERE day=?", day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes := make(map[string][]byte)
+	for rows.Next() {
+		var signer, works string
+		err := rows.Scan(&signer, &works)
+		if err != nil {
+			return nil, err
+		}
+		votes[signer] = common.DecodeHexOrPanic(works)
+	}
+	return votes, nil
+}
+
+func (s *SQLite3Store) WriteDailyWorksIfNotExists(ctx context.Context, dw *DailyWorks) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT works FROM daily_works WHERE day=?", dw.Day)
+	if err != nil || existed {
+		return err
+	}
+
+	cols := []string{"day", "members", "works", "output_id", "created_at"}
+	vals := []any{dw.Day, strings.Join(dw.Members, ","), hex.EncodeToString(dw.Works), dw.OutputId, dw.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("daily_works", cols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT daily_works %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadDailyWorks(ctx context.Context, day string) (*DailyWorks, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := "SELECT day, members, works, output_id, created_at FROM daily_works WHERE day=?"
+	row := s.db.QueryRowContext(ctx, query, day)
+	return dailyWorksFromRow(row)
+}
+
+func (s *SQLite3Store) ReadLatestDailyWorks(ctx context.Context) (*DailyWorks, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := "SELECT day, members, works, output_id, created_at FROM daily_works ORDER BY day DESC LIMIT 1"
+	row := s.db.QueryRowContext(ctx, query)
+	return dailyWorksFromRow(row)
+}
+
+func dailyWorksFromRow(row *sql.Row) (*DailyWorks, error) {
+	var dw DailyWorks
+	var members, works string
+	err := row.Scan(&dw.Day, &members, &works, &dw.OutputId, &dw.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	dw.Members = strings.Split(members, ",")
+	dw.Works = common.DecodeHexOrPanic(works)
+	return &dw, nil
+}
+
+func (s *SQLite3Store) WriteMintDistributionIfNotExists(ctx context.Context, batch uint64, amount, outputId string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT amount FROM mint_distributions WHERE batch=?", batch)
+	if err != nil || existed {
+		return err
+	}
+
+	cols := []string{"batch", "amount", "output_id", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("mint_distributions", cols), batch, amount, outputId, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("INSERT mint_distributions %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListPendingMintDistributions(ctx context.Context, limit int) ([]*MintDistribution, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := fmt.Sprintf("SELECT batch, amount, output_id, works_day, created_at, distributed_at FROM mint_distributions WHERE distributed_at IS NULL ORDER BY batch ASC LIMIT %d", limit)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mds []*MintDistribution
+	for rows.Next() {
+		var md MintDistribution
+		err := rows.Scan(&md.Batch, &md.Amount, &md.OutputId, &md.WorksDay, &md.CreatedAt, &md.DistributedAt)
+		if err != nil {
+			return nil, err
+		}
+		mds = append(mds, &md)
+	}
+	return mds, nil
+}
+
+func (s *SQLite3Store) ReadLatestMintBatch(ctx context.Context) (uint64, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row := s.db.QueryRowContext(ctx, "SELECT batch FROM mint_distributions ORDER BY batch DESC LIMIT 1")
+	var batch uint64
+	err := row.Scan(&batch)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return batch, err == nil, err
+}
+
+func (s *SQLite3Store) MarkMintDistributed(ctx context.Context, batch uint64, day string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE mint_distributions SET works_day=?, distributed_at=? WHERE batch=? AND distributed_at IS NULL",
+		day, time.Now().UTC(), batch)
+	if err != nil {
+		return fmt.Errorf("UPDATE mint_distributions %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadActionResults(ctx context.Context, outputId string) ([]*mtg.Transaction, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	row := s.db.QueryRowContext(ctx, "SELECT transactions FROM action_results WHERE output_id=?", outputId)
+	var ts string
+	err := row.Scan(&ts)
+	if err == sql.ErrNoRows {
+		return nil, false
+	} else if err != nil {
+		panic(err)
+	}
+
+	tb, err := common.Base91Decode(ts)
+	if err != nil {
+		panic(ts)
+	}
+	txs, err := mtg.DeserializeTransactions(tb)
+	if err != nil {
+		panic(ts)
+	}
+	return txs, true
+}
+
+func (s *SQLite3Store) WriteActionResults(ctx context.Context, outputId string, txs []*mtg.Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ts := common.Base91Encode(mtg.SerializeTransactions(txs))
+	cols := []string{"output_id", "transactions", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("action_results", cols), outputId, ts, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("INSERT action_results %v", err)
+	}
+	return tx.Commit()
+}
@@ -2,8 +2,18 @@ package custodian
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
 
+	"github.com/MixinNetwork/mixin/logger"
+	mixinkernel "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/shopspring/decimal"
 )
 
 const (
@@ -18,30 +28,255 @@ const (
 	// then at some point, a random output will cause
 	// all signer nodes to finalize the works
 	CustodianActionVoteWorks = 3
-	// then the signer mtg send this action to keeper mtg
-	// the custodian will process this then
+	// any signer node could send this after its vote, and
+	// the works are finalized once enough votes received
 	CustodianActionFinalizeWorks = 4
 )
 
+type Configuration struct {
+	AppId           string
+	SignerAssetId   string
+	MixinRPC        string
+	SpendPrivateKey string
+	// the distributions are only accepted from the custodian mint account
+	MintAccount string
+	// only the node holding the custodian mint account forwards the mint
+	ForwardMint bool
+}
+
 type Worker struct {
-	store           *SQLite3Store
-	signerAssetId   string
-	keeperAssetId   string
-	observerAssetId string
+	store  *SQLite3Store
+	group  *mtg.Group
+	conf   *Configuration
+	client *mixin.Client
 }
 
-func NewWorker(s *SQLite3Store) *Worker {
+func NewWorker(s *SQLite3Store, group *mtg.Group, conf *Configuration, client *mixin.Client) *Worker {
 	return &Worker{
-		store: s,
+		store:  s,
+		group:  group,
+		conf:   conf,
+		client: client,
 	}
 }
 
 func (worker *Worker) ProcessOutput(ctx context.Context, out *mtg.Action) ([]*mtg.Transaction, string) {
-	return nil, ""
+	logger.Verbosef("custodian.ProcessOutput(%v)", out)
+	if out.SequencerCreatedAt.IsZero() {
+		panic(out.OutputId)
+	}
+	txs1 := worker.processActionWithPersistence(ctx, out)
+	txs2 := worker.processActionWithPersistence(ctx, out)
+	mtg.ReplayCheck(out, txs1, txs2, "", "")
+	return txs1, ""
+}
+
+func (worker *Worker) processActionWithPersistence(ctx context.Context, out *mtg.Action) []*mtg.Transaction {
+	txs, found := worker.store.ReadActionResults(ctx, out.OutputId)
+	if found {
+		return txs
+	}
+	txs = worker.processAction(ctx, out)
+	err := worker.store.WriteActionResults(ctx, out.OutputId, txs)
+	if err != nil {
+		panic(err)
+	}
+	return txs
+}
+
+func (worker *Worker) processAction(ctx context.Context, out *mtg.Action) []*mtg.Transaction {
+	a, memo := mtg.DecodeMixinExtraHEX(out.Extra)
+	if a != worker.conf.AppId {
+		panic(out.Extra)
+	}
+	if len(memo) < 9 {
+		return nil
+	}
+
+	switch out.AssetId {
+	case worker.conf.SignerAssetId:
+		members := worker.group.GetMembers()
+		if len(out.Senders) != 1 || !slices.Contains(members, out.Senders[0]) {
+			logger.Printf("invalid senders: %s", out.Senders)
+			return nil
+		}
+		day, err := decodeWorksDay(memo[1:])
+		logger.Printf("custodian.decodeWorksDay(%x) => %s %v", memo, day, err)
+		if err != nil || !day.Add(worksDay).Before(out.SequencerCreatedAt) {
+			return nil
+		}
+		switch memo[0] {
+		case CustodianActionVoteWorks:
+			worker.processVoteWorks(ctx, out, members, day, memo[9:])
+			return nil
+		case CustodianActionFinalizeWorks:
+			return worker.processFinalizeWorks(ctx, out, members, day)
+		}
+	case XINAssetId:
+		if memo[0] != CustodianActionDistribute || len(memo) != 41 {
+			return nil
+		}
+		if len(out.Senders) != 1 || out.Senders[0] != worker.conf.MintAccount {
+			logger.Printf("invalid senders: %s", out.Senders)
+			return nil
+		}
+		batch := binary.BigEndian.Uint64(memo[1:9])
+		if !worker.checkKernelMint(ctx, out, batch, hex.EncodeToString(memo[9:])) {
+			return nil
+		}
+		return worker.processMintDistribution(ctx, out, batch)
+	}
+	return nil
+}
+
+func (worker *Worker) processVoteWorks(ctx context.Context, out *mtg.Action, members []string, day time.Time, works []byte) {
+	if len(works) != len(members) {
+		return
+	}
+	key := day.Format(worksDayFormat)
+	dw, err := worker.store.ReadDailyWorks(ctx, key)
+	if err != nil {
+		panic(err)
+	}
+	if dw != nil {
+		return
+	}
+	err = worker.store.WriteWorksVoteIfNotExists(ctx, key, out.Senders[0], works, out.OutputId)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// the works of a day are finalized when enough members have voted, and all
+// pending mint distributions are distributed with the finalized works
+func (worker *Worker) processFinalizeWorks(ctx context.Context, out *mtg.Action, members []string, day time.Time) []*mtg.Transaction {
+	key := day.Format(worksDayFormat)
+	dw, err := worker.store.ReadDailyWorks(ctx, key)
+	if err != nil {
+		panic(err)
+	}
+	if dw != nil {
+		return nil
+	}
+	votes, err := worker.store.ListWorksVotes(ctx, key)
+	if err != nil {
+		panic(err)
+	}
+	var count int
+	for _, m := range members {
+		if votes[m] != nil {
+			count = count + 1
+		}
+	}
+	if count < worker.group.GetThreshold() {
+		return nil
+	}
+
+	dw = &DailyWorks{
+		Day:       key,
+		Members:   members,
+		Works:     aggregateWorks(members, votes),
+		OutputId:  out.OutputId,
+		CreatedAt: out.SequencerCreatedAt,
+	}
+	err = worker.store.WriteDailyWorksIfNotExists(ctx, dw)
+	if err != nil {
+		panic(err)
+	}
+	latest, err := worker.store.ReadLatestDailyWorks(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return worker.distributePendingMints(ctx, out, latest)
+}
+
+// checkKernelMint ensures the batch is a kernel mint, and the distributed
+// amount is not more than the mint, so a batch can't be taken in advance
+func (worker *Worker) checkKernelMint(ctx context.Context, out *mtg.Action, batch uint64, hash string) bool {
+	ver, err := worker.group.ReadKernelTransactionUntilSufficient(ctx, hash)
+	logger.Printf("group.ReadKernelTransactionUntilSufficient(%s) => %v %v", hash, ver, err)
+	if err != nil {
+		panic(hash)
+	}
+	if ver == nil || len(ver.Inputs) != 1 || ver.Inputs[0].Mint == nil {
+		return false
+	}
+	mint := ver.Inputs[0].Mint
+	if mint.Batch != batch {
+		return false
+	}
+	return out.Amount.Cmp(decimal.RequireFromString(mint.Amount.String())) <= 0
+}
+
+func (worker *Worker) processMintDistribution(ctx context.Context, out *mtg.Action, batch uint64) []*mtg.Transaction {
+	err := worker.store.WriteMintDistributionIfNotExists(ctx, batch, out.Amount.String(), out.OutputId)
+	if err != nil {
+		panic(err)
+	}
+	dw, err := worker.store.ReadLatestDailyWorks(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if dw == nil {
+		return nil
+	}
+	return worker.distributePendingMints(ctx, out, dw)
+}
+
+func (worker *Worker) distributePendingMints(ctx context.Context, out *mtg.Action, dw *DailyWorks) []*mtg.Transaction {
+	// keep the mints pending until some works finalized
+	if slices.Max(dw.Works) == 0 {
+		return nil
+	}
+	mds, err := worker.store.ListPendingMintDistributions(ctx, 100)
+	if err != nil {
+		panic(err)
+	}
+	var txs []*mtg.Transaction
+	for _, md := range mds {
+		amount := decimal.RequireFromString(md.Amount)
+		if !common.CheckTestEnvironment(ctx) {
+			balance := out.CheckAssetBalanceAt(ctx, XINAssetId)
+			if balance.Cmp(amount) < 0 {
+				logger.Printf("custodian.distributePendingMints(%d) => %s %s", md.Batch, balance, amount)
+				break
+			}
+		}
+		txs = append(txs, worker.buildMintTransactions(ctx, out, md.Batch, amount, dw)...)
+		err = worker.store.MarkMintDistributed(ctx, md.Batch, dw.Day)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return txs
+}
+
+// each member receives the mint proportional to its finalized works, and
+// the remaining dust stays in the group for the next distribution
+func (worker *Worker) buildMintTransactions(ctx context.Context, out *mtg.Action, batch uint64, amount decimal.Decimal, dw *DailyWorks) []*mtg.Transaction {
+	var total int64
+	for _, w := range dw.Works {
+		total = total + int64(w)
+	}
+
+	var txs []*mtg.Transaction
+	for i, m := range dw.Members {
+		share := amount.Mul(decimal.NewFromInt(int64(dw.Works[i]))).Div(decimal.NewFromInt(total)).RoundFloor(8)
+		if !share.IsPositive() {
+			continue
+		}
+		traceId := common.UniqueId(fmt.Sprintf("CUSTODIAN:MINT:%d", batch), m)
+		traceId = common.UniqueId(worker.group.GenesisId(), traceId)
+		tx := out.BuildTransaction(ctx, traceId, "", XINAssetId, share.String(), "", []string{m}, 1)
+		txs = append(txs, tx)
+	}
+	return txs
 }
 
 func (worker *Worker) Boot(ctx context.Context) {
-	go worker.loopKernelMintDistributions(ctx)
+	if worker.conf.ForwardMint {
+		go worker.loopKernelMintDistributions(ctx)
+	}
 }
 
 func (worker *Worker) handleRefreshKey() {
@@ -51,9 +286,45 @@ func (worker *Worker) handleRefreshKey() {
 	// custodian use public derivation of spend key
 }
 
+// loopKernelMintDistributions forwards each new kernel mint batch received
+// by the custodian account to the group with the distribute action
 func (worker *Worker) loopKernelMintDistributions(ctx context.Context) {
-	//for {
-	// loop read mint distributions to the custodian key
-	// for new distribution, send the mint to keeper MTG with custodian action distribute
-	//}
+	for {
+		time.Sleep(time.Minute)
+		offset, found, err := worker.store.ReadLatestMintBatch(ctx)
+		if err != nil {
+			panic(err)
+		}
+		if found {
+			offset = offset + 1
+		}
+		mints, err := mixinkernel.RPCListMintDistributions(ctx, worker.conf.MixinRPC, offset, 10)
+		logger.Printf("mixin.RPCListMintDistributions(%d) => %d %v", offset, len(mints), err)
+		if err != nil {
+			continue
+		}
+		for _, m := range mints {
+			if m.Batch < offset {
+				continue
+			}
+			err = worker.forwardMintDistribution(ctx, m)
+			logger.Printf("custodian.forwardMintDistribution(%v) => %v", m, err)
+			if err != nil {
+				break
+			}
+		}
+	}
+}
+
+func (worker *Worker) forwardMintDistribution(ctx context.Context, m *mixinkernel.RPCMintDistribution) error {
+	amount, err := decimal.NewFromString(m.Amount)
+	if err != nil || !amount.IsPositive() {
+		return fmt.Errorf("invalid mint amount %s", m.Amount)
+	}
+	receivers := worker.group.GetMembers()
+	threshold := worker.group.GetThreshold()
+	traceId := common.UniqueId(fmt.Sprintf("CUSTODIAN:MINT:%d", m.Batch), m.Transaction)
+	memo := mtg.EncodeMixinExtraBase64(worker.conf.AppId, EncodeMintDistribution(m.Batch, m.Transaction))
+	_, err = common.SendTransactionUntilSufficient(ctx, worker.client, []string{worker.client.ClientID}, 1, receivers, threshold, amount, traceId, XINAssetId, memo, worker.conf.SpendPrivateKey)
+	return err
 }
@@ -0,0 +1,214 @@
+package custodian
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/pelletier/go-toml"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustodianWorker(t *testing.T) {
+	require := require.New(t)
+	ctx, worker, md := testPrepareWorker(require)
+	members := worker.group.GetMembers()
+	require.Len(members, 4)
+	require.Equal(3, worker.group.GetThreshold())
+
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	now := day.Add(25 * time.Hour)
+	key := day.Format(worksDayFormat)
+
+	out := testBuildVote(worker, members[0], EncodeWorksVote(day, []byte{0, 255, 128, 64}), now)
+	txs, _ := worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	out = testBuildVote(worker, members[1], EncodeWorksVote(day, []byte{255, 0, 100, 60}), now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	out = testBuildVote(worker, "invalid-member", EncodeWorksVote(day, []byte{255, 255, 255, 0}), now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	out = testBuildVote(worker, members[3], EncodeWorksVote(day, []byte{255, 255, 255, 0}), day.Add(time.Hour))
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	votes, err := worker.store.ListWorksVotes(ctx, key)
+	require.Nil(err)
+	require.Len(votes, 2)
+
+	out = testBuildVote(worker, members[0], EncodeFinalizeWorks(day), now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	dw, err := worker.store.ReadDailyWorks(ctx, key)
+	require.Nil(err)
+	require.Nil(dw)
+
+	out = testBuildMint(ctx, require, worker, md, 1, "10", now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+
+	out = testBuildVote(worker, members[2], EncodeWorksVote(day, []byte{250, 250, 0, 70}), now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	out = testBuildVote(worker, members[1], EncodeFinalizeWorks(day), now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 4)
+	dw, err = worker.store.ReadDailyWorks(ctx, key)
+	require.Nil(err)
+	require.Equal(members, dw.Members)
+	require.Equal([]byte{250, 250, 100, 64}, dw.Works)
+	testCheckMintTransactions(require, txs, members, "10", []string{"3.76506024", "3.76506024", "1.50602409", "0.96385542"})
+	replay, _ := worker.ProcessOutput(ctx, out)
+	require.Equal(mtg.SerializeTransactions(txs), mtg.SerializeTransactions(replay))
+
+	out = testBuildVote(worker, members[3], EncodeWorksVote(day, []byte{0, 0, 0, 0}), now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	votes, err = worker.store.ListWorksVotes(ctx, key)
+	require.Nil(err)
+	require.Len(votes, 3)
+
+	out = testBuildMint(ctx, require, worker, md, 5, "1", now)
+	out.Senders = []string{members[1]}
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	out = testBuildAction(worker, XINAssetId, "1", EncodeMintDistribution(5, testMintTransaction(6)), now)
+	out.Senders = []string{worker.conf.MintAccount}
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	out = testBuildMint(ctx, require, worker, md, 5, "1", now)
+	out.Amount = decimal.RequireFromString("1.1")
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	batch, found, err := worker.store.ReadLatestMintBatch(ctx)
+	require.Nil(err)
+	require.True(found)
+	require.Equal(uint64(1), batch)
+
+	out = testBuildMint(ctx, require, worker, md, 2, "1", now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 4)
+	testCheckMintTransactions(require, txs, members, "1", []string{"0.37650602", "0.37650602", "0.1506024", "0.09638554"})
+	out = testBuildMint(ctx, require, worker, md, 2, "1", now)
+	txs, _ = worker.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	batch, found, err = worker.store.ReadLatestMintBatch(ctx)
+	require.Nil(err)
+	require.True(found)
+	require.Equal(uint64(2), batch)
+	mds, err := worker.store.ListPendingMintDistributions(ctx, 10)
+	require.Nil(err)
+	require.Len(mds, 0)
+}
+
+func TestNormalizeWorks(t *testing.T) {
+	require := require.New(t)
+	require.Equal([]byte{0, 255, 127, 0}, NormalizeWorks([]int{0, 4, 2, 0}))
+	require.Equal([]byte{0, 0, 0}, NormalizeWorks([]int{0, 0, 0}))
+
+	members := []string{"a", "b", "c"}
+	votes := map[string][]byte{
+		"a": {255, 255, 255},
+		"b": {10, 0, 20},
+	}
+	require.Equal([]byte{10, 255, 20}, aggregateWorks(members, votes))
+}
+
+func testCheckMintTransactions(require *require.Assertions, txs []*mtg.Transaction, members []string, amount string, shares []string) {
+	total := decimal.Zero
+	for i, tx := range txs {
+		require.Equal(XINAssetId, tx.AssetId)
+		require.Equal([]string{members[i]}, tx.Receivers)
+		require.Equal(1, tx.Threshold)
+		require.Equal(shares[i], tx.Amount)
+		total = total.Add(decimal.RequireFromString(tx.Amount))
+	}
+	require.True(total.LessThanOrEqual(decimal.RequireFromString(amount)))
+}
+
+func testBuildVote(worker *Worker, sender string, extra []byte, createdAt time.Time) *mtg.Action {
+	out := testBuildAction(worker, worker.conf.SignerAssetId, "1", extra, createdAt)
+	out.Senders = []string{sender}
+	return out
+}
+
+// testBuildMint writes the kernel mint transaction of the batch to the group
+// cache, and builds the distribution forwarded by the custodian mint account
+func testBuildMint(ctx context.Context, require *require.Assertions, worker *Worker, md *mtg.SQLite3Store, batch uint64, amount string, createdAt time.Time) *mtg.Action {
+	tx := mc.NewTransactionV5(mc.XINAssetId)
+	tx.Inputs = []*mc.Input{{Mint: &mc.MintData{
+		Group:  "UNIVERSAL",
+		Batch:  batch,
+		Amount: mc.NewIntegerFromString(amount),
+	}}}
+	hash := testMintTransaction(batch)
+	val := base64.RawURLEncoding.EncodeToString(tx.AsVersioned().Marshal())
+	err := md.WriteCache(ctx, fmt.Sprintf("readKernelTransactionUntilSufficient(%s)", hash), val)
+	require.Nil(err)
+
+	out := testBuildAction(worker, XINAssetId, amount, EncodeMintDistribution(batch, hash), createdAt)
+	out.Senders = []string{worker.conf.MintAccount}
+	return out
+}
+
+func testMintTransaction(batch uint64) string {
+	return crypto.Sha256Hash([]byte(fmt.Sprintf("MINT:%d", batch))).String()
+}
+
+func testBuildAction(worker *Worker, assetId, amount string, extra []byte, createdAt time.Time) *mtg.Action {
+	memo := mtg.EncodeMixinExtraBase64(worker.conf.AppId, extra)
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:           uuid.Must(uuid.NewV4()).String(),
+			AppId:              worker.conf.AppId,
+			AssetId:            assetId,
+			Extra:              hex.EncodeToString([]byte(memo)),
+			Amount:             decimal.RequireFromString(amount),
+			SequencerCreatedAt: createdAt,
+		},
+	}
+	out.TestAttachActionToGroup(worker.group)
+	return out
+}
+
+func testPrepareWorker(require *require.Assertions) (context.Context, *Worker, *mtg.SQLite3Store) {
+	ctx := common.EnableTestEnvironment(context.Background())
+
+	f, _ := os.ReadFile("../config/example.toml")
+	var conf struct {
+		Signer struct {
+			AssetId string             `toml:"asset-id"`
+			MTG     *mtg.Configuration `toml:"mtg"`
+		} `toml:"signer"`
+	}
+	err := toml.Unmarshal(f, &conf)
+	require.Nil(err)
+	conf.Signer.MTG.App.AppId = conf.Signer.MTG.Genesis.Members[0]
+
+	root, err := os.MkdirTemp("", "safe-custodian-test")
+	require.Nil(err)
+	md, err := mtg.OpenSQLite3Store(root + "/mtg.sqlite3")
+	require.Nil(err)
+	group, err := mtg.BuildGroup(ctx, md, conf.Signer.MTG)
+	require.Nil(err)
+	group.EnableDebug()
+
+	store, err := OpenSQLite3Store(root + "/custodian.sqlite3")
+	require.Nil(err)
+	worker := NewWorker(store, group, &Configuration{
+		AppId:         "c1ce2a2c-5f0a-3aa7-a36b-8c8d9b0c1f43",
+		SignerAssetId: conf.Signer.AssetId,
+		MintAccount:   conf.Signer.MTG.Genesis.Members[0],
+	}, nil)
+	return ctx, worker, md
+}
@@ -0,0 +1,85 @@
+package custodian
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	worksDayFormat = "2006-01-02"
+	worksDay       = 24 * time.Hour
+)
+
+// NormalizeWorks scales the works of all members to a byte each, so that
+// the member with the most works has 255
+func NormalizeWorks(works []int) []byte {
+	norms := make([]byte, len(works))
+	max := slices.Max(works)
+	if max <= 0 {
+		return norms
+	}
+	for i, w := range works {
+		norms[i] = byte(255 * w / max)
+	}
+	return norms
+}
+
+func EncodeWorksVote(day time.Time, works []byte) []byte {
+	extra := []byte{CustodianActionVoteWorks}
+	extra = binary.BigEndian.AppendUint64(extra, uint64(day.Unix()))
+	return append(extra, works...)
+}
+
+func EncodeFinalizeWorks(day time.Time) []byte {
+	extra := []byte{CustodianActionFinalizeWorks}
+	return binary.BigEndian.AppendUint64(extra, uint64(day.Unix()))
+}
+
+// EncodeMintDistribution includes the kernel mint transaction hash, so that
+// the group could verify the batch and amount against the kernel
+func EncodeMintDistribution(batch uint64, transaction string) []byte {
+	hash, err := hex.DecodeString(transaction)
+	if err != nil || len(hash) != 32 {
+		panic(transaction)
+	}
+	extra := []byte{CustodianActionDistribute}
+	extra = binary.BigEndian.AppendUint64(extra, batch)
+	return append(extra, hash...)
+}
+
+func decodeWorksDay(extra []byte) (time.Time, error) {
+	if len(extra) < 8 {
+		return time.Time{}, fmt.Errorf("invalid works day %x", extra)
+	}
+	day := time.Unix(int64(binary.BigEndian.Uint64(extra[:8])), 0).UTC()
+	if !day.Truncate(worksDay).Equal(day) {
+		return time.Time{}, fmt.Errorf("invalid works day %s", day)
+	}
+	return day, nil
+}
+
+// aggregateWorks takes the median of the votes from the other members for
+// each member, so a minority of members can't raise their own works or
+// lower the works of others
+func aggregateWorks(members []string, votes map[string][]byte) []byte {
+	works := make([]byte, len(members))
+	for i, m := range members {
+		var vals []byte
+		for _, voter := range members {
+			vote := votes[voter]
+			if voter == m || vote == nil {
+				continue
+			}
+			vals = append(vals, vote[i])
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		slices.Sort(vals)
+		works[i] = vals[(len(vals)-1)/2]
+	}
+	return works
+}
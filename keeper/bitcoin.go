@@ -2,20 +2,23 @@ package keeper
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/binary"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
-	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
@@ -179,7 +182,7 @@ func (node *Node) processBitcoinSafeCloseAccount(ctx context.Context, req *commo
 			TransactionHash: txHash,
 			InputIndex:      idx,
 			Signer:          safe.Signer,
-			Curve:           req.Curve,
+			Curve:           bitcoinSafeSignerCurve(safe),
 			Message:         hex.EncodeToString(opsbt.SigHash(idx)),
 			State:           common.RequestStateInitial,
 			CreatedAt:       req.CreatedAt,
@@ -320,8 +323,18 @@ func (node *Node) processBitcoinSafeProposeAccount(ctx context.Context, req *com
 		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
 	}
 	path := bitcoinDefaultDerivationPath()
+	builder := node.buildBitcoinWitnessAccountWithDerivation
+	if req.Curve == common.CurveSecp256k1SchnorrBitcoin {
+		err = bitcoin.VerifyHolderKey(req.Holder)
+		logger.Printf("bitcoin.VerifyHolderKey(%s) => %v", req.Holder, err)
+		if err != nil {
+			return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+		}
+		path = taprootDefaultDerivationPath()
+		builder = node.buildBitcoinTaprootAccountWithDerivation
+	}
 
-	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, req.Holder, signer, observer, path, arp.Timelock, chain)
+	wsa, err := builder(ctx, req.Holder, signer, observer, path, arp.Timelock, chain)
 	logger.Verbosef("node.buildBitcoinWitnessAccountWithDerivation(%v) => %v %v", req, wsa, err)
 	if err != nil {
 		panic(err)
@@ -478,6 +491,8 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
 		assetId = common.SafeLitecoinChainId
+	case common.SafeChainBitcoinCash:
+		assetId = common.SafeBitcoinCashChainId
 	default:
 		panic(safe.Chain)
 	}
@@ -487,10 +502,10 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	if err != nil {
 		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
 	}
-	if meta.Chain != common.SafeChainPolygon {
+	if meta.Chain != ethereum.BondChain().Chain {
 		return node.failRequest(ctx, req, "")
 	}
-	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.evmRPC(ethereum.BondChain().Chain), meta.AssetKey)
 	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
 	if err != nil || deployed.Sign() <= 0 {
 		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
@@ -527,9 +542,15 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	if err != nil {
 		panic(fmt.Errorf("store.ListAllBitcoinUTXOsForHolder(%s) => %v", req.Holder, err))
 	}
-	switch extra[0] {
+	flag := extra[0] &^ common.FlagProposeCoinControl
+	coinControl := extra[0]&common.FlagProposeCoinControl != 0
+	switch flag {
 	case common.FlagProposeNormalTransaction:
+	case common.FlagProposeBatchTransaction:
 	case common.FlagProposeRecoveryTransaction:
+		if coinControl {
+			return node.failRequest(ctx, req, "")
+		}
 		for _, input := range mainInputs {
 			input.RouteBackup = true
 		}
@@ -550,43 +571,58 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	if info == nil || info.Chain != safe.Chain {
 		return node.failRequest(ctx, req, "")
 	}
+	extra = extra[16:]
+	if coinControl {
+		mainInputs, extra = node.selectBitcoinCoinControlInputs(ctx, mainInputs, extra)
+		logger.Printf("node.selectBitcoinCoinControlInputs(%v) => %d", req, len(mainInputs))
+		if len(mainInputs) == 0 {
+			return node.failRequest(ctx, req, "")
+		}
+	}
 
 	var outputs []*bitcoin.Output
 	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
-	if len(extra[16:]) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra[16:]) {
+	if len(extra) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra) {
 		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
 		extra := stx.Extra
-		var recipients [][2]string // TODO better encoding
-		err = json.Unmarshal(extra, &recipients)
+		recipients, err := common.DecodeRecipients(extra)
+		logger.Printf("common.DecodeRecipients(%x) => %d %v", extra, len(recipients), err)
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
 		for _, rp := range recipients {
-			script, err := bitcoin.ParseAddress(rp[0], safe.Chain)
-			logger.Printf("bitcoin.ParseAddress(%s, %d) => %x %v", string(extra), safe.Chain, script, err)
-			if err != nil {
+			if len(rp.Memo) > 0 {
+				return node.failRequest(ctx, req, "")
+			}
+			if rp.AssetId != "" && rp.AssetId != common.SafeChainAssetId(safe.Chain) {
 				return node.failRequest(ctx, req, "")
 			}
-			amt, err := decimal.NewFromString(rp[1])
+			script, err := bitcoin.ParseAddress(rp.Address, safe.Chain)
+			logger.Printf("bitcoin.ParseAddress(%s, %d) => %x %v", rp.Address, safe.Chain, script, err)
 			if err != nil {
 				return node.failRequest(ctx, req, "")
 			}
-			if amt.Cmp(plan.TransactionMinimum) < 0 {
+			if rp.Amount.Exponent() < -bitcoin.ValuePrecision {
+				return node.failRequest(ctx, req, "")
+			}
+			if rp.Amount.Cmp(plan.TransactionMinimum) < 0 {
 				return node.failRequest(ctx, req, "")
 			}
 			outputs = append(outputs, &bitcoin.Output{
-				Address: rp[0],
-				Satoshi: bitcoin.ParseSatoshi(amt.String()),
+				Address: rp.Address,
+				Satoshi: bitcoin.ParseSatoshi(rp.Amount.String()),
 			})
 		}
+	} else if flag == common.FlagProposeBatchTransaction {
+		return node.failRequest(ctx, req, "")
 	} else {
-		script, err := bitcoin.ParseAddress(string(extra[16:]), safe.Chain)
+		script, err := bitcoin.ParseAddress(string(extra), safe.Chain)
 		logger.Printf("bitcoin.ParseAddress(%s, %d) => %x %v", string(extra), safe.Chain, script, err)
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
 		outputs = []*bitcoin.Output{{
-			Address: string(extra[16:]),
+			Address: string(extra),
 			Satoshi: bitcoin.ParseSatoshi(req.Amount.String()),
 		}}
 	}
@@ -600,7 +636,16 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 		}
 		total = total.Add(amt)
 	}
-	if len(outputs) > 256 || !total.Equal(req.Amount) {
+	if !total.Equal(req.Amount) {
+		return node.failRequest(ctx, req, "")
+	}
+	if flag != common.FlagProposeRecoveryTransaction && !node.checkSpendingPolicy(ctx, req, safe, assetId, recipients) {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if flag == common.FlagProposeBatchTransaction {
+		return node.processBitcoinSafeProposeBatch(ctx, req, safe, assetId, mainInputs, outputs)
+	}
+	if len(outputs) > 256 {
 		return node.failRequest(ctx, req, "")
 	}
 
@@ -649,6 +694,205 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	return txs, ""
 }
 
+// A transaction of a batch is only approved with the holder signature of the
+// batch digest, which is encoded after the holder signed transaction, so that
+// the holder can't approve only a part of the batch.
+func (node *Node) verifyBitcoinBatchApproval(ctx context.Context, batchId string, tx *store.Transaction, extra []byte) []byte {
+	items, err := common.DecodeBatchItems(extra)
+	if err != nil || len(items) != 2 {
+		return nil
+	}
+	txs, err := node.store.ListTransactionsByBatch(ctx, batchId)
+	if err != nil {
+		panic(fmt.Errorf("store.ListTransactionsByBatch(%s) => %v", batchId, err))
+	}
+	hashes := make([]string, len(txs))
+	for i, t := range txs {
+		hashes[i] = t.TransactionHash
+	}
+	msg := bitcoin.HashBatchForSignature(batchId, hashes, tx.Chain)
+	err = bitcoin.VerifySignatureDER(tx.Holder, msg, items[1])
+	logger.Printf("bitcoin.VerifySignatureDER(%s, %x) => %v", batchId, msg, err)
+	if err != nil {
+		return nil
+	}
+	return items[0]
+}
+
+// A batch proposal pays a recipients list too large for a single transaction,
+// it is split into transactions within the standard weight, each of them
+// written as a normal proposal with its own request id derived from the
+// batch request id, and proposed to the observer one by one.
+func (node *Node) processBitcoinSafeProposeBatch(ctx context.Context, req *common.Request, safe *store.Safe, assetId string, mainInputs []*bitcoin.Input, outputs []*bitcoin.Output) ([]*mtg.Transaction, string) {
+	batch, err := bitcoin.BuildPartiallySignedBatch(mainInputs, outputs, req.Operation().IdBytes(), safe.Chain)
+	logger.Printf("bitcoin.BuildPartiallySignedBatch(%v) => %d %v", req, len(batch), err)
+	if bitcoin.IsInsufficientInputError(err) {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if err != nil {
+		panic(fmt.Errorf("bitcoin.BuildPartiallySignedBatch(%v) => %v", req, err))
+	}
+
+	var txs []*mtg.Transaction
+	var trxs []*store.Transaction
+	var utxos [][]*store.TransactionInput
+	for i, bt := range batch {
+		extra := bt.PSBT.Marshal()
+		stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+		if stx == nil {
+			return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+		}
+		txs = append(txs, stx)
+
+		rid := store.BatchTransactionRequestId(req.Id, i)
+		typ := byte(common.ActionBitcoinSafeProposeTransaction)
+		crv := common.SafeChainCurve(safe.Chain)
+		t := node.buildObserverResponseWithStorageTraceId(ctx, rid, req.Output, typ, crv, stx.TraceId)
+		if t == nil {
+			return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+		}
+		txs = append(txs, t)
+
+		recipients := make([]map[string]string, len(bt.Outputs))
+		for j, out := range bt.Outputs {
+			amt := decimal.New(out.Satoshi, -bitcoin.ValuePrecision)
+			recipients[j] = map[string]string{
+				"receiver": out.Address, "amount": amt.String(),
+			}
+		}
+		data := common.MarshalJSONOrPanic(recipients)
+		trxs = append(trxs, &store.Transaction{
+			TransactionHash: bt.PSBT.Hash(),
+			RawTransaction:  hex.EncodeToString(extra),
+			Holder:          req.Holder,
+			Chain:           safe.Chain,
+			AssetId:         assetId,
+			State:           common.RequestStateInitial,
+			Data:            string(data),
+			RequestId:       rid,
+			CreatedAt:       req.CreatedAt,
+			UpdatedAt:       req.CreatedAt,
+		})
+		utxos = append(utxos, store.TransactionInputsFromBitcoin(bt.Inputs))
+	}
+
+	err = node.store.WriteTransactionBatchWithRequest(ctx, trxs, utxos, txs, req)
+	logger.Printf("store.WriteTransactionBatchWithRequest(%v, %d) => %v", req, len(trxs), err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// A consolidation sweeps the smallest outputs of a fragmented safe back to
+// the safe address itself, so later proposals stay within MaxUnspentUtxo. It
+// is requested by the observer, either for the holder or on its own when the
+// fee rate is low, and still needs the holder approval as a normal proposal.
+// The transaction has no recipients, thus its output is treated as change.
+func (node *Node) processBitcoinSafeConsolidate(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 18 {
+		return node.failRequest(ctx, req, "")
+	}
+	iid, err := uuid.FromBytes(extra[:16])
+	if err != nil || iid.String() == uuid.Nil.String() {
+		return node.failRequest(ctx, req, "")
+	}
+	info, err := node.store.ReadNetworkInfo(ctx, iid.String())
+	logger.Printf("store.ReadNetworkInfo(%s) => %v %v", iid.String(), info, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadNetworkInfo(%s) => %v", iid.String(), err))
+	}
+	if info == nil || info.Chain != safe.Chain {
+		return node.failRequest(ctx, req, "")
+	}
+	count := int(binary.BigEndian.Uint16(extra[16:]))
+	if count < SafeConsolidateMinimum || count > bitcoin.MaxUnspentUtxo {
+		return node.failRequest(ctx, req, "")
+	}
+
+	mainInputs, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllBitcoinUTXOsForHolder(%s) => %v", req.Holder, err))
+	}
+	if len(mainInputs) < SafeConsolidateMinimum {
+		return node.failRequest(ctx, req, "")
+	}
+	slices.SortStableFunc(mainInputs, func(a, b *bitcoin.Input) int {
+		return cmp.Compare(a.Satoshi, b.Satoshi)
+	})
+	if len(mainInputs) > count {
+		mainInputs = mainInputs[:count]
+	}
+	var total int64
+	for _, in := range mainInputs {
+		total = total + in.Satoshi
+	}
+	outputs := []*bitcoin.Output{{Address: safe.Address, Satoshi: total}}
+
+	psbt, err := bitcoin.BuildPartiallySignedTransaction(mainInputs, outputs, req.Operation().IdBytes(), safe.Chain)
+	logger.Printf("bitcoin.BuildPartiallySignedTransaction(%v) => %v %v", req, psbt, err)
+	if err != nil {
+		panic(fmt.Errorf("bitcoin.BuildPartiallySignedTransaction(%v) => %v", req, err))
+	}
+	msgTx := psbt.UnsignedTx
+	if len(msgTx.TxOut) != 2 || msgTx.TxOut[1].Value != 0 {
+		panic(psbt.Hash())
+	}
+	receiver, err := bitcoin.ExtractPkScriptAddr(msgTx.TxOut[0].PkScript, safe.Chain)
+	if err != nil || receiver != safe.Address {
+		panic(fmt.Errorf("bitcoin.ExtractPkScriptAddr(%x) => %s %v", msgTx.TxOut[0].PkScript, receiver, err))
+	}
+
+	extra = psbt.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionBitcoinSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, t)
+
+	tx := &store.Transaction{
+		TransactionHash: psbt.Hash(),
+		RawTransaction:  hex.EncodeToString(extra),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         common.SafeChainAssetId(safe.Chain),
+		State:           common.RequestStateInitial,
+		Data:            "[]",
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	err = node.store.WriteTransactionWithRequest(ctx, tx, store.TransactionInputsFromBitcoin(mainInputs), txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
 func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
 	if req.Role != common.RequestRoleObserver {
 		panic(req.Role)
@@ -680,10 +924,20 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 	} else if tx.Holder != req.Holder {
 		return node.failRequest(ctx, req, "")
 	}
+	if node.checkSpendingPolicyDelayed(ctx, req, tx) {
+		return node.failRequest(ctx, req, "")
+	}
 
 	var ref crypto.Hash
 	copy(ref[:], extra[16:])
 	raw := node.readStorageExtraFromObserver(ctx, ref)
+	batchId, err := node.store.ReadTransactionBatchId(ctx, tx.TransactionHash)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransactionBatchId(%s) => %v", tx.TransactionHash, err))
+	}
+	if batchId != "" {
+		raw = node.verifyBitcoinBatchApproval(ctx, batchId, tx, raw)
+	}
 	signed := bitcoin.CheckTransactionPartiallySignedBy(hex.EncodeToString(raw), tx.Holder)
 	logger.Printf("bitcoin.CheckTransactionPartiallySignedBy(%x, %s) => %t", raw, tx.Holder, signed)
 	if !signed {
@@ -698,6 +952,7 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 		return node.failRequest(ctx, req, "")
 	}
 
+	var keySpend bool
 	var requests []*store.SignatureRequest
 	for idx := range msgTx.TxIn {
 		hash := psbt.SigHash(idx)
@@ -705,6 +960,14 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 		if !bytes.Equal(hash, hpsbt.SigHash(idx)) {
 			continue
 		}
+		message := hash
+		if psbt.IsTaprootKeySpendInput(idx) {
+			message = bitcoinTaprootKeySpendMessage(psbt, hpsbt, idx)
+			if message == nil {
+				return node.failRequest(ctx, req, "")
+			}
+			keySpend = true
+		}
 
 		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop)
 		logger.Printf("node.checkBitcoinUTXOSignatureRequired(%s, %d) => %t", pop.Hash.String(), pop.Index, required)
@@ -724,8 +987,8 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 			TransactionHash: tx.TransactionHash,
 			InputIndex:      idx,
 			Signer:          safe.Signer,
-			Curve:           req.Curve,
-			Message:         hex.EncodeToString(hash),
+			Curve:           bitcoinSafeSignerCurve(safe),
+			Message:         hex.EncodeToString(message),
 			State:           common.RequestStateInitial,
 			CreatedAt:       req.CreatedAt,
 			UpdatedAt:       req.CreatedAt,
@@ -734,6 +997,21 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 		requests = append(requests, sr)
 	}
 
+	// all inputs are signed in one signer session if there are many of them,
+	// and the taproot key spend messages are too large for the signer memo
+	if len(requests) > 1 || keySpend {
+		batches, txs := node.buildSignerBatchSignRequests(ctx, req, requests, safe.Path)
+		if len(txs) == 0 {
+			return node.failRequest(ctx, req, "")
+		}
+		err = node.store.WriteSignatureBatchesWithRequest(ctx, batches, requests, tx.TransactionHash, req, txs)
+		logger.Printf("store.WriteSignatureBatchesWithRequest(%s, %d, %d, %v) => %v", tx.TransactionHash, len(batches), len(requests), req, err)
+		if err != nil {
+			panic(err)
+		}
+		return txs, ""
+	}
+
 	txs := node.buildSignerSignRequests(ctx, req, requests, safe.Path)
 	if len(txs) == 0 {
 		return node.failRequest(ctx, req, "")
@@ -757,8 +1035,8 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	}
 	sig := req.ExtraBytes()
 	msg := common.DecodeHexOrPanic(old.Message)
-	err = bitcoin.VerifySignatureDER(spk, msg, sig)
-	logger.Printf("bitcoin.VerifySignatureDER(%v) => %v", req, err)
+	err = verifyBitcoinSafeSignature(safe, spk, msg, sig)
+	logger.Printf("bitcoin.VerifySignature(%v) => %v", req, err)
 	if err != nil {
 		return node.failRequest(ctx, req, "")
 	}
@@ -768,15 +1046,50 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	if err != nil {
 		panic(fmt.Errorf("store.FinishSignatureRequest(%s) => %v", req.Id, err))
 	}
+	return node.finishBitcoinSafeSignatures(ctx, req, safe, tx, spk)
+}
+
+func (node *Node) processBitcoinSafeBatchSignatureResponse(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, batch *store.SignatureBatch, sigs [][]byte) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+
+	spk, err := node.deriveBIP32WithPath(ctx, safe.Signer, common.DecodeHexOrPanic(safe.Path))
+	if err != nil {
+		panic(fmt.Errorf("node.deriveBIP32WithPath(%s, %s) => %v", safe.Signer, safe.Path, err))
+	}
+	for i, id := range batch.RequestIds {
+		sr, err := node.store.ReadSignatureRequest(ctx, id)
+		if err != nil || sr == nil {
+			panic(fmt.Errorf("store.ReadSignatureRequest(%s) => %v %v", id, sr, err))
+		}
+		msg := common.DecodeHexOrPanic(sr.Message)
+		err = verifyBitcoinSafeSignature(safe, spk, msg, sigs[i])
+		logger.Printf("bitcoin.VerifySignature(%v, %d) => %v", req, sr.InputIndex, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+	}
+
+	err = node.store.FinishSignatureBatch(ctx, batch, sigs, req)
+	logger.Printf("store.FinishSignatureBatch(%s) => %v", batch.BatchId, err)
+	if err != nil {
+		panic(fmt.Errorf("store.FinishSignatureBatch(%s) => %v", batch.BatchId, err))
+	}
+	return node.finishBitcoinSafeSignatures(ctx, req, safe, tx, spk)
+}
 
+// finishBitcoinSafeSignatures sets all the input signatures to the transaction
+// and responds it to the observer, or waits for the pending signatures
+func (node *Node) finishBitcoinSafeSignatures(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, spk string) ([]*mtg.Transaction, string) {
 	b := common.DecodeHexOrPanic(tx.RawTransaction)
 	spsbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
 	msgTx := spsbt.UnsignedTx
 
-	requests, err := node.store.ListAllSignaturesForTransaction(ctx, old.TransactionHash, common.RequestStatePending)
-	logger.Printf("store.ListAllSignaturesForTransaction(%s) => %d %v", old.TransactionHash, len(requests), err)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, tx.TransactionHash, common.RequestStatePending)
+	logger.Printf("store.ListAllSignaturesForTransaction(%s) => %d %v", tx.TransactionHash, len(requests), err)
 	if err != nil {
-		panic(fmt.Errorf("store.ListAllSignaturesForTransaction(%s) => %v", old.TransactionHash, err))
+		panic(fmt.Errorf("store.ListAllSignaturesForTransaction(%s) => %v", tx.TransactionHash, err))
 	}
 
 	for idx := range msgTx.TxIn {
@@ -792,18 +1105,20 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 		}
 		hash := spsbt.SigHash(idx)
 		msg := common.DecodeHexOrPanic(sr.Message)
-		if !bytes.Equal(hash, msg) {
+		if !bytes.Equal(hash, msg[:min(len(msg), len(hash))]) {
 			panic(sr.Message)
 		}
 		sig := common.DecodeHexOrPanic(sr.Signature.String)
-		err = bitcoin.VerifySignatureDER(spk, msg, sig)
+		if spsbt.IsTaprootKeySpendInput(idx) {
+			// the holder nonce is not in the keeper transaction
+			err = verifyBitcoinSafeSignature(safe, spk, msg, sig)
+		} else {
+			err = spsbt.VerifyInputSignature(idx, spk, sig)
+		}
 		if err != nil {
 			panic(sr.Signature.String)
 		}
-		spsbt.Inputs[idx].PartialSigs = []*psbt.PartialSig{{
-			PubKey:    common.DecodeHexOrPanic(spk),
-			Signature: sig,
-		}}
+		spsbt.SetInputSignature(idx, spk, sig)
 	}
 
 	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(spsbt.Marshal())))
@@ -812,7 +1127,7 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	}
 	txs := []*mtg.Transaction{stx}
 
-	id := common.UniqueId(old.TransactionHash, stx.TraceId)
+	id := common.UniqueId(tx.TransactionHash, stx.TraceId)
 	typ := byte(common.ActionBitcoinSafeApproveTransaction)
 	crv := common.SafeChainCurve(safe.Chain)
 	t := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ, crv, stx.TraceId)
@@ -822,14 +1137,56 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	txs = append(txs, t)
 
 	raw := hex.EncodeToString(spsbt.Marshal())
-	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, int64(len(msgTx.TxIn)), safe, nil, txs)
-	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, tx.TransactionHash, raw, req, int64(len(msgTx.TxIn)), safe, nil, txs)
+	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", tx.TransactionHash, raw, req, err)
 	if err != nil {
 		panic(err)
 	}
 	return txs, ""
 }
 
+// the key spend message is built from the keeper transaction, and only the
+// holder public nonce is taken from the holder approved transaction
+func bitcoinTaprootKeySpendMessage(psbt, hpsbt *bitcoin.PartiallySignedTransaction, idx int) []byte {
+	ks, err := psbt.TaprootKeySpend(idx)
+	if err != nil {
+		panic(err)
+	}
+	hks, err := hpsbt.TaprootKeySpend(idx)
+	logger.Printf("bitcoin.TaprootKeySpend(%d) => %v", idx, err)
+	if err != nil || !hks.Holder.IsEqual(ks.Holder) {
+		return nil
+	}
+	ks.HolderNonce = hks.HolderNonce
+	return ks.Message()
+}
+
+func verifyBitcoinSafeSignature(safe *store.Safe, spk string, msg, sig []byte) error {
+	switch {
+	case bitcoinSafeSignerCurve(safe) != common.CurveSecp256k1SchnorrBitcoin:
+		return bitcoin.VerifySignatureDER(spk, msg, sig)
+	case len(msg) == bitcoin.TaprootKeySpendMessageSize:
+		ks, err := bitcoin.ParseTaprootKeySpendMessage(msg, spk)
+		if err != nil {
+			return err
+		}
+		return ks.VerifySignerPartial(sig)
+	default:
+		return bitcoin.VerifySignatureSchnorr(spk, msg, sig)
+	}
+}
+
+func bitcoinSafeSignerCurve(safe *store.Safe) byte {
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(safe.Extra)
+	if err != nil {
+		panic(err)
+	}
+	if bitcoin.CheckTaprootScript(wsa.Script) {
+		return common.CurveSecp256k1SchnorrBitcoin
+	}
+	return common.SafeChainCurve(safe.Chain)
+}
+
 func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context, holder, signer, observer string, path []byte, timelock time.Duration, chain byte) (*bitcoin.WitnessScriptAccount, error) {
 	sdk, err := node.deriveBIP32WithPath(ctx, signer, path)
 	logger.Verbosef("bitcoin.DeriveBIP32(%s) => %s %v", signer, sdk, err)
@@ -844,10 +1201,24 @@ func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context,
 	return bitcoin.BuildWitnessScriptAccount(holder, sdk, odk, timelock, chain)
 }
 
+// the taproot signer key is x-only and not derivable, so taproot safes
+// use the zero path and all keys are used as they are
+func (node *Node) buildBitcoinTaprootAccountWithDerivation(ctx context.Context, holder, signer, observer string, path []byte, timelock time.Duration, chain byte) (*bitcoin.WitnessScriptAccount, error) {
+	odk, err := node.deriveBIP32WithPath(ctx, observer, path)
+	logger.Verbosef("bitcoin.DeriveBIP32(%s) => %s %v", observer, odk, err)
+	if err != nil {
+		return nil, fmt.Errorf("bitcoin.DeriveBIP32(%s) => %v", observer, err)
+	}
+	return bitcoin.BuildTaprootAccount(holder, signer, odk, timelock, chain)
+}
+
 func (node *Node) deriveBIP32WithPath(ctx context.Context, public string, path8 []byte) (string, error) {
 	if path8[0] > 3 {
 		panic(path8[0])
 	}
+	if path8[0] == 0 {
+		return public, nil
+	}
 	path32 := make([]uint32, path8[0])
 	for i := 0; i < int(path8[0]); i++ {
 		path32[i] = uint32(path8[1+i])
@@ -874,6 +1245,43 @@ func (node *Node) checkTransactionIndexSignaturePending(ctx context.Context, has
 	return old.CreatedAt.Add(SafeSignatureTimeout).After(req.CreatedAt), nil
 }
 
+// The coin control outpoints follow the network info id in the proposal
+// extra as count:1 | (hash:32 | index:4)*, and each of them must be an
+// unspent and not pending output of the safe.
+func (node *Node) selectBitcoinCoinControlInputs(ctx context.Context, mainInputs []*bitcoin.Input, extra []byte) ([]*bitcoin.Input, []byte) {
+	if len(extra) < 1 {
+		return nil, extra
+	}
+	count := int(extra[0])
+	extra = extra[1:]
+	if count == 0 || len(extra) < count*36 {
+		return nil, extra
+	}
+
+	var selected []*bitcoin.Input
+	for i := 0; i < count; i++ {
+		op := extra[i*36 : i*36+36]
+		hash := hex.EncodeToString(op[:32])
+		index := binary.BigEndian.Uint32(op[32:])
+		n := slices.IndexFunc(mainInputs, func(in *bitcoin.Input) bool {
+			return in.TransactionHash == hash && in.Index == index
+		})
+		if n < 0 || slices.Contains(selected, mainInputs[n]) {
+			return nil, extra
+		}
+		h, err := chainhash.NewHashFromStr(hash)
+		if err != nil {
+			panic(hash)
+		}
+		required := node.checkBitcoinUTXOSignatureRequired(ctx, *wire.NewOutPoint(h, index))
+		if !required {
+			return nil, extra
+		}
+		selected = append(selected, mainInputs[n])
+	}
+	return selected, extra[count*36:]
+}
+
 func (node *Node) checkBitcoinUTXOSignatureRequired(ctx context.Context, pop wire.OutPoint) bool {
 	utxo, _, _ := node.store.ReadBitcoinUTXO(ctx, pop.Hash.String(), int(pop.Index))
 	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script)
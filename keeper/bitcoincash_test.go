@@ -0,0 +1,166 @@
+package keeper
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinCashKeeper(t *testing.T) {
+	require := require.New(t)
+	ctx, node, _, mpc, _ := testBitcoinCashPrepare(require)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(byte(common.SafeChainBitcoinCash), safe.Chain)
+	require.Equal(common.SafeBitcoinCashChainId, common.SafeChainAssetId(safe.Chain))
+	require.Equal(mpc, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	require.True(strings.HasPrefix(safe.Address, "bitcoincash:p"))
+	require.Equal(testTimelockDuration, safe.Timelock)
+
+	script, err := bitcoin.ParseAddress(safe.Address, safe.Chain)
+	require.Nil(err)
+	addr, err := bitcoin.ExtractPkScriptAddr(script, safe.Chain)
+	require.Nil(err)
+	require.Equal(safe.Address, addr)
+	_, err = bitcoin.ParseAddress(safe.Address, common.SafeChainBitcoin)
+	require.NotNil(err)
+}
+
+func testBitcoinCashPrepare(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
+	logger.SetLevel(logger.INFO)
+	ctx, signers, _ := signer.TestPrepare(require)
+	mpc, cc := signer.TestCMPPrepareKeys(ctx, require, signers, common.CurveSecp256k1ECDSABitcoin)
+	chainCode := common.DecodeHexOrPanic(cc)
+
+	root, err := os.MkdirTemp("", "safe-keeper-test-")
+	require.Nil(err)
+	node, db := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
+
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := append([]byte{common.RequestRoleSigner}, chainCode...)
+	extra = append(extra, common.RequestFlagNone)
+	out := testBuildSignerOutput(node, id, mpc, common.OperationTypeKeygenOutput, extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	testSpareKeys(ctx, require, node, 0, 1, 0, common.CurveSecp256k1ECDSABitcoin)
+
+	id = uuid.Must(uuid.NewV4()).String()
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	occ := common.DecodeHexOrPanic(testBitcoinKeyObserverChainCode)
+	extra = append([]byte{common.RequestRoleObserver}, occ...)
+	extra = append(extra, common.RequestFlagNone)
+	out = testBuildObserverRequest(node, id, observer, common.ActionObserverAddKey, extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	testSpareKeys(ctx, require, node, 0, 1, 1, common.CurveSecp256k1ECDSABitcoin)
+
+	for i := 0; i < 10; i++ {
+		testBitcoinCashUpdateAccountPrice(ctx, require, node)
+	}
+	rid, address := testBitcoinCashProposeAccount(ctx, require, node, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
+	testBitcoinCashApproveAccount(ctx, require, node, mpc, observer, rid, address)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
+	return ctx, node, db, mpc, signers
+}
+
+func testBitcoinCashProposeAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string) (string, string) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	extra := testRecipient()
+	price := decimal.NewFromFloat(testAccountPriceAmount)
+	out := testBuildHolderRequestWithCurve(node, id, holder, common.ActionBitcoinSafeProposeAccount, testAccountPriceAssetId, extra, price, common.CurveSecp256k1ECDSABitcoinCash)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionBitcoinSafeProposeAccount)
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.True(strings.HasPrefix(wsa.Address, "bitcoincash:p"))
+	require.Equal(uint32(6), wsa.Sequence)
+
+	safe, err := node.store.ReadSafeProposal(ctx, id)
+	require.Nil(err)
+	require.Equal(id, safe.RequestId)
+	require.Equal(byte(common.SafeChainBitcoinCash), safe.Chain)
+	require.Equal(holder, safe.Holder)
+	require.Equal(signer, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	public, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holder, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoinCash)
+	require.Nil(err)
+	require.Equal(wsa.Address, public.Address)
+	require.Equal(public.Address, safe.Address)
+	btc, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holder, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(testSafeAddress, btc.Address)
+	require.Equal(btc.Script, public.Script)
+	require.Equal(byte(1), safe.Threshold)
+	require.Len(safe.Receivers, 1)
+	require.Equal(testSafeBondReceiverId, safe.Receivers[0])
+
+	return id, wsa.Address
+}
+
+func testBitcoinCashApproveAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string, rid, address string) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid, address)
+	hash := bitcoin.HashMessageForSignature(ms, common.SafeChainBitcoinCash)
+	hb, _ := hex.DecodeString(testBitcoinKeyHolderPrivate)
+	hp, _ := btcec.PrivKeyFromBytes(hb)
+	signature := ecdsa.Sign(hp, hash)
+	extra := uuid.FromStringOrNil(rid).Bytes()
+	extra = append(extra, signature.Serialize()...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeApproveAccount, extra, common.CurveSecp256k1ECDSABitcoinCash)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionBitcoinSafeApproveAccount)
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.Equal(address, wsa.Address)
+
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(id, safe.RequestId)
+	require.Equal(holder, safe.Holder)
+	require.Equal(signer, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	require.Equal(address, safe.Address)
+	require.Equal(byte(1), safe.Threshold)
+	require.Len(safe.Receivers, 1)
+	require.Equal(testSafeBondReceiverId, safe.Receivers[0])
+}
+
+func testBitcoinCashUpdateAccountPrice(ctx context.Context, require *require.Assertions, node *Node) {
+	id := uuid.Must(uuid.NewV4()).String()
+
+	extra := []byte{common.SafeChainBitcoinCash}
+	extra = append(extra, uuid.Must(uuid.FromString(testAccountPriceAssetId)).Bytes()...)
+	extra = binary.BigEndian.AppendUint64(extra, testAccountPriceAmount*100000000)
+	extra = binary.BigEndian.AppendUint64(extra, 10000)
+	dummy := testPublicKey(testBitcoinKeyDummyHolderPrivate)
+	out := testBuildObserverRequest(node, id, dummy, common.ActionObserverSetOperationParams, extra, common.CurveSecp256k1ECDSABitcoinCash)
+	testStep(ctx, require, node, out)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, common.SafeChainBitcoinCash, time.Now())
+	require.Nil(err)
+	require.Equal(testAccountPriceAssetId, plan.OperationPriceAsset)
+	require.Equal(fmt.Sprint(testAccountPriceAmount), plan.OperationPriceAmount.String())
+}
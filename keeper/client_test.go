@@ -0,0 +1,100 @@
+package keeper
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/client"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinKeeperClient(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	ap := &client.AccountProposal{
+		Chain:     common.SafeChainBitcoin,
+		Holder:    holder,
+		Timelock:  testTimelockDuration,
+		Threshold: 1,
+		Receivers: []string{testSafeBondReceiverId},
+	}
+	extra, err := ap.Extra()
+	require.Nil(err)
+	require.Equal(testRecipient(), extra)
+
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	bondId := testDeployBondContract(ctx, require, node, testSafeAddress, common.SafeBitcoinChainId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{
+		UnifiedOutput: *output,
+	})
+	input := &bitcoin.Input{
+		TransactionHash: "40e228e5a3cba99fd3fc5350a00bfeef8bafb760e26919ec74bca67776c90427",
+		Index:           0, Satoshi: 86560,
+	}
+	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 1)
+	input = &bitcoin.Input{
+		TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194",
+		Index:           0, Satoshi: 100000,
+	}
+	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 2)
+
+	rid := uuid.Must(uuid.NewV4()).String()
+	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	tp := &client.TransactionProposal{
+		Chain:         common.SafeChainBitcoin,
+		Holder:        holder,
+		Flag:          common.FlagProposeNormalTransaction,
+		NetworkInfoId: info.RequestId,
+		Receiver:      testTransactionReceiver,
+		Outpoints:     []*client.Outpoint{{Hash: input.TransactionHash, Index: input.Index}},
+	}
+	op, err := tp.Operation(rid)
+	require.Nil(err)
+	memo := client.EncodeMemo(node.conf.AppId, op)
+	testStep(ctx, require, node, &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			TransactionHash:    crypto.Sha256Hash([]byte(op.Id)).String(),
+			OutputId:           common.UniqueId(op.Id, "output"),
+			AppId:              node.conf.AppId,
+			AssetId:            bondId,
+			Extra:              hex.EncodeToString([]byte(memo)),
+			Amount:             decimal.NewFromFloat(0.000123),
+			SequencerCreatedAt: time.Now(),
+			Sequence:           sequence,
+		},
+	})
+
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	require.NotNil(tx)
+	require.Equal(common.RequestStateInitial, tx.State)
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	require.Len(psbt.UnsignedTx.TxIn, 1)
+	require.Equal(input.TransactionHash, psbt.UnsignedTx.TxIn[0].PreviousOutPoint.Hash.String())
+
+	hb, _ := hex.DecodeString(testBitcoinKeyHolderPrivate)
+	hp, _ := btcec.PrivKeyFromBytes(hb)
+	raw, nonces, err := client.SignBitcoinTransaction(hp, tx.RawTransaction)
+	require.Nil(err)
+	require.Len(nonces, 0)
+	signed, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	require.Equal(psbt.Hash(), signed.Hash())
+	for idx := range signed.UnsignedTx.TxIn {
+		sig := signed.Inputs[idx].PartialSigs[0]
+		require.Nil(bitcoin.VerifySignatureDER(holder, signed.SigHash(idx), sig.Signature))
+	}
+}
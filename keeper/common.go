@@ -8,6 +8,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -15,8 +16,10 @@ import (
 )
 
 const (
-	SafeSignatureTimeout  = 10 * time.Minute
-	SafeKeyBackupMaturity = 24 * time.Hour
+	SafeSignatureTimeout   = 10 * time.Minute
+	SafeKeyBackupMaturity  = 24 * time.Hour
+	SafeConsolidateMinimum = 32
+	SafePolicyCooldown     = 48 * time.Hour
 
 	SafeStateApproved = common.RequestStateDone
 	SafeStatePending  = common.RequestStatePending
@@ -27,6 +30,14 @@ func bitcoinDefaultDerivationPath() []byte {
 	return []byte{2, 0, 0, 0}
 }
 
+func taprootDefaultDerivationPath() []byte {
+	return []byte{0, 0, 0, 0}
+}
+
+func mixinDefaultDerivationPath() []byte {
+	return []byte{0, 0, 0, 0}
+}
+
 func ethereumDefaultDerivationPath() []byte {
 	return []byte{0, 0, 0, 0}
 }
@@ -76,7 +87,7 @@ func (node *Node) getBondAssetId(ctx context.Context, entry, assetId, holder str
 	if err != nil {
 		panic(assetKey)
 	}
-	safeAssetId := ethereum.GenerateAssetId(common.SafeChainPolygon, assetKey)
+	safeAssetId := ethereum.GenerateAssetId(ethereum.BondChain().Chain, assetKey)
 	return safeAssetId
 }
 
@@ -108,6 +119,17 @@ func (node *Node) verifySafeMessageSignatureWithHolderOrObserver(ctx context.Con
 				return err
 			}
 		}
+	case common.CurveEdwards25519Mixin:
+		msg := []byte(ms)
+		err := mixin.VerifySignature(safe.Holder, msg, sig)
+		logger.Printf("holder: mixin.VerifySignature(%s, %x) => %v", ms, sig, err)
+		if err != nil {
+			err = mixin.VerifySignature(safe.Observer, msg, sig)
+			logger.Printf("observer: mixin.VerifySignature(%s, %x) => %v", ms, sig, err)
+			if err != nil {
+				return err
+			}
+		}
 	default:
 		panic(safe.Chain)
 	}
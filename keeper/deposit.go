@@ -27,6 +27,7 @@ type Deposit struct {
 	AssetAddress string
 	Hash         string
 	Index        uint64
+	Mask         []byte
 	Amount       *big.Int
 }
 
@@ -43,15 +44,26 @@ func parseDepositExtra(req *common.Request) (*Deposit, error) {
 		panic(req.Id)
 	}
 	extra = extra[17:]
-	switch deposit.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(deposit.Chain) {
+	case common.SafeChainBitcoin:
 		deposit.Hash = hex.EncodeToString(extra[0:32])
 		deposit.Index = binary.BigEndian.Uint64(extra[32:40])
 		deposit.Amount = new(big.Int).SetBytes(extra[40:])
 		if !deposit.Amount.IsInt64() {
 			return nil, fmt.Errorf("invalid deposit amount %s", deposit.Amount.String())
 		}
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainMixin:
+		if len(extra) < 32+8+32+1 {
+			return nil, fmt.Errorf("invalid deposit extra %s", req.ExtraHEX)
+		}
+		deposit.Hash = hex.EncodeToString(extra[0:32])
+		deposit.Index = binary.BigEndian.Uint64(extra[32:40])
+		deposit.Mask = extra[40:72]
+		deposit.Amount = new(big.Int).SetBytes(extra[72:])
+		if !deposit.Amount.IsInt64() || deposit.Index > 255 {
+			return nil, fmt.Errorf("invalid deposit %s %d", deposit.Amount.String(), deposit.Index)
+		}
+	case common.SafeChainEthereum:
 		deposit.Hash = "0x" + hex.EncodeToString(extra[0:32])
 		deposit.AssetAddress = gc.BytesToAddress(extra[32:52]).Hex()
 		deposit.Index = binary.BigEndian.Uint64(extra[52:60])
@@ -95,14 +107,14 @@ func (node *Node) CreateHolderDeposit(ctx context.Context, req *common.Request)
 	if err != nil {
 		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", bondId.String(), err))
 	}
-	if bond.Decimals != 18 || bond.AssetId != safeAssetId || bond.Chain != common.SafeChainPolygon {
+	if bond.Decimals != 18 || bond.AssetId != safeAssetId || bond.Chain != ethereum.BondChain().Chain {
 		panic(bond.AssetId)
 	}
 	asset, err := node.fetchAssetMetaFromMessengerOrEthereum(ctx, deposit.Asset, deposit.AssetAddress, deposit.Chain)
 	if err != nil {
 		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", deposit.Asset, err))
 	}
-	if asset.Chain != safe.Chain {
+	if asset.Chain != safe.Chain && safe.Chain != common.SafeChainMixin {
 		panic(asset.AssetId)
 	}
 
@@ -114,10 +126,12 @@ func (node *Node) CreateHolderDeposit(ctx context.Context, req *common.Request)
 		return node.failRequest(ctx, req, "")
 	}
 
-	switch deposit.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(deposit.Chain) {
+	case common.SafeChainBitcoin:
 		return node.doBitcoinHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainMixin:
+		return node.doMixinHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
+	case common.SafeChainEthereum:
 		return node.doEthereumHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset)
 	default:
 		return node.failRequest(ctx, req, "")
@@ -130,7 +144,7 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 		panic(asset.Decimals)
 	}
 	switch asset.AssetId {
-	case common.SafeBitcoinChainId, common.SafeLitecoinChainId:
+	case common.SafeBitcoinChainId, common.SafeLitecoinChainId, common.SafeBitcoinCashChainId:
 	default:
 		panic(asset.AssetId)
 	}
@@ -176,7 +190,11 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 		panic(deposit.Hash)
 	}
 
-	output, err := node.verifyBitcoinTransaction(ctx, req, deposit, safe, bitcoin.InputTypeP2WSHMultisigHolderSigner)
+	typ := bitcoin.InputTypeP2WSHMultisigHolderSigner
+	if bitcoinSafeSignerCurve(safe) == common.CurveSecp256k1SchnorrBitcoin {
+		typ = bitcoin.InputTypeP2TRHolderSigner
+	}
+	output, err := node.verifyBitcoinTransaction(ctx, req, deposit, safe, typ)
 	logger.Printf("node.verifyBitcoinTransaction(%v) => %v %v", req, output, err)
 	if err != nil {
 		panic(fmt.Errorf("node.verifyBitcoinTransaction(%s) => %v", deposit.Hash, err))
@@ -208,7 +226,7 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 
 func (node *Node) doEthereumHolderDeposit(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe, safeAssetId string, asset *store.Asset) ([]*mtg.Transaction, string) {
 	_, chainId := node.ethereumParams(deposit.Chain)
-	if asset.AssetId == chainId && asset.Decimals != ethereum.ValuePrecision {
+	if asset.AssetId == chainId && int32(asset.Decimals) != ethereum.GetChainConfig(deposit.Chain).Precision {
 		panic(asset.Decimals)
 	}
 	deposited, err := node.store.ReadDeposit(ctx, deposit.Hash, int64(deposit.Index))
@@ -262,9 +280,10 @@ func (node *Node) checkBitcoinChange(ctx context.Context, deposit *Deposit, btx
 	}
 	var recipients []map[string]string
 	err = json.Unmarshal([]byte(tx.Data), &recipients)
-	if err != nil || len(recipients) == 0 {
+	if err != nil {
 		return false, fmt.Errorf("store.ReadTransaction(%s) => %s", spentBy, tx.Data)
 	}
+	// a consolidation has no recipients and all its outputs are change
 	return deposit.Index >= uint64(len(recipients)), nil
 }
 
@@ -294,6 +313,18 @@ func (node *Node) verifyBitcoinTransaction(ctx context.Context, req *common.Requ
 		receiver = wsa.Address
 		input.Script = wsa.Script
 		input.Sequence = wsa.Sequence
+	case bitcoin.InputTypeP2TRHolderSigner:
+		path := common.DecodeHexOrPanic(safe.Path)
+		wsa, err := node.buildBitcoinTaprootAccountWithDerivation(ctx, safe.Holder, safe.Signer, safe.Observer, path, safe.Timelock, safe.Chain)
+		if err != nil {
+			panic(err)
+		}
+		if wsa.Address != safe.Address {
+			panic(safe.Address)
+		}
+		receiver = wsa.Address
+		input.Script = wsa.Script
+		input.Sequence = wsa.Sequence
 	default:
 		panic(typ)
 	}
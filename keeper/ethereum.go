@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"math/big"
 	"time"
@@ -200,7 +199,7 @@ func (node *Node) closeEthereumAccountWithHolder(ctx context.Context, req *commo
 			logger.Printf("invalid output destination: %s, %s", norm, safe.Address)
 			return node.failRequest(ctx, req, "")
 		}
-		decimals := int32(ethereum.ValuePrecision)
+		decimals := ethereum.GetChainConfig(safe.Chain).Precision
 		if out.TokenAddress != ethereum.EthereumEmptyAddress {
 			assetId := ethereum.GenerateAssetId(safe.Chain, out.TokenAddress)
 			asset, err := node.store.ReadAssetMeta(ctx, assetId)
@@ -258,9 +257,7 @@ func (node *Node) processEthereumSafeProposeAccount(ctx context.Context, req *co
 	if req.Role != common.RequestRoleHolder {
 		panic(req.Role)
 	}
-	switch req.Curve {
-	case common.CurveSecp256k1ECDSAEthereum, common.CurveSecp256k1ECDSAMVM, common.CurveSecp256k1ECDSAPolygon:
-	default:
+	if ethereum.GetChainConfigByCurve(req.Curve) == nil {
 		panic(req.Curve)
 	}
 	rce := req.ExtraBytes()
@@ -385,9 +382,7 @@ func (node *Node) processEthereumSafeApproveAccount(ctx context.Context, req *co
 	if req.Role != common.RequestRoleObserver {
 		panic(req.Role)
 	}
-	switch req.Curve {
-	case common.CurveSecp256k1ECDSAEthereum, common.CurveSecp256k1ECDSAMVM, common.CurveSecp256k1ECDSAPolygon:
-	default:
+	if ethereum.GetChainConfigByCurve(req.Curve) == nil {
 		panic(req.Curve)
 	}
 	old, err := node.store.ReadSafe(ctx, req.Holder)
@@ -526,10 +521,10 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 	if err != nil {
 		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
 	}
-	if meta.Chain != common.SafeChainPolygon {
+	if meta.Chain != ethereum.BondChain().Chain {
 		return node.failRequest(ctx, req, "")
 	}
-	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.evmRPC(ethereum.BondChain().Chain), meta.AssetKey)
 	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
 	if err != nil || deployed.Sign() <= 0 {
 		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
@@ -582,7 +577,7 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 	if balance.SafeAssetId != req.AssetId {
 		panic(balance.SafeAssetId)
 	}
-	decimals := int32(ethereum.ValuePrecision)
+	decimals := ethereum.GetChainConfig(safe.Chain).Precision
 	if balance.AssetAddress != ethereum.EthereumEmptyAddress {
 		asset, err := node.store.ReadAssetMeta(ctx, id.String())
 		logger.Printf("store.ReadAssetMeta(%s) => %v %v", id.String(), asset, err)
@@ -597,22 +592,27 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 	if len(extra[16:]) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra[16:]) {
 		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
 		extra := stx.Extra
-		var recipients [][2]string // TODO better encoding
-		err = json.Unmarshal(extra, &recipients)
+		recipients, err := common.DecodeRecipients(extra)
+		logger.Printf("common.DecodeRecipients(%x) => %d %v", extra, len(recipients), err)
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
 		for _, rp := range recipients {
-			amt, err := decimal.NewFromString(rp[1])
-			if err != nil {
+			if len(rp.Memo) > 0 {
 				return node.failRequest(ctx, req, "")
 			}
-			if amt.Cmp(plan.TransactionMinimum) < 0 {
+			if rp.AssetId != "" && rp.AssetId != id.String() {
+				return node.failRequest(ctx, req, "")
+			}
+			if rp.Amount.Exponent() < -decimals {
+				return node.failRequest(ctx, req, "")
+			}
+			if rp.Amount.Cmp(plan.TransactionMinimum) < 0 {
 				return node.failRequest(ctx, req, "")
 			}
 			o := &ethereum.Output{
-				Destination:  rp[0],
-				Amount:       ethereum.ParseAmount(amt.String(), decimals),
+				Destination:  rp.Address,
+				Amount:       ethereum.ParseAmount(rp.Amount.String(), decimals),
 				TokenAddress: balance.AssetAddress,
 			}
 			outputs = append(outputs, o)
@@ -646,6 +646,9 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 	if len(outputs) > 256 || !total.Equal(req.Amount) {
 		return node.failRequest(ctx, req, "")
 	}
+	if flag == common.FlagProposeNormalTransaction && !node.checkSpendingPolicy(ctx, req, safe, id.String(), recipients) {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
 
 	var t *ethereum.SafeTransaction
 	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
@@ -783,6 +786,9 @@ func (node *Node) processEthereumSafeApproveTransaction(ctx context.Context, req
 	} else if tx.Holder != req.Holder {
 		return node.failRequest(ctx, req, "")
 	}
+	if node.checkSpendingPolicyDelayed(ctx, req, tx) {
+		return node.failRequest(ctx, req, "")
+	}
 
 	var ref crypto.Hash
 	copy(ref[:], extra[16:])
@@ -885,10 +891,10 @@ func (node *Node) processEthereumSafeRefundTransaction(ctx context.Context, req
 	if err != nil {
 		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
 	}
-	if meta.Chain != common.SafeChainPolygon {
+	if meta.Chain != ethereum.BondChain().Chain {
 		return node.failRequest(ctx, req, "")
 	}
-	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.evmRPC(ethereum.BondChain().Chain), meta.AssetKey)
 	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
 	if err != nil || deployed.Sign() <= 0 {
 		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
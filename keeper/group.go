@@ -9,6 +9,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -84,6 +85,12 @@ func (node *Node) getActionRole(act byte) byte {
 		return common.RequestRoleSigner
 	case common.OperationTypeSignOutput:
 		return common.RequestRoleSigner
+	case common.OperationTypeBatchSignOutput:
+		return common.RequestRoleSigner
+	case common.OperationTypeRefreshOutput:
+		return common.RequestRoleSigner
+	case common.OperationTypeReshareOutput:
+		return common.RequestRoleSigner
 	case common.ActionTerminate:
 		return common.RequestRoleObserver
 	case common.ActionObserverAddKey:
@@ -96,20 +103,28 @@ func (node *Node) getActionRole(act byte) byte {
 		return common.RequestRoleObserver
 	case common.ActionObserverSetOperationParams:
 		return common.RequestRoleObserver
+	case common.ActionObserverSetSpendingPolicy:
+		return common.RequestRoleObserver
+	case common.ActionObserverRequestSignerRefresh:
+		return common.RequestRoleObserver
+	case common.ActionObserverRequestSignerReshare:
+		return common.RequestRoleObserver
 	case common.ActionMigrateSafeToken:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
+	case common.ActionBitcoinSafeProposeAccount, common.ActionMixinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+	case common.ActionBitcoinSafeApproveAccount, common.ActionMixinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
 		return common.RequestRoleObserver
-	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
+	case common.ActionBitcoinSafeProposeTransaction, common.ActionMixinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeApproveTransaction, common.ActionEthereumSafeApproveTransaction:
+	case common.ActionBitcoinSafeApproveTransaction, common.ActionMixinSafeApproveTransaction, common.ActionEthereumSafeApproveTransaction:
 		return common.RequestRoleObserver
-	case common.ActionBitcoinSafeRevokeTransaction, common.ActionEthereumSafeRevokeTransaction:
+	case common.ActionBitcoinSafeRevokeTransaction, common.ActionMixinSafeRevokeTransaction, common.ActionEthereumSafeRevokeTransaction:
 		return common.RequestRoleObserver
 	case common.ActionBitcoinSafeCloseAccount, common.ActionEthereumSafeCloseAccount:
 		return common.RequestRoleObserver
+	case common.ActionBitcoinSafeConsolidate:
+		return common.RequestRoleObserver
 	case common.ActionEthereumSafeRefundTransaction:
 		return common.RequestRoleObserver
 	default:
@@ -126,10 +141,10 @@ func (node *Node) handleBondAsset(ctx context.Context, out *mtg.Action) (bool, e
 	if err != nil {
 		return false, fmt.Errorf("node.fetchAssetMeta(%s) => %v", out.AssetId, err)
 	}
-	if meta.Chain != common.SafeChainPolygon {
+	if meta.Chain != ethereum.BondChain().Chain {
 		return false, nil
 	}
-	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.evmRPC(ethereum.BondChain().Chain), meta.AssetKey)
 	logger.Verbosef("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
 	if err != nil {
 		return false, fmt.Errorf("abi.CheckFactoryAssetDeployed(%s) => %v", meta.AssetKey, err)
@@ -168,6 +183,10 @@ func (node *Node) processRequest(ctx context.Context, req *common.Request) ([]*m
 		return node.processKeyAdd(ctx, req)
 	case common.OperationTypeSignOutput:
 		return node.processSignerSignatureResponse(ctx, req)
+	case common.OperationTypeBatchSignOutput:
+		return node.processSignerBatchSignatureResponse(ctx, req)
+	case common.OperationTypeRefreshOutput, common.OperationTypeReshareOutput:
+		return node.processSignerRefreshResponse(ctx, req)
 	case common.ActionTerminate:
 		return node.Terminate(ctx)
 	case common.ActionObserverAddKey:
@@ -180,6 +199,12 @@ func (node *Node) processRequest(ctx context.Context, req *common.Request) ([]*m
 		return node.CreateHolderDeposit(ctx, req)
 	case common.ActionObserverSetOperationParams:
 		return node.writeOperationParams(ctx, req)
+	case common.ActionObserverSetSpendingPolicy:
+		return node.processSafeSetSpendingPolicy(ctx, req)
+	case common.ActionObserverRequestSignerRefresh:
+		return node.processSignerRefreshRequest(ctx, req)
+	case common.ActionObserverRequestSignerReshare:
+		return node.processSignerReshareRequest(ctx, req)
 	case common.ActionMigrateSafeToken:
 		return node.checkSafeTokenMigration(ctx, req)
 	case common.ActionBitcoinSafeProposeAccount:
@@ -194,6 +219,18 @@ func (node *Node) processRequest(ctx context.Context, req *common.Request) ([]*m
 		return node.processSafeRevokeTransaction(ctx, req)
 	case common.ActionBitcoinSafeCloseAccount:
 		return node.processBitcoinSafeCloseAccount(ctx, req)
+	case common.ActionBitcoinSafeConsolidate:
+		return node.processBitcoinSafeConsolidate(ctx, req)
+	case common.ActionMixinSafeProposeAccount:
+		return node.processMixinSafeProposeAccount(ctx, req)
+	case common.ActionMixinSafeApproveAccount:
+		return node.processMixinSafeApproveAccount(ctx, req)
+	case common.ActionMixinSafeProposeTransaction:
+		return node.processMixinSafeProposeTransaction(ctx, req)
+	case common.ActionMixinSafeApproveTransaction:
+		return node.processMixinSafeApproveTransaction(ctx, req)
+	case common.ActionMixinSafeRevokeTransaction:
+		return node.processSafeRevokeTransaction(ctx, req)
 	case common.ActionEthereumSafeProposeAccount:
 		return node.processEthereumSafeProposeAccount(ctx, req)
 	case common.ActionEthereumSafeApproveAccount:
@@ -252,14 +289,31 @@ func (node *Node) processKeyAdd(ctx context.Context, req *common.Request) ([]*mt
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
-	case common.CurveSecp256k1ECDSAEthereum, common.CurveSecp256k1ECDSAMVM, common.CurveSecp256k1ECDSAPolygon:
+	case common.CurveSecp256k1SchnorrBitcoin:
+		if extra[0] == common.RequestRoleObserver {
+			err = bitcoin.CheckDerivation(req.Holder, chainCode, 1000)
+		} else {
+			err = bitcoin.VerifyTaprootKey(req.Holder)
+		}
+		logger.Printf("bitcoin.VerifyTaprootKey(%s, %x) => %v", req.Holder, chainCode, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+	case common.CurveEdwards25519Mixin:
+		err = mixin.VerifyHolderKey(req.Holder)
+		logger.Printf("mixin.VerifyHolderKey(%s, %x) => %v", req.Holder, chainCode, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+	default:
+		if ethereum.GetChainConfigByCurve(req.Curve) == nil {
+			panic(req.Curve)
+		}
 		err = ethereum.VerifyHolderKey(req.Holder)
 		logger.Printf("ethereum.VerifyHolderKey(%s, %x) => %v", req.Holder, chainCode, err)
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
-	default:
-		panic(req.Curve)
 	}
 	err = node.store.WriteKeyFromRequest(ctx, req, int(extra[0]), chainCode, flags)
 	if err != nil {
@@ -291,16 +345,60 @@ func (node *Node) processSignerSignatureResponse(ctx context.Context, req *commo
 	if safe.Signer != req.Holder {
 		return node.failRequest(ctx, req, "")
 	}
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
 		return node.processBitcoinSafeSignatureResponse(ctx, req, safe, tx, old)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainMixin:
+		return node.processMixinSafeSignatureResponse(ctx, req, safe, tx, old)
+	case common.SafeChainEthereum:
 		return node.processEthereumSafeSignatureResponse(ctx, req, safe, tx, old)
 	default:
 		panic(safe.Chain)
 	}
 }
 
+func (node *Node) processSignerBatchSignatureResponse(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+	batch, err := node.store.ReadSignatureBatch(ctx, req.Id)
+	logger.Printf("store.ReadSignatureBatch(%s) => %v %v", req.Id, batch, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSignatureBatch(%s) => %v", req.Id, err))
+	}
+	if batch == nil || batch.State == common.RequestStateDone {
+		return node.failRequest(ctx, req, "")
+	}
+	extra := req.ExtraBytes()
+	if len(extra) != 32 {
+		return node.failRequest(ctx, req, "")
+	}
+	raw := node.readStorageExtraFromSigner(ctx, req, extra)
+	sigs, err := common.DecodeBatchItems(raw)
+	logger.Printf("common.DecodeBatchItems(%x) => %d %v", raw, len(sigs), err)
+	if err != nil || len(sigs) != len(batch.RequestIds) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	tx, err := node.store.ReadTransaction(ctx, batch.TransactionHash)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransaction(%v) => %s %v", req, batch.TransactionHash, err))
+	}
+	safe, err := node.store.ReadSafe(ctx, tx.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", tx.Holder, err))
+	}
+	if safe.Signer != req.Holder {
+		return node.failRequest(ctx, req, "")
+	}
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
+		return node.processBitcoinSafeBatchSignatureResponse(ctx, req, safe, tx, batch, sigs)
+	default:
+		return node.failRequest(ctx, req, "")
+	}
+}
+
 func (node *Node) processSafeRevokeTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
 	if req.Role != common.RequestRoleObserver {
 		panic(req.Role)
@@ -1,31 +1,39 @@
 package keeper
 
 import (
+	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
 )
 
 type Configuration struct {
-	AppId                       string             `toml:"app-id"`
-	SignerAppId                 string             `toml:"signer-app-id"`
-	StoreDir                    string             `toml:"store-dir"`
-	MonitorConversaionId        string             `toml:"monitor-conversation-id"`
-	SharedKey                   string             `toml:"shared-key"`
-	SignerPublicKey             string             `toml:"signer-public-key"`
-	AssetId                     string             `toml:"asset-id"`
-	ObserverAssetId             string             `toml:"observer-asset-id"`
-	ObserverPublicKey           string             `toml:"observer-public-key"`
-	ObserverUserId              string             `toml:"observer-user-id"`
-	MixinMessengerAPI           string             `toml:"mixin-messenger-api"`
-	MixinRPC                    string             `toml:"mixin-rpc"`
-	BitcoinRPC                  string             `toml:"bitcoin-rpc"`
-	LitecoinRPC                 string             `toml:"litecoin-rpc"`
-	EthereumRPC                 string             `toml:"ethereum-rpc"`
-	PolygonRPC                  string             `toml:"polygon-rpc"`
-	PolygonFactoryAddress       string             `toml:"polygon-factory-address"`
-	PolygonObserverDepositEntry string             `toml:"polygon-observer-deposit-entry"`
-	PolygonKeeperDepositEntry   string             `toml:"polygon-keeper-deposit-entry"`
-	MTG                         *mtg.Configuration `toml:"mtg"`
+	AppId                       string                  `toml:"app-id"`
+	SignerAppId                 string                  `toml:"signer-app-id"`
+	StoreDir                    string                  `toml:"store-dir"`
+	MonitorConversaionId        string                  `toml:"monitor-conversation-id"`
+	MetricsListen               string                  `toml:"metrics-listen"`
+	SharedKey                   string                  `toml:"shared-key"`
+	SignerPublicKey             string                  `toml:"signer-public-key"`
+	AssetId                     string                  `toml:"asset-id"`
+	ObserverAssetId             string                  `toml:"observer-asset-id"`
+	ObserverPublicKey           string                  `toml:"observer-public-key"`
+	ObserverUserId              string                  `toml:"observer-user-id"`
+	MixinMessengerAPI           string                  `toml:"mixin-messenger-api"`
+	MixinRPC                    string                  `toml:"mixin-rpc"`
+	BitcoinRPC                  string                  `toml:"bitcoin-rpc"`
+	LitecoinRPC                 string                  `toml:"litecoin-rpc"`
+	BitcoinCashRPC              string                  `toml:"bitcoincash-rpc"`
+	EthereumRPC                 string                  `toml:"ethereum-rpc"`
+	PolygonRPC                  string                  `toml:"polygon-rpc"`
+	PolygonObserverDepositEntry string                  `toml:"polygon-observer-deposit-entry"`
+	PolygonKeeperDepositEntry   string                  `toml:"polygon-keeper-deposit-entry"`
+	EVMRPC                      map[string]string       `toml:"evm-rpc"`
+	EVMChains                   []*ethereum.ChainConfig `toml:"evm-chains"`
+	MTG                         *mtg.Configuration      `toml:"mtg"`
+}
+
+func (c *Configuration) evmRPC(chain byte) string {
+	return ethereum.ChainRPC(chain, c.EVMRPC, c.EthereumRPC, c.PolygonRPC)
 }
 
 func OpenSQLite3Store(path string) (*store.SQLite3Store, error) {
@@ -14,12 +14,14 @@ import (
 	"testing"
 	"time"
 
+	mc "github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/safe/signer"
 	"github.com/MixinNetwork/trusted-group/mtg"
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -89,6 +91,7 @@ func TestBitcoinKeeper(t *testing.T) {
 	require.Nil(err)
 	require.Len(pendings, 0)
 
+	testSafeProposeRecipientsWithInvalidPrecision(ctx, require, node, db, bondId)
 	transactionHash := testSafeProposeTransaction(ctx, require, node, bondId, "3e37ea1c-1455-400d-9642-f6bbcd8c744e", "18d6e8a1bcce1b1dddbfed5826cde933dc55ba65a733fc5a2198f113c86e31d0", "70736274ff0100cd02000000022704c97677a6bc74ec1969e260b7af8beffe0ba05053fcd39fa9cba3e528e2400000000000ffffffff9451d4f1cbcd85535e80b54b9b151225783e11365840be166df67df179e91c850000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b981490b4a8020000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012b2052010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b292689352870001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
 	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
@@ -137,6 +140,247 @@ func TestBitcoinKeeper(t *testing.T) {
 	testAccountantSpentTransaction(ctx, require, signedRaw, testHolderSigner)
 }
 
+func TestBitcoinKeeperBatchTransaction(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	bondId := testDeployBondContract(ctx, require, node, testSafeAddress, common.SafeBitcoinChainId)
+	require.Equal(testBondAssetId, bondId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{
+		UnifiedOutput: *output,
+	})
+	input := &bitcoin.Input{
+		TransactionHash: "40e228e5a3cba99fd3fc5350a00bfeef8bafb760e26919ec74bca67776c90427",
+		Index:           0, Satoshi: 86560,
+	}
+	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 1)
+	input = &bitcoin.Input{
+		TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194",
+		Index:           0, Satoshi: 100000,
+	}
+	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 2)
+
+	rid := uuid.Must(uuid.NewV4()).String()
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	recipients := common.EncodeRecipients([]*common.Recipient{{
+		Address: testTransactionReceiver,
+		Amount:  decimal.RequireFromString("0.0009"),
+	}, {
+		Address: testTransactionReceiver,
+		Amount:  decimal.RequireFromString("0.0008"),
+	}})
+	storage := crypto.Sha256Hash(recipients)
+	testWriteKernelTransaction(ctx, require, db, storage.String(), nil, recipients)
+	testWriteKernelTransaction(ctx, require, db, crypto.Sha256Hash([]byte(rid)).String(), []crypto.Hash{storage}, nil)
+	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	extra := []byte{common.FlagProposeBatchTransaction}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, storage[:]...)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionBitcoinSafeProposeTransaction, bondId, extra, decimal.RequireFromString("0.0017"))
+	testStep(ctx, require, node, out)
+
+	txs, err := node.store.ListTransactionsByBatch(ctx, rid)
+	require.Nil(err)
+	require.Len(txs, 1)
+	tx := txs[0]
+	require.Equal(store.BatchTransactionRequestId(rid, 0), tx.RequestId)
+	require.Equal(common.RequestStateInitial, tx.State)
+	batchId, err := node.store.ReadTransactionBatchId(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(rid, batchId)
+
+	hb := common.DecodeHexOrPanic(testBitcoinKeyHolderPrivate)
+	hpk, _ := btcec.PrivKeyFromBytes(hb)
+	signed := common.DecodeHexOrPanic(testHolderApproveTransaction(tx.RawTransaction))
+	approve := func(raw []byte) {
+		ref := crypto.Sha256Hash(raw)
+		err := node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+		require.Nil(err)
+		extra := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+		extra = append(extra, ref[:]...)
+		id := uuid.Must(uuid.NewV4()).String()
+		out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeApproveTransaction, extra, common.CurveSecp256k1ECDSABitcoin)
+		testStep(ctx, require, node, out)
+	}
+
+	// the holder signed transaction alone can't approve a batch transaction
+	approve(signed)
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	// the batch digest must be signed by the holder over all the transactions
+	msg := bitcoin.HashBatchForSignature(rid, nil, common.SafeChainBitcoin)
+	sig := ecdsa.Sign(hpk, msg).Serialize()
+	approve(common.EncodeBatchItems([][]byte{signed, sig}))
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+	msg = bitcoin.HashBatchForSignature(rid, []string{tx.TransactionHash}, common.SafeChainBitcoin)
+	sig = ecdsa.Sign(testGetDerivedObserverPrivate(require), msg).Serialize()
+	approve(common.EncodeBatchItems([][]byte{signed, sig}))
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	sig = ecdsa.Sign(hpk, msg).Serialize()
+	approve(common.EncodeBatchItems([][]byte{signed, sig}))
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStatePending, tx.State)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, tx.TransactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 2)
+}
+
+func TestBitcoinKeeperConsolidate(t *testing.T) {
+	require := require.New(t)
+	ctx, node, _, _, _ := testPrepare(require)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	inputs := testWriteBitcoinUTXOs(ctx, require, node, SafeConsolidateMinimum+1)
+	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	consolidate := func(count int) *common.Request {
+		rid := uuid.Must(uuid.NewV4()).String()
+		extra := uuid.Must(uuid.FromString(info.RequestId)).Bytes()
+		extra = binary.BigEndian.AppendUint16(extra, uint16(count))
+		out := testBuildObserverRequest(node, rid, holder, common.ActionBitcoinSafeConsolidate, extra, common.CurveSecp256k1ECDSABitcoin)
+		testStep(ctx, require, node, out)
+		req, err := node.store.ReadRequest(ctx, rid)
+		require.Nil(err)
+		return req
+	}
+
+	req := consolidate(SafeConsolidateMinimum - 1)
+	require.Equal(common.RequestStateFailed, int(req.State))
+	req = consolidate(bitcoin.MaxUnspentUtxo + 1)
+	require.Equal(common.RequestStateFailed, int(req.State))
+
+	req = consolidate(SafeConsolidateMinimum)
+	require.Equal(common.RequestStateDone, int(req.State))
+	tx, err := node.store.ReadTransactionByRequestId(ctx, req.Id)
+	require.Nil(err)
+	require.Equal("[]", tx.Data)
+	require.Equal(common.RequestStateInitial, tx.State)
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	msgTx := psbt.UnsignedTx
+	require.Len(msgTx.TxIn, SafeConsolidateMinimum)
+	require.Len(msgTx.TxOut, 2)
+	var total int64
+	for i, in := range msgTx.TxIn {
+		require.Equal(inputs[i].TransactionHash, in.PreviousOutPoint.Hash.String())
+		total += inputs[i].Satoshi
+	}
+	require.Equal(total, msgTx.TxOut[0].Value)
+	receiver, err := bitcoin.ExtractPkScriptAddr(msgTx.TxOut[0].PkScript, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(testSafeAddress, receiver)
+	require.Equal(int64(0), msgTx.TxOut[1].Value)
+
+	outputs, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(inputs[SafeConsolidateMinimum].TransactionHash, outputs[0].TransactionHash)
+	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, SafeConsolidateMinimum)
+
+	// the consolidation output is the change of the safe without recipients
+	var btx bitcoin.RPCTransaction
+	err = json.Unmarshal([]byte(fmt.Sprintf(`{"vin":[{"txid":"%s","vout":0}]}`, inputs[0].TransactionHash)), &btx)
+	require.Nil(err)
+	change, err := node.checkBitcoinChange(ctx, &Deposit{Index: 0}, &btx)
+	require.Nil(err)
+	require.True(change)
+
+	req = consolidate(SafeConsolidateMinimum)
+	require.Equal(common.RequestStateFailed, int(req.State))
+}
+
+func TestBitcoinKeeperCoinControl(t *testing.T) {
+	require := require.New(t)
+	ctx, node, _, _, _ := testPrepare(require)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	inputs := testWriteBitcoinUTXOs(ctx, require, node, 6)
+	encode := func(ins ...*bitcoin.Input) []byte {
+		extra := []byte{byte(len(ins))}
+		for _, in := range ins {
+			extra = append(extra, common.DecodeHexOrPanic(in.TransactionHash)...)
+			extra = binary.BigEndian.AppendUint32(extra, in.Index)
+		}
+		return extra
+	}
+	spend := func(state int, ins ...*bitcoin.Input) {
+		req := testWriteObserverRequest(ctx, require, node, common.ActionBitcoinSafeProposeTransaction)
+		tx := &store.Transaction{
+			TransactionHash: crypto.Sha256Hash([]byte(req.Id)).String(),
+			RawTransaction:  "00",
+			Holder:          holder,
+			Chain:           common.SafeChainBitcoin,
+			AssetId:         common.SafeBitcoinChainId,
+			State:           common.RequestStateInitial,
+			Data:            "[]",
+			RequestId:       req.Id,
+			CreatedAt:       req.CreatedAt,
+			UpdatedAt:       req.CreatedAt,
+		}
+		utxos := store.TransactionInputsFromBitcoin(ins)
+		var err error
+		if state == common.RequestStatePending {
+			err = node.store.WriteTransactionWithRequest(ctx, tx, utxos, nil, req)
+		} else {
+			err = node.store.CloseAccountByTransactionWithRequest(ctx, tx, utxos, state, nil, req)
+		}
+		require.Nil(err)
+	}
+	selectInputs := func(extra []byte) ([]*bitcoin.Input, []byte) {
+		mainInputs, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
+		require.Nil(err)
+		return node.selectBitcoinCoinControlInputs(ctx, mainInputs, extra)
+	}
+
+	selected, rest := selectInputs(append(encode(inputs[4], inputs[1]), testTransactionReceiver...))
+	require.Len(selected, 2)
+	require.Equal(inputs[4].TransactionHash, selected[0].TransactionHash)
+	require.Equal(inputs[1].TransactionHash, selected[1].TransactionHash)
+	require.Equal(testTransactionReceiver, string(rest))
+
+	selected, _ = selectInputs(nil)
+	require.Len(selected, 0)
+	selected, _ = selectInputs([]byte{0})
+	require.Len(selected, 0)
+	selected, _ = selectInputs(encode(inputs[1])[:36])
+	require.Len(selected, 0)
+	selected, _ = selectInputs(encode(inputs[1], inputs[1]))
+	require.Len(selected, 0)
+	unknown := &bitcoin.Input{TransactionHash: inputs[1].TransactionHash, Index: 1}
+	selected, _ = selectInputs(encode(inputs[1], unknown))
+	require.Len(selected, 0)
+
+	// the selected outputs must be sufficient for the recipients
+	outputs := []*bitcoin.Output{{Address: testTransactionReceiver, Satoshi: inputs[4].Satoshi + inputs[1].Satoshi + 1}}
+	selected, _ = selectInputs(encode(inputs[4], inputs[1]))
+	_, err := bitcoin.BuildPartiallySignedTransaction(selected, outputs, uuid.Must(uuid.NewV4()).Bytes(), common.SafeChainBitcoin)
+	require.True(bitcoin.IsInsufficientInputError(err))
+	selected, _ = selectInputs(encode(inputs[4], inputs[1], inputs[5]))
+	psbt, err := bitcoin.BuildPartiallySignedTransaction(selected, outputs, uuid.Must(uuid.NewV4()).Bytes(), common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(psbt.UnsignedTx.TxIn, 3)
+
+	spend(common.RequestStatePending, inputs[2])
+	selected, _ = selectInputs(encode(inputs[1], inputs[2]))
+	require.Len(selected, 0)
+	selected, _ = selectInputs(encode(inputs[1], inputs[3]))
+	require.Len(selected, 2)
+
+	spend(common.RequestStateDone, inputs[3])
+	selected, _ = selectInputs(encode(inputs[1], inputs[3]))
+	require.Len(selected, 0)
+	selected, _ = selectInputs(encode(inputs[1], inputs[0]))
+	require.Len(selected, 2)
+}
+
 func TestBitcoinKeeperCloseAccountWithSignerObserver(t *testing.T) {
 	require := require.New(t)
 	ctx, node, db, mpc, signers := testPrepare(require)
@@ -310,6 +554,48 @@ func testPrepare(require *require.Assertions) (context.Context, *Node, *mtg.SQLi
 	}
 	testSpareKeys(ctx, require, node, 0, 1, 1, common.CurveSecp256k1ECDSABitcoin)
 
+	id = uuid.Must(uuid.NewV4()).String()
+	out = testBuildObserverRequest(node, id, mpc, common.ActionObserverRequestSignerRefresh, nil, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	pid := common.UniqueId(id, "REFRESH")
+	pid = common.UniqueId(pid, fmt.Sprintf("MTG:%v:%d", signerMembers, node.signer.Genesis.Threshold))
+	v, err = node.store.ReadProperty(ctx, pid)
+	require.Nil(err)
+	var om map[string]any
+	err = json.Unmarshal([]byte(v), &om)
+	require.Nil(err)
+	b, _ := hex.DecodeString(om["memo"].(string))
+	b = common.AESDecrypt(node.signerAESKey[:], b)
+	o, err := common.DecodeOperation(b)
+	require.Nil(err)
+	require.Equal(pid, o.Id)
+	require.Equal(common.OperationTypeRefreshInput, int(o.Type))
+	require.Equal(mpc, o.Public)
+	out = testBuildSignerOutput(node, pid, mpc, common.OperationTypeRefreshOutput, nil, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	testSpareKeys(ctx, require, node, 0, 1, 1, common.CurveSecp256k1ECDSABitcoin)
+
+	id = uuid.Must(uuid.NewV4()).String()
+	out = testBuildObserverRequest(node, id, mpc, common.ActionObserverRequestSignerReshare, []byte{2}, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	pid = common.UniqueId(id, "RESHARE")
+	pid = common.UniqueId(pid, fmt.Sprintf("MTG:%v:%d", signerMembers, node.signer.Genesis.Threshold))
+	v, err = node.store.ReadProperty(ctx, pid)
+	require.Nil(err)
+	err = json.Unmarshal([]byte(v), &om)
+	require.Nil(err)
+	b, _ = hex.DecodeString(om["memo"].(string))
+	b = common.AESDecrypt(node.signerAESKey[:], b)
+	o, err = common.DecodeOperation(b)
+	require.Nil(err)
+	require.Equal(pid, o.Id)
+	require.Equal(common.OperationTypeReshareInput, int(o.Type))
+	require.Equal(mpc, o.Public)
+	require.Equal(common.EncodeSignerCommittee(node.GetSigners(), 2), o.Extra)
+	out = testBuildSignerOutput(node, pid, mpc, common.OperationTypeReshareOutput, nil, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	testSpareKeys(ctx, require, node, 0, 1, 1, common.CurveSecp256k1ECDSABitcoin)
+
 	for i := 0; i < 10; i++ {
 		testUpdateAccountPrice(ctx, require, node)
 	}
@@ -446,23 +732,24 @@ func testSafeApproveTransaction(ctx context.Context, require *require.Assertions
 	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
 	require.Equal(common.RequestStatePending, tx.State)
 
-	msg, _ := hex.DecodeString(requests[0].Message)
-	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
-	op := signer.TestProcessOutput(ctx, require, signers, out, requests[0].RequestId)
-	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
-	testStep(ctx, require, node, out)
-	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStatePending)
-	require.Len(requests, 1)
-	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
-	require.Len(requests, 1)
-	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
-	require.Equal(common.RequestStatePending, tx.State)
-
-	msg, _ = hex.DecodeString(requests[1].Message)
-	out = testBuildSignerOutput(node, requests[1].RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
-	op = signer.TestProcessOutput(ctx, require, signers, out, requests[1].RequestId)
-	out = testBuildSignerOutput(node, requests[1].RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
+	batchId := common.UniqueId(id, "BATCH:0")
+	batch, err := node.store.ReadSignatureBatch(ctx, batchId)
+	require.Nil(err)
+	require.Equal([]string{requests[0].RequestId, requests[1].RequestId}, batch.RequestIds)
+	msgs := [][]byte{common.DecodeHexOrPanic(requests[0].Message), common.DecodeHexOrPanic(requests[1].Message)}
+	payload := common.EncodeBatchItems(msgs)
+	signer.TestWriteStorageExtra(ctx, signers, payload)
+	digest := crypto.Sha256Hash(payload)
+	out = testBuildSignerOutput(node, batchId, safe.Signer, common.OperationTypeBatchSignInput, digest[:], common.CurveSecp256k1ECDSABitcoin)
+	op := signer.TestProcessOutput(ctx, require, signers, out, batchId)
+	require.Equal(common.OperationTypeBatchSignOutput, int(op.Type))
+	sigs := signer.TestReadStorageExtra(ctx, signers[0], op.Extra)
+	err = node.store.WriteProperty(ctx, hex.EncodeToString(op.Extra), base64.RawURLEncoding.EncodeToString(sigs))
+	require.Nil(err)
+	out = testBuildSignerOutput(node, batchId, safe.Signer, common.OperationTypeBatchSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
 	testStep(ctx, require, node, out)
+	batch, _ = node.store.ReadSignatureBatch(ctx, batchId)
+	require.Equal(common.RequestStateDone, batch.State)
 	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
 	require.Len(requests, 0)
 	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStatePending)
@@ -528,6 +815,44 @@ func testSafeProposeTransaction(ctx context.Context, require *require.Assertions
 	return stx.TransactionHash
 }
 
+func testSafeProposeRecipientsWithInvalidPrecision(ctx context.Context, require *require.Assertions, node *Node, db *mtg.SQLite3Store, bondId string) {
+	rid := uuid.Must(uuid.NewV4()).String()
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	recipients := common.EncodeRecipients([]*common.Recipient{{
+		Address: testTransactionReceiver,
+		Amount:  decimal.RequireFromString("0.000123001"),
+	}})
+	storage := crypto.Sha256Hash(recipients)
+	testWriteKernelTransaction(ctx, require, db, storage.String(), nil, recipients)
+	testWriteKernelTransaction(ctx, require, db, crypto.Sha256Hash([]byte(rid)).String(), []crypto.Hash{storage}, nil)
+
+	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	extra := []byte{common.FlagProposeNormalTransaction}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, storage[:]...)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionBitcoinSafeProposeTransaction, bondId, extra, decimal.RequireFromString("0.000123001"))
+	testStep(ctx, require, node, out)
+
+	req, err := node.store.ReadRequest(ctx, rid)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, int(req.State))
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	require.Nil(tx)
+}
+
+// testWriteKernelTransaction caches the kernel transaction for the group to
+// read the references and the storage extra in test environment
+func testWriteKernelTransaction(ctx context.Context, require *require.Assertions, db *mtg.SQLite3Store, hash string, references []crypto.Hash, extra []byte) {
+	tx := mc.NewTransactionV5(mc.XINAssetId)
+	tx.References = references
+	tx.Extra = extra
+	val := base64.RawURLEncoding.EncodeToString(tx.AsVersioned().Marshal())
+	key := fmt.Sprintf("readKernelTransactionUntilSufficient(%s)", hash)
+	err := db.WriteCache(ctx, key, val)
+	require.Nil(err)
+}
+
 func testSafeProposeRecoveryTransaction(ctx context.Context, require *require.Assertions, node *Node, bondId string, rid, rhash, rraw string) string {
 	holder := testPublicKey(testBitcoinKeyHolderPrivate)
 	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
@@ -764,6 +1089,51 @@ func testObserverHolderDeposit(ctx context.Context, require *require.Assertions,
 	require.True(bitcoin.CheckMultisigHolderSignerScript(utxo.Script))
 }
 
+// testWriteBitcoinUTXOs writes the safe outputs without the deposit RPC, the
+// outputs are returned in the ascending order of amounts
+func testWriteBitcoinUTXOs(ctx context.Context, require *require.Assertions, node *Node, count int) []*bitcoin.Input {
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holder, safe.Signer, safe.Observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(safe.Address, wsa.Address)
+
+	var inputs []*bitcoin.Input
+	for i := 0; i < count; i++ {
+		req := testWriteObserverRequest(ctx, require, node, common.ActionObserverHolderDeposit)
+		input := &bitcoin.Input{
+			TransactionHash: crypto.Sha256Hash([]byte(req.Id + "UTXO")).String(),
+			Satoshi:         int64(10000 + i*100),
+			Script:          wsa.Script,
+			Sequence:        wsa.Sequence,
+		}
+		err = node.store.WriteBitcoinOutputFromRequest(ctx, safe, input, req, common.SafeBitcoinChainId, testTransactionReceiver, nil)
+		require.Nil(err)
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+func testWriteObserverRequest(ctx context.Context, require *require.Assertions, node *Node, action uint8) *common.Request {
+	id := uuid.Must(uuid.NewV4()).String()
+	req := &common.Request{
+		Id:        id,
+		MixinHash: crypto.Sha256Hash([]byte(id)),
+		AssetId:   common.SafeBitcoinChainId,
+		Role:      common.RequestRoleObserver,
+		Action:    action,
+		Curve:     common.CurveSecp256k1ECDSABitcoin,
+		Holder:    testPublicKey(testBitcoinKeyHolderPrivate),
+		State:     common.RequestStateInitial,
+		CreatedAt: time.Now().UTC(),
+		Output:    &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: id}},
+	}
+	err := node.store.WriteRequestIfNotExist(ctx, req)
+	require.Nil(err)
+	return req
+}
+
 func testSafeProposeAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string) (string, string) {
 	id := uuid.Must(uuid.NewV4()).String()
 	holder := testPublicKey(testBitcoinKeyHolderPrivate)
@@ -886,6 +1256,10 @@ func testReadObserverResponse(ctx context.Context, require *require.Assertions,
 		params, _ := node.store.ReadLatestOperationParams(ctx, common.SafeChainBitcoin, time.Now())
 		require.Equal(params.OperationPriceAsset, om["asset_id"])
 		require.Equal(params.OperationPriceAmount.String(), om["amount"])
+	case common.ActionMixinSafeApproveAccount:
+		params, _ := node.store.ReadLatestOperationParams(ctx, common.SafeChainMixin, time.Now())
+		require.Equal(params.OperationPriceAsset, om["asset_id"])
+		require.Equal(params.OperationPriceAmount.String(), om["amount"])
 	case common.ActionEthereumSafeApproveAccount:
 		params, _ := node.store.ReadLatestOperationParams(ctx, common.SafeChainPolygon, time.Now())
 		require.Equal(params.OperationPriceAsset, om["asset_id"])
@@ -911,9 +1285,15 @@ func testBuildHolderRequest(node *Node, id, public string, action byte, assetId
 	crv := byte(common.CurveSecp256k1ECDSABitcoin)
 	switch action {
 	case common.ActionBitcoinSafeProposeAccount, common.ActionBitcoinSafeProposeTransaction:
+	case common.ActionMixinSafeProposeAccount, common.ActionMixinSafeProposeTransaction:
+		crv = common.CurveEdwards25519Mixin
 	case common.ActionEthereumSafeProposeAccount, common.ActionEthereumSafeProposeTransaction:
 		crv = common.CurveSecp256k1ECDSAPolygon
 	}
+	return testBuildHolderRequestWithCurve(node, id, public, action, assetId, extra, amount, crv)
+}
+
+func testBuildHolderRequestWithCurve(node *Node, id, public string, action byte, assetId string, extra []byte, amount decimal.Decimal, crv byte) *mtg.Action {
 	op := &common.Operation{
 		Id:     id,
 		Type:   action,
@@ -974,8 +1354,12 @@ func testBuildSignerOutput(node *Node, id, public string, action byte, extra []b
 	path := bitcoinDefaultDerivationPath()
 	switch crv {
 	case common.CurveSecp256k1ECDSABitcoin:
+	case common.CurveEdwards25519Mixin:
+		path = mixinDefaultDerivationPath()
 	case common.CurveSecp256k1ECDSAEthereum, common.CurveSecp256k1ECDSAPolygon:
 		path = ethereumDefaultDerivationPath()
+	case common.CurveSecp256k1SchnorrBitcoin:
+		path = taprootDefaultDerivationPath()
 	default:
 		panic(crv)
 	}
@@ -991,14 +1375,14 @@ func testBuildSignerOutput(node *Node, id, public string, action byte, extra []b
 	case common.OperationTypeKeygenInput:
 		appId = node.conf.SignerAppId
 		op.Public = hex.EncodeToString(common.Fingerprint(public))
-	case common.OperationTypeSignInput:
+	case common.OperationTypeSignInput, common.OperationTypeBatchSignInput:
 		appId = node.conf.SignerAppId
 		fingerPath := append(common.Fingerprint(public), path...)
 		op.Public = hex.EncodeToString(fingerPath)
 	case common.OperationTypeKeygenOutput:
 		op.Public = public
 		timestamp = timestamp.Add(-SafeKeyBackupMaturity)
-	case common.OperationTypeSignOutput:
+	case common.OperationTypeSignOutput, common.OperationTypeBatchSignOutput, common.OperationTypeRefreshOutput, common.OperationTypeReshareOutput:
 		op.Public = public
 	}
 	memo := mtg.EncodeMixinExtraBase64(appId, node.encryptSignerOperation(op))
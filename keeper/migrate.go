@@ -6,6 +6,7 @@ import (
 	"slices"
 
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -60,8 +61,8 @@ func (node *Node) Migrate(ctx context.Context) error {
 			return err
 		}
 		ss = append(ss, ma)
-		switch safe.Chain {
-		case common.SafeChainEthereum, common.SafeChainPolygon:
+		switch common.SafeChainFamily(safe.Chain) {
+		case common.SafeChainEthereum:
 			bs, err := node.store.ReadUnmigratedEthereumAllBalance(ctx, safe.Address)
 			if err != nil {
 				return err
@@ -91,11 +92,11 @@ func (node *Node) checkSafeTokenMigration(ctx context.Context, req *common.Reque
 	if err != nil {
 		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
 	}
-	if meta.Chain != common.SafeChainPolygon {
+	if meta.Chain != ethereum.BondChain().Chain {
 		logger.Printf("invalid meta asset chain: %d", meta.Chain)
 		return node.failRequest(ctx, req, "")
 	}
-	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.evmRPC(ethereum.BondChain().Chain), meta.AssetKey)
 	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
 	if err != nil {
 		panic(fmt.Errorf("abi.CheckFactoryAssetDeployed(%s) => %v", meta.AssetKey, err))
@@ -135,12 +136,12 @@ func (node *Node) checkSafeTokenMigration(ctx context.Context, req *common.Reque
 	if safe == nil || safe.State != common.RequestStateDone {
 		return node.failRequest(ctx, req, "")
 	}
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
 		if safe.SafeAssetId != req.AssetId {
 			panic(req.AssetId)
 		}
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		bs, err := node.store.ReadAllEthereumTokenBalances(ctx, safe.Address)
 		if err != nil {
 			panic(err)
@@ -1,6 +1,655 @@
 package keeper
 
-// holder key just for safe verification, not for kernel
-// observer holds the view key, i.e. the accountant key
-// no recovery key needed, and signer holds spend key
-// the risk here is the accountant key loss
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+// the kernel account is a 2/3 multisig of holder, signer and observer keys
+// observer key is also the view key, i.e. the accountant key
+// holder and signer spend normally, and the kernel has no timelock script,
+// so the keeper never requests signer signatures without the holder approval
+// and the safe timelock is only a promise of the observer, see mixin.KernelAccount
+
+func (node *Node) processMixinSafeProposeAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	rce := req.ExtraBytes()
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(rce) == 32 && len(ver.References) == 1 && ver.References[0].String() == req.ExtraHEX {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		rce = stx.Extra
+	}
+	arp, err := req.ParseMixinRecipient(ctx, node.mixin, rce)
+	logger.Printf("req.ParseMixinRecipient(%v) => %v %v", req, arp, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("node.ReadLatestOperationParams(%d) => %v", chain, err))
+	} else if plan == nil || !plan.OperationPriceAmount.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if req.AssetId != plan.OperationPriceAsset {
+		return node.failRequest(ctx, req, "")
+	}
+	if req.Amount.Cmp(plan.OperationPriceAmount) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if safe != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	old, err := node.store.ReadSafeProposal(ctx, req.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%s) => %v", req.Id, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	signer, observer, err := node.store.AssignSignerAndObserverToHolder(ctx, req, SafeKeyBackupMaturity, arp.Observer)
+	logger.Printf("store.AssignSignerAndObserverToHolder(%s) => %s %s %v", req.Holder, signer, observer, err)
+	if err != nil {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v", req, err))
+	}
+	if signer == "" || observer == "" {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if !common.CheckUnique(req.Holder, signer, observer) {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	path := mixinDefaultDerivationPath()
+
+	ka, err := mixin.BuildKernelAccount(req.Holder, signer, observer)
+	logger.Verbosef("mixin.BuildKernelAccount(%v) => %v %v", req, ka, err)
+	if err != nil {
+		panic(err)
+	}
+	old, err = node.store.ReadSafeProposalByAddress(ctx, ka.Address)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", ka.Address, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := ka.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionMixinSafeProposeAccount)
+	crv := common.SafeChainCurve(chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs = append(txs, t)
+
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     chain,
+		Holder:    req.Holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  arp.Timelock,
+		Path:      hex.EncodeToString(path),
+		Address:   ka.Address,
+		Extra:     extra,
+		Receivers: arp.Receivers,
+		Threshold: arp.Threshold,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	err = node.store.WriteSafeProposalWithRequest(ctx, sp, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processMixinSafeApproveAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	old, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	assetId := common.SafeChainAssetId(chain)
+	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, assetId, req.Holder)
+
+	extra := req.ExtraBytes()
+	if len(extra) != 16+64 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	sp, err := node.store.ReadSafeProposal(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%v) => %s %v", req, rid.String(), err))
+	} else if sp == nil {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid.String(), sp.Address)
+	err = mixin.VerifySignature(req.Holder, []byte(ms), extra[16:])
+	logger.Printf("mixin.VerifySignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	spr, err := node.store.ReadRequest(ctx, sp.RequestId)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadRequest(%s) => %v", sp.RequestId, err))
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(sp.Extra)))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionMixinSafeApproveAccount)
+	crv := common.SafeChainCurve(sp.Chain)
+	t := node.buildObserverResponseWithAssetAndStorageTraceId(ctx, req.Id, req.Output, typ, crv, spr.AssetId, spr.Amount.String(), stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, spr.AssetId)
+	}
+	txs = append(txs, t)
+
+	safe := &store.Safe{
+		Holder:      sp.Holder,
+		Chain:       sp.Chain,
+		Signer:      sp.Signer,
+		Observer:    sp.Observer,
+		Timelock:    sp.Timelock,
+		Path:        sp.Path,
+		Address:     sp.Address,
+		Extra:       sp.Extra,
+		Receivers:   sp.Receivers,
+		Threshold:   sp.Threshold,
+		RequestId:   req.Id,
+		State:       SafeStateApproved,
+		SafeAssetId: safeAssetId,
+		CreatedAt:   req.CreatedAt,
+		UpdatedAt:   req.CreatedAt,
+	}
+	err = node.store.WriteSafeWithRequest(ctx, safe, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processMixinSafeProposeTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	meta, err := node.fetchAssetMeta(ctx, req.AssetId)
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", req.AssetId, meta, err)
+	if err != nil {
+		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
+	}
+	if meta.Chain != ethereum.BondChain().Chain {
+		return node.failRequest(ctx, req, "")
+	}
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.evmRPC(ethereum.BondChain().Chain), meta.AssetKey)
+	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
+	if err != nil || deployed.Sign() <= 0 {
+		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
+	}
+	id := uuid.Must(uuid.FromBytes(deployed.Bytes()))
+	assetId := id.String()
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", safe.Chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestOperationParams(%d) => %v", safe.Chain, err))
+	} else if plan == nil || !plan.TransactionMinimum.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if req.Amount.Cmp(plan.TransactionMinimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	entry := node.fetchBondAssetReceiver(ctx, safe.Address, assetId)
+	safeAssetId := node.getBondAssetId(ctx, entry, assetId, req.Holder)
+	logger.Printf("node.getBondAssetId(%s, %s, %s) => %s", entry, assetId, req.Holder, safeAssetId)
+	if req.AssetId != safeAssetId {
+		panic(req.AssetId)
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) < 2 || extra[0] != common.FlagProposeNormalTransaction {
+		return node.failRequest(ctx, req, "")
+	}
+	extra = extra[1:]
+
+	var outputs []*mixin.TransactionOutput
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(extra) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra) {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		extra := stx.Extra
+		recipients, err := common.DecodeRecipients(extra)
+		logger.Printf("common.DecodeRecipients(%x) => %d %v", extra, len(recipients), err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		for _, rp := range recipients {
+			if len(rp.Memo) > 0 {
+				return node.failRequest(ctx, req, "")
+			}
+			if rp.AssetId != "" && rp.AssetId != assetId {
+				return node.failRequest(ctx, req, "")
+			}
+			addr, err := mixin.ParseAddress(rp.Address)
+			logger.Printf("mixin.ParseAddress(%s) => %v %v", rp.Address, addr, err)
+			if err != nil {
+				return node.failRequest(ctx, req, "")
+			}
+			if rp.Amount.Exponent() < -mixin.ValuePrecision {
+				return node.failRequest(ctx, req, "")
+			}
+			if rp.Amount.Cmp(plan.TransactionMinimum) < 0 {
+				return node.failRequest(ctx, req, "")
+			}
+			outputs = append(outputs, &mixin.TransactionOutput{
+				Address: rp.Address,
+				Amount:  rp.Amount,
+			})
+		}
+	} else {
+		addr, err := mixin.ParseAddress(string(extra))
+		logger.Printf("mixin.ParseAddress(%s) => %v %v", string(extra), addr, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		outputs = []*mixin.TransactionOutput{{
+			Address: string(extra),
+			Amount:  req.Amount,
+		}}
+	}
+
+	total := decimal.Zero
+	recipients := make([]map[string]string, len(outputs))
+	for i, out := range outputs {
+		recipients[i] = map[string]string{
+			"receiver": out.Address, "amount": out.Amount.String(),
+		}
+		total = total.Add(out.Amount)
+	}
+	if len(outputs) >= mixin.MaxUnspentUtxo || !total.Equal(req.Amount) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	mainInputs, err := node.store.ListAllMixinUTXOsForHolderAndAsset(ctx, req.Holder, assetId)
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllMixinUTXOsForHolderAndAsset(%s, %s) => %v", req.Holder, assetId, err))
+	}
+	balance := decimal.Zero
+	for _, in := range mainInputs {
+		balance = balance.Add(in.Amount)
+	}
+	if balance.Cmp(total) < 0 || len(mainInputs) > mixin.MaxUnspentUtxo {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	seed := crypto.Sha256Hash([]byte(node.conf.SharedKey + req.Id))
+	psbt, err := mixin.BuildPartiallySignedTransaction(assetId, mainInputs, outputs, safe.Address, req.Id, seed[:])
+	logger.Printf("mixin.BuildPartiallySignedTransaction(%v) => %v %v", req, psbt, err)
+	if err != nil {
+		panic(fmt.Errorf("mixin.BuildPartiallySignedTransaction(%v) => %v", req, err))
+	}
+
+	extra = psbt.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionMixinSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs = append(txs, t)
+
+	data := common.MarshalJSONOrPanic(recipients)
+	tx := &store.Transaction{
+		TransactionHash: psbt.Hash(),
+		RawTransaction:  hex.EncodeToString(extra),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         assetId,
+		State:           common.RequestStateInitial,
+		Data:            string(data),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	transacionInputs := store.TransactionInputsFromMixin(mainInputs)
+	err = node.store.WriteTransactionWithRequest(ctx, tx, transacionInputs, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processMixinSafeApproveTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 16+64 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
+	} else if tx == nil {
+		return node.failRequest(ctx, req, "")
+	} else if tx.State == common.RequestStateDone {
+		return node.failRequest(ctx, req, "")
+	} else if tx.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	psbt, err := mixin.UnmarshalPartiallySignedTransaction(b)
+	if err != nil {
+		panic(err)
+	}
+	// this is the only path to request the signer signatures of a kernel
+	// safe, and the holder signature binds the approval to the transaction
+	err = psbt.VerifySignature(safe.Holder, 0, extra[16:])
+	logger.Printf("psbt.VerifySignature(%s, %x) => %v", tx.TransactionHash, extra[16:], err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var requests []*store.SignatureRequest
+	for idx := range psbt.Inputs {
+		pending, err := node.checkTransactionIndexSignaturePending(ctx, tx.TransactionHash, idx, req)
+		logger.Printf("node.checkTransactionIndexSignaturePending(%s, %d) => %t %v", tx.TransactionHash, idx, pending, err)
+		if err != nil {
+			panic(err)
+		} else if pending {
+			continue
+		}
+
+		sr := &store.SignatureRequest{
+			TransactionHash: tx.TransactionHash,
+			InputIndex:      idx,
+			Signer:          safe.Signer,
+			Curve:           req.Curve,
+			Message:         hex.EncodeToString(psbt.SigHash(idx)),
+			State:           common.RequestStateInitial,
+			CreatedAt:       req.CreatedAt,
+			UpdatedAt:       req.CreatedAt,
+		}
+		sr.RequestId = common.UniqueId(req.Id, sr.Message)
+		requests = append(requests, sr)
+	}
+
+	txs := node.buildSignerSignRequests(ctx, req, requests, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, requests, tx.TransactionHash, "", req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, len(requests), req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processMixinSafeSignatureResponse(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, old *store.SignatureRequest) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	spsbt, err := mixin.UnmarshalPartiallySignedTransaction(b)
+	if err != nil {
+		panic(err)
+	}
+	err = spsbt.VerifySignature(safe.Signer, old.InputIndex, req.ExtraBytes())
+	logger.Printf("mixin.VerifySignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	err = node.store.FinishSignatureRequest(ctx, req)
+	logger.Printf("store.FinishSignatureRequest(%s) => %v", req.Id, err)
+	if err != nil {
+		panic(fmt.Errorf("store.FinishSignatureRequest(%s) => %v", req.Id, err))
+	}
+
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, old.TransactionHash, common.RequestStatePending)
+	logger.Printf("store.ListAllSignaturesForTransaction(%s) => %d %v", old.TransactionHash, len(requests), err)
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllSignaturesForTransaction(%s) => %v", old.TransactionHash, err))
+	}
+
+	for idx := range spsbt.Inputs {
+		sr := requests[idx]
+		if sr == nil {
+			return node.failRequest(ctx, req, "")
+		}
+		msg := common.DecodeHexOrPanic(sr.Message)
+		if !bytes.Equal(spsbt.SigHash(idx), msg) {
+			panic(sr.Message)
+		}
+		sig := common.DecodeHexOrPanic(sr.Signature.String)
+		err = spsbt.VerifySignature(safe.Signer, idx, sig)
+		if err != nil {
+			panic(sr.Signature.String)
+		}
+		spsbt.Signatures[idx] = sig
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(spsbt.Marshal())))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	id := common.UniqueId(old.TransactionHash, stx.TraceId)
+	typ := byte(common.ActionMixinSafeApproveTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, t)
+
+	raw := hex.EncodeToString(spsbt.Marshal())
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, int64(len(spsbt.Inputs)), safe, nil, txs)
+	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) doMixinHolderDeposit(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe, safeAssetId string, asset *store.Asset, minimum decimal.Decimal) ([]*mtg.Transaction, string) {
+	old, _, err := node.store.ReadMixinUTXO(ctx, deposit.Hash, int(deposit.Index))
+	logger.Printf("store.ReadMixinUTXO(%s, %d) => %v %v", deposit.Hash, deposit.Index, old, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadMixinUTXO(%s, %d) => %v", deposit.Hash, deposit.Index, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	deposited, err := node.store.ReadDeposit(ctx, deposit.Hash, int64(deposit.Index))
+	logger.Printf("store.ReadDeposit(%s, %d, %s, %s) => %v %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, deposited, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadDeposit(%s, %d, %s, %s) => %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, err))
+	} else if deposited != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	c, err := node.store.ReadUnspentMixinUtxoCountForSafe(ctx, safe.Address, asset.AssetId)
+	logger.Printf("store.ReadUnspentMixinUtxoCountForSafe(%s, %s) => %d %v", safe.Address, asset.AssetId, c, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadUnspentMixinUtxoCountForSafe(%s) => %d %v", safe.Address, c, err))
+	}
+	if c >= mixin.MaxUnspentUtxo {
+		return node.failRequest(ctx, req, "")
+	}
+
+	mtx, err := mixin.RPCGetTransaction(ctx, node.conf.MixinRPC, deposit.Hash)
+	if err != nil {
+		panic(fmt.Errorf("mixin.RPCGetTransaction(%s) => %v", deposit.Hash, err))
+	}
+	input, err := node.verifyMixinTransaction(deposit, safe, mtx)
+	logger.Printf("node.verifyMixinTransaction(%v) => %v %v", req, input, err)
+	if err != nil {
+		panic(fmt.Errorf("node.verifyMixinTransaction(%s) => %v", deposit.Hash, err))
+	}
+	if input == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	change, err := node.checkMixinChange(ctx, deposit, mtx)
+	logger.Printf("node.checkMixinChange(%v, %v) => %t %v", deposit, mtx, change, err)
+	if err != nil {
+		panic(fmt.Errorf("node.checkMixinChange(%v) => %v", deposit, err))
+	}
+	if input.Amount.Cmp(minimum) < 0 && !change {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var txs []*mtg.Transaction
+	if !change {
+		tx := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), input.Amount.String(), nil, req.Id)
+		if tx == nil {
+			// no compaction needed, just retry from observer
+			return node.failRequest(ctx, req, "")
+		}
+		txs = append(txs, tx)
+	}
+
+	err = node.store.WriteMixinOutputFromRequest(ctx, safe, input, req, asset.AssetId, "", txs)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) checkMixinChange(ctx context.Context, deposit *Deposit, mtx *mixin.RPCTransaction) (bool, error) {
+	if len(mtx.Input) == 0 {
+		return false, nil
+	}
+	vin, spentBy, err := node.store.ReadMixinUTXO(ctx, mtx.Input[0].Hash, mtx.Input[0].Index)
+	if err != nil || vin == nil {
+		return false, err
+	}
+	tx, err := node.store.ReadTransaction(ctx, spentBy)
+	if err != nil || tx == nil {
+		return false, err
+	}
+	var recipients []map[string]string
+	err = json.Unmarshal([]byte(tx.Data), &recipients)
+	if err != nil || len(recipients) == 0 {
+		return false, fmt.Errorf("store.ReadTransaction(%s) => %s", spentBy, tx.Data)
+	}
+	return deposit.Index >= uint64(len(recipients)), nil
+}
+
+func (node *Node) verifyMixinTransaction(deposit *Deposit, safe *store.Safe, mtx *mixin.RPCTransaction) (*mixin.Input, error) {
+	if mtx == nil || mtx.Snapshot == "" {
+		return nil, fmt.Errorf("malicious mixin deposit or node not in sync? %s", deposit.Hash)
+	}
+	asset := crypto.Sha256Hash([]byte(deposit.Asset))
+	if mtx.Asset != asset.String() || int(deposit.Index) >= len(mtx.Output) {
+		return nil, fmt.Errorf("malicious mixin deposit %s", deposit.Hash)
+	}
+
+	out := mtx.Output[deposit.Index]
+	if out.Type != 0 || out.Script != mixin.KernelAccountScript() {
+		return nil, nil
+	}
+	members := mixin.KernelAccountKeys(safe.Holder, safe.Signer, safe.Observer)
+	if len(out.Keys) != len(members) {
+		return nil, nil
+	}
+	for i, m := range members {
+		key, err := mixin.DeriveOutputKey(m, deposit.Mask)
+		if err != nil {
+			return nil, nil
+		}
+		if out.Keys[i] != key.String() {
+			return nil, fmt.Errorf("malicious mixin deposit %s", deposit.Hash)
+		}
+	}
+	amount := decimal.NewFromBigInt(deposit.Amount, -mixin.ValuePrecision)
+	amt, err := decimal.NewFromString(out.Amount)
+	if err != nil || !amt.Equal(amount) {
+		return nil, fmt.Errorf("malicious mixin deposit %s", deposit.Hash)
+	}
+
+	return &mixin.Input{
+		TransactionHash: deposit.Hash,
+		Index:           uint32(deposit.Index),
+		Amount:          amount,
+		Mask:            deposit.Mask,
+	}, nil
+}
@@ -0,0 +1,481 @@
+package keeper
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMixinKeyHolder   = "3a5b1e4c3f0d8b0bd2a6e4a1f4c45b0b3e9b3c4d2f5e6a7b8c9d0e1f2a3b4c5d"
+	testMixinKeyObserver = "a9f3d0b2c1e4f5a6b7c8d9e0f1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4"
+
+	testMixinKernelAssetId         = "c94ac88f-4671-3976-b60a-09064f1811e8"
+	testMixinTransactionReceiver   = "XINZrJcfd6QoKrR7Q31YY7gk2zvbU1qkAAZ4xBan4KQYeDpTvAtMJQookpcjwbPDtJ4u8VELsbyymtLiUiEzpq6KtyjGNckr"
+	testMixinKernelSnapshotDefault = "b3ea58b8fa9d5a4a8d1e2f9c6b2b0f7a3e5c1d4f6a8b0c2e4d6f8a0b2c4d6e8f"
+)
+
+func TestMixinKeeper(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, signers := testMixinPrepare(require)
+
+	holder := testMixinHolderPublicKey()
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(byte(common.SafeChainMixin), safe.Chain)
+	require.Equal(mpc, safe.Signer)
+	require.Equal(testMixinObserverPublicKey(), safe.Observer)
+	require.Equal(time.Duration(0), safe.Timelock)
+
+	kernel := testMixinKernelRPC(node)
+	defer kernel.Close()
+
+	bondId := testDeployBondContract(ctx, require, node, safe.Address, testMixinKernelAssetId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	action := &mtg.Action{
+		UnifiedOutput: *output,
+	}
+	node.ProcessOutput(ctx, action)
+
+	deposit := testMixinBuildKernelDeposit(require, safe, "1.5")
+	testMixinObserverHolderDeposit(ctx, require, node, kernel, deposit, 0, true)
+	utxos, err := node.store.ListAllMixinUTXOsForHolderAndAsset(ctx, holder, testMixinKernelAssetId)
+	require.Nil(err)
+	require.Len(utxos, 1)
+	require.Equal("1.5", utxos[0].Amount.String())
+	outputs, err := node.store.ListAllMixinUTXOsForAddress(ctx, safe.Address)
+	require.Nil(err)
+	require.Len(outputs, 1)
+	require.Equal(testMixinKernelAssetId, outputs[0].AssetId)
+	require.Equal("1.5", outputs[0].Amount.String())
+
+	txHash := testMixinProposeTransaction(ctx, require, node, bondId, "a8d3c2b1-5e4f-4a6b-9c7d-0e1f2a3b4c5d", deposit.Hash)
+	testMixinRevokeTransaction(ctx, require, node, txHash)
+	txHash = testMixinProposeTransaction(ctx, require, node, bondId, "a8d3c2b1-5e4f-4a6b-9c7d-0e1f2a3b4c5e", deposit.Hash)
+	spent := testMixinApproveTransaction(ctx, require, node, txHash, signers)
+
+	// the change output of the spent transaction is deposited back to the
+	// safe without any bond transfer, and the recipient output is ignored
+	testMixinObserverHolderDeposit(ctx, require, node, kernel, spent, 1, false)
+	utxo, _, err := node.store.ReadMixinUTXO(ctx, spent.Hash, 1)
+	require.Nil(err)
+	require.Equal("0.3", utxo.Amount.String())
+
+	txs, err := node.store.ListTransactionsByHolder(ctx, holder, time.Time{}, "", 1)
+	require.Nil(err)
+	require.Len(txs, 1)
+	next, err := node.store.ListTransactionsByHolder(ctx, holder, txs[0].CreatedAt, txs[0].TransactionHash, 10)
+	require.Nil(err)
+	require.Len(next, 1)
+	require.NotEqual(txs[0].TransactionHash, next[0].TransactionHash)
+	require.Contains([]string{txs[0].TransactionHash, next[0].TransactionHash}, txHash)
+	next, err = node.store.ListTransactionsByHolder(ctx, holder, next[0].CreatedAt, next[0].TransactionHash, 10)
+	require.Nil(err)
+	require.Len(next, 0)
+}
+
+func testMixinPrepare(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
+	logger.SetLevel(logger.INFO)
+	ctx, signers, _ := signer.TestPrepare(require)
+	mpc := signer.TestFROSTPrepareKeys(ctx, require, signers, common.CurveEdwards25519Mixin)
+
+	root, err := os.MkdirTemp("", "safe-keeper-test-")
+	require.Nil(err)
+	node, db := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveEdwards25519Mixin)
+
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := append([]byte{common.RequestRoleSigner}, make([]byte, 32)...)
+	extra = append(extra, common.RequestFlagNone)
+	out := testBuildSignerOutput(node, id, mpc, common.OperationTypeKeygenOutput, extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+	v, err := node.store.ReadProperty(ctx, id)
+	require.Nil(err)
+	require.Equal("", v)
+	testSpareKeys(ctx, require, node, 0, 1, 0, common.CurveEdwards25519Mixin)
+
+	id = uuid.Must(uuid.NewV4()).String()
+	observer := testMixinObserverPublicKey()
+	extra = append([]byte{common.RequestRoleObserver}, make([]byte, 32)...)
+	extra = append(extra, common.RequestFlagNone)
+	out = testBuildObserverRequest(node, id, observer, common.ActionObserverAddKey, extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+	v, err = node.store.ReadProperty(ctx, id)
+	require.Nil(err)
+	require.Equal("", v)
+	testSpareKeys(ctx, require, node, 0, 1, 1, common.CurveEdwards25519Mixin)
+
+	for i := 0; i < 10; i++ {
+		testMixinUpdateAccountPrice(ctx, require, node)
+	}
+	rid, ka := testMixinProposeAccount(ctx, require, node, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveEdwards25519Mixin)
+	testMixinApproveAccount(ctx, require, node, rid, ka, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveEdwards25519Mixin)
+	return ctx, node, db, mpc, signers
+}
+
+func testMixinProposeAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string) (string, *mixin.KernelAccount) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testMixinHolderPublicKey()
+	extra := testMixinRecipient()
+	price := decimal.NewFromFloat(testAccountPriceAmount)
+	out := testBuildHolderRequest(node, id, holder, common.ActionMixinSafeProposeAccount, testAccountPriceAssetId, extra, price)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionMixinSafeProposeAccount)
+	ka, err := mixin.UnmarshalKernelAccount(b)
+	require.Nil(err)
+	require.Equal(holder, ka.Holder)
+	require.Equal(signer, ka.Signer)
+	require.Equal(observer, ka.Observer)
+
+	expected, err := mixin.BuildKernelAccount(holder, signer, observer)
+	require.Nil(err)
+	require.Equal(expected.Address, ka.Address)
+
+	safe, err := node.store.ReadSafeProposal(ctx, id)
+	require.Nil(err)
+	require.Equal(id, safe.RequestId)
+	require.Equal(holder, safe.Holder)
+	require.Equal(signer, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	require.Equal(ka.Address, safe.Address)
+	require.Equal(byte(1), safe.Threshold)
+	require.Len(safe.Receivers, 1)
+	require.Equal(testSafeBondReceiverId, safe.Receivers[0])
+
+	return id, ka
+}
+
+func testMixinApproveAccount(ctx context.Context, require *require.Assertions, node *Node, rid string, ka *mixin.KernelAccount, signer, observer string) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testMixinHolderPublicKey()
+
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid, ka.Address)
+	seed, _ := hex.DecodeString(testMixinKeyHolder)
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), []byte(ms))
+	extra := uuid.FromStringOrNil(rid).Bytes()
+	extra = append(extra, sig...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionMixinSafeApproveAccount, extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionMixinSafeApproveAccount)
+	approved, err := mixin.UnmarshalKernelAccount(b)
+	require.Nil(err)
+	require.Equal(ka, approved)
+
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(id, safe.RequestId)
+	require.Equal(holder, safe.Holder)
+	require.Equal(signer, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	require.Equal(ka.Address, safe.Address)
+	require.Equal(byte(1), safe.Threshold)
+	require.Len(safe.Receivers, 1)
+	require.Equal(testSafeBondReceiverId, safe.Receivers[0])
+}
+
+func testMixinUpdateAccountPrice(ctx context.Context, require *require.Assertions, node *Node) {
+	id := uuid.Must(uuid.NewV4()).String()
+
+	extra := []byte{common.SafeChainMixin}
+	extra = append(extra, uuid.Must(uuid.FromString(testAccountPriceAssetId)).Bytes()...)
+	extra = binary.BigEndian.AppendUint64(extra, testAccountPriceAmount*100000000)
+	extra = binary.BigEndian.AppendUint64(extra, 10000)
+	dummy := testMixinHolderPublicKey()
+	out := testBuildObserverRequest(node, id, dummy, common.ActionObserverSetOperationParams, extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, common.SafeChainMixin, time.Now())
+	require.Nil(err)
+	require.Equal(testAccountPriceAssetId, plan.OperationPriceAsset)
+	require.Equal(fmt.Sprint(testAccountPriceAmount), plan.OperationPriceAmount.String())
+	require.Equal("0.0001", plan.TransactionMinimum.String())
+}
+
+func testMixinRecipient() []byte {
+	extra := binary.BigEndian.AppendUint16(nil, 0)
+	extra = append(extra, 1, 1)
+	id := uuid.FromStringOrNil(testSafeBondReceiverId)
+	return append(extra, id.Bytes()...)
+}
+
+func testMixinHolderPublicKey() string {
+	seed, _ := hex.DecodeString(testMixinKeyHolder)
+	priv := ed25519.NewKeyFromSeed(seed)
+	return hex.EncodeToString(priv.Public().(ed25519.PublicKey))
+}
+
+func testMixinObserverPublicKey() string {
+	key := testMixinObserverPrivateKey()
+	return key.Public().String()
+}
+
+func testMixinObserverPrivateKey() mixinnet.Key {
+	seed, _ := hex.DecodeString(testMixinKeyObserver)
+	sum := sha512.Sum512(seed)
+	return mixinnet.KeyFromBytes(sum[:])
+}
+
+func testMixinProposeTransaction(ctx context.Context, require *require.Assertions, node *Node, bondId, rid, input string) string {
+	holder := testMixinHolderPublicKey()
+	extra := []byte{common.FlagProposeNormalTransaction}
+	extra = append(extra, []byte(testMixinTransactionReceiver)...)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionMixinSafeProposeTransaction, bondId, extra, decimal.RequireFromString("1.2"))
+	testStep(ctx, require, node, out)
+
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionMixinSafeProposeTransaction)
+	psbt, err := mixin.UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	asset := crypto.Sha256Hash([]byte(testMixinKernelAssetId))
+	require.Equal(asset.String(), psbt.Asset.String())
+	require.Len(psbt.Inputs, 1)
+	require.Equal(input, psbt.Inputs[0].Hash.String())
+	require.Len(psbt.Outputs, 2)
+	require.Equal("1.20000000", psbt.Outputs[0].Amount.String())
+	require.Len(psbt.Outputs[0].Keys, 1)
+	require.Equal("0.30000000", psbt.Outputs[1].Amount.String())
+	require.Len(psbt.Outputs[1].Keys, 3)
+	require.Equal(mixin.KernelAccountScript(), psbt.Outputs[1].Script.String())
+
+	stx, err := node.store.ReadTransaction(ctx, psbt.Hash())
+	require.Nil(err)
+	require.Equal(hex.EncodeToString(psbt.Marshal()), stx.RawTransaction)
+	require.Equal(fmt.Sprintf("[{\"amount\":\"1.2\",\"receiver\":\"%s\"}]", testMixinTransactionReceiver), stx.Data)
+	require.Equal(common.RequestStateInitial, stx.State)
+	return stx.TransactionHash
+}
+
+func testMixinRevokeTransaction(ctx context.Context, require *require.Assertions, node *Node, transactionHash string) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testMixinHolderPublicKey()
+
+	tx, _ := node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	seed, _ := hex.DecodeString(testMixinKeyHolder)
+	ms := fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash)
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), []byte(ms))
+	extra := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+	extra = append(extra, sig...)
+
+	out := testBuildObserverRequest(node, id, holder, common.ActionMixinSafeRevokeTransaction, extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 0)
+	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateFailed, tx.State)
+
+	utxos, err := node.store.ListAllMixinUTXOsForHolderAndAsset(ctx, holder, testMixinKernelAssetId)
+	require.Nil(err)
+	require.Len(utxos, 1)
+}
+
+func testMixinApproveTransaction(ctx context.Context, require *require.Assertions, node *Node, transactionHash string, signers []*signer.Node) *mixin.RPCTransaction {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testMixinHolderPublicKey()
+
+	tx, _ := node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+	safe, _ := node.store.ReadSafe(ctx, tx.Holder)
+
+	psbt, err := mixin.UnmarshalPartiallySignedTransactionHex(tx.RawTransaction)
+	require.Nil(err)
+	seed, _ := hex.DecodeString(testMixinKeyHolder)
+	err = psbt.SignWithHolderKey(ed25519.NewKeyFromSeed(seed))
+	require.Nil(err)
+	require.Nil(psbt.VerifyHolderSignature(holder))
+
+	// the plain holder signature of the transaction hash is not accepted
+	hash := common.DecodeHexOrPanic(psbt.Hash())
+	invalid := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+	invalid = append(invalid, ed25519.Sign(ed25519.NewKeyFromSeed(seed), hash)...)
+	out := testBuildObserverRequest(node, uuid.Must(uuid.NewV4()).String(), holder, common.ActionMixinSafeApproveTransaction, invalid, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 0)
+
+	extra := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+	extra = append(extra, psbt.HolderSignatures[0]...)
+	out = testBuildObserverRequest(node, id, holder, common.ActionMixinSafeApproveTransaction, extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+	requests, err = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 1)
+	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStatePending, tx.State)
+
+	msg, _ := hex.DecodeString(requests[0].Message)
+	require.Equal(psbt.SigHash(0), msg)
+	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveEdwards25519Mixin)
+	op := signer.TestProcessOutput(ctx, require, signers, out, requests[0].RequestId)
+	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, out)
+
+	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateDone, tx.State)
+	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateDone)
+	require.Len(requests, 1)
+
+	sig := common.DecodeHexOrPanic(requests[0].Signature.String)
+	require.Nil(psbt.VerifySignature(safe.Signer, 0, sig))
+	psbt.Signatures[0] = sig
+	require.True(psbt.IsFullySigned())
+	raw, err := psbt.SignedTransaction(safe.Holder, safe.Signer, safe.Observer)
+	require.Nil(err)
+	signed, err := mixinnet.TransactionFromData(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	sh, err := signed.TransactionHash()
+	require.Nil(err)
+	require.Equal(transactionHash, sh.String())
+	require.Len(signed.Signatures[0], 2)
+
+	spent := &mixin.RPCTransaction{
+		Version:  psbt.Version,
+		Asset:    psbt.Asset.String(),
+		Hash:     transactionHash,
+		Snapshot: testMixinKernelSnapshotDefault,
+		Input: []mixin.RPCInput{{
+			Hash:  psbt.Inputs[0].Hash.String(),
+			Index: int(psbt.Inputs[0].Index),
+		}},
+	}
+	for _, o := range psbt.Outputs {
+		var keys []string
+		for _, k := range o.Keys {
+			keys = append(keys, k.String())
+		}
+		spent.Output = append(spent.Output, mixin.Output{
+			Amount: o.Amount.String(),
+			Keys:   keys,
+			Script: o.Script.String(),
+			Mask:   o.Mask.String(),
+		})
+	}
+	return spent
+}
+
+func testMixinObserverHolderDeposit(ctx context.Context, require *require.Assertions, node *Node, kernel *testMixinKernel, tx *mixin.RPCTransaction, index int, bond bool) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testMixinHolderPublicKey()
+	safe, _ := node.store.ReadSafe(ctx, holder)
+	kernel.writeTransaction(tx)
+
+	out := tx.Output[index]
+	members := mixin.KernelAccountKeys(safe.Holder, safe.Signer, safe.Observer)
+	mask, ok := mixin.ViewOutputMask(members, testMixinObserverPrivateKey(), out.Keys, out.Mask, uint8(index))
+	require.True(ok)
+	amount := decimal.RequireFromString(out.Amount).Shift(mixin.ValuePrecision)
+
+	extra := []byte{common.SafeChainMixin}
+	extra = append(extra, uuid.Must(uuid.FromString(testMixinKernelAssetId)).Bytes()...)
+	extra = append(extra, common.DecodeHexOrPanic(tx.Hash)...)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(index))
+	extra = append(extra, mask...)
+	extra = append(extra, amount.BigInt().Bytes()...)
+	action := testBuildObserverRequest(node, id, holder, common.ActionObserverHolderDeposit, extra, common.CurveEdwards25519Mixin)
+	testStep(ctx, require, node, action)
+
+	req, err := node.store.ReadRequest(ctx, id)
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, req.State)
+	utxo, _, err := node.store.ReadMixinUTXO(ctx, tx.Hash, index)
+	require.Nil(err)
+	require.NotNil(utxo)
+	require.Equal(mask, utxo.Mask)
+
+	ar, _, err := node.store.ReadActionResult(ctx, action.OutputId, id)
+	require.Nil(err)
+	require.Equal(bond, len(ar.Transactions) > 0)
+}
+
+func testMixinBuildKernelDeposit(require *require.Assertions, safe *store.Safe, amount string) *mixin.RPCTransaction {
+	r := mixinnet.GenerateKey(rand.Reader)
+	view := testMixinObserverPrivateKey().Public()
+	var keys []string
+	for _, pub := range mixin.KernelAccountKeys(safe.Holder, safe.Signer, safe.Observer) {
+		spend, err := mixin.ParseKey(pub)
+		require.Nil(err)
+		key := mixinnet.DeriveGhostPublicKey(mixinnet.TxVersion, &r, &view, &spend, 0)
+		keys = append(keys, key.String())
+	}
+	hash := crypto.Sha256Hash([]byte(r.String()))
+	input := crypto.Sha256Hash([]byte(amount))
+	return &mixin.RPCTransaction{
+		Version:  mixinnet.TxVersion,
+		Asset:    crypto.Sha256Hash([]byte(testMixinKernelAssetId)).String(),
+		Hash:     hash.String(),
+		Snapshot: testMixinKernelSnapshotDefault,
+		Input:    []mixin.RPCInput{{Hash: input.String(), Index: 0}},
+		Output: []mixin.Output{{
+			Amount: decimal.RequireFromString(amount).StringFixed(mixin.ValuePrecision),
+			Keys:   keys,
+			Script: mixin.KernelAccountScript(),
+			Mask:   r.Public().String(),
+		}},
+	}
+}
+
+// testMixinKernel is a fake kernel RPC node, which only serves the
+// transactions written by the tests
+type testMixinKernel struct {
+	*httptest.Server
+	sync.Mutex
+	transactions map[string]*mixin.RPCTransaction
+}
+
+func testMixinKernelRPC(node *Node) *testMixinKernel {
+	kernel := &testMixinKernel{transactions: make(map[string]*mixin.RPCTransaction)}
+	kernel.Server = httptest.NewServer(http.HandlerFunc(kernel.handle))
+	node.conf.MixinRPC = kernel.URL
+	return kernel
+}
+
+func (k *testMixinKernel) writeTransaction(tx *mixin.RPCTransaction) {
+	k.Lock()
+	defer k.Unlock()
+	k.transactions[tx.Hash] = tx
+}
+
+func (k *testMixinKernel) handle(w http.ResponseWriter, r *http.Request) {
+	var call struct {
+		Method string `json:"method"`
+		Params []any  `json:"params"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&call)
+	if err != nil || call.Method != "gettransaction" || len(call.Params) != 1 {
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": "invalid request"})
+		return
+	}
+	k.Lock()
+	tx := k.transactions[fmt.Sprint(call.Params[0])]
+	k.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": tx})
+}
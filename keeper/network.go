@@ -58,8 +58,8 @@ func (node *Node) writeNetworkInfo(ctx context.Context, req *common.Request) ([]
 	if info.Chain != common.SafeCurveChain(req.Curve) {
 		panic(req.Id)
 	}
-	switch info.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(info.Chain) {
+	case common.SafeChainBitcoin:
 		info.Hash = hex.EncodeToString(extra[17:])
 		valid, err := node.verifyBitcoinNetworkInfo(info, old)
 		logger.Printf("node.verifyBitcoinNetworkInfo(%s, %v) => %t", req.Id, info, valid)
@@ -68,7 +68,7 @@ func (node *Node) writeNetworkInfo(ctx context.Context, req *common.Request) ([]
 		} else if !valid {
 			return node.failRequest(ctx, req, "")
 		}
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		info.Hash = "0x" + hex.EncodeToString(extra[17:])
 		valid, err := node.verifyEthereumNetworkInfo(info, old)
 		logger.Printf("node.verifyEthereumNetworkInfo(%s, %v) => %t", req.Id, info, valid)
@@ -101,11 +101,10 @@ func (node *Node) writeOperationParams(ctx context.Context, req *common.Request)
 	if chain != common.SafeCurveChain(req.Curve) {
 		panic(req.Id)
 	}
-	switch chain {
+	switch common.SafeChainFamily(chain) {
 	case common.SafeChainBitcoin:
-	case common.SafeChainLitecoin:
+	case common.SafeChainMixin:
 	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -184,20 +183,15 @@ func (node *Node) bitcoinParams(chain byte) (string, string) {
 		return node.conf.BitcoinRPC, common.SafeBitcoinChainId
 	case common.SafeChainLitecoin:
 		return node.conf.LitecoinRPC, common.SafeLitecoinChainId
+	case common.SafeChainBitcoinCash:
+		return node.conf.BitcoinCashRPC, common.SafeBitcoinCashChainId
 	default:
 		panic(chain)
 	}
 }
 
 func (node *Node) ethereumParams(chain byte) (string, string) {
-	switch chain {
-	case common.SafeChainEthereum:
-		return node.conf.EthereumRPC, common.SafeEthereumChainId
-	case common.SafeChainPolygon:
-		return node.conf.PolygonRPC, common.SafePolygonChainId
-	default:
-		panic(chain)
-	}
+	return node.conf.evmRPC(chain), common.SafeChainAssetId(chain)
 }
 
 func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id, assetContract string, chain byte) (*store.Asset, error) {
@@ -205,10 +199,7 @@ func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id,
 	if err != nil || meta != nil {
 		return meta, err
 	}
-	switch chain {
-	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
-	default:
+	if !ethereum.IsEVMChain(chain) {
 		panic(chain)
 	}
 	rpc, _ := node.ethereumParams(chain)
@@ -262,7 +253,7 @@ func (node *Node) fetchMixinAsset(_ context.Context, id string) (*store.Asset, e
 		Symbol:    asset.Symbol,
 		Name:      asset.Name,
 		Decimals:  asset.Precision,
-		Chain:     common.SafeAssetIdChain(asset.ChainId),
+		Chain:     common.SafeAssetIdChainNoPanic(asset.ChainId),
 		CreatedAt: time.Now().UTC(),
 	}, nil
 }
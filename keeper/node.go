@@ -9,6 +9,7 @@ import (
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -37,7 +38,10 @@ func NewNode(store *store.SQLite3Store, group *mtg.Group, conf *Configuration, s
 	node.signerAESKey = common.ECDHEd25519(conf.SharedKey, conf.SignerPublicKey)
 	node.observerAESKey = common.ECDHEd25519(conf.SharedKey, conf.ObserverPublicKey)
 	node.mixin = mixin
-	abi.InitFactoryContractAddress(conf.PolygonFactoryAddress)
+	for _, c := range conf.EVMChains {
+		common.RegisterEVMChain(c)
+	}
+	abi.InitFactoryContractAddress(ethereum.BondChain().FactoryAddress)
 	return node
 }
 
@@ -0,0 +1,201 @@
+package keeper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+// The holder registers the spending policy through the observer, the extra
+// is the reference to the observer storage of the holder signature and the
+// policy. The holder signs over req.Id, which comes from the observer MTG
+// transaction, so the signature is bound to this request.
+// The first policy of a safe, and any change not looser than the policy in
+// effect, take effect immediately. Other changes wait for SafePolicyCooldown,
+// so a stolen holder key can't raise the limits and drain the safe before the
+// owner notices, and the owner could cancel them by a tighter policy.
+func (node *Node) processSafeSetSpendingPolicy(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain || safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 32 {
+		return node.failRequest(ctx, req, "")
+	}
+	var ref crypto.Hash
+	copy(ref[:], extra)
+	raw := node.readStorageExtraFromObserver(ctx, ref)
+	if len(raw) < 2 || len(raw) < int(raw[0])+2 {
+		return node.failRequest(ctx, req, "")
+	}
+	sig, raw := raw[1:int(raw[0])+1], raw[int(raw[0])+1:]
+
+	ms := fmt.Sprintf("POLICY:%s:%x", req.Id, sha256.Sum256(raw))
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
+		hash := bitcoin.HashMessageForSignature(ms, safe.Chain)
+		err = bitcoin.VerifySignatureDER(safe.Holder, hash, sig)
+		logger.Printf("bitcoin.VerifySignatureDER(%v) => %v", req, err)
+	case common.SafeChainEthereum:
+		if len(sig) < 64 {
+			return node.failRequest(ctx, req, "")
+		}
+		err = ethereum.VerifyMessageSignature(safe.Holder, []byte(ms), sig)
+		logger.Printf("ethereum.VerifyMessageSignature(%v) => %v", req, err)
+	default:
+		return node.failRequest(ctx, req, "")
+	}
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var policy store.SpendingPolicy
+	err = json.Unmarshal(raw, &policy)
+	logger.Printf("store.SpendingPolicy(%s) => %v", string(raw), err)
+	if err != nil || !node.verifySpendingPolicy(safe, &policy) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	old, err := node.store.ReadSafePolicy(ctx, safe.Holder, req.CreatedAt)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafePolicy(%s) => %v", safe.Holder, err))
+	}
+	effective := req.CreatedAt
+	if old != nil && !policy.Tightens(old.Policy) {
+		effective = effective.Add(SafePolicyCooldown)
+	}
+	sp := &store.SafePolicy{
+		RequestId:   req.Id,
+		Holder:      safe.Holder,
+		Policy:      &policy,
+		EffectiveAt: effective,
+		CreatedAt:   req.CreatedAt,
+	}
+	err = node.store.WriteSafePolicyWithRequest(ctx, sp, req)
+	if err != nil {
+		panic(fmt.Errorf("store.WriteSafePolicyWithRequest(%v) => %v", sp, err))
+	}
+	return nil, ""
+}
+
+// the whitelist addresses of ethereum safes are normalized in place
+func (node *Node) verifySpendingPolicy(safe *store.Safe, policy *store.SpendingPolicy) bool {
+	if policy.Delay < 0 || len(policy.Limits) > 64 || len(policy.Whitelist) > 256 {
+		return false
+	}
+	assets := make(map[string]bool)
+	for _, l := range policy.Limits {
+		if l == nil || assets[l.AssetId] {
+			return false
+		}
+		assets[l.AssetId] = true
+		if l.Daily.IsNegative() || l.Weekly.IsNegative() || l.Delayed.IsNegative() {
+			return false
+		}
+	}
+	for i, addr := range policy.Whitelist {
+		switch common.SafeChainFamily(safe.Chain) {
+		case common.SafeChainBitcoin:
+			_, err := bitcoin.ParseAddress(addr, safe.Chain)
+			if err != nil {
+				return false
+			}
+		case common.SafeChainEthereum:
+			norm := ethereum.NormalizeAddress(addr)
+			if norm == ethereum.EthereumEmptyAddress {
+				return false
+			}
+			policy.Whitelist[i] = norm
+		}
+	}
+	return true
+}
+
+// checkSpendingPolicy returns false if the proposal sends to any address out
+// of the whitelist, or exceeds the daily or weekly outflow limit of the asset
+func (node *Node) checkSpendingPolicy(ctx context.Context, req *common.Request, safe *store.Safe, assetId string, recipients []map[string]string) bool {
+	sp, err := node.store.ReadSafePolicy(ctx, safe.Holder, req.CreatedAt)
+	logger.Printf("store.ReadSafePolicy(%s, %s) => %v %v", safe.Holder, req.CreatedAt, sp, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafePolicy(%s) => %v", safe.Holder, err))
+	}
+	if sp == nil {
+		return true
+	}
+	for _, r := range recipients {
+		receiver := r["receiver"]
+		if common.SafeChainFamily(safe.Chain) == common.SafeChainEthereum {
+			receiver = ethereum.NormalizeAddress(receiver)
+		}
+		if !sp.Policy.Allows(receiver) {
+			return false
+		}
+	}
+
+	limit := sp.Policy.Limit(assetId)
+	if limit == nil {
+		return true
+	}
+	for _, l := range []struct {
+		cap    decimal.Decimal
+		window time.Duration
+	}{
+		{limit.Daily, 24 * time.Hour},
+		{limit.Weekly, 7 * 24 * time.Hour},
+	} {
+		if !l.cap.IsPositive() {
+			continue
+		}
+		since := req.CreatedAt.Add(-l.window)
+		spent, err := node.store.SumTransactionOutflow(ctx, safe.Holder, assetId, since)
+		logger.Printf("store.SumTransactionOutflow(%s, %s, %s) => %s %v", safe.Holder, assetId, since, spent, err)
+		if err != nil {
+			panic(fmt.Errorf("store.SumTransactionOutflow(%s, %s) => %v", safe.Holder, assetId, err))
+		}
+		if spent.Add(req.Amount).Cmp(l.cap) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSpendingPolicyDelayed returns true if the transaction is a large
+// withdrawal still in its delay, the observer will retry the approval later
+func (node *Node) checkSpendingPolicyDelayed(ctx context.Context, req *common.Request, tx *store.Transaction) bool {
+	sp, err := node.store.ReadSafePolicy(ctx, tx.Holder, tx.CreatedAt)
+	logger.Printf("store.ReadSafePolicy(%s, %s) => %v %v", tx.Holder, tx.CreatedAt, sp, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafePolicy(%s) => %v", tx.Holder, err))
+	}
+	if sp == nil {
+		return false
+	}
+	limit := sp.Policy.Limit(tx.AssetId)
+	if limit == nil || !limit.Delayed.IsPositive() {
+		return false
+	}
+	if tx.Outflow().Cmp(limit.Delayed) < 0 {
+		return false
+	}
+	return req.CreatedAt.Before(tx.CreatedAt.Add(sp.Policy.DelayDuration()))
+}
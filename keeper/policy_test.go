@@ -0,0 +1,113 @@
+package keeper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinKeeperSpendingPolicy(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	bondId := testDeployBondContract(ctx, require, node, testSafeAddress, common.SafeBitcoinChainId)
+	require.Equal(testBondAssetId, bondId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{
+		UnifiedOutput: *output,
+	})
+	input := &bitcoin.Input{
+		TransactionHash: "40e228e5a3cba99fd3fc5350a00bfeef8bafb760e26919ec74bca67776c90427",
+		Index:           0, Satoshi: 86560,
+	}
+	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 1)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	policy := fmt.Sprintf(`{"limits":[{"asset_id":"%s","daily":"0.0001"}]}`, common.SafeBitcoinChainId)
+	first := testSafeSetSpendingPolicy(ctx, require, node, holder, policy)
+	sp, err := node.store.ReadSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Equal(first, sp.RequestId)
+	require.Equal("0.0001", sp.Policy.Limit(common.SafeBitcoinChainId).Daily.String())
+
+	rid := uuid.Must(uuid.NewV4()).String()
+	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	extra := []byte{0}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, []byte(testTransactionReceiver)...)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionBitcoinSafeProposeTransaction, bondId, extra, decimal.NewFromFloat(0.000123))
+	testStep(ctx, require, node, out)
+	req, err := node.store.ReadRequest(ctx, rid)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, int(req.State))
+	count, err := node.store.CountUnfinishedTransactionsByHolder(ctx, holder)
+	require.Nil(err)
+	require.Equal(0, count)
+
+	policy = fmt.Sprintf(`{"limits":[{"asset_id":"%s","daily":"1"}]}`, common.SafeBitcoinChainId)
+	second := testSafeSetSpendingPolicy(ctx, require, node, holder, policy)
+	sp, err = node.store.ReadSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Equal(first, sp.RequestId)
+	sp, err = node.store.ReadLatestSafePolicy(ctx, holder)
+	require.Nil(err)
+	require.Equal(second, sp.RequestId)
+	require.True(sp.EffectiveAt.After(time.Now().Add(SafePolicyCooldown - time.Hour)))
+	sp, err = node.store.ReadSafePolicy(ctx, holder, time.Now().Add(SafePolicyCooldown+time.Hour))
+	require.Nil(err)
+	require.Equal(second, sp.RequestId)
+
+	policy = fmt.Sprintf(`{"limits":[{"asset_id":"%s","daily":"0.00005"}],"whitelist":["%s"]}`, common.SafeBitcoinChainId, testTransactionReceiver)
+	third := testSafeSetSpendingPolicy(ctx, require, node, holder, policy)
+	sp, err = node.store.ReadSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Equal(third, sp.RequestId)
+	sp, err = node.store.ReadSafePolicy(ctx, holder, time.Now().Add(SafePolicyCooldown+time.Hour))
+	require.Nil(err)
+	require.Equal(third, sp.RequestId)
+
+	policy = fmt.Sprintf(`{"limits":[{"asset_id":"%s","daily":"0.00005","weekly":"0.001"}]}`, common.SafeBitcoinChainId)
+	fourth := testSafeSetSpendingPolicy(ctx, require, node, holder, policy)
+	sp, err = node.store.ReadSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Equal(third, sp.RequestId)
+	sp, err = node.store.ReadSafePolicy(ctx, holder, time.Now().Add(SafePolicyCooldown+time.Hour))
+	require.Nil(err)
+	require.Equal(fourth, sp.RequestId)
+}
+
+func testSafeSetSpendingPolicy(ctx context.Context, require *require.Assertions, node *Node, holder, policy string) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	hb := common.DecodeHexOrPanic(testBitcoinKeyHolderPrivate)
+	hp, _ := btcec.PrivKeyFromBytes(hb)
+	ms := fmt.Sprintf("POLICY:%s:%x", id, sha256.Sum256([]byte(policy)))
+	hash := bitcoin.HashMessageForSignature(ms, common.SafeChainBitcoin)
+	sig := ecdsa.Sign(hp, hash).Serialize()
+
+	raw := append([]byte{byte(len(sig))}, sig...)
+	raw = append(raw, policy...)
+	ref := crypto.Sha256Hash(raw)
+	err := node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+	out := testBuildObserverRequest(node, id, holder, common.ActionObserverSetSpendingPolicy, ref[:], common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	req, err := node.store.ReadRequest(ctx, id)
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, int(req.State))
+	return id
+}
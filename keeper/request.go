@@ -1,7 +1,9 @@
 package keeper
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -104,6 +106,36 @@ func (node *Node) readStorageExtraFromObserver(ctx context.Context, ref crypto.H
 	return raw[16:]
 }
 
+// readStorageExtraFromSigner reads the storage transaction referenced by the
+// signer response, the extra is only returned if it matches the digest
+func (node *Node) readStorageExtraFromSigner(ctx context.Context, req *common.Request, digest []byte) []byte {
+	var extra []byte
+	if common.CheckTestEnvironment(ctx) {
+		var ref crypto.Hash
+		copy(ref[:], digest)
+		extra = node.readStorageExtraFromObserver(ctx, ref)
+	} else {
+		ver, err := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+		if err != nil {
+			panic(req.MixinHash.String())
+		}
+		if len(ver.References) != 1 {
+			return nil
+		}
+		stx, err := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		if err != nil {
+			panic(ver.References[0].String())
+		}
+		extra = stx.Extra
+	}
+
+	sum := sha256.Sum256(extra)
+	if !bytes.Equal(sum[:], digest) {
+		return nil
+	}
+	return extra
+}
+
 func (node *Node) buildStorageTransaction(ctx context.Context, req *common.Request, extra []byte) *mtg.Transaction {
 	logger.Printf("node.writeStorageTransaction(%x)", extra)
 	if common.CheckTestEnvironment(ctx) {
@@ -2,10 +2,12 @@ package keeper
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
 
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -25,7 +27,9 @@ func (node *Node) processSignerKeygenRequests(ctx context.Context, req *common.R
 	crv := common.NormalizeCurve(req.Curve)
 	switch crv {
 	case common.CurveSecp256k1ECDSABitcoin:
+	case common.CurveSecp256k1SchnorrBitcoin:
 	case common.CurveSecp256k1ECDSAEthereum:
+	case common.CurveEdwards25519Mixin:
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -57,13 +61,126 @@ func (node *Node) processSignerKeygenRequests(ctx context.Context, req *common.R
 	return txs, ""
 }
 
+// processSignerRefreshRequest asks the signers to refresh their shares of the
+// signer key, and the public key remains the same after the refresh.
+func (node *Node) processSignerRefreshRequest(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	if req.Action != common.ActionObserverRequestSignerRefresh {
+		panic(req.Action)
+	}
+	key, err := node.store.ReadKey(ctx, req.Holder)
+	logger.Printf("store.ReadKey(%s) => %v %v", req.Holder, key, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadKey(%s) => %v", req.Holder, err))
+	}
+	if key == nil || key.Role != common.RequestRoleSigner {
+		return node.failRequest(ctx, req, "")
+	}
+	crv := common.NormalizeCurve(req.Curve)
+	if key.Curve != crv {
+		return node.failRequest(ctx, req, "")
+	}
+
+	signers := node.GetSigners()
+	op := &common.Operation{
+		Type:   common.OperationTypeRefreshInput,
+		Curve:  crv,
+		Public: key.Public,
+	}
+	op.Id = common.UniqueId(req.Id, "REFRESH")
+	op.Id = common.UniqueId(op.Id, fmt.Sprintf("MTG:%v:%d", signers, node.signer.Genesis.Threshold))
+	tx := node.buildSignerTransaction(ctx, req.Output, op)
+	if tx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	txs := []*mtg.Transaction{tx}
+	err = node.store.FailRequest(ctx, req, "", txs)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// processSignerReshareRequest asks the signers to transfer the signer key to
+// the current signer members with the threshold in the request extra, and the
+// public key remains the same after the reshare.
+func (node *Node) processSignerReshareRequest(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	if req.Action != common.ActionObserverRequestSignerReshare {
+		panic(req.Action)
+	}
+	extra := req.ExtraBytes()
+	if len(extra) != 1 {
+		return node.failRequest(ctx, req, "")
+	}
+	signers := node.GetSigners()
+	threshold := int(extra[0])
+	if threshold < 1 || threshold >= len(signers) || threshold > node.signer.Genesis.Threshold {
+		return node.failRequest(ctx, req, "")
+	}
+	key, err := node.store.ReadKey(ctx, req.Holder)
+	logger.Printf("store.ReadKey(%s) => %v %v", req.Holder, key, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadKey(%s) => %v", req.Holder, err))
+	}
+	if key == nil || key.Role != common.RequestRoleSigner {
+		return node.failRequest(ctx, req, "")
+	}
+	crv := common.NormalizeCurve(req.Curve)
+	if key.Curve != crv {
+		return node.failRequest(ctx, req, "")
+	}
+
+	op := &common.Operation{
+		Type:   common.OperationTypeReshareInput,
+		Curve:  crv,
+		Public: key.Public,
+		Extra:  common.EncodeSignerCommittee(signers, threshold),
+	}
+	op.Id = common.UniqueId(req.Id, "RESHARE")
+	op.Id = common.UniqueId(op.Id, fmt.Sprintf("MTG:%v:%d", signers, node.signer.Genesis.Threshold))
+	tx := node.buildSignerTransaction(ctx, req.Output, op)
+	if tx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	txs := []*mtg.Transaction{tx}
+	err = node.store.FailRequest(ctx, req, "", txs)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processSignerRefreshResponse(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+	key, err := node.store.ReadKey(ctx, req.Holder)
+	logger.Printf("store.ReadKey(%s) => %v %v", req.Holder, key, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadKey(%s) => %v", req.Holder, err))
+	}
+	if key == nil || key.Role != common.RequestRoleSigner {
+		panic(req.Holder)
+	}
+	return node.failRequest(ctx, req, "")
+}
+
 func (node *Node) buildSignerSignRequests(ctx context.Context, request *common.Request, srs []*store.SignatureRequest, path string) []*mtg.Transaction {
 	var txs []*mtg.Transaction
 	for _, sr := range srs {
 		crv := common.NormalizeCurve(sr.Curve)
 		switch crv {
 		case common.CurveSecp256k1ECDSABitcoin:
+		case common.CurveSecp256k1SchnorrBitcoin:
 		case common.CurveSecp256k1ECDSAEthereum:
+		case common.CurveEdwards25519Mixin:
 		default:
 			panic(sr.Curve)
 		}
@@ -89,6 +206,60 @@ func (node *Node) buildSignerSignRequests(ctx context.Context, request *common.R
 	return txs
 }
 
+// buildSignerBatchSignRequests signs the signature requests of the same signer
+// key and path in batches, the messages of each batch are sent in a storage
+// transaction, and the signer operation only has the digest of them
+func (node *Node) buildSignerBatchSignRequests(ctx context.Context, request *common.Request, srs []*store.SignatureRequest, path string) ([]*store.SignatureBatch, []*mtg.Transaction) {
+	fp := common.DecodeHexOrPanic(path)
+	if len(fp) != 4 {
+		panic(path)
+	}
+
+	var txs []*mtg.Transaction
+	var batches []*store.SignatureBatch
+	for i := 0; i < len(srs); i += common.BatchSignLimit {
+		part := srs[i:min(i+common.BatchSignLimit, len(srs))]
+		crv := common.NormalizeCurve(part[0].Curve)
+		batch := &store.SignatureBatch{
+			BatchId:         common.UniqueId(request.Id, fmt.Sprintf("BATCH:%d", i)),
+			TransactionHash: part[0].TransactionHash,
+			State:           common.RequestStateInitial,
+			CreatedAt:       request.CreatedAt,
+			UpdatedAt:       request.CreatedAt,
+		}
+		var msgs [][]byte
+		for _, sr := range part {
+			if sr.Signer != part[0].Signer || sr.Curve != part[0].Curve {
+				panic(sr.RequestId)
+			}
+			batch.RequestIds = append(batch.RequestIds, sr.RequestId)
+			msgs = append(msgs, common.DecodeHexOrPanic(sr.Message))
+		}
+
+		extra := common.EncodeBatchItems(msgs)
+		stx := node.buildStorageTransaction(ctx, request, extra)
+		if stx == nil {
+			return nil, nil
+		}
+		sum := sha256.Sum256(extra)
+		fingerPath := append(common.Fingerprint(part[0].Signer), fp...)
+		op := &common.Operation{
+			Id:     batch.BatchId,
+			Type:   common.OperationTypeBatchSignInput,
+			Curve:  crv,
+			Public: hex.EncodeToString(fingerPath),
+			Extra:  sum[:],
+		}
+		tx := node.buildSignerTransactionWithStorageTraceId(ctx, request.Output, op, stx.TraceId)
+		if tx == nil {
+			return nil, nil
+		}
+		txs = append(txs, stx, tx)
+		batches = append(batches, batch)
+	}
+	return batches, txs
+}
+
 func (node *Node) encryptSignerOperation(op *common.Operation) []byte {
 	extra := op.Encode()
 	return common.AESEncrypt(node.signerAESKey[:], extra, op.Id)
@@ -103,3 +274,13 @@ func (node *Node) buildSignerTransaction(ctx context.Context, act *mtg.Action, o
 	threshold := node.signer.Genesis.Threshold
 	return node.buildTransaction(ctx, act, node.conf.SignerAppId, node.conf.AssetId, members, threshold, "1", extra, op.Id)
 }
+
+func (node *Node) buildSignerTransactionWithStorageTraceId(ctx context.Context, act *mtg.Action, op *common.Operation, storageTraceId string) *mtg.Transaction {
+	extra := node.encryptSignerOperation(op)
+	if len(extra) > 160 {
+		panic(fmt.Errorf("node.buildSignerTransactionWithStorageTraceId(%v) omitted %x", op, extra))
+	}
+	members := node.GetSigners()
+	threshold := node.signer.Genesis.Threshold
+	return node.buildTransactionWithStorageTraceId(ctx, act, node.conf.SignerAppId, node.conf.AssetId, members, threshold, "1", extra, op.Id, storageTraceId)
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+var transactionBatchCols = []string{"request_id", "batch_index", "transaction_hash", "created_at"}
+
+// BatchTransactionRequestId is the request id of the index-th transaction in
+// a batch, because every transaction row needs its own request id for the
+// approval and signature flows.
+func BatchTransactionRequestId(requestId string, index int) string {
+	return common.UniqueId(requestId, fmt.Sprintf("BATCH:%d", index))
+}
+
+func (s *SQLite3Store) WriteTransactionBatchWithRequest(ctx context.Context, trxs []*Transaction, utxos [][]*TransactionInput, txs []*mtg.Transaction, req *common.Request) error {
+	if len(trxs) == 0 || len(trxs) != len(utxos) {
+		panic(len(trxs))
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, trx := range trxs {
+		if trx.RequestId != BatchTransactionRequestId(req.Id, i) {
+			panic(trx.RequestId)
+		}
+		err = s.writeTransaction(ctx, tx, trx, utxos[i], common.RequestStatePending)
+		if err != nil {
+			return err
+		}
+		vals := []any{req.Id, i, trx.TransactionHash, trx.CreatedAt}
+		err = s.execOne(ctx, tx, buildInsertionSQL("transaction_batches", transactionBatchCols), vals...)
+		if err != nil {
+			return fmt.Errorf("INSERT transaction_batches %v", err)
+		}
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListTransactionsByBatch(ctx context.Context, requestId string) ([]*Transaction, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT transaction_hash FROM transaction_batches WHERE request_id=? ORDER BY batch_index ASC"
+	rows, err := tx.QueryContext(ctx, query, requestId)
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+
+	var trxs []*Transaction
+	for _, hash := range hashes {
+		trx, err := s.readTransaction(ctx, tx, hash)
+		if err != nil {
+			return nil, err
+		}
+		trxs = append(trxs, trx)
+	}
+	return trxs, nil
+}
+
+func (s *SQLite3Store) ReadTransactionBatchId(ctx context.Context, hash string) (string, error) {
+	var requestId string
+	query := "SELECT request_id FROM transaction_batches WHERE transaction_hash=?"
+	row := s.db.QueryRowContext(ctx, query, hash)
+	err := row.Scan(&requestId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return requestId, err
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+type MixinOutput struct {
+	mixin.Input
+	AssetId string
+}
+
+func (s *SQLite3Store) WriteMixinOutputFromRequest(ctx context.Context, safe *Safe, utxo *mixin.Input, req *common.Request, assetId, sender string, txs []*mtg.Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	mask := hex.EncodeToString(utxo.Mask)
+	cols := []string{"transaction_hash", "output_index", "address", "asset_id", "amount", "mask", "chain", "state", "spent_by", "request_id", "created_at", "updated_at"}
+	vals := []any{utxo.TransactionHash, utxo.Index, safe.Address, assetId, utxo.Amount.String(), mask, safe.Chain, common.RequestStateInitial, nil, req.Id, req.CreatedAt, req.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("mixin_outputs", cols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT mixin_outputs %v", err)
+	}
+
+	vals = []any{utxo.TransactionHash, utxo.Index, assetId, utxo.Amount.String(), safe.Address, sender, common.RequestStateDone, safe.Chain, safe.Holder, common.ActionObserverHolderDeposit, req.CreatedAt, req.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("deposits", depositsCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT deposits %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?", common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadMixinUTXO(ctx context.Context, transactionHash string, index int) (*MixinOutput, string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	return s.readMixinUTXO(ctx, tx, transactionHash, index)
+}
+
+func (s *SQLite3Store) ListAllMixinUTXOsForHolderAndAsset(ctx context.Context, holder, assetId string) ([]*mixin.Input, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	safe, err := s.readSafe(ctx, tx, holder)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.listAllMixinUTXOsForAddressAndAsset(ctx, tx, safe.Address, assetId, common.RequestStateInitial)
+}
+
+func (s *SQLite3Store) ListAllMixinUTXOsForAddress(ctx context.Context, address string) ([]*MixinOutput, error) {
+	cols := strings.Join([]string{"transaction_hash", "output_index", "asset_id", "amount", "mask"}, ",")
+	query := fmt.Sprintf("SELECT %s FROM mixin_outputs WHERE address=? AND state=? ORDER BY created_at ASC, request_id ASC", cols)
+	rows, err := s.db.QueryContext(ctx, query, address, common.RequestStateInitial)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outputs []*MixinOutput
+	for rows.Next() {
+		var amount, mask string
+		var output MixinOutput
+		err = rows.Scan(&output.TransactionHash, &output.Index, &output.AssetId, &amount, &mask)
+		if err != nil {
+			return nil, err
+		}
+		output.Amount = decimal.RequireFromString(amount)
+		output.Mask = common.DecodeHexOrPanic(mask)
+		outputs = append(outputs, &output)
+	}
+	return outputs, nil
+}
+
+func (s *SQLite3Store) ReadUnspentMixinUtxoCountForSafe(ctx context.Context, address, assetId string) (int, error) {
+	query := "SELECT COUNT(*) FROM mixin_outputs WHERE address=? AND asset_id=? AND state IN (?, ?)"
+	row := s.db.QueryRowContext(ctx, query, address, assetId, common.RequestStateInitial, common.RequestStatePending)
+	var count int
+	err := row.Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (s *SQLite3Store) listAllMixinUTXOsForAddressAndAsset(ctx context.Context, tx *sql.Tx, receiver, assetId string, state int) ([]*mixin.Input, error) {
+	cols := strings.Join([]string{"transaction_hash", "output_index", "amount", "mask"}, ",")
+	query := fmt.Sprintf("SELECT %s FROM mixin_outputs WHERE address=? AND asset_id=? AND state=? ORDER BY created_at ASC, request_id ASC", cols)
+	rows, err := tx.QueryContext(ctx, query, receiver, assetId, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inputs []*mixin.Input
+	for rows.Next() {
+		var amount, mask string
+		var input mixin.Input
+		err = rows.Scan(&input.TransactionHash, &input.Index, &amount, &mask)
+		if err != nil {
+			return nil, err
+		}
+		input.Amount = decimal.RequireFromString(amount)
+		input.Mask = common.DecodeHexOrPanic(mask)
+		inputs = append(inputs, &input)
+	}
+	return inputs, nil
+}
+
+func (s *SQLite3Store) readMixinUTXO(ctx context.Context, tx *sql.Tx, transactionHash string, index int) (*MixinOutput, string, error) {
+	output := &MixinOutput{
+		Input: mixin.Input{
+			TransactionHash: transactionHash,
+			Index:           uint32(index),
+		},
+	}
+
+	query := "SELECT asset_id,amount,mask,spent_by FROM mixin_outputs WHERE transaction_hash=? AND output_index=?"
+	row := tx.QueryRowContext(ctx, query, transactionHash, index)
+
+	var amount, mask string
+	var spent sql.NullString
+	err := row.Scan(&output.AssetId, &amount, &mask, &spent)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	output.Amount = decimal.RequireFromString(amount)
+	output.Mask = common.DecodeHexOrPanic(mask)
+	return output, spent.String, nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/shopspring/decimal"
+)
+
+// zero amounts of a limit mean no restriction
+type SpendingLimit struct {
+	AssetId string          `json:"asset_id"`
+	Daily   decimal.Decimal `json:"daily"`
+	Weekly  decimal.Decimal `json:"weekly"`
+	Delayed decimal.Decimal `json:"delayed"`
+}
+
+type SpendingPolicy struct {
+	Limits    []*SpendingLimit `json:"limits"`
+	Whitelist []string         `json:"whitelist"`
+	Delay     int64            `json:"delay"`
+}
+
+type SafePolicy struct {
+	RequestId   string
+	Holder      string
+	Policy      *SpendingPolicy
+	EffectiveAt time.Time
+	CreatedAt   time.Time
+}
+
+var policyCols = []string{"request_id", "holder", "policy", "effective_at", "created_at"}
+
+func (p *SpendingPolicy) Limit(assetId string) *SpendingLimit {
+	for _, l := range p.Limits {
+		if l.AssetId == assetId {
+			return l
+		}
+	}
+	return nil
+}
+
+func (p *SpendingPolicy) Allows(receiver string) bool {
+	return len(p.Whitelist) == 0 || slices.Contains(p.Whitelist, receiver)
+}
+
+// Tightens returns true if the policy is not looser than the old one in any
+// way, i.e. the whitelist is a subset, the delay is not shorter, and all
+// positive limits of the old policy are kept with amounts not larger.
+func (p *SpendingPolicy) Tightens(old *SpendingPolicy) bool {
+	if len(old.Whitelist) > 0 {
+		if len(p.Whitelist) == 0 {
+			return false
+		}
+		for _, addr := range p.Whitelist {
+			if !slices.Contains(old.Whitelist, addr) {
+				return false
+			}
+		}
+	}
+	if p.Delay < old.Delay {
+		return false
+	}
+	for _, ol := range old.Limits {
+		l := p.Limit(ol.AssetId)
+		if l == nil {
+			l = &SpendingLimit{}
+		}
+		if !tightensLimit(l.Daily, ol.Daily) || !tightensLimit(l.Weekly, ol.Weekly) || !tightensLimit(l.Delayed, ol.Delayed) {
+			return false
+		}
+	}
+	return true
+}
+
+// zero means no restriction, so the new amount must be positive if the old
+// one is, and not larger than it
+func tightensLimit(amount, old decimal.Decimal) bool {
+	if !old.IsPositive() {
+		return true
+	}
+	return amount.IsPositive() && amount.Cmp(old) <= 0
+}
+
+func (p *SpendingPolicy) DelayDuration() time.Duration {
+	return time.Duration(p.Delay) * time.Second
+}
+
+func (t *Transaction) Outflow() decimal.Decimal {
+	var recipients []map[string]string
+	err := json.Unmarshal([]byte(t.Data), &recipients)
+	if err != nil {
+		panic(fmt.Errorf("store.Transaction(%s) => %s", t.TransactionHash, t.Data))
+	}
+	total := decimal.Zero
+	for _, r := range recipients {
+		total = total.Add(decimal.RequireFromString(r["amount"]))
+	}
+	return total
+}
+
+func (s *SQLite3Store) WriteSafePolicyWithRequest(ctx context.Context, policy *SafePolicy, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT request_id FROM requests WHERE request_id=? AND state=?", policy.RequestId, common.RequestStateDone)
+	if err != nil || existed {
+		return err
+	}
+
+	data := common.MarshalJSONOrPanic(policy.Policy)
+	vals := []any{policy.RequestId, policy.Holder, string(data), policy.EffectiveAt, policy.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("safe_policies", policyCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_policies %v", err)
+	}
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), policy.RequestId)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", nil, policy.RequestId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReadSafePolicy returns the policy in effect for the holder at offset, it is
+// the latest registered one of all effective, so a tighter policy registered
+// later cancels the looser ones still waiting for their effective time
+func (s *SQLite3Store) ReadSafePolicy(ctx context.Context, holder string, offset time.Time) (*SafePolicy, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_policies WHERE holder=? AND effective_at<=? ORDER BY created_at DESC, request_id DESC LIMIT 1", strings.Join(policyCols, ","))
+	row := s.db.QueryRowContext(ctx, query, holder, offset)
+	return policyFromRow(row)
+}
+
+// ReadLatestSafePolicy returns the latest registered policy of the holder,
+// which may still be waiting for its effective time
+func (s *SQLite3Store) ReadLatestSafePolicy(ctx context.Context, holder string) (*SafePolicy, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_policies WHERE holder=? ORDER BY created_at DESC, request_id DESC LIMIT 1", strings.Join(policyCols, ","))
+	row := s.db.QueryRowContext(ctx, query, holder)
+	return policyFromRow(row)
+}
+
+// SumTransactionOutflow sums the amounts of all transactions of the holder
+// for the asset proposed since the offset, except the failed ones
+func (s *SQLite3Store) SumTransactionOutflow(ctx context.Context, holder, assetId string, offset time.Time) (decimal.Decimal, error) {
+	query := fmt.Sprintf("SELECT %s FROM transactions WHERE holder=? AND asset_id=? AND state IN (?, ?, ?) AND created_at>=?", strings.Join(transactionCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, holder, assetId, common.RequestStateInitial, common.RequestStatePending, common.RequestStateDone, offset)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	defer rows.Close()
+
+	total := decimal.Zero
+	for rows.Next() {
+		var t Transaction
+		err := rows.Scan(&t.TransactionHash, &t.RawTransaction, &t.Holder, &t.Chain, &t.AssetId, &t.State, &t.Data, &t.RequestId, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(t.Outflow())
+	}
+	return total, rows.Err()
+}
+
+func policyFromRow(row *sql.Row) (*SafePolicy, error) {
+	var p SafePolicy
+	var data string
+	err := row.Scan(&p.RequestId, &p.Holder, &data, &p.EffectiveAt, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(data), &p.Policy)
+	return &p, err
+}
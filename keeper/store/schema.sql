@@ -66,6 +66,20 @@ CREATE INDEX IF NOT EXISTS operation_params_by_chain_created ON operation_params
 
 
 
+CREATE TABLE IF NOT EXISTS safe_policies (
+  request_id     VARCHAR NOT NULL,
+  holder         VARCHAR NOT NULL,
+  policy         VARCHAR NOT NULL,
+  effective_at   TIMESTAMP NOT NULL,
+  created_at     TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+CREATE INDEX IF NOT EXISTS safe_policies_by_holder_effective ON safe_policies(holder, effective_at);
+
+
+
+
 CREATE TABLE IF NOT EXISTS assets (
   asset_id      VARCHAR NOT NULL,
   mixin_id      VARCHAR NOT NULL,
@@ -184,6 +198,30 @@ CREATE INDEX IF NOT EXISTS bitcoin_outputs_by_address_state_created ON bitcoin_o
 
 
 
+CREATE TABLE IF NOT EXISTS mixin_outputs (
+  transaction_hash   VARCHAR NOT NULL,
+  output_index       INTEGER NOT NULL,
+  address            VARCHAR NOT NULL,
+  asset_id           VARCHAR NOT NULL,
+  amount             VARCHAR NOT NULL,
+  mask               VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  state              INTEGER NOT NULL,
+  spent_by           VARCHAR,
+  request_id         VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('transaction_hash', 'output_index')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS mixin_outputs_by_request_id ON mixin_outputs(request_id);
+CREATE INDEX IF NOT EXISTS mixin_outputs_by_address_asset_state_created ON mixin_outputs(address, asset_id, state, created_at);
+
+
+
+
+
+
 CREATE TABLE IF NOT EXISTS ethereum_balances (
   address            VARCHAR NOT NULL,
   asset_id           VARCHAR NOT NULL,
@@ -238,6 +276,21 @@ CREATE TABLE IF NOT EXISTS transactions (
 );
 
 CREATE UNIQUE INDEX IF NOT EXISTS transactions_by_request_id ON transactions(request_id);
+CREATE INDEX IF NOT EXISTS transactions_by_holder_created_hash ON transactions(holder, created_at, transaction_hash);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS transaction_batches (
+  request_id         VARCHAR NOT NULL,
+  batch_index        INTEGER NOT NULL,
+  transaction_hash   VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id', 'batch_index')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_batches_by_transaction ON transaction_batches(transaction_hash);
 
 
 
@@ -260,6 +313,19 @@ CREATE TABLE IF NOT EXISTS signature_requests (
 CREATE INDEX IF NOT EXISTS signature_requests_by_transaction_state_created ON signature_requests(transaction_hash, state, created_at);
 
 
+CREATE TABLE IF NOT EXISTS signature_batches (
+  batch_id            VARCHAR NOT NULL,
+  transaction_hash    VARCHAR NOT NULL,
+  request_ids         VARCHAR NOT NULL,
+  state               INTEGER NOT NULL,
+  created_at          TIMESTAMP NOT NULL,
+  updated_at          TIMESTAMP NOT NULL,
+  PRIMARY KEY ('batch_id')
+);
+
+CREATE INDEX IF NOT EXISTS signature_batches_by_transaction ON signature_batches(transaction_hash);
+
+
 
 
 
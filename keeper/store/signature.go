@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"time"
@@ -26,6 +27,19 @@ type SignatureRequest struct {
 
 var signatureCols = []string{"request_id", "transaction_hash", "input_index", "signer", "curve", "message", "signature", "state", "created_at", "updated_at"}
 
+// SignatureBatch signs the messages of the signature requests in one signer
+// session, and the request ids are in the same order as the messages
+type SignatureBatch struct {
+	BatchId         string
+	TransactionHash string
+	RequestIds      []string
+	State           int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+var signatureBatchCols = []string{"batch_id", "transaction_hash", "request_ids", "state", "created_at", "updated_at"}
+
 func (s *SQLite3Store) CloseAccountBySignatureRequestsWithRequest(ctx context.Context, requests []*SignatureRequest, transactionHash, raw string, req *common.Request, txs []*mtg.Transaction) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -92,6 +106,42 @@ func (s *SQLite3Store) WriteSignatureRequestsWithRequest(ctx context.Context, re
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) WriteSignatureBatchesWithRequest(ctx context.Context, batches []*SignatureBatch, requests []*SignatureRequest, transactionHash string, req *common.Request, txs []*mtg.Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.writeSignatureRequestsWithRequest(ctx, tx, requests, transactionHash, req)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range batches {
+		existed, err := s.checkExistence(ctx, tx, "SELECT batch_id FROM signature_batches WHERE batch_id=?", b.BatchId)
+		if err != nil {
+			return err
+		} else if existed {
+			continue
+		}
+		vals := []any{b.BatchId, b.TransactionHash, strings.Join(b.RequestIds, ","), b.State, b.CreatedAt, b.UpdatedAt}
+		err = s.execOne(ctx, tx, buildInsertionSQL("signature_batches", signatureBatchCols), vals...)
+		if err != nil {
+			return fmt.Errorf("INSERT signature_batches %v", err)
+		}
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) writeSignatureRequestsWithRequest(ctx context.Context, tx *sql.Tx, requests []*SignatureRequest, transactionHash string, req *common.Request) error {
 	err := s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
 		common.RequestStateDone, time.Now().UTC(), req.Id)
@@ -148,6 +198,41 @@ func (s *SQLite3Store) FinishSignatureRequest(ctx context.Context, req *common.R
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) FinishSignatureBatch(ctx context.Context, batch *SignatureBatch, signatures [][]byte, req *common.Request) error {
+	if len(signatures) != len(batch.RequestIds) {
+		panic(batch.BatchId)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT batch_id FROM signature_batches WHERE batch_id=? AND state=?", batch.BatchId, common.RequestStateDone)
+	if err != nil || existed {
+		return err
+	}
+
+	for i, id := range batch.RequestIds {
+		err = s.execOne(ctx, tx, "UPDATE signature_requests SET signature=?, state=?, updated_at=? WHERE request_id=? AND state=?",
+			hex.EncodeToString(signatures[i]), common.RequestStatePending, req.CreatedAt, id, common.RequestStateInitial)
+		if err != nil {
+			return fmt.Errorf("UPDATE signature_requests %v", err)
+		}
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE signature_batches SET state=?, updated_at=? WHERE batch_id=? AND state=?",
+		common.RequestStateDone, req.CreatedAt, batch.BatchId, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE signature_batches %v", err)
+	}
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) FinishTransactionSignaturesWithRequest(ctx context.Context, transactionHash, psbt string, req *common.Request, num int64, safe *Safe, bm map[string]*SafeBalance, txs []*mtg.Transaction) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -171,10 +256,11 @@ func (s *SQLite3Store) FinishTransactionSignaturesWithRequest(ctx context.Contex
 	}
 
 	if transactionHasOutputs(safe.Chain) {
-		update := "UPDATE bitcoin_outputs SET state=?, updated_at=? WHERE spent_by=?"
+		table := transactionOutputsTable(safe.Chain)
+		update := fmt.Sprintf("UPDATE %s SET state=?, updated_at=? WHERE spent_by=?", table)
 		err = s.execMultiple(ctx, tx, num, update, common.RequestStateDone, req.CreatedAt, transactionHash)
 		if err != nil {
-			return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+			return fmt.Errorf("UPDATE %s %v", table, err)
 		}
 	}
 	if transactionHasBalance(safe.Chain) {
@@ -228,6 +314,19 @@ func (s *SQLite3Store) ReadSignatureRequest(ctx context.Context, id string) (*Si
 	return &r, err
 }
 
+func (s *SQLite3Store) ReadSignatureBatch(ctx context.Context, id string) (*SignatureBatch, error) {
+	var b SignatureBatch
+	var ids string
+	query := fmt.Sprintf("SELECT %s FROM signature_batches WHERE batch_id=?", strings.Join(signatureBatchCols, ","))
+	row := s.db.QueryRowContext(ctx, query, id)
+	err := row.Scan(&b.BatchId, &b.TransactionHash, &ids, &b.State, &b.CreatedAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	b.RequestIds = strings.Split(ids, ",")
+	return &b, err
+}
+
 func (s *SQLite3Store) ListAllSignaturesForTransaction(ctx context.Context, transactionHash string, state int) (map[int]*SignatureRequest, error) {
 	query := fmt.Sprintf("SELECT %s FROM signature_requests WHERE transaction_hash=? AND state=? ORDER BY created_at DESC, request_id DESC", strings.Join(signatureCols, ","))
 	rows, err := s.db.QueryContext(ctx, query, transactionHash, state)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/trusted-group/mtg"
 )
@@ -44,11 +45,22 @@ func TransactionInputsFromBitcoin(mainInputs []*bitcoin.Input) []*TransactionInp
 	return inputs
 }
 
+func TransactionInputsFromMixin(mainInputs []*mixin.Input) []*TransactionInput {
+	inputs := make([]*TransactionInput, len(mainInputs))
+	for i, in := range mainInputs {
+		inputs[i] = &TransactionInput{
+			Hash:  in.TransactionHash,
+			Index: in.Index,
+		}
+	}
+	return inputs
+}
+
 func TransactionInputsFromRawTransaction(trx *Transaction) []*TransactionInput {
 	b := common.DecodeHexOrPanic(trx.RawTransaction)
 	var inputs []*TransactionInput
 	switch trx.Chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash:
 		psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
 		for _, in := range psbt.UnsignedTx.TxIn {
 			pop := in.PreviousOutPoint
@@ -57,6 +69,17 @@ func TransactionInputsFromRawTransaction(trx *Transaction) []*TransactionInput {
 				Index: pop.Index,
 			})
 		}
+	case mixin.ChainMixinKernel:
+		psbt, err := mixin.UnmarshalPartiallySignedTransaction(b)
+		if err != nil {
+			panic(err)
+		}
+		for _, in := range psbt.Inputs {
+			inputs = append(inputs, &TransactionInput{
+				Hash:  in.Hash.String(),
+				Index: uint32(in.Index),
+			})
+		}
 	default:
 		panic(trx.Chain)
 	}
@@ -117,6 +140,29 @@ func (s *SQLite3Store) CountUnfinishedTransactionsByHolder(ctx context.Context,
 	return count, err
 }
 
+// ListTransactionsByHolder pages the transactions by the (created_at, transaction_hash)
+// cursor of the last transaction in the previous page, so the transactions
+// created at the same time are never skipped.
+func (s *SQLite3Store) ListTransactionsByHolder(ctx context.Context, holder string, offset time.Time, hash string, limit int) ([]*Transaction, error) {
+	query := fmt.Sprintf("SELECT %s FROM transactions WHERE holder=? AND (created_at>? OR (created_at=? AND transaction_hash>?)) ORDER BY created_at ASC, transaction_hash ASC LIMIT %d", strings.Join(transactionCols, ","), limit)
+	rows, err := s.db.QueryContext(ctx, query, holder, offset, offset, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []*Transaction
+	for rows.Next() {
+		var tx Transaction
+		err = rows.Scan(&tx.TransactionHash, &tx.RawTransaction, &tx.Holder, &tx.Chain, &tx.AssetId, &tx.State, &tx.Data, &tx.RequestId, &tx.CreatedAt, &tx.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, &tx)
+	}
+	return txs, nil
+}
+
 func (s *SQLite3Store) ReadUnfinishedTransactionsByHolder(ctx context.Context, holder string) ([]*Transaction, error) {
 	query := fmt.Sprintf("SELECT %s FROM transactions WHERE holder=? AND state IN (?, ?)", strings.Join(transactionCols, ","))
 	rows, err := s.db.QueryContext(ctx, query, holder, common.RequestStateInitial, common.RequestStatePending)
@@ -201,10 +247,9 @@ func (s *SQLite3Store) WriteTransactionWithRequest(ctx context.Context, trx *Tra
 }
 
 func (s *SQLite3Store) writeTransactionWithRequest(ctx context.Context, tx *sql.Tx, trx *Transaction, utxos []*TransactionInput, utxoState int) error {
-	vals := []any{trx.TransactionHash, trx.RawTransaction, trx.Holder, trx.Chain, trx.AssetId, trx.State, trx.Data, trx.RequestId, trx.CreatedAt, trx.UpdatedAt}
-	err := s.execOne(ctx, tx, buildInsertionSQL("transactions", transactionCols), vals...)
+	err := s.writeTransaction(ctx, tx, trx, utxos, utxoState)
 	if err != nil {
-		return fmt.Errorf("INSERT transactions %v", err)
+		return err
 	}
 
 	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
@@ -212,14 +257,24 @@ func (s *SQLite3Store) writeTransactionWithRequest(ctx context.Context, tx *sql.
 	if err != nil {
 		return fmt.Errorf("UPDATE requests %v", err)
 	}
+	return nil
+}
+
+func (s *SQLite3Store) writeTransaction(ctx context.Context, tx *sql.Tx, trx *Transaction, utxos []*TransactionInput, utxoState int) error {
+	vals := []any{trx.TransactionHash, trx.RawTransaction, trx.Holder, trx.Chain, trx.AssetId, trx.State, trx.Data, trx.RequestId, trx.CreatedAt, trx.UpdatedAt}
+	err := s.execOne(ctx, tx, buildInsertionSQL("transactions", transactionCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT transactions %v", err)
+	}
 	if !transactionHasOutputs(trx.Chain) {
 		return nil
 	}
-	query := "UPDATE bitcoin_outputs SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=?"
+	table := transactionOutputsTable(trx.Chain)
+	query := fmt.Sprintf("UPDATE %s SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=?", table)
 	for _, utxo := range utxos {
 		err = s.execOne(ctx, tx, query, utxoState, trx.TransactionHash, trx.UpdatedAt, utxo.Hash, utxo.Index)
 		if err != nil {
-			return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+			return fmt.Errorf("UPDATE %s %v", table, err)
 		}
 	}
 	return nil
@@ -237,12 +292,13 @@ func (s *SQLite3Store) RevokeTransactionWithRequest(ctx context.Context, trx *Tr
 
 	if transactionHasOutputs(trx.Chain) {
 		inputs := TransactionInputsFromRawTransaction(trx)
-		update := "UPDATE bitcoin_outputs SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=? AND spent_by=?"
-		query := "SELECT address FROM bitcoin_outputs WHERE transaction_hash=? AND output_index=?"
+		table := transactionOutputsTable(trx.Chain)
+		update := fmt.Sprintf("UPDATE %s SET state=?, spent_by=?, updated_at=? WHERE transaction_hash=? AND output_index=? AND spent_by=?", table)
+		query := fmt.Sprintf("SELECT address FROM %s WHERE transaction_hash=? AND output_index=?", table)
 		for _, in := range inputs {
 			err = s.execOne(ctx, tx, update, common.RequestStateInitial, nil, req.CreatedAt, in.Hash, in.Index, trx.TransactionHash)
 			if err != nil {
-				return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+				return fmt.Errorf("UPDATE %s %v", table, err)
 			}
 
 			var receiver string
@@ -331,24 +387,35 @@ func (s *SQLite3Store) readTransaction(ctx context.Context, tx *sql.Tx, transact
 	return &trx, err
 }
 
+func transactionOutputsTable(chain byte) string {
+	switch chain {
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash:
+		return "bitcoin_outputs"
+	case mixin.ChainMixinKernel:
+		return "mixin_outputs"
+	default:
+		panic(chain)
+	}
+}
+
 func transactionHasOutputs(chain byte) bool {
 	switch chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash, mixin.ChainMixinKernel:
 		return true
-	case ethereum.ChainEthereum, ethereum.ChainPolygon:
+	}
+	if ethereum.IsEVMChain(chain) {
 		return false
-	default:
-		panic(chain)
 	}
+	panic(chain)
 }
 
 func transactionHasBalance(chain byte) bool {
 	switch chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainBitcoinCash, mixin.ChainMixinKernel:
 		return false
-	case ethereum.ChainEthereum, ethereum.ChainPolygon:
+	}
+	if ethereum.IsEVMChain(chain) {
 		return true
-	default:
-		panic(chain)
 	}
+	panic(chain)
 }
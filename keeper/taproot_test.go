@@ -0,0 +1,409 @@
+package keeper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/client"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTaprootKeyHolderPrivate      = "82239bb7d5c6431d57daf0508067a2efcce3331dbd55dcf1a4bb4330ad1ea962"
+	testTaprootKeyObserverPrivate    = "64442d726704ab72729656506948cba022f29c9bc05b588cc7c8d8860c3930c9"
+	testTaprootKeyObserverChainCode  = "1db02371c5bb7774430835ead1d20778cb2f7ea5b532b4258183133f99d76e1d"
+	testTaprootTransactionAmount     = 0.000123
+	testTaprootRecoveryOutputSatoshi = 80000
+)
+
+func TestBitcoinKeeperTaproot(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, _, signers := testPrepare(require)
+
+	mpc, observer := testTaprootPrepareKeys(ctx, require, node, signers)
+	rid, address := testTaprootProposeAccount(ctx, require, node, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1SchnorrBitcoin)
+	testTaprootApproveAccount(ctx, require, node, mpc, observer, rid, address)
+
+	holder := testPublicKey(testTaprootKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(byte(common.CurveSecp256k1SchnorrBitcoin), bitcoinSafeSignerCurve(safe))
+	bondId := testDeployBondContract(ctx, require, node, address, common.SafeBitcoinChainId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{
+		UnifiedOutput: *output,
+	})
+
+	testTaprootWriteUTXOs(ctx, require, node, safe, 86560, 100000)
+	outputs, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(outputs, 2)
+
+	transactionHash := testTaprootProposeTransaction(ctx, require, node, safe, bondId)
+	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 2)
+	testTaprootApproveTransaction(ctx, require, node, safe, transactionHash, signers)
+	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(outputs, 0)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+
+	inputs := testTaprootWriteUTXOs(ctx, require, node, safe, testTaprootRecoveryOutputSatoshi)
+	testTaprootCloseAccount(ctx, require, node, safe, inputs)
+	safe, _ = node.store.ReadSafe(ctx, holder)
+	require.Equal(common.RequestStateFailed, int(safe.State))
+	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(outputs, 0)
+}
+
+// testTaprootPrepareKeys makes the FROST signer key with the signer nodes,
+// and adds it together with a new observer key to the keeper
+func testTaprootPrepareKeys(ctx context.Context, require *require.Assertions, node *Node, signers []*signer.Node) (string, string) {
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1SchnorrBitcoin)
+	mpc, cc := signer.TestFROSTKeyGen(ctx, require, signers, common.CurveSecp256k1SchnorrBitcoin)
+	require.Nil(bitcoin.VerifyTaprootKey(mpc))
+
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := append([]byte{common.RequestRoleSigner}, common.DecodeHexOrPanic(cc)...)
+	extra = append(extra, common.RequestFlagNone)
+	out := testBuildSignerOutput(node, id, mpc, common.OperationTypeKeygenOutput, extra, common.CurveSecp256k1SchnorrBitcoin)
+	testStep(ctx, require, node, out)
+	testSpareKeys(ctx, require, node, 0, 1, 0, common.CurveSecp256k1SchnorrBitcoin)
+
+	id = uuid.Must(uuid.NewV4()).String()
+	observer := testPublicKey(testTaprootKeyObserverPrivate)
+	occ := common.DecodeHexOrPanic(testTaprootKeyObserverChainCode)
+	extra = append([]byte{common.RequestRoleObserver}, occ...)
+	extra = append(extra, common.RequestFlagNone)
+	out = testBuildObserverRequest(node, id, observer, common.ActionObserverAddKey, extra, common.CurveSecp256k1SchnorrBitcoin)
+	testStep(ctx, require, node, out)
+	testSpareKeys(ctx, require, node, 0, 1, 1, common.CurveSecp256k1SchnorrBitcoin)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
+	return mpc, observer
+}
+
+func testTaprootProposeAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string) (string, string) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testPublicKey(testTaprootKeyHolderPrivate)
+	extra := testRecipient()
+	price := decimal.NewFromFloat(testAccountPriceAmount)
+	out := testBuildHolderRequestWithCurve(node, id, holder, common.ActionBitcoinSafeProposeAccount, testAccountPriceAssetId, extra, price, common.CurveSecp256k1SchnorrBitcoin)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionBitcoinSafeProposeAccount)
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.True(bitcoin.CheckTaprootScript(wsa.Script))
+
+	safe, err := node.store.ReadSafeProposal(ctx, id)
+	require.Nil(err)
+	require.Equal(id, safe.RequestId)
+	require.Equal(holder, safe.Holder)
+	require.Equal(signer, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	require.Equal(hex.EncodeToString(taprootDefaultDerivationPath()), safe.Path)
+	public, err := bitcoin.BuildTaprootAccount(holder, signer, observer, testTimelockDuration, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(public.Address, wsa.Address)
+	require.Equal(public.Address, safe.Address)
+	require.Equal(public.Sequence, wsa.Sequence)
+	require.Equal(byte(1), safe.Threshold)
+	require.Len(safe.Receivers, 1)
+	require.Equal(testSafeBondReceiverId, safe.Receivers[0])
+
+	return id, wsa.Address
+}
+
+func testTaprootApproveAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string, rid, address string) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testPublicKey(testTaprootKeyHolderPrivate)
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid, address)
+	hash := bitcoin.HashMessageForSignature(ms, common.SafeChainBitcoin)
+	hb, _ := hex.DecodeString(testTaprootKeyHolderPrivate)
+	hp, _ := btcec.PrivKeyFromBytes(hb)
+	signature := ecdsa.Sign(hp, hash)
+	extra := uuid.FromStringOrNil(rid).Bytes()
+	extra = append(extra, signature.Serialize()...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeApproveAccount, extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionBitcoinSafeApproveAccount)
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.Equal(address, wsa.Address)
+
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(id, safe.RequestId)
+	require.Equal(holder, safe.Holder)
+	require.Equal(signer, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	require.Equal(address, safe.Address)
+	require.Equal(SafeStateApproved, int(safe.State))
+}
+
+// testTaprootWriteUTXOs writes the safe outputs without the deposit RPC,
+// because the taproot safe address is new for each test run
+func testTaprootWriteUTXOs(ctx context.Context, require *require.Assertions, node *Node, safe *store.Safe, satoshis ...int64) []*bitcoin.Input {
+	wsa, err := node.buildBitcoinTaprootAccountWithDerivation(ctx, safe.Holder, safe.Signer, safe.Observer, taprootDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(safe.Address, wsa.Address)
+
+	var inputs []*bitcoin.Input
+	for _, satoshi := range satoshis {
+		id := uuid.Must(uuid.NewV4()).String()
+		req := &common.Request{
+			Id:        id,
+			MixinHash: crypto.Sha256Hash([]byte(id)),
+			AssetId:   common.SafeBitcoinChainId,
+			Role:      common.RequestRoleObserver,
+			Action:    common.ActionObserverHolderDeposit,
+			Curve:     common.CurveSecp256k1ECDSABitcoin,
+			Holder:    safe.Holder,
+			State:     common.RequestStateInitial,
+			CreatedAt: time.Now().UTC(),
+			Output:    &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: id}},
+		}
+		err := node.store.WriteRequestIfNotExist(ctx, req)
+		require.Nil(err)
+		input := &bitcoin.Input{
+			TransactionHash: crypto.Sha256Hash([]byte(id + "UTXO")).String(),
+			Satoshi:         satoshi,
+			Script:          wsa.Script,
+			Sequence:        wsa.Sequence,
+		}
+		err = node.store.WriteBitcoinOutputFromRequest(ctx, safe, input, req, common.SafeBitcoinChainId, testTransactionReceiver, nil)
+		require.Nil(err)
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+func testTaprootProposeTransaction(ctx context.Context, require *require.Assertions, node *Node, safe *store.Safe, bondId string) string {
+	rid := uuid.Must(uuid.NewV4()).String()
+	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	extra := []byte{common.FlagProposeNormalTransaction}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, []byte(testTransactionReceiver)...)
+	amount := decimal.NewFromFloat(testTaprootTransactionAmount)
+	out := testBuildHolderRequest(node, rid, safe.Holder, common.ActionBitcoinSafeProposeTransaction, bondId, extra, amount)
+	testStep(ctx, require, node, out)
+
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionBitcoinSafeProposeTransaction)
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	require.False(psbt.IsRecoveryTransaction())
+	for idx := range psbt.Inputs {
+		require.True(psbt.IsTaprootKeySpendInput(idx))
+		ks, err := psbt.TaprootKeySpend(idx)
+		require.Nil(err)
+		require.Equal(safe.Holder, hex.EncodeToString(ks.Holder.SerializeCompressed()))
+		require.Equal(safe.Signer, hex.EncodeToString(schnorr.SerializePubKey(ks.Signer)))
+	}
+
+	tx := psbt.UnsignedTx
+	require.Len(tx.TxOut, 3)
+	main := tx.TxOut[0]
+	require.Equal(int64(12300), main.Value)
+	receiver, err := bitcoin.ExtractPkScriptAddr(main.PkScript, safe.Chain)
+	require.Nil(err)
+	require.Equal(testTransactionReceiver, receiver)
+	change, err := bitcoin.ExtractPkScriptAddr(tx.TxOut[1].PkScript, safe.Chain)
+	require.Nil(err)
+	require.Equal(safe.Address, change)
+
+	stx, err := node.store.ReadTransaction(ctx, psbt.Hash())
+	require.Nil(err)
+	require.Equal(hex.EncodeToString(psbt.Marshal()), stx.RawTransaction)
+	require.Equal(common.RequestStateInitial, stx.State)
+	return stx.TransactionHash
+}
+
+// testTaprootApproveTransaction approves the transaction with the holder
+// nonces, then the signer nodes make their MuSig2 partial signatures in one
+// batch, and the holder combines them to the key path signatures at last
+func testTaprootApproveTransaction(ctx context.Context, require *require.Assertions, node *Node, safe *store.Safe, transactionHash string, signers []*signer.Node) {
+	tx, _ := node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+	hb := common.DecodeHexOrPanic(testTaprootKeyHolderPrivate)
+	hp, _ := btcec.PrivKeyFromBytes(hb)
+
+	// the transaction without the holder nonces is not approved
+	id := testTaprootWriteApproval(ctx, require, node, safe, tx, common.DecodeHexOrPanic(tx.RawTransaction))
+	req, err := node.store.ReadRequest(ctx, id)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, int(req.State))
+
+	raw, nonces, err := client.SignBitcoinTransaction(hp, tx.RawTransaction)
+	require.Nil(err)
+	hpsbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	require.Len(nonces, len(hpsbt.Inputs))
+	id = testTaprootWriteApproval(ctx, require, node, safe, tx, hpsbt.Marshal())
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, len(hpsbt.Inputs))
+	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStatePending, tx.State)
+
+	batchId := common.UniqueId(id, "BATCH:0")
+	batch, err := node.store.ReadSignatureBatch(ctx, batchId)
+	require.Nil(err)
+	require.Len(batch.RequestIds, len(requests))
+	messages := make(map[string][]byte)
+	for _, r := range requests {
+		messages[r.RequestId] = common.DecodeHexOrPanic(r.Message)
+	}
+	var msgs [][]byte
+	for _, id := range batch.RequestIds {
+		msg := messages[id]
+		require.Len(msg, bitcoin.TaprootKeySpendMessageSize)
+		ks, err := bitcoin.ParseTaprootKeySpendMessage(msg, safe.Signer)
+		require.Nil(err)
+		require.Equal(safe.Holder, hex.EncodeToString(ks.Holder.SerializeCompressed()))
+		msgs = append(msgs, msg)
+	}
+	payload := common.EncodeBatchItems(msgs)
+	signer.TestWriteStorageExtra(ctx, signers, payload)
+	digest := crypto.Sha256Hash(payload)
+	out := testBuildSignerOutput(node, batchId, safe.Signer, common.OperationTypeBatchSignInput, digest[:], common.CurveSecp256k1SchnorrBitcoin)
+	op := signer.TestProcessOutput(ctx, require, signers, out, batchId)
+	require.Equal(common.OperationTypeBatchSignOutput, int(op.Type))
+	sigs := signer.TestReadStorageExtra(ctx, signers[0], op.Extra)
+	err = node.store.WriteProperty(ctx, hex.EncodeToString(op.Extra), base64.RawURLEncoding.EncodeToString(sigs))
+	require.Nil(err)
+	out = testBuildSignerOutput(node, batchId, safe.Signer, common.OperationTypeBatchSignOutput, op.Extra, common.CurveSecp256k1SchnorrBitcoin)
+	testStep(ctx, require, node, out)
+	batch, _ = node.store.ReadSignatureBatch(ctx, batchId)
+	require.Equal(common.RequestStateDone, batch.State)
+	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateDone)
+	require.Len(requests, len(hpsbt.Inputs))
+	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateDone, tx.State)
+
+	mb := common.DecodeHexOrPanic(tx.RawTransaction)
+	sTraceId := crypto.Blake3Hash([]byte(common.Base91Encode(mb))).String()
+	sTraceId = mtg.UniqueId(sTraceId, sTraceId)
+	rid := common.UniqueId(transactionHash, sTraceId)
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionBitcoinSafeApproveTransaction)
+	require.Equal(mb, b)
+
+	// the observer combines the signer partial signatures to the holder approved
+	// transaction, and the holder finishes the key path signatures with it
+	spsbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(mb)
+	for idx := range hpsbt.Inputs {
+		sig := spsbt.InputSignature(idx, safe.Signer)
+		require.Len(sig, 98)
+		require.Nil(hpsbt.VerifyInputSignature(idx, safe.Signer, sig))
+		hpsbt.AddInputSignature(idx, safe.Signer, sig)
+	}
+	_, err = client.FinishBitcoinTransaction(hp, hex.EncodeToString(hpsbt.Marshal()), nil)
+	require.NotNil(err)
+	raw, err = client.FinishBitcoinTransaction(hp, hex.EncodeToString(hpsbt.Marshal()), nonces)
+	require.Nil(err)
+	fpsbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	msgTx, err := fpsbt.SignedTransaction(safe.Holder, safe.Signer, safe.Observer)
+	require.Nil(err)
+	for idx := range msgTx.TxIn {
+		require.Len(msgTx.TxIn[idx].Witness, 1)
+	}
+	testTaprootVerifyTransaction(require, fpsbt, msgTx)
+}
+
+func testTaprootWriteApproval(ctx context.Context, require *require.Assertions, node *Node, safe *store.Safe, tx *store.Transaction, raw []byte) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	ref := crypto.Sha256Hash(raw)
+	err := node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+	extra := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+	extra = append(extra, ref[:]...)
+	out := testBuildObserverRequest(node, id, safe.Holder, common.ActionBitcoinSafeApproveTransaction, extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+// testTaprootCloseAccount spends the recovery leaf with the holder and the
+// observer, which is the only case the script is revealed
+func testTaprootCloseAccount(ctx context.Context, require *require.Assertions, node *Node, safe *store.Safe, inputs []*bitcoin.Input) {
+	hb := common.DecodeHexOrPanic(testTaprootKeyHolderPrivate)
+	hp, _ := btcec.PrivKeyFromBytes(hb)
+	ob := common.DecodeHexOrPanic(testTaprootKeyObserverPrivate)
+	op, _ := btcec.PrivKeyFromBytes(ob)
+
+	var total int64
+	for _, in := range inputs {
+		in.RouteBackup = true
+		total = total + in.Satoshi
+	}
+	id := uuid.Must(uuid.NewV4())
+	outputs := []*bitcoin.Output{{Address: testTransactionReceiver, Satoshi: total}}
+	psbt, err := bitcoin.BuildPartiallySignedTransaction(inputs, outputs, id.Bytes(), safe.Chain)
+	require.Nil(err)
+	require.True(psbt.IsRecoveryTransaction())
+	for idx := range psbt.Inputs {
+		require.True(psbt.IsTaprootInput(idx))
+		require.False(psbt.IsTaprootKeySpendInput(idx))
+	}
+	psbt = bitcoin.SignPartiallySignedTransaction(psbt.Marshal(), hp)
+	for idx := range psbt.Inputs {
+		sig, err := schnorr.Sign(op, psbt.SigHash(idx))
+		require.Nil(err)
+		psbt.AddInputSignature(idx, safe.Observer, sig.Serialize())
+	}
+	raw := psbt.Marshal()
+	require.True(bitcoin.CheckTransactionPartiallySignedBy(hex.EncodeToString(raw), safe.Holder))
+	require.True(bitcoin.CheckTransactionPartiallySignedBy(hex.EncodeToString(raw), safe.Observer))
+
+	ref := crypto.Sha256Hash(raw)
+	err = node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+	extra := uuid.Nil.Bytes()
+	extra = append(extra, ref[:]...)
+	out := testBuildObserverRequest(node, id.String(), safe.Holder, common.ActionBitcoinSafeCloseAccount, extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+
+	tx, err := node.store.ReadTransaction(ctx, psbt.Hash())
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, tx.State)
+	require.Equal(hex.EncodeToString(raw), tx.RawTransaction)
+
+	msgTx, err := psbt.SignedTransaction(safe.Holder, safe.Signer, safe.Observer)
+	require.Nil(err)
+	for idx := range msgTx.TxIn {
+		require.Len(msgTx.TxIn[idx].Witness, 5)
+	}
+	testTaprootVerifyTransaction(require, psbt, msgTx)
+}
+
+func testTaprootVerifyTransaction(require *require.Assertions, psbt *bitcoin.PartiallySignedTransaction, msgTx *wire.MsgTx) {
+	for idx := range msgTx.TxIn {
+		utxo := psbt.Inputs[idx].WitnessUtxo
+		pof := txscript.NewCannedPrevOutputFetcher(utxo.PkScript, utxo.Value)
+		tsh := txscript.NewTxSigHashes(msgTx, pof)
+		vm, err := txscript.NewEngine(utxo.PkScript, msgTx, idx, txscript.StandardVerifyFlags, nil, tsh, utxo.Value, pof)
+		require.Nil(err)
+		require.Nil(vm.Execute())
+	}
+}
@@ -0,0 +1,382 @@
+package keeperapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	listLimitDefault = 100
+	listLimitMaximum = 500
+)
+
+const (
+	rpcErrorParse          = -32700
+	rpcErrorInvalidRequest = -32600
+	rpcErrorMethodNotFound = -32601
+	rpcErrorInvalidParams  = -32602
+	rpcErrorInternal       = -32603
+)
+
+type Call struct {
+	Version string          `json:"jsonrpc"`
+	Id      any             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type Params struct {
+	Holder    string    `json:"holder"`
+	Address   string    `json:"address"`
+	Id        string    `json:"id"`
+	Hash      string    `json:"hash"`
+	RequestId string    `json:"request_id"`
+	Offset    time.Time `json:"offset"`
+	Limit     int       `json:"limit"`
+}
+
+type Server struct {
+	store   *store.SQLite3Store
+	methods map[string]func(context.Context, *Params) (any, error)
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// NewServer serves the JSON-RPC 2.0 queries of the keeper store, the store
+// should be opened read only, so the service could run beside a keeper node
+// without any chance to interfere with it.
+func NewServer(store *store.SQLite3Store) *Server {
+	s := &Server{store: store}
+	s.methods = map[string]func(context.Context, *Params) (any, error){
+		"getSafe":             s.getSafe,
+		"getSafeBalances":     s.getSafeBalances,
+		"listSafeUTXOs":       s.listSafeUTXOs,
+		"getTransaction":      s.getTransaction,
+		"listTransactions":    s.listTransactions,
+		"getRequest":          s.getRequest,
+		"getSignatureRequest": s.getSignatureRequest,
+	}
+	return s
+}
+
+// StartHTTP serves the queries at the listen address, e.g. 127.0.0.1:7090.
+// The service has no authentication or rate limit, so it should listen on a
+// local address and sit behind a reverse proxy if exposed to the public.
+func StartHTTP(store *store.SQLite3Store, listen string) error {
+	s := NewServer(store)
+	router := httptreemux.New()
+	router.PanicHandler = common.HandlePanic
+	router.NotFoundHandler = common.HandleNotFound
+
+	router.GET("/", s.root)
+	router.POST("/", s.serveRPC)
+	handler := common.HandleCORS(router)
+	return http.ListenAndServe(listen, handler)
+}
+
+func (s *Server) root(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	methods := make([]string, 0, len(s.methods))
+	for m := range s.methods {
+		methods = append(methods, m)
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{"methods": methods})
+}
+
+func (s *Server) serveRPC(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var call Call
+	err := json.NewDecoder(r.Body).Decode(&call)
+	if err != nil {
+		renderRPC(w, r, nil, nil, &Error{rpcErrorParse, err.Error()})
+		return
+	}
+	result, err := s.Handle(r.Context(), &call)
+	renderRPC(w, r, call.Id, result, err)
+}
+
+// Handle returns the result of the call, or an Error with the JSON-RPC code,
+// a store error is reported as an internal error without the details.
+func (s *Server) Handle(ctx context.Context, call *Call) (any, error) {
+	if call.Version != "2.0" || call.Method == "" {
+		return nil, &Error{rpcErrorInvalidRequest, "invalid request"}
+	}
+	method := s.methods[call.Method]
+	if method == nil {
+		return nil, &Error{rpcErrorMethodNotFound, call.Method}
+	}
+	var p Params
+	if len(call.Params) > 0 {
+		err := json.Unmarshal(call.Params, &p)
+		if err != nil {
+			return nil, &Error{rpcErrorInvalidParams, err.Error()}
+		}
+	}
+	result, err := method(ctx, &p)
+	if _, ok := err.(*Error); err != nil && !ok {
+		return nil, &Error{rpcErrorInternal, "internal error"}
+	}
+	return result, err
+}
+
+func renderRPC(w http.ResponseWriter, r *http.Request, id, result any, err error) {
+	resp := map[string]any{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		resp["error"] = err
+	} else {
+		resp["result"] = result
+	}
+	common.RenderJSON(w, r, http.StatusOK, resp)
+}
+
+func (s *Server) readSafe(ctx context.Context, p *Params) (*store.Safe, error) {
+	switch {
+	case p.Holder != "":
+		return s.store.ReadSafe(ctx, p.Holder)
+	case p.Address != "":
+		return s.store.ReadSafeByAddress(ctx, p.Address)
+	}
+	return nil, &Error{rpcErrorInvalidParams, "holder or address required"}
+}
+
+func (s *Server) getSafe(ctx context.Context, p *Params) (any, error) {
+	safe, err := s.readSafe(ctx, p)
+	if err != nil || safe == nil {
+		return nil, err
+	}
+	return viewSafe(safe), nil
+}
+
+func (s *Server) getSafeBalances(ctx context.Context, p *Params) (any, error) {
+	safe, err := s.readSafe(ctx, p)
+	if err != nil || safe == nil {
+		return nil, err
+	}
+	view := make([]map[string]any, 0)
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
+		inputs, err := s.store.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
+		if err != nil {
+			return nil, err
+		}
+		var satoshi int64
+		for _, in := range inputs {
+			satoshi += in.Satoshi
+		}
+		view = append(view, map[string]any{
+			"asset_id": safe.SafeAssetId,
+			"balance":  fmt.Sprint(satoshi),
+			"outputs":  len(inputs),
+		})
+	case common.SafeChainMixin:
+		outputs, err := s.store.ListAllMixinUTXOsForAddress(ctx, safe.Address)
+		if err != nil {
+			return nil, err
+		}
+		var assets []string
+		balances := make(map[string]decimal.Decimal)
+		counts := make(map[string]int)
+		for _, o := range outputs {
+			if counts[o.AssetId] == 0 {
+				assets = append(assets, o.AssetId)
+			}
+			balances[o.AssetId] = balances[o.AssetId].Add(o.Amount)
+			counts[o.AssetId] += 1
+		}
+		for _, id := range assets {
+			view = append(view, map[string]any{
+				"asset_id": id,
+				"balance":  balances[id].String(),
+				"outputs":  counts[id],
+			})
+		}
+	case common.SafeChainEthereum:
+		balances, err := s.store.ReadAllEthereumTokenBalances(ctx, safe.Address)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range balances {
+			view = append(view, map[string]any{
+				"asset_id":       b.AssetId,
+				"asset_address":  b.AssetAddress,
+				"safe_asset_id":  b.SafeAssetId,
+				"balance":        b.BigBalance().String(),
+				"latest_tx_hash": b.LatestTxHash,
+				"updated_at":     b.UpdatedAt,
+			})
+		}
+	}
+	return view, nil
+}
+
+func (s *Server) listSafeUTXOs(ctx context.Context, p *Params) (any, error) {
+	safe, err := s.readSafe(ctx, p)
+	if err != nil || safe == nil {
+		return nil, err
+	}
+	if common.SafeChainFamily(safe.Chain) != common.SafeChainBitcoin {
+		return nil, &Error{rpcErrorInvalidParams, "bitcoin safe required"}
+	}
+	initial, err := s.store.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.store.ListPendingBitcoinUTXOsForHolder(ctx, safe.Holder)
+	if err != nil {
+		return nil, err
+	}
+	view := make([]map[string]any, 0)
+	for i, inputs := range [][]*bitcoin.Input{initial, pending} {
+		state := common.RequestStateInitial
+		if i > 0 {
+			state = common.RequestStatePending
+		}
+		for _, in := range inputs {
+			view = append(view, map[string]any{
+				"transaction_hash": in.TransactionHash,
+				"index":            in.Index,
+				"satoshi":          in.Satoshi,
+				"state":            common.StateName(state),
+			})
+		}
+	}
+	return view, nil
+}
+
+func (s *Server) getTransaction(ctx context.Context, p *Params) (any, error) {
+	var tx *store.Transaction
+	var err error
+	switch {
+	case p.Hash != "":
+		tx, err = s.store.ReadTransaction(ctx, p.Hash)
+	case p.RequestId != "":
+		tx, err = s.store.ReadTransactionByRequestId(ctx, p.RequestId)
+	default:
+		return nil, &Error{rpcErrorInvalidParams, "hash or request_id required"}
+	}
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	return viewTransaction(tx), nil
+}
+
+// listTransactions pages by the offset and hash of the last transaction in
+// the previous page.
+func (s *Server) listTransactions(ctx context.Context, p *Params) (any, error) {
+	if p.Holder == "" {
+		return nil, &Error{rpcErrorInvalidParams, "holder required"}
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = listLimitDefault
+	}
+	if limit > listLimitMaximum {
+		return nil, &Error{rpcErrorInvalidParams, "limit too large"}
+	}
+	txs, err := s.store.ListTransactionsByHolder(ctx, p.Holder, p.Offset, p.Hash, limit)
+	if err != nil {
+		return nil, err
+	}
+	view := make([]map[string]any, 0)
+	for _, tx := range txs {
+		view = append(view, viewTransaction(tx))
+	}
+	return view, nil
+}
+
+func (s *Server) getRequest(ctx context.Context, p *Params) (any, error) {
+	if p.Id == "" {
+		return nil, &Error{rpcErrorInvalidParams, "id required"}
+	}
+	req, err := s.store.ReadRequest(ctx, p.Id)
+	if err != nil || req == nil {
+		return nil, err
+	}
+	return map[string]any{
+		"id":         req.Id,
+		"mixin_hash": req.MixinHash.String(),
+		"asset_id":   req.AssetId,
+		"amount":     req.Amount.String(),
+		"role":       req.Role,
+		"action":     req.Action,
+		"curve":      req.Curve,
+		"holder":     req.Holder,
+		"extra":      req.ExtraHEX,
+		"state":      common.StateName(int(req.State)),
+		"created_at": req.CreatedAt,
+	}, nil
+}
+
+func (s *Server) getSignatureRequest(ctx context.Context, p *Params) (any, error) {
+	if p.Id == "" {
+		return nil, &Error{rpcErrorInvalidParams, "id required"}
+	}
+	sr, err := s.store.ReadSignatureRequest(ctx, p.Id)
+	if err != nil || sr == nil {
+		return nil, err
+	}
+	view := map[string]any{
+		"id":               sr.RequestId,
+		"transaction_hash": sr.TransactionHash,
+		"input_index":      sr.InputIndex,
+		"signer":           sr.Signer,
+		"curve":            sr.Curve,
+		"message":          sr.Message,
+		"state":            common.StateName(sr.State),
+		"created_at":       sr.CreatedAt,
+		"updated_at":       sr.UpdatedAt,
+	}
+	if sr.Signature.Valid {
+		view["signature"] = sr.Signature.String
+	}
+	return view, nil
+}
+
+func viewSafe(safe *store.Safe) map[string]any {
+	return map[string]any{
+		"holder":        safe.Holder,
+		"chain":         safe.Chain,
+		"signer":        safe.Signer,
+		"observer":      safe.Observer,
+		"timelock":      safe.Timelock / time.Hour,
+		"path":          safe.Path,
+		"address":       safe.Address,
+		"receivers":     safe.Receivers,
+		"threshold":     safe.Threshold,
+		"request_id":    safe.RequestId,
+		"nonce":         safe.Nonce,
+		"state":         common.StateName(int(safe.State)),
+		"safe_asset_id": safe.SafeAssetId,
+		"created_at":    safe.CreatedAt,
+		"updated_at":    safe.UpdatedAt,
+	}
+}
+
+func viewTransaction(tx *store.Transaction) map[string]any {
+	return map[string]any{
+		"hash":       tx.TransactionHash,
+		"raw":        tx.RawTransaction,
+		"holder":     tx.Holder,
+		"chain":      tx.Chain,
+		"asset_id":   tx.AssetId,
+		"state":      common.StateName(tx.State),
+		"data":       tx.Data,
+		"request_id": tx.RequestId,
+		"created_at": tx.CreatedAt,
+		"updated_at": tx.UpdatedAt,
+	}
+}
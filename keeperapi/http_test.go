@@ -0,0 +1,43 @@
+package keeperapi
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeeperAPI(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-keeperapi-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+	kd, err := store.OpenSQLite3Store(root + "/safe.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+	s := NewServer(kd)
+
+	_, err = s.Handle(ctx, &Call{Version: "1.0", Method: "getSafe"})
+	require.Equal(rpcErrorInvalidRequest, err.(*Error).Code)
+	_, err = s.Handle(ctx, &Call{Version: "2.0", Method: "writeSafe"})
+	require.Equal(rpcErrorMethodNotFound, err.(*Error).Code)
+	_, err = s.Handle(ctx, &Call{Version: "2.0", Method: "getSafe"})
+	require.Equal(rpcErrorInvalidParams, err.(*Error).Code)
+	_, err = s.Handle(ctx, &Call{Version: "2.0", Method: "listTransactions", Params: json.RawMessage(`{"holder":"h","limit":1000}`)})
+	require.Equal(rpcErrorInvalidParams, err.(*Error).Code)
+
+	result, err := s.Handle(ctx, &Call{Version: "2.0", Method: "getSafe", Params: json.RawMessage(`{"holder":"h"}`)})
+	require.Nil(err)
+	require.Nil(result)
+	result, err = s.Handle(ctx, &Call{Version: "2.0", Method: "listTransactions", Params: json.RawMessage(`{"holder":"h"}`)})
+	require.Nil(err)
+	require.Len(result, 0)
+	result, err = s.Handle(ctx, &Call{Version: "2.0", Method: "getRequest", Params: json.RawMessage(`{"id":"c6d0c728-2624-429b-8e0d-d9d19b6592fa"}`)})
+	require.Nil(err)
+	require.Nil(result)
+}
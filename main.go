@@ -43,6 +43,48 @@ func main() {
 						Usage:   "The configuration file path",
 					},
 				},
+				Subcommands: []*cli.Command{
+					{
+						Name:   "rekey",
+						Usage:  "Encrypt the stored shares with a new key, the signer must be stopped",
+						Action: cmd.SignerRekeyCmd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "config",
+								Aliases: []string{"c"},
+								Value:   "~/.mixin/safe/config.toml",
+								Usage:   "The configuration file path",
+							},
+							&cli.StringFlag{
+								Name:  "key-file",
+								Usage: "The new share key file with 32 bytes key in hex",
+							},
+							&cli.BoolFlag{
+								Name:  "passphrase-stdin",
+								Value: false,
+								Usage: "Read the new share passphrase from stdin instead of SAFE_SIGNER_SHARE_NEW_PASSPHRASE",
+							},
+							&cli.BoolFlag{
+								Name:  "plaintext",
+								Value: false,
+								Usage: "Decrypt all shares without a new key",
+							},
+						},
+					},
+					{
+						Name:   "restore",
+						Usage:  "Restore the lost shares from the saver backups, the signer must be stopped",
+						Action: cmd.SignerRestoreCmd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "config",
+								Aliases: []string{"c"},
+								Value:   "~/.mixin/safe/config.toml",
+								Usage:   "The configuration file path",
+							},
+						},
+					},
+				},
 			},
 			{
 				Name:   "keygen",
@@ -119,6 +161,24 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "keeperapi",
+				Usage:  "Run the read only query service of a keeper store",
+				Action: cmd.KeeperAPIBootCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+					&cli.StringFlag{
+						Name:  "listen",
+						Value: "127.0.0.1:7090",
+						Usage: "The query service HTTP address to listen, behind a reverse proxy if public",
+					},
+				},
+			},
 			{
 				Name:   "observer",
 				Usage:  "Run the observer node",
@@ -132,6 +192,22 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "observerkeysigner",
+				Usage:  "Serve the observer keys list to sign on a unix socket",
+				Action: cmd.ObserverKeySignerCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "list",
+						Usage: "The observer and accountant private keys file",
+					},
+					&cli.StringFlag{
+						Name:  "socket",
+						Value: "/tmp/mixin-safe-observer-signer.sock",
+						Usage: "The unix socket path to listen",
+					},
+				},
+			},
 			{
 				Name:   "importobserverkeys",
 				Usage:  "Import observer public keys",
@@ -153,6 +229,44 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "requestsignerrefresh",
+				Usage:  "Request the signers to refresh the shares of a signer key",
+				Action: cmd.ObserverSignerRefreshCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+					&cli.StringFlag{
+						Name:  "key",
+						Usage: "The signer public key",
+					},
+				},
+			},
+			{
+				Name:   "requestsignerreshare",
+				Usage:  "Request the signers to reshare a signer key to the current signer members",
+				Action: cmd.ObserverSignerReshareCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The configuration file path",
+					},
+					&cli.StringFlag{
+						Name:  "key",
+						Usage: "The signer public key",
+					},
+					&cli.IntFlag{
+						Name:  "threshold",
+						Usage: "The new MPC threshold of the signer key",
+					},
+				},
+			},
 			{
 				Name:   "fillobserveraccountants",
 				Usage:  "Fill more observer accountant outputs",
@@ -354,6 +468,14 @@ func main() {
 						Name:  "address",
 						Usage: "The receiver address",
 					},
+					&cli.StringSliceFlag{
+						Name:  "recipient",
+						Usage: "The receiver address and amount as address:amount, repeat for multiple recipients",
+					},
+					&cli.StringFlag{
+						Name:  "reference",
+						Usage: "The storage transaction hash of the encoded recipients",
+					},
 					&cli.Float64Flag{
 						Name:  "amount",
 						Usage: "The amount",
@@ -0,0 +1,437 @@
+package messenger
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// The direct messenger connects the signer nodes with each other by TCP, all
+// connections are authenticated by the ed25519 keys of both peers and then
+// encrypted by a session key from ephemeral x25519 exchange. Each connection
+// only transfers messages from the dialer to the listener.
+
+const (
+	directHandshakeTimeout = 10 * time.Second
+	directWriteTimeout     = 30 * time.Second
+	directClockSkew        = time.Minute
+	directFrameLimit       = 16 * 1024 * 1024
+	directSendRetries      = 3
+)
+
+type DirectPeer struct {
+	Id        string `toml:"id"`
+	Address   string `toml:"address"`
+	PublicKey string `toml:"public-key"`
+}
+
+type DirectConfiguration struct {
+	UserId        string
+	Key           string
+	Listen        string
+	Peers         []*DirectPeer
+	SendBuffer    int
+	ReceiveBuffer int
+}
+
+type DirectMessenger struct {
+	conf     *DirectConfiguration
+	key      ed25519.PrivateKey
+	peers    map[string]*directPeer
+	listener net.Listener
+	recv     chan *MixinMessage
+}
+
+type directPeer struct {
+	id      string
+	address string
+	public  ed25519.PublicKey
+	send    chan []byte
+}
+
+type directConn struct {
+	conn  net.Conn
+	aead  cipher.AEAD
+	nonce uint64
+}
+
+// DeriveDirectKey derives the direct messenger ed25519 seed from the MTG app
+// session key, so that no extra secret is needed for the signer network
+func DeriveDirectKey(userId, sessionKey string) string {
+	seed, err := hex.DecodeString(sessionKey)
+	if err != nil || (len(seed) != ed25519.SeedSize && len(seed) != ed25519.PrivateKeySize) {
+		panic(fmt.Errorf("invalid session key %s", sessionKey))
+	}
+	seed = append(seed[:ed25519.SeedSize], []byte(userId)...)
+	seed = append(seed, []byte("SAFE:MESSENGER:DIRECT")...)
+	sum := sha256.Sum256(seed)
+	return hex.EncodeToString(sum[:])
+}
+
+func DirectPublicKey(key string) string {
+	priv := parseDirectKey(key)
+	return hex.EncodeToString(priv.Public().(ed25519.PublicKey))
+}
+
+func NewDirectMessenger(ctx context.Context, conf *DirectConfiguration) (*DirectMessenger, error) {
+	if conf.SendBuffer >= 100 || conf.SendBuffer == 0 {
+		panic(fmt.Errorf("messenger messages limit %d", conf.SendBuffer))
+	}
+
+	dm := &DirectMessenger{
+		conf:  conf,
+		key:   parseDirectKey(conf.Key),
+		peers: make(map[string]*directPeer),
+		recv:  make(chan *MixinMessage, conf.ReceiveBuffer),
+	}
+	for _, p := range conf.Peers {
+		pub, err := hex.DecodeString(p.PublicKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid peer %s public key %s", p.Id, p.PublicKey)
+		}
+		if p.Id == "" || len(p.Id) > 255 || dm.peers[p.Id] != nil {
+			return nil, fmt.Errorf("invalid peer id %s", p.Id)
+		}
+		dm.peers[p.Id] = &directPeer{
+			id:      p.Id,
+			address: p.Address,
+			public:  pub,
+			send:    make(chan []byte, conf.SendBuffer),
+		}
+	}
+
+	listener, err := net.Listen("tcp", conf.Listen)
+	if err != nil {
+		return nil, err
+	}
+	dm.listener = listener
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	go dm.loopAccept(ctx)
+	for _, p := range dm.peers {
+		if p.id == conf.UserId {
+			continue
+		}
+		go dm.loopSend(ctx, p)
+	}
+
+	return dm, nil
+}
+
+func (dm *DirectMessenger) ReceiveMessage(ctx context.Context) (*MixinMessage, error) {
+	select {
+	case msg := <-dm.recv:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ErrorDone
+	}
+}
+
+func (dm *DirectMessenger) QueueMessage(ctx context.Context, receiver string, b []byte) error {
+	if receiver == dm.conf.UserId {
+		return dm.deliver(ctx, receiver, b, time.Now())
+	}
+	p := dm.peers[receiver]
+	if p == nil {
+		return fmt.Errorf("messenger.QueueMessage(%s) unknown peer", receiver)
+	}
+	data := binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+	data = append(data, b...)
+	select {
+	case p.send <- data:
+		return nil
+	case <-ctx.Done():
+		return ErrorDone
+	}
+}
+
+func (dm *DirectMessenger) deliver(ctx context.Context, peer string, b []byte, createdAt time.Time) error {
+	msg := &MixinMessage{Peer: peer, Data: b, CreatedAt: createdAt}
+	select {
+	case dm.recv <- msg:
+		return nil
+	case <-ctx.Done():
+		return ErrorDone
+	}
+}
+
+func (dm *DirectMessenger) loopAccept(ctx context.Context) {
+	for {
+		conn, err := dm.listener.Accept()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Printf("messenger.loopAccept() => %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go dm.handleConn(ctx, conn)
+	}
+}
+
+func (dm *DirectMessenger) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	peer, dc, err := dm.acceptHandshake(conn)
+	logger.Verbosef("messenger.acceptHandshake(%s) => %v", conn.RemoteAddr(), err)
+	if err != nil {
+		return
+	}
+	for {
+		data, err := dc.readFrame()
+		if err != nil {
+			logger.Verbosef("messenger.readFrame(%s) => %v", peer.id, err)
+			return
+		}
+		if len(data) < 8 {
+			return
+		}
+		ts := binary.BigEndian.Uint64(data[:8])
+		err = dm.deliver(ctx, peer.id, data[8:], time.Unix(0, int64(ts)))
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (dm *DirectMessenger) loopSend(ctx context.Context, p *directPeer) {
+	var dc *directConn
+	for {
+		var data []byte
+		select {
+		case data = <-p.send:
+		case <-ctx.Done():
+			if dc != nil {
+				dc.conn.Close()
+			}
+			return
+		}
+
+		var err error
+		for i := 0; i < directSendRetries; i++ {
+			if dc == nil {
+				dc, err = dm.dialHandshake(ctx, p)
+				logger.Verbosef("messenger.dialHandshake(%s, %s) => %v", p.id, p.address, err)
+				if err != nil {
+					time.Sleep(time.Second)
+					continue
+				}
+			}
+			err = dc.writeFrame(data)
+			if err == nil {
+				break
+			}
+			logger.Verbosef("messenger.writeFrame(%s) => %v", p.id, err)
+			dc.conn.Close()
+			dc = nil
+		}
+		if err != nil {
+			logger.Printf("messenger.loopSend(%s, %d) dropped => %v", p.id, len(data), err)
+		}
+	}
+}
+
+// the hello message is the peer id, the ephemeral x25519 public key, the
+// timestamp, and the ed25519 signature of the handshake transcript
+func (dm *DirectMessenger) dialHandshake(ctx context.Context, p *directPeer) (*directConn, error) {
+	dialer := &net.Dialer{Timeout: directHandshakeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(directHandshakeTimeout))
+
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	ts := uint64(time.Now().UnixNano())
+	msg := directTranscript(dm.conf.UserId, p.id, eph.PublicKey().Bytes(), nil, ts)
+	err = writeDirectHello(conn, dm.conf.UserId, eph.PublicKey().Bytes(), ts, ed25519.Sign(dm.key, msg))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	id, pub, rts, sig, err := readDirectHello(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	msg = directTranscript(p.id, dm.conf.UserId, pub, eph.PublicKey().Bytes(), rts)
+	if id != p.id || rts != ts || !ed25519.Verify(p.public, msg, sig) {
+		conn.Close()
+		return nil, fmt.Errorf("invalid handshake from %s", id)
+	}
+	dc, err := newDirectConn(conn, eph, pub, eph.PublicKey().Bytes(), pub)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	go func() {
+		// the listener never writes after handshake, so any read result
+		// means the connection is broken and should not be written anymore
+		io.Copy(io.Discard, conn)
+		conn.Close()
+	}()
+	return dc, nil
+}
+
+func (dm *DirectMessenger) acceptHandshake(conn net.Conn) (*directPeer, *directConn, error) {
+	conn.SetDeadline(time.Now().Add(directHandshakeTimeout))
+
+	id, pub, ts, sig, err := readDirectHello(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := dm.peers[id]
+	if p == nil || id == dm.conf.UserId {
+		return nil, nil, fmt.Errorf("unknown peer %s", id)
+	}
+	diff := time.Since(time.Unix(0, int64(ts)))
+	if diff > directClockSkew || diff < -directClockSkew {
+		return nil, nil, fmt.Errorf("invalid handshake timestamp %s %d", id, ts)
+	}
+	msg := directTranscript(id, dm.conf.UserId, pub, nil, ts)
+	if !ed25519.Verify(p.public, msg, sig) {
+		return nil, nil, fmt.Errorf("invalid handshake signature %s", id)
+	}
+
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	msg = directTranscript(dm.conf.UserId, id, eph.PublicKey().Bytes(), pub, ts)
+	err = writeDirectHello(conn, dm.conf.UserId, eph.PublicKey().Bytes(), ts, ed25519.Sign(dm.key, msg))
+	if err != nil {
+		return nil, nil, err
+	}
+	dc, err := newDirectConn(conn, eph, pub, pub, eph.PublicKey().Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return p, dc, nil
+}
+
+func newDirectConn(conn net.Conn, eph *ecdh.PrivateKey, remote, dialer, listener []byte) (*directConn, error) {
+	pub, err := ecdh.X25519().NewPublicKey(remote)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := eph.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	seed := append(secret, dialer...)
+	seed = append(seed, listener...)
+	key := sha256.Sum256(seed)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &directConn{conn: conn, aead: aead}, nil
+}
+
+func (dc *directConn) nextNonce() []byte {
+	nonce := make([]byte, dc.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], dc.nonce)
+	dc.nonce = dc.nonce + 1
+	return nonce
+}
+
+func (dc *directConn) writeFrame(data []byte) error {
+	sealed := dc.aead.Seal(nil, dc.nextNonce(), data, nil)
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+	frame = append(frame, sealed...)
+	dc.conn.SetWriteDeadline(time.Now().Add(directWriteTimeout))
+	_, err := dc.conn.Write(frame)
+	return err
+}
+
+func (dc *directConn) readFrame() ([]byte, error) {
+	var header [4]byte
+	_, err := io.ReadFull(dc.conn, header[:])
+	if err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > directFrameLimit {
+		return nil, fmt.Errorf("invalid frame size %d", size)
+	}
+	sealed := make([]byte, size)
+	_, err = io.ReadFull(dc.conn, sealed)
+	if err != nil {
+		return nil, err
+	}
+	return dc.aead.Open(nil, dc.nextNonce(), sealed, nil)
+}
+
+func directTranscript(sender, receiver string, pub, remote []byte, ts uint64) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("SAFE:MESSENGER:DIRECT")
+	buf.WriteByte(byte(len(sender)))
+	buf.WriteString(sender)
+	buf.WriteByte(byte(len(receiver)))
+	buf.WriteString(receiver)
+	buf.Write(pub)
+	buf.Write(remote)
+	buf.Write(binary.BigEndian.AppendUint64(nil, ts))
+	return buf.Bytes()
+}
+
+func writeDirectHello(conn net.Conn, id string, pub []byte, ts uint64, sig []byte) error {
+	hello := []byte{byte(len(id))}
+	hello = append(hello, []byte(id)...)
+	hello = append(hello, pub...)
+	hello = binary.BigEndian.AppendUint64(hello, ts)
+	hello = append(hello, sig...)
+	_, err := conn.Write(hello)
+	return err
+}
+
+func readDirectHello(conn net.Conn) (string, []byte, uint64, []byte, error) {
+	var size [1]byte
+	_, err := io.ReadFull(conn, size[:])
+	if err != nil {
+		return "", nil, 0, nil, err
+	}
+	hello := make([]byte, int(size[0])+32+8+ed25519.SignatureSize)
+	_, err = io.ReadFull(conn, hello)
+	if err != nil {
+		return "", nil, 0, nil, err
+	}
+	id := string(hello[:size[0]])
+	hello = hello[size[0]:]
+	ts := binary.BigEndian.Uint64(hello[32:40])
+	return id, hello[:32], ts, hello[40:], nil
+}
+
+func parseDirectKey(key string) ed25519.PrivateKey {
+	seed, err := hex.DecodeString(key)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		panic(fmt.Errorf("invalid direct key %s", key))
+	}
+	return ed25519.NewKeyFromSeed(seed)
+}
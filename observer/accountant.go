@@ -14,8 +14,6 @@ import (
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
-	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
@@ -34,6 +32,7 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 	switch tx.Chain {
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
+	case common.SafeChainBitcoinCash:
 	default:
 		panic(spsbt.Hash())
 	}
@@ -64,34 +63,32 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 
 	for idx, in := range spsbt.UnsignedTx.TxIn {
 		pop := in.PreviousOutPoint
-		hash := spsbt.SigHash(idx)
 		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop)
 		if !required {
 			continue
 		}
+		hpk, ipk := tx.Holder, opk
+		if hpsbt.IsTaprootInput(idx) {
+			hpk, ipk = bitcoin.XOnlyPublicKey(hpk), bitcoin.XOnlyPublicKey(ipk)
+		}
 		hpin := hpsbt.Inputs[idx]
-		hsig := hpin.PartialSigs[0]
+		pubs := hpsbt.InputSigners(idx)
 
 		signedByHolderObserver := false
 		switch in.Sequence {
 		case bitcoin.MaxTransactionSequence: // normal tx
-			if hex.EncodeToString(hsig.PubKey) != tx.Holder {
+			if pubs[0] != hpk {
 				panic(spsbt.Hash())
 			}
 		default: // recovery tx
-			if len(hpin.PartialSigs) == 1 {
+			if len(pubs) == 1 {
 				// observer signer
-				if hex.EncodeToString(hsig.PubKey) != opk {
+				if pubs[0] != ipk {
 					panic(spsbt.Hash())
 				}
 			} else {
 				// holder observer
-				pubs := []string{}
-				for _, sig := range hpin.PartialSigs {
-					pub := hex.EncodeToString(sig.PubKey)
-					pubs = append(pubs, pub)
-				}
-				if slices.Contains(pubs, tx.Holder) && slices.Contains(pubs, opk) {
+				if slices.Contains(pubs, hpk) && slices.Contains(pubs, ipk) {
 					signedByHolderObserver = true
 				} else {
 					panic(spsbt.Hash())
@@ -103,19 +100,29 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 		}
 
 		spin := spsbt.Inputs[idx]
-		ssig := spin.PartialSigs[0]
-		if hex.EncodeToString(ssig.PubKey) != spk {
+		if spsbt.InputSigners(idx)[0] != spk {
 			panic(spsbt.Hash())
 		}
-		if !bytes.Equal(ssig.Signature, signed[idx]) {
+		ssig := spsbt.InputSignature(idx, spk)
+		if !bytes.Equal(ssig, signed[idx]) {
 			panic(spsbt.Hash())
 		}
-		err = bitcoin.VerifySignatureDER(spk, hash, ssig.Signature)
+		if hpsbt.IsTaprootKeySpendInput(idx) {
+			// the signer partial signature is verified with the holder nonce
+			hpsbt.AddInputSignature(idx, spk, ssig)
+			err = hpsbt.VerifyInputSignature(idx, spk, ssig)
+			if err != nil {
+				panic(spsbt.Hash())
+			}
+			continue
+		}
+		err = spsbt.VerifyInputSignature(idx, spk, ssig)
 		if err != nil {
 			panic(spsbt.Hash())
 		}
 
 		hpsbt.Inputs[idx].PartialSigs = append(hpin.PartialSigs, spin.PartialSigs...)
+		hpsbt.Inputs[idx].TaprootScriptSpendSig = append(hpin.TaprootScriptSpendSig, spin.TaprootScriptSpendSig...)
 	}
 
 	raw := hex.EncodeToString(hpsbt.Marshal())
@@ -143,10 +150,7 @@ func (node *Node) keeperVerifyEthereumTransactionSignatures(ctx context.Context,
 	if err != nil {
 		return err
 	}
-	switch safe.Chain {
-	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
-	default:
+	if !ethereum.IsEVMChain(safe.Chain) {
 		panic(st.TxHash)
 	}
 
@@ -182,6 +186,9 @@ func (node *Node) bitcoinTransactionSpendLoop(ctx context.Context, chain byte) {
 			panic(err)
 		}
 		for _, tx := range txs {
+			if bitcoinTransactionAwaitingHolder(tx) {
+				continue
+			}
 			msgTx, err := node.bitcoinSpendFullySignedTransaction(ctx, tx)
 			logger.Verbosef("node.bitcoinSpendFullySignedTransaction(%v) => %v %v", tx, msgTx, err)
 			if err != nil {
@@ -209,29 +216,47 @@ func (node *Node) bitcoinTransactionSpendLoop(ctx context.Context, chain byte) {
 	}
 }
 
-func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Transaction) (*wire.MsgTx, error) {
-	rpc, _ := node.bitcoinParams(tx.Chain)
+// the taproot key spend transaction signed by the keeper waits for the
+// holder to combine the key path signatures
+func bitcoinTransactionAwaitingHolder(tx *Transaction) bool {
+	b := common.DecodeHexOrPanic(tx.RawTransaction)
+	psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
+	for idx := range psbt.Inputs {
+		if psbt.IsTaprootKeySpendInput(idx) && len(psbt.Inputs[idx].TaprootKeySpendSig) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (node *Node) bitcoinSignFullySignedTransaction(ctx context.Context, tx *Transaction) (*bitcoin.PartiallySignedTransaction, []byte, error) {
 	b := common.DecodeHexOrPanic(tx.RawTransaction)
 	psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
 
 	safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	spk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Signer, safe.Path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	opk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	msgTx, err := psbt.SignedTransaction(tx.Holder, spk, opk)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	signedBuffer, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, tx.Chain)
+	return psbt, signedBuffer, err
+}
+
+func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Transaction) (*wire.MsgTx, error) {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+	psbt, signedBuffer, err := node.bitcoinSignFullySignedTransaction(ctx, tx)
 	if err != nil {
 		return nil, err
 	}
@@ -254,7 +279,7 @@ func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Tr
 		return nil, fmt.Errorf("insufficient accountant balance %d %d", fee, fvb)
 	}
 
-	accountant, err := node.store.ReadAccountantPrivateKey(ctx, feeInput.Address)
+	accountant, sign, err := node.bitcoinAccountantSigner(ctx, feeInput.Address, tx.Chain)
 	if err != nil {
 		return nil, err
 	}
@@ -263,7 +288,7 @@ func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Tr
 		Index:           feeInput.Index,
 		Satoshi:         feeInput.Satoshi,
 	}}
-	msgTx, err = bitcoin.SpendSignedTransaction(hex.EncodeToString(signedBuffer), feeInputs, accountant, tx.Chain)
+	msgTx, err := bitcoin.SpendSignedTransactionWithSigner(hex.EncodeToString(signedBuffer), feeInputs, accountant, sign, tx.Chain)
 	if err != nil {
 		return nil, err
 	}
@@ -277,7 +302,12 @@ func (node *Node) bitcoinRetrieveFeeInputsForTransaction(ctx context.Context, fe
 	if err != nil || old != nil {
 		return old, err
 	}
+	return node.bitcoinBuildFeeOutput(ctx, fee, fvb, tx)
+}
 
+// bitcoinBuildFeeOutput sends fee satoshi from the accountant UTXOs to a new
+// output, which is reserved for the transaction.
+func (node *Node) bitcoinBuildFeeOutput(ctx context.Context, fee, fvb uint64, tx *Transaction) (*Output, error) {
 	utxos, err := node.store.ReadBitcoinUTXOs(ctx, tx.Chain)
 	if err != nil || len(utxos) == 0 {
 		return nil, err
@@ -323,34 +353,9 @@ func (node *Node) bitcoinRetrieveFeeInputsForTransaction(ctx context.Context, fe
 		return nil, nil
 	}
 
-	for idx := range msgTx.TxIn {
-		in := utxos[idx]
-		accountant, err := node.store.ReadAccountantPrivateKey(ctx, in.Address)
-		if err != nil {
-			return nil, err
-		}
-		b := common.DecodeHexOrPanic(accountant)
-		privateKey, publicKey := btcec.PrivKeyFromBytes(b)
-
-		script := publicKey.SerializeCompressed()
-		script = btcutil.Hash160(script)
-		builder := txscript.NewScriptBuilder()
-		builder.AddOp(txscript.OP_0)
-		builder.AddData(script)
-		script, err = builder.Script()
-		if err != nil {
-			return nil, err
-		}
-		pof := txscript.NewCannedPrevOutputFetcher(script, in.Satoshi)
-		tsh := txscript.NewTxSigHashes(msgTx, pof)
-		hash, err := txscript.CalcWitnessSigHash(script, tsh, txscript.SigHashAll, msgTx, idx, in.Satoshi)
-		if err != nil {
-			return nil, err
-		}
-		signature := ecdsa.Sign(privateKey, hash)
-		sig := append(signature.Serialize(), byte(txscript.SigHashAll))
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, sig)
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, publicKey.SerializeCompressed())
+	err = node.bitcoinSignAccountantInputs(ctx, msgTx, utxos[:len(msgTx.TxIn)], tx.Chain)
+	if err != nil {
+		return nil, err
 	}
 
 	raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, tx.Chain)
@@ -367,6 +372,63 @@ func (node *Node) bitcoinRetrieveFeeInputsForTransaction(ctx context.Context, fe
 	}, node.store.WriteBitcoinFeeOutput(ctx, msgTx, receiver, tx)
 }
 
+// bitcoinSignAccountantInputs signs the accountant inputs of msgTx, which
+// are P2WPKH outputs, or P2PKH outputs with forkid signatures for Bitcoin Cash.
+func (node *Node) bitcoinSignAccountantInputs(ctx context.Context, msgTx *wire.MsgTx, inputs []*Output, chain byte) error {
+	hashType := txscript.SigHashAll
+	if chain == common.SafeChainBitcoinCash {
+		hashType = hashType | bitcoin.SigHashForkID
+	}
+	for idx, in := range inputs {
+		accountant, sign, err := node.bitcoinAccountantSigner(ctx, in.Address, chain)
+		if err != nil {
+			return err
+		}
+		publicKey := common.DecodeHexOrPanic(accountant)
+
+		script := btcutil.Hash160(publicKey)
+		builder := txscript.NewScriptBuilder()
+		if chain == common.SafeChainBitcoinCash {
+			builder.AddOp(txscript.OP_DUP)
+			builder.AddOp(txscript.OP_HASH160)
+			builder.AddData(script)
+			builder.AddOp(txscript.OP_EQUALVERIFY)
+			builder.AddOp(txscript.OP_CHECKSIG)
+		} else {
+			builder.AddOp(txscript.OP_0)
+			builder.AddData(script)
+		}
+		script, err = builder.Script()
+		if err != nil {
+			return err
+		}
+		pof := txscript.NewCannedPrevOutputFetcher(script, in.Satoshi)
+		tsh := txscript.NewTxSigHashes(msgTx, pof)
+		hash, err := txscript.CalcWitnessSigHash(script, tsh, hashType, msgTx, idx, in.Satoshi)
+		if err != nil {
+			return err
+		}
+		signature, err := sign(hash)
+		if err != nil {
+			return err
+		}
+		sig := append(signature, byte(hashType))
+		if chain == common.SafeChainBitcoinCash {
+			builder := txscript.NewScriptBuilder()
+			builder.AddData(sig)
+			builder.AddData(publicKey)
+			msgTx.TxIn[idx].SignatureScript, err = builder.Script()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, sig)
+		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, publicKey)
+	}
+	return nil
+}
+
 func (node *Node) ethereumTransactionSpendLoop(ctx context.Context, chain byte) {
 	rpc, assetId := node.ethereumParams(chain)
 	asset, err := node.fetchAssetMeta(ctx, assetId)
@@ -521,7 +583,48 @@ func (s *SQLite3Store) WriteBitcoinFeeOutput(ctx context.Context, msgTx *wire.Ms
 	}
 	defer txn.Rollback()
 
-	signedBuffer, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, tx.Chain)
+	err = s.writeBitcoinAccountantTransaction(ctx, txn, msgTx, receiver, tx.Chain, tx.TransactionHash)
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+func (s *SQLite3Store) WriteBitcoinFeeBumpChild(ctx context.Context, msgTx *wire.MsgTx, receiver string, bump *FeeBump, chain byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if bump.Method != FeeBumpMethodCPFP || bump.BumpHash != msgTx.TxHash().String() {
+		panic(bump.BumpHash)
+	}
+	err = s.writeBitcoinAccountantTransaction(ctx, txn, msgTx, receiver, chain, "")
+	if err != nil {
+		return err
+	}
+
+	err = s.execOne(ctx, txn, "UPDATE transactions SET updated_at=? WHERE transaction_hash=? AND state=?",
+		bump.CreatedAt, bump.TransactionHash, common.RequestStateDone)
+	if err != nil {
+		return fmt.Errorf("UPDATE transactions %v", err)
+	}
+	err = s.execOne(ctx, txn, buildInsertionSQL("fee_bumps", feeBumpCols), bump.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT fee_bumps %v", err)
+	}
+	return txn.Commit()
+}
+
+// writeBitcoinAccountantTransaction spends the accountant inputs of msgTx and
+// writes all its outputs, the first output is reserved for the reserved
+// transaction if not empty.
+func (s *SQLite3Store) writeBitcoinAccountantTransaction(ctx context.Context, txn *sql.Tx, msgTx *wire.MsgTx, receiver string, chain byte, reserved string) error {
+	signedBuffer, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, chain)
 	if err != nil {
 		return err
 	}
@@ -541,21 +644,59 @@ func (s *SQLite3Store) WriteBitcoinFeeOutput(ctx context.Context, msgTx *wire.Ms
 			Index:           uint32(i),
 			Address:         receiver,
 			Satoshi:         out.Value,
-			Chain:           tx.Chain,
+			Chain:           chain,
 			State:           common.RequestStateInitial,
 			RawTransaction:  sql.NullString{Valid: true, String: raw},
 			CreatedAt:       time.Now().UTC(),
 			UpdatedAt:       time.Now().UTC(),
 		}
-		if i == 0 {
+		if i == 0 && reserved != "" {
 			utxo.State = common.RequestStateDone
-			utxo.SpentBy = sql.NullString{Valid: true, String: tx.TransactionHash}
+			utxo.SpentBy = sql.NullString{Valid: true, String: reserved}
 		}
 		err = s.execOne(ctx, txn, buildInsertionSQL("bitcoin_outputs", outputCols), utxo.values()...)
 		if err != nil {
 			return fmt.Errorf("INSERT bitcoin_outputs %v", err)
 		}
 	}
+	return nil
+}
+
+func (s *SQLite3Store) ListBitcoinUTXOsSpentBy(ctx context.Context, hash string) ([]*Output, error) {
+	query := fmt.Sprintf("SELECT %s FROM bitcoin_outputs WHERE spent_by=? ORDER BY created_at ASC", strings.Join(outputCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outputs []*Output
+	for rows.Next() {
+		var o Output
+		err := rows.Scan(&o.TransactionHash, &o.Index, &o.Address, &o.Satoshi, &o.Chain, &o.State, &o.SpentBy, &o.RawTransaction, &o.CreatedAt, &o.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &o)
+	}
+	return outputs, nil
+}
+
+func (s *SQLite3Store) ReleaseBitcoinUTXO(ctx context.Context, o *Output) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	err = s.execOne(ctx, txn, "UPDATE bitcoin_outputs SET state=?,spent_by=NULL,updated_at=? WHERE transaction_hash=? AND output_index=? AND state=? AND spent_by=?",
+		common.RequestStateInitial, time.Now().UTC(), o.TransactionHash, o.Index, common.RequestStateDone, o.SpentBy)
+	if err != nil {
+		return fmt.Errorf("UPDATE bitcoin_outputs %v", err)
+	}
 	return txn.Commit()
 }
 
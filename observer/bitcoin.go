@@ -23,8 +23,9 @@ import (
 )
 
 const (
-	bitcoinKeygenRequestTimeKey  = "bitcoin-keygen-request-time"
-	bitcoinKeyDummyHolderPrivate = "75d5f311c8647e3a1d84a0d975b6e50b8c6d3d7f195365320077f41c6a165155"
+	bitcoinKeygenRequestTimeKey        = "bitcoin-keygen-request-time"
+	bitcoinTaprootKeygenRequestTimeKey = "bitcoin-taproot-keygen-request-time"
+	bitcoinKeyDummyHolderPrivate       = "75d5f311c8647e3a1d84a0d975b6e50b8c6d3d7f195365320077f41c6a165155"
 )
 
 func (node *Node) bitcoinParams(chain byte) (string, string) {
@@ -33,6 +34,8 @@ func (node *Node) bitcoinParams(chain byte) (string, string) {
 		return node.conf.BitcoinRPC, common.SafeBitcoinChainId
 	case common.SafeChainLitecoin:
 		return node.conf.LitecoinRPC, common.SafeLitecoinChainId
+	case common.SafeChainBitcoinCash:
+		return node.conf.BitcoinCashRPC, common.SafeBitcoinCashChainId
 	default:
 		panic(chain)
 	}
@@ -127,10 +130,10 @@ func (node *Node) bitcoinWriteFeeOutput(ctx context.Context, receiver string, tx
 		return nil
 	}
 
-	accountant, err := node.store.ReadAccountantPrivateKey(ctx, receiver)
-	logger.Printf("store.ReadAccountantPrivateKey(%s) => %v", receiver, err)
+	accountant, err := node.store.ReadAccountantPublicKey(ctx, receiver)
+	logger.Printf("store.ReadAccountantPublicKey(%s) => %s %v", receiver, accountant, err)
 	if err != nil {
-		return fmt.Errorf("store.ReadAccountantPrivateKey(%s) => %v", receiver, err)
+		return fmt.Errorf("store.ReadAccountantPublicKey(%s) => %v", receiver, err)
 	} else if accountant == "" {
 		return nil
 	}
@@ -164,7 +167,7 @@ func (node *Node) bitcoinCheckDepositChange(ctx context.Context, transactionHash
 	}
 	var recipients []map[string]string
 	err = json.Unmarshal([]byte(tx.Data), &recipients)
-	if err != nil || len(recipients) == 0 {
+	if err != nil {
 		panic(fmt.Errorf("store.ReadTransaction(%s) => %s", transactionHash, tx.Data))
 	}
 	return outputIndex >= int64(len(recipients))
@@ -312,7 +315,7 @@ func (node *Node) bitcoinRPCBlocksLoop(ctx context.Context, chain byte) {
 	switch chain {
 	case common.SafeChainLitecoin:
 		duration = 1 * time.Minute
-	case common.SafeChainBitcoin:
+	case common.SafeChainBitcoin, common.SafeChainBitcoinCash:
 	}
 
 	for {
@@ -360,7 +363,15 @@ func (node *Node) bitcoinProcessTransaction(ctx context.Context, tx *bitcoin.RPC
 	for index := range tx.Vout {
 		out := tx.Vout[index]
 		skt := out.ScriptPubKey.Type
-		if skt != bitcoin.ScriptPubKeyTypeWitnessScriptHash && skt != bitcoin.ScriptPubKeyTypeWitnessKeyHash {
+		switch skt {
+		case bitcoin.ScriptPubKeyTypeWitnessScriptHash:
+		case bitcoin.ScriptPubKeyTypeWitnessKeyHash:
+		case bitcoin.ScriptPubKeyTypeWitnessTaproot:
+		case bitcoin.ScriptPubKeyTypeScriptHash, bitcoin.ScriptPubKeyTypePubKeyHash:
+			if chain != common.SafeChainBitcoinCash {
+				continue
+			}
+		default:
 			continue
 		}
 		if out.N != int64(index) {
@@ -429,6 +440,17 @@ func (node *Node) sendToKeeperBitcoinApproveNormalTransaction(ctx context.Contex
 
 	rawId := common.UniqueId(approval.RawTransaction, approval.RawTransaction)
 	raw := common.DecodeHexOrPanic(approval.RawTransaction)
+	batchId, err := node.keeperStore.ReadTransactionBatchId(ctx, approval.TransactionHash)
+	if err != nil {
+		return err
+	}
+	if batchId != "" {
+		sig, err := node.store.ReadBatchApproval(ctx, batchId)
+		if err != nil || sig == "" {
+			return err
+		}
+		raw = common.EncodeBatchItems([][]byte{raw, common.DecodeHexOrPanic(sig)})
+	}
 	raw = append(uuid.Must(uuid.FromString(rawId)).Bytes(), raw...)
 	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
 	msg := base64.RawURLEncoding.EncodeToString(raw)
@@ -711,7 +733,8 @@ func (node *Node) httpSignBitcoinAccountRecoveryRequest(ctx context.Context, saf
 	if err != nil {
 		return err
 	}
-	if !bitcoin.CheckTransactionPartiallySignedBy(raw, opk) {
+	isObserverSigned := bitcoin.CheckTransactionPartiallySignedBy(raw, opk)
+	if !isObserverSigned && !node.conf.RecoveryAutoSign {
 		return fmt.Errorf("bitcoin.CheckTransactionPartiallySignedBy(%s, %s) observer", raw, opk)
 	}
 	rb := common.DecodeHexOrPanic(raw)
@@ -720,7 +743,6 @@ func (node *Node) httpSignBitcoinAccountRecoveryRequest(ctx context.Context, saf
 		return err
 	}
 	msgTx := psTx.UnsignedTx
-	signedRaw := psTx.Marshal()
 	txHash := psTx.Hash()
 	if txHash != hash {
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
@@ -783,6 +805,15 @@ func (node *Node) httpSignBitcoinAccountRecoveryRequest(ctx context.Context, saf
 		}
 	}
 
+	if !isObserverSigned {
+		err = node.bitcoinSignObserverInputs(ctx, safe, opk, psTx)
+		logger.Printf("node.bitcoinSignObserverInputs(%s, %s) => %v", safe.Address, opk, err)
+		if err != nil {
+			return err
+		}
+		raw = hex.EncodeToString(psTx.Marshal())
+	}
+	signedRaw := psTx.Marshal()
 	err = node.store.AddTransactionPartials(ctx, hash, hex.EncodeToString(signedRaw))
 	logger.Printf("store.AddTransactionPartials(%s) => %v", hash, err)
 	if err != nil {
@@ -820,12 +851,126 @@ func (node *Node) httpApproveBitcoinTransaction(ctx context.Context, raw string)
 		return err
 	}
 
+	batchId, err := node.keeperStore.ReadTransactionBatchId(ctx, txHash)
+	if err != nil {
+		return err
+	}
+	if batchId != "" {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
 	raw = hex.EncodeToString(psbt.Marshal())
 	err = node.store.AddTransactionPartials(ctx, txHash, raw)
 	logger.Printf("store.AddTransactionPartials(%s) => %v", txHash, err)
 	return err
 }
 
+// httpSignBitcoinKeySpendTransaction saves the key path signatures of the
+// taproot safe transaction, the holder combines them with the signer partial
+// signatures after the keeper signed the transaction
+func (node *Node) httpSignBitcoinKeySpendTransaction(ctx context.Context, approval *Transaction, raw string) error {
+	logger.Printf("node.httpSignBitcoinKeySpendTransaction(%s, %s)", approval.TransactionHash, raw)
+	if common.SafeChainFamily(approval.Chain) != common.SafeChainBitcoin {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	if approval.State != common.RequestStateDone || approval.SpentHash.Valid {
+		return nil
+	}
+	rb, _ := hex.DecodeString(raw)
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+	if err != nil {
+		return err
+	}
+	if psbt.Hash() != approval.TransactionHash {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	b := common.DecodeHexOrPanic(approval.RawTransaction)
+	spsbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
+	var keySpend bool
+	for idx := range spsbt.Inputs {
+		if !spsbt.IsTaprootKeySpendInput(idx) {
+			continue
+		}
+		spsbt.Inputs[idx].TaprootKeySpendSig = psbt.Inputs[idx].TaprootKeySpendSig
+		keySpend = true
+	}
+	if !keySpend {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	approval.RawTransaction = hex.EncodeToString(spsbt.Marshal())
+	_, _, err = node.bitcoinSignFullySignedTransaction(ctx, approval)
+	logger.Printf("node.bitcoinSignFullySignedTransaction(%s) => %v", approval.TransactionHash, err)
+	if err != nil {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	err = node.store.FinishTransactionKeySpendSignatures(ctx, approval.TransactionHash, approval.RawTransaction)
+	logger.Printf("store.FinishTransactionKeySpendSignatures(%s) => %v", approval.TransactionHash, err)
+	return err
+}
+
+// The holder approves all transactions of a batch with one signature over
+// the batch digest, together with the holder signed transactions, which are
+// still required by the safe script. Either all of them are approved or none.
+func (node *Node) httpApproveBitcoinBatch(ctx context.Context, id string, raws []string, sigBase64 string) error {
+	logger.Printf("node.httpApproveBitcoinBatch(%s, %d, %s)", id, len(raws), sigBase64)
+	txs, err := node.keeperStore.ListTransactionsByBatch(ctx, id)
+	logger.Verbosef("keeperStore.ListTransactionsByBatch(%s) => %d %v", id, len(txs), err)
+	if err != nil || len(txs) == 0 {
+		return err
+	}
+	if len(raws) != len(txs) {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	hashes := make([]string, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.TransactionHash
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigBase64)
+	if err != nil {
+		return err
+	}
+	msg := bitcoin.HashBatchForSignature(id, hashes, txs[0].Chain)
+	err = bitcoin.VerifySignatureDER(txs[0].Holder, msg, sig)
+	logger.Printf("bitcoin.VerifySignatureDER(%s, %x) => %v", id, msg, err)
+	if err != nil {
+		return err
+	}
+
+	partials := make([]string, len(txs))
+	for i, tx := range txs {
+		rb, _ := hex.DecodeString(raws[i])
+		psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+		if err != nil {
+			return err
+		}
+		if psbt.Hash() != tx.TransactionHash {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		if !bitcoin.CheckTransactionPartiallySignedBy(raws[i], tx.Holder) {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		approval, err := node.store.ReadTransactionApproval(ctx, tx.TransactionHash)
+		logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", tx.TransactionHash, approval, err)
+		if err != nil {
+			return err
+		}
+		if approval == nil || approval.State != common.RequestStateInitial {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		if bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		partials[i] = hex.EncodeToString(psbt.Marshal())
+	}
+
+	err = node.store.AddBatchTransactionPartials(ctx, id, hashes, partials, hex.EncodeToString(sig))
+	logger.Printf("store.AddBatchTransactionPartials(%s, %d) => %v", id, len(hashes), err)
+	return err
+}
+
 func (node *Node) httpRevokeBitcoinTransaction(ctx context.Context, txHash string, sigBase64 string) error {
 	logger.Printf("node.httpRevokeBitcoinTransaction(%s, %s)", txHash, sigBase64)
 	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
@@ -13,6 +13,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 )
@@ -48,6 +49,27 @@ func (node *Node) deployBitcoinSafeBond(ctx context.Context, data []byte) error
 	return err
 }
 
+func (node *Node) deployMixinSafeBond(ctx context.Context, data []byte) error {
+	logger.Printf("node.deployMixinSafeBond(%x)", data)
+	ka, err := mixin.UnmarshalKernelAccount(data)
+	if err != nil {
+		return fmt.Errorf("mixin.UnmarshalKernelAccount(%x) => %v", data, err)
+	}
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, ka.Address)
+	if err != nil || safe == nil || safe.State != common.RequestStateDone {
+		return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v %v", ka.Address, safe, err)
+	}
+	assetId := common.SafeChainAssetId(safe.Chain)
+	_, err = node.checkOrDeployKeeperBond(ctx, safe.Chain, assetId, "", safe.Holder, safe.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, safe.Holder, err)
+	}
+	err = node.store.MarkAccountDeployed(ctx, safe.Address)
+	logger.Printf("store.MarkAccountDeployed(%s) => %v", safe.Address, err)
+	return err
+}
+
 func (node *Node) fetchBondAssetReceiver(ctx context.Context, address, assetId string) string {
 	migrated, err := node.keeperStore.CheckMigrateAsset(ctx, address, assetId)
 	if err != nil {
@@ -72,7 +94,7 @@ func (node *Node) checkOrDeployKeeperBond(ctx context.Context, chain byte, asset
 	}
 	entry := node.fetchBondAssetReceiver(ctx, address, assetId)
 	logger.Printf("node.fetchBondAssetReceiver(%s, %s) => %s", address, assetId, entry)
-	rpc, key := node.conf.PolygonRPC, node.conf.EVMKey
+	rpc, key := node.conf.evmRPC(ethereum.BondChain().Chain), node.conf.EVMKey
 	return false, abi.GetOrDeployFactoryAsset(ctx, rpc, key, assetId, asset.Symbol, asset.Name, entry, holder)
 }
 
@@ -91,7 +113,7 @@ func (node *Node) fetchBondAsset(ctx context.Context, chain byte, assetId, asset
 		return nil, nil, "", fmt.Errorf("mvm.VerifyAssetKey(%s) => %v", assetKey, err)
 	}
 
-	bondId := ethereum.GenerateAssetId(common.SafeChainPolygon, assetKey)
+	bondId := ethereum.GenerateAssetId(ethereum.BondChain().Chain, assetKey)
 	logger.Printf("GenerateAssetId() => %s", bondId)
 	bond, err := node.fetchAssetMeta(ctx, bondId)
 	return asset, bond, bondId, err
@@ -102,10 +124,7 @@ func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id,
 	if err != nil || meta != nil {
 		return meta, err
 	}
-	switch chain {
-	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
-	default:
+	if !ethereum.IsEVMChain(chain) {
 		panic(chain)
 	}
 	rpc, _ := node.ethereumParams(chain)
@@ -0,0 +1,303 @@
+package observer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	bitcoinFeeBumpDelay  = 30 * time.Minute
+	bitcoinFeeBumpWindow = 7 * 24 * time.Hour
+
+	bitcoinFeeInputVirtualSize  = 68
+	bitcoinChildTxVirtualSize   = 110
+	bitcoinIncrementalRelayRate = 1
+)
+
+// bitcoinTransactionBumpLoop watches the broadcasted safe transactions, and
+// bumps the fee of those stuck in the mempool. The safe inputs are signed
+// with SigHashAnyOneCanPay, so the spent transaction could be replaced with
+// an additional accountant fee input, and the fee input parent could be
+// accelerated by a child spending its accountant change output. Only the
+// chains with mempool replacement relayed by default are replaced, the others
+// could only be accelerated by the child.
+func (node *Node) bitcoinTransactionBumpLoop(ctx context.Context, chain byte) {
+	for {
+		time.Sleep(time.Minute)
+		offset := time.Now().Add(-bitcoinFeeBumpWindow)
+		txs, err := node.store.ListSpentTransactionApprovals(ctx, chain, offset)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			if tx.UpdatedAt.Add(bitcoinFeeBumpDelay).After(time.Now()) {
+				continue
+			}
+			err := node.bitcoinBumpTransactionFee(ctx, tx)
+			logger.Verbosef("node.bitcoinBumpTransactionFee(%v) => %v", tx, err)
+		}
+	}
+}
+
+func (node *Node) bitcoinBumpTransactionFee(ctx context.Context, tx *Transaction) error {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+	entry, err := bitcoin.RPCGetMempoolEntry(tx.Chain, rpc, tx.SpentHash.String)
+	logger.Verbosef("bitcoin.RPCGetMempoolEntry(%s) => %v %v", tx.SpentHash.String, entry, err)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return node.bitcoinReleaseFeeBumpInputs(ctx, tx)
+	}
+
+	fvb, err := bitcoin.EstimateAvgFee(tx.Chain, rpc)
+	if err != nil {
+		return err
+	}
+	if bitcoinFeeRate(entry.Fees.Ancestor, entry.AncestorSize) >= fvb {
+		return nil
+	}
+	if bitcoinFeeRate(entry.Fees.Base, entry.VSize) >= fvb {
+		return node.bitcoinBumpParentFee(ctx, tx, fvb)
+	}
+	if !bitcoin.CheckReplaceByFee(tx.Chain) {
+		logger.Printf("node.bitcoinBumpTransactionFee(%s) => no rbf on chain %d", tx.TransactionHash, tx.Chain)
+		return nil
+	}
+	return node.bitcoinReplaceTransactionFee(ctx, tx, entry, fvb)
+}
+
+// bitcoinReplaceTransactionFee replaces the spent transaction with one more
+// accountant fee input. The replacement must not add unconfirmed inputs, so
+// the additional fee output is built and reserved first, and only used after
+// it is confirmed.
+func (node *Node) bitcoinReplaceTransactionFee(ctx context.Context, tx *Transaction, entry *bitcoin.MemPoolTransaction, fvb int64) error {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+	feeInputs, extra, err := node.bitcoinReadFeeInputs(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if len(extra) == 0 {
+		oldFee := bitcoinSatoshi(entry.Fees.Base)
+		size := entry.VSize + bitcoinFeeInputVirtualSize
+		fee := max(fvb*size, oldFee+bitcoinIncrementalRelayRate*size) - oldFee
+		fee = max(fee, bitcoin.ValueDust(tx.Chain))
+		out, err := node.bitcoinBuildFeeOutput(ctx, uint64(fee), uint64(fvb), tx)
+		logger.Printf("node.bitcoinBuildFeeOutput(%s, %d, %d) => %v %v", tx.TransactionHash, fee, fvb, out, err)
+		if err != nil || out == nil {
+			return fmt.Errorf("insufficient accountant balance %d %d %v", fee, fvb, err)
+		}
+		raw := common.DecodeHexOrPanic(out.RawTransaction.String)
+		return node.bitcoinBroadcastTransaction(out.TransactionHash, raw, tx.Chain)
+	}
+
+	var fee int64
+	for _, out := range extra {
+		etx, err := bitcoin.RPCGetTransaction(tx.Chain, rpc, out.TransactionHash)
+		logger.Verbosef("bitcoin.RPCGetTransaction(%s) => %v %v", out.TransactionHash, etx, err)
+		if err != nil || etx == nil {
+			raw := common.DecodeHexOrPanic(out.RawTransaction.String)
+			return node.bitcoinBroadcastTransaction(out.TransactionHash, raw, tx.Chain)
+		}
+		if etx.BlockHash == "" {
+			return nil
+		}
+		fee = fee + out.Satoshi
+	}
+
+	inputs := make([]*bitcoin.Input, len(feeInputs))
+	for i, out := range feeInputs {
+		if out.Address != feeInputs[0].Address {
+			return fmt.Errorf("multiple accountant addresses %s %s", out.Address, feeInputs[0].Address)
+		}
+		inputs[i] = &bitcoin.Input{
+			TransactionHash: out.TransactionHash,
+			Index:           out.Index,
+			Satoshi:         out.Satoshi,
+		}
+	}
+	accountant, sign, err := node.bitcoinAccountantSigner(ctx, feeInputs[0].Address, tx.Chain)
+	if err != nil {
+		return err
+	}
+	_, signedBuffer, err := node.bitcoinSignFullySignedTransaction(ctx, tx)
+	if err != nil {
+		return err
+	}
+	msgTx, err := bitcoin.SpendSignedTransactionWithSigner(hex.EncodeToString(signedBuffer), inputs, accountant, sign, tx.Chain)
+	if err != nil {
+		return err
+	}
+	raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, tx.Chain)
+	if err != nil {
+		return err
+	}
+	hash := msgTx.TxHash().String()
+	err = node.bitcoinBroadcastTransaction(hash, raw, tx.Chain)
+	logger.Printf("node.bitcoinBroadcastTransaction(%s, %s) => %v", tx.SpentHash.String, hash, err)
+	if err != nil {
+		return err
+	}
+
+	bump := &FeeBump{
+		BumpHash:        hash,
+		TransactionHash: tx.TransactionHash,
+		Method:          FeeBumpMethodRBF,
+		ParentHash:      tx.SpentHash.String,
+		FeeRate:         fvb,
+		Fee:             fee,
+		CreatedAt:       time.Now().UTC(),
+	}
+	err = node.store.ReplaceSpentTransactionApproval(ctx, bump, hex.EncodeToString(raw))
+	if err != nil {
+		panic(err)
+	}
+	// the replacement is already saved, and it will be processed again by
+	// the blocks loop after confirmed, so just retry in the next round
+	rtx, err := bitcoin.RPCGetTransaction(tx.Chain, rpc, hash)
+	if err != nil || rtx == nil {
+		return fmt.Errorf("bitcoin.RPCGetTransaction(%s) => %v %v", hash, rtx, err)
+	}
+	return node.bitcoinProcessTransaction(ctx, rtx, tx.Chain)
+}
+
+// bitcoinBumpParentFee spends the accountant change output of the unconfirmed
+// fee input parents, with enough fee to pay for the whole ancestor package.
+func (node *Node) bitcoinBumpParentFee(ctx context.Context, tx *Transaction, fvb int64) error {
+	rpc, _ := node.bitcoinParams(tx.Chain)
+	feeInputs, _, err := node.bitcoinReadFeeInputs(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, in := range feeInputs {
+		parent, err := bitcoin.RPCGetMempoolEntry(tx.Chain, rpc, in.TransactionHash)
+		if err != nil {
+			return err
+		}
+		if parent == nil || bitcoinFeeRate(parent.Fees.Ancestor, parent.AncestorSize) >= fvb {
+			continue
+		}
+		change, err := node.store.ReadBitcoinUTXO(ctx, in.TransactionHash, 1, tx.Chain)
+		if err != nil {
+			return err
+		}
+		if change == nil || change.State != common.RequestStateInitial {
+			continue
+		}
+
+		size := parent.AncestorSize + bitcoinChildTxVirtualSize
+		fee := max(fvb*size-bitcoinSatoshi(parent.Fees.Ancestor), bitcoinIncrementalRelayRate*bitcoinChildTxVirtualSize)
+		value := change.Satoshi - fee
+		if value <= bitcoin.ValueDust(tx.Chain) {
+			logger.Printf("node.bitcoinBumpParentFee(%s, %s) => insufficient change %d %d", tx.TransactionHash, in.TransactionHash, change.Satoshi, fee)
+			continue
+		}
+		script, err := bitcoin.ParseAddress(change.Address, tx.Chain)
+		if err != nil {
+			return err
+		}
+		hash, err := chainhash.NewHashFromStr(change.TransactionHash)
+		if err != nil {
+			return err
+		}
+		msgTx := wire.NewMsgTx(2)
+		msgTx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: *hash, Index: change.Index},
+			Sequence:         bitcoin.MaxTransactionSequence,
+		})
+		msgTx.AddTxOut(wire.NewTxOut(value, script))
+		err = node.bitcoinSignAccountantInputs(ctx, msgTx, []*Output{change}, tx.Chain)
+		if err != nil {
+			return err
+		}
+
+		raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, tx.Chain)
+		if err != nil {
+			return err
+		}
+		child := msgTx.TxHash().String()
+		err = node.bitcoinBroadcastTransaction(child, raw, tx.Chain)
+		logger.Printf("node.bitcoinBroadcastTransaction(%s, %s) => %v", in.TransactionHash, child, err)
+		if err != nil {
+			return err
+		}
+		bump := &FeeBump{
+			BumpHash:        child,
+			TransactionHash: tx.TransactionHash,
+			Method:          FeeBumpMethodCPFP,
+			ParentHash:      in.TransactionHash,
+			FeeRate:         fvb,
+			Fee:             fee,
+			CreatedAt:       time.Now().UTC(),
+		}
+		err = node.store.WriteBitcoinFeeBumpChild(ctx, msgTx, change.Address, bump, tx.Chain)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return nil
+}
+
+// bitcoinReleaseFeeBumpInputs releases the reserved fee outputs not used by
+// the spent transaction after it leaves the mempool.
+func (node *Node) bitcoinReleaseFeeBumpInputs(ctx context.Context, tx *Transaction) error {
+	_, extra, err := node.bitcoinReadFeeInputs(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, out := range extra {
+		err = node.store.ReleaseBitcoinUTXO(ctx, out)
+		logger.Printf("store.ReleaseBitcoinUTXO(%s, %v) => %v", tx.TransactionHash, out, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bitcoinReadFeeInputs returns all accountant outputs reserved for the
+// transaction, and those not spent by the current spent transaction yet.
+func (node *Node) bitcoinReadFeeInputs(ctx context.Context, tx *Transaction) ([]*Output, []*Output, error) {
+	outputs, err := node.store.ListBitcoinUTXOsSpentBy(ctx, tx.TransactionHash)
+	if err != nil || len(outputs) == 0 {
+		return nil, nil, fmt.Errorf("store.ListBitcoinUTXOsSpentBy(%s) => %d %v", tx.TransactionHash, len(outputs), err)
+	}
+	b := common.DecodeHexOrPanic(tx.SpentRaw.String)
+	stx, err := btcutil.NewTxFromBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	spent := make(map[string]bool)
+	for _, in := range stx.MsgTx().TxIn {
+		spent[in.PreviousOutPoint.String()] = true
+	}
+	var extra []*Output
+	for _, out := range outputs {
+		if !spent[fmt.Sprintf("%s:%d", out.TransactionHash, out.Index)] {
+			extra = append(extra, out)
+		}
+	}
+	return outputs, extra, nil
+}
+
+func bitcoinFeeRate(fee float64, vsize int64) int64 {
+	if vsize <= 0 {
+		return 0
+	}
+	return bitcoinSatoshi(fee) / vsize
+}
+
+func bitcoinSatoshi(value float64) int64 {
+	return decimal.NewFromFloat(value).Mul(decimal.New(1, bitcoin.ValuePrecision)).IntPart()
+}
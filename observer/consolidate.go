@@ -0,0 +1,109 @@
+package observer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/gofrs/uuid/v5"
+)
+
+const (
+	bitcoinConsolidateDelay     = time.Hour
+	bitcoinConsolidateFeeRate   = 5
+	bitcoinConsolidateThreshold = 256
+	bitcoinConsolidateExpire    = time.Hour
+)
+
+// bitcoinConsolidateLoop proposes consolidations for the fragmented safes
+// when the network fee rate is low, because the accountant pays the fee of
+// all the swept inputs. The holder still has to approve the proposals.
+func (node *Node) bitcoinConsolidateLoop(ctx context.Context, chain byte) {
+	for {
+		time.Sleep(bitcoinConsolidateDelay)
+		info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, chain, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		if info == nil || info.Fee > bitcoinConsolidateFeeRate {
+			continue
+		}
+		safes, err := node.keeperStore.ListSafesWithState(ctx, keeper.SafeStateApproved)
+		if err != nil {
+			panic(err)
+		}
+		for _, safe := range safes {
+			if safe.Chain != chain {
+				continue
+			}
+			err = node.bitcoinConsolidateSafe(ctx, safe, info, bitcoinConsolidateThreshold)
+			logger.Verbosef("node.bitcoinConsolidateSafe(%s, %d) => %v", safe.Address, info.Fee, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) bitcoinConsolidateSafe(ctx context.Context, safe *store.Safe, info *store.NetworkInfo, threshold int) error {
+	mainInputs, err := node.keeperStore.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
+	if err != nil || len(mainInputs) < threshold {
+		return err
+	}
+	count, err := node.keeperStore.CountUnfinishedTransactionsByHolder(ctx, safe.Holder)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	extra := uuid.Must(uuid.FromString(info.RequestId)).Bytes()
+	extra = binary.BigEndian.AppendUint16(extra, bitcoin.MaxUnspentUtxo)
+	id := common.UniqueId(safe.Address, fmt.Sprintf("CONSOLIDATE:%s", info.RequestId))
+	action := common.ActionBitcoinSafeConsolidate
+	err = node.sendKeeperResponse(ctx, safe.Holder, byte(action), safe.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", safe.Holder, action, id, extra, err)
+	return err
+}
+
+// The holder could request a consolidation regardless of the fee rate, with
+// a signature of the recent network info id to prevent replays.
+func (node *Node) httpConsolidateSafeAccount(ctx context.Context, addr, infoId, signature string) error {
+	logger.Printf("node.httpConsolidateSafeAccount(%s, %s, %s)", addr, infoId, signature)
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if safe == nil || safe.State != keeper.SafeStateApproved {
+		return fmt.Errorf("HTTP: %d", http.StatusNotFound)
+	}
+	if common.SafeChainFamily(safe.Chain) != common.SafeChainBitcoin {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	info, err := node.keeperStore.ReadNetworkInfo(ctx, infoId)
+	if err != nil {
+		return err
+	}
+	if info == nil || info.Chain != safe.Chain || info.CreatedAt.Add(bitcoinConsolidateExpire).Before(time.Now()) {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	ms := fmt.Sprintf("CONSOLIDATE:%s:%s", info.RequestId, safe.Address)
+	hash := bitcoin.HashMessageForSignature(ms, safe.Chain)
+	err = bitcoin.VerifySignatureDER(safe.Holder, hash, sig)
+	logger.Printf("bitcoin.VerifySignatureDER(%v) => %v", safe, err)
+	if err != nil {
+		return err
+	}
+	return node.bitcoinConsolidateSafe(ctx, safe, info, keeper.SafeConsolidateMinimum)
+}
@@ -81,14 +81,7 @@ func (node *Node) deployEthereumGnosisSafeAccount(ctx context.Context, data []by
 }
 
 func (node *Node) ethereumParams(chain byte) (string, string) {
-	switch chain {
-	case common.SafeChainEthereum:
-		return node.conf.EthereumRPC, common.SafeEthereumChainId
-	case common.SafeChainPolygon:
-		return node.conf.PolygonRPC, common.SafePolygonChainId
-	default:
-		panic(chain)
-	}
+	return node.conf.evmRPC(chain), common.SafeChainAssetId(chain)
 }
 
 func (node *Node) ethereumNetworkInfoLoop(ctx context.Context, chain byte) {
@@ -199,7 +192,7 @@ func (node *Node) ethereumWritePendingDeposit(ctx context.Context, transfer *eth
 	rpc, chainAssetId := node.ethereumParams(chain)
 	switch transfer.AssetId {
 	case chainAssetId:
-		amount = decimal.NewFromBigInt(transfer.Value, -ethereum.ValuePrecision)
+		amount = decimal.NewFromBigInt(transfer.Value, -ethereum.GetChainConfig(chain).Precision)
 	default:
 		asset, err := ethereum.FetchAsset(chain, rpc, transfer.TokenAddress)
 		if err != nil {
@@ -252,7 +245,7 @@ func (node *Node) ethereumConfirmPendingDeposit(ctx context.Context, deposit *De
 	} else if !bonded {
 		return nil
 	}
-	decimals := int32(ethereum.ValuePrecision)
+	decimals := ethereum.GetChainConfig(deposit.Chain).Precision
 	switch asset.AssetId {
 	case ethereumAssetId:
 	default:
@@ -362,12 +355,7 @@ func (node *Node) ethereumDepositConfirmLoop(ctx context.Context, chain byte) {
 
 func (node *Node) ethereumRPCBlocksLoop(ctx context.Context, chain byte) {
 	rpc, _ := node.ethereumParams(chain)
-	duration := 5 * time.Second
-	switch chain {
-	case ethereum.ChainPolygon:
-		duration = 2 * time.Second
-	case ethereum.ChainEthereum:
-	}
+	duration := ethereum.GetChainConfig(chain).BlockInterval
 
 	for {
 		checkpoint, err := node.readDepositCheckpoint(ctx, chain)
@@ -799,7 +787,8 @@ func (node *Node) httpSignEthereumAccountRecoveryRequest(ctx context.Context, sa
 	}
 
 	isHolderSigned := ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
-	if !ethereum.CheckTransactionPartiallySignedBy(raw, safe.Observer) {
+	isObserverSigned := ethereum.CheckTransactionPartiallySignedBy(raw, safe.Observer)
+	if !isObserverSigned && !node.conf.RecoveryAutoSign {
 		return fmt.Errorf("ethereum.CheckTransactionPartiallySignedBy(%s, %s) observer", raw, safe.Observer)
 	}
 
@@ -811,7 +800,6 @@ func (node *Node) httpSignEthereumAccountRecoveryRequest(ctx context.Context, sa
 	if st.Destination.Hex() == safe.Address {
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
-	signedRaw := st.Marshal()
 
 	rpc, _ := node.ethereumParams(safe.Chain)
 	info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, safe.Chain, time.Now())
@@ -877,6 +865,15 @@ func (node *Node) httpSignEthereumAccountRecoveryRequest(ctx context.Context, sa
 		}
 	}
 
+	if !isObserverSigned {
+		err = node.ethereumSignObserverTransaction(ctx, safe, st)
+		logger.Printf("node.ethereumSignObserverTransaction(%s) => %v", safe.Address, err)
+		if err != nil {
+			return err
+		}
+		raw = hex.EncodeToString(st.Marshal())
+	}
+	signedRaw := st.Marshal()
 	err = node.store.AddTransactionPartials(ctx, hash, hex.EncodeToString(signedRaw))
 	logger.Printf("store.AddTransactionPartials(%s) => %v", hash, err)
 	if err != nil {
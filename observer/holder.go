@@ -15,9 +15,11 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/mixin"
 	"github.com/MixinNetwork/safe/common"
 	gc "github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
 )
 
 func (node *Node) getSafeStatus(ctx context.Context, proposalId string) (string, error) {
@@ -42,14 +44,20 @@ func (node *Node) getSafeStatus(ctx context.Context, proposalId string) (string,
 func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, extra []byte, createdAt time.Time) error {
 	logger.Printf("node.keeperSaveAccountProposal(%d, %x, %s)", chain, extra, createdAt)
 	var address string
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
 		wsa, err := bitcoin.UnmarshalWitnessScriptAccount(extra)
 		if err != nil {
 			return err
 		}
 		address = wsa.Address
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainMixin:
+		ka, err := mixin.UnmarshalKernelAccount(extra)
+		if err != nil {
+			return err
+		}
+		address = ka.Address
+	case common.SafeChainEthereum:
 		gs, err := ethereum.UnmarshalGnosisSafe(extra)
 		if err != nil {
 			return err
@@ -67,10 +75,12 @@ func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, ext
 	}
 
 	var assetId string
-	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(sp.Chain) {
+	case common.SafeChainBitcoin:
 		_, assetId = node.bitcoinParams(sp.Chain)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainMixin:
+		assetId = common.SafeChainAssetId(sp.Chain)
+	case common.SafeChainEthereum:
 		_, assetId = node.ethereumParams(sp.Chain)
 	}
 	_, err = node.checkOrDeployKeeperBond(ctx, chain, assetId, "", sp.Holder, sp.Address)
@@ -84,11 +94,14 @@ func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, ext
 func (node *Node) keeperSaveTransactionProposal(ctx context.Context, chain byte, extra []byte, createdAt time.Time) error {
 	logger.Printf("node.keeperSaveTransactionProposal(%x, %s)", extra, createdAt)
 	var txHash string
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
 		psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(extra)
 		txHash = psbt.UnsignedTx.TxHash().String()
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainMixin:
+		psbt, _ := mixin.UnmarshalPartiallySignedTransaction(extra)
+		txHash = psbt.Hash()
+	case common.SafeChainEthereum:
 		t, _ := ethereum.UnmarshalSafeTransaction(extra)
 		txHash = t.TxHash
 	}
@@ -125,8 +138,8 @@ func (node *Node) httpApproveSafeAccount(ctx context.Context, addr, signature st
 	}
 
 	var sig []byte
-	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(sp.Chain) {
+	case common.SafeChainBitcoin:
 		sig, err = base64.RawURLEncoding.DecodeString(signature)
 		if err != nil {
 			return err
@@ -138,7 +151,18 @@ func (node *Node) httpApproveSafeAccount(ctx context.Context, addr, signature st
 		if err != nil {
 			return err
 		}
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainMixin:
+		sig, err = hex.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		ms := fmt.Sprintf("APPROVE:%s:%s", sp.RequestId, sp.Address)
+		err = mixin.VerifySignature(sp.Holder, []byte(ms), sig)
+		logger.Printf("mixin.VerifySignature(%v) => %v", sp, err)
+		if err != nil {
+			return err
+		}
+	case common.SafeChainEthereum:
 		sig, err = hex.DecodeString(signature)
 		if err != nil {
 			return err
@@ -194,10 +218,10 @@ func (node *Node) httpCreateSafeAccountRecoveryRequest(ctx context.Context, addr
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
 
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
 		return node.httpCreateBitcoinAccountRecoveryRequest(ctx, safe, raw, hash)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		return node.httpCreateEthereumAccountRecoveryRequest(ctx, safe, raw, hash)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
@@ -233,10 +257,10 @@ func (node *Node) httpSignAccountRecoveryRequest(ctx context.Context, addr, raw,
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
 
-	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
 		return node.httpSignBitcoinAccountRecoveryRequest(ctx, safe, raw, hash)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		return node.httpSignEthereumAccountRecoveryRequest(ctx, safe, raw, hash)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
@@ -244,10 +268,12 @@ func (node *Node) httpSignAccountRecoveryRequest(ctx context.Context, addr, raw,
 }
 
 func (node *Node) httpApproveSafeTransaction(ctx context.Context, chain byte, raw string) error {
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
 		return node.httpApproveBitcoinTransaction(ctx, raw)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainMixin:
+		return node.httpApproveMixinTransaction(ctx, raw)
+	case common.SafeChainEthereum:
 		return node.httpApproveEthereumTransaction(ctx, raw)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
@@ -255,10 +281,12 @@ func (node *Node) httpApproveSafeTransaction(ctx context.Context, chain byte, ra
 }
 
 func (node *Node) httpRevokeSafeTransaction(ctx context.Context, chain byte, hash, sig string) error {
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
 		return node.httpRevokeBitcoinTransaction(ctx, hash, sig)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainMixin:
+		return node.httpRevokeMixinTransaction(ctx, hash, sig)
+	case common.SafeChainEthereum:
 		return node.httpRevokeEthereumTransaction(ctx, hash, sig)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
@@ -281,15 +309,17 @@ func (node *Node) holderPayTransactionApproval(ctx context.Context, chain byte,
 		return err
 	}
 	var signedByHolder, signedByObserver bool
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
 		signedByHolder = bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
 		opk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 		if err != nil {
 			panic(err)
 		}
 		signedByObserver = bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, opk)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainMixin:
+		signedByHolder = mixin.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	case common.SafeChainEthereum:
 		signedByHolder = ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
 		signedByObserver = ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer)
 	}
@@ -309,24 +339,34 @@ func (deposit *Deposit) encodeKeeperExtra(decimals int32) []byte {
 	extra := []byte{deposit.Chain}
 	extra = append(extra, uuid.Must(uuid.FromString(deposit.AssetId)).Bytes()...)
 	extra = append(extra, hash[:]...)
-	switch deposit.Chain {
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	switch common.SafeChainFamily(deposit.Chain) {
+	case common.SafeChainEthereum:
 		extra = append(extra, gc.HexToAddress(deposit.AssetAddress).Bytes()...)
 	}
 	extra = binary.BigEndian.AppendUint64(extra, uint64(deposit.OutputIndex))
+	switch deposit.Chain {
+	case common.SafeChainMixin:
+		extra = append(extra, deposit.Mask...)
+	}
 	extra = append(extra, deposit.bigAmount(decimals).Bytes()...)
 	return extra
 }
 
 func (d *Deposit) bigAmount(decimals int32) *big.Int {
-	switch d.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(d.Chain) {
+	case common.SafeChainBitcoin:
 		if decimals != bitcoin.ValuePrecision {
 			panic(decimals)
 		}
 		satoshi := bitcoin.ParseSatoshi(d.Amount)
 		return new(big.Int).SetInt64(satoshi)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainMixin:
+		if decimals != mixin.ValuePrecision {
+			panic(decimals)
+		}
+		amt := decimal.RequireFromString(d.Amount)
+		return amt.Shift(mixin.ValuePrecision).BigInt()
+	case common.SafeChainEthereum:
 		return ethereum.ParseAmount(d.Amount, decimals)
 	}
 	panic(0)
@@ -11,7 +11,6 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -107,10 +106,16 @@ func (node *Node) StartHTTP(version, readme string) {
 	router.GET("/recoveries", node.httpListRecoveries)
 	router.GET("/recoveries/:id", node.httpGetRecovery)
 	router.POST("/recoveries/:id", node.httpSignRecovery)
+	router.GET("/accounts", node.httpListAccounts)
 	router.GET("/accounts/:id", node.httpGetAccount)
 	router.POST("/accounts/:id", node.httpApproveAccount)
+	router.GET("/transactions", node.httpListTransactions)
 	router.GET("/transactions/:id", node.httpGetTransaction)
 	router.POST("/transactions/:id", node.httpApproveTransaction)
+	router.GET("/batches/:id", node.httpGetBatch)
+	router.POST("/batches/:id", node.httpApproveBatch)
+	router.GET("/policies/:id", node.httpGetPolicy)
+	router.POST("/policies/:id", node.httpSetPolicy)
 	router.GET("/keys/:public", node.httpGetCustomKey)
 	handler := common.HandleCORS(router)
 	err := http.ListenAndServe(fmt.Sprintf(":%d", 7080), handler)
@@ -137,7 +142,7 @@ func (node *Node) httpIndex(w http.ResponseWriter, r *http.Request, params map[s
 		"observer": node.conf.App.AppId,
 		"bond": map[string]any{
 			"chain":    137,
-			"contract": node.conf.PolygonFactoryAddress,
+			"contract": ethereum.BondChain().FactoryAddress,
 		},
 		"keeper": map[string]any{
 			"members":   node.GetKeepers(),
@@ -163,7 +168,7 @@ func (node *Node) httpFavicon(w http.ResponseWriter, r *http.Request, params map
 
 func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	var cs []map[string]any
-	for _, c := range []byte{common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainPolygon, common.SafeChainEthereum} {
+	for _, c := range node.safeChains() {
 		info, err := node.keeperStore.ReadLatestNetworkInfo(r.Context(), c, time.Now())
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -191,8 +196,8 @@ func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params
 		chain["deposit"] = map[string]any{
 			"checkpoint": ckp,
 		}
-		switch c {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.SafeChainFamily(c) {
+		case common.SafeChainBitcoin:
 			c, s, err := node.readChainAccountantBalance(r.Context(), int(c))
 			if err != nil {
 				common.RenderError(w, r, err)
@@ -204,7 +209,7 @@ func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params
 			accountant := make(map[string]any)
 			accountant["outputs"] = outputs
 			chain["accountant"] = accountant
-		case common.SafeChainEthereum, common.SafeChainPolygon:
+		case common.SafeChainEthereum:
 			addr, err := ethereum.PrivToAddress(node.conf.EVMKey)
 			if err != nil {
 				common.RenderError(w, r, err)
@@ -258,10 +263,15 @@ func (node *Node) httpListNodes(w http.ResponseWriter, r *http.Request, typ stri
 }
 
 func (node *Node) httpListDeposits(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	holder := r.URL.Query().Get("holder")
-	chain, _ := strconv.ParseInt(r.URL.Query().Get("chain"), 10, 64)
-	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
-	deposits, err := node.store.ListDeposits(r.Context(), int(chain), holder, common.RequestStateDone, offset)
+	f, err := parseListFilter(r.URL.Query(), "chain", "holder", "asset")
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	if f.State == "" {
+		f.State = "done"
+	}
+	deposits, err := node.store.ListDepositsWithFilter(r.Context(), f)
 	if err != nil {
 		common.RenderError(w, r, err)
 		return
@@ -272,20 +282,112 @@ func (node *Node) httpListDeposits(w http.ResponseWriter, r *http.Request, param
 		return
 	}
 
+	if len(deposits) == f.Limit {
+		d := deposits[len(deposits)-1]
+		setNextCursor(w, &ListCursor{Time: d.CreatedAt, Key: fmt.Sprintf("%s:%d", d.TransactionHash, d.OutputIndex)})
+	}
 	common.RenderJSON(w, r, http.StatusOK, node.viewDeposits(r.Context(), deposits, sent))
 }
 
 func (node *Node) httpListRecoveries(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
-	rs, err := node.store.ListInitialRecoveries(r.Context(), offset)
+	f, err := parseListFilter(r.URL.Query(), "chain", "holder")
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	if f.State == "" {
+		f.State = "initial"
+	}
+	rs, err := node.store.ListRecoveriesWithFilter(r.Context(), f)
 	if err != nil {
 		common.RenderError(w, r, err)
 		return
 	}
 
+	if len(rs) == f.Limit {
+		last := rs[len(rs)-1]
+		setNextCursor(w, &ListCursor{Time: last.CreatedAt, Key: last.Address})
+	}
 	common.RenderJSON(w, r, http.StatusOK, node.viewRecoveries(r.Context(), rs))
 }
 
+func (node *Node) httpListTransactions(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	f, err := parseListFilter(r.URL.Query(), "chain", "holder")
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	txs, err := node.store.ListTransactionApprovalsWithFilter(r.Context(), f)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+
+	view := make([]map[string]any, 0)
+	for _, t := range txs {
+		tm := map[string]any{
+			"hash":       t.TransactionHash,
+			"chain":      t.Chain,
+			"holder":     t.Holder,
+			"state":      common.StateName(int(t.State)),
+			"created_at": t.CreatedAt,
+			"updated_at": t.UpdatedAt,
+		}
+		if t.SpentHash.Valid {
+			tm["spent_hash"] = t.SpentHash.String
+			tm["state"] = "spent"
+		}
+		view = append(view, tm)
+	}
+	if len(txs) == f.Limit {
+		last := txs[len(txs)-1]
+		setNextCursor(w, &ListCursor{Time: last.CreatedAt, Key: last.TransactionHash})
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+func (node *Node) httpListAccounts(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	f, err := parseListFilter(r.URL.Query())
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	accounts, err := node.store.ListAccountsWithFilter(r.Context(), f)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+
+	view := make([]map[string]any, 0)
+	for _, a := range accounts {
+		am := map[string]any{
+			"address":    a.Address,
+			"state":      "proposed",
+			"created_at": a.CreatedAt,
+		}
+		if a.ApprovedAt.Valid {
+			am["state"] = "approved"
+			am["approved_at"] = a.ApprovedAt.Time
+		}
+		if a.DeployedAt.Valid {
+			am["state"] = "deployed"
+			am["deployed_at"] = a.DeployedAt.Time
+		}
+		view = append(view, am)
+	}
+	if len(accounts) == f.Limit {
+		last := accounts[len(accounts)-1]
+		setNextCursor(w, &ListCursor{Time: last.CreatedAt, Key: last.Address})
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+// the list bodies stay plain arrays for the existing clients, and the cursor
+// of the next page is returned in the header only if there may be more
+func setNextCursor(w http.ResponseWriter, cursor *ListCursor) {
+	w.Header().Set("X-Next-Cursor", cursor.String())
+}
+
 func (node *Node) httpGetRecovery(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	safe, _, err := node.readSafeProposalOrRequest(r.Context(), params["id"])
 	if err != nil {
@@ -411,6 +513,12 @@ func (node *Node) httpApproveAccount(w http.ResponseWriter, r *http.Request, par
 			common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
 			return
 		}
+	case "consolidate":
+		err = node.httpConsolidateSafeAccount(r.Context(), body.Address, body.Hash, body.Signature)
+		if err != nil {
+			common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
+			return
+		}
 	default:
 		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": "action"})
 		return
@@ -516,6 +624,14 @@ func (node *Node) httpGetTransaction(w http.ResponseWriter, r *http.Request, par
 		data["raw"] = approval.SpentRaw.String
 		data["state"] = "spent"
 	}
+	if common.SafeChainFamily(tx.Chain) == common.SafeChainBitcoin {
+		bumps, err := node.store.ListFeeBumps(r.Context(), approval.TransactionHash)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
+		data["fee_bumps"] = viewFeeBumps(bumps)
+	}
 	common.RenderJSON(w, r, http.StatusOK, data)
 }
 
@@ -550,7 +666,9 @@ func (node *Node) httpApproveTransaction(w http.ResponseWriter, r *http.Request,
 		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "approval"})
 		return
 	}
-	if approval.State != common.RequestStateInitial {
+	switch {
+	case body.Action == "sign" && approval.State == common.RequestStateDone:
+	case approval.State != common.RequestStateInitial:
 		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "state"})
 		return
 	}
@@ -565,6 +683,12 @@ func (node *Node) httpApproveTransaction(w http.ResponseWriter, r *http.Request,
 	}
 
 	switch body.Action {
+	case "sign":
+		err = node.httpSignBitcoinKeySpendTransaction(r.Context(), approval, body.Raw)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
 	case "approve":
 		err = node.httpApproveSafeTransaction(r.Context(), byte(body.Chain), body.Raw)
 		if err != nil {
@@ -598,6 +722,124 @@ func (node *Node) httpApproveTransaction(w http.ResponseWriter, r *http.Request,
 	common.RenderJSON(w, r, http.StatusOK, data)
 }
 
+func (node *Node) httpGetBatch(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	txs, err := node.keeperStore.ListTransactionsByBatch(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if len(txs) == 0 {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "batch"})
+		return
+	}
+
+	view := make([]map[string]any, len(txs))
+	for i, tx := range txs {
+		approval, err := node.store.ReadTransactionApproval(r.Context(), tx.TransactionHash)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
+		view[i] = map[string]any{
+			"id":    tx.RequestId,
+			"hash":  tx.TransactionHash,
+			"raw":   tx.RawTransaction,
+			"state": common.StateName(tx.State),
+		}
+		if approval != nil {
+			view[i]["raw"] = approval.RawTransaction
+		}
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"id":           params["id"],
+		"chain":        txs[0].Chain,
+		"transactions": view,
+	})
+}
+
+func (node *Node) httpApproveBatch(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Chain     int      `json:"chain"`
+		Raws      []string `json:"raws"`
+		Signature string   `json:"signature"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	if common.SafeChainFamily(byte(body.Chain)) != common.SafeChainBitcoin {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "chain"})
+		return
+	}
+
+	err = node.httpApproveBitcoinBatch(r.Context(), params["id"], body.Raws, body.Signature)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	node.httpGetBatch(w, r, params)
+}
+
+func (node *Node) httpGetPolicy(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	safe, err := node.keeperStore.ReadSafeByAddress(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if safe == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "safe"})
+		return
+	}
+	current, err := node.keeperStore.ReadSafePolicy(r.Context(), safe.Holder, time.Now())
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	latest, err := node.keeperStore.ReadLatestSafePolicy(r.Context(), safe.Holder)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+
+	data := map[string]any{"address": safe.Address}
+	if current != nil {
+		data["current"] = map[string]any{
+			"id":           current.RequestId,
+			"policy":       current.Policy,
+			"effective_at": current.EffectiveAt,
+		}
+	}
+	if latest != nil && latest.EffectiveAt.After(time.Now()) {
+		data["pending"] = map[string]any{
+			"id":           latest.RequestId,
+			"policy":       latest.Policy,
+			"effective_at": latest.EffectiveAt,
+		}
+	}
+	common.RenderJSON(w, r, http.StatusOK, data)
+}
+
+func (node *Node) httpSetPolicy(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Id        string `json:"id"`
+		Policy    string `json:"policy"`
+		Signature string `json:"signature"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+
+	err = node.httpSetSafePolicy(r.Context(), params["id"], body.Id, body.Policy, body.Signature)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	common.RenderJSON(w, r, http.StatusAccepted, map[string]any{"id": body.Id})
+}
+
 func (node *Node) httpGetCustomKey(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	key, err := node.keeperStore.ReadKey(r.Context(), params["public"])
 	if err != nil {
@@ -626,6 +868,9 @@ func (node *Node) httpGetCustomKey(w http.ResponseWriter, r *http.Request, param
 }
 
 func (node *Node) viewSafeXPubs(ctx context.Context, safe *store.SafeProposal) []string {
+	if checkSafeProposalTaproot(safe) {
+		return []string{safe.Signer, safe.Observer}
+	}
 	pubs := make([]string, 2)
 	for i, k := range []string{safe.Signer, safe.Observer} {
 		key, _ := node.keeperStore.ReadKey(ctx, k)
@@ -649,9 +894,20 @@ func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("bitcoin.DeriveBIP32(%s) => %v", safe.Observer, err)
 	}
+	if checkSafeProposalTaproot(safe) {
+		return bitcoin.BuildTaprootAccount(safe.Holder, sdk, odk, safe.Timelock, safe.Chain)
+	}
 	return bitcoin.BuildWitnessScriptAccount(safe.Holder, sdk, odk, safe.Timelock, safe.Chain)
 }
 
+func checkSafeProposalTaproot(safe *store.SafeProposal) bool {
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(safe.Extra)
+	if err != nil {
+		panic(err)
+	}
+	return bitcoin.CheckTaprootScript(wsa.Script)
+}
+
 func (node *Node) readChainAccountantBalance(ctx context.Context, chain int) (uint64, uint64, error) {
 	query := "SELECT SUM(satoshi),COUNT(*) FROM bitcoin_outputs WHERE chain=? AND state=?"
 	row := node.store.db.QueryRowContext(ctx, query, chain, common.RequestStateInitial)
@@ -718,7 +974,7 @@ func (node *Node) viewDeposits(ctx context.Context, deposits []*Deposit, sent ma
 			dm["sent_hash"] = d.TransactionHash
 		} else {
 			switch d.Chain {
-			case common.SafeChainBitcoin, common.SafeChainLitecoin:
+			case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 				dm["change"] = node.bitcoinCheckDepositChange(ctx, d.TransactionHash, d.OutputIndex, sent[d.TransactionHash])
 			}
 		}
@@ -760,6 +1016,21 @@ func viewOutputs(outputs []*bitcoin.Input) []map[string]any {
 	return view
 }
 
+func viewFeeBumps(bumps []*FeeBump) []map[string]any {
+	view := make([]map[string]any, 0)
+	for _, b := range bumps {
+		view = append(view, map[string]any{
+			"hash":       b.BumpHash,
+			"method":     b.Method,
+			"parent":     b.ParentHash,
+			"fee_rate":   b.FeeRate,
+			"fee":        b.Fee,
+			"created_at": b.CreatedAt,
+		})
+	}
+	return view
+}
+
 type AssetBalance struct {
 	AssetAddress string `json:"asset_address"`
 	Amount       string `json:"amount"`
@@ -835,8 +1106,8 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 	if safe != nil {
 		safeAssetId = safe.SafeAssetId
 	}
-	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(sp.Chain) {
+	case common.SafeChainBitcoin:
 		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(r.Context(), sp)
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -867,7 +1138,16 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 			"safe_asset_id": safeAssetId,
 			"state":         status,
 		})
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainMixin:
+		common.RenderJSON(w, r, http.StatusOK, map[string]any{
+			"chain":         sp.Chain,
+			"id":            sp.RequestId,
+			"address":       sp.Address,
+			"keys":          []string{sp.Signer, sp.Observer},
+			"safe_asset_id": safeAssetId,
+			"state":         status,
+		})
+	case common.SafeChainEthereum:
 		balances, err := node.keeperStore.ReadAllEthereumTokenBalances(r.Context(), sp.Address)
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -3,33 +3,40 @@ package observer
 import (
 	"fmt"
 
+	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/shopspring/decimal"
 )
 
 type Configuration struct {
-	KeeperAppId                 string `toml:"keeper-app-id"`
-	StoreDir                    string `toml:"store-dir"`
-	PrivateKey                  string `toml:"private-key"`
-	Timestamp                   int64  `toml:"timestamp"`
-	KeeperStoreDir              string `toml:"keeper-store-dir"`
-	MonitorConversaionId        string `toml:"monitor-conversation-id"`
-	KeeperPublicKey             string `toml:"keeper-public-key"`
-	AssetId                     string `toml:"asset-id"`
-	CustomKeyPriceAssetId       string `toml:"custom-key-price-asset-id"`
-	CustomKeyPriceAmount        string `toml:"custom-key-price-amount"`
-	OperationPriceAssetId       string `toml:"operation-price-asset-id"`
-	OperationPriceAmount        string `toml:"operation-price-amount"`
-	TransactionMinimum          string `toml:"transaction-minimum"`
-	MixinMessengerAPI           string `toml:"mixin-messenger-api"`
-	MixinRPC                    string `toml:"mixin-rpc"`
-	BitcoinRPC                  string `toml:"bitcoin-rpc"`
-	LitecoinRPC                 string `toml:"litecoin-rpc"`
-	EthereumRPC                 string `toml:"ethereum-rpc"`
-	PolygonRPC                  string `toml:"polygon-rpc"`
-	PolygonFactoryAddress       string `toml:"polygon-factory-address"`
-	PolygonObserverDepositEntry string `toml:"polygon-observer-deposit-entry"`
-	PolygonKeeperDepositEntry   string `toml:"polygon-keeper-deposit-entry"`
-	EVMKey                      string `toml:"evm-key"`
+	KeeperAppId                 string                  `toml:"keeper-app-id"`
+	StoreDir                    string                  `toml:"store-dir"`
+	PrivateKey                  string                  `toml:"private-key"`
+	Timestamp                   int64                   `toml:"timestamp"`
+	KeeperStoreDir              string                  `toml:"keeper-store-dir"`
+	MonitorConversaionId        string                  `toml:"monitor-conversation-id"`
+	MetricsListen               string                  `toml:"metrics-listen"`
+	KeySigner                   string                  `toml:"key-signer"`
+	RecoveryAutoSign            bool                    `toml:"recovery-auto-sign"`
+	KeeperPublicKey             string                  `toml:"keeper-public-key"`
+	AssetId                     string                  `toml:"asset-id"`
+	CustomKeyPriceAssetId       string                  `toml:"custom-key-price-asset-id"`
+	CustomKeyPriceAmount        string                  `toml:"custom-key-price-amount"`
+	OperationPriceAssetId       string                  `toml:"operation-price-asset-id"`
+	OperationPriceAmount        string                  `toml:"operation-price-amount"`
+	TransactionMinimum          string                  `toml:"transaction-minimum"`
+	MixinMessengerAPI           string                  `toml:"mixin-messenger-api"`
+	MixinRPC                    string                  `toml:"mixin-rpc"`
+	BitcoinRPC                  string                  `toml:"bitcoin-rpc"`
+	LitecoinRPC                 string                  `toml:"litecoin-rpc"`
+	BitcoinCashRPC              string                  `toml:"bitcoincash-rpc"`
+	EthereumRPC                 string                  `toml:"ethereum-rpc"`
+	PolygonRPC                  string                  `toml:"polygon-rpc"`
+	PolygonObserverDepositEntry string                  `toml:"polygon-observer-deposit-entry"`
+	PolygonKeeperDepositEntry   string                  `toml:"polygon-keeper-deposit-entry"`
+	EVMKey                      string                  `toml:"evm-key"`
+	EVMRPC                      map[string]string       `toml:"evm-rpc"`
+	EVMChains                   []*ethereum.ChainConfig `toml:"evm-chains"`
+	Webhooks                    []WebhookEndpoint       `toml:"webhooks"`
 	App                         struct {
 		AppId             string `toml:"app-id"`
 		SessionId         string `toml:"session-id"`
@@ -49,5 +56,15 @@ func (c *Configuration) Validate() error {
 	if decimal.RequireFromString(c.TransactionMinimum).Sign() <= 0 {
 		return fmt.Errorf("Configuration.Validate(transaction) minimum %s", c.TransactionMinimum)
 	}
+	for _, e := range c.Webhooks {
+		err := e.validate()
+		if err != nil {
+			return fmt.Errorf("Configuration.Validate(webhook) %v", err)
+		}
+	}
 	return nil
 }
+
+func (c *Configuration) evmRPC(chain byte) string {
+	return ethereum.ChainRPC(chain, c.EVMRPC, c.EthereumRPC, c.PolygonRPC)
+}
@@ -18,6 +18,9 @@ func (node *Node) deriveBIP32WithKeeperPath(ctx context.Context, public, path st
 	if path8[0] > 3 {
 		panic(path8[0])
 	}
+	if path8[0] == 0 {
+		return public, nil
+	}
 	path32 := make([]uint32, path8[0])
 	for i := 0; i < int(path8[0]); i++ {
 		path32[i] = uint32(path8[1+i])
@@ -50,18 +53,7 @@ func (node *Node) sendKeeperResponse(ctx context.Context, holder string, typ, ch
 }
 
 func (node *Node) sendKeeperResponseWithReferences(ctx context.Context, holder string, typ, chain uint8, id string, extra []byte, references []crypto.Hash) error {
-	crv := byte(common.CurveSecp256k1ECDSABitcoin)
-	switch chain {
-	case common.SafeChainBitcoin:
-	case common.SafeChainLitecoin:
-		crv = common.CurveSecp256k1ECDSALitecoin
-	case common.SafeChainEthereum:
-		crv = common.CurveSecp256k1ECDSAEthereum
-	case common.SafeChainPolygon:
-		crv = common.CurveSecp256k1ECDSAPolygon
-	default:
-		panic(chain)
-	}
+	crv := common.SafeChainCurve(chain)
 	op := &common.Operation{
 		Id:     id,
 		Type:   typ,
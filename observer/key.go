@@ -2,42 +2,55 @@ package observer
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"time"
 
 	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
 )
 
 func (node *Node) safeKeyLoop(ctx context.Context, chain byte) {
 	for {
-		err := node.safeRequestSignerKeys(ctx, chain)
-		if err != nil {
-			panic(err)
-		}
+		for _, crv := range safeChainKeyCurves(chain) {
+			err := node.safeRequestSignerKeys(ctx, chain, crv)
+			if err != nil {
+				panic(err)
+			}
 
-		err = node.safeAddObserverKeys(ctx, chain)
-		if err != nil {
-			panic(err)
+			err = node.safeAddObserverKeys(ctx, chain, crv)
+			if err != nil {
+				panic(err)
+			}
 		}
 
 		time.Sleep(10 * time.Minute)
 	}
 }
 
-func (node *Node) safeAddObserverKeys(ctx context.Context, chain byte) error {
-	var crv byte
+// bitcoin safes could be either P2WSH with ECDSA keys or P2TR with
+// the taproot signer keys, so both curves are kept with spare keys
+func safeChainKeyCurves(chain byte) []byte {
 	switch chain {
 	case common.SafeChainBitcoin:
-		crv = common.CurveSecp256k1ECDSABitcoin
+		return []byte{common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1SchnorrBitcoin}
+	case common.SafeChainMixin:
+		return []byte{common.CurveEdwards25519Mixin}
 	case common.SafeChainEthereum:
-		crv = common.CurveSecp256k1ECDSAEthereum
+		return []byte{common.CurveSecp256k1ECDSAEthereum}
+	default:
+		panic(chain)
 	}
+}
+
+func (node *Node) safeAddObserverKeys(ctx context.Context, chain, crv byte) error {
 	count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, common.RequestRoleObserver)
 	if err != nil {
 		return err
 	}
 	for count < 1000 {
-		observer, chainCode, err := node.store.ReadObserverKey(ctx, crv)
+		observer, chainCode, err := node.readOrGenerateObserverKey(ctx, crv)
 		if err != nil {
 			return err
 		}
@@ -60,7 +73,14 @@ func (node *Node) safeAddObserverKeys(ctx context.Context, chain byte) error {
 		id := common.UniqueId(observer, observer)
 		extra := append([]byte{common.RequestRoleObserver}, chainCode...)
 		extra = append(extra, common.RequestFlagNone)
-		err = node.sendKeeperResponse(ctx, observer, common.ActionObserverAddKey, chain, id, extra)
+		op := &common.Operation{
+			Id:     id,
+			Type:   common.ActionObserverAddKey,
+			Curve:  crv,
+			Public: observer,
+			Extra:  extra,
+		}
+		err = node.sendKeeperTransactionWithReferences(ctx, op, nil)
 		if err != nil {
 			return err
 		}
@@ -69,34 +89,33 @@ func (node *Node) safeAddObserverKeys(ctx context.Context, chain byte) error {
 	return nil
 }
 
-func (node *Node) safeRequestSignerKeys(ctx context.Context, chain byte) error {
-	var crv byte
-	switch chain {
-	case common.SafeChainBitcoin:
-		crv = common.CurveSecp256k1ECDSABitcoin
-	case common.SafeChainEthereum:
-		crv = common.CurveSecp256k1ECDSAEthereum
-	}
+func (node *Node) safeRequestSignerKeys(ctx context.Context, chain, crv byte) error {
 	count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, common.RequestRoleSigner)
 	if err != nil || count > 1000 {
 		return err
 	}
-	requested, err := node.readSignerKeygenRequestTime(ctx, chain)
+	requested, err := node.readSignerKeygenRequestTime(ctx, chain, crv)
 	if err != nil || requested.Add(60*time.Minute).After(time.Now()) {
 		return err
 	}
 	dummy := node.bitcoinDummyHolder()
 	id := common.UniqueId(requested.String(), requested.String())
-	keysCount := []byte{16}
-	err = node.sendKeeperResponse(ctx, dummy, common.ActionObserverRequestSignerKeys, chain, id, keysCount)
+	op := &common.Operation{
+		Id:     id,
+		Type:   common.ActionObserverRequestSignerKeys,
+		Curve:  crv,
+		Public: dummy,
+		Extra:  []byte{16},
+	}
+	err = node.sendKeeperTransactionWithReferences(ctx, op, nil)
 	if err != nil {
 		return err
 	}
-	return node.writeSignerKeygenRequestTime(ctx, chain)
+	return node.writeSignerKeygenRequestTime(ctx, chain, crv)
 }
 
-func (node *Node) readSignerKeygenRequestTime(ctx context.Context, chain byte) (time.Time, error) {
-	key, err := node.chainKeygenRequestTimeKey(chain)
+func (node *Node) readSignerKeygenRequestTime(ctx context.Context, chain, crv byte) (time.Time, error) {
+	key, err := node.chainKeygenRequestTimeKey(chain, crv)
 	if err != nil {
 		return time.Unix(0, node.conf.Timestamp), err
 	}
@@ -107,21 +126,91 @@ func (node *Node) readSignerKeygenRequestTime(ctx context.Context, chain byte) (
 	return time.Parse(time.RFC3339Nano, val)
 }
 
-func (node *Node) writeSignerKeygenRequestTime(ctx context.Context, chain byte) error {
-	key, err := node.chainKeygenRequestTimeKey(chain)
+func (node *Node) writeSignerKeygenRequestTime(ctx context.Context, chain, crv byte) error {
+	key, err := node.chainKeygenRequestTimeKey(chain, crv)
 	if err != nil {
 		return err
 	}
 	return node.store.WriteProperty(ctx, key, time.Now().Format(time.RFC3339Nano))
 }
 
-func (node *Node) chainKeygenRequestTimeKey(chain byte) (string, error) {
+func (node *Node) chainKeygenRequestTimeKey(chain, crv byte) (string, error) {
 	switch chain {
 	case common.SafeChainBitcoin:
+		if crv == common.CurveSecp256k1SchnorrBitcoin {
+			return bitcoinTaprootKeygenRequestTimeKey, nil
+		}
 		return bitcoinKeygenRequestTimeKey, nil
+	case common.SafeChainMixin:
+		return mixinKeygenRequestTimeKey, nil
 	case common.SafeChainEthereum:
 		return ethereumKeygenRequestTimeKey, nil
 	default:
 		return "", fmt.Errorf("invalid keygen request chain")
 	}
 }
+
+// the mixin observer key is the view key of the kernel account, so it
+// is generated by the observer and kept as an accountant key
+func (node *Node) readOrGenerateObserverKey(ctx context.Context, crv byte) (string, []byte, error) {
+	if crv != common.CurveEdwards25519Mixin {
+		return node.store.ReadObserverKey(ctx, crv)
+	}
+	priv := mixinnet.GenerateKey(rand.Reader)
+	pub := priv.Public()
+	err := node.store.WriteMixinAccountantKey(ctx, pub.String(), priv.String())
+	if err != nil {
+		return "", nil, err
+	}
+	return pub.String(), make([]byte, 32), nil
+}
+
+// RequestSignerRefresh asks the keeper to refresh the shares of the signer
+// key, it is sent by the operator after a signer share may be leaked.
+func (node *Node) RequestSignerRefresh(ctx context.Context, public string) error {
+	key, err := node.readSignerKey(ctx, public)
+	if err != nil {
+		return err
+	}
+	id := common.UniqueId(public, fmt.Sprintf("REFRESH:%d", time.Now().UnixNano()))
+	op := &common.Operation{
+		Id:     id,
+		Type:   common.ActionObserverRequestSignerRefresh,
+		Curve:  key.Curve,
+		Public: key.Public,
+	}
+	return node.sendKeeperTransactionWithReferences(ctx, op, nil)
+}
+
+// RequestSignerReshare asks the keeper to transfer the signer key to the
+// current signer members with the new threshold, it should be sent after
+// the signer MTG members and threshold are reconfigured.
+func (node *Node) RequestSignerReshare(ctx context.Context, public string, threshold int) error {
+	if threshold < 1 || threshold > 255 {
+		return fmt.Errorf("invalid reshare threshold %d", threshold)
+	}
+	key, err := node.readSignerKey(ctx, public)
+	if err != nil {
+		return err
+	}
+	id := common.UniqueId(public, fmt.Sprintf("RESHARE:%d:%d", threshold, time.Now().UnixNano()))
+	op := &common.Operation{
+		Id:     id,
+		Type:   common.ActionObserverRequestSignerReshare,
+		Curve:  key.Curve,
+		Public: key.Public,
+		Extra:  []byte{byte(threshold)},
+	}
+	return node.sendKeeperTransactionWithReferences(ctx, op, nil)
+}
+
+func (node *Node) readSignerKey(ctx context.Context, public string) (*store.Key, error) {
+	key, err := node.keeperStore.ReadKey(ctx, public)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.Role != common.RequestRoleSigner {
+		return nil, fmt.Errorf("invalid signer key %s", public)
+	}
+	return key, nil
+}
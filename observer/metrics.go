@@ -0,0 +1,82 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+)
+
+func (node *Node) metricsLoop(ctx context.Context) {
+	err := common.ServeMetrics(ctx, node.conf.MetricsListen, node.collectMetrics)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (node *Node) collectMetrics(ctx context.Context) (*common.Metrics, error) {
+	m := common.NewMetrics()
+	for _, c := range node.safeChains() {
+		chain := fmt.Sprint(c)
+		ckp, err := node.readDepositCheckpoint(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		m.Gauge("safe_observer_deposit_checkpoint", "The next block height to scan for deposits", float64(ckp), "chain", chain)
+		info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, c, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			m.Gauge("safe_observer_keeper_height", "The latest block height accepted by the keeper", float64(info.Height), "chain", chain)
+		}
+		if common.SafeChainFamily(c) != common.SafeChainBitcoin {
+			continue
+		}
+		count, satoshi, err := node.readChainAccountantBalance(ctx, int(c))
+		if err != nil {
+			return nil, err
+		}
+		m.Gauge("safe_observer_accountant_outputs", "The unspent accountant outputs", float64(count), "chain", chain)
+		m.Gauge("safe_observer_accountant_satoshi", "The unspent accountant balance in satoshi", float64(satoshi), "chain", chain)
+	}
+	mckp, err := node.readMixinDepositCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.Gauge("safe_observer_deposit_checkpoint", "The next block height to scan for deposits", float64(mckp), "chain", fmt.Sprint(common.SafeChainMixin))
+
+	for _, chain := range []byte{common.SafeChainBitcoin, common.SafeChainEthereum, common.SafeChainMixin} {
+		for _, crv := range safeChainKeyCurves(chain) {
+			for role, name := range map[int]string{common.RequestRoleSigner: "signer", common.RequestRoleObserver: "observer"} {
+				count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, role)
+				if err != nil {
+					return nil, err
+				}
+				m.Gauge("safe_observer_spare_keys", "The spare keys by curve and role", float64(count), "curve", fmt.Sprint(crv), "role", name)
+			}
+		}
+	}
+
+	for _, table := range []string{"deposits", "transactions"} {
+		for _, state := range []int{common.RequestStateInitial, common.RequestStatePending} {
+			count, err := node.countByState(ctx, table, state)
+			if err != nil {
+				return nil, err
+			}
+			name := fmt.Sprintf("safe_observer_%s", table)
+			m.Gauge(name, fmt.Sprintf("The unfinished %s by state", table), float64(count), "state", common.StateName(state))
+		}
+	}
+	return m, nil
+}
+
+func (node *Node) countByState(ctx context.Context, table string, state int) (int, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE state=?", table)
+	row := node.store.db.QueryRowContext(ctx, query, state)
+
+	var count int
+	err := row.Scan(&count)
+	return count, err
+}
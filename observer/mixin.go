@@ -0,0 +1,459 @@
+package observer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/fox-one/mixin-sdk-go/v2/mixinnet"
+	"github.com/gofrs/uuid/v5"
+)
+
+const (
+	mixinKeygenRequestTimeKey = "mixin-keygen-request-time"
+	mixinDepositCheckpointKey = "mixin-deposit-checkpoint"
+)
+
+type mixinViewAccount struct {
+	safe *store.Safe
+	view mixinnet.Key
+}
+
+func (node *Node) mixinKernelDepositLoop(ctx context.Context) {
+	for {
+		time.Sleep(time.Second)
+		checkpoint, err := node.readMixinDepositCheckpoint(ctx)
+		if err != nil {
+			panic(err)
+		}
+		snapshots, err := mixin.RPCListSnapshots(ctx, node.conf.MixinRPC, checkpoint, 100)
+		if err != nil {
+			continue
+		}
+		accounts, err := node.listMixinViewAccounts(ctx)
+		if err != nil {
+			panic(err)
+		}
+
+		for i := range snapshots {
+			s := &snapshots[i]
+			checkpoint = s.Topology
+			for j := range s.Transaction {
+				err := node.mixinProcessTransaction(ctx, &s.Transaction[j], accounts)
+				logger.Verbosef("node.mixinProcessTransaction(%s) => %v", s.Transaction[j].Hash, err)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+		if len(snapshots) < 100 {
+			time.Sleep(time.Second)
+		}
+
+		err = node.writeMixinDepositCheckpoint(ctx, checkpoint)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (node *Node) listMixinViewAccounts(ctx context.Context) ([]*mixinViewAccount, error) {
+	safes, err := node.keeperStore.ListSafesWithState(ctx, common.RequestStateDone)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []*mixinViewAccount
+	for _, safe := range safes {
+		if safe.Chain != common.SafeChainMixin {
+			continue
+		}
+		priv, err := node.store.ReadAccountantPrivateKey(ctx, safe.Observer)
+		if err != nil {
+			return nil, err
+		}
+		view, err := mixinnet.KeyFromString(priv)
+		if err != nil {
+			return nil, fmt.Errorf("mixinnet.KeyFromString(%s) => %v", safe.Observer, err)
+		}
+		accounts = append(accounts, &mixinViewAccount{safe: safe, view: view})
+	}
+	return accounts, nil
+}
+
+func (node *Node) mixinProcessTransaction(ctx context.Context, tx *mixin.RPCTransaction, accounts []*mixinViewAccount) error {
+	for index, out := range tx.Output {
+		if out.Type != 0 || out.Script != mixin.KernelAccountScript() {
+			continue
+		}
+		for _, a := range accounts {
+			members := mixin.KernelAccountKeys(a.safe.Holder, a.safe.Signer, a.safe.Observer)
+			_, ok := mixin.ViewOutputMask(members, a.view, out.Keys, out.Mask, uint8(index))
+			if !ok {
+				continue
+			}
+			err := node.mixinWritePendingDeposit(ctx, tx, index, out.Amount, a.safe)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (node *Node) mixinWritePendingDeposit(ctx context.Context, tx *mixin.RPCTransaction, index int, amount string, safe *store.Safe) error {
+	asset, err := node.mixinFetchAssetMeta(ctx, tx.Asset)
+	logger.Printf("node.mixinFetchAssetMeta(%s) => %v %v", tx.Asset, asset, err)
+	if err != nil || asset == nil {
+		return err
+	}
+
+	c, err := node.keeperStore.ReadUnspentMixinUtxoCountForSafe(ctx, safe.Address, asset.AssetId)
+	logger.Printf("keeperStore.ReadUnspentMixinUtxoCountForSafe(%s) => %d %v", safe.Address, c, err)
+	if err != nil || c >= mixin.MaxUnspentUtxo/2 {
+		return err
+	}
+
+	id := common.UniqueId(asset.AssetId, safe.Holder)
+	id = common.UniqueId(id, fmt.Sprintf("%s:%d", tx.Hash, index))
+	createdAt := time.Now().UTC()
+	deposit := &Deposit{
+		TransactionHash: tx.Hash,
+		OutputIndex:     int64(index),
+		AssetId:         asset.AssetId,
+		Amount:          amount,
+		Receiver:        safe.Address,
+		Holder:          safe.Holder,
+		Category:        common.ActionObserverHolderDeposit,
+		State:           common.RequestStateInitial,
+		Chain:           common.SafeChainMixin,
+		RequestId:       id,
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+
+	err = node.store.WritePendingDepositIfNotExists(ctx, deposit)
+	if err != nil {
+		return fmt.Errorf("store.WritePendingDeposit(%v) => %v", deposit, err)
+	}
+	return nil
+}
+
+func (node *Node) mixinDepositConfirmLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		deposits, err := node.store.ListDeposits(ctx, common.SafeChainMixin, "", common.RequestStateInitial, 0)
+		if err != nil {
+			panic(err)
+		}
+		for _, d := range deposits {
+			err := node.mixinConfirmPendingDeposit(ctx, d)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) mixinConfirmPendingDeposit(ctx context.Context, deposit *Deposit) error {
+	safe, err := node.keeperStore.ReadSafe(ctx, deposit.Holder)
+	logger.Printf("node.mixinConfirmPendingDeposit(%v) => %v %v", deposit, safe, err)
+	if err != nil || safe == nil {
+		return err
+	}
+	bonded, err := node.checkOrDeployKeeperBond(ctx, deposit.Chain, deposit.AssetId, "", deposit.Holder, safe.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%v) => %t %v", deposit, bonded, err)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s) => %v", deposit.Holder, err)
+	} else if !bonded {
+		return nil
+	}
+
+	tx, err := mixin.RPCGetTransaction(ctx, node.conf.MixinRPC, deposit.TransactionHash)
+	if err != nil || tx == nil || tx.Snapshot == "" || int(deposit.OutputIndex) >= len(tx.Output) {
+		panic(fmt.Errorf("malicious mixin deposit or node not in sync? %s %v", deposit.TransactionHash, err))
+	}
+	out := tx.Output[deposit.OutputIndex]
+	if out.Amount != deposit.Amount || out.Script != mixin.KernelAccountScript() {
+		panic(fmt.Errorf("malicious mixin deposit %s", deposit.TransactionHash))
+	}
+	priv, err := node.store.ReadAccountantPrivateKey(ctx, safe.Observer)
+	if err != nil {
+		return err
+	}
+	view, err := mixinnet.KeyFromString(priv)
+	if err != nil {
+		panic(safe.Observer)
+	}
+	members := mixin.KernelAccountKeys(safe.Holder, safe.Signer, safe.Observer)
+	mask, ok := mixin.ViewOutputMask(members, view, out.Keys, out.Mask, uint8(deposit.OutputIndex))
+	if !ok {
+		panic(fmt.Errorf("malicious mixin deposit %s", deposit.TransactionHash))
+	}
+	deposit.Mask = mask
+
+	return node.sendKeeperDepositTransaction(ctx, deposit, mixin.ValuePrecision)
+}
+
+func (node *Node) mixinFetchAssetMeta(ctx context.Context, kernelAssetId string) (*Asset, error) {
+	meta, err := node.store.ReadAssetMeta(ctx, kernelAssetId)
+	if err != nil || meta != nil {
+		return meta, err
+	}
+	meta, err = node.fetchMixinAsset(ctx, kernelAssetId)
+	if err != nil || meta == nil {
+		return nil, err
+	}
+	// the kernel safe holds any asset, even those not supported as a safe chain
+	if meta.Chain == 0 {
+		meta.Chain = common.SafeChainMixin
+	}
+	return meta, node.store.WriteAssetMeta(ctx, meta)
+}
+
+func (node *Node) httpApproveMixinTransaction(ctx context.Context, raw string) error {
+	logger.Printf("node.httpApproveMixinTransaction(%s)", raw)
+	psbt, err := mixin.UnmarshalPartiallySignedTransactionHex(raw)
+	if err != nil {
+		return err
+	}
+	txHash := psbt.Hash()
+
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	if mixin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		return nil
+	}
+	if !mixin.CheckTransactionPartiallySignedBy(raw, approval.Holder) {
+		return nil
+	}
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil || tx == nil {
+		return err
+	}
+
+	raw = hex.EncodeToString(psbt.Marshal())
+	err = node.store.AddTransactionPartials(ctx, txHash, raw)
+	logger.Printf("store.AddTransactionPartials(%s) => %v", txHash, err)
+	return err
+}
+
+func (node *Node) httpRevokeMixinTransaction(ctx context.Context, txHash string, sigHex string) error {
+	logger.Printf("node.httpRevokeMixinTransaction(%s, %s)", txHash, sigHex)
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	if mixin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		return nil
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil {
+		return err
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return err
+	}
+	ms := fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash)
+	err = mixin.VerifySignature(tx.Holder, []byte(ms), sig)
+	logger.Printf("holder: mixin.VerifySignature(%v) => %v", tx, err)
+	if err != nil {
+		return err
+	}
+
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), sig...)
+	action := common.ActionMixinSafeRevokeTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x)", tx.Holder, action, id, extra)
+	if err != nil {
+		return err
+	}
+
+	err = node.store.RevokeTransactionApproval(ctx, txHash, sigHex+":"+approval.RawTransaction)
+	logger.Printf("store.RevokeTransactionApproval(%s) => %v", txHash, err)
+	return err
+}
+
+func (node *Node) mixinTransactionApprovalLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		approvals, err := node.store.ListPendingTransactionApprovals(ctx, common.SafeChainMixin)
+		if err != nil {
+			panic(err)
+		}
+		for _, approval := range approvals {
+			err := node.sendToKeeperMixinApproveTransaction(ctx, approval)
+			logger.Printf("node.sendToKeeperMixinApproveTransaction(%v) => %v", approval, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperMixinApproveTransaction(ctx context.Context, approval *Transaction) error {
+	signed, err := node.mixinCheckKeeperSignedTransaction(ctx, approval)
+	logger.Printf("node.mixinCheckKeeperSignedTransaction(%v) => %t %v", approval, signed, err)
+	if err != nil || signed {
+		return err
+	}
+	psbt, err := mixin.UnmarshalPartiallySignedTransactionHex(approval.RawTransaction)
+	if err != nil {
+		panic(approval.RawTransaction)
+	}
+	if psbt.VerifyHolderSignature(approval.Holder) != nil {
+		panic(approval.RawTransaction)
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, approval.TransactionHash)
+	if err != nil {
+		return err
+	}
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), psbt.HolderSignatures[0]...)
+	action := common.ActionMixinSafeApproveTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x)", tx.Holder, action, id, extra)
+	if err != nil {
+		return err
+	}
+
+	if approval.UpdatedAt.Add(keeper.SafeSignatureTimeout).After(time.Now()) {
+		return nil
+	}
+	id = common.UniqueId(id, approval.UpdatedAt.String())
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x)", tx.Holder, action, id, extra)
+	if err != nil {
+		return err
+	}
+	return node.store.UpdateTransactionApprovalRequestTime(ctx, approval.TransactionHash)
+}
+
+func (node *Node) mixinCheckKeeperSignedTransaction(ctx context.Context, approval *Transaction) (bool, error) {
+	requests, err := node.keeperStore.ListAllSignaturesForTransaction(ctx, approval.TransactionHash, common.RequestStateDone)
+	if err != nil {
+		return false, err
+	}
+	signed := make(map[int][]byte)
+	for _, r := range requests {
+		signed[r.InputIndex] = common.DecodeHexOrPanic(r.Signature.String)
+	}
+
+	psbt, _ := mixin.UnmarshalPartiallySignedTransactionHex(approval.RawTransaction)
+	for idx := range psbt.Inputs {
+		if len(signed[idx]) != 64 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (node *Node) keeperCombineMixinTransactionSignatures(ctx context.Context, extra []byte) error {
+	logger.Printf("node.keeperCombineMixinTransactionSignatures(%x)", extra)
+	spsbt, err := mixin.UnmarshalPartiallySignedTransaction(extra)
+	if err != nil {
+		panic(err)
+	}
+
+	tx, err := node.store.ReadTransactionApproval(ctx, spsbt.Hash())
+	if err != nil || tx.State >= common.RequestStateDone {
+		return err
+	}
+	if tx.Chain != common.SafeChainMixin {
+		panic(spsbt.Hash())
+	}
+	hpsbt, err := mixin.UnmarshalPartiallySignedTransactionHex(tx.RawTransaction)
+	if err != nil {
+		panic(err)
+	}
+	err = hpsbt.VerifyHolderSignature(tx.Holder)
+	if err != nil {
+		panic(spsbt.Hash())
+	}
+	for idx := range spsbt.Inputs {
+		err = spsbt.VerifySignature(tx.Signer, idx, spsbt.Signatures[idx])
+		if err != nil {
+			panic(spsbt.Hash())
+		}
+	}
+	spsbt.HolderSignatures = hpsbt.HolderSignatures
+
+	raw := hex.EncodeToString(spsbt.Marshal())
+	err = node.store.FinishTransactionSignatures(ctx, spsbt.Hash(), raw)
+	logger.Printf("store.FinishTransactionSignatures(%s) => %v", spsbt.Hash(), err)
+	return err
+}
+
+func (node *Node) mixinTransactionSpendLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		txs, err := node.store.ListFullySignedTransactionApprovals(ctx, common.SafeChainMixin)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			psbt, err := mixin.UnmarshalPartiallySignedTransactionHex(tx.RawTransaction)
+			if err != nil {
+				panic(err)
+			}
+			safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
+			if err != nil {
+				panic(err)
+			}
+			spentRaw, err := psbt.SignedTransaction(safe.Holder, safe.Signer, safe.Observer)
+			if err != nil {
+				panic(err)
+			}
+			spentHash, err := mixin.RPCSendRawTransaction(ctx, node.conf.MixinRPC, spentRaw)
+			logger.Verbosef("mixin.RPCSendRawTransaction(%s) => %s %v", tx.TransactionHash, spentHash, err)
+			if err != nil {
+				break
+			}
+			if spentHash != tx.TransactionHash {
+				panic(fmt.Errorf("mixin.RPCSendRawTransaction(%s) => %s", tx.TransactionHash, spentHash))
+			}
+			err = node.store.ConfirmFullySignedTransactionApproval(ctx, tx.TransactionHash, spentHash, spentRaw)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) readMixinDepositCheckpoint(ctx context.Context) (uint64, error) {
+	val, err := node.store.ReadProperty(ctx, mixinDepositCheckpointKey)
+	if err != nil || val == "" {
+		return 4655227, err
+	}
+	return strconv.ParseUint(val, 10, 64)
+}
+
+func (node *Node) writeMixinDepositCheckpoint(ctx context.Context, offset uint64) error {
+	return node.store.WriteProperty(ctx, mixinDepositCheckpointKey, fmt.Sprint(offset))
+}
@@ -38,6 +38,7 @@ type Node struct {
 	mixin       *mixin.Client
 	keeperStore *store.SQLite3Store
 	store       *SQLite3Store
+	signer      KeySigner
 }
 
 func NewNode(db *SQLite3Store, kd *store.SQLite3Store, conf *Configuration, keeper *mtg.Configuration, mixin *mixin.Client) *Node {
@@ -53,7 +54,14 @@ func NewNode(db *SQLite3Store, kd *store.SQLite3Store, conf *Configuration, keep
 		mixin:       mixin,
 	}
 	node.aesKey = common.ECDHEd25519(conf.PrivateKey, conf.KeeperPublicKey)
-	abi.InitFactoryContractAddress(conf.PolygonFactoryAddress)
+	node.signer, err = NewKeySigner(conf.KeySigner, db)
+	if err != nil {
+		panic(err)
+	}
+	for _, c := range conf.EVMChains {
+		common.RegisterEVMChain(c)
+	}
+	abi.InitFactoryContractAddress(ethereum.BondChain().FactoryAddress)
 	return node
 }
 
@@ -63,25 +71,24 @@ func (node *Node) Boot(ctx context.Context) {
 		panic(err)
 	}
 
-	for _, chain := range []byte{
-		common.SafeChainBitcoin,
-		common.SafeChainLitecoin,
-		common.SafeChainPolygon,
-		common.SafeChainEthereum,
-	} {
+	for _, chain := range node.safeChains() {
 		err := node.sendPriceInfo(ctx, chain)
 		if err != nil {
 			panic(err)
 		}
 
-		switch chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.SafeChainFamily(chain) {
+		case common.SafeChainBitcoin:
 			go node.bitcoinNetworkInfoLoop(ctx, chain)
 			go node.bitcoinRPCBlocksLoop(ctx, chain)
 			go node.bitcoinDepositConfirmLoop(ctx, chain)
 			go node.bitcoinTransactionApprovalLoop(ctx, chain)
 			go node.bitcoinTransactionSpendLoop(ctx, chain)
-		case common.SafeChainPolygon, common.SafeChainEthereum:
+			go node.bitcoinConsolidateLoop(ctx, chain)
+			if chain != common.SafeChainBitcoinCash { // no rbf or cpfp
+				go node.bitcoinTransactionBumpLoop(ctx, chain)
+			}
+		case common.SafeChainEthereum:
 			go node.ethereumNetworkInfoLoop(ctx, chain)
 			go node.ethereumRPCBlocksLoop(ctx, chain)
 			go node.ethereumDepositConfirmLoop(ctx, chain)
@@ -91,18 +98,43 @@ func (node *Node) Boot(ctx context.Context) {
 	}
 	go node.safeKeyLoop(ctx, common.SafeChainBitcoin)
 	go node.safeKeyLoop(ctx, common.SafeChainEthereum)
+	go node.safeKeyLoop(ctx, common.SafeChainMixin)
+	go node.mixinKernelDepositLoop(ctx)
+	go node.mixinDepositConfirmLoop(ctx)
+	go node.mixinTransactionApprovalLoop(ctx)
+	go node.mixinTransactionSpendLoop(ctx)
 	go node.mixinWithdrawalsLoop(ctx)
 	go node.sendAccountApprovals(ctx)
+	go node.webhookLoop(ctx)
+	if node.conf.MetricsListen != "" {
+		go node.metricsLoop(ctx)
+	}
 	go node.Blaze(ctx)
 	node.snapshotsLoop(ctx)
 }
 
+// safeChains lists all bitcoin chains and the EVM chains with RPC configured
+func (node *Node) safeChains() []byte {
+	chains := []byte{
+		common.SafeChainBitcoin,
+		common.SafeChainLitecoin,
+		common.SafeChainBitcoinCash,
+	}
+	for _, ec := range ethereum.ListChains() {
+		if node.conf.evmRPC(ec.Chain) == "" {
+			continue
+		}
+		chains = append(chains, ec.Chain)
+	}
+	return chains
+}
+
 func (node *Node) sendPriceInfo(ctx context.Context, chain byte) error {
 	var assetId string
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
 		_, assetId = node.bitcoinParams(chain)
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainEthereum:
 		_, assetId = node.ethereumParams(chain)
 	default:
 		panic(chain)
@@ -168,8 +200,8 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 			var extra []byte
 			var action byte
 			var assetId string
-			switch sp.Chain {
-			case common.SafeChainBitcoin, common.SafeChainLitecoin:
+			switch common.SafeChainFamily(sp.Chain) {
+			case common.SafeChainBitcoin:
 				_, assetId = node.bitcoinParams(sp.Chain)
 				sig, err := base64.RawURLEncoding.DecodeString(account.Signature.String)
 				if err != nil {
@@ -177,7 +209,15 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 				}
 				action = common.ActionBitcoinSafeApproveAccount
 				extra = append(rid.Bytes(), sig...)
-			case common.SafeChainPolygon, common.SafeChainEthereum:
+			case common.SafeChainMixin:
+				assetId = common.SafeChainAssetId(sp.Chain)
+				sig, err := hex.DecodeString(account.Signature.String)
+				if err != nil {
+					panic(err)
+				}
+				action = common.ActionMixinSafeApproveAccount
+				extra = append(rid.Bytes(), sig...)
+			case common.SafeChainEthereum:
 				_, assetId = node.ethereumParams(sp.Chain)
 				sig, err := hex.DecodeString(account.Signature.String)
 				if err != nil {
@@ -336,15 +376,15 @@ func (node *Node) processMixinWithdrawalSnapshot(ctx context.Context, s *m.RPCSn
 			return err
 		}
 		hash := string(extra[64:])
-		switch asset.Chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.SafeChainFamily(asset.Chain) {
+		case common.SafeChainBitcoin:
 			rpc, _ := node.bitcoinParams(asset.Chain)
 			btx, err := bitcoin.RPCGetTransaction(asset.Chain, rpc, hash)
 			if err != nil {
 				return err
 			}
 			return node.bitcoinProcessTransaction(ctx, btx, asset.Chain)
-		case common.SafeChainEthereum, common.SafeChainPolygon:
+		case common.SafeChainEthereum:
 			rpc, _ := node.ethereumParams(asset.Chain)
 			etx, err := ethereum.RPCGetTransactionByHash(rpc, hash)
 			if err != nil {
@@ -441,7 +481,7 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 	switch s.AssetID {
 	case node.conf.AssetId:
 		switch op.Type {
-		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+		case common.ActionBitcoinSafeApproveAccount, common.ActionMixinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
 			return false, nil
 		}
 		if s.Amount.Cmp(decimal.NewFromInt(1)) < 0 {
@@ -449,7 +489,7 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 		}
 	case params.OperationPriceAsset:
 		switch op.Type {
-		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+		case common.ActionBitcoinSafeApproveAccount, common.ActionMixinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
 		default:
 			return false, nil
 		}
@@ -473,16 +513,20 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 	}
 
 	switch op.Type {
-	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
+	case common.ActionBitcoinSafeProposeTransaction, common.ActionMixinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
 		return true, node.keeperSaveTransactionProposal(ctx, chain, data, s.CreatedAt)
 	case common.ActionBitcoinSafeApproveTransaction:
 		return true, node.keeperCombineBitcoinTransactionSignatures(ctx, data)
+	case common.ActionMixinSafeApproveTransaction:
+		return true, node.keeperCombineMixinTransactionSignatures(ctx, data)
 	case common.ActionEthereumSafeApproveTransaction:
 		return true, node.keeperVerifyEthereumTransactionSignatures(ctx, data)
-	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
+	case common.ActionBitcoinSafeProposeAccount, common.ActionMixinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
 		return true, node.keeperSaveAccountProposal(ctx, chain, data, s.CreatedAt)
 	case common.ActionBitcoinSafeApproveAccount:
 		return true, node.deployBitcoinSafeBond(ctx, data)
+	case common.ActionMixinSafeApproveAccount:
+		return true, node.deployMixinSafeBond(ctx, data)
 	case common.ActionEthereumSafeApproveAccount:
 		return true, node.deployEthereumGnosisSafeAccount(ctx, data)
 	}
@@ -553,20 +597,20 @@ func depositCheckpointDefault(chain byte) int64 {
 		return 802220
 	case common.SafeChainLitecoin:
 		return 2523300
-	case common.SafeChainPolygon:
-		return 52950000
-	case common.SafeChainEthereum:
-		return 19175473
-	default:
-		panic(chain)
+	case common.SafeChainBitcoinCash:
+		return 831000
 	}
+	if ec := ethereum.GetChainConfig(chain); ec != nil {
+		return ec.DepositCheckpoint
+	}
+	panic(chain)
 }
 
 func depositCheckpointKey(chain byte) string {
-	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	switch common.SafeChainFamily(chain) {
+	case common.SafeChainBitcoin:
 		return fmt.Sprintf("bitcoin-deposit-checkpoint-%d", chain)
-	case common.SafeChainEthereum, common.SafeChainPolygon:
+	case common.SafeChainEthereum:
 		return fmt.Sprintf("ethereum-deposit-checkpoint-%d", chain)
 	default:
 		panic(chain)
@@ -585,13 +629,11 @@ func (node *Node) getChainFinalizationDelay(chain byte) int64 {
 	switch chain {
 	case common.SafeChainBitcoin:
 		return 3
-	case common.SafeChainLitecoin:
+	case common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		return 6
-	case common.SafeChainEthereum:
-		return 32
-	case common.SafeChainPolygon:
-		return 512
-	default:
-		panic(chain)
 	}
+	if ec := ethereum.GetChainConfig(chain); ec != nil {
+		return ec.FinalizationDelay
+	}
+	panic(chain)
 }
@@ -1,10 +1,20 @@
 package observer
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -15,7 +25,14 @@ import (
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	ec "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -100,7 +117,7 @@ func TestObserverMigrateBondAsset(t *testing.T) {
 	bondId := ethereum.GenerateAssetId(testChainMVM, strings.ToLower(bond.Hex()))
 	require.Equal(testMVMBondAssetId, bondId)
 
-	abi.InitFactoryContractAddress(node.conf.PolygonFactoryAddress)
+	abi.InitFactoryContractAddress(ethereum.BondChain().FactoryAddress)
 	err = abi.GetOrDeployFactoryAsset(ctx, node.conf.PolygonRPC, os.Getenv("MVM_DEPLOYER"), assetId, asset.Symbol, asset.Name, testReceiverAddress, holder)
 	require.Nil(err)
 
@@ -244,6 +261,384 @@ func TestNode(t *testing.T) {
 	}
 }
 
+func TestFeeBump(t *testing.T) {
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+
+	hash, spent, replaced := strings.Repeat("a", 64), strings.Repeat("b", 64), strings.Repeat("c", 64)
+	now := time.Now().UTC()
+	tx := &Transaction{
+		TransactionHash: hash,
+		RawTransaction:  "00",
+		Chain:           common.SafeChainBitcoin,
+		Holder:          testPublicKey(testBitcoinKeyHolderPrivate),
+		Signer:          testPublicKey(testBitcoinKeyHolderPrivate),
+		State:           common.RequestStateDone,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	err = node.store.WriteTransactionApprovalIfNotExists(ctx, tx)
+	require.Nil(err)
+	err = node.store.ConfirmFullySignedTransactionApproval(ctx, hash, spent, "01")
+	require.Nil(err)
+	txs, err := node.store.ListSpentTransactionApprovals(ctx, common.SafeChainBitcoin, now.Add(-time.Hour))
+	require.Nil(err)
+	require.Len(txs, 1)
+	require.Equal(spent, txs[0].SpentHash.String)
+
+	err = node.store.WriteBitcoinUTXOIfNotExists(ctx, &Output{
+		TransactionHash: strings.Repeat("d", 64),
+		Address:         testSafeAddress,
+		Satoshi:         10000,
+		Chain:           common.SafeChainBitcoin,
+		State:           common.RequestStateInitial,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	require.Nil(err)
+	out, err := node.store.AssignBitcoinUTXOByRangeForTransaction(ctx, 9000, 11000, tx)
+	require.Nil(err)
+	require.NotNil(out)
+	outputs, err := node.store.ListBitcoinUTXOsSpentBy(ctx, hash)
+	require.Nil(err)
+	require.Len(outputs, 1)
+
+	err = node.store.WritePendingDepositIfNotExists(ctx, &Deposit{
+		TransactionHash: spent,
+		AssetId:         common.SafeBitcoinChainId,
+		Amount:          "0.0001",
+		Receiver:        testSafeAddress,
+		Sender:          testSafeAddress,
+		State:           common.RequestStateInitial,
+		Chain:           common.SafeChainBitcoin,
+		Holder:          tx.Holder,
+		RequestId:       common.UniqueId(spent, "0"),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	require.Nil(err)
+
+	bump := &FeeBump{
+		BumpHash:        replaced,
+		TransactionHash: hash,
+		Method:          FeeBumpMethodRBF,
+		ParentHash:      spent,
+		FeeRate:         20,
+		Fee:             3000,
+		CreatedAt:       now,
+	}
+	err = node.store.ReplaceSpentTransactionApproval(ctx, bump, "02")
+	require.Nil(err)
+	err = node.store.ReplaceSpentTransactionApproval(ctx, bump, "02")
+	require.NotNil(err)
+	approval, err := node.store.ReadTransactionApproval(ctx, replaced)
+	require.Nil(err)
+	require.Equal(hash, approval.TransactionHash)
+	require.Equal(sql.NullString{Valid: true, String: "02"}, approval.SpentRaw)
+	deposits, err := node.store.ListDeposits(ctx, common.SafeChainBitcoin, "", common.RequestStateInitial, 0)
+	require.Nil(err)
+	require.Len(deposits, 0)
+	bumps, err := node.store.ListFeeBumps(ctx, hash)
+	require.Nil(err)
+	require.Len(bumps, 1)
+	require.Equal(FeeBumpMethodRBF, bumps[0].Method)
+	require.Equal(spent, bumps[0].ParentHash)
+	require.Equal(int64(3000), bumps[0].Fee)
+
+	err = node.store.ReleaseBitcoinUTXO(ctx, outputs[0])
+	require.Nil(err)
+	outputs, err = node.store.ListBitcoinUTXOsSpentBy(ctx, hash)
+	require.Nil(err)
+	require.Len(outputs, 0)
+	utxos, err := node.store.ReadBitcoinUTXOs(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(utxos, 1)
+}
+
+func TestFeeBumpFlow(t *testing.T) {
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+
+	rpc := &testBitcoinRPC{
+		mempool:      make(map[string]*bitcoin.MemPoolTransaction),
+		transactions: make(map[string]*bitcoin.RPCTransaction),
+	}
+	server := httptest.NewServer(http.HandlerFunc(rpc.handle))
+	defer server.Close()
+	node.conf.BitcoinRPC = server.URL
+	node.conf.LitecoinRPC = server.URL
+
+	hash := strings.Repeat("a", 64)
+	spent, spentRaw := testBitcoinTransaction(strings.Repeat("b", 64), 10000)
+	feeHash, feeRaw := testBitcoinTransaction(strings.Repeat("c", 64), 5000)
+	now := time.Now().UTC()
+	tx := &Transaction{
+		TransactionHash: hash,
+		RawTransaction:  "00",
+		Chain:           common.SafeChainBitcoin,
+		Holder:          testPublicKey(testBitcoinKeyHolderPrivate),
+		Signer:          testPublicKey(testBitcoinKeyHolderPrivate),
+		State:           common.RequestStateDone,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	err = node.store.WriteTransactionApprovalIfNotExists(ctx, tx)
+	require.Nil(err)
+	err = node.store.ConfirmFullySignedTransactionApproval(ctx, hash, spent, spentRaw)
+	require.Nil(err)
+	err = node.store.WriteBitcoinUTXOIfNotExists(ctx, &Output{
+		TransactionHash: feeHash,
+		Address:         testSafeAddress,
+		Satoshi:         5000,
+		Chain:           common.SafeChainBitcoin,
+		State:           common.RequestStateInitial,
+		RawTransaction:  sql.NullString{Valid: true, String: feeRaw},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	require.Nil(err)
+	out, err := node.store.AssignBitcoinUTXOByRangeForTransaction(ctx, 4000, 6000, tx)
+	require.Nil(err)
+	require.NotNil(out)
+	txs, err := node.store.ListSpentTransactionApprovals(ctx, common.SafeChainBitcoin, now.Add(-time.Hour))
+	require.Nil(err)
+	require.Len(txs, 1)
+	tx = txs[0]
+
+	entry := &bitcoin.MemPoolTransaction{VSize: 200, AncestorSize: 200}
+	entry.Fees.Base, entry.Fees.Ancestor = 0.0001, 0.0001
+	rpc.mempool[spent] = entry
+	err = node.bitcoinBumpTransactionFee(ctx, tx)
+	require.Nil(err)
+	require.Len(rpc.broadcasted, 0)
+
+	entry.Fees.Base, entry.Fees.Ancestor = 0.000002, 0.000002
+	ltx := *tx
+	ltx.Chain = common.SafeChainLitecoin
+	err = node.bitcoinBumpTransactionFee(ctx, &ltx)
+	require.Nil(err)
+	require.Len(rpc.broadcasted, 0)
+
+	err = node.bitcoinBumpTransactionFee(ctx, tx)
+	require.Nil(err)
+	require.Equal([]string{feeRaw}, rpc.broadcasted)
+	rpc.transactions[feeHash] = &bitcoin.RPCTransaction{TxId: feeHash}
+	err = node.bitcoinBumpTransactionFee(ctx, tx)
+	require.Nil(err)
+	require.Len(rpc.broadcasted, 1)
+
+	delete(rpc.mempool, spent)
+	err = node.bitcoinBumpTransactionFee(ctx, tx)
+	require.Nil(err)
+	outputs, err := node.store.ListBitcoinUTXOsSpentBy(ctx, hash)
+	require.Nil(err)
+	require.Len(outputs, 0)
+	utxos, err := node.store.ReadBitcoinUTXOs(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(utxos, 1)
+	require.Equal(feeHash, utxos[0].TransactionHash)
+}
+
+type testBitcoinRPC struct {
+	mempool      map[string]*bitcoin.MemPoolTransaction
+	transactions map[string]*bitcoin.RPCTransaction
+	broadcasted  []string
+}
+
+func (rpc *testBitcoinRPC) handle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+		Params []any  `json:"params"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		panic(err)
+	}
+	var result, failure any
+	switch req.Method {
+	case "getblockchaininfo":
+		result = map[string]any{"blocks": 100}
+	case "getblockhash":
+		result = strings.Repeat("0", 64)
+	case "getblock":
+		result = map[string]any{"height": 100, "tx": []any{
+			map[string]any{"txid": strings.Repeat("1", 64), "fee": 0.00002, "vsize": 100},
+		}}
+	case "getrawmempool":
+		result = map[string]any{strings.Repeat("2", 64): map[string]any{
+			"fees": map[string]any{"base": 0.00002}, "vsize": 100,
+		}}
+	case "getmempoolentry":
+		if entry := rpc.mempool[req.Params[0].(string)]; entry != nil {
+			result = entry
+		} else {
+			failure = map[string]any{"code": -5, "message": "Transaction not in mempool"}
+		}
+	case "getrawtransaction":
+		if tx := rpc.transactions[req.Params[0].(string)]; tx != nil {
+			result = tx
+		} else {
+			failure = map[string]any{"code": -5, "message": "No such mempool or blockchain transaction"}
+		}
+	case "sendrawtransaction":
+		raw := req.Params[0].(string)
+		tx, err := btcutil.NewTxFromBytes(common.DecodeHexOrPanic(raw))
+		if err != nil {
+			panic(err)
+		}
+		rpc.broadcasted = append(rpc.broadcasted, raw)
+		result = tx.Hash().String()
+	default:
+		panic(req.Method)
+	}
+	err = json.NewEncoder(w).Encode(map[string]any{"result": result, "error": failure})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func testBitcoinTransaction(parent string, satoshi int64) (string, string) {
+	hash, err := chainhash.NewHashFromStr(parent)
+	if err != nil {
+		panic(err)
+	}
+	msgTx := wire.NewMsgTx(2)
+	msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, 0), nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(satoshi, []byte{txscript.OP_TRUE}))
+	var buf bytes.Buffer
+	err = msgTx.Serialize(&buf)
+	if err != nil {
+		panic(err)
+	}
+	return msgTx.TxHash().String(), hex.EncodeToString(buf.Bytes())
+}
+
+func TestConsolidateSafe(t *testing.T) {
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+	kd, err := keeper.OpenSQLite3Store(root + "/keeper.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	now := time.Now().UTC()
+	request := func(action byte) *common.Request {
+		id := uuid.Must(uuid.NewV4()).String()
+		req := &common.Request{
+			Id:        id,
+			MixinHash: crypto.Keccak256Hash([]byte(id)),
+			Role:      common.RequestRoleObserver,
+			Action:    action,
+			Curve:     common.CurveSecp256k1ECDSABitcoin,
+			Holder:    holder,
+			State:     common.RequestStateInitial,
+			CreatedAt: now,
+			Output:    &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: id}},
+		}
+		err := kd.WriteRequestIfNotExist(ctx, req)
+		require.Nil(err)
+		return req
+	}
+	req := request(common.ActionBitcoinSafeApproveAccount)
+	safe := &store.Safe{
+		Holder:      holder,
+		Chain:       common.SafeChainBitcoin,
+		Signer:      testPublicKey(strings.Repeat("1", 64)),
+		Observer:    testPublicKey(strings.Repeat("2", 64)),
+		Timelock:    bitcoin.TimeLockMinimum,
+		Path:        "00000000",
+		Address:     testSafeAddress,
+		Extra:       []byte{0},
+		Receivers:   []string{uuid.Must(uuid.NewV4()).String()},
+		Threshold:   1,
+		RequestId:   req.Id,
+		State:       common.RequestStateDone,
+		SafeAssetId: uuid.Must(uuid.NewV4()).String(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = kd.WriteSafeWithRequest(ctx, safe, nil, req)
+	require.Nil(err)
+	req = request(common.ActionObserverUpdateNetworkStatus)
+	info := &store.NetworkInfo{
+		RequestId: req.Id,
+		Chain:     common.SafeChainBitcoin,
+		Fee:       3,
+		Height:    100,
+		Hash:      strings.Repeat("0", 64),
+		CreatedAt: now,
+	}
+	err = kd.WriteNetworkInfoFromRequest(ctx, info, req)
+	require.Nil(err)
+	req = request(common.ActionObserverHolderDeposit)
+	err = kd.WriteBitcoinOutputFromRequest(ctx, safe, &bitcoin.Input{
+		TransactionHash: strings.Repeat("a", 64),
+		Satoshi:         10000,
+		Script:          []byte{txscript.OP_TRUE},
+	}, req, common.SafeBitcoinChainId, testSafeAddress, nil)
+	require.Nil(err)
+
+	sign := func(priv string, addr string) string {
+		ms := fmt.Sprintf("CONSOLIDATE:%s:%s", info.RequestId, addr)
+		hash := bitcoin.HashMessageForSignature(ms, common.SafeChainBitcoin)
+		seed := common.DecodeHexOrPanic(priv)
+		key, _ := btcec.PrivKeyFromBytes(seed)
+		return base64.RawURLEncoding.EncodeToString(ecdsa.Sign(key, hash).Serialize())
+	}
+	sig := sign(testBitcoinKeyHolderPrivate, testSafeAddress)
+	err = node.httpConsolidateSafeAccount(ctx, testReceiverAddress, info.RequestId, sig)
+	require.ErrorContains(err, "HTTP: 404")
+	err = node.httpConsolidateSafeAccount(ctx, testSafeAddress, uuid.Must(uuid.NewV4()).String(), sig)
+	require.ErrorContains(err, "HTTP: 406")
+	err = node.httpConsolidateSafeAccount(ctx, testSafeAddress, info.RequestId, sign(strings.Repeat("3", 64), testSafeAddress))
+	require.NotNil(err)
+	err = node.httpConsolidateSafeAccount(ctx, testSafeAddress, info.RequestId, sign(testBitcoinKeyHolderPrivate, testReceiverAddress))
+	require.NotNil(err)
+
+	// too few outputs to consolidate, so nothing is sent to the keeper
+	err = node.httpConsolidateSafeAccount(ctx, testSafeAddress, info.RequestId, sig)
+	require.Nil(err)
+	err = node.bitcoinConsolidateSafe(ctx, safe, info, 2)
+	require.Nil(err)
+}
+
+func TestSignerRequests(t *testing.T) {
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+
+	public := testPublicKey(testBitcoinKeyHolderPrivate)
+	err = node.RequestSignerRefresh(ctx, public)
+	require.ErrorContains(err, "invalid signer key")
+	err = node.RequestSignerReshare(ctx, public, 0)
+	require.ErrorContains(err, "invalid reshare threshold")
+	err = node.RequestSignerReshare(ctx, public, 2)
+	require.ErrorContains(err, "invalid signer key")
+}
+
 func testUpsertStats(ctx context.Context, node *Node, s *StatsInfo) error {
 	id := uuid.Must(uuid.NewV4()).String()
 	return node.store.UpsertNodeStats(ctx, id, s.Type, s.String())
@@ -317,3 +712,302 @@ func getMVMFactoryAssetAddress(assetId, symbol, name string, holder string) ec.A
 }
 
 const testMVMAssetContractCode = "0x60806040523480156200001157600080fd5b506040516200093038038062000930833981016040819052620000349162000133565b60036200004283826200022c565b5060026200005182826200022c565b5050336000908152602081905260409020600019905550620002f8565b634e487b7160e01b600052604160045260246000fd5b600082601f8301126200009657600080fd5b81516001600160401b0380821115620000b357620000b36200006e565b604051601f8301601f19908116603f01168101908282118183101715620000de57620000de6200006e565b81604052838152602092508683858801011115620000fb57600080fd5b600091505b838210156200011f578582018301518183018401529082019062000100565b600093810190920192909252949350505050565b600080604083850312156200014757600080fd5b82516001600160401b03808211156200015f57600080fd5b6200016d8683870162000084565b935060208501519150808211156200018457600080fd5b50620001938582860162000084565b9150509250929050565b600181811c90821680620001b257607f821691505b602082108103620001d357634e487b7160e01b600052602260045260246000fd5b50919050565b601f8211156200022757600081815260208120601f850160051c81016020861015620002025750805b601f850160051c820191505b8181101562000223578281556001016200020e565b5050505b505050565b81516001600160401b038111156200024857620002486200006e565b62000260816200025984546200019d565b84620001d9565b602080601f8311600181146200029857600084156200027f5750858301515b600019600386901b1c1916600185901b17855562000223565b600085815260208120601f198616915b82811015620002c957888601518255948401946001909101908401620002a8565b5085821015620002e85787850151600019600388901b60f8161c191681555b5050505050600190811b01905550565b61062880620003086000396000f3fe608060405234801561001057600080fd5b50600436106100935760003560e01c8063313ce56711610066578063313ce5671461010357806370a082311461011d57806395d89b4114610146578063a9059cbb1461014e578063dd62ed3e1461016157600080fd5b806306fdde0314610098578063095ea7b3146100b657806318160ddd146100d957806323b872dd146100f0575b600080fd5b6100a061019a565b6040516100ad9190610457565b60405180910390f35b6100c96100c43660046104c1565b610228565b60405190151581526020016100ad565b6100e260001981565b6040519081526020016100ad565b6100c96100fe3660046104eb565b61030d565b61010b601281565b60405160ff90911681526020016100ad565b6100e261012b366004610527565b6001600160a01b031660009081526020819052604090205490565b6100a0610324565b6100c961015c3660046104c1565b610331565b6100e261016f366004610549565b6001600160a01b03918216600090815260016020908152604080832093909416825291909152205490565b600280546101a79061057c565b80601f01602080910402602001604051908101604052809291908181526020018280546101d39061057c565b80156102205780601f106101f557610100808354040283529160200191610220565b820191906000526020600020905b81548152906001019060200180831161020357829003601f168201915b505050505081565b600081158061025857503360009081526001602090815260408083206001600160a01b0387168452909152902054155b6102a85760405162461bcd60e51b815260206004820152601f60248201527f617070726f7665206f6e2061206e6f6e2d7a65726f20616c6c6f77616e636500604482015260640160405180910390fd5b3360008181526001602090815260408083206001600160a01b03881680855290835292819020869055518581529192917f8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925910160405180910390a35060015b92915050565b600061031a848484610347565b5060019392505050565b600380546101a79061057c565b600061033e3384846103aa565b50600192915050565b6001600160a01b038316600090815260016020908152604080832033845290915290205461037582826105cc565b6001600160a01b03851660009081526001602090815260408083203384529091529020556103a48484846103aa565b50505050565b6001600160a01b0383166000908152602081905260409020546103ce9082906105cc565b6001600160a01b0380851660009081526020819052604080822093909355908416815220546103fe9082906105df565b6001600160a01b038381166000818152602081815260409182902094909455518481529092918616917fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef910160405180910390a3505050565b600060208083528351808285015260005b8181101561048457858101830151858201604001528201610468565b506000604082860101526040601f19601f8301168501019250505092915050565b80356001600160a01b03811681146104bc57600080fd5b919050565b600080604083850312156104d457600080fd5b6104dd836104a5565b946020939093013593505050565b60008060006060848603121561050057600080fd5b610509846104a5565b9250610517602085016104a5565b9150604084013590509250925092565b60006020828403121561053957600080fd5b610542826104a5565b9392505050565b6000806040838503121561055c57600080fd5b610565836104a5565b9150610573602084016104a5565b90509250929050565b600181811c9082168061059057607f821691505b6020821081036105b057634e487b7160e01b600052602260045260246000fd5b50919050565b634e487b7160e01b600052601160045260246000fd5b81810381811115610307576103076105b6565b80820180821115610307576103076105b656fea264697066735822122084ca443d97b3271c715ed62fcad694ee7a1a98607b036f06e1a648531aeb1bc264736f6c63430008120033"
+
+func TestWebhook(t *testing.T) {
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+
+	secret := "webhook-test-secret"
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get("X-Safe-Timestamp"), 10, 64)
+		if r.Header.Get("X-Safe-Signature") != SignWebhookPayload(secret, ts, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received = append(received, r.Header.Get("X-Safe-Event"))
+	}))
+	defer server.Close()
+
+	err = node.store.SyncWebhookEndpoints(ctx, []string{server.URL})
+	require.Nil(err)
+	now := time.Now().UTC()
+	err = node.store.WriteAccountProposalIfNotExists(ctx, testSafeAddress, now)
+	require.Nil(err)
+	err = node.store.WriteAccountProposalIfNotExists(ctx, testSafeAddress, now)
+	require.Nil(err)
+	err = node.store.MarkAccountApproved(ctx, testSafeAddress)
+	require.Nil(err)
+	events, err := node.store.ListDueWebhookEvents(ctx, server.URL, time.Now().UTC(), 100)
+	require.Nil(err)
+	require.Len(events, 2)
+	require.Equal(WebhookAccountProposed, events[0].Event)
+	require.Equal(WebhookAccountApproved, events[1].Event)
+
+	client := &http.Client{Timeout: time.Second}
+	endpoint := &WebhookEndpoint{URL: server.URL, Secret: secret}
+	err = node.deliverWebhookEvent(ctx, client, endpoint, events[0])
+	require.Nil(err)
+	require.Equal([]string{WebhookAccountProposed}, received)
+	err = node.store.FinishWebhookEvent(ctx, events[0], common.RequestStateDone)
+	require.Nil(err)
+
+	endpoint.Secret = "webhook-wrong-secret"
+	node.handleWebhookEvent(ctx, client, endpoint, events[1])
+	require.Len(received, 1)
+	events, err = node.store.ListDueWebhookEvents(ctx, server.URL, time.Now().UTC(), 100)
+	require.Nil(err)
+	require.Len(events, 0)
+	events, err = node.store.ListDueWebhookEvents(ctx, server.URL, time.Now().UTC().Add(time.Hour), 100)
+	require.Nil(err)
+	require.Len(events, 1)
+	require.Equal(1, events[0].Attempts)
+
+	// the same event of the same subject is queued again for another change
+	for _, ref := range []string{"first", "second", "second"} {
+		tx, err := node.store.db.BeginTx(ctx, nil)
+		require.Nil(err)
+		err = node.store.writeWebhookEvent(ctx, tx, WebhookRecoveryInitial, testSafeAddress, ref, map[string]any{})
+		require.Nil(err)
+		err = tx.Commit()
+		require.Nil(err)
+	}
+	events, err = node.store.ListDueWebhookEvents(ctx, server.URL, time.Now().UTC(), 100)
+	require.Nil(err)
+	require.Len(events, 2)
+	require.NotEqual(events[0].EventId, events[1].EventId)
+
+	err = node.store.SyncWebhookEndpoints(ctx, nil)
+	require.Nil(err)
+	events, err = node.store.ListDueWebhookEvents(ctx, server.URL, time.Now().UTC().Add(time.Hour), 100)
+	require.Nil(err)
+	require.Len(events, 0)
+}
+
+func TestListPagination(t *testing.T) {
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	now := time.Now().UTC()
+	for i := 0; i < 5; i++ {
+		created := now
+		if i == 4 {
+			created = now.Add(time.Minute)
+		}
+		err = node.store.WriteTransactionApprovalIfNotExists(ctx, &Transaction{
+			TransactionHash: hex.EncodeToString(crypto.Keccak256([]byte(strconv.Itoa(i)))),
+			RawTransaction:  "raw",
+			Chain:           common.SafeChainBitcoin,
+			Holder:          holder,
+			Signer:          holder,
+			State:           common.RequestStateInitial,
+			CreatedAt:       created,
+			UpdatedAt:       created,
+		})
+		require.Nil(err)
+	}
+
+	query := make(map[string][]string)
+	query["holder"] = []string{holder}
+	query["limit"] = []string{"2"}
+	seen := make(map[string]bool)
+	for range 3 {
+		f, err := parseListFilter(query, "chain", "holder")
+		require.Nil(err)
+		txs, err := node.store.ListTransactionApprovalsWithFilter(ctx, f)
+		require.Nil(err)
+		for _, tx := range txs {
+			require.False(seen[tx.TransactionHash])
+			seen[tx.TransactionHash] = true
+		}
+		if len(txs) < f.Limit {
+			break
+		}
+		last := txs[len(txs)-1]
+		cursor, err := ParseListCursor((&ListCursor{Time: last.CreatedAt, Key: last.TransactionHash}).String())
+		require.Nil(err)
+		require.Equal(last.TransactionHash, cursor.Key)
+		query["cursor"] = []string{cursor.String()}
+	}
+	require.Len(seen, 5)
+
+	hash := hex.EncodeToString(crypto.Keccak256([]byte("deposit")))
+	for i := 0; i < 3; i++ {
+		err = node.store.WritePendingDepositIfNotExists(ctx, &Deposit{
+			TransactionHash: hash,
+			OutputIndex:     int64(i),
+			AssetId:         common.SafeBitcoinChainId,
+			AssetAddress:    "",
+			Amount:          "0.001",
+			Receiver:        testSafeAddress,
+			Sender:          testSafeAddress,
+			State:           common.RequestStateInitial,
+			Chain:           common.SafeChainBitcoin,
+			Holder:          holder,
+			Category:        common.ActionObserverHolderDeposit,
+			RequestId:       strconv.Itoa(i),
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+		require.Nil(err)
+	}
+	query = map[string][]string{"holder": {holder}, "asset": {common.SafeBitcoinChainId}, "state": {"initial"}, "limit": {"2"}}
+	f, err := parseListFilter(query, "chain", "holder", "asset")
+	require.Nil(err)
+	deposits, err := node.store.ListDepositsWithFilter(ctx, f)
+	require.Nil(err)
+	require.Len(deposits, 2)
+	require.Equal(int64(1), deposits[1].OutputIndex)
+	// the update of a listed deposit doesn't move it after the cursor
+	err = node.store.UpdateDepositRequestId(ctx, hash, 0, "0", "updated")
+	require.Nil(err)
+	query["cursor"] = []string{(&ListCursor{Time: deposits[1].CreatedAt, Key: fmt.Sprintf("%s:%d", hash, 1)}).String()}
+	f, err = parseListFilter(query, "chain", "holder", "asset")
+	require.Nil(err)
+	deposits, err = node.store.ListDepositsWithFilter(ctx, f)
+	require.Nil(err)
+	require.Len(deposits, 1)
+	require.Equal(int64(2), deposits[0].OutputIndex)
+	query["cursor"] = []string{(&ListCursor{Time: now, Key: hash}).String()}
+	f, err = parseListFilter(query, "chain", "holder", "asset")
+	require.Nil(err)
+	_, err = node.store.ListDepositsWithFilter(ctx, f)
+	require.NotNil(err)
+
+	_, err = parseListFilter(map[string][]string{"asset": {common.SafeBitcoinChainId}}, "chain", "holder")
+	require.ErrorContains(err, "unsupported filter asset")
+	_, err = parseListFilter(map[string][]string{"holder": {holder}})
+	require.ErrorContains(err, "unsupported filter holder")
+	_, err = parseListFilter(map[string][]string{"chain": {"1"}})
+	require.ErrorContains(err, "unsupported filter chain")
+
+	f, err = parseListFilter(map[string][]string{"state": {"done"}})
+	require.Nil(err)
+	txs, err := node.store.ListTransactionApprovalsWithFilter(ctx, f)
+	require.Nil(err)
+	require.Len(txs, 0)
+	f, err = parseListFilter(map[string][]string{"since": {now.Add(time.Second).Format(time.RFC3339Nano)}})
+	require.Nil(err)
+	txs, err = node.store.ListTransactionApprovalsWithFilter(ctx, f)
+	require.Nil(err)
+	require.Len(txs, 1)
+	_, err = parseListFilter(map[string][]string{"limit": {"1000"}})
+	require.NotNil(err)
+	f, err = parseListFilter(map[string][]string{"state": {"unknown"}})
+	require.Nil(err)
+	_, err = node.store.ListTransactionApprovalsWithFilter(ctx, f)
+	require.NotNil(err)
+}
+
+func TestKeySigner(t *testing.T) {
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+
+	accountant, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	address, err := bitcoin.EncodeAccountantAddress(accountant.PubKey().SerializeCompressed(), common.SafeChainBitcoin)
+	require.Nil(err)
+	err = node.store.WriteAccountantKeys(ctx, common.CurveSecp256k1ECDSABitcoin, map[string]*btcec.PrivateKey{address: accountant})
+	require.Nil(err)
+	public, sign, err := node.bitcoinAccountantSigner(ctx, address, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(hex.EncodeToString(accountant.PubKey().SerializeCompressed()), public)
+	hash := crypto.Keccak256([]byte("accountant"))
+	sig, err := sign(hash)
+	require.Nil(err)
+	require.Nil(bitcoin.VerifySignatureDER(public, hash, sig))
+	_, _, err = node.bitcoinAccountantSigner(ctx, testSafeAddress, common.SafeChainBitcoin)
+	require.NotNil(err)
+
+	observer, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	observerPublic := hex.EncodeToString(observer.PubKey().SerializeCompressed())
+	chainCode := crypto.Keccak256([]byte("chain code"))
+	list := fmt.Sprintf("%s:%x:00000000:%x\n%s:%x\n", observerPublic, chainCode, observer.Serialize(), public, accountant.Serialize())
+	err = os.WriteFile(root+"/keys", []byte(list), 0600)
+	require.Nil(err)
+	fs, err := NewKeySigner("file:"+root+"/keys", node.store)
+	require.Nil(err)
+
+	ln, err := net.Listen("unix", root+"/signer.sock")
+	require.Nil(err)
+	defer ln.Close()
+	go ServeKeySigner(ctx, ln, fs)
+	ss, err := NewKeySigner("unix:"+root+"/signer.sock", node.store)
+	require.Nil(err)
+
+	_, derived, err := bitcoin.DeriveBIP32(observerPublic, chainCode, 0, 0, 0)
+	require.Nil(err)
+	for _, signer := range []KeySigner{fs, ss} {
+		sig, err := signer.Sign(ctx, &SignRequest{
+			Curve:     common.CurveSecp256k1ECDSABitcoin,
+			Public:    observerPublic,
+			ChainCode: chainCode,
+			Path:      []uint32{0, 0, 0},
+			Hash:      hash,
+		})
+		require.Nil(err)
+		require.Nil(bitcoin.VerifySignatureDER(derived, hash, sig))
+		sig, err = signer.Sign(ctx, &SignRequest{
+			Curve:     common.CurveSecp256k1SchnorrBitcoin,
+			Public:    observerPublic,
+			ChainCode: chainCode,
+			Path:      []uint32{0, 0, 0},
+			Hash:      hash,
+		})
+		require.Nil(err)
+		require.Len(sig, 64)
+		require.Nil(bitcoin.VerifySignatureSchnorr(derived, hash, sig))
+
+		msg := []byte("observer")
+		sig, err = signer.Sign(ctx, &SignRequest{
+			Curve:  common.CurveSecp256k1ECDSAPolygon,
+			Public: observerPublic,
+			Hash:   ethereum.HashMessageForSignature(hex.EncodeToString(msg)),
+		})
+		require.Nil(err)
+		require.Nil(ethereum.VerifyMessageSignature(observerPublic, msg, ethereum.ProcessSignature(sig)))
+
+		sig, err = signer.Sign(ctx, &SignRequest{
+			Curve:  common.CurveSecp256k1ECDSALitecoin,
+			Public: public,
+			Hash:   hash,
+		})
+		require.Nil(err)
+		require.Nil(bitcoin.VerifySignatureDER(public, hash, sig))
+
+		_, err = signer.Sign(ctx, &SignRequest{
+			Curve:  common.CurveSecp256k1ECDSABitcoin,
+			Public: testPublicKey(testBitcoinKeyHolderPrivate),
+			Hash:   hash,
+		})
+		require.NotNil(err)
+		_, err = signer.Sign(ctx, &SignRequest{
+			Curve:  common.CurveSecp256k1ECDSABitcoin,
+			Public: public,
+			Path:   []uint32{0, 0, 0},
+			Hash:   hash,
+		})
+		require.NotNil(err)
+	}
+}
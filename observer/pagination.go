@@ -0,0 +1,167 @@
+package observer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+)
+
+const (
+	listLimitDefault = 100
+	listLimitMaximum = 500
+)
+
+// ListCursor points to the last item of a page, all list queries are ordered
+// by an immutable time column and then the unique key columns, so the next
+// page starts strictly after the cursor even if many items share the same
+// time. A key of several columns is joined by colons.
+type ListCursor struct {
+	Time time.Time
+	Key  string
+}
+
+type ListFilter struct {
+	Chain   byte
+	Holder  string
+	AssetId string
+	State   string
+	Since   time.Time
+	Until   time.Time
+	Cursor  *ListCursor
+	Limit   int
+}
+
+func (c *ListCursor) String() string {
+	s := fmt.Sprintf("%d:%s", c.Time.UnixNano(), c.Key)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func ParseListCursor(s string) (*ListCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor %s", s)
+	}
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &ListCursor{Time: time.Unix(0, ns).UTC(), Key: parts[1]}, nil
+}
+
+// parseListFilter reads the common query parameters of all list endpoints,
+// the legacy offset in nanoseconds is still accepted as the since filter.
+// The chain, holder and asset filters are rejected unless the listing
+// supports them, so they are never silently ignored.
+func parseListFilter(query url.Values, filters ...string) (*ListFilter, error) {
+	for _, k := range []string{"chain", "holder", "asset"} {
+		if query.Get(k) != "" && !slices.Contains(filters, k) {
+			return nil, fmt.Errorf("unsupported filter %s", k)
+		}
+	}
+	f := &ListFilter{
+		Holder:  query.Get("holder"),
+		AssetId: query.Get("asset"),
+		State:   query.Get("state"),
+		Limit:   listLimitDefault,
+	}
+	if c := query.Get("chain"); c != "" {
+		chain, err := strconv.ParseUint(c, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		f.Chain = byte(chain)
+	}
+	if o := query.Get("offset"); o != "" {
+		offset, err := strconv.ParseInt(o, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		f.Since = time.Unix(0, offset)
+	}
+	for k, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		v := query.Get(k)
+		if v == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, err
+		}
+		*t = ts
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > listLimitMaximum {
+			return nil, fmt.Errorf("invalid limit %s", l)
+		}
+		f.Limit = limit
+	}
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := ParseListCursor(c)
+		if err != nil {
+			return nil, err
+		}
+		f.Cursor = cursor
+	}
+	return f, nil
+}
+
+// stateValue returns the request state of the filter, or zero if no state
+// filter is given, and an error for the unknown state names
+func (f *ListFilter) stateValue() (int, error) {
+	switch f.State {
+	case "":
+		return 0, nil
+	case "initial":
+		return common.RequestStateInitial, nil
+	case "pending":
+		return common.RequestStatePending, nil
+	case "done":
+		return common.RequestStateDone, nil
+	case "failed":
+		return common.RequestStateFailed, nil
+	}
+	return 0, fmt.Errorf("invalid state %s", f.State)
+}
+
+// build appends the time range and cursor conditions of the filter to the
+// query conditions, then the ordering and the limit
+func (f *ListFilter) build(conds []string, params []any, timeCol string, keyCols ...string) (string, []any, error) {
+	if !f.Since.IsZero() {
+		conds = append(conds, timeCol+">=?")
+		params = append(params, f.Since)
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, timeCol+"<?")
+		params = append(params, f.Until)
+	}
+	cols := append([]string{timeCol}, keyCols...)
+	if f.Cursor != nil {
+		keys := strings.SplitN(f.Cursor.Key, ":", len(keyCols))
+		if len(keys) != len(keyCols) {
+			return "", nil, fmt.Errorf("invalid cursor %s", f.Cursor)
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",")
+		conds = append(conds, fmt.Sprintf("(%s)>(%s)", strings.Join(cols, ","), marks))
+		params = append(params, f.Cursor.Time)
+		for _, k := range keys {
+			params = append(params, k)
+		}
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+	order := strings.Join(cols, " ASC, ") + " ASC"
+	return fmt.Sprintf("%s ORDER BY %s LIMIT %d", where, order, f.Limit), params, nil
+}
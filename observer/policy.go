@@ -0,0 +1,83 @@
+package observer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/gofrs/uuid/v5"
+)
+
+// The holder signs the request id together with the exact policy JSON, and
+// both are forwarded to the keeper, which verifies the signature again and
+// decides when the policy takes effect.
+func (node *Node) httpSetSafePolicy(ctx context.Context, addr, id, policy, signature string) error {
+	logger.Printf("node.httpSetSafePolicy(%s, %s, %s, %s)", addr, id, policy, signature)
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if safe == nil || safe.State != keeper.SafeStateApproved {
+		return fmt.Errorf("HTTP: %d", http.StatusNotFound)
+	}
+	rid, err := uuid.FromString(id)
+	if err != nil || len(policy) == 0 {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	var sig []byte
+	ms := fmt.Sprintf("POLICY:%s:%x", rid.String(), sha256.Sum256([]byte(policy)))
+	switch common.SafeChainFamily(safe.Chain) {
+	case common.SafeChainBitcoin:
+		sig, err = base64.RawURLEncoding.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		hash := bitcoin.HashMessageForSignature(ms, safe.Chain)
+		err = bitcoin.VerifySignatureDER(safe.Holder, hash, sig)
+		logger.Printf("bitcoin.VerifySignatureDER(%v) => %v", safe, err)
+	case common.SafeChainEthereum:
+		sig, err = hex.DecodeString(signature)
+		if err != nil || len(sig) < 64 {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		err = ethereum.VerifyMessageSignature(safe.Holder, []byte(ms), sig)
+		logger.Printf("ethereum.VerifyMessageSignature(%v) => %v", safe, err)
+	default:
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	if err != nil {
+		return err
+	}
+	if len(sig) > 255 {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	raw := append([]byte{byte(len(sig))}, sig...)
+	raw = append(raw, policy...)
+	rawId := common.UniqueId(rid.String(), policy)
+	raw = append(uuid.Must(uuid.FromString(rawId)).Bytes(), raw...)
+	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
+	msg := base64.RawURLEncoding.EncodeToString(raw)
+	traceId := common.UniqueId(msg, msg)
+	ref, err := common.WriteStorageUntilSufficient(ctx, node.mixin, raw, traceId, node.safeUser())
+	logger.Printf("common.WriteStorageUntilSufficient(%s) => %s %v", traceId, ref, err)
+	if err != nil {
+		return err
+	}
+
+	action := common.ActionObserverSetSpendingPolicy
+	references := []crypto.Hash{ref}
+	err = node.sendKeeperResponseWithReferences(ctx, safe.Holder, byte(action), safe.Chain, rid.String(), ref[:], references)
+	logger.Printf("node.sendKeeperResponseWithReferences(%s, %d, %s, %s)", safe.Holder, action, rid, ref)
+	return err
+}
@@ -58,6 +58,8 @@ CREATE TABLE IF NOT EXISTS accounts (
   PRIMARY KEY ('address')
 );
 
+CREATE INDEX IF NOT EXISTS accounts_by_created_address ON accounts(created_at, address);
+
 
 
 
@@ -83,6 +85,7 @@ CREATE INDEX IF NOT EXISTS deposits_by_chain_state_created ON deposits(chain, st
 CREATE INDEX IF NOT EXISTS deposits_by_holder_asset_state_created ON deposits(holder, asset_id, state, created_at);
 CREATE INDEX IF NOT EXISTS deposits_by_chain_state_updated ON deposits(chain, state, updated_at);
 CREATE INDEX IF NOT EXISTS deposits_by_holder_asset_state_updated ON deposits(holder, asset_id, state, updated_at);
+CREATE INDEX IF NOT EXISTS deposits_by_created_hash_index ON deposits(created_at, transaction_hash, output_index);
 
 
 
@@ -102,6 +105,8 @@ CREATE TABLE IF NOT EXISTS transactions (
 );
 
 CREATE INDEX IF NOT EXISTS transactions_by_chain_state_created ON transactions(chain, state, created_at);
+CREATE INDEX IF NOT EXISTS transactions_by_holder_state_created ON transactions(holder, state, created_at);
+CREATE INDEX IF NOT EXISTS transactions_by_created_hash ON transactions(created_at, transaction_hash);
 
 
 
@@ -121,6 +126,23 @@ CREATE TABLE IF NOT EXISTS bitcoin_outputs (
 );
 
 CREATE INDEX IF NOT EXISTS bitcoin_outputs_by_chain_state_created ON bitcoin_outputs(chain, state, created_at);
+CREATE INDEX IF NOT EXISTS bitcoin_outputs_by_spent ON bitcoin_outputs(spent_by);
+
+
+
+
+CREATE TABLE IF NOT EXISTS fee_bumps (
+  bump_hash          VARCHAR NOT NULL,
+  transaction_hash   VARCHAR NOT NULL,
+  method             VARCHAR NOT NULL,
+  parent_hash        VARCHAR NOT NULL,
+  fee_rate           INTEGER NOT NULL,
+  fee                INTEGER NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('bump_hash')
+);
+
+CREATE INDEX IF NOT EXISTS fee_bumps_by_transaction_created ON fee_bumps(transaction_hash, created_at);
 
 
 
@@ -137,6 +159,9 @@ CREATE TABLE IF NOT EXISTS recoveries (
   PRIMARY KEY ('address')
 );
 
+CREATE INDEX IF NOT EXISTS recoveries_by_state_created_address ON recoveries(state, created_at, address);
+CREATE INDEX IF NOT EXISTS recoveries_by_holder_created_address ON recoveries(holder, created_at, address);
+
 
 
 CREATE TABLE IF NOT EXISTS nodes (
@@ -146,3 +171,28 @@ CREATE TABLE IF NOT EXISTS nodes (
   updated_at         TIMESTAMP NOT NULL,
   PRIMARY KEY ('app_id', 'node_type')
 );
+
+
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+  url                VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('url')
+);
+
+
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+  event_id           VARCHAR NOT NULL,
+  endpoint           VARCHAR NOT NULL,
+  event              VARCHAR NOT NULL,
+  payload            TEXT NOT NULL,
+  state              INTEGER NOT NULL,
+  attempts           INTEGER NOT NULL,
+  next_at            TIMESTAMP NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('event_id', 'endpoint')
+);
+
+CREATE INDEX IF NOT EXISTS webhook_events_by_endpoint_state_next ON webhook_events(endpoint, state, next_at);
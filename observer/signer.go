@@ -0,0 +1,335 @@
+package observer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// KeySigner signs with the observer and accountant private keys, so that
+// they could be kept out of the observer node, e.g. by a hardware module
+// behind a signing service. The mixin view keys are not spending keys and
+// still kept in the observer store.
+type KeySigner interface {
+	Sign(ctx context.Context, req *SignRequest) ([]byte, error)
+}
+
+// SignRequest asks for the signature of the hash by the key of the public,
+// or by its BIP32 child when the path is not empty. The signature is DER
+// encoded for bitcoin curves, 64 bytes BIP340 for the schnorr curve, and 65
+// bytes with recovery id for ethereum.
+type SignRequest struct {
+	Curve     byte     `json:"curve"`
+	Public    string   `json:"public"`
+	ChainCode []byte   `json:"chain_code,omitempty"`
+	Path      []uint32 `json:"path,omitempty"`
+	Hash      []byte   `json:"hash"`
+}
+
+type signResponse struct {
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NewKeySigner parses the key-signer option of the configuration, it is
+// either empty to use the accountant keys in the store, "file:PATH" for the
+// keys list file, or "unix:PATH" for a signing service on the socket.
+func NewKeySigner(option string, db *SQLite3Store) (KeySigner, error) {
+	switch {
+	case option == "":
+		return &storeKeySigner{store: db}, nil
+	case strings.HasPrefix(option, "file:"):
+		return NewFileKeySigner(strings.TrimPrefix(option, "file:"))
+	case strings.HasPrefix(option, "unix:"):
+		return NewSocketKeySigner(strings.TrimPrefix(option, "unix:")), nil
+	}
+	return nil, fmt.Errorf("invalid key signer %s", option)
+}
+
+type storeKeySigner struct {
+	store *SQLite3Store
+}
+
+func (s *storeKeySigner) Sign(ctx context.Context, req *SignRequest) ([]byte, error) {
+	priv, err := s.store.ReadAccountantPrivateKeyByPublic(ctx, req.Public)
+	if err != nil || priv == "" {
+		return nil, fmt.Errorf("store.ReadAccountantPrivateKeyByPublic(%s) => %v", req.Public, err)
+	}
+	if len(req.Path) > 0 {
+		return nil, fmt.Errorf("accountant key %s derivation", req.Public)
+	}
+	return signHashWithKey(common.DecodeHexOrPanic(priv), req)
+}
+
+// FileKeySigner is the software signer with the keys list file, each line is
+// the public and private key separated by colons, and the optional chain code
+// in the second field as the output of the observer keys generator.
+type FileKeySigner struct {
+	keys map[string]*fileKey
+}
+
+type fileKey struct {
+	private   []byte
+	chainCode []byte
+}
+
+func NewFileKeySigner(path string) (*FileKeySigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileKeySigner{keys: make(map[string]*fileKey)}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		items := strings.Split(line, ":")
+		if len(items) < 2 {
+			return nil, fmt.Errorf("invalid key line %s", line)
+		}
+		priv, err := hex.DecodeString(items[len(items)-1])
+		if err != nil || len(priv) != 32 {
+			return nil, fmt.Errorf("invalid private key of %s", items[0])
+		}
+		_, pub := btcec.PrivKeyFromBytes(priv)
+		if hex.EncodeToString(pub.SerializeCompressed()) != items[0] {
+			return nil, fmt.Errorf("invalid key pair %s", items[0])
+		}
+		key := &fileKey{private: priv}
+		if len(items) > 2 {
+			key.chainCode, err = hex.DecodeString(items[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid chain code of %s", items[0])
+			}
+		}
+		s.keys[items[0]] = key
+	}
+	return s, nil
+}
+
+func (s *FileKeySigner) Sign(_ context.Context, req *SignRequest) ([]byte, error) {
+	key := s.keys[req.Public]
+	if key == nil {
+		return nil, fmt.Errorf("key %s not found", req.Public)
+	}
+	if len(req.Path) == 0 {
+		return signHashWithKey(key.private, req)
+	}
+	if !bytes.Equal(key.chainCode, req.ChainCode) {
+		return nil, fmt.Errorf("invalid chain code of %s", req.Public)
+	}
+
+	parentFP := []byte{0x00, 0x00, 0x00, 0x00}
+	version := []byte{0x04, 0x88, 0xb2, 0x1e}
+	ext := hdkeychain.NewExtendedKey(version, key.private, key.chainCode, parentFP, 0, 0, true)
+	for _, i := range req.Path {
+		child, err := ext.Derive(i)
+		if err != nil {
+			return nil, err
+		}
+		ext = child
+	}
+	priv, err := ext.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	return signHashWithKey(priv.Serialize(), req)
+}
+
+// SocketKeySigner sends each request as a JSON line to the signing service
+// on the unix socket, and reads the JSON line response.
+type SocketKeySigner struct {
+	path string
+}
+
+func NewSocketKeySigner(path string) *SocketKeySigner {
+	return &SocketKeySigner{path: path}
+}
+
+func (s *SocketKeySigner) Sign(ctx context.Context, req *SignRequest) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var res signResponse
+	err = json.Unmarshal(line, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("key signer %s", res.Error)
+	}
+	return res.Signature, nil
+}
+
+// ServeKeySigner serves the signer on the listener with the protocol of the
+// SocketKeySigner, until the listener is closed.
+func ServeKeySigner(ctx context.Context, ln net.Listener, signer KeySigner) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			serveKeySignerConn(ctx, conn, signer)
+		}()
+	}
+}
+
+func serveKeySignerConn(ctx context.Context, conn net.Conn, signer KeySigner) {
+	var res signResponse
+	var req SignRequest
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err == nil {
+		res.Signature, err = signer.Sign(ctx, &req)
+		logger.Printf("KeySigner.Sign(%d, %s, %v, %x) => %v", req.Curve, req.Public, req.Path, req.Hash, err)
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	_ = json.NewEncoder(conn).Encode(res)
+}
+
+func (node *Node) bitcoinAccountantSigner(ctx context.Context, address string, chain byte) (string, func([]byte) ([]byte, error), error) {
+	public, err := node.store.ReadAccountantPublicKey(ctx, address)
+	if err != nil || public == "" {
+		return "", nil, fmt.Errorf("store.ReadAccountantPublicKey(%s) => %s %v", address, public, err)
+	}
+	return public, func(hash []byte) ([]byte, error) {
+		return node.signer.Sign(ctx, &SignRequest{
+			Curve:  common.SafeChainCurve(chain),
+			Public: public,
+			Hash:   hash,
+		})
+	}, nil
+}
+
+// bitcoinSignObserverInputs adds the signatures of the observer child key
+// opk, derived with the safe path, to all inputs of the recovery transaction.
+func (node *Node) bitcoinSignObserverInputs(ctx context.Context, safe *store.Safe, opk string, psTx *bitcoin.PartiallySignedTransaction) error {
+	req := &SignRequest{Public: safe.Observer}
+	path8 := common.DecodeHexOrPanic(safe.Path)
+	for i := 0; i < int(path8[0]); i++ {
+		req.Path = append(req.Path, uint32(path8[1+i]))
+	}
+	if len(req.Path) > 0 {
+		sk, err := node.keeperStore.ReadKey(ctx, safe.Observer)
+		if err != nil || sk == nil {
+			return fmt.Errorf("keeperStore.ReadKey(%s) => %v %v", safe.Observer, sk, err)
+		}
+		req.ChainCode = common.DecodeHexOrPanic(sk.Extra)
+	}
+
+	for idx := range psTx.UnsignedTx.TxIn {
+		// the taproot recovery leaf checks the BIP340 signature of the observer
+		req.Curve = common.SafeChainCurve(safe.Chain)
+		if psTx.IsTaprootInput(idx) {
+			req.Curve = common.CurveSecp256k1SchnorrBitcoin
+		}
+		req.Hash = psTx.SigHash(idx)
+		sig, err := node.signer.Sign(ctx, req)
+		if err != nil {
+			return err
+		}
+		err = psTx.VerifyInputSignature(idx, opk, sig)
+		if err != nil {
+			return err
+		}
+		psTx.AddInputSignature(idx, opk, sig)
+	}
+	return nil
+}
+
+// ethereumSignObserverTransaction puts the observer signature to the observer
+// position of the sorted safe owners.
+func (node *Node) ethereumSignObserverTransaction(ctx context.Context, safe *store.Safe, st *ethereum.SafeTransaction) error {
+	sig, err := node.signer.Sign(ctx, &SignRequest{
+		Curve:  common.SafeChainCurve(safe.Chain),
+		Public: safe.Observer,
+		Hash:   ethereum.HashMessageForSignature(hex.EncodeToString(st.Message)),
+	})
+	if err != nil {
+		return err
+	}
+	sig = ethereum.ProcessSignature(sig)
+	err = ethereum.VerifyMessageSignature(safe.Observer, st.Message, sig)
+	if err != nil {
+		return err
+	}
+	_, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
+	for i, pub := range pubs {
+		if pub == safe.Observer {
+			st.Signatures[i] = sig
+			return nil
+		}
+	}
+	return fmt.Errorf("observer %s not in owners", safe.Observer)
+}
+
+func signHashWithKey(priv []byte, req *SignRequest) ([]byte, error) {
+	if len(req.Hash) != 32 {
+		return nil, fmt.Errorf("invalid hash %x", req.Hash)
+	}
+	switch common.NormalizeCurve(req.Curve) {
+	case common.CurveSecp256k1ECDSABitcoin:
+		pk, _ := btcec.PrivKeyFromBytes(priv)
+		return ecdsa.Sign(pk, req.Hash).Serialize(), nil
+	case common.CurveSecp256k1SchnorrBitcoin:
+		pk, _ := btcec.PrivKeyFromBytes(priv)
+		sig, err := schnorr.Sign(pk, req.Hash)
+		if err != nil {
+			return nil, err
+		}
+		return sig.Serialize(), nil
+	case common.CurveSecp256k1ECDSAEthereum:
+		pk, err := crypto.ToECDSA(priv)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Sign(req.Hash, pk)
+	}
+	return nil, fmt.Errorf("invalid curve %d", req.Curve)
+}
@@ -51,6 +51,8 @@ type Deposit struct {
 	RequestId       string
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
+
+	Mask []byte
 }
 
 type Transaction struct {
@@ -79,6 +81,23 @@ type Output struct {
 	UpdatedAt       time.Time
 }
 
+const (
+	FeeBumpMethodRBF  = "rbf"
+	FeeBumpMethodCPFP = "cpfp"
+)
+
+// FeeBump is either a replacement of the spent transaction with an additional
+// fee input, or a child spending the accountant change of its fee input parent.
+type FeeBump struct {
+	BumpHash        string
+	TransactionHash string
+	Method          string
+	ParentHash      string
+	FeeRate         int64
+	Fee             int64
+	CreatedAt       time.Time
+}
+
 type Recovery struct {
 	Address         string
 	Chain           byte
@@ -91,6 +110,18 @@ type Recovery struct {
 	UpdatedAt       time.Time
 }
 
+type WebhookEvent struct {
+	EventId   string
+	Endpoint  string
+	Event     string
+	Payload   string
+	State     int
+	Attempts  int
+	NextAt    time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type NodeStats struct {
 	AppId     string
 	Type      string
@@ -120,6 +151,12 @@ func (o *Output) values() []any {
 	return []any{o.TransactionHash, o.Index, o.Address, o.Satoshi, o.Chain, o.State, o.SpentBy, o.RawTransaction, o.CreatedAt, o.UpdatedAt}
 }
 
+var feeBumpCols = []string{"bump_hash", "transaction_hash", "method", "parent_hash", "fee_rate", "fee", "created_at"}
+
+func (b *FeeBump) values() []any {
+	return []any{b.BumpHash, b.TransactionHash, b.Method, b.ParentHash, b.FeeRate, b.Fee, b.CreatedAt}
+}
+
 var recoveryCols = []string{"address", "chain", "holder", "observer", "raw_transaction", "transaction_hash", "state", "created_at", "updated_at"}
 
 func (r *Recovery) values() []any {
@@ -128,6 +165,8 @@ func (r *Recovery) values() []any {
 
 var nodeCols = []string{"app_id", "node_type", "stats", "updated_at"}
 
+var webhookEventCols = []string{"event_id", "endpoint", "event", "payload", "state", "attempts", "next_at", "created_at", "updated_at"}
+
 func (n *NodeStats) values() []any {
 	return []any{n.AppId, n.Type, n.Stats, n.UpdatedAt}
 }
@@ -160,7 +199,7 @@ func (t *Transaction) Signers(ctx context.Context, node *Node, safe *store.Safe)
 
 	opk, spk := safe.Observer, safe.Signer
 	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainBitcoinCash:
 		var err error
 		opk, err = node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 		if err != nil {
@@ -175,10 +214,10 @@ func (t *Transaction) Signers(ctx context.Context, node *Node, safe *store.Safe)
 	pubs := []string{t.Holder, spk, opk}
 	for idx, pub := range pubs {
 		isSigned := false
-		switch safe.Chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		switch common.SafeChainFamily(safe.Chain) {
+		case common.SafeChainBitcoin:
 			isSigned = bitcoin.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
-		case common.SafeChainPolygon, common.SafeChainEthereum:
+		case common.SafeChainEthereum:
 			isSigned = ethereum.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
 		default:
 			panic(safe.Chain)
@@ -218,6 +257,11 @@ func (s *SQLite3Store) WriteAccountProposalIfNotExists(ctx context.Context, addr
 		return fmt.Errorf("INSERT accounts %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookAccountProposed, address, "", map[string]any{"address": address})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -306,6 +350,11 @@ func (s *SQLite3Store) MarkAccountApproved(ctx context.Context, addr string) err
 		return fmt.Errorf("UPDATE accounts %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookAccountApproved, addr, "", map[string]any{"address": addr})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -335,6 +384,11 @@ func (s *SQLite3Store) MarkAccountDeployed(ctx context.Context, addr string) err
 		return fmt.Errorf("UPDATE accounts %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookAccountDeployed, addr, "", map[string]any{"address": addr})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -467,6 +521,19 @@ func (s *SQLite3Store) WritePendingDepositIfNotExists(ctx context.Context, d *De
 		return fmt.Errorf("INSERT deposits %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookDepositPending, fmt.Sprintf("%s:%d", d.TransactionHash, d.OutputIndex), d.RequestId, map[string]any{
+		"transaction_hash": d.TransactionHash,
+		"output_index":     d.OutputIndex,
+		"asset_id":         d.AssetId,
+		"amount":           d.Amount,
+		"receiver":         d.Receiver,
+		"chain":            d.Chain,
+		"holder":           d.Holder,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -505,6 +572,15 @@ func (s *SQLite3Store) ConfirmPendingDeposit(ctx context.Context, transactionHas
 		return fmt.Errorf("UPDATE deposits %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookDepositConfirmed, fmt.Sprintf("%s:%d", transactionHash, outputIndex), rid, map[string]any{
+		"transaction_hash": transactionHash,
+		"output_index":     outputIndex,
+		"request_id":       rid,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -524,6 +600,14 @@ func (s *SQLite3Store) ConfirmFullySignedTransactionApproval(ctx context.Context
 		return fmt.Errorf("UPDATE transactions %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookTransactionSpent, hash, spentHash, map[string]any{
+		"transaction_hash": hash,
+		"spent_hash":       spentHash,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -543,6 +627,11 @@ func (s *SQLite3Store) RefundFullySignedTransactionApproval(ctx context.Context,
 		return fmt.Errorf("UPDATE transactions %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookTransactionRefunded, hash, hash, map[string]any{"transaction_hash": hash})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -566,6 +655,88 @@ func (s *SQLite3Store) ListFullySignedTransactionApprovals(ctx context.Context,
 	return approvals, nil
 }
 
+func (s *SQLite3Store) ListSpentTransactionApprovals(ctx context.Context, chain byte, offset time.Time) ([]*Transaction, error) {
+	query := fmt.Sprintf("SELECT %s FROM transactions WHERE chain=? AND state=? AND spent_hash IS NOT NULL AND updated_at>=? ORDER BY updated_at ASC", strings.Join(transactionCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStateDone, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []*Transaction
+	for rows.Next() {
+		var t Transaction
+		err = rows.Scan(&t.TransactionHash, &t.RawTransaction, &t.Chain, &t.Holder, &t.Signer, &t.State, &t.SpentHash, &t.SpentRaw, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, &t)
+	}
+	return approvals, nil
+}
+
+func (s *SQLite3Store) ReplaceSpentTransactionApproval(ctx context.Context, bump *FeeBump, spentRaw string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if bump.Method != FeeBumpMethodRBF {
+		panic(bump.Method)
+	}
+	query := "UPDATE transactions SET spent_hash=?, spent_raw=?, updated_at=? WHERE transaction_hash=? AND state=? AND spent_hash=?"
+	err = s.execOne(ctx, tx, query, bump.BumpHash, spentRaw, bump.CreatedAt, bump.TransactionHash, common.RequestStateDone, bump.ParentHash)
+	if err != nil {
+		return fmt.Errorf("UPDATE transactions %v", err)
+	}
+
+	// the change outputs of the replaced transaction will never confirm
+	_, err = tx.ExecContext(ctx, "DELETE FROM deposits WHERE transaction_hash=? AND state=?", bump.ParentHash, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("DELETE deposits %v", err)
+	}
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("fee_bumps", feeBumpCols), bump.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT fee_bumps %v", err)
+	}
+
+	err = s.writeWebhookEvent(ctx, tx, WebhookTransactionSpent, bump.TransactionHash, bump.BumpHash, map[string]any{
+		"transaction_hash": bump.TransactionHash,
+		"spent_hash":       bump.BumpHash,
+		"replaced_hash":    bump.ParentHash,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListFeeBumps(ctx context.Context, transactionHash string) ([]*FeeBump, error) {
+	query := fmt.Sprintf("SELECT %s FROM fee_bumps WHERE transaction_hash=? ORDER BY created_at ASC", strings.Join(feeBumpCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, transactionHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bumps []*FeeBump
+	for rows.Next() {
+		var b FeeBump
+		err = rows.Scan(&b.BumpHash, &b.TransactionHash, &b.Method, &b.ParentHash, &b.FeeRate, &b.Fee, &b.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		bumps = append(bumps, &b)
+	}
+	return bumps, nil
+}
+
 func (s *SQLite3Store) ListPendingTransactionApprovals(ctx context.Context, chain byte) ([]*Transaction, error) {
 	query := fmt.Sprintf("SELECT %s FROM transactions WHERE chain=? AND state=? ORDER BY created_at ASC", strings.Join(transactionCols, ","))
 	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStatePending)
@@ -618,6 +789,15 @@ func (s *SQLite3Store) WriteTransactionApprovalIfNotExists(ctx context.Context,
 		return fmt.Errorf("INSERT transactions %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookTransactionProposed, approval.TransactionHash, approval.TransactionHash, map[string]any{
+		"transaction_hash": approval.TransactionHash,
+		"chain":            approval.Chain,
+		"holder":           approval.Holder,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -637,6 +817,11 @@ func (s *SQLite3Store) RevokeTransactionApproval(ctx context.Context, transactio
 		return fmt.Errorf("UPDATE transactions %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookTransactionRevoked, transactionHash, transactionHash, map[string]any{"transaction_hash": transactionHash})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -659,6 +844,75 @@ func (s *SQLite3Store) AddTransactionPartials(ctx context.Context, transactionHa
 	return tx.Commit()
 }
 
+// FinishTransactionKeySpendSignatures saves the transaction with the key path
+// signatures of the holder, after the keeper signed it and before it is spent
+func (s *SQLite3Store) FinishTransactionKeySpendSignatures(ctx context.Context, transactionHash string, raw string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE transactions SET raw_transaction=?, updated_at=? WHERE transaction_hash=? AND state=? AND spent_hash IS NULL",
+		raw, time.Now().UTC(), transactionHash, common.RequestStateDone)
+	if err != nil {
+		return fmt.Errorf("UPDATE transactions %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func batchApprovalKey(batchId string) string {
+	return "BATCH:APPROVAL:" + batchId
+}
+
+// ReadBatchApproval reads the holder signature of the batch digest
+func (s *SQLite3Store) ReadBatchApproval(ctx context.Context, batchId string) (string, error) {
+	return s.ReadProperty(ctx, batchApprovalKey(batchId))
+}
+
+// AddBatchTransactionPartials saves the holder signed transactions of the
+// batch together with the holder signature of the batch digest
+func (s *SQLite3Store) AddBatchTransactionPartials(ctx context.Context, batchId string, hashes, raws []string, sig string) error {
+	if len(hashes) != len(raws) {
+		panic(len(raws))
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, hash := range hashes {
+		err = s.execOne(ctx, tx, "UPDATE transactions SET raw_transaction=?, updated_at=? WHERE transaction_hash=? AND state=?",
+			raws[i], time.Now().UTC(), hash, common.RequestStateInitial)
+		if err != nil {
+			return fmt.Errorf("UPDATE transactions %v", err)
+		}
+	}
+
+	key, now := batchApprovalKey(batchId), time.Now().UTC()
+	existed, err := s.checkExistence(ctx, tx, "SELECT value FROM properties WHERE key=?", key)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		cols := []string{"key", "value", "created_at", "updated_at"}
+		err = s.execOne(ctx, tx, buildInsertionSQL("properties", cols), key, sig, now, now)
+		if err != nil {
+			return fmt.Errorf("INSERT properties %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) MarkTransactionApprovalPaid(ctx context.Context, transactionHash string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -713,6 +967,11 @@ func (s *SQLite3Store) FinishTransactionSignatures(ctx context.Context, transact
 		return fmt.Errorf("UPDATE transactions %v", err)
 	}
 
+	err = s.writeWebhookEvent(ctx, tx, WebhookTransactionApproved, transactionHash, transactionHash, map[string]any{"transaction_hash": transactionHash})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -751,6 +1010,26 @@ func (s *SQLite3Store) WriteAccountantKeys(ctx context.Context, crv byte, keys m
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) WriteMixinAccountantKey(ctx context.Context, pub, priv string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cols := []string{"public_key", "private_key", "address", "curve", "created_at"}
+	vals := []any{pub, priv, pub, common.CurveEdwards25519Mixin, time.Now().UTC()}
+	err = s.execOne(ctx, tx, buildInsertionSQL("accountants", cols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT accountants %v", err)
+	}
+
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) ReadAccountantPrivateKey(ctx context.Context, address string) (string, error) {
 	query := "SELECT private_key FROM accountants WHERE address=?"
 	row := s.db.QueryRowContext(ctx, query, address)
@@ -763,6 +1042,30 @@ func (s *SQLite3Store) ReadAccountantPrivateKey(ctx context.Context, address str
 	return key, err
 }
 
+func (s *SQLite3Store) ReadAccountantPrivateKeyByPublic(ctx context.Context, public string) (string, error) {
+	query := "SELECT private_key FROM accountants WHERE public_key=?"
+	row := s.db.QueryRowContext(ctx, query, public)
+
+	var key string
+	err := row.Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return key, err
+}
+
+func (s *SQLite3Store) ReadAccountantPublicKey(ctx context.Context, address string) (string, error) {
+	query := "SELECT public_key FROM accountants WHERE address=?"
+	row := s.db.QueryRowContext(ctx, query, address)
+
+	var key string
+	err := row.Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return key, err
+}
+
 func (s *SQLite3Store) WriteObserverKeys(ctx context.Context, crv byte, publics map[string]string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -858,6 +1161,16 @@ func (s *SQLite3Store) WriteInitialRecovery(ctx context.Context, recovery *Recov
 	if err != nil {
 		return fmt.Errorf("INSERT recoveries %v", err)
 	}
+
+	err = s.writeWebhookEvent(ctx, tx, WebhookRecoveryInitial, recovery.Address, recovery.TransactionHash, map[string]any{
+		"address": recovery.Address,
+		"chain":   recovery.Chain,
+		"holder":  recovery.Holder,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -889,6 +1202,21 @@ func (s *SQLite3Store) UpdateRecoveryState(ctx context.Context, address, raw str
 		return fmt.Errorf("UPDATE recoveries %v", err)
 	}
 
+	var hash string
+	row := tx.QueryRowContext(ctx, "SELECT transaction_hash FROM recoveries WHERE address=?", address)
+	err = row.Scan(&hash)
+	if err != nil {
+		return err
+	}
+	event := WebhookRecoveryPending
+	if state == common.RequestStateDone {
+		event = WebhookRecoveryDone
+	}
+	err = s.writeWebhookEvent(ctx, tx, event, address, hash, map[string]any{"address": address})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -924,6 +1252,272 @@ func (s *SQLite3Store) ListInitialRecoveries(ctx context.Context, offset int64)
 	return recoveries, nil
 }
 
+func (s *SQLite3Store) ListDepositsWithFilter(ctx context.Context, f *ListFilter) ([]*Deposit, error) {
+	state, err := f.stateValue()
+	if err != nil {
+		return nil, err
+	}
+	var conds []string
+	var params []any
+	if f.Chain != 0 {
+		conds, params = append(conds, "chain=?"), append(params, f.Chain)
+	}
+	if f.Holder != "" {
+		conds, params = append(conds, "holder=?"), append(params, f.Holder)
+	}
+	if f.AssetId != "" {
+		conds, params = append(conds, "asset_id=?"), append(params, f.AssetId)
+	}
+	if state != 0 {
+		conds, params = append(conds, "state=?"), append(params, state)
+	}
+	cond, params, err := f.build(conds, params, "created_at", "transaction_hash", "output_index")
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM deposits%s", strings.Join(depositsCols, ","), cond)
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deposits []*Deposit
+	for rows.Next() {
+		var d Deposit
+		err := rows.Scan(&d.TransactionHash, &d.OutputIndex, &d.AssetId, &d.AssetAddress, &d.Amount, &d.Receiver, &d.Sender, &d.State, &d.Chain, &d.Holder, &d.Category, &d.RequestId, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, &d)
+	}
+	return deposits, nil
+}
+
+// the spent state filter lists the done transactions with a spent hash
+func (s *SQLite3Store) ListTransactionApprovalsWithFilter(ctx context.Context, f *ListFilter) ([]*Transaction, error) {
+	var conds []string
+	var params []any
+	if f.Chain != 0 {
+		conds, params = append(conds, "chain=?"), append(params, f.Chain)
+	}
+	if f.Holder != "" {
+		conds, params = append(conds, "holder=?"), append(params, f.Holder)
+	}
+	if f.State == "spent" {
+		conds, params = append(conds, "state=? AND spent_hash IS NOT NULL"), append(params, common.RequestStateDone)
+	} else if state, err := f.stateValue(); err != nil {
+		return nil, err
+	} else if state != 0 {
+		conds, params = append(conds, "state=?"), append(params, state)
+	}
+	cond, params, err := f.build(conds, params, "created_at", "transaction_hash")
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM transactions%s", strings.Join(transactionCols, ","), cond)
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []*Transaction
+	for rows.Next() {
+		var t Transaction
+		err = rows.Scan(&t.TransactionHash, &t.RawTransaction, &t.Chain, &t.Holder, &t.Signer, &t.State, &t.SpentHash, &t.SpentRaw, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, &t)
+	}
+	return approvals, nil
+}
+
+func (s *SQLite3Store) ListRecoveriesWithFilter(ctx context.Context, f *ListFilter) ([]*Recovery, error) {
+	state, err := f.stateValue()
+	if err != nil {
+		return nil, err
+	}
+	var conds []string
+	var params []any
+	if f.Chain != 0 {
+		conds, params = append(conds, "chain=?"), append(params, f.Chain)
+	}
+	if f.Holder != "" {
+		conds, params = append(conds, "holder=?"), append(params, f.Holder)
+	}
+	if state != 0 {
+		conds, params = append(conds, "state=?"), append(params, state)
+	}
+	cond, params, err := f.build(conds, params, "created_at", "address")
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM recoveries%s", strings.Join(recoveryCols, ","), cond)
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recoveries []*Recovery
+	for rows.Next() {
+		var r Recovery
+		err = rows.Scan(&r.Address, &r.Chain, &r.Holder, &r.Observer, &r.RawTransaction, &r.TransactionHash, &r.State, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		recoveries = append(recoveries, &r)
+	}
+	return recoveries, nil
+}
+
+// the accounts have no request state, and are filtered by the proposed,
+// approved and deployed stages instead
+func (s *SQLite3Store) ListAccountsWithFilter(ctx context.Context, f *ListFilter) ([]*Account, error) {
+	var conds []string
+	switch f.State {
+	case "":
+	case "proposed":
+		conds = append(conds, "approved_at IS NULL")
+	case "approved":
+		conds = append(conds, "approved_at IS NOT NULL AND deployed_at IS NULL")
+	case "deployed":
+		conds = append(conds, "deployed_at IS NOT NULL")
+	default:
+		return nil, fmt.Errorf("invalid state %s", f.State)
+	}
+	cond, params, err := f.build(conds, nil, "created_at", "address")
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM accounts%s", strings.Join(accountCols, ","), cond)
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*Account
+	for rows.Next() {
+		var a Account
+		err := rows.Scan(&a.Address, &a.CreatedAt, &a.Signature, &a.ApprovedAt, &a.DeployedAt, &a.MigratedAt)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, &a)
+	}
+	return accounts, nil
+}
+
+func (s *SQLite3Store) SyncWebhookEndpoints(ctx context.Context, urls []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM webhook_endpoints")
+	if err != nil {
+		return fmt.Errorf("DELETE webhook_endpoints %v", err)
+	}
+	for _, u := range urls {
+		err = s.execOne(ctx, tx, "INSERT INTO webhook_endpoints (url, created_at) VALUES (?, ?)", u, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("INSERT webhook_endpoints %v", err)
+		}
+	}
+
+	// the queued events of the removed endpoints will never be delivered
+	query := "DELETE FROM webhook_events WHERE state=? AND endpoint NOT IN (SELECT url FROM webhook_endpoints)"
+	_, err = tx.ExecContext(ctx, query, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("DELETE webhook_events %v", err)
+	}
+	return tx.Commit()
+}
+
+// writeWebhookEvent queues the event for all registered endpoints in the
+// database transaction of the state change, the event id is derived from
+// the event, the subject and the request or transaction id of the change,
+// so a repeated state change is queued once, but a later one is not lost.
+func (s *SQLite3Store) writeWebhookEvent(ctx context.Context, tx *sql.Tx, event, subject, ref string, data map[string]any) error {
+	now := time.Now().UTC()
+	id := common.UniqueId(event, fmt.Sprintf("%s:%s", subject, ref))
+	payload := common.MarshalJSONOrPanic(map[string]any{
+		"id":         id,
+		"event":      event,
+		"data":       data,
+		"created_at": now,
+	})
+	query := fmt.Sprintf("INSERT OR IGNORE INTO webhook_events (%s) SELECT ?, url, ?, ?, ?, ?, ?, ?, ? FROM webhook_endpoints", strings.Join(webhookEventCols, ","))
+	_, err := tx.ExecContext(ctx, query, id, event, string(payload), common.RequestStateInitial, 0, now, now, now)
+	if err != nil {
+		return fmt.Errorf("INSERT webhook_events %v", err)
+	}
+	return nil
+}
+
+func (s *SQLite3Store) ListDueWebhookEvents(ctx context.Context, endpoint string, offset time.Time, limit int) ([]*WebhookEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM webhook_events WHERE endpoint=? AND state=? AND next_at<=? ORDER BY next_at ASC, created_at ASC LIMIT %d", strings.Join(webhookEventCols, ","), limit)
+	rows, err := s.db.QueryContext(ctx, query, endpoint, common.RequestStateInitial, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*WebhookEvent
+	for rows.Next() {
+		var e WebhookEvent
+		err = rows.Scan(&e.EventId, &e.Endpoint, &e.Event, &e.Payload, &e.State, &e.Attempts, &e.NextAt, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	return events, nil
+}
+
+func (s *SQLite3Store) FinishWebhookEvent(ctx context.Context, e *WebhookEvent, state int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE webhook_events SET state=?, attempts=?, updated_at=? WHERE event_id=? AND endpoint=? AND state=?",
+		state, e.Attempts+1, time.Now().UTC(), e.EventId, e.Endpoint, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE webhook_events %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) DelayWebhookEvent(ctx context.Context, e *WebhookEvent, next time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = s.execOne(ctx, tx, "UPDATE webhook_events SET attempts=?, next_at=?, updated_at=? WHERE event_id=? AND endpoint=? AND state=?",
+		e.Attempts+1, next, time.Now().UTC(), e.EventId, e.Endpoint, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE webhook_events %v", err)
+	}
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) UpsertNodeStats(ctx context.Context, appId, typ, stats string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
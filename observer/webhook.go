@@ -0,0 +1,148 @@
+package observer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+)
+
+const (
+	WebhookAccountProposed     = "account.proposed"
+	WebhookAccountApproved     = "account.approved"
+	WebhookAccountDeployed     = "account.deployed"
+	WebhookDepositPending      = "deposit.pending"
+	WebhookDepositConfirmed    = "deposit.confirmed"
+	WebhookTransactionProposed = "transaction.proposed"
+	WebhookTransactionApproved = "transaction.approved"
+	WebhookTransactionRevoked  = "transaction.revoked"
+	WebhookTransactionSpent    = "transaction.spent"
+	WebhookTransactionRefunded = "transaction.refunded"
+	WebhookRecoveryInitial     = "recovery.initial"
+	WebhookRecoveryPending     = "recovery.pending"
+	WebhookRecoveryDone        = "recovery.done"
+
+	webhookDeliveryDelay       = 3 * time.Second
+	webhookDeliveryTimeout     = 10 * time.Second
+	webhookDeliveryConcurrency = 16
+	webhookRetryMaximum        = 16
+	webhookBackoffMaximum      = time.Hour
+)
+
+type WebhookEndpoint struct {
+	URL    string `toml:"url"`
+	Secret string `toml:"secret"`
+}
+
+func (e *WebhookEndpoint) validate() error {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %s", e.URL)
+	}
+	if len(e.Secret) < 16 {
+		return fmt.Errorf("invalid webhook secret for %s", e.URL)
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of the timestamp and the
+// body joined by a dot, the receiver should recompute it with the shared
+// secret and reject stale timestamps to prevent replays.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookLoop delivers the queued events to their endpoints, the events are
+// written in the same database transactions as the state changes, so they
+// survive restarts and each of them is retried with exponential backoff.
+// Each endpoint has its own loop, so a slow endpoint never delays the others.
+func (node *Node) webhookLoop(ctx context.Context) {
+	urls := make([]string, len(node.conf.Webhooks))
+	for i := range node.conf.Webhooks {
+		urls[i] = node.conf.Webhooks[i].URL
+	}
+	err := node.store.SyncWebhookEndpoints(ctx, urls)
+	if err != nil {
+		panic(err)
+	}
+
+	client := &http.Client{Timeout: webhookDeliveryTimeout}
+	for i := range node.conf.Webhooks {
+		go node.webhookEndpointLoop(ctx, client, &node.conf.Webhooks[i])
+	}
+}
+
+// webhookEndpointLoop delivers the due events of the endpoint in parallel,
+// thus the receivers should not depend on the order of the events.
+func (node *Node) webhookEndpointLoop(ctx context.Context, client *http.Client, endpoint *WebhookEndpoint) {
+	for {
+		events, err := node.store.ListDueWebhookEvents(ctx, endpoint.URL, time.Now().UTC(), webhookDeliveryConcurrency)
+		if err != nil {
+			panic(err)
+		}
+		var wg sync.WaitGroup
+		for _, e := range events {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				node.handleWebhookEvent(ctx, client, endpoint, e)
+			}()
+		}
+		wg.Wait()
+		if len(events) == 0 {
+			time.Sleep(webhookDeliveryDelay)
+		}
+	}
+}
+
+func (node *Node) handleWebhookEvent(ctx context.Context, client *http.Client, endpoint *WebhookEndpoint, e *WebhookEvent) {
+	err := node.deliverWebhookEvent(ctx, client, endpoint, e)
+	logger.Verbosef("node.deliverWebhookEvent(%s, %s, %s) => %v", e.Endpoint, e.Event, e.EventId, err)
+	if err == nil {
+		err = node.store.FinishWebhookEvent(ctx, e, common.RequestStateDone)
+	} else if e.Attempts+1 >= webhookRetryMaximum {
+		err = node.store.FinishWebhookEvent(ctx, e, common.RequestStateFailed)
+	} else {
+		backoff := min(time.Duration(1<<e.Attempts)*10*time.Second, webhookBackoffMaximum)
+		err = node.store.DelayWebhookEvent(ctx, e, time.Now().UTC().Add(backoff))
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (node *Node) deliverWebhookEvent(ctx context.Context, client *http.Client, endpoint *WebhookEndpoint, e *WebhookEvent) error {
+	body := []byte(e.Payload)
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Safe-Event", e.Event)
+	req.Header.Set("X-Safe-Event-Id", e.EventId)
+	req.Header.Set("X-Safe-Timestamp", fmt.Sprint(timestamp))
+	req.Header.Set("X-Safe-Signature", SignWebhookPayload(endpoint.Secret, timestamp, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s => %d", endpoint.URL, resp.StatusCode)
+	}
+	return nil
+}
@@ -3,15 +3,26 @@ package saver
 import (
 	"context"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/dimfeld/httptreemux/v5"
 )
 
+const ItemsRequestExpiration = 5 * time.Minute
+
+// ItemsRequestHash is the hash signed by the node saver key to list its items.
+func ItemsRequestHash(nodeId string, timestamp int64) crypto.Hash {
+	msg := fmt.Sprintf("SAVER:ITEMS:%s:%d", nodeId, timestamp)
+	return crypto.Sha256Hash([]byte(msg))
+}
+
 func StartHTTP(store *SQLite3Store, port int) error {
 	router := httptreemux.New()
 	router.PanicHandler = common.HandlePanic
@@ -19,6 +30,7 @@ func StartHTTP(store *SQLite3Store, port int) error {
 
 	router.GET("/", root)
 	router.POST("/", createItem)
+	router.GET("/nodes/:id/items", listItems)
 	handler := handleSession(router, store)
 	listen := fmt.Sprintf(":%d", port)
 	return http.ListenAndServe(listen, handler)
@@ -65,6 +77,52 @@ func createItem(w http.ResponseWriter, r *http.Request, params map[string]string
 	}
 }
 
+// listItems returns all backups of the node for the restore, the request is
+// signed by the node saver key with the timestamp in nanoseconds, and expires
+// in a few minutes to limit replays.
+func listItems(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	nodeId := params["id"]
+	timestamp, err := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "timestamp"})
+		return
+	}
+	if d := time.Since(time.Unix(0, timestamp)); d > ItemsRequestExpiration || d < -ItemsRequestExpiration {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "timestamp"})
+		return
+	}
+	var sig crypto.Signature
+	b, err := hex.DecodeString(r.URL.Query().Get("signature"))
+	if err != nil || len(b) != len(sig) {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "signature"})
+		return
+	}
+	copy(sig[:], b)
+
+	store := r.Context().Value("store").(*SQLite3Store)
+	pub, err := store.ReadNodePublicKey(r.Context(), nodeId)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+		return
+	}
+	hash := ItemsRequestHash(nodeId, timestamp)
+	if !pub.Verify(hash, sig) {
+		common.RenderJSON(w, r, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+		return
+	}
+
+	items, err := store.ListItemsForNode(r.Context(), nodeId)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	view := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		view[i] = json.RawMessage(item.Data)
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
 func handleSession(handler http.Handler, store *SQLite3Store) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), "store", store)
@@ -1,17 +1,35 @@
 package signer
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
 	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/saver"
 	"github.com/gofrs/uuid/v5"
 )
 
+type backupItem struct {
+	Id        string           `json:"id"`
+	NodeId    string           `json:"node_id"`
+	SessionId string           `json:"session_id"`
+	Public    string           `json:"public"`
+	Share     string           `json:"share"`
+	Signature crypto.Signature `json:"signature"`
+}
+
 func (node *Node) sendKeygenBackup(_ context.Context, op *common.Operation, share []byte) (bool, error) {
 	sid := uuid.Must(uuid.NewV4())
 	secret := crypto.Sha256Hash([]byte(node.saverKey.String() + sid.String()))
@@ -51,3 +69,156 @@ func (node *Node) sendKeygenBackup(_ context.Context, op *common.Operation, shar
 	}
 	return true, nil
 }
+
+// RestoreKeysFromSaver fetches all backups of the node from the saver, and
+// writes the latest shares not in the store after they are decrypted and
+// verified against their public keys. It returns the number of shares restored.
+func RestoreKeysFromSaver(ctx context.Context, store *SQLite3Store, conf *Configuration) (int, error) {
+	priv, err := crypto.KeyFromString(conf.SaverKey)
+	if err != nil {
+		return 0, fmt.Errorf("invalid saver key %v", err)
+	}
+	nodeId := conf.MTG.App.AppId
+	items, err := fetchSaverItems(ctx, conf.SaverAPI, nodeId, &priv)
+	if err != nil {
+		return 0, err
+	}
+
+	// the items are ordered by creation, and a key refreshed is backed up
+	// again, so only the latest share of each public is restored
+	var ops []*common.Operation
+	shares := make(map[string][]byte)
+	for _, item := range items {
+		op, share, err := decryptBackupItem(&priv, nodeId, item)
+		logger.Printf("decryptBackupItem(%s, %s) => %v %v", item.Id, item.SessionId, op, err)
+		if err != nil {
+			return 0, err
+		}
+		if shares[op.Public] == nil {
+			ops = append(ops, op)
+		}
+		shares[op.Public] = share
+	}
+
+	var count int
+	for _, op := range ops {
+		share := shares[op.Public]
+		public, _, _, err := store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(op.Public)))
+		if err != nil {
+			return count, err
+		}
+		if public == op.Public {
+			continue
+		}
+		err = store.WriteKeyIfNotExists(ctx, op.Id, op.Curve, op.Public, share, true)
+		if err != nil {
+			return count, err
+		}
+		count += 1
+	}
+	return count, nil
+}
+
+func fetchSaverItems(ctx context.Context, api, nodeId string, priv *crypto.Key) ([]*backupItem, error) {
+	timestamp := time.Now().UnixNano()
+	sig := priv.Sign(saver.ItemsRequestHash(nodeId, timestamp))
+	path := fmt.Sprintf("%s/nodes/%s/items?timestamp=%d&signature=%s",
+		strings.TrimSuffix(api, "/"), nodeId, timestamp, sig)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("saver.listItems(%s) => %d", nodeId, resp.StatusCode)
+	}
+
+	var items []*backupItem
+	err = json.NewDecoder(resp.Body).Decode(&items)
+	return items, err
+}
+
+func decryptBackupItem(priv *crypto.Key, nodeId string, item *backupItem) (*common.Operation, []byte, error) {
+	if item.NodeId != nodeId {
+		return nil, nil, fmt.Errorf("invalid backup node %s", item.NodeId)
+	}
+	msg := item.Id + item.NodeId + item.SessionId + item.Public + item.Share
+	pub := priv.Public()
+	if !pub.Verify(crypto.Sha256Hash([]byte(msg)), item.Signature) {
+		return nil, nil, fmt.Errorf("invalid backup signature %s", item.Id)
+	}
+
+	sid, err := uuid.FromString(item.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	secret := crypto.Sha256Hash([]byte(priv.String() + sid.String()))
+	secret = crypto.Sha256Hash(secret[:])
+
+	b, err := base64.RawURLEncoding.DecodeString(item.Public)
+	if err != nil {
+		return nil, nil, err
+	}
+	op, err := common.DecodeOperation(common.AESDecrypt(secret[:], b))
+	if err != nil {
+		return nil, nil, err
+	}
+	if op.Id != item.SessionId {
+		return nil, nil, fmt.Errorf("invalid backup session %s", item.SessionId)
+	}
+
+	b, err = base64.RawURLEncoding.DecodeString(item.Share)
+	if err != nil {
+		return nil, nil, err
+	}
+	b = common.AESDecrypt(secret[:], b)
+	if len(b) <= 16 || !bytes.Equal(b[:16], sid.Bytes()) {
+		return nil, nil, fmt.Errorf("invalid backup share %s", item.Id)
+	}
+	share := b[16:]
+
+	public, err := sharePublic(op.Curve, share)
+	if err != nil {
+		return nil, nil, err
+	}
+	fingerprint := common.Fingerprint(hex.EncodeToString(public))
+	if !bytes.Equal(fingerprint, common.Fingerprint(op.Public)) {
+		return nil, nil, fmt.Errorf("invalid backup share %s for %s", item.Id, op.Public)
+	}
+	return op, share, nil
+}
+
+// sharePublic is the same as deriveByPath without derivation, but returns
+// the error for the share not from the signer itself
+func sharePublic(crv byte, share []byte) ([]byte, error) {
+	switch crv {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+		conf := cmp.EmptyConfig(curve.Secp256k1{})
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			return nil, err
+		}
+		return conf.PublicPoint().MarshalBinary()
+	case common.CurveSecp256k1SchnorrBitcoin:
+		group := curve.Secp256k1{}
+		conf := &frost.TaprootConfig{PrivateShare: group.NewScalar()}
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			return nil, err
+		}
+		return conf.PublicKey, nil
+	case common.CurveEdwards25519Default, common.CurveEdwards25519Mixin:
+		conf := frost.EmptyConfig(curve.Edwards25519{})
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			return nil, err
+		}
+		return conf.PublicPoint().MarshalBinary()
+	}
+	return nil, fmt.Errorf("invalid curve %d", crv)
+}
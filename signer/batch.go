@@ -0,0 +1,254 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+)
+
+// A batch sign session signs all the messages of the operation with the same
+// key and derivation path. The messages are read from the storage transaction
+// referenced by the keeper output, and signed one by one in sub sessions with
+// the same members, then each member sends the signatures one by one to the
+// group, and the signatures are sent back to the keeper in a storage transaction.
+//
+// So a batch of N messages still runs N MPC protocols sequentially, and each
+// member sends N result transactions. The batch only saves the keeper and
+// signer MTG rows and transactions of the operations, and the protocols are
+// not any cheaper, so the batch size is capped by common.BatchSignLimit to
+// finish before the SessionTimeout.
+
+func batchSessionId(sessionId string, index int) []byte {
+	sid := common.UniqueId(sessionId, fmt.Sprintf("BATCH:%d", index))
+	return uuid.Must(uuid.FromString(sid)).Bytes()
+}
+
+// readStorageExtra reads the storage transaction referenced by the output,
+// the extra is only returned if it matches the digest in the operation
+func (node *Node) readStorageExtra(ctx context.Context, out *mtg.Action, digest []byte) []byte {
+	if len(digest) != 32 {
+		return nil
+	}
+
+	var extra []byte
+	if common.CheckTestEnvironment(ctx) {
+		val, err := node.store.ReadProperty(ctx, hex.EncodeToString(digest))
+		if err != nil {
+			panic(err)
+		}
+		extra = common.DecodeHexOrPanic(val)
+	} else {
+		ver, err := node.group.ReadKernelTransactionUntilSufficient(ctx, out.TransactionHash)
+		if err != nil {
+			panic(out.TransactionHash)
+		}
+		if len(ver.References) != 1 {
+			return nil
+		}
+		stx, err := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		if err != nil {
+			panic(ver.References[0].String())
+		}
+		extra = stx.Extra
+	}
+
+	sum := sha256.Sum256(extra)
+	if !bytes.Equal(sum[:], digest) {
+		return nil
+	}
+	return extra
+}
+
+func decodeBatchMessages(extra []byte) ([][]byte, error) {
+	msgs, err := common.DecodeBatchItems(extra)
+	if err != nil {
+		return nil, err
+	}
+	for i, msg := range msgs {
+		if !checkSignMessageSize(msg) {
+			return nil, fmt.Errorf("invalid batch message %d %x", i, msg)
+		}
+	}
+	return msgs, nil
+}
+
+// the session extra is the encoded messages before signed, and then the
+// signatures are appended like a single message sign session
+func concatBatchMessagesAndSignatures(msgs, sigs [][]byte) []byte {
+	payload := common.EncodeBatchItems(msgs)
+	extra := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	extra = append(extra, payload...)
+	return append(extra, common.EncodeBatchItems(sigs)...)
+}
+
+func splitBatchMessagesAndSignatures(extra []byte) ([][]byte, [][]byte, error) {
+	msgs, err := decodeBatchMessages(extra)
+	if err == nil {
+		return msgs, nil, nil
+	}
+	if len(extra) < 4 {
+		return nil, nil, err
+	}
+	el := int(binary.BigEndian.Uint32(extra[:4]))
+	if len(extra) < 4+el {
+		return nil, nil, fmt.Errorf("invalid batch extra %x", extra)
+	}
+	msgs, err = decodeBatchMessages(extra[4 : 4+el])
+	if err != nil {
+		return nil, nil, err
+	}
+	sigs, err := common.DecodeBatchItems(extra[4+el:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sigs) != len(msgs) {
+		return nil, nil, fmt.Errorf("invalid batch signatures %d %d", len(msgs), len(sigs))
+	}
+	return msgs, sigs, nil
+}
+
+func (node *Node) startBatchSign(ctx context.Context, op *common.Operation, members []party.ID) error {
+	logger.Printf("node.startBatchSign(%v, %v)\n", op, members)
+	if !slices.Contains(members, node.id) {
+		logger.Printf("node.startBatchSign(%v, %v) exit without committement\n", op, members)
+		return nil
+	}
+	public, crv, share, path, err := node.readKeyByFingerPath(ctx, op.Public)
+	logger.Printf("node.readKeyByFingerPath(%s) => %s %v", op.Public, public, err)
+	if err != nil {
+		return fmt.Errorf("node.readKeyByFingerPath(%s) => %v", op.Public, err)
+	}
+	if public == "" {
+		return node.store.FailSession(ctx, op.Id)
+	}
+	if crv != op.Curve {
+		return fmt.Errorf("node.startBatchSign(%v) invalid curve %d %d", op, crv, op.Curve)
+	}
+	fingerprint := op.Public[:16]
+	if hex.EncodeToString(common.Fingerprint(public)) != fingerprint {
+		return fmt.Errorf("node.startBatchSign(%v) invalid sum %x %s", op, common.Fingerprint(public), fingerprint)
+	}
+	msgs, err := decodeBatchMessages(op.Extra)
+	if err != nil {
+		panic(err)
+	}
+
+	sigs := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		res, err := node.signMessage(ctx, op.Curve, members, public, share, msg, batchSessionId(op.Id, i), path)
+		logger.Printf("node.signMessage(%v, %d) => %v %v", op, i, res, err)
+		if err != nil {
+			err = node.store.FailSession(ctx, op.Id)
+			logger.Printf("store.FailSession(%s, startBatchSign) => %v", op.Id, err)
+			return err
+		}
+		sigs[i] = res.Signature
+	}
+	extra := concatBatchMessagesAndSignatures(msgs, sigs)
+	err = node.store.MarkSessionPending(ctx, op.Id, op.Curve, op.Public, extra)
+	logger.Printf("store.MarkSessionPending(%v, startBatchSign) => %x %v\n", op, extra, err)
+	return err
+}
+
+// the signatures are sent one by one with the message index, and a failed
+// session sends an empty result as the single message sign session
+func (node *Node) sendSignerBatchResultTransactions(ctx context.Context, op *common.Operation) error {
+	holder, crv, share, path, err := node.readKeyByFingerPath(ctx, op.Public)
+	if err != nil || crv != op.Curve {
+		panic(err)
+	}
+	msgs, sigs, err := splitBatchMessagesAndSignatures(op.Extra)
+	if err != nil {
+		panic(err)
+	}
+	signed := len(sigs) > 0
+	for i := 0; signed && i < len(sigs); i++ {
+		extra := node.concatMessageAndSignature(msgs[i], sigs[i])
+		signed, _ = node.verifySessionSignature(ctx, op.Curve, holder, extra, share, path)
+	}
+	if !signed {
+		op.Extra = nil
+		return node.sendSignerResultTransaction(ctx, op)
+	}
+
+	for i, sig := range sigs {
+		op.Extra = binary.BigEndian.AppendUint16(nil, uint16(i))
+		op.Extra = append(op.Extra, sig...)
+		extra := common.AESEncrypt(node.aesKey[:], op.Encode(), op.Id)
+		if len(extra) > 160 {
+			panic(fmt.Errorf("node.sendSignerBatchResultTransactions(%v) omitted %x", op, extra))
+		}
+		traceId := fmt.Sprintf("SESSION:%s:SIGNER:%s:RESULT:%d", op.Id, string(node.id), i)
+		err = node.sendTransactionToSignerGroupUntilSufficient(ctx, extra, traceId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (node *Node) processSignerBatchResult(ctx context.Context, op *common.Operation, out *mtg.Action, self bool) error {
+	if len(op.Extra) <= 2 {
+		return node.store.UpdateSessionSigner(ctx, op.Id, out.Senders[0], nil, out.SequencerCreatedAt, self)
+	}
+	index := int(binary.BigEndian.Uint16(op.Extra[:2]))
+	return node.store.WriteBatchSignatureIfNotExist(ctx, op.Id, out.Senders[0], index, op.Extra[2:], out.SequencerCreatedAt, self)
+}
+
+// every message must have the same signature from at least threshold + 1 members,
+// and the signatures are encoded in the message order
+func (node *Node) verifyBatchSignerResults(ctx context.Context, session *Session) (bool, []byte) {
+	msgs, _, err := splitBatchMessagesAndSignatures(common.DecodeHexOrPanic(session.Extra))
+	if err != nil {
+		panic(err)
+	}
+	results, err := node.store.ListBatchSignatures(ctx, session.Id)
+	if err != nil {
+		panic(err)
+	}
+
+	members := node.GetMembers()
+	sigs := make([][]byte, len(msgs))
+	for i := range msgs {
+		var signed int
+		for _, id := range members {
+			extra, found := results[i][id]
+			if sigs[i] == nil && found {
+				sigs[i] = common.DecodeHexOrPanic(extra)
+			}
+			if found && extra != "" && hex.EncodeToString(sigs[i]) == extra {
+				signed = signed + 1
+			}
+		}
+		if signed < node.threshold+1 {
+			return false, nil
+		}
+	}
+	return true, common.EncodeBatchItems(sigs)
+}
+
+func (node *Node) buildKeeperBatchTransactions(ctx context.Context, op *common.Operation, sigs []byte, act *mtg.Action) ([]*mtg.Transaction, string) {
+	if !common.CheckTestEnvironment(ctx) && !act.CheckAssetBalanceForStorageAt(ctx, sigs) {
+		return nil, mtg.StorageAssetId
+	}
+	stx := act.BuildStorageTransaction(ctx, sigs)
+	logger.Printf("group.BuildStorageTransaction(%x) => %v", sigs, stx)
+
+	sum := sha256.Sum256(sigs)
+	op.Extra = sum[:]
+	tx, asset := node.buildKeeperTransactionWithStorageTraceId(ctx, op, act, stx.TraceId)
+	if asset != "" {
+		return nil, asset
+	}
+	return []*mtg.Transaction{stx, tx}, ""
+}
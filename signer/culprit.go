@@ -0,0 +1,102 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer/protocol"
+	"github.com/gofrs/uuid/v5"
+)
+
+const (
+	CulpritReasonAbort   = 1
+	CulpritReasonTimeout = 2
+
+	// the culprits are encoded as uuid bytes, and the operation
+	// encrypted should be small enough for the transaction extra
+	culpritsLimit = 4
+)
+
+var errSessionTimeout = errors.New("timeout")
+
+// reportSessionCulprits sends the culprits of a failed session to the signer
+// group, the culprits are either the members blamed by the protocol abort,
+// or the members missing in the last round if the session timeout
+func (node *Node) reportSessionCulprits(ctx context.Context, sessionId []byte, err error, missing []party.ID) {
+	var perr protocol.Error
+	reason, culprits := 0, []party.ID{}
+	if errors.As(err, &perr) {
+		reason, culprits = CulpritReasonAbort, perr.Culprits
+	} else if errors.Is(err, errSessionTimeout) {
+		reason, culprits = CulpritReasonTimeout, missing
+	}
+	extra := encodeSessionCulprits(reason, culprits)
+	if len(extra) == len(CulpritExtra)+1 {
+		return
+	}
+	sid, err := uuid.FromBytes(sessionId)
+	if err != nil {
+		return
+	}
+	s, err := node.store.ReadSession(ctx, sid.String())
+	if err != nil {
+		panic(err)
+	} else if s == nil {
+		return
+	}
+
+	op := s.asOperation()
+	op.Extra = extra
+	err = node.sendSignerCulpritsTransaction(ctx, op)
+	logger.Printf("node.sendSignerCulpritsTransaction(%v, %v) => %v", op, culprits, err)
+}
+
+func (node *Node) sendSignerCulpritsTransaction(ctx context.Context, op *common.Operation) error {
+	extra := common.AESEncrypt(node.aesKey[:], op.Encode(), op.Id)
+	if len(extra) > 160 {
+		panic(fmt.Errorf("node.sendSignerCulpritsTransaction(%v) omitted %x", op, extra))
+	}
+	traceId := fmt.Sprintf("SESSION:%s:SIGNER:%s:CULPRIT", op.Id, string(node.id))
+
+	return node.sendTransactionToSignerGroupUntilSufficient(ctx, extra, traceId)
+}
+
+func encodeSessionCulprits(reason int, culprits []party.ID) []byte {
+	extra := append([]byte(CulpritExtra), byte(reason))
+	for _, id := range culprits {
+		if len(extra) == len(CulpritExtra)+1+16*culpritsLimit {
+			break
+		}
+		uid, err := uuid.FromString(string(id))
+		if err != nil {
+			continue
+		}
+		extra = append(extra, uid.Bytes()...)
+	}
+	return extra
+}
+
+func decodeSessionCulprits(extra []byte) (int, []string, error) {
+	l := len(CulpritExtra)
+	if len(extra) < l+1+16 || (len(extra)-l-1)%16 != 0 {
+		return 0, nil, fmt.Errorf("invalid culprits %x", extra)
+	}
+	if len(extra) > l+1+16*culpritsLimit {
+		return 0, nil, fmt.Errorf("invalid culprits %x", extra)
+	}
+	reason := int(extra[l])
+	switch reason {
+	case CulpritReasonAbort, CulpritReasonTimeout:
+	default:
+		return 0, nil, fmt.Errorf("invalid culprits reason %d", reason)
+	}
+	var culprits []string
+	for b := extra[l+1:]; len(b) > 0; b = b[16:] {
+		culprits = append(culprits, uuid.Must(uuid.FromBytes(b[:16])).String())
+	}
+	return reason, culprits, nil
+}
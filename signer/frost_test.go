@@ -2,6 +2,8 @@ package signer
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"testing"
@@ -9,8 +11,16 @@ import (
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/cronokirby/saferith"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
@@ -20,57 +30,103 @@ func TestFROSTSigner(t *testing.T) {
 	require := require.New(t)
 	ctx, nodes, saverStore := TestPrepare(require)
 
-	public := testFROSTKeyGen(ctx, require, nodes, common.CurveEdwards25519Default)
+	public, _ := TestFROSTKeyGen(ctx, require, nodes, common.CurveEdwards25519Default)
 	testFROSTSign(ctx, require, nodes, public, []byte("mixin"), common.CurveEdwards25519Default)
 	testSaverItemsCheck(ctx, require, nodes, saverStore, 1)
+	testSignerRefresh(ctx, require, nodes, public, common.CurveEdwards25519Default)
+	testFROSTSign(ctx, require, nodes, public, []byte("refresh"), common.CurveEdwards25519Default)
 
-	public = testFROSTKeyGen(ctx, require, nodes, common.CurveSecp256k1SchnorrBitcoin)
+	public, _ = TestFROSTKeyGen(ctx, require, nodes, common.CurveSecp256k1SchnorrBitcoin)
 	testFROSTSign(ctx, require, nodes, public, []byte("mixin"), common.CurveSecp256k1SchnorrBitcoin)
 	testSaverItemsCheck(ctx, require, nodes, saverStore, 2)
+	testSignerRefresh(ctx, require, nodes, public, common.CurveSecp256k1SchnorrBitcoin)
+	testFROSTSign(ctx, require, nodes, public, []byte("refresh"), common.CurveSecp256k1SchnorrBitcoin)
 }
 
-func testFROSTKeyGen(ctx context.Context, require *require.Assertions, nodes []*Node, curve uint8) string {
-	sid := common.UniqueId("keygen", fmt.Sprint(curve))
-	for i := 0; i < 4; i++ {
-		node := nodes[i]
-		op := &common.Operation{
-			Type:  common.OperationTypeKeygenInput,
-			Id:    sid,
-			Curve: curve,
+func TestTaprootMuSigSign(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+	crv := byte(common.CurveSecp256k1SchnorrBitcoin)
+	public, _ := TestFROSTKeyGen(ctx, require, nodes, crv)
+
+	hk, err := btcec.NewPrivateKey()
+	require.Nil(err)
+	ks, secNonce := testTaprootKeySpend(require, hk, public)
+	sigs := testCMPBatchSignWithPath(ctx, require, nodes, public, [][]byte{ks.Message()}, crv, []byte{0, 0, 0, 0})
+	require.Len(sigs, 1)
+	require.Nil(ks.VerifySignerPartial(sigs[0]))
+	sig, err := ks.Sign(hk, secNonce, sigs[0])
+	require.Nil(err)
+	require.Len(sig.Serialize(), 64)
+
+	group := curve.Secp256k1{}
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	var members []party.ID
+	confs := make(map[party.ID]*frost.TaprootConfig)
+	for _, node := range nodes[:nodes[0].threshold+1] {
+		_, _, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		conf := &frost.TaprootConfig{PrivateShare: group.NewScalar()}
+		err = conf.UnmarshalBinary(share)
+		require.Nil(err)
+		confs[node.id] = conf
+		members = append(members, node.id)
+	}
+	sign := func(sid string, ks *bitcoin.TaprootKeySpend, tamper func(*round.Message)) ([]byte, error) {
+		sessions := make(map[party.ID]round.Session)
+		for id, conf := range confs {
+			start, err := taprootMuSigSession(conf, members, ks)([]byte(sid))
+			require.Nil(err)
+			sessions[id] = start
 		}
-		memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(op))
-		memo = hex.EncodeToString([]byte(memo))
-		out := &mtg.Action{
-			UnifiedOutput: mtg.UnifiedOutput{
-				OutputId:           uuid.Must(uuid.NewV4()).String(),
-				TransactionHash:    crypto.Sha256Hash([]byte(op.Id)).String(),
-				AppId:              node.conf.AppId,
-				AssetId:            node.conf.KeeperAssetId,
-				Extra:              memo,
-				Amount:             decimal.NewFromInt(1),
-				SequencerCreatedAt: time.Now(),
-			},
+		results, err := testRunRounds(sessions, tamper)
+		if err != nil {
+			return nil, err
 		}
-
-		msg := common.MarshalJSONOrPanic(out)
-		network := node.network.(*testNetwork)
-		network.mtgChannel(nodes[i].id) <- msg
+		for _, id := range members {
+			require.Equal(results[members[0]], results[id])
+		}
+		return results[members[0]].([]byte), nil
 	}
 
-	var public string
-	for _, node := range nodes {
-		op := testWaitOperation(ctx, node, sid)
-		logger.Verbosef("testWaitOperation(%s, %s) => %v\n", node.id, sid, op)
-		require.Equal(common.OperationTypeKeygenOutput, int(op.Type))
-		require.Equal(sid, op.Id)
-		require.Equal(curve, op.Curve)
-		require.Len(op.Public, 64)
-		require.Len(op.Extra, 34)
-		require.Equal(op.Extra[0], byte(common.RequestRoleSigner))
-		require.Equal(op.Extra[33], byte(common.RequestFlagNone))
-		public = op.Public
-	}
-	return public
+	// the invalid zⱼ is identified with Dⱼ, Eⱼ and the verification share
+	ks, secNonce = testTaprootKeySpend(require, hk, public)
+	_, err = sign("musig-bad-z", ks, func(msg *round.Message) {
+		body, ok := msg.Content.(*musigBroadcast3)
+		if ok && msg.From == members[1] {
+			body.Z = group.NewScalar().Set(body.Z).Add(group.NewScalar().SetNat(new(saferith.Nat).SetUint64(1)))
+		}
+	})
+	require.ErrorContains(err, fmt.Sprintf("invalid partial signature share from %s", members[1]))
+
+	partial, err := sign("musig", ks, nil)
+	require.Nil(err)
+	require.Len(partial, 98)
+	sig, err = ks.Sign(hk, secNonce, partial)
+	require.Nil(err)
+	require.True(sig.Verify(ks.Hash, testTaprootOutputKey(require, ks)))
+}
+
+func testTaprootKeySpend(require *require.Assertions, hk *btcec.PrivateKey, public string) (*bitcoin.TaprootKeySpend, [musig2.SecNonceSize]byte) {
+	nonces, err := musig2.GenNonces(musig2.WithPublicKey(hk.PubKey()))
+	require.Nil(err)
+	msg := make([]byte, 32)
+	_, err = rand.Read(msg)
+	require.Nil(err)
+	msg = append(msg, hk.PubKey().SerializeCompressed()...)
+	root := sha256.Sum256(msg)
+	msg = append(msg, root[:]...)
+	msg = append(msg, nonces.PubNonce[:]...)
+	ks, err := bitcoin.ParseTaprootKeySpendMessage(msg, public)
+	require.Nil(err)
+	require.Equal(msg, ks.Message())
+	return ks, nonces.SecNonce
+}
+
+func testTaprootOutputKey(require *require.Assertions, ks *bitcoin.TaprootKeySpend) *btcec.PublicKey {
+	agg, _, _, err := musig2.AggregateKeys([]*btcec.PublicKey{ks.Holder, ks.Signer}, true, musig2.WithTaprootKeyTweak(ks.MerkleRoot))
+	require.Nil(err)
+	return agg.FinalKey
 }
 
 func testFROSTSign(ctx context.Context, require *require.Assertions, nodes []*Node, public string, msg []byte, crv uint8) []byte {
@@ -14,6 +14,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
 	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/taproot"
 	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
 	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
 	"github.com/MixinNetwork/multi-party-sig/protocols/frost/sign"
@@ -28,10 +29,12 @@ import (
 
 const (
 	SessionTimeout       = time.Hour
+	SessionPrepareRetry  = time.Minute
 	KernelTimeout        = 3 * time.Minute
 	OperationExtraLimit  = 128
 	MPCFirstMessageRound = 2
 	PrepareExtra         = "PREPARE"
+	CulpritExtra         = "CULPRIT"
 )
 
 type Session struct {
@@ -128,7 +131,16 @@ func (node *Node) processAction(ctx context.Context, out *mtg.Action) (string, [
 			return sessionId, nil, ""
 		}
 		sessionId = op.Id
-		needsCommittment := op.Type == common.OperationTypeSignInput
+		if op.Type == common.OperationTypeBatchSignInput {
+			extra := node.readStorageExtra(ctx, out, op.Extra)
+			msgs, err := decodeBatchMessages(extra)
+			logger.Printf("node.decodeBatchMessages(%v, %x) => %d %v", op, extra, len(msgs), err)
+			if err != nil {
+				return sessionId, nil, ""
+			}
+			op.Extra = extra
+		}
+		needsCommittment := op.Type == common.OperationTypeSignInput || op.Type == common.OperationTypeBatchSignInput
 		hash, err := crypto.HashFromString(out.TransactionHash)
 		if err != nil {
 			panic(err)
@@ -154,6 +166,18 @@ func (node *Node) processAction(ctx context.Context, out *mtg.Action) (string, [
 			if err != nil {
 				panic(err)
 			}
+		} else if bytes.HasPrefix(req.Extra, []byte(CulpritExtra)) {
+			err = node.processSignerCulprits(ctx, req, out)
+			logger.Printf("node.processSignerCulprits(%v, %v) => %v", req, out, err)
+			if err != nil {
+				panic(err)
+			}
+		} else if string(req.Extra) == PresignExtra {
+			err = node.processSignerPresign(ctx, req, out)
+			logger.Printf("node.processSignerPresign(%v, %v) => %v", req, out, err)
+			if err != nil {
+				panic(err)
+			}
 		} else {
 			txs, asset := node.processSignerResult(ctx, req, out)
 			logger.Printf("node.processSignerResult(%v, %v) => %v %s", req, out, txs, asset)
@@ -164,7 +188,9 @@ func (node *Node) processAction(ctx context.Context, out *mtg.Action) (string, [
 }
 
 func (node *Node) processSignerPrepare(ctx context.Context, op *common.Operation, out *mtg.Action) error {
-	if op.Type != common.OperationTypeSignInput {
+	switch op.Type {
+	case common.OperationTypeSignInput, common.OperationTypeBatchSignInput, common.OperationTypePresignInput:
+	default:
 		return fmt.Errorf("node.processSignerPrepare(%v) type", op)
 	}
 	if string(op.Extra) != PrepareExtra {
@@ -187,11 +213,46 @@ func (node *Node) processSignerPrepare(ctx context.Context, op *common.Operation
 	if len(signers) <= node.threshold {
 		return nil
 	}
+	// wait a while for more members to prepare, so that the signing subset
+	// could be chosen from the most reliable members, this delays the session
+	// by the whole window whenever any member is offline
+	window := time.Duration(node.conf.SessionPrepareWindow) * time.Second
+	if len(signers) < len(node.GetMembers()) && out.SequencerCreatedAt.Before(s.CreatedAt.Add(window)) {
+		return nil
+	}
 	err = node.store.MarkSessionPrepared(ctx, op.Id, out.SequencerCreatedAt)
 	logger.Printf("node.MarkSessionPrepared(%v) => %v", op, err)
 	return err
 }
 
+// processSignerCulprits records the members reported by a signer as the
+// culprits of a failed session, the reports are only counted for the sessions
+// prepared after them, so all nodes choose the same signing members
+func (node *Node) processSignerCulprits(ctx context.Context, op *common.Operation, out *mtg.Action) error {
+	reason, culprits, err := decodeSessionCulprits(op.Extra)
+	if err != nil {
+		logger.Printf("decodeSessionCulprits(%x) => %v", op.Extra, err)
+		return nil
+	}
+	s, err := node.store.ReadSession(ctx, op.Id)
+	if err != nil {
+		return fmt.Errorf("store.ReadSession(%s) => %v", op.Id, err)
+	} else if s == nil {
+		return nil
+	}
+	var signers []string
+	for _, id := range culprits {
+		if id == out.Senders[0] || node.findMember(id) < 0 {
+			continue
+		}
+		signers = append(signers, id)
+	}
+	if len(signers) == 0 {
+		return nil
+	}
+	return node.store.WriteSessionCulpritsIfNotExist(ctx, op.Id, out.Senders[0], signers, reason, out.SequencerCreatedAt)
+}
+
 func (node *Node) processSignerResult(ctx context.Context, op *common.Operation, out *mtg.Action) ([]*mtg.Transaction, string) {
 	session, err := node.store.ReadSession(ctx, op.Id)
 	if err != nil {
@@ -203,16 +264,21 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 
 	self := len(out.Senders) == 1 && out.Senders[0] == string(node.id)
 	switch session.Operation {
-	case common.OperationTypeKeygenInput:
+	case common.OperationTypeKeygenInput, common.OperationTypeRefreshInput, common.OperationTypeReshareInput:
 		err = node.store.WriteSessionSignerIfNotExist(ctx, op.Id, out.Senders[0], op.Extra, out.SequencerCreatedAt, self)
 		if err != nil {
 			panic(fmt.Errorf("store.WriteSessionSignerIfNotExist(%v) => %v", op, err))
 		}
-	case common.OperationTypeSignInput:
+	case common.OperationTypeSignInput, common.OperationTypePresignInput:
 		err = node.store.UpdateSessionSigner(ctx, op.Id, out.Senders[0], op.Extra, out.SequencerCreatedAt, self)
 		if err != nil {
 			panic(fmt.Errorf("store.UpdateSessionSigner(%v) => %v", op, err))
 		}
+	case common.OperationTypeBatchSignInput:
+		err = node.processSignerBatchResult(ctx, op, out, self)
+		if err != nil {
+			panic(fmt.Errorf("node.processSignerBatchResult(%v) => %v", op, err))
+		}
 	}
 
 	signers, err := node.store.ListSessionSignerResults(ctx, op.Id)
@@ -227,6 +293,10 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 	if l := len(signers); l <= node.threshold {
 		panic(session.Id)
 	}
+	if session.Operation == common.OperationTypePresignInput {
+		node.finishPresignSession(ctx, session, sig, out)
+		return nil, ""
+	}
 
 	op = &common.Operation{Id: op.Id, Curve: session.Curve}
 	switch session.Operation {
@@ -285,6 +355,59 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 		op.Type = common.OperationTypeSignOutput
 		op.Public = holder
 		op.Extra = vsig
+	case common.OperationTypeBatchSignInput:
+		msgs, _, err := splitBatchMessagesAndSignatures(common.DecodeHexOrPanic(session.Extra))
+		if err != nil {
+			panic(err)
+		}
+		sigs, err := common.DecodeBatchItems(sig)
+		if err != nil || len(sigs) != len(msgs) {
+			panic(session.Id)
+		}
+		if session.State == common.RequestStateInitial && session.PreparedAt.Valid {
+			// this could happend only after crash or not commited
+			extra := concatBatchMessagesAndSignatures(msgs, sigs)
+			err = node.store.MarkSessionPending(ctx, session.Id, session.Curve, session.Public, extra)
+			logger.Printf("store.MarkSessionPending(%v, processSignerResult) => %x %v\n", session, extra, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		holder, crv, share, path, err := node.readKeyByFingerPath(ctx, session.Public)
+		logger.Printf("node.readKeyByFingerPath(%s) => %s %v", session.Public, holder, err)
+		if err != nil {
+			panic(err)
+		}
+		if crv != op.Curve {
+			panic(session.Id)
+		}
+		for i, msg := range msgs {
+			extra := node.concatMessageAndSignature(msg, sigs[i])
+			valid, _ := node.verifySessionSignature(ctx, op.Curve, holder, extra, share, path)
+			logger.Printf("node.verifySessionSignature(%v, %s, %x, %v) => %t", session, holder, extra, path, valid)
+			if !valid {
+				panic(hex.EncodeToString(sigs[i]))
+			}
+		}
+		op.Type = common.OperationTypeBatchSignOutput
+		op.Public = holder
+	case common.OperationTypeRefreshInput:
+		err = node.store.CommitKeyRefresh(ctx, session.Id, sig)
+		logger.Printf("store.CommitKeyRefresh(%v) => %v", session, err)
+		if err != nil {
+			panic(err)
+		}
+		op.Type = common.OperationTypeRefreshOutput
+		op.Public = session.Public
+	case common.OperationTypeReshareInput:
+		err = node.store.CommitKeyRefresh(ctx, session.Id, sig)
+		logger.Printf("store.CommitKeyRefresh(%v) => %v", session, err)
+		if err != nil {
+			panic(err)
+		}
+		op.Type = common.OperationTypeReshareOutput
+		op.Public = session.Public
 	default:
 		panic(session.Id)
 	}
@@ -293,6 +416,9 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 	if repliedToKeeper {
 		return nil, ""
 	}
+	if op.Type == common.OperationTypeBatchSignOutput {
+		return node.buildKeeperBatchTransactions(ctx, op, sig, out)
+	}
 	tx, asset := node.buildKeeperTransaction(ctx, op, out)
 	if asset != "" {
 		return nil, asset
@@ -369,9 +495,15 @@ func (node *Node) verifySessionHolder(_ context.Context, crv byte, holder string
 	}
 }
 
+// the taproot key spend message is the only message larger than the limit,
+// and it is too large for the keeper memo, so it is only signed in batches
+func checkSignMessageSize(msg []byte) bool {
+	return len(msg) <= OperationExtraLimit || len(msg) == bitcoin.TaprootKeySpendMessageSize
+}
+
 func (node *Node) concatMessageAndSignature(msg, sig []byte) []byte {
 	size := uint32(len(msg))
-	if size > OperationExtraLimit {
+	if !checkSignMessageSize(msg) {
 		panic(size)
 	}
 	extra := binary.BigEndian.AppendUint32(nil, size)
@@ -385,7 +517,7 @@ func (node *Node) checkSignatureAppended(extra []byte) bool {
 		return false
 	}
 	el := binary.BigEndian.Uint32(extra[:4])
-	if el > 160 {
+	if el > bitcoin.TaprootKeySpendMessageSize {
 		return false
 	}
 	return len(extra) > int(el)+32
@@ -405,6 +537,19 @@ func (node *Node) verifySessionSignature(ctx context.Context, crv byte, holder s
 		err := bitcoin.VerifySignatureDER(hex.EncodeToString(public), msg, sig)
 		logger.Printf("bitcoin.VerifySignatureDER(%x, %x, %x) => %v", public, msg, sig, err)
 		return err == nil, sig
+	case common.CurveSecp256k1SchnorrBitcoin:
+		if len(msg) == bitcoin.TaprootKeySpendMessageSize {
+			ks, err := bitcoin.ParseTaprootKeySpendMessage(msg, hex.EncodeToString(public))
+			if err != nil {
+				return false, nil
+			}
+			err = ks.VerifySignerPartial(sig)
+			logger.Printf("bitcoin.VerifySignerPartial(%x, %x, %x) => %v", public, msg, sig, err)
+			return err == nil, sig
+		}
+		res := taproot.PublicKey(public).Verify(sig, msg)
+		logger.Printf("taproot.Verify(%x, %x, %x) => %t", public, msg, sig, res)
+		return res, sig
 	case common.CurveSecp256k1ECDSAEthereum:
 		err := ethereum.VerifyHashSignature(hex.EncodeToString(public), msg, sig)
 		logger.Printf("ethereum.VerifyHashSignature(%x, %x, %x) => %v", public, msg, sig, err)
@@ -436,15 +581,14 @@ func (node *Node) verifySessionSignature(ctx context.Context, crv byte, holder s
 		res := mpub.Verify(hash, msig)
 		logger.Printf("mixin.Verify(%v, %x) => %t", hash, msig[:], res)
 		return res, sig
-	case common.CurveEdwards25519Default,
-		common.CurveSecp256k1SchnorrBitcoin:
+	case common.CurveEdwards25519Default:
 		return common.CheckTestEnvironment(ctx), sig // TODO
 	default:
 		panic(crv)
 	}
 }
 
-func (node *Node) verifySessionSignerResults(_ context.Context, session *Session, sessionSigners map[string]string) (bool, []byte) {
+func (node *Node) verifySessionSignerResults(ctx context.Context, session *Session, sessionSigners map[string]string) (bool, []byte) {
 	members := node.GetMembers()
 	switch session.Operation {
 	case common.OperationTypeKeygenInput:
@@ -471,6 +615,37 @@ func (node *Node) verifySessionSignerResults(_ context.Context, session *Session
 		}
 		exact := node.threshold + 1
 		return signed >= exact, sig
+	case common.OperationTypeBatchSignInput:
+		return node.verifyBatchSignerResults(ctx, session)
+	case common.OperationTypeRefreshInput, common.OperationTypeReshareInput:
+		// all members must have the same refreshed public shares, otherwise
+		// the old shares are kept and the refresh is retried by the keeper
+		digest := sessionSigners[string(node.id)]
+		var signed int
+		for _, id := range members {
+			extra, found := sessionSigners[id]
+			if found && extra != "" && extra == digest {
+				signed = signed + 1
+			}
+		}
+		exact := len(members)
+		return signed >= exact, common.DecodeHexOrPanic(digest)
+	case common.OperationTypePresignInput:
+		// the presignature is only usable if all its members have it
+		members, err := node.store.ListSessionPreparedMembers(ctx, session.Id, node.threshold+1)
+		if err != nil {
+			panic(err)
+		}
+		digest := sessionSigners[string(members[0])]
+		var signed int
+		for _, id := range members {
+			extra, found := sessionSigners[string(id)]
+			if found && len(extra) == 64 && extra == digest {
+				signed = signed + 1
+			}
+		}
+		exact := node.threshold + 1
+		return signed >= exact, common.DecodeHexOrPanic(digest)
 	default:
 		panic(session.Id)
 	}
@@ -491,6 +666,10 @@ func (node *Node) parseSignerMessage(out *mtg.Action) (*common.Operation, error)
 	switch req.Type {
 	case common.OperationTypeKeygenInput:
 	case common.OperationTypeSignInput:
+	case common.OperationTypeBatchSignInput:
+	case common.OperationTypeRefreshInput:
+	case common.OperationTypeReshareInput:
+	case common.OperationTypePresignInput:
 	default:
 		return nil, fmt.Errorf("invalid action %d", req.Type)
 	}
@@ -505,6 +684,14 @@ func (node *Node) startOperation(ctx context.Context, op *common.Operation, memb
 		return node.startKeygen(ctx, op)
 	case common.OperationTypeSignInput:
 		return node.startSign(ctx, op, members)
+	case common.OperationTypeBatchSignInput:
+		return node.startBatchSign(ctx, op, members)
+	case common.OperationTypeRefreshInput:
+		return node.startRefresh(ctx, op)
+	case common.OperationTypeReshareInput:
+		return node.startReshare(ctx, op)
+	case common.OperationTypePresignInput:
+		return node.startPresign(ctx, op, members)
 	default:
 		panic(op.Id)
 	}
@@ -564,22 +751,22 @@ func (node *Node) startSign(ctx context.Context, op *common.Operation, members [
 		return fmt.Errorf("node.startSign(%v) invalid sum %x %s", op, common.Fingerprint(public), fingerprint)
 	}
 
-	var res *SignResult
+	var presignId string
 	switch op.Curve {
 	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
-		res, err = node.cmpSign(ctx, members, public, share, op.Extra, op.IdBytes(), op.Curve, path)
-		logger.Printf("node.cmpSign(%v) => %v %v", op, res, err)
-	case common.CurveSecp256k1SchnorrBitcoin:
-		res, err = node.taprootSign(ctx, members, public, share, op.Extra, op.IdBytes())
-		logger.Printf("node.taprootSign(%v) => %v %v", op, res, err)
-	case common.CurveEdwards25519Default:
-		res, err = node.frostSign(ctx, members, public, share, op.Extra, op.IdBytes(), curve.Edwards25519{}, sign.ProtocolEd25519SHA512)
-		logger.Printf("node.frostSign(%v) => %v %v", op, res, err)
-	case common.CurveEdwards25519Mixin:
-		res, err = node.frostSign(ctx, members, public, share, op.Extra, op.IdBytes(), curve.Edwards25519{}, sign.ProtocolMixinPublic)
-		logger.Printf("node.frostSign(%v) => %v %v", op, res, err)
-	default:
-		panic(op.Id)
+		presignId, err = node.store.ReadSessionPresignature(ctx, op.Id)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var res *SignResult
+	if presignId != "" {
+		res, err = node.cmpPresignSign(ctx, presignId, public, share, op.Extra, op.IdBytes(), op.Curve, path)
+		logger.Printf("node.cmpPresignSign(%v, %s) => %v %v", op, presignId, res, err)
+	} else {
+		res, err = node.signMessage(ctx, op.Curve, members, public, share, op.Extra, op.IdBytes(), path)
+		logger.Printf("node.signMessage(%v) => %v %v", op, res, err)
 	}
 
 	if err != nil {
@@ -593,6 +780,28 @@ func (node *Node) startSign(ctx context.Context, op *common.Operation, members [
 	return err
 }
 
+func (node *Node) signMessage(ctx context.Context, crv byte, members []party.ID, public string, share, msg, sessionId, path []byte) (*SignResult, error) {
+	var err error
+	var res *SignResult
+	switch crv {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+		res, err = node.cmpSign(ctx, members, public, share, msg, sessionId, crv, path)
+		logger.Printf("node.cmpSign(%x, %x) => %v %v", sessionId, msg, res, err)
+	case common.CurveSecp256k1SchnorrBitcoin:
+		res, err = node.taprootSign(ctx, members, public, share, msg, sessionId)
+		logger.Printf("node.taprootSign(%x, %x) => %v %v", sessionId, msg, res, err)
+	case common.CurveEdwards25519Default:
+		res, err = node.frostSign(ctx, members, public, share, msg, sessionId, curve.Edwards25519{}, sign.ProtocolEd25519SHA512)
+		logger.Printf("node.frostSign(%x, %x) => %v %v", sessionId, msg, res, err)
+	case common.CurveEdwards25519Mixin:
+		res, err = node.frostSign(ctx, members, public, share, msg, sessionId, curve.Edwards25519{}, sign.ProtocolMixinPublic)
+		logger.Printf("node.frostSign(%x, %x) => %v %v", sessionId, msg, res, err)
+	default:
+		panic(crv)
+	}
+	return res, err
+}
+
 func (node *Node) verifyKernelTransaction(ctx context.Context, out *mtg.Action) bool {
 	if common.CheckTestEnvironment(ctx) {
 		return false
@@ -621,7 +830,10 @@ func (node *Node) parseOperation(_ context.Context, memo string) (*common.Operat
 
 	switch op.Type {
 	case common.OperationTypeSignInput:
+	case common.OperationTypeBatchSignInput:
 	case common.OperationTypeKeygenInput:
+	case common.OperationTypeRefreshInput:
+	case common.OperationTypeReshareInput:
 	default:
 		return nil, fmt.Errorf("invalid action %d", op.Type)
 	}
@@ -645,6 +857,10 @@ func (node *Node) encryptOperation(op *common.Operation) []byte {
 }
 
 func (node *Node) buildKeeperTransaction(ctx context.Context, op *common.Operation, act *mtg.Action) (*mtg.Transaction, string) {
+	return node.buildKeeperTransactionWithStorageTraceId(ctx, op, act, "")
+}
+
+func (node *Node) buildKeeperTransactionWithStorageTraceId(ctx context.Context, op *common.Operation, act *mtg.Action, storageTraceId string) (*mtg.Transaction, string) {
 	extra := node.encryptOperation(op)
 	if len(extra) > 160 {
 		panic(fmt.Errorf("node.buildKeeperTransaction(%v) omitted %x", op, extra))
@@ -662,6 +878,9 @@ func (node *Node) buildKeeperTransaction(ctx context.Context, op *common.Operati
 	threshold := node.keeper.Genesis.Threshold
 	traceId := common.UniqueId(node.group.GenesisId(), op.Id)
 	tx := act.BuildTransaction(ctx, traceId, node.conf.KeeperAppId, node.conf.KeeperAssetId, amount.String(), string(extra), members, threshold)
+	if storageTraceId != "" {
+		tx = act.BuildTransactionWithStorageTraceId(ctx, traceId, node.conf.KeeperAppId, node.conf.KeeperAssetId, amount.String(), string(extra), members, threshold, storageTraceId)
+	}
 	logger.Printf("node.buildKeeperTransaction(%v) => %s %x %x", op, traceId, extra, tx.Serialize())
 	return tx, ""
 }
@@ -8,21 +8,34 @@ import (
 )
 
 type Configuration struct {
-	AppId                   string             `toml:"app-id"`
-	KeeperAppId             string             `toml:"keeper-app-id"`
-	StoreDir                string             `toml:"store-dir"`
-	MessengerConversationId string             `toml:"messenger-conversation-id"`
-	MonitorConversaionId    string             `toml:"monitor-conversation-id"`
-	ObserverUserId          string             `toml:"observer-user-id"`
-	Threshold               int                `toml:"threshold"`
-	SharedKey               string             `toml:"shared-key"`
-	AssetId                 string             `toml:"asset-id"`
-	KeeperAssetId           string             `toml:"keeper-asset-id"`
-	KeeperPublicKey         string             `toml:"keeper-public-key"`
-	SaverAPI                string             `toml:"saver-api"`
-	SaverKey                string             `toml:"saver-key"`
-	MixinRPC                string             `toml:"mixin-rpc"`
-	MTG                     *mtg.Configuration `toml:"mtg"`
+	AppId                   string                `toml:"app-id"`
+	KeeperAppId             string                `toml:"keeper-app-id"`
+	StoreDir                string                `toml:"store-dir"`
+	MessengerConversationId string                `toml:"messenger-conversation-id"`
+	MonitorConversaionId    string                `toml:"monitor-conversation-id"`
+	MetricsListen           string                `toml:"metrics-listen"`
+	ObserverUserId          string                `toml:"observer-user-id"`
+	Threshold               int                   `toml:"threshold"`
+	SharedKey               string                `toml:"shared-key"`
+	AssetId                 string                `toml:"asset-id"`
+	KeeperAssetId           string                `toml:"keeper-asset-id"`
+	KeeperPublicKey         string                `toml:"keeper-public-key"`
+	SaverAPI                string                `toml:"saver-api"`
+	SaverKey                string                `toml:"saver-key"`
+	ShareKeyFile            string                `toml:"share-key-file"`
+	SharePassphrase         string                `toml:"share-passphrase"`
+	MixinRPC                string                `toml:"mixin-rpc"`
+	CustodianAppId          string                `toml:"custodian-app-id"`
+	CustodianMintAccount    string                `toml:"custodian-mint-account"`
+	CustodianForwardMint    bool                  `toml:"custodian-forward-mint"`
+	SessionPrepareWindow    int                   `toml:"session-prepare-window"`
+	Network                 *NetworkConfiguration `toml:"network"`
+	MTG                     *mtg.Configuration    `toml:"mtg"`
+}
+
+type NetworkConfiguration struct {
+	Listen string                  `toml:"listen"`
+	Peers  []*messenger.DirectPeer `toml:"peers"`
 }
 
 func (c *Configuration) Messenger() *messenger.MixinConfiguration {
@@ -36,6 +49,22 @@ func (c *Configuration) Messenger() *messenger.MixinConfiguration {
 	}
 }
 
+// Direct returns the direct messenger configuration if the network is
+// configured, otherwise the signer should fallback to the mixin messenger
+func (c *Configuration) Direct() *messenger.DirectConfiguration {
+	if c.Network == nil || c.Network.Listen == "" {
+		return nil
+	}
+	return &messenger.DirectConfiguration{
+		UserId:        c.MTG.App.AppId,
+		Key:           messenger.DeriveDirectKey(c.MTG.App.AppId, c.MTG.App.SessionPrivateKey),
+		Listen:        c.Network.Listen,
+		Peers:         c.Network.Peers,
+		ReceiveBuffer: 128,
+		SendBuffer:    64,
+	}
+}
+
 type Network interface {
 	ReceiveMessage(context.Context) (*messenger.MixinMessage, error)
 	QueueMessage(ctx context.Context, receiver string, b []byte) error
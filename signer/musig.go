@@ -0,0 +1,266 @@
+package signer
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/polynomial"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/sample"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/protocol"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+)
+
+const musigSignProtocolId = "safe/taproot-musig2-sign"
+
+// taprootMuSigSession makes the signer public nonce and partial signature of
+// the MuSig2 key spend of a taproot safe. The signer key is shared by FROST,
+// so each member acts as another MuSig2 signer with the key share λᵢ⋅sᵢ, and
+// the signer nonces are the sums of the member nonces.
+//
+// The holder nonce is fixed in the message before the members sample their
+// nonces, and the MuSig2 nonce coefficient b commits to all of them, so the
+// members need no more binding factors than a MuSig2 signer.
+func taprootMuSigSession(conf *frost.TaprootConfig, signers []party.ID, ks *bitcoin.TaprootKeySpend) protocol.StartFunc {
+	return func(sessionID []byte) (round.Session, error) {
+		group := curve.Secp256k1{}
+		info := round.Info{
+			ProtocolID:       musigSignProtocolId,
+			FinalRoundNumber: 3,
+			SelfID:           conf.ID,
+			PartyIDs:         signers,
+			Threshold:        conf.Threshold,
+			Group:            group,
+		}
+		helper, err := round.NewSession(info, sessionID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("musig.Start: %w", err)
+		}
+		if len(signers) != conf.Threshold+1 {
+			return nil, errors.New("musig.Start: signers is not a valid signing subset")
+		}
+
+		lagrange := polynomial.Lagrange(group, signers)
+		shares := make(map[party.ID]curve.Point, len(signers))
+		for _, j := range signers {
+			share := conf.VerificationShares[j]
+			if share == nil {
+				return nil, fmt.Errorf("musig.Start: verification share %s not found", j)
+			}
+			shares[j] = lagrange[j].Act(share)
+		}
+		return &musigRound1{
+			Helper: helper,
+			ks:     ks,
+			secret: group.NewScalar().Set(lagrange[conf.ID]).Mul(conf.PrivateShare),
+			shares: shares,
+		}, nil
+	}
+}
+
+type musigRound1 struct {
+	*round.Helper
+	ks     *bitcoin.TaprootKeySpend
+	secret curve.Scalar
+	shares map[party.ID]curve.Point
+}
+
+func (musigRound1) VerifyMessage(round.Message) error { return nil }
+
+func (musigRound1) StoreMessage(round.Message) error { return nil }
+
+// - sample dᵢ, eᵢ <- 𝔽
+// - broadcast Dᵢ = [dᵢ]G and Eᵢ = [eᵢ]G
+func (r *musigRound1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	d, D := sample.ScalarPointPair(rand.Reader, r.Group())
+	e, E := sample.ScalarPointPair(rand.Reader, r.Group())
+	err := r.BroadcastMessage(out, &musigBroadcast2{D: D, E: E})
+	if err != nil {
+		return r, err
+	}
+	return &musigRound2{
+		musigRound1: r,
+		d:           d,
+		e:           e,
+		D:           map[party.ID]curve.Point{r.SelfID(): D},
+		E:           map[party.ID]curve.Point{r.SelfID(): E},
+	}, nil
+}
+
+func (musigRound1) MessageContent() round.Content { return nil }
+
+func (musigRound1) Number() round.Number { return 1 }
+
+type musigRound2 struct {
+	*musigRound1
+	d curve.Scalar
+	e curve.Scalar
+	D map[party.ID]curve.Point
+	E map[party.ID]curve.Point
+}
+
+type musigBroadcast2 struct {
+	round.ReliableBroadcastContent
+	D curve.Point
+	E curve.Point
+}
+
+func (r *musigRound2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*musigBroadcast2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.D.IsIdentity() || body.E.IsIdentity() {
+		return round.ErrNilFields
+	}
+	r.D[msg.From] = body.D
+	r.E[msg.From] = body.E
+	return nil
+}
+
+func (musigRound2) VerifyMessage(round.Message) error { return nil }
+
+func (musigRound2) StoreMessage(round.Message) error { return nil }
+
+// - the signer nonce is (∑ⱼ Dⱼ, ∑ⱼ Eⱼ), and b, e, c from the MuSig2 session
+// - zᵢ = dᵢ + b⋅eᵢ + e⋅c⋅λᵢ⋅sᵢ, with dᵢ and eᵢ negated if R has odd y
+func (r *musigRound2) Finalize(out chan<- *round.Message) (round.Session, error) {
+	D := r.Group().NewPoint()
+	E := r.Group().NewPoint()
+	for _, j := range r.PartyIDs() {
+		D = D.Add(r.D[j])
+		E = E.Add(r.E[j])
+	}
+	if D.IsIdentity() || E.IsIdentity() {
+		return r.AbortRound(errors.New("invalid signer nonce")), nil
+	}
+	var nonce [musig2.PubNonceSize]byte
+	copy(nonce[:], musigPointBytes(D))
+	copy(nonce[btcec.PubKeyBytesLenCompressed:], musigPointBytes(E))
+	b, e, c, negate, err := r.ks.SignerChallenge(nonce)
+	if err != nil {
+		return r.AbortRound(fmt.Errorf("invalid signer nonce: %w", err)), nil
+	}
+
+	rn := &musigRound3{
+		musigRound2: r,
+		nonce:       nonce,
+		b:           musigScalar(r.Group(), b),
+		ec:          musigScalar(r.Group(), e).Mul(musigScalar(r.Group(), c)),
+		negate:      negate,
+		Z:           make(map[party.ID]curve.Scalar),
+	}
+	d := r.Group().NewScalar().Set(r.d)
+	be := r.Group().NewScalar().Set(rn.b).Mul(r.e)
+	if negate {
+		d.Negate()
+		be.Negate()
+	}
+	Z := d.Add(be).Add(r.Group().NewScalar().Set(rn.ec).Mul(r.secret))
+	err = r.BroadcastMessage(out, &musigBroadcast3{Z: Z})
+	if err != nil {
+		return r, err
+	}
+	rn.Z[r.SelfID()] = Z
+	return rn, nil
+}
+
+func (musigRound2) MessageContent() round.Content { return nil }
+
+func (r *musigRound2) BroadcastContent() round.BroadcastContent {
+	return &musigBroadcast2{
+		D: r.Group().NewPoint(),
+		E: r.Group().NewPoint(),
+	}
+}
+
+func (musigRound2) Number() round.Number { return 2 }
+
+func (musigBroadcast2) RoundNumber() round.Number { return 2 }
+
+type musigRound3 struct {
+	*musigRound2
+	nonce  [musig2.PubNonceSize]byte
+	b      curve.Scalar
+	ec     curve.Scalar
+	negate bool
+	Z      map[party.ID]curve.Scalar
+}
+
+type musigBroadcast3 struct {
+	round.NormalBroadcastContent
+	Z curve.Scalar
+}
+
+// - verify [zⱼ]G = Dⱼ + [b]Eⱼ + [e⋅c]([λⱼ]Yⱼ), with Dⱼ and Eⱼ negated if R has odd y
+func (r *musigRound3) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*musigBroadcast3)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.Z.IsZero() {
+		return round.ErrNilFields
+	}
+	expected := r.D[msg.From].Add(r.b.Act(r.E[msg.From]))
+	if r.negate {
+		expected = expected.Negate()
+	}
+	expected = expected.Add(r.ec.Act(r.shares[msg.From]))
+	if !body.Z.ActOnBase().Equal(expected) {
+		return fmt.Errorf("invalid partial signature share from %s", msg.From)
+	}
+	r.Z[msg.From] = body.Z
+	return nil
+}
+
+func (musigRound3) VerifyMessage(round.Message) error { return nil }
+
+func (musigRound3) StoreMessage(round.Message) error { return nil }
+
+// the result is the signer public nonce and the partial signature ∑ⱼ zⱼ
+func (r *musigRound3) Finalize(chan<- *round.Message) (round.Session, error) {
+	Z := r.Group().NewScalar()
+	for _, j := range r.PartyIDs() {
+		Z.Add(r.Z[j])
+	}
+	sig := append(r.nonce[:], Z.Bytes()...)
+	err := r.ks.VerifySignerPartial(sig)
+	if err != nil {
+		return r.AbortRound(fmt.Errorf("failed to validate partial signature: %w", err)), nil
+	}
+	return r.ResultRound(sig), nil
+}
+
+func (musigRound3) MessageContent() round.Content { return nil }
+
+func (r *musigRound3) BroadcastContent() round.BroadcastContent {
+	return &musigBroadcast3{Z: r.Group().NewScalar()}
+}
+
+func (musigRound3) Number() round.Number { return 3 }
+
+func (musigBroadcast3) RoundNumber() round.Number { return 3 }
+
+func musigPointBytes(p curve.Point) []byte {
+	b, err := p.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func musigScalar(group curve.Curve, s *btcec.ModNScalar) curve.Scalar {
+	b := s.Bytes()
+	v := group.NewScalar()
+	err := v.UnmarshalBinary(b[:])
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
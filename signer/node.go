@@ -90,7 +90,11 @@ func (node *Node) Boot(ctx context.Context) {
 	go node.loopInitialSessions(ctx)
 	go node.loopPreparedSessions(ctx)
 	go node.loopPendingSessions(ctx)
+	go node.loopPresignatures(ctx)
 	go node.acceptIncomingMessages(ctx)
+	if node.conf.CustodianAppId != "" {
+		go node.loopDailyWorks(ctx)
+	}
 	logger.Printf("node.Boot(%s, %d)", node.id, node.Index())
 }
 
@@ -139,7 +143,7 @@ func (node *Node) loopInitialSessions(ctx context.Context) {
 
 		for _, s := range sessions {
 			op := s.asOperation()
-			err := node.sendSignerPrepareTransaction(ctx, op)
+			err := node.sendSignerPrepareTransaction(ctx, op, false)
 			logger.Printf("node.sendSignerPrepareTransaction(%v) => %v", op, err)
 			if err != nil {
 				break
@@ -150,6 +154,22 @@ func (node *Node) loopInitialSessions(ctx context.Context) {
 				break
 			}
 		}
+
+		// the session waits for all members to prepare during the prepare window,
+		// so prepare it again after the window if some members are missing
+		window := time.Duration(node.conf.SessionPrepareWindow) * time.Second
+		sessions, err = node.store.ListUnpreparedSessions(ctx, time.Now().Add(-max(window, SessionPrepareRetry)), 64)
+		if err != nil {
+			panic(err)
+		}
+		for _, s := range sessions {
+			op := s.asOperation()
+			err := node.sendSignerPrepareTransaction(ctx, op, true)
+			logger.Printf("node.sendSignerPrepareTransaction(%v, true) => %v", op, err)
+			if err != nil {
+				break
+			}
+		}
 	}
 }
 
@@ -169,7 +189,7 @@ func (node *Node) loopPreparedSessions(ctx context.Context) {
 			if err != nil {
 				panic(err)
 			}
-			if len(signers) != threshold && s.Operation != common.OperationTypeKeygenInput {
+			if len(signers) != threshold && (s.Operation == common.OperationTypeSignInput || s.Operation == common.OperationTypeBatchSignInput) {
 				panic(fmt.Sprintf("ListSessionPreparedMember(%s, %d) => %d", s.Id, threshold, len(signers)))
 			}
 			results[i] = node.queueOperation(ctx, s.asOperation(), signers)
@@ -228,6 +248,17 @@ func (node *Node) loopPendingSessions(ctx context.Context) {
 			switch op.Type {
 			case common.OperationTypeKeygenInput:
 				op.Extra = common.DecodeHexOrPanic(op.Public)
+			case common.OperationTypeRefreshInput:
+			case common.OperationTypeReshareInput:
+				// a failed reshare still has the committee in extra
+				if len(op.Extra) != 32 {
+					op.Extra = nil
+				}
+			case common.OperationTypePresignInput:
+				// a failed presign still has the presign request in extra
+				if len(op.Extra) != 32 {
+					op.Extra = nil
+				}
 			case common.OperationTypeSignInput:
 				holder, crv, share, path, err := node.readKeyByFingerPath(ctx, op.Public)
 				if err != nil || crv != op.Curve {
@@ -239,11 +270,19 @@ func (node *Node) loopPendingSessions(ctx context.Context) {
 				} else {
 					op.Extra = nil
 				}
+			case common.OperationTypeBatchSignInput:
+				// the signatures are verified and sent one by one
 			default:
 				panic(op.Id)
 			}
-			err := node.sendSignerResultTransaction(ctx, op)
-			logger.Printf("node.sendSignerResultTransaction(%v) => %v", op, err)
+			var err error
+			if op.Type == common.OperationTypeBatchSignInput {
+				err = node.sendSignerBatchResultTransactions(ctx, op)
+				logger.Printf("node.sendSignerBatchResultTransactions(%v) => %v", op, err)
+			} else {
+				err = node.sendSignerResultTransaction(ctx, op)
+				logger.Printf("node.sendSignerResultTransaction(%v) => %v", op, err)
+			}
 			if err != nil {
 				break
 			}
@@ -310,7 +349,7 @@ func (node *Node) acceptIncomingMessages(ctx context.Context) {
 		if err != nil {
 			panic(err)
 		}
-		if r == nil {
+		if r == nil || !r.PreparedAt.Valid {
 			continue
 		}
 		threshold := node.threshold + 1
@@ -364,6 +403,9 @@ func (node *Node) handlerLoop(ctx context.Context, start round.Session, sessionI
 	res, err := node.loopMultiPartySession(ctx, mps, h, roundTimeout)
 	missing := mps.missing(node.id)
 	logger.Printf("node.loopMultiPartySession(%x, %d) => %v with %v missing", mps.id, mps.round, err, missing)
+	if err != nil {
+		node.reportSessionCulprits(ctx, sessionId, err, missing)
+	}
 	return res, err
 }
 
@@ -395,7 +437,7 @@ func (node *Node) loopMultiPartySession(ctx context.Context, mps *MultiPartySess
 			mps.receive(msg)
 			mps.process(ctx, h, node.store)
 		case <-time.After(roundTimeout):
-			return nil, fmt.Errorf("node.handlerLoop(%x) timeout", mps.id)
+			return nil, fmt.Errorf("node.handlerLoop(%x) %w", mps.id, errSessionTimeout)
 		}
 	}
 }
@@ -535,8 +577,10 @@ func unmarshalSessionMessage(b []byte) ([]byte, *protocol.Message, error) {
 	return sessionId, &msg, err
 }
 
-func (node *Node) sendSignerPrepareTransaction(ctx context.Context, op *common.Operation) error {
-	if op.Type != common.OperationTypeSignInput {
+func (node *Node) sendSignerPrepareTransaction(ctx context.Context, op *common.Operation, retry bool) error {
+	switch op.Type {
+	case common.OperationTypeSignInput, common.OperationTypeBatchSignInput, common.OperationTypePresignInput:
+	default:
 		panic(op.Type)
 	}
 	op.Extra = []byte(PrepareExtra)
@@ -545,6 +589,9 @@ func (node *Node) sendSignerPrepareTransaction(ctx context.Context, op *common.O
 		panic(fmt.Errorf("node.sendSignerPrepareTransaction(%v) omitted %x", op, extra))
 	}
 	traceId := fmt.Sprintf("SESSION:%s:SIGNER:%s:PREPARE", op.Id, string(node.id))
+	if retry {
+		traceId = traceId + ":RETRY"
+	}
 
 	return node.sendTransactionToSignerGroupUntilSufficient(ctx, extra, traceId)
 }
@@ -0,0 +1,1221 @@
+package signer
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"time"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/pkg/ecdsa"
+	mh "github.com/MixinNetwork/multi-party-sig/pkg/hash"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/polynomial"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/sample"
+	"github.com/MixinNetwork/multi-party-sig/pkg/mta"
+	"github.com/MixinNetwork/multi-party-sig/pkg/paillier"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/pedersen"
+	"github.com/MixinNetwork/multi-party-sig/pkg/protocol"
+	zkaffg "github.com/MixinNetwork/multi-party-sig/pkg/zk/affg"
+	zkenc "github.com/MixinNetwork/multi-party-sig/pkg/zk/enc"
+	zklogstar "github.com/MixinNetwork/multi-party-sig/pkg/zk/logstar"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/cronokirby/saferith"
+)
+
+const (
+	presignProtocolId       = "safe/cmp-presign"
+	presignSignProtocolId   = "safe/cmp-presign-sign"
+	presignRoundTimeout     = 5 * time.Minute
+	presignSignRoundTimeout = time.Minute
+
+	PresignExtra    = "PRESIGN"
+	PresignPoolSize = 4
+
+	// only the keys signed recently keep presignatures in the pool
+	presignKeysWindow = 24 * time.Hour
+	presignKeysLimit  = 16
+)
+
+// Presignature is the message independent part of a CMP signature among the
+// members, the online signing needs only one round to combine the shares of
+// σ = km + rχ, so it must be consumed exactly once.
+type Presignature struct {
+	Members  []party.ID
+	R        curve.Point
+	KShare   curve.Scalar
+	ChiShare curve.Scalar
+
+	// KR[j] = [kⱼ]R and ChiR[j] = [χⱼ]R are used to identify the member
+	// who sends an invalid σⱼ in the online signing
+	KR   map[party.ID]curve.Point
+	ChiR map[party.ID]curve.Point
+}
+
+func (p *Presignature) MarshalBinary() []byte {
+	enc := mc.NewEncoder()
+	enc.WriteInt(len(p.Members))
+	for _, id := range p.Members {
+		writePresignBytes(enc, []byte(id))
+		writePresignBytes(enc, common.MarshalPanic(p.KR[id]))
+		writePresignBytes(enc, common.MarshalPanic(p.ChiR[id]))
+	}
+	writePresignBytes(enc, common.MarshalPanic(p.R))
+	writePresignBytes(enc, common.MarshalPanic(p.KShare))
+	writePresignBytes(enc, common.MarshalPanic(p.ChiShare))
+	return enc.Bytes()
+}
+
+func unmarshalPresignature(group curve.Curve, b []byte) (*Presignature, error) {
+	dec := mc.NewDecoder(b)
+	n, err := dec.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	p := &Presignature{
+		KR:   make(map[party.ID]curve.Point, n),
+		ChiR: make(map[party.ID]curve.Point, n),
+	}
+	for i := 0; i < n; i++ {
+		id, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		p.Members = append(p.Members, party.ID(id))
+		p.KR[party.ID(id)], err = readPresignPoint(group, dec)
+		if err != nil {
+			return nil, err
+		}
+		p.ChiR[party.ID(id)], err = readPresignPoint(group, dec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.R, err = readPresignPoint(group, dec)
+	if err != nil {
+		return nil, err
+	}
+	p.KShare, err = readPresignScalar(group, dec)
+	if err != nil {
+		return nil, err
+	}
+	p.ChiShare, err = readPresignScalar(group, dec)
+	return p, err
+}
+
+// the digest of the public parts, which should be the same for all members
+func (p *Presignature) digest() []byte {
+	h := sha256.New()
+	for _, id := range p.Members {
+		h.Write([]byte(id))
+		h.Write(common.MarshalPanic(p.KR[id]))
+		h.Write(common.MarshalPanic(p.ChiR[id]))
+	}
+	h.Write(common.MarshalPanic(p.R))
+	return h.Sum(nil)
+}
+
+func writePresignBytes(enc *mc.Encoder, b []byte) {
+	enc.WriteInt(len(b))
+	enc.Write(b)
+}
+
+func readPresignPoint(group curve.Curve, dec *mc.Decoder) (curve.Point, error) {
+	b, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	p := group.NewPoint()
+	err = p.UnmarshalBinary(b)
+	return p, err
+}
+
+func readPresignScalar(group curve.Curve, dec *mc.Decoder) (curve.Scalar, error) {
+	b, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	s := group.NewScalar()
+	err = s.UnmarshalBinary(b)
+	return s, err
+}
+
+// the presign is the first four rounds of the CMP signing, which don't
+// depend on the message, then the members exchange [kⱼ]R and [χⱼ]R
+func cmpPresignSession(conf *cmp.Config, signers []party.ID) protocol.StartFunc {
+	return func(sessionID []byte) (round.Session, error) {
+		group := conf.Group
+		info := round.Info{
+			ProtocolID:       presignProtocolId,
+			FinalRoundNumber: 5,
+			SelfID:           conf.ID,
+			PartyIDs:         signers,
+			Threshold:        conf.Threshold,
+			Group:            group,
+		}
+		helper, err := round.NewSession(info, sessionID, nil, conf)
+		if err != nil {
+			return nil, fmt.Errorf("presign.Start: %w", err)
+		}
+		if !conf.CanSign(helper.PartyIDs()) {
+			return nil, errors.New("presign.Start: signers is not a valid signing subset")
+		}
+
+		T := helper.N()
+		ECDSA := make(map[party.ID]curve.Point, T)
+		Paillier := make(map[party.ID]*paillier.PublicKey, T)
+		Pedersen := make(map[party.ID]*pedersen.Parameters, T)
+		PublicKey := group.NewPoint()
+		lagrange := polynomial.Lagrange(group, signers)
+		SecretECDSA := group.NewScalar().Set(lagrange[conf.ID]).Mul(conf.ECDSA)
+		for _, j := range helper.PartyIDs() {
+			public := conf.Public[j]
+			ECDSA[j] = lagrange[j].Act(public.ECDSA)
+			Paillier[j] = public.Paillier
+			Pedersen[j] = public.Pedersen
+			PublicKey = PublicKey.Add(ECDSA[j])
+		}
+
+		return &presignRound1{
+			Helper:         helper,
+			PublicKey:      PublicKey,
+			SecretECDSA:    SecretECDSA,
+			SecretPaillier: conf.Paillier,
+			Paillier:       Paillier,
+			Pedersen:       Pedersen,
+			ECDSA:          ECDSA,
+		}, nil
+	}
+}
+
+type presignRound1 struct {
+	*round.Helper
+
+	PublicKey      curve.Point
+	SecretECDSA    curve.Scalar
+	SecretPaillier *paillier.SecretKey
+	Paillier       map[party.ID]*paillier.PublicKey
+	Pedersen       map[party.ID]*pedersen.Parameters
+	ECDSA          map[party.ID]curve.Point
+}
+
+func (presignRound1) VerifyMessage(round.Message) error { return nil }
+
+func (presignRound1) StoreMessage(round.Message) error { return nil }
+
+// - sample kᵢ, γᵢ <- 𝔽,
+// - Γᵢ = [γᵢ]⋅G
+// - Gᵢ = Encᵢ(γᵢ;νᵢ)
+// - Kᵢ = Encᵢ(kᵢ;ρᵢ)
+func (r *presignRound1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	GammaShare, BigGammaShare := sample.ScalarPointPair(rand.Reader, r.Group())
+	G, GNonce := r.Paillier[r.SelfID()].Enc(curve.MakeInt(GammaShare))
+	KShare := sample.Scalar(rand.Reader, r.Group())
+	K, KNonce := r.Paillier[r.SelfID()].Enc(curve.MakeInt(KShare))
+
+	err := r.BroadcastMessage(out, &presignBroadcast2{K: K, G: G})
+	if err != nil {
+		return r, err
+	}
+	otherIDs := r.OtherPartyIDs()
+	errs := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
+		j := otherIDs[i]
+		proof := zkenc.NewProof(r.Group(), r.HashForID(r.SelfID()), zkenc.Public{
+			K:      K,
+			Prover: r.Paillier[r.SelfID()],
+			Aux:    r.Pedersen[j],
+		}, zkenc.Private{
+			K:   curve.MakeInt(KShare),
+			Rho: KNonce,
+		})
+		return r.SendMessage(out, &presignMessage2{ProofEnc: proof}, j)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return r, err.(error)
+		}
+	}
+
+	return &presignRound2{
+		presignRound1: r,
+		K:             map[party.ID]*paillier.Ciphertext{r.SelfID(): K},
+		G:             map[party.ID]*paillier.Ciphertext{r.SelfID(): G},
+		BigGammaShare: map[party.ID]curve.Point{r.SelfID(): BigGammaShare},
+		GammaShare:    curve.MakeInt(GammaShare),
+		KShare:        KShare,
+		KNonce:        KNonce,
+		GNonce:        GNonce,
+	}, nil
+}
+
+func (presignRound1) MessageContent() round.Content { return nil }
+
+func (presignRound1) Number() round.Number { return 1 }
+
+type presignRound2 struct {
+	*presignRound1
+
+	K             map[party.ID]*paillier.Ciphertext
+	G             map[party.ID]*paillier.Ciphertext
+	BigGammaShare map[party.ID]curve.Point
+	GammaShare    *saferith.Int
+	KShare        curve.Scalar
+	KNonce        *saferith.Nat
+	GNonce        *saferith.Nat
+}
+
+type presignBroadcast2 struct {
+	round.ReliableBroadcastContent
+	K *paillier.Ciphertext
+	G *paillier.Ciphertext
+}
+
+type presignMessage2 struct {
+	ProofEnc *zkenc.Proof
+}
+
+func (r *presignRound2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*presignBroadcast2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if !r.Paillier[msg.From].ValidateCiphertexts(body.K, body.G) {
+		return errors.New("invalid K, G")
+	}
+	r.K[msg.From] = body.K
+	r.G[msg.From] = body.G
+	return nil
+}
+
+func (r *presignRound2) VerifyMessage(msg round.Message) error {
+	from, to := msg.From, msg.To
+	body, ok := msg.Content.(*presignMessage2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.ProofEnc == nil {
+		return round.ErrNilFields
+	}
+	if !body.ProofEnc.Verify(r.Group(), r.HashForID(from), zkenc.Public{
+		K:      r.K[from],
+		Prover: r.Paillier[from],
+		Aux:    r.Pedersen[to],
+	}) {
+		return errors.New("failed to validate enc proof for K")
+	}
+	return nil
+}
+
+func (presignRound2) StoreMessage(round.Message) error { return nil }
+
+// - broadcast Γᵢ
+// - prove the MtA of γᵢ and xᵢ with all Kⱼ
+func (r *presignRound2) Finalize(out chan<- *round.Message) (round.Session, error) {
+	err := r.BroadcastMessage(out, &presignBroadcast3{BigGammaShare: r.BigGammaShare[r.SelfID()]})
+	if err != nil {
+		return r, err
+	}
+
+	otherIDs := r.OtherPartyIDs()
+	type mtaOut struct {
+		err       error
+		DeltaBeta *saferith.Int
+		ChiBeta   *saferith.Int
+	}
+	mtaOuts := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
+		j := otherIDs[i]
+		DeltaBeta, DeltaD, DeltaF, DeltaProof := mta.ProveAffG(r.Group(), r.HashForID(r.SelfID()),
+			r.GammaShare, r.BigGammaShare[r.SelfID()], r.K[j],
+			r.SecretPaillier, r.Paillier[j], r.Pedersen[j])
+		ChiBeta, ChiD, ChiF, ChiProof := mta.ProveAffG(r.Group(),
+			r.HashForID(r.SelfID()), curve.MakeInt(r.SecretECDSA), r.ECDSA[r.SelfID()], r.K[j],
+			r.SecretPaillier, r.Paillier[j], r.Pedersen[j])
+		proof := zklogstar.NewProof(r.Group(), r.HashForID(r.SelfID()), zklogstar.Public{
+			C:      r.G[r.SelfID()],
+			X:      r.BigGammaShare[r.SelfID()],
+			Prover: r.Paillier[r.SelfID()],
+			Aux:    r.Pedersen[j],
+		}, zklogstar.Private{
+			X:   r.GammaShare,
+			Rho: r.GNonce,
+		})
+		err := r.SendMessage(out, &presignMessage3{
+			DeltaD:     DeltaD,
+			DeltaF:     DeltaF,
+			DeltaProof: DeltaProof,
+			ChiD:       ChiD,
+			ChiF:       ChiF,
+			ChiProof:   ChiProof,
+			ProofLog:   proof,
+		}, j)
+		return mtaOut{err: err, DeltaBeta: DeltaBeta, ChiBeta: ChiBeta}
+	})
+	DeltaShareBetas := make(map[party.ID]*saferith.Int, len(otherIDs))
+	ChiShareBetas := make(map[party.ID]*saferith.Int, len(otherIDs))
+	for i, raw := range mtaOuts {
+		m := raw.(mtaOut)
+		if m.err != nil {
+			return r, m.err
+		}
+		DeltaShareBetas[otherIDs[i]] = m.DeltaBeta
+		ChiShareBetas[otherIDs[i]] = m.ChiBeta
+	}
+
+	return &presignRound3{
+		presignRound2:   r,
+		DeltaShareBeta:  DeltaShareBetas,
+		ChiShareBeta:    ChiShareBetas,
+		DeltaShareAlpha: map[party.ID]*saferith.Int{},
+		ChiShareAlpha:   map[party.ID]*saferith.Int{},
+	}, nil
+}
+
+func (presignRound2) MessageContent() round.Content { return &presignMessage2{} }
+
+func (presignRound2) BroadcastContent() round.BroadcastContent { return &presignBroadcast2{} }
+
+func (presignRound2) Number() round.Number { return 2 }
+
+func (presignMessage2) RoundNumber() round.Number { return 2 }
+
+func (presignBroadcast2) RoundNumber() round.Number { return 2 }
+
+type presignRound3 struct {
+	*presignRound2
+
+	DeltaShareAlpha map[party.ID]*saferith.Int
+	DeltaShareBeta  map[party.ID]*saferith.Int
+	ChiShareAlpha   map[party.ID]*saferith.Int
+	ChiShareBeta    map[party.ID]*saferith.Int
+}
+
+type presignMessage3 struct {
+	DeltaD     *paillier.Ciphertext
+	DeltaF     *paillier.Ciphertext
+	DeltaProof *zkaffg.Proof
+	ChiD       *paillier.Ciphertext
+	ChiF       *paillier.Ciphertext
+	ChiProof   *zkaffg.Proof
+	ProofLog   *zklogstar.Proof
+}
+
+type presignBroadcast3 struct {
+	round.NormalBroadcastContent
+	BigGammaShare curve.Point
+}
+
+func (r *presignRound3) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*presignBroadcast3)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.BigGammaShare.IsIdentity() {
+		return round.ErrNilFields
+	}
+	r.BigGammaShare[msg.From] = body.BigGammaShare
+	return nil
+}
+
+func (r *presignRound3) VerifyMessage(msg round.Message) error {
+	from, to := msg.From, msg.To
+	body, ok := msg.Content.(*presignMessage3)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if !body.DeltaProof.Verify(r.HashForID(from), zkaffg.Public{
+		Kv:       r.K[to],
+		Dv:       body.DeltaD,
+		Fp:       body.DeltaF,
+		Xp:       r.BigGammaShare[from],
+		Prover:   r.Paillier[from],
+		Verifier: r.Paillier[to],
+		Aux:      r.Pedersen[to],
+	}) {
+		return errors.New("failed to validate affg proof for Delta MtA")
+	}
+	if !body.ChiProof.Verify(r.HashForID(from), zkaffg.Public{
+		Kv:       r.K[to],
+		Dv:       body.ChiD,
+		Fp:       body.ChiF,
+		Xp:       r.ECDSA[from],
+		Prover:   r.Paillier[from],
+		Verifier: r.Paillier[to],
+		Aux:      r.Pedersen[to],
+	}) {
+		return errors.New("failed to validate affg proof for Chi MtA")
+	}
+	if !body.ProofLog.Verify(r.HashForID(from), zklogstar.Public{
+		C:      r.G[from],
+		X:      r.BigGammaShare[from],
+		Prover: r.Paillier[from],
+		Aux:    r.Pedersen[to],
+	}) {
+		return errors.New("failed to validate log proof")
+	}
+	return nil
+}
+
+func (r *presignRound3) StoreMessage(msg round.Message) error {
+	from, body := msg.From, msg.Content.(*presignMessage3)
+	DeltaShareAlpha, err := r.SecretPaillier.Dec(body.DeltaD)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt alpha share for delta: %w", err)
+	}
+	ChiShareAlpha, err := r.SecretPaillier.Dec(body.ChiD)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt alpha share for chi: %w", err)
+	}
+	r.DeltaShareAlpha[from] = DeltaShareAlpha
+	r.ChiShareAlpha[from] = ChiShareAlpha
+	return nil
+}
+
+// - Γ = ∑ⱼ Γⱼ
+// - Δᵢ = [kᵢ]Γ
+// - δᵢ = γᵢ kᵢ + ∑ⱼ δᵢⱼ
+// - χᵢ = xᵢ kᵢ + ∑ⱼ χᵢⱼ
+func (r *presignRound3) Finalize(out chan<- *round.Message) (round.Session, error) {
+	Gamma := r.Group().NewPoint()
+	for _, BigGammaShare := range r.BigGammaShare {
+		Gamma = Gamma.Add(BigGammaShare)
+	}
+
+	KShareInt := curve.MakeInt(r.KShare)
+	BigDeltaShare := r.KShare.Act(Gamma)
+	DeltaShare := new(saferith.Int).Mul(r.GammaShare, KShareInt, -1)
+	ChiShare := new(saferith.Int).Mul(curve.MakeInt(r.SecretECDSA), KShareInt, -1)
+	for _, j := range r.OtherPartyIDs() {
+		DeltaShare.Add(DeltaShare, r.DeltaShareAlpha[j], -1)
+		DeltaShare.Add(DeltaShare, r.DeltaShareBeta[j], -1)
+		ChiShare.Add(ChiShare, r.ChiShareAlpha[j], -1)
+		ChiShare.Add(ChiShare, r.ChiShareBeta[j], -1)
+	}
+
+	DeltaShareScalar := r.Group().NewScalar().SetNat(DeltaShare.Mod(r.Group().Order()))
+	err := r.BroadcastMessage(out, &presignBroadcast4{
+		DeltaShare:    DeltaShareScalar,
+		BigDeltaShare: BigDeltaShare,
+	})
+	if err != nil {
+		return r, err
+	}
+
+	otherIDs := r.OtherPartyIDs()
+	errs := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
+		j := otherIDs[i]
+		proofLog := zklogstar.NewProof(r.Group(), r.HashForID(r.SelfID()), zklogstar.Public{
+			C:      r.K[r.SelfID()],
+			X:      BigDeltaShare,
+			G:      Gamma,
+			Prover: r.Paillier[r.SelfID()],
+			Aux:    r.Pedersen[j],
+		}, zklogstar.Private{
+			X:   KShareInt,
+			Rho: r.KNonce,
+		})
+		return r.SendMessage(out, &presignMessage4{ProofLog: proofLog}, j)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return r, err.(error)
+		}
+	}
+
+	return &presignRound4{
+		presignRound3:  r,
+		DeltaShares:    map[party.ID]curve.Scalar{r.SelfID(): DeltaShareScalar},
+		BigDeltaShares: map[party.ID]curve.Point{r.SelfID(): BigDeltaShare},
+		Gamma:          Gamma,
+		ChiShare:       r.Group().NewScalar().SetNat(ChiShare.Mod(r.Group().Order())),
+	}, nil
+}
+
+func (r *presignRound3) MessageContent() round.Content {
+	return &presignMessage3{
+		ProofLog:   zklogstar.Empty(r.Group()),
+		DeltaProof: zkaffg.Empty(r.Group()),
+		ChiProof:   zkaffg.Empty(r.Group()),
+	}
+}
+
+func (r *presignRound3) BroadcastContent() round.BroadcastContent {
+	return &presignBroadcast3{BigGammaShare: r.Group().NewPoint()}
+}
+
+func (presignRound3) Number() round.Number { return 3 }
+
+func (presignMessage3) RoundNumber() round.Number { return 3 }
+
+func (presignBroadcast3) RoundNumber() round.Number { return 3 }
+
+type presignRound4 struct {
+	*presignRound3
+
+	DeltaShares    map[party.ID]curve.Scalar
+	BigDeltaShares map[party.ID]curve.Point
+	Gamma          curve.Point
+	ChiShare       curve.Scalar
+}
+
+type presignMessage4 struct {
+	ProofLog *zklogstar.Proof
+}
+
+type presignBroadcast4 struct {
+	round.NormalBroadcastContent
+	DeltaShare    curve.Scalar
+	BigDeltaShare curve.Point
+}
+
+func (r *presignRound4) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*presignBroadcast4)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.DeltaShare.IsZero() || body.BigDeltaShare.IsIdentity() {
+		return round.ErrNilFields
+	}
+	r.BigDeltaShares[msg.From] = body.BigDeltaShare
+	r.DeltaShares[msg.From] = body.DeltaShare
+	return nil
+}
+
+func (r *presignRound4) VerifyMessage(msg round.Message) error {
+	from, to := msg.From, msg.To
+	body, ok := msg.Content.(*presignMessage4)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if !body.ProofLog.Verify(r.HashForID(from), zklogstar.Public{
+		C:      r.K[from],
+		X:      r.BigDeltaShares[from],
+		G:      r.Gamma,
+		Prover: r.Paillier[from],
+		Aux:    r.Pedersen[to],
+	}) {
+		return errors.New("failed to validate log proof")
+	}
+	return nil
+}
+
+func (presignRound4) StoreMessage(round.Message) error { return nil }
+
+// - δ = ∑ⱼ δⱼ, Δ = ∑ⱼ Δⱼ and verify Δ = [δ]G
+// - R = [δ⁻¹]Γ
+// - broadcast [kᵢ]R and [χᵢ]R, with the DLEQ proof of [χᵢ]G and [χᵢ]R
+// - prove [kᵢ]R with Kᵢ to all others
+func (r *presignRound4) Finalize(out chan<- *round.Message) (round.Session, error) {
+	Delta := r.Group().NewScalar()
+	BigDelta := r.Group().NewPoint()
+	for _, j := range r.PartyIDs() {
+		Delta.Add(r.DeltaShares[j])
+		BigDelta = BigDelta.Add(r.BigDeltaShares[j])
+	}
+	if !Delta.ActOnBase().Equal(BigDelta) {
+		return r.AbortRound(errors.New("computed Δ is inconsistent with [δ]G")), nil
+	}
+
+	deltaInv := r.Group().NewScalar().Set(Delta).Invert()
+	BigR := deltaInv.Act(r.Gamma)
+	KR := r.KShare.Act(BigR)
+	ChiR := r.ChiShare.Act(BigR)
+	ChiG := r.ChiShare.ActOnBase()
+	err := r.BroadcastMessage(out, &presignBroadcast5{
+		KR:       KR,
+		ChiR:     ChiR,
+		ChiG:     ChiG,
+		ProofChi: newPresignProofDLEQ(r.HashForID(r.SelfID()), r.ChiShare, BigR, ChiG, ChiR),
+	})
+	if err != nil {
+		return r, err
+	}
+
+	otherIDs := r.OtherPartyIDs()
+	errs := r.Pool.Parallelize(len(otherIDs), func(i int) interface{} {
+		j := otherIDs[i]
+		proofLog := zklogstar.NewProof(r.Group(), r.HashForID(r.SelfID()), zklogstar.Public{
+			C:      r.K[r.SelfID()],
+			X:      KR,
+			G:      BigR,
+			Prover: r.Paillier[r.SelfID()],
+			Aux:    r.Pedersen[j],
+		}, zklogstar.Private{
+			X:   curve.MakeInt(r.KShare),
+			Rho: r.KNonce,
+		})
+		return r.SendMessage(out, &presignMessage5{ProofLog: proofLog}, j)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return r, err.(error)
+		}
+	}
+
+	return &presignRound5{
+		presignRound4: r,
+		BigR:          BigR,
+		KR:            map[party.ID]curve.Point{r.SelfID(): KR},
+		ChiR:          map[party.ID]curve.Point{r.SelfID(): ChiR},
+	}, nil
+}
+
+func (r *presignRound4) MessageContent() round.Content {
+	return &presignMessage4{ProofLog: zklogstar.Empty(r.Group())}
+}
+
+func (r *presignRound4) BroadcastContent() round.BroadcastContent {
+	return &presignBroadcast4{
+		DeltaShare:    r.Group().NewScalar(),
+		BigDeltaShare: r.Group().NewPoint(),
+	}
+}
+
+func (presignRound4) Number() round.Number { return 4 }
+
+func (presignMessage4) RoundNumber() round.Number { return 4 }
+
+func (presignBroadcast4) RoundNumber() round.Number { return 4 }
+
+type presignRound5 struct {
+	*presignRound4
+
+	BigR curve.Point
+	KR   map[party.ID]curve.Point
+	ChiR map[party.ID]curve.Point
+}
+
+type presignMessage5 struct {
+	ProofLog *zklogstar.Proof
+}
+
+type presignBroadcast5 struct {
+	round.NormalBroadcastContent
+	KR       curve.Point
+	ChiR     curve.Point
+	ChiG     curve.Point
+	ProofChi *presignProofDLEQ
+}
+
+func (r *presignRound5) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*presignBroadcast5)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.KR.IsIdentity() || body.ChiR.IsIdentity() || body.ChiG.IsIdentity() {
+		return round.ErrNilFields
+	}
+	if !body.ProofChi.Verify(r.HashForID(msg.From), r.BigR, body.ChiG, body.ChiR) {
+		return errors.New("failed to validate dleq proof for ChiR")
+	}
+	r.KR[msg.From] = body.KR
+	r.ChiR[msg.From] = body.ChiR
+	return nil
+}
+
+func (r *presignRound5) VerifyMessage(msg round.Message) error {
+	from, to := msg.From, msg.To
+	body, ok := msg.Content.(*presignMessage5)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if r.KR[from] == nil {
+		return round.ErrNilFields
+	}
+	if !body.ProofLog.Verify(r.HashForID(from), zklogstar.Public{
+		C:      r.K[from],
+		X:      r.KR[from],
+		G:      r.BigR,
+		Prover: r.Paillier[from],
+		Aux:    r.Pedersen[to],
+	}) {
+		return errors.New("failed to validate log proof for KR")
+	}
+	return nil
+}
+
+func (presignRound5) StoreMessage(round.Message) error { return nil }
+
+// R = [k⁻¹]G, so ∑ⱼ [kⱼ]R = G and ∑ⱼ [χⱼ]R = [kx][k⁻¹]G = X
+func (r *presignRound5) Finalize(chan<- *round.Message) (round.Session, error) {
+	KR := r.Group().NewPoint()
+	ChiR := r.Group().NewPoint()
+	for _, j := range r.PartyIDs() {
+		KR = KR.Add(r.KR[j])
+		ChiR = ChiR.Add(r.ChiR[j])
+	}
+	if !KR.Equal(r.Group().NewBasePoint()) || !ChiR.Equal(r.PublicKey) {
+		return r.AbortRound(errors.New("inconsistent presignature shares")), nil
+	}
+	return r.ResultRound(&Presignature{
+		Members:  r.PartyIDs(),
+		R:        r.BigR,
+		KShare:   r.KShare,
+		ChiShare: r.ChiShare,
+		KR:       r.KR,
+		ChiR:     r.ChiR,
+	}), nil
+}
+
+func (r *presignRound5) MessageContent() round.Content {
+	return &presignMessage5{ProofLog: zklogstar.Empty(r.Group())}
+}
+
+func (r *presignRound5) BroadcastContent() round.BroadcastContent {
+	return &presignBroadcast5{
+		KR:       r.Group().NewPoint(),
+		ChiR:     r.Group().NewPoint(),
+		ChiG:     r.Group().NewPoint(),
+		ProofChi: emptyPresignProofDLEQ(r.Group()),
+	}
+}
+
+func (presignRound5) Number() round.Number { return 5 }
+
+func (presignMessage5) RoundNumber() round.Number { return 5 }
+
+func (presignBroadcast5) RoundNumber() round.Number { return 5 }
+
+// χᵢ has no public commitment before round 5, so the proof only binds [χᵢ]R
+// to a χᵢ known by the sender, and the sum ∑ⱼ [χⱼ]R = X is still checked
+// - A = [a]G, B = [a]R, e = H(A, B, [χᵢ]G, [χᵢ]R, R), z = a + eχᵢ
+// - verify [z]G = A + [e]([χᵢ]G) and [z]R = B + [e]([χᵢ]R)
+type presignProofDLEQ struct {
+	A curve.Point
+	B curve.Point
+	Z curve.Scalar
+}
+
+func emptyPresignProofDLEQ(group curve.Curve) *presignProofDLEQ {
+	return &presignProofDLEQ{
+		A: group.NewPoint(),
+		B: group.NewPoint(),
+		Z: group.NewScalar(),
+	}
+}
+
+func newPresignProofDLEQ(h *mh.Hash, secret curve.Scalar, R, XG, XR curve.Point) *presignProofDLEQ {
+	group := secret.Curve()
+	a := sample.Scalar(rand.Reader, group)
+	A, B := a.ActOnBase(), a.Act(R)
+	e := presignProofDLEQChallenge(h, group, A, B, XG, XR, R)
+	return &presignProofDLEQ{
+		A: A,
+		B: B,
+		Z: e.Mul(secret).Add(a),
+	}
+}
+
+func (p *presignProofDLEQ) Verify(h *mh.Hash, R, XG, XR curve.Point) bool {
+	if p == nil || p.A.IsIdentity() || p.B.IsIdentity() || p.Z.IsZero() {
+		return false
+	}
+	group := R.Curve()
+	e := presignProofDLEQChallenge(h, group, p.A, p.B, XG, XR, R)
+	if !p.Z.ActOnBase().Equal(e.Act(XG).Add(p.A)) {
+		return false
+	}
+	return p.Z.Act(R).Equal(e.Act(XR).Add(p.B))
+}
+
+func presignProofDLEQChallenge(h *mh.Hash, group curve.Curve, points ...curve.Point) curve.Scalar {
+	for _, p := range points {
+		err := h.WriteAny(p)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return sample.Scalar(h.Digest(), group)
+}
+
+// the online signing with a presignature, the shares of the derived key are
+// the shares of the root key plus the derivation scalar, so χⱼ is adjusted
+// to χⱼ + kⱼ⋅adjust for the derived key.
+//
+// R of the presignature is known long before the message and the derivation
+// are chosen, which allows the related key forgeries of presignatures with
+// additive key derivation. So R is re-randomized to R' = [δ]R, with δ hashed
+// from the session, R, the derived key and the message, then k' = δ⁻¹k.
+func cmpPresignSignSession(group curve.Curve, selfID party.ID, presig *Presignature, public curve.Point, adjust curve.Scalar, message []byte) protocol.StartFunc {
+	return func(sessionID []byte) (round.Session, error) {
+		info := round.Info{
+			ProtocolID:       presignSignProtocolId,
+			FinalRoundNumber: 2,
+			SelfID:           selfID,
+			PartyIDs:         presig.Members,
+			Threshold:        len(presig.Members) - 1,
+			Group:            group,
+		}
+		helper, err := round.NewSession(info, sessionID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("presign.Sign: %w", err)
+		}
+		return &presignSignRound1{
+			Helper:  helper,
+			presig:  presig,
+			public:  public,
+			adjust:  adjust,
+			message: message,
+			delta:   presignRerandomizer(group, sessionID, presig, public, adjust, message),
+		}, nil
+	}
+}
+
+type presignSignRound1 struct {
+	*round.Helper
+	presig  *Presignature
+	public  curve.Point
+	adjust  curve.Scalar
+	message []byte
+	delta   curve.Scalar
+}
+
+func presignRerandomizer(group curve.Curve, sessionId []byte, presig *Presignature, public curve.Point, adjust curve.Scalar, message []byte) curve.Scalar {
+	h := sha256.New()
+	h.Write([]byte(presignSignProtocolId))
+	writePresignHash(h, sessionId)
+	writePresignHash(h, presig.digest())
+	writePresignHash(h, common.MarshalPanic(public))
+	writePresignHash(h, common.MarshalPanic(adjust))
+	writePresignHash(h, message)
+	return curve.FromHash(group, h.Sum(nil))
+}
+
+func writePresignHash(h hash.Hash, b []byte) {
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(b))))
+	h.Write(b)
+}
+
+func (presignSignRound1) VerifyMessage(round.Message) error { return nil }
+
+func (presignSignRound1) StoreMessage(round.Message) error { return nil }
+
+// - R' = [δ]R, r' = R'|ₓ
+// - σᵢ = δ⁻¹(kᵢm + r'(χᵢ + kᵢ⋅adjust))
+func (r *presignSignRound1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	if r.delta.IsZero() {
+		return r.AbortRound(errors.New("invalid presignature re-randomizer")), nil
+	}
+	BigR := r.delta.Act(r.presig.R)
+	R := BigR.XScalar()
+	m := curve.FromHash(r.Group(), r.message)
+	chi := r.Group().NewScalar().Set(r.presig.KShare).Mul(r.adjust).Add(r.presig.ChiShare)
+	SigmaShare := r.Group().NewScalar().Set(R).Mul(chi).Add(m.Mul(r.presig.KShare))
+	SigmaShare.Mul(r.Group().NewScalar().Set(r.delta).Invert())
+	err := r.BroadcastMessage(out, &presignSignBroadcast2{SigmaShare: SigmaShare})
+	if err != nil {
+		return r, err
+	}
+	return &presignSignRound2{
+		presignSignRound1: r,
+		BigR:              BigR,
+		R:                 R,
+		SigmaShares:       map[party.ID]curve.Scalar{r.SelfID(): SigmaShare},
+	}, nil
+}
+
+func (presignSignRound1) MessageContent() round.Content { return nil }
+
+func (presignSignRound1) Number() round.Number { return 1 }
+
+type presignSignRound2 struct {
+	*presignSignRound1
+	BigR        curve.Point
+	R           curve.Scalar
+	SigmaShares map[party.ID]curve.Scalar
+}
+
+type presignSignBroadcast2 struct {
+	round.NormalBroadcastContent
+	SigmaShare curve.Scalar
+}
+
+// - verify [σⱼ]R' = [m]([kⱼ]R) + [r']([χⱼ]R + [adjust]([kⱼ]R))
+func (r *presignSignRound2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*presignSignBroadcast2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.SigmaShare.IsZero() {
+		return round.ErrNilFields
+	}
+	KR, ChiR := r.presig.KR[msg.From], r.presig.ChiR[msg.From]
+	if KR == nil || ChiR == nil {
+		return round.ErrNilFields
+	}
+	m := curve.FromHash(r.Group(), r.message)
+	expected := m.Act(KR).Add(r.R.Act(ChiR.Add(r.adjust.Act(KR))))
+	if !body.SigmaShare.Act(r.BigR).Equal(expected) {
+		return fmt.Errorf("invalid sigma share from %s", msg.From)
+	}
+	r.SigmaShares[msg.From] = body.SigmaShare
+	return nil
+}
+
+func (presignSignRound2) VerifyMessage(round.Message) error { return nil }
+
+func (presignSignRound2) StoreMessage(round.Message) error { return nil }
+
+func (r *presignSignRound2) Finalize(chan<- *round.Message) (round.Session, error) {
+	Sigma := r.Group().NewScalar()
+	for _, j := range r.PartyIDs() {
+		Sigma.Add(r.SigmaShares[j])
+	}
+	signature := &ecdsa.Signature{R: r.BigR, S: Sigma}
+	if !signature.Verify(r.public, r.message) {
+		return r.AbortRound(errors.New("failed to validate signature")), nil
+	}
+	return r.ResultRound(signature), nil
+}
+
+func (presignSignRound2) MessageContent() round.Content { return nil }
+
+func (r *presignSignRound2) BroadcastContent() round.BroadcastContent {
+	return &presignSignBroadcast2{SigmaShare: r.Group().NewScalar()}
+}
+
+func (presignSignRound2) Number() round.Number { return 2 }
+
+func (presignSignBroadcast2) RoundNumber() round.Number { return 2 }
+
+// startPresign runs a presign session of the key among the prepared members,
+// the presignature is saved but not usable until all members agree on its
+// digest through the signer MTG
+func (node *Node) startPresign(ctx context.Context, op *common.Operation, members []party.ID) error {
+	logger.Printf("node.startPresign(%v, %v)\n", op, members)
+	if !slices.Contains(members, node.id) {
+		logger.Printf("node.startPresign(%v, %v) exit without committement\n", op, members)
+		return nil
+	}
+	public, crv, share, _, err := node.readKeyByFingerPath(ctx, op.Public)
+	logger.Printf("node.readKeyByFingerPath(%s) => %s %v", op.Public, public, err)
+	if err != nil {
+		return fmt.Errorf("node.readKeyByFingerPath(%s) => %v", op.Public, err)
+	}
+	if public == "" || crv != op.Curve {
+		return node.store.FailSession(ctx, op.Id)
+	}
+
+	presig, err := node.cmpPresign(ctx, members, public, share, op.IdBytes())
+	logger.Printf("node.cmpPresign(%v) => %v", op, err)
+	if err != nil {
+		return node.store.FailSession(ctx, op.Id)
+	}
+	return node.store.WritePresignatureShare(ctx, op.Id, presig.MarshalBinary(), presig.digest())
+}
+
+func (node *Node) cmpPresign(ctx context.Context, members []party.ID, public string, share []byte, sessionId []byte) (*Presignature, error) {
+	logger.Printf("node.cmpPresign(%x, %s, %v)", sessionId, public, members)
+	conf := cmp.EmptyConfig(curve.Secp256k1{})
+	err := conf.UnmarshalBinary(share)
+	if err != nil {
+		panic(err)
+	}
+	if hex.EncodeToString(common.MarshalPanic(conf.PublicPoint())) != public {
+		panic(public)
+	}
+
+	start, err := cmpPresignSession(conf, members)(sessionId)
+	if err != nil {
+		return nil, fmt.Errorf("presign.Start(%x) => %v", sessionId, err)
+	}
+	res, err := node.handlerLoop(ctx, start, sessionId, presignRoundTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("node.handlerLoop(%x) => %v", sessionId, err)
+	}
+	return res.(*Presignature), nil
+}
+
+// cmpPresignSign signs the message with the presignature consumed by the
+// session in a single round, and the presignature share is erased no matter
+// the signing succeeds or not
+func (node *Node) cmpPresignSign(ctx context.Context, presignId string, public string, share []byte, m []byte, sessionId []byte, crv byte, path []byte) (*SignResult, error) {
+	logger.Printf("node.cmpPresignSign(%x, %s, %s, %x, %d, %x)", sessionId, presignId, public, m, crv, path)
+	group := curve.Secp256k1{}
+	b, err := node.store.ErasePresignatureShare(ctx, presignId)
+	if err != nil {
+		panic(err)
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("presignature %s not found", presignId)
+	}
+	presig, err := unmarshalPresignature(group, b)
+	if err != nil {
+		panic(err)
+	}
+
+	root := cmp.EmptyConfig(group)
+	err = root.UnmarshalBinary(share)
+	if err != nil {
+		panic(err)
+	}
+	if hex.EncodeToString(common.MarshalPanic(root.PublicPoint())) != public {
+		panic(public)
+	}
+	conf := root
+	for i := 0; i < int(path[0]); i++ {
+		conf, err = conf.DeriveBIP32(uint32(path[i+1]))
+		if err != nil {
+			return nil, fmt.Errorf("cmp.DeriveBIP32(%x, %d, %d) => %v", sessionId, i, path[i+1], err)
+		}
+	}
+	adjust := group.NewScalar().Set(conf.ECDSA).Sub(root.ECDSA)
+
+	start, err := cmpPresignSignSession(group, node.id, presig, conf.PublicPoint(), adjust, m)(sessionId)
+	if err != nil {
+		return nil, fmt.Errorf("presign.Sign(%x, %x) => %v", sessionId, m, err)
+	}
+	signResult, err := node.handlerLoop(ctx, start, sessionId, presignSignRoundTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("node.handlerLoop(%x) => %v", sessionId, err)
+	}
+	signature := signResult.(*ecdsa.Signature)
+	logger.Printf("node.cmpPresignSign(%x, %s, %x) => %v", sessionId, public, m, signature)
+	if !signature.Verify(conf.PublicPoint(), m) {
+		return nil, fmt.Errorf("node.cmpPresignSign(%x, %s, %x) => %v verify", sessionId, public, m, signature)
+	}
+
+	res := &SignResult{SSID: start.SSID()}
+	switch crv {
+	case common.CurveSecp256k1ECDSABitcoin:
+		res.Signature = signature.SerializeDER()
+	case common.CurveSecp256k1ECDSAEthereum:
+		res.Signature = signature.SerializeEthereum()
+	default:
+		panic(crv)
+	}
+	return res, nil
+}
+
+// finishPresignSession makes the presignature ready after all its members
+// agree on the digest, and the other nodes mark the session pending as well
+func (node *Node) finishPresignSession(ctx context.Context, session *Session, digest []byte, out *mtg.Action) {
+	members, err := node.store.ListSessionPreparedMembers(ctx, session.Id, node.threshold+1)
+	if err != nil {
+		panic(err)
+	}
+	err = node.store.MarkPresignatureReady(ctx, session.Id, members, digest, out.SequencerCreatedAt)
+	logger.Printf("store.MarkPresignatureReady(%v, %v) => %x %v", session, members, digest, err)
+	if err != nil {
+		panic(err)
+	}
+	if session.State == common.RequestStateInitial && session.PreparedAt.Valid {
+		err = node.store.MarkSessionPending(ctx, session.Id, session.Curve, session.Public, digest)
+		logger.Printf("store.MarkSessionPending(%v, finishPresignSession) => %x %v\n", session, digest, err)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// loopPresignatures fills the presignature pool of the recently signed keys
+// when there are no sign sessions in progress
+func (node *Node) loopPresignatures(ctx context.Context) {
+	for {
+		time.Sleep(10 * time.Second)
+		synced := node.synced(ctx)
+		if !synced {
+			logger.Printf("group.Synced(%s) => %t", node.group.GenesisId(), synced)
+			continue
+		}
+		busy, err := node.store.CheckSignSessionsInProgress(ctx)
+		if err != nil {
+			panic(err)
+		}
+		if busy {
+			continue
+		}
+		node.requestPresignatures(ctx)
+	}
+}
+
+// all nodes request the same next presign session of a key, and the signer
+// MTG creates it only once
+func (node *Node) requestPresignatures(ctx context.Context) {
+	keys, err := node.store.ListPresignKeys(ctx, time.Now().Add(-presignKeysWindow), presignKeysLimit)
+	if err != nil {
+		panic(err)
+	}
+	for _, k := range keys {
+		available, total, err := node.store.CountPresignatures(ctx, k.Fingerprint, time.Now().Add(-SessionTimeout))
+		if err != nil {
+			panic(err)
+		}
+		if available >= PresignPoolSize {
+			continue
+		}
+		op := &common.Operation{
+			Id:     presignSessionId(k.Fingerprint, total),
+			Type:   common.OperationTypePresignInput,
+			Curve:  k.Curve,
+			Public: k.Fingerprint + "00000000",
+			Extra:  []byte(PresignExtra),
+		}
+		err = node.sendSignerPresignTransaction(ctx, op)
+		logger.Printf("node.sendSignerPresignTransaction(%v) => %v", op, err)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func presignSessionId(fingerprint string, index int) string {
+	return common.UniqueId(fingerprint, fmt.Sprintf("PRESIGN:%d", index))
+}
+
+func (node *Node) sendSignerPresignTransaction(ctx context.Context, op *common.Operation) error {
+	extra := common.AESEncrypt(node.aesKey[:], op.Encode(), op.Id)
+	if len(extra) > 160 {
+		panic(fmt.Errorf("node.sendSignerPresignTransaction(%v) omitted %x", op, extra))
+	}
+	traceId := fmt.Sprintf("SESSION:%s:SIGNER:%s:PRESIGN", op.Id, string(node.id))
+
+	return node.sendTransactionToSignerGroupUntilSufficient(ctx, extra, traceId)
+}
+
+// processSignerPresign creates the next presign session of the key, if the
+// pool of the key is not full, and all members could prepare it then
+func (node *Node) processSignerPresign(ctx context.Context, op *common.Operation, out *mtg.Action) error {
+	if op.Type != common.OperationTypePresignInput {
+		return nil
+	}
+	switch op.Curve {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+	default:
+		return nil
+	}
+	if len(op.Public) != 24 || op.Public[16:] != "00000000" {
+		return nil
+	}
+	fingerprint := op.Public[:16]
+	public, crv, _, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+	if err != nil {
+		return fmt.Errorf("store.ReadKeyByFingerprint(%s) => %v", fingerprint, err)
+	}
+	if public == "" || crv != op.Curve {
+		return nil
+	}
+	available, total, err := node.store.CountPresignatures(ctx, fingerprint, out.SequencerCreatedAt.Add(-SessionTimeout))
+	if err != nil {
+		return fmt.Errorf("store.CountPresignatures(%s) => %v", fingerprint, err)
+	}
+	if available >= PresignPoolSize || op.Id != presignSessionId(fingerprint, total) {
+		return nil
+	}
+	hash, err := crypto.HashFromString(out.TransactionHash)
+	if err != nil {
+		panic(err)
+	}
+	return node.store.WritePresignSessionIfNotExist(ctx, op, public, hash, out.OutputIndex, out.SequencerCreatedAt)
+}
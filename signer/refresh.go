@@ -0,0 +1,327 @@
+package signer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/polynomial"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/protocol"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/MixinNetwork/safe/common"
+)
+
+const (
+	refreshProtocolId   = "safe/refresh-zero-sharing"
+	refreshRoundTimeout = 5 * time.Minute
+)
+
+type RefreshResult struct {
+	Share  []byte
+	Digest []byte
+	SSID   []byte
+}
+
+// the refresh protocol shares a random polynomial with zero constant among
+// all parties, then each party adds the received shares to its own, so that
+// all shares change while the public key and chain key remain the same
+type refreshOutput struct {
+	share   curve.Scalar
+	publics map[party.ID]curve.Point
+}
+
+func refreshSession(group curve.Curve, selfID party.ID, participants []party.ID, threshold int) protocol.StartFunc {
+	return func(sessionID []byte) (round.Session, error) {
+		info := round.Info{
+			ProtocolID:       refreshProtocolId,
+			FinalRoundNumber: 2,
+			SelfID:           selfID,
+			PartyIDs:         participants,
+			Threshold:        threshold,
+			Group:            group,
+		}
+		helper, err := round.NewSession(info, sessionID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("refresh.Start: %w", err)
+		}
+		return &refreshRound1{Helper: helper}, nil
+	}
+}
+
+type refreshRound1 struct {
+	*round.Helper
+}
+
+func (r *refreshRound1) VerifyMessage(round.Message) error { return nil }
+
+func (r *refreshRound1) StoreMessage(round.Message) error { return nil }
+
+func (r *refreshRound1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	f := polynomial.NewPolynomial(r.Group(), r.Threshold(), r.Group().NewScalar())
+	phi := polynomial.NewPolynomialExponent(f)
+	err := r.BroadcastMessage(out, &refreshBroadcast2{Phi: phi})
+	if err != nil {
+		return r, err
+	}
+	for _, j := range r.OtherPartyIDs() {
+		err := r.SendMessage(out, &refreshMessage2{Share: f.Evaluate(j.Scalar(r.Group()))}, j)
+		if err != nil {
+			return r, err
+		}
+	}
+	return &refreshRound2{
+		refreshRound1: r,
+		phi:           map[party.ID]*polynomial.Exponent{r.SelfID(): phi},
+		shares:        map[party.ID]curve.Scalar{r.SelfID(): f.Evaluate(r.SelfID().Scalar(r.Group()))},
+	}, nil
+}
+
+func (refreshRound1) MessageContent() round.Content { return nil }
+
+func (refreshRound1) Number() round.Number { return 1 }
+
+type refreshRound2 struct {
+	*refreshRound1
+	phi    map[party.ID]*polynomial.Exponent
+	shares map[party.ID]curve.Scalar
+}
+
+type refreshBroadcast2 struct {
+	round.NormalBroadcastContent
+	Phi *polynomial.Exponent
+}
+
+type refreshMessage2 struct {
+	Share curve.Scalar
+}
+
+func (r *refreshRound2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*refreshBroadcast2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.Phi == nil {
+		return round.ErrNilFields
+	}
+	if !body.Phi.IsConstant || body.Phi.Degree() != r.Threshold() {
+		return fmt.Errorf("invalid zero sharing from %s", msg.From)
+	}
+	r.phi[msg.From] = body.Phi
+	return nil
+}
+
+func (r *refreshRound2) VerifyMessage(msg round.Message) error {
+	body, ok := msg.Content.(*refreshMessage2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.Share == nil {
+		return round.ErrNilFields
+	}
+	return nil
+}
+
+func (r *refreshRound2) StoreMessage(msg round.Message) error {
+	body := msg.Content.(*refreshMessage2)
+	phi := r.phi[msg.From]
+	if phi == nil {
+		return fmt.Errorf("missing zero sharing from %s", msg.From)
+	}
+	expected := phi.Evaluate(r.SelfID().Scalar(r.Group()))
+	if !body.Share.ActOnBase().Equal(expected) {
+		return fmt.Errorf("invalid share from %s", msg.From)
+	}
+	r.shares[msg.From] = body.Share
+	return nil
+}
+
+func (r *refreshRound2) Finalize(chan<- *round.Message) (round.Session, error) {
+	share := r.Group().NewScalar()
+	for _, s := range r.shares {
+		share.Add(s)
+	}
+	exponents := make([]*polynomial.Exponent, 0, len(r.phi))
+	for _, j := range r.PartyIDs() {
+		exponents = append(exponents, r.phi[j])
+	}
+	sum, err := polynomial.Sum(exponents)
+	if err != nil {
+		return r.AbortRound(err), nil
+	}
+	publics := make(map[party.ID]curve.Point, len(r.PartyIDs()))
+	for _, j := range r.PartyIDs() {
+		publics[j] = sum.Evaluate(j.Scalar(r.Group()))
+	}
+	if !share.ActOnBase().Equal(publics[r.SelfID()]) {
+		return r.AbortRound(fmt.Errorf("invalid refresh share")), nil
+	}
+	return r.ResultRound(&refreshOutput{share: share, publics: publics}), nil
+}
+
+func (r *refreshRound2) MessageContent() round.Content {
+	return &refreshMessage2{Share: r.Group().NewScalar()}
+}
+
+func (r *refreshRound2) BroadcastContent() round.BroadcastContent {
+	return &refreshBroadcast2{Phi: polynomial.EmptyExponent(r.Group())}
+}
+
+func (refreshRound2) Number() round.Number { return 2 }
+
+func (refreshBroadcast2) RoundNumber() round.Number { return 2 }
+
+func (refreshMessage2) RoundNumber() round.Number { return 2 }
+
+func (node *Node) startRefresh(ctx context.Context, op *common.Operation) error {
+	logger.Printf("node.startRefresh(%v)", op)
+	public, crv, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(op.Public)))
+	if err != nil {
+		return fmt.Errorf("store.ReadKeyByFingerprint(%s) => %v", op.Public, err)
+	}
+	if public != op.Public || crv != op.Curve {
+		return node.store.FailSession(ctx, op.Id)
+	}
+
+	res, err := node.refreshShare(ctx, op.IdBytes(), crv, public, share)
+	logger.Printf("node.refreshShare(%v) => %v", op, err)
+	if err != nil {
+		return node.store.FailSession(ctx, op.Id)
+	}
+	return node.store.WriteKeyRefreshIfNotExists(ctx, op.Id, public, res.Share, res.Digest)
+}
+
+func (node *Node) refreshShare(ctx context.Context, sessionId []byte, crv byte, public string, share []byte) (*RefreshResult, error) {
+	switch crv {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+		// only the ecdsa shares are refreshed, the paillier, pedersen and
+		// elgamal keys are kept, and they are only rotated by a reshare
+		conf := cmp.EmptyConfig(curve.Secp256k1{})
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			panic(err)
+		}
+		res, ssid, err := node.refreshLoop(ctx, sessionId, conf.Group, conf.PartyIDs(), conf.Threshold)
+		if err != nil {
+			return nil, err
+		}
+		conf.ECDSA.Add(res.share)
+		publics := make(map[party.ID]curve.Point, len(conf.Public))
+		for id, p := range conf.Public {
+			p.ECDSA = p.ECDSA.Add(res.publics[id])
+			publics[id] = p.ECDSA
+		}
+		pb := common.MarshalPanic(interpolatePublic(conf.Group, publics))
+		return buildRefreshResult(public, hex.EncodeToString(pb), publics, conf, ssid)
+	case common.CurveSecp256k1SchnorrBitcoin:
+		group := curve.Secp256k1{}
+		conf := &frost.TaprootConfig{PrivateShare: group.NewScalar()}
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			panic(err)
+		}
+		ids := make([]party.ID, 0, len(conf.VerificationShares))
+		for id := range conf.VerificationShares {
+			ids = append(ids, id)
+		}
+		res, ssid, err := node.refreshLoop(ctx, sessionId, group, ids, conf.Threshold)
+		if err != nil {
+			return nil, err
+		}
+		conf.PrivateShare.Add(res.share)
+		for id, p := range conf.VerificationShares {
+			conf.VerificationShares[id] = p.Add(res.publics[id])
+		}
+		pb := common.MarshalPanic(interpolatePublic(group, conf.VerificationShares))
+		return buildRefreshResult(public, hex.EncodeToString(pb[1:]), conf.VerificationShares, conf, ssid)
+	case common.CurveEdwards25519Mixin, common.CurveEdwards25519Default:
+		group := curve.Edwards25519{}
+		conf := frost.EmptyConfig(group)
+		err := conf.UnmarshalBinary(share)
+		if err != nil {
+			panic(err)
+		}
+		points := conf.VerificationShares.Points
+		ids := make([]party.ID, 0, len(points))
+		for id := range points {
+			ids = append(ids, id)
+		}
+		res, ssid, err := node.refreshLoop(ctx, sessionId, group, ids, conf.Threshold)
+		if err != nil {
+			return nil, err
+		}
+		conf.PrivateShare.Add(res.share)
+		for id, p := range points {
+			points[id] = p.Add(res.publics[id])
+		}
+		pb := common.MarshalPanic(interpolatePublic(group, points))
+		return buildRefreshResult(public, hex.EncodeToString(pb), points, conf, ssid)
+	default:
+		panic(crv)
+	}
+}
+
+func (node *Node) refreshLoop(ctx context.Context, sessionId []byte, group curve.Curve, ids []party.ID, threshold int) (*refreshOutput, []byte, error) {
+	logger.Printf("node.refreshLoop(%x, %v)", sessionId, ids)
+	parties := party.NewIDSlice(ids)
+	if !slices.Equal(parties, node.GetPartySlice()) {
+		return nil, nil, fmt.Errorf("node.refreshLoop(%x) members %v", sessionId, parties)
+	}
+	start, err := refreshSession(group, node.id, parties, threshold)(sessionId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("refresh.Start(%x) => %v", sessionId, err)
+	}
+	res, err := node.handlerLoop(ctx, start, sessionId, refreshRoundTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("node.handlerLoop(%x) => %v", sessionId, err)
+	}
+	return res.(*refreshOutput), start.SSID(), nil
+}
+
+func interpolatePublic(group curve.Curve, publics map[party.ID]curve.Point) curve.Point {
+	ids := make([]party.ID, 0, len(publics))
+	for id := range publics {
+		ids = append(ids, id)
+	}
+	sum := group.NewPoint()
+	l := polynomial.Lagrange(group, ids)
+	for id, p := range publics {
+		sum = sum.Add(l[id].Act(p))
+	}
+	return sum
+}
+
+// the digest is the same for all parties with consistent refreshed shares,
+// and is used to agree on the refresh result before replacing any share
+func buildRefreshResult(public, refreshed string, publics map[party.ID]curve.Point, conf encoding.BinaryMarshaler, ssid []byte) (*RefreshResult, error) {
+	if refreshed != public {
+		return nil, fmt.Errorf("refresh public %s => %s", public, refreshed)
+	}
+
+	ids := make([]string, 0, len(publics))
+	for id := range publics {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+	h := sha256.New()
+	h.Write([]byte(public))
+	for _, id := range ids {
+		h.Write([]byte(id))
+		h.Write(common.MarshalPanic(publics[party.ID(id)]))
+	}
+
+	return &RefreshResult{
+		Share:  common.MarshalPanic(conf),
+		Digest: h.Sum(nil),
+		SSID:   ssid,
+	}, nil
+}
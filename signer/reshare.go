@@ -0,0 +1,430 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/math/polynomial"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/multi-party-sig/pkg/protocol"
+	"github.com/MixinNetwork/multi-party-sig/pkg/taproot"
+	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
+	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/gofrs/uuid/v5"
+)
+
+const (
+	reshareProtocolId   = "safe/reshare-committee"
+	reshareRoundTimeout = 5 * time.Minute
+)
+
+// the old sharing of a dealer, all dealers must have the same threshold,
+// public shares and chain key, i.e. the same digest
+type reshareDealer struct {
+	share     curve.Scalar
+	threshold int
+	publics   map[party.ID]curve.Point
+	chainKey  []byte
+}
+
+func (d *reshareDealer) digest() []byte {
+	ids := make([]string, 0, len(d.publics))
+	for id := range d.publics {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+	h := sha256.New()
+	h.Write([]byte{byte(d.threshold)})
+	for _, id := range ids {
+		h.Write([]byte(id))
+		h.Write(common.MarshalPanic(d.publics[party.ID(id)]))
+	}
+	h.Write(d.chainKey)
+	return h.Sum(nil)
+}
+
+// the dealers are the old parties still in the new committee, each of them
+// shares its old share to the new committee with a polynomial of the new
+// threshold, then the new parties interpolate the received shares with the
+// lagrange coefficients of the dealers, so the secret remains the same
+type reshareOutput struct {
+	share    curve.Scalar
+	publics  map[party.ID]curve.Point
+	chainKey []byte
+}
+
+func reshareSession(group curve.Curve, selfID party.ID, participants []party.ID, threshold int, public curve.Point, dealer *reshareDealer) protocol.StartFunc {
+	return func(sessionID []byte) (round.Session, error) {
+		info := round.Info{
+			ProtocolID:       reshareProtocolId,
+			FinalRoundNumber: 2,
+			SelfID:           selfID,
+			PartyIDs:         participants,
+			Threshold:        threshold,
+			Group:            group,
+		}
+		helper, err := round.NewSession(info, sessionID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("reshare.Start: %w", err)
+		}
+		return &reshareRound1{Helper: helper, public: public, dealer: dealer}, nil
+	}
+}
+
+type reshareRound1 struct {
+	*round.Helper
+	public curve.Point
+	dealer *reshareDealer
+}
+
+func (r *reshareRound1) VerifyMessage(round.Message) error { return nil }
+
+func (r *reshareRound1) StoreMessage(round.Message) error { return nil }
+
+func (r *reshareRound1) Finalize(out chan<- *round.Message) (round.Session, error) {
+	next := &reshareRound2{
+		reshareRound1: r,
+		dealers:       make(map[party.ID]*reshareBroadcast2),
+		shares:        make(map[party.ID]curve.Scalar),
+	}
+	if r.dealer == nil {
+		err := r.BroadcastMessage(out, &reshareBroadcast2{Phi: polynomial.EmptyExponent(r.Group())})
+		if err != nil {
+			return r, err
+		}
+		for _, j := range r.OtherPartyIDs() {
+			err := r.SendMessage(out, &reshareMessage2{Share: r.Group().NewScalar()}, j)
+			if err != nil {
+				return r, err
+			}
+		}
+		return next, nil
+	}
+
+	f := polynomial.NewPolynomial(r.Group(), r.Threshold(), r.dealer.share)
+	body := &reshareBroadcast2{
+		Dealer:    true,
+		Threshold: r.dealer.threshold,
+		Digest:    r.dealer.digest(),
+		ChainKey:  r.dealer.chainKey,
+		Phi:       polynomial.NewPolynomialExponent(f),
+	}
+	err := r.BroadcastMessage(out, body)
+	if err != nil {
+		return r, err
+	}
+	for _, j := range r.OtherPartyIDs() {
+		err := r.SendMessage(out, &reshareMessage2{Share: f.Evaluate(j.Scalar(r.Group()))}, j)
+		if err != nil {
+			return r, err
+		}
+	}
+	next.dealers[r.SelfID()] = body
+	next.shares[r.SelfID()] = f.Evaluate(r.SelfID().Scalar(r.Group()))
+	return next, nil
+}
+
+func (reshareRound1) MessageContent() round.Content { return nil }
+
+func (reshareRound1) Number() round.Number { return 1 }
+
+type reshareRound2 struct {
+	*reshareRound1
+	dealers map[party.ID]*reshareBroadcast2
+	shares  map[party.ID]curve.Scalar
+}
+
+type reshareBroadcast2 struct {
+	round.NormalBroadcastContent
+	Dealer    bool
+	Threshold int
+	Digest    []byte
+	ChainKey  []byte
+	Phi       *polynomial.Exponent
+}
+
+type reshareMessage2 struct {
+	Share curve.Scalar
+}
+
+func (r *reshareRound2) StoreBroadcastMessage(msg round.Message) error {
+	body, ok := msg.Content.(*reshareBroadcast2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if !body.Dealer {
+		return nil
+	}
+	if body.Phi == nil {
+		return round.ErrNilFields
+	}
+	if body.Phi.IsConstant || body.Phi.Degree() != r.Threshold() {
+		return fmt.Errorf("invalid reshare polynomial from %s", msg.From)
+	}
+	if r.dealer != nil && r.dealer.publics[msg.From] == nil {
+		return fmt.Errorf("unknown reshare dealer %s", msg.From)
+	}
+	if r.dealer != nil && !body.Phi.Constant().Equal(r.dealer.publics[msg.From]) {
+		return fmt.Errorf("invalid reshare public from %s", msg.From)
+	}
+	r.dealers[msg.From] = body
+	return nil
+}
+
+func (r *reshareRound2) VerifyMessage(msg round.Message) error {
+	body, ok := msg.Content.(*reshareMessage2)
+	if !ok || body == nil {
+		return round.ErrInvalidContent
+	}
+	if body.Share == nil {
+		return round.ErrNilFields
+	}
+	return nil
+}
+
+func (r *reshareRound2) StoreMessage(msg round.Message) error {
+	body := msg.Content.(*reshareMessage2)
+	dealer := r.dealers[msg.From]
+	if dealer == nil {
+		return nil
+	}
+	expected := dealer.Phi.Evaluate(r.SelfID().Scalar(r.Group()))
+	if !body.Share.ActOnBase().Equal(expected) {
+		return fmt.Errorf("invalid reshare share from %s", msg.From)
+	}
+	r.shares[msg.From] = body.Share
+	return nil
+}
+
+func (r *reshareRound2) Finalize(chan<- *round.Message) (round.Session, error) {
+	var first *reshareBroadcast2
+	dealers := make([]party.ID, 0, len(r.dealers))
+	for _, id := range r.PartyIDs() {
+		d := r.dealers[id]
+		if d == nil {
+			continue
+		}
+		if first == nil {
+			first = d
+		}
+		if d.Threshold != first.Threshold || !bytes.Equal(d.Digest, first.Digest) || !bytes.Equal(d.ChainKey, first.ChainKey) {
+			return r.AbortRound(fmt.Errorf("inconsistent reshare dealer %s", id), id), nil
+		}
+		dealers = append(dealers, id)
+	}
+	// the old shares could only be interpolated by more than the old
+	// threshold dealers, so the old members retained must be enough
+	if first == nil || len(dealers) <= first.Threshold {
+		return r.AbortRound(fmt.Errorf("insufficient reshare dealers %v", dealers)), nil
+	}
+	if r.dealer != nil && !bytes.Equal(r.dealer.digest(), first.Digest) {
+		return r.AbortRound(fmt.Errorf("inconsistent reshare digest %x", first.Digest)), nil
+	}
+
+	l := polynomial.Lagrange(r.Group(), dealers)
+	share := r.Group().NewScalar()
+	public := r.Group().NewPoint()
+	publics := make(map[party.ID]curve.Point, len(r.PartyIDs()))
+	for _, j := range r.PartyIDs() {
+		publics[j] = r.Group().NewPoint()
+	}
+	for _, i := range dealers {
+		share.Add(r.Group().NewScalar().Set(l[i]).Mul(r.shares[i]))
+		phi := r.dealers[i].Phi
+		public = public.Add(l[i].Act(phi.Constant()))
+		for _, j := range r.PartyIDs() {
+			publics[j] = publics[j].Add(l[i].Act(phi.Evaluate(j.Scalar(r.Group()))))
+		}
+	}
+	if !public.Equal(r.public) {
+		return r.AbortRound(fmt.Errorf("reshare public changed")), nil
+	}
+	if !share.ActOnBase().Equal(publics[r.SelfID()]) {
+		return r.AbortRound(fmt.Errorf("invalid reshare share")), nil
+	}
+	return r.ResultRound(&reshareOutput{share: share, publics: publics, chainKey: first.ChainKey}), nil
+}
+
+func (r *reshareRound2) MessageContent() round.Content {
+	return &reshareMessage2{Share: r.Group().NewScalar()}
+}
+
+func (r *reshareRound2) BroadcastContent() round.BroadcastContent {
+	return &reshareBroadcast2{Phi: polynomial.EmptyExponent(r.Group())}
+}
+
+func (reshareRound2) Number() round.Number { return 2 }
+
+func (reshareBroadcast2) RoundNumber() round.Number { return 2 }
+
+func (reshareMessage2) RoundNumber() round.Number { return 2 }
+
+func (node *Node) startReshare(ctx context.Context, op *common.Operation) error {
+	logger.Printf("node.startReshare(%v)", op)
+	committee := common.EncodeSignerCommittee(node.GetMembers(), node.threshold)
+	if !bytes.Equal(op.Extra, committee) {
+		logger.Printf("node.startReshare(%v) committee %x", op, committee)
+		return node.store.FailSession(ctx, op.Id)
+	}
+	public, crv, share, err := node.store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(op.Public)))
+	if err != nil {
+		return fmt.Errorf("store.ReadKeyByFingerprint(%s) => %v", op.Public, err)
+	}
+	if public != "" && (public != op.Public || crv != op.Curve) {
+		return node.store.FailSession(ctx, op.Id)
+	}
+
+	res, err := node.reshareKey(ctx, op, share)
+	logger.Printf("node.reshareKey(%v) => %v", op, err)
+	if err != nil {
+		return node.store.FailSession(ctx, op.Id)
+	}
+	return node.store.WriteKeyRefreshIfNotExists(ctx, op.Id, op.Public, res.Share, res.Digest)
+}
+
+// reshareKey transfers the key to the current members and threshold, the
+// share is nil if this node is not in the old party set of the key
+func (node *Node) reshareKey(ctx context.Context, op *common.Operation, share []byte) (*RefreshResult, error) {
+	pub := common.DecodeHexOrPanic(op.Public)
+	switch op.Curve {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+		group := curve.Secp256k1{}
+		var dealer *reshareDealer
+		if share != nil {
+			conf := cmp.EmptyConfig(group)
+			err := conf.UnmarshalBinary(share)
+			if err != nil {
+				panic(err)
+			}
+			dealer = &reshareDealer{share: conf.ECDSA, threshold: conf.Threshold, chainKey: conf.ChainKey}
+			dealer.publics = make(map[party.ID]curve.Point, len(conf.Public))
+			for id, p := range conf.Public {
+				dealer.publics[id] = p.ECDSA
+			}
+		}
+		P := group.NewPoint()
+		err := P.UnmarshalBinary(pub)
+		if err != nil {
+			return nil, err
+		}
+
+		// the new parties need their own paillier, pedersen and elgamal keys,
+		// so a cmp keygen of the new committee generates them, then its secret
+		// shares are replaced by the reshared ones
+		auxId := common.UniqueId(op.Id, "RESHARE:AUX")
+		aux, err := node.cmpKeygen(ctx, uuid.Must(uuid.FromString(auxId)).Bytes(), op.Curve)
+		if err != nil {
+			return nil, err
+		}
+		conf := cmp.EmptyConfig(group)
+		err = conf.UnmarshalBinary(aux.Share)
+		if err != nil {
+			panic(err)
+		}
+
+		res, ssid, err := node.reshareLoop(ctx, op.IdBytes(), group, P, dealer)
+		if err != nil {
+			return nil, err
+		}
+		conf.ECDSA = res.share
+		conf.ChainKey = res.chainKey
+		for id, p := range conf.Public {
+			p.ECDSA = res.publics[id]
+		}
+		pb := common.MarshalPanic(interpolatePublic(group, res.publics))
+		return buildRefreshResult(op.Public, hex.EncodeToString(pb), res.publics, conf, ssid)
+	case common.CurveSecp256k1SchnorrBitcoin:
+		group := curve.Secp256k1{}
+		var dealer *reshareDealer
+		if share != nil {
+			conf := &frost.TaprootConfig{PrivateShare: group.NewScalar()}
+			err := conf.UnmarshalBinary(share)
+			if err != nil {
+				panic(err)
+			}
+			dealer = &reshareDealer{
+				share:     conf.PrivateShare,
+				threshold: conf.Threshold,
+				publics:   conf.VerificationShares,
+				chainKey:  conf.ChainKey,
+			}
+		}
+		P, err := group.LiftX(pub)
+		if err != nil {
+			return nil, err
+		}
+		res, ssid, err := node.reshareLoop(ctx, op.IdBytes(), group, P, dealer)
+		if err != nil {
+			return nil, err
+		}
+		conf := &frost.TaprootConfig{
+			ID:                 node.id,
+			Threshold:          node.threshold,
+			PrivateShare:       res.share,
+			PublicKey:          taproot.PublicKey(pub),
+			ChainKey:           res.chainKey,
+			VerificationShares: res.publics,
+		}
+		pb := common.MarshalPanic(interpolatePublic(group, res.publics))
+		return buildRefreshResult(op.Public, hex.EncodeToString(pb[1:]), res.publics, conf, ssid)
+	case common.CurveEdwards25519Mixin, common.CurveEdwards25519Default:
+		group := curve.Edwards25519{}
+		var dealer *reshareDealer
+		if share != nil {
+			conf := frost.EmptyConfig(group)
+			err := conf.UnmarshalBinary(share)
+			if err != nil {
+				panic(err)
+			}
+			dealer = &reshareDealer{
+				share:     conf.PrivateShare,
+				threshold: conf.Threshold,
+				publics:   conf.VerificationShares.Points,
+				chainKey:  conf.ChainKey,
+			}
+		}
+		P := group.NewPoint()
+		err := P.UnmarshalBinary(pub)
+		if err != nil {
+			return nil, err
+		}
+		res, ssid, err := node.reshareLoop(ctx, op.IdBytes(), group, P, dealer)
+		if err != nil {
+			return nil, err
+		}
+		conf := &frost.Config{
+			ID:                 node.id,
+			Threshold:          node.threshold,
+			PrivateShare:       res.share,
+			PublicKey:          P,
+			ChainKey:           res.chainKey,
+			VerificationShares: party.NewPointMap(res.publics),
+		}
+		pb := common.MarshalPanic(interpolatePublic(group, res.publics))
+		return buildRefreshResult(op.Public, hex.EncodeToString(pb), res.publics, conf, ssid)
+	default:
+		panic(op.Curve)
+	}
+}
+
+func (node *Node) reshareLoop(ctx context.Context, sessionId []byte, group curve.Curve, public curve.Point, dealer *reshareDealer) (*reshareOutput, []byte, error) {
+	logger.Printf("node.reshareLoop(%x, %t)", sessionId, dealer != nil)
+	start, err := reshareSession(group, node.id, node.GetPartySlice(), node.threshold, public, dealer)(sessionId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reshare.Start(%x) => %v", sessionId, err)
+	}
+	res, err := node.handlerLoop(ctx, start, sessionId, reshareRoundTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("node.handlerLoop(%x) => %v", sessionId, err)
+	}
+	return res.(*reshareOutput), start.SSID(), nil
+}
@@ -19,6 +19,35 @@ CREATE TABLE IF NOT EXISTS keys (
 CREATE UNIQUE INDEX IF NOT EXISTS keys_by_session_id ON keys(session_id);
 CREATE UNIQUE INDEX IF NOT EXISTS keys_by_fingerprint ON keys(fingerprint);
 
+CREATE TABLE IF NOT EXISTS key_refreshes (
+	session_id    VARCHAR NOT NULL,
+	public        VARCHAR NOT NULL,
+	share         VARCHAR NOT NULL,
+	digest        VARCHAR NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	committed_at  TIMESTAMP,
+	PRIMARY KEY ('session_id')
+);
+
+CREATE INDEX IF NOT EXISTS key_refreshes_by_public ON key_refreshes(public);
+
+CREATE TABLE IF NOT EXISTS presignatures (
+	session_id    VARCHAR NOT NULL,
+	public        VARCHAR NOT NULL,
+	fingerprint   VARCHAR NOT NULL,
+	members       VARCHAR NOT NULL,
+	share         VARCHAR NOT NULL,
+	digest        VARCHAR NOT NULL,
+	created_at    TIMESTAMP NOT NULL,
+	ready_at      TIMESTAMP,
+	consumed_by   VARCHAR,
+	consumed_at   TIMESTAMP,
+	PRIMARY KEY ('session_id')
+);
+
+CREATE INDEX IF NOT EXISTS presignatures_by_fingerprint_created ON presignatures(fingerprint, created_at);
+CREATE INDEX IF NOT EXISTS presignatures_by_consumed_by ON presignatures(consumed_by);
+
 CREATE TABLE IF NOT EXISTS sessions (
 	session_id    VARCHAR NOT NULL,
 	mixin_hash    VARCHAR NOT NULL,
@@ -49,6 +78,28 @@ CREATE TABLE IF NOT EXISTS session_signers (
 );
 
 
+CREATE TABLE IF NOT EXISTS batch_signatures (
+	session_id     VARCHAR NOT NULL,
+	signer_id      VARCHAR NOT NULL,
+	message_index  INTEGER NOT NULL,
+	signature      VARCHAR NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	PRIMARY KEY ('session_id', 'signer_id', 'message_index')
+);
+
+
+CREATE TABLE IF NOT EXISTS session_culprits (
+	session_id   VARCHAR NOT NULL,
+	reporter_id  VARCHAR NOT NULL,
+	signer_id    VARCHAR NOT NULL,
+	reason       INTEGER NOT NULL,
+	created_at   TIMESTAMP NOT NULL,
+	PRIMARY KEY ('session_id', 'reporter_id', 'signer_id')
+);
+
+CREATE INDEX IF NOT EXISTS session_culprits_by_signer_created ON session_culprits(signer_id, created_at);
+
+
 CREATE TABLE IF NOT EXISTS session_works (
 	session_id  VARCHAR NOT NULL,
 	signer_id   VARCHAR NOT NULL,
@@ -0,0 +1,93 @@
+package signer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MixinNetwork/safe/common"
+	"golang.org/x/crypto/scrypt"
+)
+
+const shareKeyIdPropertyKey = "SHARE:KEY:ID"
+
+// ShareEncryptionKey returns the key to encrypt the shares at rest, either
+// from the local key file with the 32 bytes key in hex, or derived from the
+// operator passphrase. It returns nil when neither is set, then the shares
+// are stored in plaintext.
+func ShareEncryptionKey(keyFile, passphrase, appId string) ([]byte, error) {
+	switch {
+	case keyFile != "" && passphrase != "":
+		return nil, fmt.Errorf("both share key file and passphrase")
+	case keyFile != "":
+		data, err := os.ReadFile(common.ExpandTilde(keyFile))
+		if err != nil {
+			return nil, err
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid share key file %s", keyFile)
+		}
+		return key, nil
+	case passphrase != "":
+		salt := []byte("MIXIN:SAFE:SIGNER:SHARE:" + appId)
+		return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	}
+	return nil, nil
+}
+
+// shareKeyId identifies the key without leaking it, and is empty for the
+// plaintext shares.
+func shareKeyId(key []byte) string {
+	if key == nil {
+		return ""
+	}
+	sum := sha256.Sum256(append([]byte(shareKeyIdPropertyKey), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// the share is sealed with the public key as additional data, so that an
+// encrypted share could not be moved to another key row
+func encryptShare(key []byte, public string, share []byte) string {
+	if key == nil {
+		return common.Base91Encode(share)
+	}
+	aead := newShareAEAD(key)
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		panic(err)
+	}
+	b := aead.Seal(nonce, nonce, share, []byte(public))
+	return common.Base91Encode(b)
+}
+
+func decryptShare(key []byte, public, share string) ([]byte, error) {
+	b, err := common.Base91Decode(share)
+	if err != nil || key == nil {
+		return b, err
+	}
+	aead := newShareAEAD(key)
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid share of %s", public)
+	}
+	nonce, sealed := b[:aead.NonceSize()], b[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(public))
+}
+
+func newShareAEAD(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
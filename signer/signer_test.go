@@ -3,22 +3,29 @@ package signer
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/common/round"
+	"github.com/MixinNetwork/multi-party-sig/pkg/ecdsa"
 	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
 	"github.com/MixinNetwork/multi-party-sig/protocols/cmp"
 	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/saver"
 	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/cronokirby/saferith"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
@@ -29,6 +36,22 @@ func TestCMPSigner(t *testing.T) {
 	ctx, nodes, saverStore := TestPrepare(require)
 	public, chainCode := testCMPKeyGen(ctx, require, nodes, common.CurveSecp256k1ECDSABitcoin)
 	testSaverItemsCheck(ctx, require, nodes, saverStore, 1)
+	testSaverRestore(ctx, require, nodes, public)
+	testSignerRefresh(ctx, require, nodes, public, common.CurveSecp256k1ECDSABitcoin)
+	for _, node := range nodes {
+		keys, err := node.store.ListUnbackupedKeys(ctx, 100)
+		require.Nil(err)
+		require.Len(keys, 1)
+		share, err := common.Base91Decode(keys[0].Share)
+		require.Nil(err)
+		saved, err := node.sendKeygenBackup(ctx, keys[0].asOperation(), share)
+		require.Nil(err)
+		require.True(saved)
+		err = node.store.MarkKeyBackuped(ctx, public)
+		require.Nil(err)
+	}
+	testSaverItemsCheck(ctx, require, nodes, saverStore, 2)
+	testSaverRestore(ctx, require, nodes, public)
 
 	sig := testCMPSign(ctx, require, nodes, public, []byte("mixin"), common.CurveSecp256k1ECDSABitcoin)
 	t.Logf("testCMPSign(%s) => %x\n", public, sig)
@@ -68,6 +91,190 @@ func TestCMPSigner(t *testing.T) {
 	require.Nil(err)
 }
 
+func TestCMPReshare(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+	crv := byte(common.CurveSecp256k1ECDSABitcoin)
+	testCommitteeChange(nodes, nodes[:3], 1)
+	public, chainCode := testCMPKeyGen(ctx, require, nodes[:3], crv)
+
+	testCommitteeChange(nodes, nodes, 2)
+	testSignerReshare(ctx, require, nodes, public, crv)
+
+	sig := testCMPSign(ctx, require, nodes, public, []byte("reshare"), crv)
+	err := bitcoin.VerifySignatureDER(public, []byte("reshare"), sig)
+	require.Nil(err)
+
+	path := []byte{1, 123, 0, 0}
+	sig = testCMPSignWithPath(ctx, require, nodes, public, []byte("reshare"), crv, path)
+	_, cp, err := bitcoin.DeriveBIP32(public, chainCode, 123)
+	require.Nil(err)
+	err = bitcoin.VerifySignatureDER(cp, []byte("reshare"), sig)
+	require.Nil(err)
+}
+
+func TestCMPPresign(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+	crv := byte(common.CurveSecp256k1ECDSABitcoin)
+	public, chainCode := TestCMPPrepareKeys(ctx, require, nodes, crv)
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+
+	sig := testCMPSign(ctx, require, nodes, public, []byte("online"), crv)
+	err := bitcoin.VerifySignatureDER(public, []byte("online"), sig)
+	require.Nil(err)
+
+	for _, node := range nodes {
+		node.requestPresignatures(ctx)
+	}
+	presignId := presignSessionId(fingerprint, 0)
+	for _, node := range nodes {
+		testWaitPresignature(ctx, require, node, presignId)
+	}
+
+	msg, path := []byte("presign"), []byte{1, 123, 0, 0}
+	sig = testCMPSignWithPath(ctx, require, nodes, public, msg, crv, path)
+	_, cp, err := bitcoin.DeriveBIP32(public, common.DecodeHexOrPanic(chainCode), 123)
+	require.Nil(err)
+	err = bitcoin.VerifySignatureDER(cp, msg, sig)
+	require.Nil(err)
+
+	sid := common.UniqueId(common.UniqueId("sign", hex.EncodeToString(msg)), hex.EncodeToString(path))
+	for _, node := range nodes {
+		consumed, err := node.store.ReadSessionPresignature(ctx, sid)
+		require.Nil(err)
+		require.Equal(presignId, consumed)
+		share, err := node.store.ErasePresignatureShare(ctx, presignId)
+		require.Nil(err)
+		require.Len(share, 0)
+	}
+}
+
+func TestCMPPresignAdversary(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+	crv := byte(common.CurveSecp256k1ECDSABitcoin)
+	public, _ := TestCMPPrepareKeys(ctx, require, nodes, crv)
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+
+	group := curve.Secp256k1{}
+	var members []party.ID
+	confs := make(map[party.ID]*cmp.Config)
+	for _, node := range nodes[:nodes[0].threshold+1] {
+		_, _, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		conf := cmp.EmptyConfig(group)
+		err = conf.UnmarshalBinary(share)
+		require.Nil(err)
+		confs[node.id] = conf
+		members = append(members, node.id)
+	}
+	presign := func(sid string, tamper func(*round.Message)) (map[party.ID]*Presignature, error) {
+		sessions := make(map[party.ID]round.Session)
+		for id, conf := range confs {
+			start, err := cmpPresignSession(conf, members)([]byte(sid))
+			require.Nil(err)
+			sessions[id] = start
+		}
+		results, err := testRunRounds(sessions, tamper)
+		if err != nil {
+			return nil, err
+		}
+		presigs := make(map[party.ID]*Presignature)
+		for id, r := range results {
+			presigs[id] = r.(*Presignature)
+		}
+		return presigs, nil
+	}
+
+	// the MtA proof for Chi can't be used for Delta
+	_, err := presign("presign-bad-mta", func(msg *round.Message) {
+		body, ok := msg.Content.(*presignMessage3)
+		if ok && msg.From == members[1] {
+			body.DeltaProof = body.ChiProof
+		}
+	})
+	require.ErrorContains(err, "failed to validate affg proof for Delta MtA")
+
+	// [kⱼ]R and [χⱼ]R are proved by each member in round 5
+	one := group.NewScalar().SetNat(new(saferith.Nat).SetUint64(1))
+	_, err = presign("presign-bad-kr", func(msg *round.Message) {
+		body, ok := msg.Content.(*presignBroadcast5)
+		if ok && msg.From == members[1] {
+			body.KR = body.KR.Add(one.ActOnBase())
+		}
+	})
+	require.ErrorContains(err, "failed to validate log proof for KR")
+	_, err = presign("presign-bad-chir", func(msg *round.Message) {
+		body, ok := msg.Content.(*presignBroadcast5)
+		if ok && msg.From == members[1] {
+			body.ChiR = body.ChiR.Add(one.ActOnBase())
+		}
+	})
+	require.ErrorContains(err, "failed to validate dleq proof for ChiR")
+
+	presigs, err := presign("presign", nil)
+	require.Nil(err)
+	for _, id := range members {
+		require.Equal(presigs[members[0]].digest(), presigs[id].digest())
+	}
+
+	root := confs[members[0]]
+	derived, err := root.DeriveBIP32(123)
+	require.Nil(err)
+	adjust := group.NewScalar().Set(derived.ECDSA).Sub(root.ECDSA)
+	sign := func(sid string, msg []byte, tamper func(*round.Message)) (*ecdsa.Signature, error) {
+		sessions := make(map[party.ID]round.Session)
+		for _, id := range members {
+			start, err := cmpPresignSignSession(group, id, presigs[id], derived.PublicPoint(), adjust, msg)([]byte(sid))
+			require.Nil(err)
+			sessions[id] = start
+		}
+		results, err := testRunRounds(sessions, tamper)
+		if err != nil {
+			return nil, err
+		}
+		return results[members[0]].(*ecdsa.Signature), nil
+	}
+
+	// the invalid σⱼ is identified with [kⱼ]R and [χⱼ]R
+	msg := []byte("presign")
+	_, err = sign("presign-bad-sigma", msg, func(msg *round.Message) {
+		body, ok := msg.Content.(*presignSignBroadcast2)
+		if ok && msg.From == members[2] {
+			body.SigmaShare = group.NewScalar().Set(body.SigmaShare).Add(one)
+		}
+	})
+	require.ErrorContains(err, fmt.Sprintf("invalid sigma share from %s", members[2]))
+
+	sig, err := sign("presign-sign", msg, nil)
+	require.Nil(err)
+	require.True(sig.Verify(derived.PublicPoint(), msg))
+	require.False(sig.R.Equal(presigs[members[0]].R))
+	other, err := sign("presign-sign", []byte("other"), nil)
+	require.Nil(err)
+	require.True(other.Verify(derived.PublicPoint(), []byte("other")))
+	require.False(other.R.Equal(sig.R))
+}
+
+func TestCMPBatchSign(t *testing.T) {
+	require := require.New(t)
+	ctx, nodes, _ := TestPrepare(require)
+	crv := byte(common.CurveSecp256k1ECDSABitcoin)
+	public, chainCode := TestCMPPrepareKeys(ctx, require, nodes, crv)
+
+	msgs := [][]byte{[]byte("batch"), []byte("sign"), []byte("mixin")}
+	path := []byte{1, 123, 0, 0}
+	sigs := testCMPBatchSignWithPath(ctx, require, nodes, public, msgs, crv, path)
+	require.Len(sigs, len(msgs))
+	_, cp, err := bitcoin.DeriveBIP32(public, common.DecodeHexOrPanic(chainCode), 123)
+	require.Nil(err)
+	for i, msg := range msgs {
+		err = bitcoin.VerifySignatureDER(cp, msg, sigs[i])
+		require.Nil(err)
+	}
+}
+
 func TestSSID(t *testing.T) {
 	require := require.New(t)
 
@@ -85,11 +292,170 @@ func TestSSID(t *testing.T) {
 	require.Equal("b4ee4f1ad7294abdb0d09699e420c085c377580f0397c0daa0dae5b272c75e495bdb77146775ddd347050d0093459204189b75bbe5c5cc534817fce62d25df1d", hex.EncodeToString(start.SSID()))
 }
 
+func TestDailyWorks(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-signer-test")
+	require.Nil(err)
+	store, err := OpenSQLite3Store(root + "/mpc.sqlite3")
+	require.Nil(err)
+	defer store.Close()
+
+	conf := &Configuration{MTG: &mtg.Configuration{}}
+	conf.MTG.Genesis.Members = []string{"member-id-3", "member-id-0", "member-id-2", "member-id-1"}
+	node := &Node{id: "member-id-0", conf: conf, store: store}
+
+	now := time.Date(2024, 3, 6, 2, 0, 0, 0, time.UTC)
+	works := map[string][]time.Duration{
+		"member-id-1": {time.Hour, 2 * time.Hour, 3 * time.Hour, 23 * time.Hour},
+		"member-id-2": {time.Hour, 12 * time.Hour, 25 * time.Hour, -time.Hour},
+		"member-id-3": {26 * time.Hour},
+	}
+	begin := now.Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	for signer, offsets := range works {
+		for i, offset := range offsets {
+			_, err := store.db.Exec("INSERT INTO session_works (session_id, signer_id, round, extra, created_at) VALUES (?, ?, ?, ?, ?)",
+				uuid.Must(uuid.NewV4()).String(), signer, i, "", begin.Add(offset))
+			require.Nil(err)
+		}
+	}
+	require.Equal([]byte{0, 255, 127, 0}, node.DailyWorks(ctx, now))
+}
+
+func TestSessionCulprits(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-signer-test")
+	require.Nil(err)
+	store, err := OpenSQLite3Store(root + "/mpc.sqlite3")
+	require.Nil(err)
+	defer store.Close()
+
+	members := []party.ID{
+		"0ba23d4a-0a5b-4f4e-9b6a-4e6e1c3cf8b1", "2c6f3f0e-5a09-44a4-8b4c-7f3b0f0d2a11",
+		"5d4b1e6a-7c1e-4c6f-a2d5-8e2c0a9b3f22", "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c33",
+	}
+	extra := encodeSessionCulprits(CulpritReasonTimeout, members[1:])
+	reason, culprits, err := decodeSessionCulprits(extra)
+	require.Nil(err)
+	require.Equal(CulpritReasonTimeout, reason)
+	require.Equal([]string{string(members[1]), string(members[2]), string(members[3])}, culprits)
+	_, _, err = decodeSessionCulprits(extra[:len(extra)-1])
+	require.NotNil(err)
+
+	now := time.Date(2024, 3, 6, 2, 0, 0, 0, time.UTC)
+	failed := uuid.Must(uuid.NewV4()).String()
+	err = store.WriteSessionCulpritsIfNotExist(ctx, failed, string(members[0]), []string{string(members[2])}, CulpritReasonAbort, now)
+	require.Nil(err)
+	err = store.WriteSessionCulpritsIfNotExist(ctx, failed, string(members[1]), []string{string(members[2])}, CulpritReasonAbort, now)
+	require.Nil(err)
+	err = store.WriteSessionCulpritsIfNotExist(ctx, failed, string(members[3]), []string{string(members[2])}, CulpritReasonAbort, now)
+	require.Nil(err)
+	// a single reporter can't harm the reputation of an honest member
+	lonely := uuid.Must(uuid.NewV4()).String()
+	err = store.WriteSessionCulpritsIfNotExist(ctx, lonely, string(members[2]), []string{string(members[0])}, CulpritReasonAbort, now)
+	require.Nil(err)
+
+	op := &common.Operation{Id: uuid.Must(uuid.NewV4()).String(), Type: common.OperationTypeSignInput, Curve: common.CurveSecp256k1ECDSABitcoin}
+	err = store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(op.Id)), 0, now, true)
+	require.Nil(err)
+	for i, id := range []party.ID{members[2], members[0], members[3], members[1]} {
+		err = store.PrepareSessionSignerIfNotExist(ctx, op.Id, string(id), now.Add(time.Duration(i+1)*time.Second))
+		require.Nil(err)
+	}
+	err = store.MarkSessionPrepared(ctx, op.Id, now.Add(time.Minute))
+	require.Nil(err)
+	signers, err := store.ListSessionPreparedMembers(ctx, op.Id, 3)
+	require.Nil(err)
+	require.Equal([]party.ID{members[0], members[3], members[1]}, signers)
+
+	// the reports after the session prepared don't change the signers
+	later := uuid.Must(uuid.NewV4()).String()
+	err = store.WriteSessionCulpritsIfNotExist(ctx, later, string(members[2]), []string{string(members[0])}, CulpritReasonTimeout, now.Add(2*time.Minute))
+	require.Nil(err)
+	signers, err = store.ListSessionPreparedMembers(ctx, op.Id, 3)
+	require.Nil(err)
+	require.Equal([]party.ID{members[0], members[3], members[1]}, signers)
+
+	reputations, err := store.ListMemberReputations(ctx, 3)
+	require.Nil(err)
+	require.Equal(map[string]int{string(members[2]): 1}, reputations)
+	reputations, err = store.ListMemberReputations(ctx, 1)
+	require.Nil(err)
+	require.Equal(map[string]int{string(members[0]): 2, string(members[2]): 1}, reputations)
+}
+
+func TestShareEncryption(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	root, err := os.MkdirTemp("", "safe-signer-test")
+	require.Nil(err)
+	store, err := OpenSQLite3Store(root + "/mpc.sqlite3")
+	require.Nil(err)
+	defer store.Close()
+
+	public := "0362c3b2c3e8d2b7b5d0b4d9e6e0fcd6a1e2c9e3c9c9b3f8f1d0e7d9a8b6c5d4e3"
+	share := []byte("the mpc share of the public key")
+	err = store.WriteKeyIfNotExists(ctx, uuid.Must(uuid.NewV4()).String(), common.CurveSecp256k1ECDSABitcoin, public, share, false)
+	require.Nil(err)
+
+	key, err := ShareEncryptionKey("", "passphrase", "app")
+	require.Nil(err)
+	require.Len(key, 32)
+	same, err := ShareEncryptionKey("", "passphrase", "app")
+	require.Nil(err)
+	require.Equal(key, same)
+	_, err = ShareEncryptionKey(root+"/key", "passphrase", "app")
+	require.NotNil(err)
+
+	err = store.EnableShareEncryption(ctx, key)
+	require.Nil(err)
+	var raw string
+	err = store.db.QueryRow("SELECT share FROM keys WHERE public=?", public).Scan(&raw)
+	require.Nil(err)
+	require.NotEqual(common.Base91Encode(share), raw)
+	_, _, conf, err := store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(public)))
+	require.Nil(err)
+	require.Equal(share, conf)
+	keys, err := store.ListUnbackupedKeys(ctx, 10)
+	require.Nil(err)
+	require.Len(keys, 1)
+	require.Equal(common.Base91Encode(share), keys[0].Share)
+
+	err = os.WriteFile(root+"/key", []byte(hex.EncodeToString(crypto.Sha256Hash([]byte("key")).Bytes())+"\n"), 0600)
+	require.Nil(err)
+	fileKey, err := ShareEncryptionKey(root+"/key", "", "app")
+	require.Nil(err)
+	err = store.EnableShareEncryption(ctx, fileKey)
+	require.NotNil(err)
+	err = store.EnableShareEncryption(ctx, nil)
+	require.NotNil(err)
+	_, err = store.RekeyShares(ctx, fileKey, key)
+	require.NotNil(err)
+	count, err := store.RekeyShares(ctx, key, fileKey)
+	require.Nil(err)
+	require.Equal(1, count)
+	_, _, conf, err = store.ReadKeyByFingerprint(ctx, hex.EncodeToString(common.Fingerprint(public)))
+	require.Nil(err)
+	require.Equal(share, conf)
+
+	count, err = store.RekeyShares(ctx, fileKey, nil)
+	require.Nil(err)
+	require.Equal(1, count)
+	err = store.db.QueryRow("SELECT share FROM keys WHERE public=?", public).Scan(&raw)
+	require.Nil(err)
+	require.Equal(common.Base91Encode(share), raw)
+	err = store.EnableShareEncryption(ctx, nil)
+	require.Nil(err)
+}
+
 func testCMPKeyGen(ctx context.Context, require *require.Assertions, nodes []*Node, crv byte) (string, []byte) {
 	sid := common.UniqueId("keygen", fmt.Sprint(400))
 	sequence := 4600000
-	for i := 0; i < 4; i++ {
-		node := nodes[i]
+	for i, node := range nodes {
 		op := &common.Operation{
 			Type:  common.OperationTypeKeygenInput,
 			Id:    sid,
@@ -112,7 +478,7 @@ func testCMPKeyGen(ctx context.Context, require *require.Assertions, nodes []*No
 
 		msg := common.MarshalJSONOrPanic(out)
 		network := node.network.(*testNetwork)
-		network.mtgChannel(nodes[i].id) <- msg
+		network.mtgChannel(node.id) <- msg
 	}
 
 	var public string
@@ -137,6 +503,244 @@ func testCMPKeyGen(ctx context.Context, require *require.Assertions, nodes []*No
 	return public, chainCode
 }
 
+func testSignerRefresh(ctx context.Context, require *require.Assertions, nodes []*Node, public string, crv byte) {
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	shares := make(map[party.ID][]byte)
+	for _, node := range nodes {
+		_, _, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		shares[node.id] = share
+	}
+
+	node := nodes[0]
+	sid := common.UniqueId("refresh", public)
+	op := &common.Operation{
+		Type:   common.OperationTypeRefreshInput,
+		Id:     sid,
+		Curve:  crv,
+		Public: public,
+	}
+	memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(op))
+	memo = hex.EncodeToString([]byte(memo))
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:           uuid.Must(uuid.NewV4()).String(),
+			TransactionHash:    crypto.Sha256Hash([]byte(op.Id)).String(),
+			AppId:              node.conf.AppId,
+			AssetId:            node.conf.KeeperAssetId,
+			Extra:              memo,
+			Amount:             decimal.NewFromInt(1),
+			SequencerCreatedAt: time.Now(),
+		},
+	}
+	op = TestProcessOutput(ctx, require, nodes, out, sid)
+	require.Equal(common.OperationTypeRefreshOutput, int(op.Type))
+	require.Equal(sid, op.Id)
+	require.Equal(crv, op.Curve)
+	require.Equal(public, op.Public)
+
+	for _, node := range nodes {
+		rp, rc, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		require.Equal(public, rp)
+		require.Equal(crv, rc)
+		require.False(bytes.Equal(shares[node.id], share))
+	}
+}
+
+func testSignerReshare(ctx context.Context, require *require.Assertions, nodes []*Node, public string, crv byte) {
+	node := nodes[0]
+	sid := common.UniqueId("reshare", public)
+	op := &common.Operation{
+		Type:   common.OperationTypeReshareInput,
+		Id:     sid,
+		Curve:  crv,
+		Public: public,
+		Extra:  common.EncodeSignerCommittee(node.GetMembers(), node.threshold),
+	}
+	memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(op))
+	memo = hex.EncodeToString([]byte(memo))
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:           uuid.Must(uuid.NewV4()).String(),
+			TransactionHash:    crypto.Sha256Hash([]byte(op.Id)).String(),
+			AppId:              node.conf.AppId,
+			AssetId:            node.conf.KeeperAssetId,
+			Extra:              memo,
+			Amount:             decimal.NewFromInt(1),
+			SequencerCreatedAt: time.Now(),
+		},
+	}
+	op = TestProcessOutput(ctx, require, nodes, out, sid)
+	require.Equal(common.OperationTypeReshareOutput, int(op.Type))
+	require.Equal(sid, op.Id)
+	require.Equal(crv, op.Curve)
+	require.Equal(public, op.Public)
+
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	for _, node := range nodes {
+		rp, rc, _, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		require.Equal(public, rp)
+		require.Equal(crv, rc)
+		keys, err := node.store.ListUnbackupedKeys(ctx, 100)
+		require.Nil(err)
+		require.Len(keys, 1)
+	}
+}
+
+func testCMPBatchSignWithPath(ctx context.Context, require *require.Assertions, nodes []*Node, public string, msgs [][]byte, crv byte, path []byte) [][]byte {
+	node := nodes[0]
+	payload := common.EncodeBatchItems(msgs)
+	TestWriteStorageExtra(ctx, nodes, payload)
+	digest := crypto.Sha256Hash(payload)
+	sid := common.UniqueId("batch", hex.EncodeToString(payload))
+	fingerPath := append(common.Fingerprint(public), path...)
+	sop := &common.Operation{
+		Type:   common.OperationTypeBatchSignInput,
+		Id:     sid,
+		Curve:  crv,
+		Public: hex.EncodeToString(fingerPath),
+		Extra:  digest[:],
+	}
+	memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(sop))
+	memo = hex.EncodeToString([]byte(memo))
+	out := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:           uuid.Must(uuid.NewV4()).String(),
+			TransactionHash:    crypto.Sha256Hash([]byte(sop.Id)).String(),
+			AppId:              node.conf.AppId,
+			AssetId:            node.conf.KeeperAssetId,
+			Extra:              memo,
+			Amount:             decimal.NewFromInt(1),
+			SequencerCreatedAt: time.Now(),
+		},
+	}
+	op := TestProcessOutput(ctx, require, nodes, out, sid)
+	require.Equal(common.OperationTypeBatchSignOutput, int(op.Type))
+	require.Equal(sid, op.Id)
+	require.Len(op.Extra, 32)
+
+	sigs, err := common.DecodeBatchItems(TestReadStorageExtra(ctx, node, op.Extra))
+	require.Nil(err)
+	return sigs
+}
+
+// testRunRounds runs the rounds of all members in process, the messages could
+// be tampered before delivered, and the first invalid message is returned
+func testRunRounds(sessions map[party.ID]round.Session, tamper func(*round.Message)) (map[party.ID]any, error) {
+	for {
+		var msgs []*round.Message
+		results := make(map[party.ID]any)
+		for id, s := range sessions {
+			out := make(chan *round.Message, 2*len(sessions))
+			next, err := s.Finalize(out)
+			if err != nil {
+				return nil, err
+			}
+			close(out)
+			for m := range out {
+				msgs = append(msgs, m)
+			}
+			switch r := next.(type) {
+			case *round.Abort:
+				return nil, r.Err
+			case *round.Output:
+				results[id] = r.Result
+			}
+			sessions[id] = next
+		}
+		if len(results) == len(sessions) {
+			return results, nil
+		}
+		slices.SortStableFunc(msgs, func(a, b *round.Message) int {
+			if a.Broadcast == b.Broadcast {
+				return 0
+			} else if a.Broadcast {
+				return -1
+			}
+			return 1
+		})
+		for _, msg := range msgs {
+			if tamper != nil {
+				tamper(msg)
+			}
+			for id, s := range sessions {
+				if id == msg.From || (msg.To != "" && msg.To != id) {
+					continue
+				}
+				if msg.Broadcast {
+					err := s.(round.BroadcastRound).StoreBroadcastMessage(*msg)
+					if err != nil {
+						return nil, err
+					}
+					continue
+				}
+				err := s.VerifyMessage(*msg)
+				if err != nil {
+					return nil, err
+				}
+				err = s.StoreMessage(*msg)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+}
+
+func testWaitPresignature(ctx context.Context, require *require.Assertions, node *Node, presignId string) {
+	timeout := time.Now().Add(time.Minute * 4)
+	for ; time.Now().Before(timeout); time.Sleep(3 * time.Second) {
+		var ready sql.NullTime
+		row := node.store.db.QueryRowContext(ctx, "SELECT ready_at FROM presignatures WHERE session_id=?", presignId)
+		err := row.Scan(&ready)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		require.Nil(err)
+		if ready.Valid {
+			return
+		}
+	}
+	require.Fail("presignature not ready", presignId)
+}
+
+func testSaverRestore(ctx context.Context, require *require.Assertions, nodes []*Node, public string) {
+	fingerprint := hex.EncodeToString(common.Fingerprint(public))
+	for _, node := range nodes {
+		root, err := os.MkdirTemp("", "safe-signer-restore-test-")
+		require.Nil(err)
+		store, err := OpenSQLite3Store(root + "/mpc.sqlite3")
+		require.Nil(err)
+		err = store.Migrate(ctx)
+		require.Nil(err)
+
+		count, err := RestoreKeysFromSaver(ctx, store, node.conf)
+		require.Nil(err)
+		require.Equal(1, count)
+		count, err = RestoreKeysFromSaver(ctx, store, node.conf)
+		require.Nil(err)
+		require.Equal(0, count)
+
+		_, crv, share, err := node.store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		rp, rc, rs, err := store.ReadKeyByFingerprint(ctx, fingerprint)
+		require.Nil(err)
+		require.Equal(public, rp)
+		require.Equal(crv, rc)
+		require.True(bytes.Equal(share, rs))
+		store.Close()
+	}
+
+	conf := *nodes[0].conf
+	conf.MTG = &mtg.Configuration{}
+	*conf.MTG = *nodes[0].conf.MTG
+	conf.MTG.App.AppId = nodes[1].conf.MTG.App.AppId
+	_, err := RestoreKeysFromSaver(ctx, nodes[0].store, &conf)
+	require.NotNil(err)
+}
+
 func testSaverItemsCheck(ctx context.Context, require *require.Assertions, nodes []*Node, saverStore *saver.SQLite3Store, count int) {
 	for _, node := range nodes {
 		items, err := saverStore.ListItemsForNode(ctx, string(node.id))
@@ -22,8 +22,9 @@ import (
 var SCHEMA string
 
 type SQLite3Store struct {
-	db    *sql.DB
-	mutex *sync.Mutex
+	db       *sql.DB
+	mutex    *sync.Mutex
+	shareKey []byte
 }
 
 func OpenSQLite3Store(path string) (*SQLite3Store, error) {
@@ -109,6 +110,118 @@ func (s *SQLite3Store) Migrate(ctx context.Context) error {
 	return tx.Commit()
 }
 
+// EnableShareEncryption checks the key against the one used to encrypt the
+// stored shares, and encrypts all plaintext shares if not encrypted before.
+// It should be called before any key read or written, and the nil key keeps
+// the shares in plaintext.
+func (s *SQLite3Store) EnableShareEncryption(ctx context.Context, key []byte) error {
+	id, err := s.ReadProperty(ctx, shareKeyIdPropertyKey)
+	if err != nil {
+		return err
+	}
+	switch id {
+	case shareKeyId(key):
+		s.shareKey = key
+		return nil
+	case "":
+		_, err = s.RekeyShares(ctx, nil, key)
+		return err
+	}
+	return fmt.Errorf("share key %s not match %s", shareKeyId(key), id)
+}
+
+// RekeyShares encrypts all shares with the new key in a single transaction,
+// and returns the number of shares. The nil new key decrypts all shares.
+func (s *SQLite3Store) RekeyShares(ctx context.Context, oldKey, newKey []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	row := tx.QueryRowContext(ctx, "SELECT value FROM properties WHERE key=?", shareKeyIdPropertyKey)
+	err = row.Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if id != shareKeyId(oldKey) {
+		return 0, fmt.Errorf("share key %s not match %s", shareKeyId(oldKey), id)
+	}
+
+	shares, err := rekeyTableShares(ctx, tx, "keys", "public", oldKey, newKey)
+	if err != nil {
+		return 0, err
+	}
+	_, err = rekeyTableShares(ctx, tx, "key_refreshes", "session_id", oldKey, newKey)
+	if err != nil {
+		return 0, err
+	}
+	_, err = rekeyTableShares(ctx, tx, "presignatures", "session_id", oldKey, newKey)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM properties WHERE key=?", shareKeyIdPropertyKey)
+	if err != nil {
+		return 0, fmt.Errorf("SQLite3Store DELETE properties %v", err)
+	}
+	if newKey != nil {
+		err = s.execOne(ctx, tx, "INSERT INTO properties (key, value, created_at) VALUES (?, ?, ?)",
+			shareKeyIdPropertyKey, shareKeyId(newKey), time.Now().UTC())
+		if err != nil {
+			return 0, fmt.Errorf("SQLite3Store INSERT properties %v", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	s.shareKey = newKey
+	return shares, nil
+}
+
+// the shares are always sealed with the public as additional data, and the
+// rows are identified by the primary key column of the table
+func rekeyTableShares(ctx context.Context, tx *sql.Tx, table, pk string, oldKey, newKey []byte) (int, error) {
+	query := fmt.Sprintf("SELECT %s, public, share FROM %s", pk, table)
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	type row struct{ public, share string }
+	shares := make(map[string]*row)
+	for rows.Next() {
+		var id string
+		var r row
+		err = rows.Scan(&id, &r.public, &r.share)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		shares[id] = &r
+	}
+	rows.Close()
+
+	for id, r := range shares {
+		conf, err := decryptShare(oldKey, r.public, r.share)
+		if err != nil {
+			return 0, fmt.Errorf("decryptShare(%s) => %v", r.public, err)
+		}
+		share := encryptShare(newKey, r.public, conf)
+		query := fmt.Sprintf("UPDATE %s SET share=? WHERE %s=?", table, pk)
+		_, err = tx.ExecContext(ctx, query, share, id)
+		if err != nil {
+			return 0, fmt.Errorf("SQLite3Store UPDATE %s %v", table, err)
+		}
+	}
+	return len(shares), nil
+}
+
 func (s *SQLite3Store) WriteKeyIfNotExists(ctx context.Context, sessionId string, curve uint8, public string, conf []byte, saved bool) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -125,7 +238,7 @@ func (s *SQLite3Store) WriteKeyIfNotExists(ctx context.Context, sessionId string
 	}
 
 	timestamp := time.Now().UTC()
-	share := common.Base91Encode(conf)
+	share := encryptShare(s.shareKey, public, conf)
 	fingerprint := hex.EncodeToString(common.Fingerprint(public))
 	cols := []string{"public", "fingerprint", "curve", "share", "session_id", "created_at"}
 	values := []any{public, fingerprint, curve, share, sessionId, timestamp}
@@ -148,6 +261,316 @@ func (s *SQLite3Store) WriteKeyIfNotExists(ctx context.Context, sessionId string
 	return tx.Commit()
 }
 
+// WriteKeyRefreshIfNotExists saves the refreshed share of the public, and
+// marks the session pending with the digest of the refreshed public shares.
+// The share replaces the current one only after all members agree on the
+// digest, see CommitKeyRefresh.
+func (s *SQLite3Store) WriteKeyRefreshIfNotExists(ctx context.Context, sessionId, public string, conf, digest []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT public FROM key_refreshes WHERE session_id=?", sessionId)
+	if err != nil || existed {
+		return err
+	}
+
+	timestamp := time.Now().UTC()
+	share := encryptShare(s.shareKey, public, conf)
+	cols := []string{"session_id", "public", "share", "digest", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("key_refreshes", cols),
+		sessionId, public, share, hex.EncodeToString(digest), timestamp)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT key_refreshes %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE sessions SET extra=?, state=?, updated_at=? WHERE session_id=? AND public=? AND state=?",
+		hex.EncodeToString(digest), common.RequestStatePending, timestamp, sessionId, public, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE sessions %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// CommitKeyRefresh replaces the share of the public with the refreshed or
+// reshared one of the session, and the key needs to be backed up again.
+func (s *SQLite3Store) CommitKeyRefresh(ctx context.Context, sessionId string, digest []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var public, share, sum string
+	var committedAt sql.NullTime
+	row := tx.QueryRowContext(ctx, "SELECT public, share, digest, committed_at FROM key_refreshes WHERE session_id=?", sessionId)
+	err = row.Scan(&public, &share, &sum, &committedAt)
+	if err != nil {
+		return err
+	}
+	if sum != hex.EncodeToString(digest) {
+		return fmt.Errorf("invalid refresh digest %s %x", sum, digest)
+	}
+	if committedAt.Valid {
+		return nil
+	}
+
+	timestamp := time.Now().UTC()
+	existed, err := s.checkExistence(ctx, tx, "SELECT curve FROM keys WHERE public=?", public)
+	if err != nil {
+		return err
+	}
+	if existed {
+		err = s.execOne(ctx, tx, "UPDATE keys SET share=?, backed_up_at=NULL WHERE public=?", share, public)
+		if err != nil {
+			return fmt.Errorf("SQLite3Store UPDATE keys %v", err)
+		}
+	} else {
+		// a new member of the reshared committee has no key before the commit
+		var curve uint8
+		row := tx.QueryRowContext(ctx, "SELECT curve FROM sessions WHERE session_id=?", sessionId)
+		err = row.Scan(&curve)
+		if err != nil {
+			return err
+		}
+		fingerprint := hex.EncodeToString(common.Fingerprint(public))
+		cols := []string{"public", "fingerprint", "curve", "share", "session_id", "created_at"}
+		err = s.execOne(ctx, tx, buildInsertionSQL("keys", cols), public, fingerprint, curve, share, sessionId, timestamp)
+		if err != nil {
+			return fmt.Errorf("SQLite3Store INSERT keys %v", err)
+		}
+	}
+	err = s.execOne(ctx, tx, "UPDATE key_refreshes SET committed_at=? WHERE session_id=? AND committed_at IS NULL", timestamp, sessionId)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE key_refreshes %v", err)
+	}
+
+	// the presignatures are bound to the old shares, so discard them all
+	_, err = tx.ExecContext(ctx, "UPDATE presignatures SET share=?, consumed_by=?, consumed_at=? WHERE public=? AND consumed_by IS NULL",
+		encryptShare(s.shareKey, public, nil), sessionId, timestamp, public)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE presignatures %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// WritePresignSessionIfNotExist creates the presign session of the key, and
+// the presignature without any share, which is saved by the members later.
+func (s *SQLite3Store) WritePresignSessionIfNotExist(ctx context.Context, op *common.Operation, public string, transaction crypto.Hash, outputIndex int, createdAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT session_id FROM sessions WHERE session_id=?", op.Id)
+	if err != nil || existed {
+		return err
+	}
+
+	cols := []string{"session_id", "mixin_hash", "mixin_index", "operation", "curve", "public",
+		"extra", "state", "created_at", "updated_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("sessions", cols), op.Id, transaction.String(), outputIndex,
+		op.Type, op.Curve, op.Public, hex.EncodeToString(op.Extra), common.RequestStateInitial, createdAt, createdAt)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT sessions %v", err)
+	}
+
+	cols = []string{"session_id", "public", "fingerprint", "members", "share", "digest", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("presignatures", cols), op.Id, public,
+		hex.EncodeToString(common.Fingerprint(public)), "", encryptShare(s.shareKey, public, nil), "", createdAt)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT presignatures %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// WritePresignatureShare saves the presignature share of the member, and marks
+// the session pending with the digest of the presignature public parts.
+func (s *SQLite3Store) WritePresignatureShare(ctx context.Context, sessionId string, share, digest []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var public string
+	row := tx.QueryRowContext(ctx, "SELECT public FROM presignatures WHERE session_id=? AND ready_at IS NULL", sessionId)
+	err = row.Scan(&public)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().UTC()
+	err = s.execOne(ctx, tx, "UPDATE presignatures SET share=? WHERE session_id=?",
+		encryptShare(s.shareKey, public, share), sessionId)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE presignatures %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE sessions SET extra=?, state=?, updated_at=? WHERE session_id=? AND state=? AND prepared_at IS NOT NULL",
+		hex.EncodeToString(digest), common.RequestStatePending, timestamp, sessionId, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE sessions %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// MarkPresignatureReady makes the presignature available to the sign sessions
+// after all its members agree on the digest, unless discarded before.
+func (s *SQLite3Store) MarkPresignatureReady(ctx context.Context, sessionId string, members []party.ID, digest []byte, readyAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT public FROM presignatures WHERE session_id=? AND ready_at IS NULL AND consumed_by IS NULL"
+	existed, err := s.checkExistence(ctx, tx, query, sessionId)
+	if err != nil || !existed {
+		return err
+	}
+
+	ids := make([]string, len(members))
+	for i, id := range members {
+		ids[i] = string(id)
+	}
+	err = s.execOne(ctx, tx, "UPDATE presignatures SET members=?, digest=?, ready_at=? WHERE session_id=? AND ready_at IS NULL",
+		strings.Join(ids, ","), hex.EncodeToString(digest), readyAt, sessionId)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE presignatures %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// ReadSessionPresignature returns the presignature consumed by the sign session
+func (s *SQLite3Store) ReadSessionPresignature(ctx context.Context, sessionId string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var id string
+	row := s.db.QueryRowContext(ctx, "SELECT session_id FROM presignatures WHERE consumed_by=? AND ready_at IS NOT NULL", sessionId)
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// ErasePresignatureShare returns the presignature share and erases it at
+// the same time, so that it could never be used twice.
+func (s *SQLite3Store) ErasePresignatureShare(ctx context.Context, sessionId string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var public, share string
+	row := tx.QueryRowContext(ctx, "SELECT public, share FROM presignatures WHERE session_id=?", sessionId)
+	err = row.Scan(&public, &share)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := decryptShare(s.shareKey, public, share)
+	if err != nil || len(conf) == 0 {
+		return nil, err
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE presignatures SET share=? WHERE session_id=?",
+		encryptShare(s.shareKey, public, nil), sessionId)
+	if err != nil {
+		return nil, fmt.Errorf("SQLite3Store UPDATE presignatures %v", err)
+	}
+
+	return conf, tx.Commit()
+}
+
+// CountPresignatures returns the number of presignatures available or still
+// in progress since the time, and the number of all presignatures of the key.
+func (s *SQLite3Store) CountPresignatures(ctx context.Context, fingerprint string, since time.Time) (int, int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var available, total int
+	query := "SELECT COUNT(*) FROM presignatures WHERE fingerprint=? AND consumed_by IS NULL AND (ready_at IS NOT NULL OR created_at>?)"
+	row := s.db.QueryRowContext(ctx, query, fingerprint, since)
+	err := row.Scan(&available)
+	if err != nil {
+		return 0, 0, err
+	}
+	row = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM presignatures WHERE fingerprint=?", fingerprint)
+	err = row.Scan(&total)
+	return available, total, err
+}
+
+// ListPresignKeys lists the ECDSA keys with sign sessions since the time,
+// and the shares are not included.
+func (s *SQLite3Store) ListPresignKeys(ctx context.Context, since time.Time, limit int) ([]*Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := fmt.Sprintf(`SELECT public, fingerprint, curve FROM keys WHERE curve IN (?, ?) AND fingerprint IN
+		(SELECT DISTINCT substr(public, 1, 16) FROM sessions WHERE operation=? AND created_at>?)
+		ORDER BY created_at ASC, public ASC LIMIT %d`, limit)
+	rows, err := s.db.QueryContext(ctx, query, common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum,
+		common.OperationTypeSignInput, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*Key
+	for rows.Next() {
+		var k Key
+		err := rows.Scan(&k.Public, &k.Fingerprint, &k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
+
+func (s *SQLite3Store) CheckSignSessionsInProgress(ctx context.Context) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var id string
+	query := "SELECT session_id FROM sessions WHERE operation IN (?, ?) AND state=? LIMIT 1"
+	row := s.db.QueryRowContext(ctx, query, common.OperationTypeSignInput, common.OperationTypeBatchSignInput, common.RequestStateInitial)
+	err := row.Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (s *SQLite3Store) ListUnbackupedKeys(ctx context.Context, threshold int) ([]*Key, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -167,11 +590,24 @@ func (s *SQLite3Store) ListUnbackupedKeys(ctx context.Context, threshold int) ([
 		if err != nil {
 			return nil, err
 		}
+		share, err := decryptShare(s.shareKey, k.Public, k.Share)
+		if err != nil {
+			return nil, fmt.Errorf("decryptShare(%s) => %v", k.Public, err)
+		}
+		k.Share = common.Base91Encode(share)
 		keys = append(keys, &k)
 	}
 	return keys, nil
 }
 
+func (s *SQLite3Store) CountUnbackupedKeys(ctx context.Context) (int, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM keys WHERE backed_up_at IS NULL")
+
+	var count int
+	err := row.Scan(&count)
+	return count, err
+}
+
 func (s *SQLite3Store) MarkKeyBackuped(ctx context.Context, public string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -204,7 +640,7 @@ func (s *SQLite3Store) ReadKeyByFingerprint(ctx context.Context, sum string) (st
 	} else if err != nil {
 		return "", 0, nil, err
 	}
-	conf, err := common.Base91Decode(share)
+	conf, err := decryptShare(s.shareKey, public, share)
 	return public, curve, conf, err
 }
 
@@ -348,17 +784,113 @@ func (s *SQLite3Store) UpdateSessionSigner(ctx context.Context, sessionId, signe
 	return tx.Commit()
 }
 
-func (s *SQLite3Store) ListSessionPreparedMembers(ctx context.Context, sessionId string, threshold int) ([]party.ID, error) {
+// WriteBatchSignatureIfNotExist records the signature of a message in the
+// batch sign session from a prepared member of the session
+func (s *SQLite3Store) WriteBatchSignatureIfNotExist(ctx context.Context, sessionId, signerId string, index int, signature []byte, createdAt time.Time, self bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT extra FROM session_signers WHERE session_id=? AND signer_id=?"
+	existed, err := s.checkExistence(ctx, tx, query, sessionId, signerId)
+	if err != nil || !existed {
+		return err
+	}
+
+	query = "SELECT signature FROM batch_signatures WHERE session_id=? AND signer_id=? AND message_index=?"
+	existed, err = s.checkExistence(ctx, tx, query, sessionId, signerId, index)
+	if err != nil || existed {
+		return err
+	}
+
+	cols := []string{"session_id", "signer_id", "message_index", "signature", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("batch_signatures", cols),
+		sessionId, signerId, index, hex.EncodeToString(signature), createdAt)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store INSERT batch_signatures %v", err)
+	}
+
+	existed, err = s.checkExistence(ctx, tx, "SELECT session_id FROM sessions WHERE session_id=? AND state=?", sessionId, common.RequestStateInitial)
+	if err != nil {
+		return err
+	}
+	if self && existed {
+		err = s.execOne(ctx, tx, "UPDATE sessions SET state=?, updated_at=? WHERE session_id=? AND state=?",
+			common.RequestStatePending, createdAt, sessionId, common.RequestStateInitial)
+		if err != nil {
+			return fmt.Errorf("SQLite3Store UPDATE sessions %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListBatchSignatures(ctx context.Context, sessionId string) (map[int]map[string]string, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	query := fmt.Sprintf("SELECT signer_id FROM session_signers WHERE session_id=? ORDER BY created_at ASC LIMIT %d", threshold)
+	query := "SELECT signer_id, message_index, signature FROM batch_signatures WHERE session_id=?"
 	rows, err := s.db.QueryContext(ctx, query, sessionId)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	signatures := make(map[int]map[string]string)
+	for rows.Next() {
+		var signer, signature string
+		var index int
+		err := rows.Scan(&signer, &index, &signature)
+		if err != nil {
+			return nil, err
+		}
+		if signatures[index] == nil {
+			signatures[index] = make(map[string]string)
+		}
+		signatures[index][signer] = signature
+	}
+	return signatures, nil
+}
+
+func (s *SQLite3Store) ListSessionPreparedMembers(ctx context.Context, sessionId string, threshold int) ([]party.ID, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// the sign session with a presignature must be signed by its members
+	var members string
+	query := "SELECT p.members FROM presignatures p JOIN sessions s ON s.session_id=p.consumed_by WHERE p.consumed_by=? AND s.operation=?"
+	row := s.db.QueryRowContext(ctx, query, sessionId, common.OperationTypeSignInput)
+	err := row.Scan(&members)
+	if err == nil {
+		var signers []party.ID
+		for _, id := range strings.Split(members, ",") {
+			signers = append(signers, party.ID(id))
+		}
+		return signers, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// prefer the members with fewer culprit reports before the session prepared,
+	// so the reputation is the same for all nodes when choosing the members. A
+	// session is only counted against a member if at least threshold members
+	// reported it, so a few malicious reporters can't push honest members out.
+	query = fmt.Sprintf(`SELECT ss.signer_id FROM session_signers ss JOIN sessions s ON s.session_id=ss.session_id
+		WHERE ss.session_id=? ORDER BY (SELECT COUNT(DISTINCT sc.session_id) FROM session_culprits sc
+		WHERE sc.signer_id=ss.signer_id AND sc.created_at<s.prepared_at AND (SELECT COUNT(DISTINCT sr.reporter_id)
+		FROM session_culprits sr WHERE sr.session_id=sc.session_id AND sr.signer_id=sc.signer_id AND sr.created_at<s.prepared_at)>=?) ASC,
+		ss.created_at ASC, ss.signer_id ASC LIMIT %d`, threshold)
+	rows, err := s.db.QueryContext(ctx, query, sessionId, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
 	var signers []party.ID
 	for rows.Next() {
 		var signer string
@@ -371,6 +903,63 @@ func (s *SQLite3Store) ListSessionPreparedMembers(ctx context.Context, sessionId
 	return signers, nil
 }
 
+func (s *SQLite3Store) WriteSessionCulpritsIfNotExist(ctx context.Context, sessionId, reporterId string, culprits []string, reason int, createdAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range culprits {
+		query := "SELECT reason FROM session_culprits WHERE session_id=? AND reporter_id=? AND signer_id=?"
+		existed, err := s.checkExistence(ctx, tx, query, sessionId, reporterId, id)
+		if err != nil {
+			return err
+		} else if existed {
+			continue
+		}
+		cols := []string{"session_id", "reporter_id", "signer_id", "reason", "created_at"}
+		err = s.execOne(ctx, tx, buildInsertionSQL("session_culprits", cols),
+			sessionId, reporterId, id, reason, createdAt)
+		if err != nil {
+			return fmt.Errorf("SQLite3Store INSERT session_culprits %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListMemberReputations counts the sessions in which each member has been
+// reported as a culprit by at least threshold members, the members never
+// reported are not included
+func (s *SQLite3Store) ListMemberReputations(ctx context.Context, threshold int) (map[string]int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := `SELECT signer_id, COUNT(*) FROM (SELECT signer_id, session_id FROM session_culprits
+		GROUP BY signer_id, session_id HAVING COUNT(DISTINCT reporter_id)>=?) GROUP BY signer_id`
+	rows, err := s.db.QueryContext(ctx, query, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reputations := make(map[string]int)
+	for rows.Next() {
+		var signer string
+		var count int
+		err := rows.Scan(&signer, &count)
+		if err != nil {
+			return nil, err
+		}
+		reputations[signer] = count
+	}
+	return reputations, nil
+}
+
 func (s *SQLite3Store) ListSessionSignerResults(ctx context.Context, sessionId string) (map[string]string, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -507,9 +1096,81 @@ func (s *SQLite3Store) MarkSessionPrepared(ctx context.Context, sessionId string
 		return fmt.Errorf("SQLite3Store UPDATE sessions %v", err)
 	}
 
+	var operation, curve uint8
+	var public string
+	row := tx.QueryRowContext(ctx, "SELECT operation, curve, public FROM sessions WHERE session_id=?", sessionId)
+	err = row.Scan(&operation, &curve, &public)
+	if err != nil {
+		return err
+	}
+	switch curve {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSAEthereum:
+		if operation != common.OperationTypeSignInput {
+			break
+		}
+		err = s.consumePresignature(ctx, tx, sessionId, public[:16], preparedAt)
+		if err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
+// the oldest ready presignature of the key, whose members have all prepared
+// the sign session, is consumed by the session
+func (s *SQLite3Store) consumePresignature(ctx context.Context, tx *sql.Tx, sessionId, fingerprint string, consumedAt time.Time) error {
+	rows, err := tx.QueryContext(ctx, "SELECT signer_id FROM session_signers WHERE session_id=?", sessionId)
+	if err != nil {
+		return err
+	}
+	prepared := make(map[string]bool)
+	for rows.Next() {
+		var signer string
+		err = rows.Scan(&signer)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		prepared[signer] = true
+	}
+	rows.Close()
+
+	query := "SELECT session_id, members FROM presignatures WHERE fingerprint=? AND ready_at IS NOT NULL AND consumed_by IS NULL ORDER BY created_at ASC, session_id ASC"
+	rows, err = tx.QueryContext(ctx, query, fingerprint)
+	if err != nil {
+		return err
+	}
+	var presignId string
+	for rows.Next() {
+		var id, members string
+		err = rows.Scan(&id, &members)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		ready := true
+		for _, m := range strings.Split(members, ",") {
+			ready = ready && prepared[m]
+		}
+		if ready {
+			presignId = id
+			break
+		}
+	}
+	rows.Close()
+	if presignId == "" {
+		return nil
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE presignatures SET consumed_by=?, consumed_at=? WHERE session_id=? AND consumed_by IS NULL",
+		sessionId, consumedAt, presignId)
+	if err != nil {
+		return fmt.Errorf("SQLite3Store UPDATE presignatures %v", err)
+	}
+	return nil
+}
+
 func (s *SQLite3Store) MarkSessionDone(ctx context.Context, sessionId string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -638,8 +1299,18 @@ func (s *SQLite3Store) ListPreparedSessions(ctx context.Context, limit int) ([]*
 	defer s.mutex.Unlock()
 
 	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at"
-	sql := fmt.Sprintf("SELECT %s FROM sessions WHERE state=? AND committed_at IS NOT NULL AND prepared_at IS NOT NULL ORDER BY operation DESC, created_at ASC, session_id ASC LIMIT %d", cols, limit)
-	return s.listSessionsByQuery(ctx, sql, common.RequestStateInitial)
+	// the presign sessions run only after all other sessions
+	sql := fmt.Sprintf("SELECT %s FROM sessions WHERE state=? AND committed_at IS NOT NULL AND prepared_at IS NOT NULL ORDER BY operation=? ASC, operation DESC, created_at ASC, session_id ASC LIMIT %d", cols, limit)
+	return s.listSessionsByQuery(ctx, sql, common.RequestStateInitial, common.OperationTypePresignInput)
+}
+
+func (s *SQLite3Store) ListUnpreparedSessions(ctx context.Context, before time.Time, limit int) ([]*Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at"
+	sql := fmt.Sprintf("SELECT %s FROM sessions WHERE state=? AND committed_at IS NOT NULL AND prepared_at IS NULL AND created_at<? ORDER BY created_at ASC, session_id ASC LIMIT %d", cols, limit)
+	return s.listSessionsByQuery(ctx, sql, common.RequestStateInitial, before)
 }
 
 func (s *SQLite3Store) ListPendingSessions(ctx context.Context, limit int) ([]*Session, error) {
@@ -651,8 +1322,8 @@ func (s *SQLite3Store) ListPendingSessions(ctx context.Context, limit int) ([]*S
 	return s.listSessionsByQuery(ctx, sql, common.RequestStatePending)
 }
 
-func (s *SQLite3Store) listSessionsByQuery(ctx context.Context, sql string, state int) ([]*Session, error) {
-	rows, err := s.db.QueryContext(ctx, sql, state)
+func (s *SQLite3Store) listSessionsByQuery(ctx context.Context, sql string, params ...any) ([]*Session, error) {
+	rows, err := s.db.QueryContext(ctx, sql, params...)
 	if err != nil {
 		return nil, err
 	}
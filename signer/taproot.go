@@ -11,6 +11,7 @@ import (
 	"github.com/MixinNetwork/multi-party-sig/pkg/party"
 	"github.com/MixinNetwork/multi-party-sig/pkg/taproot"
 	"github.com/MixinNetwork/multi-party-sig/protocols/frost"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/common"
 )
 
@@ -50,6 +51,9 @@ func (node *Node) taprootSign(ctx context.Context, members []party.ID, public st
 	if hex.EncodeToString(conf.PublicKey) != public {
 		panic(public)
 	}
+	if len(m) == bitcoin.TaprootKeySpendMessageSize {
+		return node.taprootMuSigSign(ctx, conf, members, public, m, sessionId)
+	}
 
 	start, err := frost.SignTaproot(conf, members, m)(sessionId)
 	if err != nil {
@@ -71,3 +75,32 @@ func (node *Node) taprootSign(ctx context.Context, members []party.ID, public st
 		SSID:      start.SSID(),
 	}, nil
 }
+
+// taprootMuSigSign makes the signer nonce and partial signature for the key
+// spend of a taproot safe, the holder combines it to the final signature
+func (node *Node) taprootMuSigSign(ctx context.Context, conf *frost.TaprootConfig, members []party.ID, public string, m []byte, sessionId []byte) (*SignResult, error) {
+	ks, err := bitcoin.ParseTaprootKeySpendMessage(m, public)
+	if err != nil {
+		return nil, fmt.Errorf("bitcoin.ParseTaprootKeySpendMessage(%x, %s) => %v", m, public, err)
+	}
+	start, err := taprootMuSigSession(conf, members, ks)(sessionId)
+	if err != nil {
+		return nil, fmt.Errorf("musig.Start(%x, %x) => %v", sessionId, m, err)
+	}
+
+	signResult, err := node.handlerLoop(ctx, start, sessionId, taprootSignRoundTimeout)
+	if err != nil {
+		return nil, err
+	}
+	signature := signResult.([]byte)
+	logger.Printf("node.taprootMuSigSign(%x, %s, %x) => %x", sessionId, public, m, signature)
+	err = ks.VerifySignerPartial(signature)
+	if err != nil {
+		return nil, fmt.Errorf("node.taprootMuSigSign(%x, %s, %x) => %x %v", sessionId, public, m, signature, err)
+	}
+
+	return &SignResult{
+		Signature: signature,
+		SSID:      start.SSID(),
+	}, nil
+}
@@ -2,6 +2,7 @@ package signer
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -27,10 +28,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-type partyContextTyp string
-
-const partyContextKey = partyContextTyp("party")
-
 func TestPrepare(require *require.Assertions) (context.Context, []*Node, *saver.SQLite3Store) {
 	logger.SetLevel(logger.INFO)
 	ctx := context.Background()
@@ -46,11 +43,25 @@ func TestPrepare(require *require.Assertions) (context.Context, []*Node, *saver.
 		nodes[i] = testBuildNode(ctx, require, root, i, saverStore, port)
 	}
 
-	network := newTestNetwork(nodes[0].GetPartySlice())
+	peers := make([]*messenger.DirectPeer, len(nodes))
+	for i, node := range nodes {
+		node.conf.Network = &NetworkConfiguration{
+			Listen: fmt.Sprintf("127.0.0.1:%d", getFreePort()),
+		}
+		peers[i] = &messenger.DirectPeer{
+			Id:        string(node.id),
+			Address:   node.conf.Network.Listen,
+			PublicKey: messenger.DirectPublicKey(node.conf.Direct().Key),
+		}
+	}
+
+	group := newTestMTGNetwork(nodes[0].GetPartySlice())
 	for i := 0; i < 4; i++ {
-		nodes[i].network = network
-		ctx = context.WithValue(ctx, partyContextKey, string(nodes[i].id))
-		go network.mtgLoop(ctx, nodes[i])
+		nodes[i].conf.Network.Peers = peers
+		direct, err := messenger.NewDirectMessenger(ctx, nodes[i].conf.Direct())
+		require.Nil(err)
+		nodes[i].network = &testNetwork{Network: direct, testMTGNetwork: group}
+		go group.mtgLoop(ctx, nodes[i])
 		go nodes[i].loopInitialSessions(ctx)
 		go nodes[i].loopPreparedSessions(ctx)
 		go nodes[i].loopPendingSessions(ctx)
@@ -78,6 +89,53 @@ func TestFROSTPrepareKeys(ctx context.Context, require *require.Assertions, node
 	return public
 }
 
+// TestFROSTKeyGen runs the keygen of the curve with the nodes, and returns
+// the public key and chain code in the keygen output to the keeper
+func TestFROSTKeyGen(ctx context.Context, require *require.Assertions, nodes []*Node, curve uint8) (string, string) {
+	sid := common.UniqueId("keygen", fmt.Sprint(curve))
+	for i := 0; i < 4; i++ {
+		node := nodes[i]
+		op := &common.Operation{
+			Type:  common.OperationTypeKeygenInput,
+			Id:    sid,
+			Curve: curve,
+		}
+		memo := mtg.EncodeMixinExtraBase64(node.conf.AppId, node.encryptOperation(op))
+		memo = hex.EncodeToString([]byte(memo))
+		out := &mtg.Action{
+			UnifiedOutput: mtg.UnifiedOutput{
+				OutputId:           uuid.Must(uuid.NewV4()).String(),
+				TransactionHash:    crypto.Sha256Hash([]byte(op.Id)).String(),
+				AppId:              node.conf.AppId,
+				AssetId:            node.conf.KeeperAssetId,
+				Extra:              memo,
+				Amount:             decimal.NewFromInt(1),
+				SequencerCreatedAt: time.Now(),
+			},
+		}
+
+		msg := common.MarshalJSONOrPanic(out)
+		network := node.network.(*testNetwork)
+		network.mtgChannel(nodes[i].id) <- msg
+	}
+
+	var public, chainCode string
+	for _, node := range nodes {
+		op := testWaitOperation(ctx, node, sid)
+		logger.Verbosef("testWaitOperation(%s, %s) => %v\n", node.id, sid, op)
+		require.Equal(common.OperationTypeKeygenOutput, int(op.Type))
+		require.Equal(sid, op.Id)
+		require.Equal(curve, op.Curve)
+		require.Len(op.Public, 64)
+		require.Len(op.Extra, 34)
+		require.Equal(op.Extra[0], byte(common.RequestRoleSigner))
+		require.Equal(op.Extra[33], byte(common.RequestFlagNone))
+		public = op.Public
+		chainCode = hex.EncodeToString(op.Extra[1:33])
+	}
+	return public, chainCode
+}
+
 func TestCMPPrepareKeys(ctx context.Context, require *require.Assertions, nodes []*Node, crv byte) (string, string) {
 	const public = "02bf0a7fa4b7905a0de5ab60a5322529e1a591ddd1ee53df82e751e8adb4bed08c"
 	const chainCode = "f555b08a9871213c0d52fee12e1bd365990b956880491b2b1a106f84584aa3a2"
@@ -168,9 +226,9 @@ func testCMPSignWithPath(ctx context.Context, require *require.Assertions, nodes
 func TestProcessOutput(ctx context.Context, require *require.Assertions, nodes []*Node, out *mtg.Action, sessionId string) *common.Operation {
 	out.TestAttachActionToGroup(nodes[0].group)
 	network := nodes[0].network.(*testNetwork)
-	for i := 0; i < 4; i++ {
+	for _, node := range nodes {
 		data := common.MarshalJSONOrPanic(out)
-		network.mtgChannel(nodes[i].id) <- data
+		network.mtgChannel(node.id) <- data
 	}
 
 	var op *common.Operation
@@ -181,6 +239,30 @@ func TestProcessOutput(ctx context.Context, require *require.Assertions, nodes [
 	return op
 }
 
+// TestWriteStorageExtra makes the extra readable by the nodes as the storage
+// transaction referenced by the keeper output
+func TestWriteStorageExtra(ctx context.Context, nodes []*Node, extra []byte) {
+	for _, node := range nodes {
+		testWriteStorageExtra(ctx, node, extra)
+	}
+}
+
+func TestReadStorageExtra(ctx context.Context, node *Node, digest []byte) []byte {
+	val, err := node.store.ReadProperty(ctx, hex.EncodeToString(digest))
+	if err != nil {
+		panic(err)
+	}
+	return common.DecodeHexOrPanic(val)
+}
+
+func testWriteStorageExtra(ctx context.Context, node *Node, extra []byte) {
+	sum := sha256.Sum256(extra)
+	err := node.store.WriteProperty(ctx, hex.EncodeToString(sum[:]), hex.EncodeToString(extra))
+	if err != nil {
+		panic(err)
+	}
+}
+
 func testBuildNode(ctx context.Context, require *require.Assertions, root string, i int, saverStore *saver.SQLite3Store, port int) *Node {
 	f, _ := os.ReadFile("../config/example.toml")
 	var conf struct {
@@ -262,28 +344,32 @@ func testStartSaver(require *require.Assertions) (*saver.SQLite3Store, int) {
 	return store, port
 }
 
+// testNetwork sends the signer messages by the direct messenger, and
+// simulates the MTG outputs delivery to all the group members
 type testNetwork struct {
+	Network
+	*testMTGNetwork
+}
+
+type testMTGNetwork struct {
 	parties     party.IDSlice
-	msgChannels map[party.ID]chan []byte
 	mtgChannels map[party.ID]chan []byte
 	mtx         sync.Mutex
 }
 
-func newTestNetwork(parties party.IDSlice) *testNetwork {
-	n := &testNetwork{
+func newTestMTGNetwork(parties party.IDSlice) *testMTGNetwork {
+	n := &testMTGNetwork{
 		parties:     parties,
-		msgChannels: make(map[party.ID]chan []byte, 2*len(parties)),
 		mtgChannels: make(map[party.ID]chan []byte, 2*len(parties)),
 	}
 	N := len(n.parties)
 	for _, id := range n.parties {
-		n.msgChannels[id] = make(chan []byte, N*N)
 		n.mtgChannels[id] = make(chan []byte, N*N)
 	}
 	return n
 }
 
-func (n *testNetwork) mtgLoop(ctx context.Context, node *Node) {
+func (n *testMTGNetwork) mtgLoop(ctx context.Context, node *Node) {
 	filter := make(map[string]bool)
 	loop := n.mtgChannels[node.id]
 	logger.Printf("loop: %s %d", node.id, len(loop))
@@ -300,6 +386,10 @@ func (n *testNetwork) mtgLoop(ctx context.Context, node *Node) {
 			panic(asset)
 		}
 		for _, t := range ts {
+			if t.AssetId == mtg.StorageAssetId {
+				testWriteStorageExtra(ctx, node, []byte(t.Memo))
+				continue
+			}
 			b := common.AESDecrypt(node.aesKey[:], []byte(t.Memo))
 			op, err := common.DecodeOperation(b)
 			if err != nil {
@@ -332,50 +422,44 @@ func (node *Node) mtgQueueTestOutput(ctx context.Context, memo []byte) error {
 	return network.QueueMTGOutput(ctx, data)
 }
 
-func (n *testNetwork) ReceiveMessage(ctx context.Context) (*messenger.MixinMessage, error) {
-	id := ctx.Value(partyContextKey).(string)
-	msb := <-n.msgChannel(party.ID(id))
-	_, msg, _ := unmarshalSessionMessage(msb)
-	return &messenger.MixinMessage{
-		Peer:      string(msg.From),
-		Data:      msb,
-		CreatedAt: time.Now(),
-	}, nil
-}
-
-func (n *testNetwork) QueueMessage(ctx context.Context, receiver string, b []byte) error {
-	sessionId, msg, err := unmarshalSessionMessage(b)
-	logger.Verbosef("test.QueueMessage(%s) => %x %v %v", receiver, sessionId, msg, err)
-	if err != nil {
-		return err
-	}
-	n.msgChannel(party.ID(receiver)) <- marshalSessionMessage(sessionId, msg)
-	logger.Verbosef("test.Send(%s) => %x %v %v", receiver, sessionId, msg, err)
-	return nil
-}
-
-func (n *testNetwork) QueueMTGOutput(ctx context.Context, b []byte) error {
+func (n *testMTGNetwork) QueueMTGOutput(ctx context.Context, b []byte) error {
 	n.mtx.Lock()
 	defer n.mtx.Unlock()
 
-	for _, c := range n.mtgChannels {
-		c <- b
+	for _, id := range n.parties {
+		n.mtgChannels[id] <- b
 	}
 	return nil
 }
 
-func (n *testNetwork) mtgChannel(id party.ID) chan []byte {
-	n.mtx.Lock()
-	defer n.mtx.Unlock()
+// testCommitteeChange simulates a signer group reconfiguration, the members
+// and threshold of all committee nodes are updated, and the MTG outputs are
+// only delivered to the committee nodes thereafter
+func testCommitteeChange(nodes, committee []*Node, threshold int) {
+	members := make([]string, len(committee))
+	parties := make(party.IDSlice, len(committee))
+	for i, node := range committee {
+		members[i] = string(node.id)
+		parties[i] = node.id
+	}
+	for _, node := range committee {
+		node.conf.MTG.Genesis.Members = members
+		node.conf.MTG.Genesis.Threshold = len(members)*2/3 + 1
+		node.conf.Threshold = threshold
+		node.threshold = threshold
+	}
 
-	return n.mtgChannels[id]
+	network := nodes[0].network.(*testNetwork)
+	network.mtx.Lock()
+	network.parties = party.NewIDSlice(parties)
+	network.mtx.Unlock()
 }
 
-func (n *testNetwork) msgChannel(id party.ID) chan []byte {
+func (n *testMTGNetwork) mtgChannel(id party.ID) chan []byte {
 	n.mtx.Lock()
 	defer n.mtx.Unlock()
 
-	return n.msgChannels[id]
+	return n.mtgChannels[id]
 }
 
 var (
@@ -2,13 +2,70 @@ package signer
 
 import (
 	"context"
-	"slices"
+	"fmt"
 	"time"
 
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/custodian"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
 )
 
-// TODO put all works query to the custodian module
+// the works of a day are voted after a delay, so the sessions at the end of
+// the day have enough time to be finished by all signers
+const custodianVoteDelay = time.Hour
+
+func (node *Node) loopDailyWorks(ctx context.Context) {
+	day := time.Hour * 24
+	for {
+		time.Sleep(time.Minute)
+		now := time.Now().UTC()
+		if now.Sub(now.Truncate(day)) < custodianVoteDelay {
+			continue
+		}
+		begin := now.Truncate(day).Add(-day)
+		key := fmt.Sprintf("CUSTODIAN:WORKS:%d", begin.Unix())
+		voted, err := node.store.ReadProperty(ctx, key)
+		if err != nil {
+			panic(err)
+		}
+		if voted != "" {
+			continue
+		}
+
+		works := node.DailyWorks(ctx, now)
+		traceId := fmt.Sprintf("CUSTODIAN:%s:WORKS:%d", node.id, begin.Unix())
+		err = node.sendCustodianTransaction(ctx, custodian.EncodeWorksVote(begin, works), traceId)
+		logger.Printf("node.sendCustodianTransaction(%s, %x) => %v", begin, works, err)
+		if err != nil {
+			continue
+		}
+		traceId = fmt.Sprintf("CUSTODIAN:%s:FINALIZE:%d", node.id, begin.Unix())
+		err = node.sendCustodianTransaction(ctx, custodian.EncodeFinalizeWorks(begin), traceId)
+		logger.Printf("node.sendCustodianTransaction(%s) => %v", begin, err)
+		if err != nil {
+			continue
+		}
+		err = node.store.WriteProperty(ctx, key, fmt.Sprintf("%x", works))
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (node *Node) sendCustodianTransaction(ctx context.Context, memo []byte, traceId string) error {
+	receivers := node.GetMembers()
+	threshold := node.conf.MTG.Genesis.Threshold
+	amount := decimal.NewFromInt(1)
+	traceId = common.UniqueId(traceId, fmt.Sprintf("MTG:%v:%d", receivers, threshold))
+
+	m := mtg.EncodeMixinExtraBase64(node.conf.CustodianAppId, memo)
+	_, err := common.SendTransactionUntilSufficient(ctx, node.mixin, []string{node.mixin.ClientID}, 1, receivers, threshold, amount, traceId, node.conf.AssetId, m, node.conf.MTG.App.SpendPrivateKey)
+	return err
+}
+
 func (node *Node) DailyWorks(ctx context.Context, now time.Time) []byte {
 	day := time.Hour * 24
 	end := now.UTC().Truncate(day)
@@ -25,7 +82,7 @@ func (node *Node) DailyWorks(ctx context.Context, now time.Time) []byte {
 		}
 	}
 
-	return normalizeWorks(works)
+	return custodian.NormalizeWorks(works)
 }
 
 func (s *SQLite3Store) CountDailyWorks(ctx context.Context, members []party.ID, begin, end time.Time) ([]int, error) {
@@ -52,12 +109,3 @@ func (s *SQLite3Store) CountDailyWorks(ctx context.Context, members []party.ID,
 
 	return works, nil
 }
-
-func normalizeWorks(works []int) []byte {
-	max := slices.Max(works)
-	norms := make([]byte, len(works))
-	for i, w := range works {
-		norms[i] = byte(255 * w / max)
-	}
-	return norms
-}